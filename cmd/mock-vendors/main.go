@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"kyc-service/pkg/mockvendor"
+)
+
+// mock-vendors 独立运行的 OCR/人脸/活体模拟供应商，供本地联调、CI 与 kyc-loadtest 压测使用。
+// 将 third_party 配置指向本服务即可，例如 ocr_service.url=http://127.0.0.1:18090/vrlOCR。
+func main() {
+	addr := flag.String("addr", ":18090", "监听地址")
+	latency := flag.Duration("latency", 0, "每个请求的固定延迟")
+	jitter := flag.Duration("jitter", 0, "叠加的随机延迟上限")
+	errorRate := flag.Float64("error-rate", 0, "随机返回 HTTP 500 的比例(0-1)")
+	seed := flag.Int64("seed", 1, "随机数种子")
+	fixtures := flag.String("fixtures", "", "fixture JSON 文件路径")
+	flag.Parse()
+
+	opts := mockvendor.Options{Latency: *latency, Jitter: *jitter, ErrorRate: *errorRate, Seed: *seed}
+	if *fixtures != "" {
+		fx, err := mockvendor.LoadFixtures(*fixtures)
+		if err != nil {
+			log.Fatalf("加载fixture失败: %v", err)
+		}
+		opts.Fixtures = fx
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: mockvendor.New(opts), ReadHeaderTimeout: 10 * time.Second}
+	log.Printf("模拟供应商已启动: %s (latency=%s jitter=%s error_rate=%.2f fixtures=%d)", *addr, *latency, *jitter, *errorRate, len(opts.Fixtures))
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalf("模拟供应商服务退出: %v", err)
+	}
+}
@@ -171,6 +171,13 @@ func main() {
 	logger.Init(cfg.LogLevel)
 	log := logger.GetLogger()
 
+	// use_mock 时启动内置模拟供应商，并将第三方服务地址指向它
+	if cfg.UseMock {
+		if err := startMockVendors(cfg); err != nil {
+			log.Fatalf("模拟供应商启动失败: %v", err)
+		}
+	}
+
 	// 初始化链路追踪
 	tracerCleanup, err := tracing.Init(cfg)
 	if err != nil {
@@ -239,6 +246,16 @@ func main() {
 	`).Error; err != nil {
 		log.Warnf("创建roles表失败: %v", err)
 	}
+	// 组织自定义角色：org_id 为空的是全局角色
+	if err := db.Exec(`ALTER TABLE roles ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT ''`).Error; err != nil {
+		log.Warnf("roles.org_id 列创建失败: %v", err)
+	}
+	if err := db.Exec(`ALTER TABLE roles ADD COLUMN IF NOT EXISTS created_by VARCHAR(64)`).Error; err != nil {
+		log.Warnf("roles.created_by 列创建失败: %v", err)
+	}
+	if err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_roles_org_id ON roles(org_id)`).Error; err != nil {
+		log.Warnf("roles.org_id 索引创建失败: %v", err)
+	}
 
 	if err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS role_permissions (
@@ -254,6 +271,9 @@ func main() {
 	if err := db.Exec(`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS usage_summary JSONB DEFAULT '{}'::jsonb`).Error; err != nil {
 		log.Warnf("organizations.usage_summary 列创建失败: %v", err)
 	}
+	if err := db.Exec(`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS timezone VARCHAR(64)`).Error; err != nil {
+		log.Warnf("organizations.timezone 列创建失败: %v", err)
+	}
 	if err := db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS is_platform_admin BOOLEAN DEFAULT FALSE`).Error; err != nil {
 		log.Warnf("users.is_platform_admin 列创建失败: %v", err)
 	}
@@ -263,6 +283,15 @@ func main() {
 	if err := db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_active_org_id UUID`).Error; err != nil {
 		log.Warnf("users.last_active_org_id 列创建失败: %v", err)
 	}
+	if err := db.Exec(`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS require_mfa BOOLEAN DEFAULT FALSE`).Error; err != nil {
+		log.Warnf("organizations.require_mfa 列创建失败: %v", err)
+	}
+	if err := db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret_enc TEXT`).Error; err != nil {
+		log.Warnf("users.totp_secret_enc 列创建失败: %v", err)
+	}
+	if err := db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN DEFAULT FALSE`).Error; err != nil {
+		log.Warnf("users.totp_enabled 列创建失败: %v", err)
+	}
 	// 头像字段长度放宽以兼容外部URL
 	if err := db.Exec(`DO $$ BEGIN IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name='users' AND column_name='avatar') THEN ALTER TABLE users ALTER COLUMN avatar TYPE TEXT; END IF; END $$;`).Error; err != nil {
 		log.Warnf("users.avatar 列类型调整失败: %v", err)
@@ -507,6 +536,35 @@ func main() {
 		log.Warnf("api_request_logs.api_key_owner_id 列创建失败: %v", err)
 	}
 
+	// kyc_requests.org_id 回填：早期请求没有记录组织。依次取同ID异步任务的组织、提交者API Key所属组织、
+	// 提交者唯一所属的组织；有多个候选组织的不猜测。仍无组织的请求只按系统默认策略做保留期清理
+	// （清理报告中 org_id 为空的一项），且不会出现在任何组织的数据主体查找中
+	if err := db.Exec(`
+	DO $$
+	BEGIN
+	    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name='kyc_requests' AND column_name='org_id') THEN
+	        UPDATE kyc_requests r SET org_id = j.org_id
+	          FROM kyc_jobs j
+	         WHERE j.id = r.id AND COALESCE(r.org_id, '') = '' AND COALESCE(j.org_id, '') <> '';
+	        UPDATE kyc_requests r SET org_id = k.org_id
+	          FROM (SELECT user_id::text AS user_id, MIN(org_id::text) AS org_id FROM api_keys
+	                 WHERE COALESCE(org_id::text, '') <> '' GROUP BY user_id::text HAVING COUNT(DISTINCT org_id) = 1) k
+	         WHERE k.user_id = r.user_id AND COALESCE(r.org_id, '') = '';
+	        UPDATE kyc_requests r SET org_id = m.org_id
+	          FROM (SELECT user_id::text AS user_id, MIN(organization_id::text) AS org_id FROM organization_members
+	                 GROUP BY user_id::text HAVING COUNT(DISTINCT organization_id) = 1) m
+	         WHERE m.user_id = r.user_id AND COALESCE(r.org_id, '') = '';
+	    END IF;
+	END $$;
+	`).Error; err != nil {
+		log.Warnf("kyc_requests.org_id 回填失败: %v", err)
+	} else {
+		var unowned int64
+		if err := db.Raw(`SELECT COUNT(*) FROM kyc_requests WHERE COALESCE(org_id, '') = ''`).Scan(&unowned).Error; err == nil && unowned > 0 {
+			log.Warnf("kyc_requests 中仍有 %d 条请求无法确定所属组织：只按系统默认保留策略清理，数据主体查找不到，需人工核对 user_id 后补填 org_id", unowned)
+		}
+	}
+
 	// 扩展 oauth_clients 表以支持IP白名单与速率限制
 	if err := db.Exec(`
 	DO $$
@@ -525,6 +583,61 @@ func main() {
 		log.Warnf("oauth_clients 列扩展失败: %v", err)
 	}
 
+	// OAuth 客户端密钥改为哈希存储：补齐列并迁移旧版明文密钥（与 Go 侧 SHA-256 十六进制一致）
+	if err := db.Exec(`
+	DO $$
+	BEGIN
+	    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name='oauth_clients') THEN
+	        ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS secret_hash TEXT;
+	        ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS secret_enc TEXT;
+	        ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS previous_secret_hash TEXT;
+	        ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMPTZ;
+	        ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS jwks TEXT;
+	        UPDATE oauth_clients
+	           SET secret_hash = encode(sha256(convert_to(secret, 'UTF8')), 'hex'), secret = ''
+	         WHERE secret IS NOT NULL AND secret <> '' AND (secret_hash IS NULL OR secret_hash = '');
+	    END IF;
+	END $$;
+	`).Error; err != nil {
+		log.Warnf("oauth_clients 密钥哈希迁移失败: %v", err)
+	}
+
+	// OAuth 授权码模式：令牌族、刷新轮换与授权码
+	if err := db.Exec(`
+	DO $$
+	BEGIN
+	    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name='oauth_tokens') THEN
+	        ALTER TABLE oauth_tokens ADD COLUMN IF NOT EXISTS org_id TEXT;
+	        ALTER TABLE oauth_tokens ADD COLUMN IF NOT EXISTS family_id TEXT;
+	        ALTER TABLE oauth_tokens ADD COLUMN IF NOT EXISTS refresh_expires_at TIMESTAMPTZ;
+	        ALTER TABLE oauth_tokens ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMPTZ;
+	        ALTER TABLE oauth_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+	        CREATE INDEX IF NOT EXISTS idx_oauth_tokens_family_id ON oauth_tokens(family_id);
+	    END IF;
+	    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name='oauth_clients') THEN
+	        ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS auth_method TEXT DEFAULT 'client_secret_post';
+	        UPDATE oauth_clients SET auth_method = 'client_secret_post' WHERE auth_method IS NULL OR auth_method = '';
+	    END IF;
+	END $$;
+	CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+		code_hash VARCHAR(64) PRIMARY KEY,
+		client_id VARCHAR(255) NOT NULL,
+		user_id VARCHAR(255) NOT NULL,
+		org_id VARCHAR(255),
+		redirect_uri TEXT NOT NULL,
+		scopes TEXT,
+		code_challenge VARCHAR(128) NOT NULL,
+		code_challenge_method VARCHAR(10) NOT NULL,
+		family_id VARCHAR(255) NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		used_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_oauth_authorization_codes_client_id ON oauth_authorization_codes(client_id);
+	`).Error; err != nil {
+		log.Warnf("OAuth 授权码相关表扩展失败: %v", err)
+	}
+
 	// 邀请与通知表
 	if err := db.Exec(`
         CREATE TABLE IF NOT EXISTS invitations (
@@ -588,6 +701,143 @@ func main() {
 	`).Error; err != nil {
 		log.Fatalf("创建 organization_quotas 表失败: %v", err)
 	}
+	// 计费周期：套餐额度、结转与当期起点
+	if err := db.Exec(`
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS base_allocation INT NOT NULL DEFAULT 0;
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS rolled_over INT NOT NULL DEFAULT 0;
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS period VARCHAR(20);
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS rollover BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS rollover_cap INT NOT NULL DEFAULT 0;
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS period_start_at TIMESTAMP WITH TIME ZONE;
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS overage_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS hard_limit INT NOT NULL DEFAULT 0;
+		ALTER TABLE organization_quotas ADD COLUMN IF NOT EXISTS overage INT NOT NULL DEFAULT 0;
+	`).Error; err != nil {
+		log.Warnf("organization_quotas 计费周期列创建失败: %v", err)
+	}
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS quota_period_histories (
+			id VARCHAR(255) PRIMARY KEY,
+			organization_id VARCHAR(255) NOT NULL,
+			service_type VARCHAR(50) NOT NULL,
+			period VARCHAR(20) NOT NULL,
+			period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+			period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+			base_allocation INT NOT NULL DEFAULT 0,
+			rolled_over_in INT NOT NULL DEFAULT 0,
+			allocation INT NOT NULL DEFAULT 0,
+			consumed INT NOT NULL DEFAULT 0,
+			overage INT NOT NULL DEFAULT 0,
+			rolled_over_out INT NOT NULL DEFAULT 0,
+			closed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_quota_period_org_service ON quota_period_histories(organization_id, service_type);
+		CREATE INDEX IF NOT EXISTS idx_quota_period_histories_period_end ON quota_period_histories(period_end);
+	`).Error; err != nil {
+		log.Warnf("创建 quota_period_histories 表失败: %v", err)
+	}
+	if err := db.Exec(`ALTER TABLE quota_period_histories ADD COLUMN IF NOT EXISTS overage INT NOT NULL DEFAULT 0`).Error; err != nil {
+		log.Warnf("quota_period_histories.overage 列创建失败: %v", err)
+	}
+
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS quota_ledger_entries (
+			id VARCHAR(255) PRIMARY KEY,
+			reservation_id VARCHAR(255) NOT NULL,
+			entry_type VARCHAR(20) NOT NULL,
+			organization_id VARCHAR(255) NOT NULL,
+			service_type VARCHAR(50) NOT NULL,
+			request_id VARCHAR(255),
+			amount INT NOT NULL DEFAULT 0,
+			reason TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_quota_ledger_reservation_entry ON quota_ledger_entries(reservation_id, entry_type);
+		CREATE INDEX IF NOT EXISTS idx_quota_ledger_org_service ON quota_ledger_entries(organization_id, service_type);
+		CREATE INDEX IF NOT EXISTS idx_quota_ledger_request ON quota_ledger_entries(request_id);
+		CREATE INDEX IF NOT EXISTS idx_quota_ledger_created ON quota_ledger_entries(created_at);
+	`).Error; err != nil {
+		log.Warnf("创建 quota_ledger_entries 表失败: %v", err)
+	}
+
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+			id VARCHAR(64) PRIMARY KEY,
+			algorithm VARCHAR(10) NOT NULL,
+			private_key_enc TEXT NOT NULL,
+			public_key TEXT NOT NULL,
+			activates_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_jwt_signing_keys_activates_at ON jwt_signing_keys(activates_at);
+	`).Error; err != nil {
+		log.Warnf("创建 jwt_signing_keys 表失败: %v", err)
+	}
+
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id VARCHAR(255) PRIMARY KEY,
+			org_id VARCHAR(255) NOT NULL,
+			url TEXT NOT NULL,
+			description TEXT,
+			secret_enc TEXT NOT NULL,
+			events TEXT[],
+			status VARCHAR(20) NOT NULL DEFAULT 'active',
+			created_by VARCHAR(255),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_webhooks_org ON webhooks(org_id);
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id VARCHAR(255) PRIMARY KEY,
+			webhook_id VARCHAR(255) NOT NULL,
+			org_id VARCHAR(255) NOT NULL,
+			event_id VARCHAR(255) NOT NULL,
+			event_type VARCHAR(100) NOT NULL,
+			payload JSONB,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP WITH TIME ZONE,
+			last_status_code INT,
+			last_error TEXT,
+			delivered_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_org_status ON webhook_deliveries(org_id, status);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	`).Error; err != nil {
+		log.Warnf("创建 webhooks 表失败: %v", err)
+	}
+
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS kyc_jobs (
+			id VARCHAR(255) PRIMARY KEY,
+			org_id VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255),
+			job_type VARCHAR(50) NOT NULL DEFAULT 'complete',
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			max_attempts INT NOT NULL DEFAULT 3,
+			input JSONB,
+			checkpoint JSONB,
+			next_run_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			locked_until TIMESTAMP WITH TIME ZONE,
+			claim_token VARCHAR(64),
+			last_error TEXT,
+			cancelled_at TIMESTAMP WITH TIME ZONE,
+			finished_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_kyc_jobs_org ON kyc_jobs(org_id);
+		CREATE INDEX IF NOT EXISTS idx_kyc_jobs_due ON kyc_jobs(status, next_run_at);
+		ALTER TABLE kyc_jobs ADD COLUMN IF NOT EXISTS claim_token VARCHAR(64);
+	`).Error; err != nil {
+		log.Warnf("创建 kyc_jobs 表失败: %v", err)
+	}
 
 	if err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS audit_actions (
@@ -614,6 +864,10 @@ func main() {
 			{"view_organization_members", "View Members", "Team"},
 			{"key.update_scopes", "Update Key Scopes", "Keys"},
 			{"key.show_secret", "Show Key Secret", "Keys"},
+			{"webhook.create", "Create Webhook", "Webhooks"},
+			{"webhook.update", "Update Webhook", "Webhooks"},
+			{"webhook.delete", "Delete Webhook", "Webhooks"},
+			{"webhook.redeliver", "Redeliver Webhook", "Webhooks"},
 		}
 		for _, s := range seed {
 			_ = db.Exec("INSERT INTO audit_actions(id,name,category) VALUES(?, ?, ?) ON CONFLICT (id) DO NOTHING", s.id, s.name, s.cat).Error
@@ -668,33 +922,11 @@ func main() {
 	}
 	_ = db.Exec("INSERT INTO global_configs(key,value) VALUES('daily_registration_cap','1000') ON CONFLICT (key) DO NOTHING").Error
 
-	// 权限与角色种子
+	// 权限与角色种子：权限目录以 config.PermissionList 为准，每次启动补齐缺失项（已有项及平台管理员新增的权限不受影响）
 	{
-		var permCount int64
-		err = db.Table("permissions").Count(&permCount).Error
-		if err != nil {
-			panic(err)
-		}
-
-		if err == nil && permCount == 0 {
-			permSeeds := []struct{ id, cat, name, desc string }{
-				{"org.read", "Organization", "Read Organization", "Read organization details"},
-				{"org.update", "Organization", "Update Organization", "Update organization settings"},
-				{"org.delete", "Organization", "Delete Organization", "Delete organization"},
-				{"team.read", "Team", "View Members", "View members"},
-				{"team.invite", "Team", "Invite Members", "Invite members"},
-				{"team.write", "Team", "Modify Members", "Modify/remove members"},
-				{"billing.read", "Billing", "View Billing", "View billing"},
-				{"org.billing.read", "Billing", "View Organization Billing", "View org billing"},
-				{"billing.write", "Billing", "Modify Billing", "Modify payment/subscription"},
-				{"keys.read", "API Keys", "View API Keys", "View API keys"},
-				{"keys.write", "API Keys", "Manage API Keys", "Create/Revoke API keys"},
-				{"logs.read", "Logs", "View Audit Logs", "View audit logs"},
-				{"org.usage.read", "Logs", "View Usage", "View organization usage"},
-				{"org.audit", "Logs", "Export Audit Logs", "Export organization audit logs"},
-			}
-			for _, p := range permSeeds {
-				_ = db.Exec("INSERT INTO permissions(id, category, name, description) VALUES(?, ?, ?, ?) ON CONFLICT (id) DO NOTHING", p.id, p.cat, p.name, p.desc).Error
+		for _, p := range config.PermissionList {
+			if err := db.Exec("INSERT INTO permissions(id, category, name, description) VALUES(?, ?, ?, ?) ON CONFLICT (id) DO NOTHING", p.ID, p.Category, p.Name, p.Description).Error; err != nil {
+				log.Warnf("权限种子写入失败: %s err=%v", p.ID, err)
 			}
 		}
 		_ = db.Exec("UPDATE permissions SET name = COALESCE(name, description) WHERE name IS NULL OR name = ''").Error
@@ -718,7 +950,7 @@ func main() {
 		}
 		seedRole("owner", "Owner", "系统所有者", allIDs)
 		// admin
-		seedRole("admin", "Administrator", "组织管理员", []string{"org.read", "team.read", "team.invite", "team.write", "keys.read", "keys.write", "billing.read", "logs.read", "org.audit"})
+		seedRole("admin", "Administrator", "组织管理员", []string{"org.read", "team.read", "team.invite", "team.write", "keys.read", "keys.write", "billing.read", "logs.read", "org.audit", "privacy.read"})
 		// developer
 		seedRole("developer", "Developer", "开发者", []string{"keys.read", "keys.write", "logs.read"})
 		// viewer
@@ -792,11 +1024,27 @@ func main() {
 
 	// 初始化服务
 	kycService := service.NewKYCService(db, redisClient, cfg)
+	if err := kycService.InitJWTKeys(context.Background()); err != nil {
+		log.Warnf("JWT签名密钥初始化失败，回退为 HS256 签名: %v", err)
+	}
+	if err := kycService.InitBlobStore(); err != nil {
+		log.Fatalf("对象存储初始化失败: %v", err)
+	}
+	if kycService.BlobEncryptionEnabled() {
+		tasks.StartBlobSealer(kycService, time.Hour)
+	}
 	tasks.StartStatsRefresher(kycService, 5*time.Minute)
 	tasks.StartInvitationCleaner(kycService, time.Hour)
 	tasks.StartAuditActionsSync(kycService, 10*time.Minute)
-	tasks.StartQuotaResetter(db, time.Hour)
+	tasks.StartQuotaResetter(kycService, time.Hour)
+	tasks.StartQuotaReconciler(kycService, 10*time.Minute)
+	tasks.StartJWTKeyRotator(kycService, 10*time.Minute)
 	tasks.StartUsageMeterConsumer(kycService, 100, time.Second)
+	tasks.StartWebhookDispatcher(kycService, 5*time.Second)
+	tasks.StartKYCJobWorkers(kycService, cfg.Async.Workers, cfg.Async.PollInterval)
+	if cfg.Retention.Enabled && cfg.Retention.Interval > 0 {
+		tasks.StartRetentionPurger(kycService, cfg.Retention.Interval, cfg.Retention.DryRun)
+	}
 
 	// 启动后同步现有组织的配额（Plans -> OrganizationQuotas）
 	{
@@ -864,6 +1112,7 @@ func main() {
 
 	// 健康检查（支持双向鉴权）
 	healthCheck := middleware.NewBidirectionalHealthCheck(bidirectionalAuth)
+	healthCheck.AddCheck("third_party", kycService.ProviderHealth)
 	r.GET("/health", healthCheck.HealthCheckHandler)
 
 	// 心跳检测接口
@@ -898,6 +1147,34 @@ func main() {
 			consoleAuth.POST("/login", consoleAuthHandler.Login)
 			consoleAuth.POST("/register", consoleAuthHandler.Register)
 			consoleAuth.GET("/me", middleware.JWTAuth(kycService), consoleAuthHandler.Me)
+			consoleAuth.POST("/refresh", consoleAuthHandler.Refresh)
+			consoleAuth.POST("/logout", middleware.JWTAuthForMFASetup(kycService), consoleAuthHandler.Logout)
+
+			// 多因素认证：登录第二步使用挑战令牌；注册接口允许尚未完成MFA的会话访问
+			mfa := consoleAuth.Group("/mfa")
+			mfa.POST("/verify", consoleAuthHandler.VerifyMFA)
+			mfa.POST("/webauthn/challenge", consoleAuthHandler.MFALoginWebAuthnChallenge)
+			mfaSetup := mfa.Group("", middleware.JWTAuthForMFASetup(kycService))
+			mfaSetup.GET("", consoleAuthHandler.GetMFAStatus)
+			mfaSetup.POST("/totp/enroll", consoleAuthHandler.EnrollTOTP)
+			mfaSetup.POST("/totp/confirm", consoleAuthHandler.ConfirmTOTP)
+			mfaSetup.POST("/webauthn/register/begin", consoleAuthHandler.BeginWebAuthnRegistration)
+			mfaSetup.POST("/webauthn/register/finish", consoleAuthHandler.FinishWebAuthnRegistration)
+			mfaSetup.POST("/step-up", consoleAuthHandler.StepUp)
+			mfaSetup.POST("/step-up/webauthn/challenge", consoleAuthHandler.StepUpWebAuthnChallenge)
+			mfaManage := mfa.Group("", middleware.JWTAuth(kycService), middleware.RequireStepUp(kycService))
+			mfaManage.DELETE("/totp", consoleAuthHandler.DisableTOTP)
+			mfaManage.POST("/recovery-codes", consoleAuthHandler.RegenerateRecoveryCodes)
+			mfaManage.DELETE("/webauthn/:id", consoleAuthHandler.DeleteWebAuthnCredential)
+
+			// 企业 SSO（OIDC / SAML）：回调完成后以一次性 code 换取会话
+			sso := consoleAuth.Group("/sso")
+			sso.GET("/discover", consoleAuthHandler.DiscoverSSO)
+			sso.GET("/start", consoleAuthHandler.StartSSO)
+			sso.GET("/oidc/callback", consoleAuthHandler.OIDCCallback)
+			sso.POST("/saml/acs", consoleAuthHandler.SAMLACS)
+			sso.GET("/saml/metadata/:org_id", consoleAuthHandler.SAMLMetadata)
+			sso.POST("/exchange", consoleAuthHandler.ExchangeSSOCode)
 		}
 
 		// 控制台API（需要用户认证）
@@ -914,11 +1191,15 @@ func main() {
 			console.POST("/keys", middleware.RequireOrganizationHeader(kycService), middleware.RequirePermission("keys.write"), consoleHandler.CreateAPIKey)
 			console.DELETE("/keys/:id", middleware.RequireOrganizationHeader(kycService), middleware.RequirePermission("keys.write"), consoleHandler.RevokeAPIKey)
 			console.PATCH("/keys/:id", middleware.RequireOrganizationHeader(kycService), middleware.RequirePermission("keys.write"), consoleHandler.UpdateAPIKeyScopes)
-			console.GET("/keys/:id/secret", middleware.RequireOrganizationHeader(kycService), middleware.RequirePermission("keys.read"), consoleHandler.GetAPIKeySecret)
+			console.GET("/keys/:id/secret", middleware.RequireOrganizationHeader(kycService), middleware.RequirePermission("keys.read"), middleware.RequireStepUp(kycService), consoleHandler.GetAPIKeySecret)
 			console.GET("/usage", middleware.RequireOrganizationHeader(kycService), middleware.RequirePermission("logs.read"), consoleHandler.GetUsage)
 			console.GET("/usage/stats", middleware.RequireOrganizationHeader(kycService), middleware.RequirePermission("logs.read"), consoleHandler.GetUsageStats)
 			console.GET("/logs", middleware.RequireOrganizationHeader(kycService), middleware.RequirePermission("logs.read"), consoleHandler.GetLogs)
 			console.DELETE("/users/me", consoleHandler.DeleteMe)
+			sessionHandler := api.NewConsoleAuthHandler(kycService)
+			console.GET("/sessions", sessionHandler.ListSessions)
+			console.DELETE("/sessions", sessionHandler.RevokeOtherSessions)
+			console.DELETE("/sessions/:id", sessionHandler.RevokeSession)
 			console.GET("/me/notifications", middleware.JWTAuth(kycService), consoleHandler.GetNotifications)
 			console.PUT("/me/notifications/:id/read", middleware.JWTAuth(kycService), consoleHandler.MarkNotificationRead)
 			console.GET("/usage/quota", middleware.RequireOrganizationHeader(kycService), consoleHandler.GetQuotaStatus)
@@ -932,7 +1213,8 @@ func main() {
 			clients.DELETE(":client_id", middleware.RequirePermission("keys.write"), clientHandler.DeleteClient)
 			clients.POST(":id/rotate", middleware.RequirePermission("keys.write"), clientHandler.RotateClientSecret)
 			clients.PATCH(":id/status", middleware.RequirePermission("keys.write"), clientHandler.UpdateClientStatus)
-			clients.GET(":id/secret", middleware.JWTAuth(kycService), middleware.RequireOrganizationHeader(kycService), middleware.InjectOrgContext(), clientHandler.GetClientSecret)
+			clients.PUT(":id/keys", middleware.RequirePermission("keys.write"), clientHandler.UpdateClientKeys)
+			clients.GET(":id/secret", middleware.JWTAuth(kycService), middleware.RequireOrganizationHeader(kycService), middleware.InjectOrgContext(), middleware.RequireStepUp(kycService), clientHandler.GetClientSecret)
 
 		}
 
@@ -960,6 +1242,8 @@ func main() {
 			admin.GET("/users", adminHandler.GetUserList)
 			admin.PUT("/users/:id/status", adminHandler.UpdateUserStatus)
 			admin.PUT("/users/:id", adminHandler.UpdateUserAdmin)
+			admin.POST("/users/:id/unlock", adminHandler.UnlockUser)
+			admin.POST("/tokens/revoke", adminHandler.RevokeTokens)
 			admin.GET("/organizations", adminHandler.GetOrganizationList)
 			admin.PUT("/organizations/:id/plan", middleware.RequireOrganizationHeader(kycService), adminHandler.UpdateOrganizationPlan)
 			admin.GET("/audit-logs", adminHandler.GetAuditLogs)
@@ -975,6 +1259,9 @@ func main() {
 			// 新增：配额管理
 			admin.GET("/organizations/:id/quotas", middleware.RequireOrganizationHeader(kycService), adminHandler.GetOrganizationQuotas)
 			admin.POST("/organizations/:id/quotas/adjust", middleware.RequireOrganizationHeader(kycService), adminHandler.AdjustOrganizationQuota)
+			admin.GET("/organizations/:id/data-keys", adminHandler.GetOrganizationDataKeys)
+			admin.POST("/organizations/:id/data-keys/shred", adminHandler.ShredOrganizationDataKeys)
+			admin.POST("/retention/run", adminHandler.RunRetentionPurge)
 		}
 
 		// 密码重置API
@@ -1000,6 +1287,9 @@ func main() {
 			oauthGroup.POST("/refresh", oauthHandler.RefreshToken)
 			oauthGroup.POST("/revoke", oauthHandler.Revoke)
 			oauthGroup.POST("/introspect", oauthHandler.Introspect)
+			// 授权码模式：控制台用户在同意页授权合作方应用
+			oauthGroup.GET("/authorize", middleware.JWTAuth(kycService), oauthHandler.Authorize)
+			oauthGroup.POST("/authorize", middleware.JWTAuth(kycService), oauthHandler.AuthorizeDecision)
 		}
 
 		// 通知与邮件发送（需权限）
@@ -1011,7 +1301,27 @@ func main() {
 			notifications.POST("/email", nh.SendEmail)
 		}
 
-		discovery := api.NewDiscoveryHandler()
+		// SCIM 2.0 成员开通（组织 SCIM 令牌认证，IdP 调用）
+		scimGroup := r.Group("/scim/v2")
+		scimGroup.Use(middleware.SCIMAuth(kycService))
+		{
+			scimHandler := api.NewSCIMHandler(kycService)
+			scimGroup.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+			scimGroup.GET("/Users", scimHandler.ListUsers)
+			scimGroup.POST("/Users", scimHandler.CreateUser)
+			scimGroup.GET("/Users/:id", scimHandler.GetUser)
+			scimGroup.PUT("/Users/:id", scimHandler.ReplaceUser)
+			scimGroup.PATCH("/Users/:id", scimHandler.PatchUser)
+			scimGroup.DELETE("/Users/:id", scimHandler.DeleteUser)
+			scimGroup.GET("/Groups", scimHandler.ListGroups)
+			scimGroup.POST("/Groups", scimHandler.CreateGroup)
+			scimGroup.GET("/Groups/:id", scimHandler.GetGroup)
+			scimGroup.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+			scimGroup.PATCH("/Groups/:id", scimHandler.PatchGroup)
+			scimGroup.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+		}
+
+		discovery := api.NewDiscoveryHandler(kycService)
 		r.GET("/.well-known/oauth-authorization-server", discovery.WellKnown)
 		r.GET("/jwks.json", discovery.JWKS)
 
@@ -1058,10 +1368,43 @@ func main() {
 			orgs.PATCH("/members/:id", middleware.RequirePermission("team.write"), orgHandler.UpdateMemberRole)
 			orgs.PUT("/members/:id/password", middleware.RequirePermission("team.write"), orgHandler.ResetMemberPassword)
 			orgs.PATCH("/members/:id/status", middleware.RequirePermission("team.write"), orgHandler.UpdateMemberStatus)
+			orgs.GET("/roles", middleware.RequirePermission("team.read"), orgHandler.ListOrgRoles)
+			orgs.POST("/roles", middleware.RequirePermission("team.write"), orgHandler.CreateOrgRole)
+			orgs.GET("/roles/:id", middleware.RequirePermission("team.read"), orgHandler.GetOrgRole)
+			orgs.PUT("/roles/:id", middleware.RequirePermission("team.write"), orgHandler.UpdateOrgRole)
+			orgs.DELETE("/roles/:id", middleware.RequirePermission("team.write"), orgHandler.DeleteOrgRole)
+			orgs.GET("/roles/:id/simulate", middleware.RequirePermission("team.read"), orgHandler.SimulateOrgRole)
 			orgs.PUT("/plan", middleware.RequirePermission("billing.write"), orgHandler.UpdatePlan)
+			orgs.GET("/kyc-rules", middleware.RequirePermission("org.read"), orgHandler.GetKYCRules)
+			orgs.PUT("/kyc-rules", middleware.RequirePermission("org.update"), orgHandler.UpdateKYCRules)
+			orgs.GET("/retention-policy", middleware.RequirePermission("org.read"), orgHandler.GetRetentionPolicy)
+			orgs.PUT("/retention-policy", middleware.RequirePermission("org.update"), orgHandler.UpdateRetentionPolicy)
+			orgs.GET("/retention-policy/preview", middleware.RequirePermission("org.read"), orgHandler.PreviewRetentionPurge)
+			orgs.POST("/data-subjects/search", middleware.RequirePermission("privacy.read"), orgHandler.SearchDataSubject)
+			orgs.POST("/data-subjects/export", middleware.RequirePermission("privacy.read"), orgHandler.ExportDataSubject)
+			orgs.POST("/data-subjects/erase", middleware.RequirePermission("privacy.erase"), middleware.RequireStepUp(kycService), orgHandler.EraseDataSubject)
+			orgs.POST("/data-subjects/receipts/verify", middleware.RequirePermission("privacy.read"), orgHandler.VerifyDataSubjectReceipt)
+			orgs.GET("/security", middleware.RequirePermission("org.read"), orgHandler.GetSecuritySettings)
+			orgs.PUT("/security", middleware.RequirePermission("org.update"), orgHandler.UpdateSecuritySettings)
+			orgs.GET("/sso", middleware.RequirePermission("org.read"), orgHandler.GetSSOConfig)
+			orgs.PUT("/sso", middleware.RequirePermission("org.update"), orgHandler.UpdateSSOConfig)
+			orgs.DELETE("/sso", middleware.RequirePermission("org.update"), orgHandler.DeleteSSOConfig)
+			orgs.GET("/domains", middleware.RequirePermission("org.read"), orgHandler.ListDomains)
+			orgs.POST("/domains", middleware.RequirePermission("org.update"), orgHandler.ClaimDomain)
+			orgs.POST("/domains/:domain/verify", middleware.RequirePermission("org.update"), orgHandler.VerifyDomain)
+			orgs.DELETE("/domains/:domain", middleware.RequirePermission("org.update"), orgHandler.DeleteDomain)
+			orgs.GET("/scim/tokens", middleware.RequirePermission("org.read"), orgHandler.ListSCIMTokens)
+			orgs.POST("/scim/tokens", middleware.RequirePermission("org.update"), middleware.RequireStepUp(kycService), orgHandler.CreateSCIMToken)
+			orgs.DELETE("/scim/tokens/:id", middleware.RequirePermission("org.update"), orgHandler.RevokeSCIMToken)
+			orgs.GET("/scim/groups", middleware.RequirePermission("org.read"), orgHandler.ListSCIMGroups)
+			orgs.PUT("/scim/groups/:id/role", middleware.RequirePermission("org.update"), orgHandler.UpdateSCIMGroupRole)
 			orgs.GET("/:org_id/usage/summary", middleware.RequirePermission("logs.read"), orgHandler.GetUsageSummary)
 			orgs.DELETE("/members/:id", middleware.RequirePermission("team.write"), orgHandler.DeleteOrganizationMember)
 			orgs.GET("/billing", middleware.ScopePermission([]string{"org.billing.read", "billing.read"}), orgHandler.GetBilling)
+			orgs.GET("/billing/settings", middleware.ScopePermission([]string{"org.billing.read", "billing.read"}), orgHandler.GetBillingSettings)
+			orgs.PUT("/billing/settings", middleware.RequirePermission("billing.write"), orgHandler.UpdateBillingSettings)
+			orgs.PUT("/billing/overage", middleware.RequirePermission("billing.write"), orgHandler.UpdateOverageLimit)
+			orgs.GET("/billing/periods", middleware.ScopePermission([]string{"org.billing.read", "billing.read"}), orgHandler.GetBillingPeriods)
 			orgs.GET("/usage/daily", middleware.ScopePermission([]string{"org.usage.read", "logs.read"}), orgHandler.GetUsageDaily)
 			orgs.GET("/usage/detailed", middleware.ScopePermission([]string{"org.usage.read", "logs.read"}), orgHandler.GetUsageDetailedV2)
 			orgs.GET("/audit-logs", middleware.RequirePermission("logs.read"), orgHandler.GetOrgAuditLogs)
@@ -1070,6 +1413,16 @@ func main() {
 			orgs.POST("/invitations", middleware.RequirePermission("team.invite"), orgHandler.CreateInvitation)
 			orgs.GET("/invitations", middleware.RequirePermission("team.read"), orgHandler.ListInvitations)
 			orgs.DELETE("/invitations/:id", middleware.RequirePermission("team.write"), orgHandler.RevokeInvitation)
+			// Webhook 管理
+			webhookHandler := api.NewWebhookHandler(kycService)
+			orgs.GET("/webhooks", middleware.RequirePermission("keys.read"), webhookHandler.ListWebhooks)
+			orgs.POST("/webhooks", middleware.RequirePermission("keys.write"), webhookHandler.CreateWebhook)
+			orgs.GET("/webhooks/dead-letters", middleware.RequirePermission("keys.read"), webhookHandler.ListDeadLetters)
+			orgs.POST("/webhooks/deliveries/:delivery_id/redeliver", middleware.RequirePermission("keys.write"), webhookHandler.RedeliverDelivery)
+			orgs.PUT("/webhooks/:id", middleware.RequirePermission("keys.write"), webhookHandler.UpdateWebhook)
+			orgs.DELETE("/webhooks/:id", middleware.RequirePermission("keys.write"), webhookHandler.DeleteWebhook)
+			orgs.POST("/webhooks/:id/test", middleware.RequirePermission("keys.write"), webhookHandler.TestWebhook)
+			orgs.GET("/webhooks/:id/deliveries", middleware.RequirePermission("keys.read"), webhookHandler.ListDeliveries)
 			// 注销组织（仅owner）
 			orgs.DELETE("/:id", middleware.RequirePermission("org.delete"), orgHandler.DeleteOrganization)
 		}
@@ -1112,12 +1465,17 @@ func main() {
 			kyc.POST("/liveness/silent", middleware.RequireKeyScope("liveness:read"), kycHandler.LivenessSilent)
 			kyc.POST("/liveness/video", middleware.RequireKeyScope("liveness:read"), kycHandler.LivenessVideo)
 			kyc.GET("/liveness/ws", middleware.RequireKeyScope("liveness:read"), kycHandler.LivenessWebSocket)
-			// Action liveness (MVP placeholder)
+			// Action liveness：随机动作序列会话
 			kyc.POST("/liveness/action/session", middleware.RequireKeyScope("liveness:read"), kycHandler.LivenessActionSession)
+			kyc.GET("/liveness/action/session/:id", middleware.RequireKeyScope("liveness:read"), kycHandler.GetLivenessActionSession)
 			kyc.POST("/liveness/action/upload", middleware.RequireKeyScope("liveness:read"), kycHandler.LivenessActionUpload)
 			kyc.POST("/liveness/action/verify", middleware.RequireKeyScope("liveness:read"), kycHandler.LivenessActionVerify)
 			// 完整KYC流程
 			kyc.POST("/verify", middleware.RequireKeyScope("kyc:verify"), kycHandler.CompleteKYC)
+			// 异步KYC任务
+			kyc.GET("/jobs/:id", middleware.RequireKeyScope("kyc:verify"), kycHandler.GetKYCJob)
+			kyc.POST("/jobs/:id/cancel", middleware.RequireKeyScope("kyc:verify"), kycHandler.CancelKYCJob)
+			kyc.POST("/jobs/:id/retry", middleware.RequireKeyScope("kyc:verify"), kycHandler.RetryKYCJob)
 
 			// 查询KYC状态
 			kyc.GET("/status/:request_id", kycHandler.GetKYCStatus)
@@ -1139,6 +1497,9 @@ func main() {
 		imageHandler := api.NewImageHandler(kycService)
 		images.POST("", imageHandler.Upload)
 		images.GET(":id/image", imageHandler.GetImage)
+		images.GET(":id/url", imageHandler.GetImageURL)
+		// 签名下载地址，凭 URL 签名访问，加密存储的文件解密后输出
+		v1.GET("/blobs/*key", imageHandler.ServeSignedBlob)
 	}
 
 	// 启动HTTP服务器
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"kyc-service/internal/config"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/mockvendor"
+)
+
+// startMockVendors 在 mock_vendor.addr 上启动模拟供应商，并覆盖 third_party 中的全部供应商地址
+func startMockVendors(cfg *config.Config) error {
+	mc := cfg.MockVendor
+	opts := mockvendor.Options{Latency: mc.Latency, Jitter: mc.Jitter, ErrorRate: mc.ErrorRate, Seed: mc.Seed}
+	if mc.Fixtures != "" {
+		fixtures, err := mockvendor.LoadFixtures(mc.Fixtures)
+		if err != nil {
+			return err
+		}
+		opts.Fixtures = fixtures
+	}
+	ln, err := net.Listen("tcp", mc.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := http.Serve(ln, mockvendor.New(opts)); err != nil {
+			logger.GetLogger().Errorf("模拟供应商服务退出: %v", err)
+		}
+	}()
+
+	base := "http://" + ln.Addr().String()
+	tp := &cfg.ThirdParty
+	tp.OCRService.URL = base + mockvendor.PathOCR
+	tp.FaceService.URL = base
+	tp.LivenessSlient.URL = base + mockvendor.PathLivenessSilent
+	tp.LivenessVideo.URL = base + mockvendor.PathLivenessVideo
+	for i := range tp.Providers.OCR {
+		tp.Providers.OCR[i].URL = base + mockvendor.PathOCR
+	}
+	for i := range tp.Providers.Face {
+		tp.Providers.Face[i].URL = base
+	}
+	for i := range tp.Providers.Liveness {
+		tp.Providers.Liveness[i].URL = base + mockvendor.PathLivenessSilent
+		tp.Providers.Liveness[i].VideoURL = base + mockvendor.PathLivenessVideo
+	}
+	logger.GetLogger().Warnf("use_mock 已开启，第三方供应商已指向模拟服务 %s", base)
+	return nil
+}
@@ -73,24 +73,26 @@ type PlanInfo struct {
 }
 
 type AdminPlan struct {
-	ID            string          `json:"id"`
-	Name          string          `json:"name"`
-	Price         int             `json:"price"`
-	Currency      string          `json:"currency"`
-	RequestsLimit int             `json:"requestsLimit"`
-	Features      json.RawMessage `json:"features"`
-	QuotaConfig   json.RawMessage `json:"quotaConfig"`
-	IsActive      bool            `json:"isActive"`
-	UpdatedAt     time.Time       `json:"updatedAt"`
+	ID              string          `json:"id"`
+	Name            string          `json:"name"`
+	Price           int             `json:"price"`
+	Currency        string          `json:"currency"`
+	RequestsLimit   int             `json:"requestsLimit"`
+	Features        json.RawMessage `json:"features"`
+	QuotaConfig     json.RawMessage `json:"quotaConfig"`
+	RetentionConfig json.RawMessage `json:"retentionConfig"`
+	IsActive        bool            `json:"isActive"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
 }
 
 type PlanUpdateRequest struct {
-	Price         *int             `json:"price"`
-	Currency      *string          `json:"currency"`
-	RequestsLimit *int             `json:"requestsLimit"`
-	Features      *json.RawMessage `json:"features"`
-	QuotaConfig   *json.RawMessage `json:"quotaConfig"`
-	IsActive      *bool            `json:"isActive"`
+	Price           *int             `json:"price"`
+	Currency        *string          `json:"currency"`
+	RequestsLimit   *int             `json:"requestsLimit"`
+	Features        *json.RawMessage `json:"features"`
+	QuotaConfig     *json.RawMessage `json:"quotaConfig"`
+	RetentionConfig *json.RawMessage `json:"retentionConfig"`
+	IsActive        *bool            `json:"isActive"`
 }
 
 // AdminUserListRequest 用户列表请求
@@ -411,7 +413,7 @@ func (h *AdminHandler) GetOrganizationList(c *gin.Context) {
 // GetPlans 管理端获取计划列表
 func (h *AdminHandler) GetPlans(c *gin.Context) {
 	var rows []AdminPlan
-	err := h.service.DB.Raw(`SELECT id, name, COALESCE(price,0) AS price, COALESCE(currency,'USD') AS currency, COALESCE(requests_limit,0) AS requests_limit, COALESCE(features,'[]') AS features, COALESCE(quota_config,'{}') AS quota_config, COALESCE(is_active, true) AS is_active, updated_at FROM plans`).Scan(&rows).Error
+	err := h.service.DB.Raw(`SELECT id, name, COALESCE(price,0) AS price, COALESCE(currency,'USD') AS currency, COALESCE(requests_limit,0) AS requests_limit, COALESCE(features,'[]') AS features, COALESCE(quota_config,'{}') AS quota_config, COALESCE(retention_config,'{}') AS retention_config, COALESCE(is_active, true) AS is_active, updated_at FROM plans`).Scan(&rows).Error
 	if err != nil {
 		JSONError(c, CodeDatabaseError, "查询失败")
 		return
@@ -447,6 +449,13 @@ func (h *AdminHandler) UpdatePlan(c *gin.Context) {
 	if req.QuotaConfig != nil {
 		updates["quota_config"] = *req.QuotaConfig
 	}
+	if req.RetentionConfig != nil {
+		if _, err := service.ParseRetentionPolicy(string(*req.RetentionConfig)); err != nil {
+			JSONError(c, CodeInvalidParameter, err.Error())
+			return
+		}
+		updates["retention_config"] = *req.RetentionConfig
+	}
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
@@ -632,6 +641,12 @@ func (h *AdminHandler) UpdateUserStatus(c *gin.Context) {
 		return
 	}
 
+	if req.Status != "active" {
+		if err := h.service.RevokeTokensBy(c.Request.Context(), service.RevocationByUser, userID, "admin_status_"+req.Status); err != nil {
+			logger.GetLogger().WithError(err).Error("吊销用户令牌失败")
+		}
+	}
+
 	// 记录审计日志
 	h.recordAuditLog(c, c.GetString("userID"), "admin_user_status_updated", "success",
 		fmt.Sprintf("Admin updated user %s status to %s", userID, req.Status))
@@ -704,8 +719,10 @@ func (h *AdminHandler) UpdateUserAdmin(c *gin.Context) {
 			return
 		}
 	}
-	// 会话撤销：删除该用户的OAuthToken（如使用）
-	_ = h.service.DB.Where("user_id = ?", userID).Delete(&models.OAuthToken{}).Error
+	// 会话撤销：吊销该用户已签发的控制台会话与 OAuth 令牌
+	if err := h.service.RevokeTokensBy(c.Request.Context(), service.RevocationByUser, userID, "admin_user_update"); err != nil {
+		logger.GetLogger().WithError(err).Error("吊销用户令牌失败")
+	}
 	// 审计日志
 	details := map[string]interface{}{"target_user_id": userID, "updated_fields": updates}
 	b, _ := json.Marshal(details)
@@ -928,10 +945,18 @@ func (h *AdminHandler) recordAuditLog(c *gin.Context, userID, action, status, me
 }
 
 type UpdatePlanQuotaRequest struct {
-	OCRLimit   int    `json:"ocr_limit"`
-	OCRPeriod  string `json:"ocr_period"`
-	FaceLimit  int    `json:"face_limit"`
-	FacePeriod string `json:"face_period"`
+	OCRLimit        int    `json:"ocr_limit"`
+	OCRPeriod       string `json:"ocr_period"` // daily, weekly, monthly, annual, lifetime
+	OCRRollover     bool   `json:"ocr_rollover"`
+	OCRRolloverCap  int    `json:"ocr_rollover_cap"`
+	OCROverage      bool   `json:"ocr_overage"`
+	OCRHardLimit    int    `json:"ocr_hard_limit"`
+	FaceLimit       int    `json:"face_limit"`
+	FacePeriod      string `json:"face_period"`
+	FaceRollover    bool   `json:"face_rollover"`
+	FaceRolloverCap int    `json:"face_rollover_cap"`
+	FaceOverage     bool   `json:"face_overage"`
+	FaceHardLimit   int    `json:"face_hard_limit"`
 }
 
 func (h *AdminHandler) UpdatePlanQuota(c *gin.Context) { // ignore_security_alert
@@ -941,9 +966,19 @@ func (h *AdminHandler) UpdatePlanQuota(c *gin.Context) { // ignore_security_aler
 		JSONError(c, CodeInvalidParameter, "参数验证失败")
 		return
 	}
-	cfg := map[string]map[string]interface{}{
-		"ocr":  {"limit": req.OCRLimit, "period": req.OCRPeriod},
-		"face": {"limit": req.FaceLimit, "period": req.FacePeriod},
+	ocrPeriod, err := service.NormalizeQuotaPeriod(req.OCRPeriod)
+	if err != nil {
+		JSONError(c, CodeInvalidParameter, err.Error())
+		return
+	}
+	facePeriod, err := service.NormalizeQuotaPeriod(req.FacePeriod)
+	if err != nil {
+		JSONError(c, CodeInvalidParameter, err.Error())
+		return
+	}
+	cfg := map[string]service.QuotaPlanEntry{
+		"ocr":  {Limit: req.OCRLimit, Period: ocrPeriod, Rollover: req.OCRRollover, RolloverCap: req.OCRRolloverCap, Overage: req.OCROverage, HardLimit: req.OCRHardLimit},
+		"face": {Limit: req.FaceLimit, Period: facePeriod, Rollover: req.FaceRollover, RolloverCap: req.FaceRolloverCap, Overage: req.FaceOverage, HardLimit: req.FaceHardLimit},
 	}
 	b, _ := json.Marshal(cfg)
 	if err := h.service.DB.Exec("UPDATE plans SET quota_config = ?, updated_at = NOW() WHERE id = ?", string(b), planID).Error; err != nil {
@@ -1031,8 +1066,95 @@ func (h *AdminHandler) DeletePermission(c *gin.Context) {
 	}
 
 	tx.Commit()
+	h.service.InvalidateAllRolePermissions(c.Request.Context())
 
 	// 审计日志
 	h.recordAuditLog(c, c.GetString("userID"), "admin.delete_permission", "success", fmt.Sprintf("Deleted permission: %s", id))
 	JSONSuccess(c, gin.H{"deleted": id})
 }
+
+// RevokeTokensRequest 批量吊销令牌请求
+type RevokeTokensRequest struct {
+	Type   string `json:"type" binding:"required,oneof=client user org"`
+	ID     string `json:"id" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// RevokeTokens 管理员按客户端/用户/组织批量吊销此前签发的全部令牌
+func (h *AdminHandler) RevokeTokens(c *gin.Context) {
+	var req RevokeTokensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = "admin_revoke"
+	}
+	if err := h.service.RevokeTokensBy(c.Request.Context(), req.Type, req.ID, reason); err != nil {
+		logger.GetLogger().WithError(err).Error("批量吊销令牌失败")
+		JSONError(c, CodeInternalError, "吊销失败")
+		return
+	}
+	h.recordAuditLog(c, c.GetString("userID"), "admin.revoke_tokens", "success", fmt.Sprintf("Revoked tokens by %s %s: %s", req.Type, req.ID, reason))
+	JSONSuccess(c, gin.H{"type": req.Type, "id": req.ID, "revoked_at": time.Now()})
+}
+
+// UnlockUser 管理员解除账号因多次登录失败触发的临时锁定
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	var user models.User
+	if err := h.service.DB.Select("id", "email").First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		JSONError(c, CodeNotFound, "用户不存在")
+		return
+	}
+	lockedFor := h.service.AccountLockedFor(c.Request.Context(), user.Email)
+	if err := h.service.UnlockAccount(c.Request.Context(), user.Email); err != nil {
+		logger.GetLogger().WithError(err).Error("解锁账号失败")
+		JSONError(c, CodeInternalError, "解锁失败")
+		return
+	}
+	h.recordAuditLog(c, c.GetString("userID"), "admin.account_unlocked", "success",
+		fmt.Sprintf("Unlocked user %s (remaining lock %s)", user.ID, lockedFor.Round(time.Second)))
+	JSONSuccess(c, gin.H{"user_id": user.ID, "was_locked": lockedFor > 0})
+}
+
+// GetOrganizationDataKeys 组织的数据密钥列表（不含密钥内容）
+func (h *AdminHandler) GetOrganizationDataKeys(c *gin.Context) {
+	keys, err := h.service.ListOrgDataKeys(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "查询失败")
+		return
+	}
+	JSONSuccess(c, keys)
+}
+
+// ShredDataKeysRequest confirm 须与路径中的组织ID一致，防止误操作
+type ShredDataKeysRequest struct {
+	Confirm string `json:"confirm" binding:"required"`
+	Reason  string `json:"reason"`
+}
+
+// ShredOrganizationDataKeys 销毁组织全部数据密钥，使其已加密的图片/视频永久不可读（不可恢复）
+func (h *AdminHandler) ShredOrganizationDataKeys(c *gin.Context) {
+	orgID := c.Param("id")
+	var req ShredDataKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Confirm != orgID {
+		JSONError(c, CodeInvalidParameter, "请在 confirm 中填写组织ID以确认销毁")
+		return
+	}
+	var org models.Organization
+	if err := h.service.DB.Select("id").First(&org, "id = ?", orgID).Error; err != nil {
+		JSONError(c, CodeNotFound, "组织不存在")
+		return
+	}
+	res, err := h.service.ShredOrgDataKeys(c.Request.Context(), orgID, c.GetString("userID"))
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("销毁组织数据密钥失败")
+		h.recordAuditLog(c, c.GetString("userID"), "admin.data_keys_shredded", "failed", fmt.Sprintf("Shred data keys of org %s failed", orgID))
+		JSONError(c, CodeDatabaseError, "销毁失败")
+		return
+	}
+	h.recordAuditLog(c, c.GetString("userID"), "admin.data_keys_shredded", "success",
+		fmt.Sprintf("Shredded %d data keys of org %s (%d images, %d videos): %s", res.KeysDestroyed, orgID, res.ImageAssets, res.VideoAssets, req.Reason))
+	JSONSuccess(c, res)
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"kyc-service/internal/middleware"
+	"kyc-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rejectBlockedAuth 账号或IP处于锁定/延迟期时写出 429 并返回 true
+func rejectBlockedAuth(c *gin.Context, svc *service.KYCService, purpose, account string) (*service.AuthBlock, bool) {
+	block := svc.CheckAuthAttempt(c.Request.Context(), purpose, account, c.ClientIP())
+	if block == nil {
+		return nil, false
+	}
+	secs := int(math.Ceil(block.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(secs))
+	middleware.RecordAuthFailure(purpose, block.Reason, c.ClientIP())
+	msg := fmt.Sprintf("尝试过于频繁，请 %d 秒后重试", secs)
+	if block.Reason != service.AuthBlockThrottled {
+		msg = fmt.Sprintf("多次认证失败，已临时锁定，请 %d 秒后重试", secs)
+	}
+	JSONError(c, CodeTooManyRequests, msg)
+	return block, true
+}
+
+// recordAuthFailure 记录认证失败指标与防暴力破解计数
+func recordAuthFailure(c *gin.Context, svc *service.KYCService, purpose, account, reason string) service.AuthFailureResult {
+	middleware.RecordAuthFailure(purpose, reason, c.ClientIP())
+	return svc.RecordAuthAttemptFailure(c.Request.Context(), purpose, account, c.ClientIP())
+}
@@ -2,7 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -24,12 +26,19 @@ func NewAuthHandler(svc *service.KYCService) *AuthHandler {
 	return &AuthHandler{service: svc}
 }
 
-// TokenRequest 令牌请求
+// TokenRequest 令牌请求（支持 JSON 与 application/x-www-form-urlencoded）
 type TokenRequest struct {
-	ClientID     string `json:"client_id" binding:"required"`
-	ClientSecret string `json:"client_secret" binding:"required"`
-	GrantType    string `json:"grant_type" binding:"required"`
-	Scope        string `json:"scope"`
+	ClientID     string `json:"client_id" form:"client_id"`         // 使用 client_assertion 时可省略
+	ClientSecret string `json:"client_secret" form:"client_secret"` // client_secret_post 客户端必填；只有登记为 none 的公开客户端在授权码/刷新模式下可省略
+	// private_key_jwt（RFC 7523）：以客户端私钥签名的断言代替密钥
+	ClientAssertionType string `json:"client_assertion_type" form:"client_assertion_type"`
+	ClientAssertion     string `json:"client_assertion" form:"client_assertion"`
+	GrantType           string `json:"grant_type" form:"grant_type" binding:"required"`
+	Scope               string `json:"scope" form:"scope"`
+	Code                string `json:"code" form:"code"`                   // authorization_code
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri"`   // authorization_code
+	CodeVerifier        string `json:"code_verifier" form:"code_verifier"` // authorization_code（PKCE）
+	RefreshToken        string `json:"refresh_token" form:"refresh_token"` // refresh_token
 }
 
 // TokenResponse 令牌响应
@@ -45,11 +54,12 @@ type TokenResponse struct {
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 	ClientID     string `json:"client_id" binding:"required"`
+	ClientSecret string `json:"client_secret"` // 公开客户端（none）以外必填
 }
 
 // GetToken Obtain access token
 // @Summary Obtain access token
-// @Description Use Client credentials to obtain access tokens
+// @Description Obtain access tokens with client_credentials, authorization_code (PKCE) or refresh_token grants
 // @Tags Auth
 // @Tags Public
 // @Accept json
@@ -61,26 +71,29 @@ func (h *AuthHandler) GetToken(c *gin.Context) {
 	start := time.Now()
 
 	var req TokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBind(&req); err != nil {
 		metrics.RecordBusinessOperation(c.Request.Context(), "token_request", false, time.Since(start), "invalid_request")
 		JSONError(c, CodeInvalidParameter, "Invalid request body")
 		return
 	}
 
 	// 验证授权类型
-	if req.GrantType != "client_credentials" {
+	switch req.GrantType {
+	case "client_credentials":
+	case "authorization_code", "refresh_token":
+		h.exchangeUserGrant(c, &req, start)
+		return
+	default:
 		metrics.RecordBusinessOperation(c.Request.Context(), "token_request", false, time.Since(start), "unsupported_grant_type")
 		JSONError(c, CodeInvalidParameter, "Unsupported grant type")
 		return
 	}
 	// 验证客户端凭证
-	var client models.OAuthClient
-	if err := h.service.DB.Where("id = ? AND secret = ?", req.ClientID, req.ClientSecret).First(&client).Error; err != nil {
-		metrics.RecordBusinessOperation(c.Request.Context(), "token_request", false, time.Since(start), "invalid_client")
-		middleware.RecordAuthFailure("client_credentials", "invalid_client", c.ClientIP())
-		JSONError(c, CodeUnauthorized, "Invalid client credentials")
+	client, ok := h.authenticateClient(c, &req, false, start)
+	if !ok {
 		return
 	}
+	req.ClientID = client.ID
 
 	// 计算有效的scope：若请求为空，默认使用客户端预设；否则必须是预设的子集
 	allowed := strings.Fields(strings.TrimSpace(client.Scopes))
@@ -129,7 +142,7 @@ func (h *AuthHandler) GetToken(c *gin.Context) {
 	}
 
 	var existing models.OAuthToken
-	if err := h.service.DB.Where("client_id = ? AND scopes = ? AND expires_at > ?", req.ClientID, scopeStr, time.Now()).Order("expires_at desc").First(&existing).Error; err == nil {
+	if err := h.service.DB.Where("client_id = ? AND scopes = ? AND user_id = '' AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > ?", req.ClientID, scopeStr, time.Now()).Order("expires_at desc").First(&existing).Error; err == nil {
 		remain := int(time.Until(existing.ExpiresAt).Seconds())
 		if remain > int((5 * time.Minute).Seconds()) {
 			middleware.RecordBusinessOperation("token_request", true, time.Since(start), "cache_hit_db")
@@ -150,31 +163,15 @@ func (h *AuthHandler) GetToken(c *gin.Context) {
 		}
 	}
 
-	ttl := 24 * time.Hour
-	if client.TokenTTLSeconds > 0 {
-		ttl = time.Duration(client.TokenTTLSeconds) * time.Second
-	}
-	accessToken, refreshToken, err := h.generateTokensWithTTL(req.ClientID, "", scopeStr, ttl)
+	ttl := service.OAuthAccessTTL(client)
+	token, err := h.service.IssueOAuthToken(h.service.DB, service.OAuthGrant{ClientID: req.ClientID, OrgID: client.OrgID, Scope: scopeStr, TTL: ttl})
 	if err != nil {
 		logger.GetLogger().WithError(err).Error("token generation failed")
 		metrics.RecordBusinessOperation(c.Request.Context(), "token_request", false, time.Since(start), "token_generation_failed")
 		JSONError(c, CodeInternalError, "Token generation failed")
 		return
 	}
-
-	token := &models.OAuthToken{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ClientID:     req.ClientID,
-		Scopes:       scopeStr,
-		ExpiresAt:    time.Now().Add(ttl),
-	}
-	if err := h.service.DB.Create(token).Error; err != nil {
-		logger.GetLogger().WithError(err).Error("save token failed")
-		metrics.RecordBusinessOperation(c.Request.Context(), "token_request", false, time.Since(start), "database_error")
-		JSONError(c, CodeDatabaseError, "Failed to save token")
-		return
-	}
+	accessToken, refreshToken := token.AccessToken, token.RefreshToken
 
 	if h.service.Redis != nil {
 		key := "oauth:token:" + req.ClientID + ":" + scopeStr
@@ -209,99 +206,133 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) { //ignore_security_alert IDO
 		return
 	}
 
-	// 验证刷新令牌
-	var token models.OAuthToken
-	if err := h.service.DB.Where("refresh_token = ? AND client_id = ?", req.RefreshToken, req.ClientID).First(&token).Error; err != nil {
-		JSONError(c, CodeUnauthorized, "刷新令牌无效")
+	client, ok := h.authenticateClient(c, &TokenRequest{
+		ClientID: req.ClientID, ClientSecret: req.ClientSecret, GrantType: "refresh_token",
+	}, true, time.Now())
+	if !ok {
 		return
 	}
-
-	// 检查令牌是否过期
-	if time.Now().After(token.ExpiresAt) {
-		JSONError(c, CodeUnauthorized, "刷新令牌已过期")
-		return
-	}
-
-	// 生成新的访问令牌
-	var client models.OAuthClient
-	_ = h.service.DB.Where("id = ?", token.ClientID).First(&client).Error
-	ttl := 24 * time.Hour
-	if client.TokenTTLSeconds > 0 {
-		ttl = time.Duration(client.TokenTTLSeconds) * time.Second
-	}
-	accessToken, newRefreshToken, err := h.generateTokensWithTTL(req.ClientID, token.UserID, token.Scopes, ttl)
+	token, err := h.service.RotateRefreshToken(c.Request.Context(), client, req.RefreshToken)
 	if err != nil {
-		logger.GetLogger().WithError(err).Error("生成令牌失败")
+		if errors.Is(err, service.ErrOAuthRefreshReused) {
+			h.service.RecordAuditLog(c, "oauth.refresh_reuse", "oauth", req.ClientID, "failed", "refresh token reuse, token family revoked")
+		}
+		if errors.Is(err, service.ErrOAuthInvalidGrant) || errors.Is(err, service.ErrOAuthRefreshReused) {
+			JSONError(c, CodeUnauthorized, "刷新令牌无效或已过期")
+			return
+		}
+		logger.GetLogger().WithError(err).Error("刷新令牌失败")
 		JSONError(c, CodeInternalError, "生成令牌失败")
 		return
 	}
-
-	// 更新令牌记录
-	token.AccessToken = accessToken
-	token.RefreshToken = newRefreshToken
-	token.ExpiresAt = time.Now().Add(ttl)
-
-	if err := h.service.DB.Save(&token).Error; err != nil {
-		logger.GetLogger().WithError(err).Error("更新令牌失败")
-		JSONError(c, CodeDatabaseError, "更新令牌失败")
-		return
-	}
-
-	if h.service.Redis != nil {
-		key := "oauth:token:" + token.ClientID + ":" + token.Scopes
-		payload, _ := json.Marshal(map[string]interface{}{"AccessToken": accessToken, "RefreshToken": newRefreshToken, "ExpiresAt": token.ExpiresAt.Unix()})
-		_ = h.service.Redis.Set(c.Request.Context(), key, string(payload), time.Until(token.ExpiresAt)).Err()
-	}
+	h.cacheClientToken(c, token)
 
 	// 返回标准格式的成功响应
 	JSONSuccess(c, TokenResponse{
-		AccessToken:  accessToken,
+		AccessToken:  token.AccessToken,
 		TokenType:    "Bearer",
-		ExpiresIn:    86400, // 24小时
-		RefreshToken: newRefreshToken,
+		ExpiresIn:    int(time.Until(token.ExpiresAt).Seconds()),
+		RefreshToken: token.RefreshToken,
 		Scope:        token.Scopes,
 	})
 }
 
-// generateTokens 生成访问令牌和刷新令牌
-func (h *AuthHandler) generateTokensWithTTL(clientID, userID, scope string, ttl time.Duration) (string, string, error) {
-	// 生成访问令牌
-	accessTokenClaims := jwt.MapClaims{
-		"client_id": clientID,
-		"user_id":   userID,
-		"scope":     scope,
-		"org_id": func() string {
-			var oc models.OAuthClient
-			if err := h.service.DB.Where("id = ?", clientID).First(&oc).Error; err == nil {
-				return oc.OrgID
-			}
-			return ""
-		}(),
-		"exp": time.Now().Add(ttl).Unix(),
-		"iat": time.Now().Unix(),
+// authenticateClient 令牌端点客户端认证（client_secret_post / private_key_jwt / none），失败时写出错误响应
+func (h *AuthHandler) authenticateClient(c *gin.Context, req *TokenRequest, allowPublic bool, start time.Time) (*models.OAuthClient, bool) {
+	// 按 client_id 与IP防暴力破解；private_key_jwt 请求的 client_id 可能只在断言中，此时仅按IP计数
+	if block, blocked := rejectBlockedAuth(c, h.service, service.AuthGuardOAuthToken, req.ClientID); blocked {
+		metrics.RecordBusinessOperation(c.Request.Context(), "token_request", false, time.Since(start), block.Reason)
+		return nil, false
 	}
-
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(h.service.Config.Security.JWTSecret))
+	client, err := h.service.AuthenticateOAuthClient(c.Request.Context(), service.ClientCredentials{
+		ClientID:        req.ClientID,
+		ClientSecret:    req.ClientSecret,
+		AssertionType:   req.ClientAssertionType,
+		Assertion:       req.ClientAssertion,
+		AllowPublic:     allowPublic,
+		ExpectAudiences: h.tokenEndpointAudiences(c),
+	})
 	if err != nil {
-		return "", "", err
+		metrics.RecordBusinessOperation(c.Request.Context(), "token_request", false, time.Since(start), "invalid_client")
+		middleware.RecordAuthFailure(req.GrantType, "invalid_client", c.ClientIP())
+		if res := h.service.RecordAuthAttemptFailure(c.Request.Context(), service.AuthGuardOAuthToken, req.ClientID, c.ClientIP()); res.AccountLocked || res.IPLocked {
+			h.service.RecordAuditLog(c, "oauth.client_locked", "oauth_client", req.ClientID, "locked",
+				fmt.Sprintf("Token endpoint locked for %s (client=%s, ip=%s) after repeated failures", res.LockedFor, req.ClientID, c.ClientIP()))
+		}
+		JSONError(c, CodeUnauthorized, "Invalid client credentials")
+		return nil, false
 	}
+	h.service.ClearAuthAttempts(c.Request.Context(), service.AuthGuardOAuthToken, req.ClientID)
+	return client, true
+}
 
-	// 生成刷新令牌
-	refreshTokenClaims := jwt.MapClaims{
-		"client_id": clientID,
-		"user_id":   userID,
-		"exp":       time.Now().Add(7 * 24 * time.Hour).Unix(),
-		"iat":       time.Now().Unix(),
+// tokenEndpointAudiences client_assertion 可接受的 aud：签发者标识，以及配置的对外地址（security.sso.base_url）下的端点完整地址。
+// 不使用请求中的 Host / X-Forwarded-Proto，否则客户端可伪造头部使任意 aud 的断言被接受
+func (h *AuthHandler) tokenEndpointAudiences(c *gin.Context) []string {
+	auds := []string{discoveryIssuer}
+	if base := strings.TrimRight(strings.TrimSpace(h.service.Config.Security.SSO.BaseURL), "/"); base != "" && c.FullPath() != "" {
+		auds = append(auds, base+c.FullPath())
 	}
+	return auds
+}
+
+// exchangeUserGrant 处理 authorization_code（PKCE）与 refresh_token 授权
+func (h *AuthHandler) exchangeUserGrant(c *gin.Context, req *TokenRequest, start time.Time) {
+	ctx := c.Request.Context()
+	client, ok := h.authenticateClient(c, req, true, start)
+	if !ok {
+		return
+	}
+	req.ClientID = client.ID
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshTokenClaims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(h.service.Config.Security.JWTSecret))
+	var token *models.OAuthToken
+	var err error
+	if req.GrantType == "authorization_code" {
+		if req.Code == "" || req.CodeVerifier == "" || req.RedirectURI == "" {
+			JSONError(c, CodeInvalidParameter, "code, redirect_uri and code_verifier are required")
+			return
+		}
+		token, err = h.service.ExchangeAuthorizationCode(ctx, client, req.Code, req.RedirectURI, req.CodeVerifier)
+	} else {
+		if req.RefreshToken == "" {
+			JSONError(c, CodeInvalidParameter, "refresh_token is required")
+			return
+		}
+		token, err = h.service.RotateRefreshToken(ctx, client, req.RefreshToken)
+		if errors.Is(err, service.ErrOAuthRefreshReused) {
+			h.service.RecordAuditLog(c, "oauth.refresh_reuse", "oauth", req.ClientID, "failed", "refresh token reuse, token family revoked")
+		}
+	}
 	if err != nil {
-		return "", "", err
+		if errors.Is(err, service.ErrOAuthInvalidGrant) || errors.Is(err, service.ErrOAuthRefreshReused) {
+			metrics.RecordBusinessOperation(ctx, "token_request", false, time.Since(start), "invalid_grant")
+			JSONError(c, CodeUnauthorized, "invalid_grant")
+			return
+		}
+		logger.GetLogger().WithError(err).Error("token generation failed")
+		metrics.RecordBusinessOperation(ctx, "token_request", false, time.Since(start), "token_generation_failed")
+		JSONError(c, CodeInternalError, "Token generation failed")
+		return
 	}
+	h.cacheClientToken(c, token)
+	metrics.RecordBusinessOperation(ctx, "token_request", true, time.Since(start), req.GrantType)
+	JSONSuccess(c, TokenResponse{
+		AccessToken:  token.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(token.ExpiresAt).Seconds()),
+		RefreshToken: token.RefreshToken,
+		Scope:        token.Scopes,
+	})
+}
 
-	return accessTokenString, refreshTokenString, nil
+// cacheClientToken 更新客户端凭证令牌缓存；代表用户的令牌不进入按 client+scope 共享的缓存
+func (h *AuthHandler) cacheClientToken(c *gin.Context, token *models.OAuthToken) {
+	if h.service.Redis == nil || token.UserID != "" {
+		return
+	}
+	key := "oauth:token:" + token.ClientID + ":" + token.Scopes
+	payload, _ := json.Marshal(map[string]interface{}{"AccessToken": token.AccessToken, "RefreshToken": token.RefreshToken, "ExpiresAt": token.ExpiresAt.Unix()})
+	_ = h.service.Redis.Set(c.Request.Context(), key, string(payload), time.Until(token.ExpiresAt)).Err()
 }
 
 type RevokeRequest struct {
@@ -316,6 +347,7 @@ func (h *AuthHandler) Revoke(c *gin.Context) {
 		JSONError(c, CodeInvalidParameter, "参数绑定失败")
 		return
 	}
+	ctx := c.Request.Context()
 	var tok models.OAuthToken
 	if req.TokenTypeHint == "refresh_token" {
 		_ = h.service.DB.Where("refresh_token = ? AND client_id = ?", req.Token, req.ClientID).First(&tok).Error
@@ -326,57 +358,130 @@ func (h *AuthHandler) Revoke(c *gin.Context) {
 		JSONSuccess(c, gin.H{"revoked": false})
 		return
 	}
-	if err := h.service.DB.Delete(&tok).Error; err != nil {
+	// 加入吊销列表，各认证中间件立即拒绝（不必等到 exp）；未能写入时不能报告成功
+	for _, t := range []string{tok.AccessToken, tok.RefreshToken} {
+		if err := h.service.RevokeTokenString(ctx, t); err != nil {
+			logger.GetLogger().WithError(err).Warn("写入令牌吊销列表失败")
+			JSONError(c, CodeDatabaseError, "撤销失败")
+			return
+		}
+	}
+	if tok.FamilyID != "" {
+		// 同一授权下轮换产生的令牌一并吊销
+		h.service.RevokeOAuthFamily(ctx, tok.FamilyID, "revoked_by_client")
+		h.service.RecordAuditLog(c, "oauth.revoke", "oauth", req.ClientID, "success", "")
+		JSONSuccess(c, gin.H{"revoked": true})
+		return
+	}
+	// 保留记录并标记吊销，自省与令牌复用据此判断
+	if err := h.service.DB.Model(&tok).Update("revoked_at", time.Now()).Error; err != nil {
 		JSONError(c, CodeDatabaseError, "撤销失败")
 		return
 	}
 	if h.service.Redis != nil {
 		key := "oauth:token:" + tok.ClientID + ":" + tok.Scopes
-		_ = h.service.Redis.Del(c.Request.Context(), key).Err()
+		_ = h.service.Redis.Del(ctx, key).Err()
 	}
 	h.service.RecordAuditLog(c, "oauth.revoke", "oauth", req.ClientID, "success", "")
 	JSONSuccess(c, gin.H{"revoked": true})
 }
 
+// IntrospectRequest 令牌自省请求（RFC 7662），调用方须以令牌端点相同的方式完成客户端认证
 type IntrospectRequest struct {
-	Token    string `json:"token"`
-	ClientID string `json:"client_id"`
+	Token               string `json:"token" form:"token" binding:"required"`
+	TokenTypeHint       string `json:"token_type_hint" form:"token_type_hint"`
+	ClientID            string `json:"client_id" form:"client_id"`
+	ClientSecret        string `json:"client_secret" form:"client_secret"`
+	ClientAssertionType string `json:"client_assertion_type" form:"client_assertion_type"`
+	ClientAssertion     string `json:"client_assertion" form:"client_assertion"`
 }
 
+// IntrospectResponse RFC 7662 响应；令牌无效时仅返回 active=false
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	OrgID     string `json:"org_id,omitempty"`
+}
+
+// Introspect 令牌自省（RFC 7662）
+// @Summary Token introspection
+// @Description Returns whether a token issued to the calling client is active, reflecting expiry, revocation list, family revocation and bulk revocation
+// @Tags Auth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Token to introspect"
+// @Success 200 {object} IntrospectResponse
+// @Router /api/v1/oauth/introspect [post]
 func (h *AuthHandler) Introspect(c *gin.Context) {
 	var req IntrospectRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		JSONError(c, CodeInvalidParameter, "参数绑定失败")
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
 		return
 	}
+	ctx := c.Request.Context()
+	client, err := h.service.AuthenticateOAuthClient(ctx, service.ClientCredentials{
+		ClientID:        req.ClientID,
+		ClientSecret:    req.ClientSecret,
+		AssertionType:   req.ClientAssertionType,
+		Assertion:       req.ClientAssertion,
+		ExpectAudiences: h.tokenEndpointAudiences(c),
+	})
+	if err != nil {
+		middleware.RecordAuthFailure("introspect", "invalid_client", c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+
+	inactive := IntrospectResponse{Active: false}
+	claims := jwt.MapClaims{}
+	if token, err := jwt.ParseWithClaims(req.Token, claims, h.service.JWTKeyfunc); err != nil || !token.Valid {
+		c.JSON(http.StatusOK, inactive)
+		return
+	}
+	// 只能自省签发给自己的令牌
+	if cid, _ := claims["client_id"].(string); cid != client.ID {
+		c.JSON(http.StatusOK, inactive)
+		return
+	}
+	use, _ := claims["token_use"].(string)
 	var tok models.OAuthToken
-	_ = h.service.DB.Where("access_token = ? AND client_id = ?", req.Token, req.ClientID).First(&tok).Error
-	if tok.ID == 0 {
-		_ = h.service.DB.Where("refresh_token = ? AND client_id = ?", req.Token, req.ClientID).First(&tok).Error
+	column := "access_token"
+	if use == service.OAuthTokenUseRefresh {
+		column = "refresh_token"
 	}
-	active := false
-	scope := ""
-	clientID := ""
-	exp := int64(0)
-	iat := int64(0)
-	if tok.ID != 0 && time.Now().Before(tok.ExpiresAt) {
-		active = true
-		scope = tok.Scopes
-		clientID = tok.ClientID
-	}
-	token, err := jwt.Parse(req.Token, func(token *jwt.Token) (interface{}, error) { return []byte(h.service.Config.Security.JWTSecret), nil })
-	if err == nil && token.Valid {
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			if v, ok := claims["exp"].(float64); ok {
-				exp = int64(v)
-			}
-			if v, ok := claims["iat"].(float64); ok {
-				iat = int64(v)
-			}
-			if v, ok := claims["scope"].(string); ok && scope == "" {
-				scope = v
-			}
-		}
+	if err := h.service.DB.Where(column+" = ? AND client_id = ?", req.Token, client.ID).First(&tok).Error; err != nil ||
+		tok.RevokedAt != nil || (use == service.OAuthTokenUseRefresh && tok.RotatedAt != nil) {
+		c.JSON(http.StatusOK, inactive)
+		return
+	}
+	fid, _ := claims["fid"].(string)
+	if h.service.IsTokenRevoked(ctx, claims) || h.service.IsOAuthFamilyRevoked(ctx, fid) {
+		c.JSON(http.StatusOK, inactive)
+		return
+	}
+
+	resp := IntrospectResponse{Active: true, ClientID: client.ID, Scope: tok.Scopes, TokenType: "Bearer", Iss: discoveryIssuer, OrgID: tok.OrgID}
+	if use == service.OAuthTokenUseRefresh {
+		resp.TokenType = "refresh_token"
+	}
+	resp.Sub, _ = claims["user_id"].(string)
+	if resp.Sub == "" {
+		resp.Sub = client.ID
+	}
+	resp.Jti, _ = claims["jti"].(string)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		resp.Exp = exp.Unix()
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		resp.Iat = iat.Unix()
 	}
-	JSONSuccess(c, gin.H{"active": active, "client_id": clientID, "scope": scope, "exp": exp, "iat": iat})
+	c.JSON(http.StatusOK, resp)
 }
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kyc-service/internal/config"
+	"kyc-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenEndpointAudiencesIgnoreRequestHost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	h := NewAuthHandler(&service.KYCService{Config: cfg})
+
+	var got []string
+	router := gin.New()
+	router.POST("/api/v1/oauth/token", func(c *gin.Context) { got = h.tokenEndpointAudiences(c) })
+	send := func() {
+		req := httptest.NewRequest(http.MethodPost, "https://attacker.example/api/v1/oauth/token", nil)
+		req.Host = "attacker.example"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send()
+	assert.Equal(t, []string{discoveryIssuer}, got, "未配置 base_url 时只接受签发者标识")
+
+	cfg.Security.SSO.BaseURL = "https://kyc.example.com/"
+	send()
+	assert.Equal(t, []string{discoveryIssuer, "https://kyc.example.com/api/v1/oauth/token"}, got)
+}
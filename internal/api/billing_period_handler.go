@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"kyc-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BillingSettingsResponse 组织计费设置与当前周期配额
+type BillingSettingsResponse struct {
+	Timezone string                      `json:"timezone"` // 为空表示使用服务器时区
+	Quotas   []models.OrganizationQuotas `json:"quotas"`
+}
+
+type UpdateBillingSettingsRequest struct {
+	Timezone string `json:"timezone" binding:"required"`
+}
+
+// @Summary 获取计费设置
+// @Description 获取组织时区及各服务当前计费周期（起止时间、套餐额度、结转额度）
+// @Tags Organization
+// @Produce json
+// @Success 200 {object} BillingSettingsResponse
+// @Router /api/v1/orgs/billing/settings [get]
+func (h *OrganizationHandler) GetBillingSettings(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	var org models.Organization
+	if err := h.service.DB.First(&org, "id = ?", orgID).Error; err != nil {
+		JSONError(c, CodeNotFound, "组织不存在")
+		return
+	}
+	resp := BillingSettingsResponse{Timezone: org.Timezone, Quotas: []models.OrganizationQuotas{}}
+	if err := h.service.DB.Where("organization_id = ?", orgID).Order("service_type").Find(&resp.Quotas).Error; err != nil {
+		JSONError(c, CodeDatabaseError, "查询配额失败")
+		return
+	}
+	JSONSuccess(c, resp)
+}
+
+// @Summary 更新计费设置
+// @Description 修改组织时区（IANA 名称，如 Asia/Shanghai），当前计费周期按新时区重新计算
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body UpdateBillingSettingsRequest true "计费设置"
+// @Success 200 {object} BillingSettingsResponse
+// @Router /api/v1/orgs/billing/settings [put]
+func (h *OrganizationHandler) UpdateBillingSettings(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	var req UpdateBillingSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "Invalid request body")
+		return
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		JSONError(c, CodeInvalidParameter, "无效的时区: "+req.Timezone)
+		return
+	}
+	if err := h.service.UpdateOrganizationTimezone(orgID, req.Timezone); err != nil {
+		JSONError(c, CodeDatabaseError, "保存计费设置失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.billing.timezone.update", "organization", orgID, "success", req.Timezone)
+	h.GetBillingSettings(c)
+}
+
+// @Summary 查询计费周期历史
+// @Description 按服务类型与时间范围分页查询已关闭的计费周期，用于与账单对账
+// @Tags Organization
+// @Produce json
+// @Param service_type query string false "服务类型（ocr/face/liveness）"
+// @Param from query string false "起始日期（2006-01-02 或 RFC3339）"
+// @Param to query string false "结束日期（2006-01-02 或 RFC3339）"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {array} models.QuotaPeriodHistory
+// @Router /api/v1/orgs/billing/periods [get]
+func (h *OrganizationHandler) GetBillingPeriods(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	from, ok := parseBillingTime(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseBillingTime(c, "to")
+	if !ok {
+		return
+	}
+	items, total, err := h.service.ListQuotaPeriods(c.GetString("orgID"), c.Query("service_type"), from, to, pageSize, (page-1)*pageSize)
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "查询计费周期失败")
+		return
+	}
+	JSONPaginated(c, items, page, pageSize, int(total))
+}
+
+func parseBillingTime(c *gin.Context, key string) (*time.Time, bool) {
+	v := c.Query(key)
+	if v == "" {
+		return nil, true
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, true
+		}
+	}
+	JSONError(c, CodeInvalidParameter, "无效的时间参数: "+key)
+	return nil, false
+}
+
+type UpdateOverageLimitRequest struct {
+	ServiceType string `json:"service_type" binding:"required"`
+	HardLimit   int    `json:"hard_limit"` // 当期总用量上限，0 表示不限
+}
+
+// @Summary 设置超额硬上限
+// @Description 套餐开启超额计费时，额度用完后继续服务直到该上限；0 表示不限
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body UpdateOverageLimitRequest true "超额上限"
+// @Success 200 {object} BillingSettingsResponse
+// @Router /api/v1/orgs/billing/overage [put]
+func (h *OrganizationHandler) UpdateOverageLimit(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	var req UpdateOverageLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.HardLimit < 0 {
+		JSONError(c, CodeInvalidParameter, "Invalid request body")
+		return
+	}
+	if err := h.service.SetQuotaHardLimit(c.Request.Context(), orgID, req.ServiceType, req.HardLimit); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			JSONError(c, CodeNotFound, "该服务未配置配额")
+			return
+		}
+		JSONError(c, CodeDatabaseError, "保存超额上限失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.billing.overage.update", "organization", orgID, "success", fmt.Sprintf("%s hard_limit=%d", req.ServiceType, req.HardLimit))
+	h.GetBillingSettings(c)
+}
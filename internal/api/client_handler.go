@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
@@ -33,12 +34,18 @@ type ClientRegistrationRequest struct {
 	OwnerID         string   `json:"owner_id"`
 	IPWhitelist     []string `json:"ip_whitelist"`
 	RateLimitPerSec int      `json:"rate_limit_per_sec"`
+	// private_key_jwt 客户端不签发密钥，须登记 JWK Set（jwks）或 PEM 公钥（public_key）；
+	// none 为公开客户端，不签发密钥，只能用于授权码（PKCE）与刷新模式
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method"`
+	JWKS                    json.RawMessage `json:"jwks" swaggertype:"object"`
+	PublicKey               string          `json:"public_key"`
 }
 
 // ClientRegistrationResponse 客户端注册响应
 type ClientRegistrationResponse struct {
 	ClientID     string    `json:"client_id"`
-	ClientSecret string    `json:"client_secret"`
+	ClientSecret string    `json:"client_secret,omitempty"` // 仅在创建时返回
+	AuthMethod   string    `json:"token_endpoint_auth_method"`
 	Name         string    `json:"name"`
 	Description  string    `json:"description"`
 	RedirectURI  string    `json:"redirect_uri"`
@@ -51,6 +58,7 @@ type ClientRegistrationResponse struct {
 // ClientListResponse 客户端列表响应
 type ClientListResponse struct {
 	ID          string    `json:"client_id"`
+	AuthMethod  string    `json:"token_endpoint_auth_method"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	RedirectURI string    `json:"redirect_uri"`
@@ -105,9 +113,22 @@ func (h *ClientHandler) RegisterClient(c *gin.Context) {
 		req.TokenTTLSeconds = policy.MaxTokenTTLSec
 	}
 
+	authMethod, publicKeys, ok := parseClientAuthMethod(c, req.TokenEndpointAuthMethod, req.JWKS, req.PublicKey)
+	if !ok {
+		return
+	}
+	// 公开客户端只能走授权码（PKCE）流程，须登记回调地址
+	if authMethod == service.ClientAuthNone && req.RedirectURI == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Public clients (token_endpoint_auth_method=none) require redirect_uri"})
+		return
+	}
+
 	// 生成客户端凭证
 	clientID := uuid.New().String()
-	clientSecret := uuid.New().String()
+	clientSecret := ""
+	if authMethod == service.ClientAuthSecretPost {
+		clientSecret = uuid.New().String()
+	}
 
 	// 创建客户端记录
 	ownerID := c.GetString("userID")
@@ -126,7 +147,8 @@ func (h *ClientHandler) RegisterClient(c *gin.Context) {
 	}
 	client := &models.OAuthClient{
 		ID:          clientID,
-		Secret:      clientSecret,
+		AuthMethod:  authMethod,
+		JWKS:        publicKeys,
 		Name:        req.Name,
 		Description: req.Description,
 		RedirectURI: req.RedirectURI,
@@ -140,6 +162,10 @@ func (h *ClientHandler) RegisterClient(c *gin.Context) {
 		RateLimitPerSec: req.RateLimitPerSec,
 	}
 
+	if clientSecret != "" {
+		h.service.SetClientSecret(client, clientSecret, 0)
+	}
+
 	if err := h.service.DB.Create(client).Error; err != nil {
 		logger.GetLogger().WithError(err).Error("create client failed")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
@@ -152,6 +178,7 @@ func (h *ClientHandler) RegisterClient(c *gin.Context) {
 	c.JSON(http.StatusCreated, ClientRegistrationResponse{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
+		AuthMethod:   authMethod,
 		Name:         req.Name,
 		Description:  req.Description,
 		RedirectURI:  req.RedirectURI,
@@ -204,6 +231,7 @@ func (h *ClientHandler) ListClients(c *gin.Context) {
 	for i, client := range clients {
 		response[i] = ClientListResponse{
 			ID:          client.ID,
+			AuthMethod:  client.AuthMethod,
 			Name:        client.Name,
 			Description: client.Description,
 			RedirectURI: client.RedirectURI,
@@ -240,28 +268,61 @@ func (h *ClientHandler) DeleteClient(c *gin.Context) {
 	if orgID != "" {
 		q = q.Where("org_id = ?", orgID)
 	}
-	if err := q.Delete(&models.OAuthClient{}).Error; err != nil {
-		logger.GetLogger().WithError(err).Error("delete client failed")
+	res := q.Delete(&models.OAuthClient{})
+	if res.Error != nil {
+		logger.GetLogger().WithError(res.Error).Error("delete client failed")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client"})
 		return
 	}
 
+	// 仅吊销本组织内确实被删除的客户端的令牌
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	if err := h.service.RevokeTokensBy(c.Request.Context(), service.RevocationByClient, clientID, "client_deleted"); err != nil {
+		logger.GetLogger().WithError(err).Error("revoke client tokens failed")
+	}
+
 	// 记录删除审计日志
 	h.service.RecordAuditLog(c, "client.delete", "client", clientID, "success", "client deleted")
 	c.JSON(http.StatusOK, gin.H{"message": "Client deleted"})
 }
 
+// RotateSecretRequest 轮换密钥请求
+type RotateSecretRequest struct {
+	// 旧密钥继续可用的秒数，默认86400（24小时），0 表示立即失效，最长7天
+	GracePeriodSeconds *int `json:"grace_period_seconds"`
+}
+
 type RotateSecretResponse struct {
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
+	ClientID                string     `json:"client_id"`
+	ClientSecret            string     `json:"client_secret"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
 }
 
+// RotateClientSecret 轮换客户端密钥，宽限期内新旧密钥均可换取令牌
 func (h *ClientHandler) RotateClientSecret(c *gin.Context) {
 	clientID := c.Param("id")
 	if clientID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Client ID must not be empty"})
 		return
 	}
+	var req RotateSecretRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	grace := service.DefaultClientSecretGrace
+	if req.GracePeriodSeconds != nil {
+		grace = time.Duration(*req.GracePeriodSeconds) * time.Second
+		if grace < 0 || grace > service.MaxClientSecretGrace {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period_seconds must be between 0 and 604800"})
+			return
+		}
+	}
 	orgID := c.GetString("orgID")
 	var client models.OAuthClient
 	q := h.service.DB.Where("id = ?", clientID)
@@ -272,14 +333,85 @@ func (h *ClientHandler) RotateClientSecret(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
 		return
 	}
+	if client.AuthMethod == service.ClientAuthPrivateKeyJWT {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Client uses private_key_jwt; update its public keys instead"})
+		return
+	}
+	if client.AuthMethod == service.ClientAuthNone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Public client has no secret"})
+		return
+	}
 	newSecret := uuid.New().String()
-	client.Secret = newSecret
+	h.service.SetClientSecret(&client, newSecret, grace)
 	if err := h.service.DB.Save(&client).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Secret rotation failed"})
 		return
 	}
-	h.service.RecordAuditLog(c, "client.rotate_secret", "client", clientID, "success", "")
-	c.JSON(http.StatusOK, RotateSecretResponse{ClientID: clientID, ClientSecret: newSecret})
+	h.service.RecordAuditLog(c, "client.rotate_secret", "client", clientID, "success", "grace="+grace.String())
+	c.JSON(http.StatusOK, RotateSecretResponse{ClientID: clientID, ClientSecret: newSecret, PreviousSecretExpiresAt: client.PreviousSecretExpiresAt})
+}
+
+// UpdateClientKeysRequest 更新 private_key_jwt 客户端公钥（jwks 与 public_key 二选一）
+type UpdateClientKeysRequest struct {
+	JWKS      json.RawMessage `json:"jwks" swaggertype:"object"`
+	PublicKey string          `json:"public_key"`
+}
+
+// UpdateClientKeys 替换客户端登记的验签公钥，用于客户端自行轮换私钥
+func (h *ClientHandler) UpdateClientKeys(c *gin.Context) {
+	clientID := c.Param("id")
+	var req UpdateClientKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	_, publicKeys, ok := parseClientAuthMethod(c, service.ClientAuthPrivateKeyJWT, req.JWKS, req.PublicKey)
+	if !ok {
+		return
+	}
+	orgID := c.GetString("orgID")
+	q := h.service.DB.Model(&models.OAuthClient{}).Where("id = ?", clientID)
+	if orgID != "" {
+		q = q.Where("org_id = ?", orgID)
+	}
+	// 切换为 private_key_jwt 时同时作废密钥，避免两种认证方式并存
+	res := q.Updates(map[string]interface{}{
+		"auth_method": service.ClientAuthPrivateKeyJWT, "jwks": publicKeys,
+		"secret": "", "secret_hash": "", "secret_enc": "", "previous_secret_hash": "", "previous_secret_expires_at": nil,
+	})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update client keys"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	h.service.RecordAuditLog(c, "client.update_keys", "client", clientID, "success", "")
+	c.JSON(http.StatusOK, gin.H{"client_id": clientID, "token_endpoint_auth_method": service.ClientAuthPrivateKeyJWT})
+}
+
+// parseClientAuthMethod 校验认证方式与登记的公钥，返回规范化的认证方式与待保存的公钥文本
+func parseClientAuthMethod(c *gin.Context, method string, jwks json.RawMessage, publicKey string) (string, string, bool) {
+	switch method {
+	case "", service.ClientAuthSecretPost:
+		return service.ClientAuthSecretPost, "", true
+	case service.ClientAuthNone:
+		return service.ClientAuthNone, "", true
+	case service.ClientAuthPrivateKeyJWT:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported token_endpoint_auth_method"})
+		return "", "", false
+	}
+	keys := strings.TrimSpace(publicKey)
+	if len(jwks) > 0 && string(jwks) != "null" {
+		keys = string(jwks)
+	}
+	if err := service.ValidateClientPublicKeys(keys); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "private_key_jwt requires a valid jwks or public_key", "details": err.Error()})
+		return "", "", false
+	}
+	return service.ClientAuthPrivateKeyJWT, keys, true
 }
 
 type UpdateStatusRequest struct {
@@ -302,10 +434,16 @@ func (h *ClientHandler) UpdateClientStatus(c *gin.Context) {
 	if orgID != "" {
 		q = q.Where("org_id = ?", orgID)
 	}
-	if err := q.Update("status", req.Status).Error; err != nil {
+	res := q.Update("status", req.Status)
+	if res.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
 		return
 	}
+	if res.RowsAffected > 0 && req.Status != "active" {
+		if err := h.service.RevokeTokensBy(c.Request.Context(), service.RevocationByClient, clientID, "client_"+req.Status); err != nil {
+			logger.GetLogger().WithError(err).Error("revoke client tokens failed")
+		}
+	}
 	h.service.RecordAuditLog(c, "client.update_status", "client", clientID, "success", req.Status)
 	c.JSON(http.StatusOK, gin.H{"client_id": clientID, "status": req.Status})
 }
@@ -326,6 +464,16 @@ func (h *ClientHandler) GetClientSecret(c *gin.Context) {
 		JSONError(c, CodeForbidden, "Access denied")
 		return
 	}
+	// 密钥仅保存哈希与加密副本；未配置加密或旧版迁移的客户端需轮换后取得新密钥
+	if client.SecretEnc == "" || h.service.Encryptor == nil {
+		JSONError(c, CodeNotFound, "Secret is not retrievable, rotate the secret to obtain a new one")
+		return
+	}
+	secret, err := h.service.Encryptor.Decrypt(client.SecretEnc)
+	if err != nil {
+		JSONError(c, CodeInternalError, "Failed to decrypt secret")
+		return
+	}
 	h.service.RecordAuditLog(c, "oauth.client.secret.read", "oauth_client", client.ID, "success", "")
-	JSONSuccess(c, gin.H{"id": client.ID, "secret": client.Secret})
+	JSONSuccess(c, gin.H{"id": client.ID, "secret": secret})
 }
@@ -1,10 +1,8 @@
 package api
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -35,14 +33,20 @@ func NewConsoleAuthHandler(svc *service.KYCService) *ConsoleAuthHandler {
 
 // ConsoleLoginRequest 登录请求
 type ConsoleLoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=6"`
+	DeviceName string `json:"device_name"` // 可选，缺省时根据 User-Agent 识别
 }
 
 // ConsoleLoginResponse 登录响应
 type ConsoleLoginResponse struct {
-	AccessToken string              `json:"access_token"`
-	User        *ConsoleUserProfile `json:"user"`
+	AccessToken  string              `json:"access_token"`
+	RefreshToken string              `json:"refresh_token"`
+	ExpiresIn    int                 `json:"expires_in"` // 访问令牌有效期（秒）
+	SessionID    string              `json:"session_id"`
+	User         *ConsoleUserProfile `json:"user"`
+	// MFAEnrollmentRequired 组织要求MFA但用户尚未启用，需先完成注册才能访问其他接口
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
 	//Orgs        []OrganizationLite  `json:"orgs,omitempty"`
 }
 
@@ -115,6 +119,16 @@ func (h *ConsoleAuthHandler) Login(c *gin.Context) { // ignore_security_alert
 		Message:   fmt.Sprintf("Login attempt for email: %s", req.Email),
 	}
 
+	// 账号或IP处于锁定/延迟期
+	if block, blocked := rejectBlockedAuth(c, h.service, service.AuthGuardConsoleLogin, req.Email); blocked {
+		auditLog.Action = "login_blocked"
+		auditLog.Status = "failed"
+		auditLog.Message = fmt.Sprintf("Login blocked for email %s: %s", req.Email, block.Reason)
+		h.recordAuditLog(auditLog)
+		metrics.RecordBusinessOperation(c.Request.Context(), "console_login", false, time.Since(start), block.Reason)
+		return
+	}
+
 	// 查找用户
 	var user models.User
 	if err := h.service.DB.Where("email = ? AND status = ?", req.Email, "active").First(&user).Error; err != nil {
@@ -122,6 +136,7 @@ func (h *ConsoleAuthHandler) Login(c *gin.Context) { // ignore_security_alert
 			auditLog.Status = "failed"
 			auditLog.Message = "User not found or inactive"
 			h.recordAuditLog(auditLog)
+			h.recordLoginFailure(c, nil, req.Email, "user_not_found")
 			metrics.RecordBusinessOperation(c.Request.Context(), "console_login", false, time.Since(start), "user_not_found")
 			JSONError(c, CodeUnauthorized, "邮箱或密码错误")
 			return
@@ -138,21 +153,83 @@ func (h *ConsoleAuthHandler) Login(c *gin.Context) { // ignore_security_alert
 		auditLog.Status = "failed"
 		auditLog.Message = "Invalid password"
 		h.recordAuditLog(auditLog)
+		h.recordLoginFailure(c, &user, req.Email, "invalid_password")
 		metrics.RecordBusinessOperation(c.Request.Context(), "console_login", false, time.Since(start), "invalid_password")
 		JSONError(c, CodeUnauthorized, "邮箱或密码错误")
 		return
 	}
 
-	// 延后到解析组织上下文与角色后再生成Token
+	h.service.ClearAuthAttempts(c.Request.Context(), service.AuthGuardConsoleLogin, req.Email)
 
-	// 更新最后登录时间
-	now := time.Now()
-	user.LastLoginAt = &now
-	// 设置当前组织上下文
+	// 已启用第二因素的账号先签发MFA挑战，第二步验证通过后再建立会话
+	if startMFAChallenge(c, h.service, &user, []string{service.AMRPassword}, "") {
+		auditLog.UserID = user.ID
+		auditLog.OrgID = user.OrgID
+		auditLog.Status = "mfa_required"
+		auditLog.Message = "Password accepted, awaiting second factor"
+		h.recordAuditLog(auditLog)
+		metrics.RecordBusinessOperation(c.Request.Context(), "console_login", true, time.Since(start), "mfa_required")
+		return
+	}
+
+	h.completeLogin(c, &user, auditLog, service.SessionAuth{AMR: []string{service.AMRPassword}}, req.DeviceName, start)
+}
+
+// recordLoginFailure 记录登录失败；触发锁定时写入独立的审计动作并邮件通知账号所有者
+func (h *ConsoleAuthHandler) recordLoginFailure(c *gin.Context, user *models.User, email, reason string) {
+	res := recordAuthFailure(c, h.service, service.AuthGuardConsoleLogin, email, reason)
+	if !res.AccountLocked && !res.IPLocked {
+		return
+	}
+	lock := &models.AuditLog{
+		Action:    "account_locked",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Status:    "locked",
+	}
+	if res.IPLocked && !res.AccountLocked {
+		lock.Action = "ip_locked"
+	}
+	if user != nil {
+		lock.UserID = user.ID
+		lock.OrgID = user.OrgID
+	}
+	lock.Message = fmt.Sprintf("Login locked for %s (email=%s, ip=%s) after repeated failures", res.LockedFor, email, c.ClientIP())
+	h.recordAuditLog(lock)
+	if res.AccountLocked && user != nil {
+		h.service.NotifyAccountLocked(user.Email, c.ClientIP(), res.LockedFor)
+	}
+}
+
+// completeLogin 第一因素（或第二因素）通过后建立控制台会话
+func (h *ConsoleAuthHandler) completeLogin(c *gin.Context, user *models.User, auditLog *models.AuditLog, auth service.SessionAuth, deviceName string, start time.Time) {
+	// 设置当前组织上下文：SSO 登录固定进入对应组织
+	if auth.SSOOrgID != "" {
+		user.CurrentOrgID = auth.SSOOrgID
+	}
 	if user.CurrentOrgID == "" {
 		user.CurrentOrgID = user.OrgID
 	}
-	if err := h.service.DB.Save(&user).Error; err != nil {
+	// 当前组织要求通过 SSO 访问时，改为进入用户可直接访问的其他组织；没有则拒绝
+	if auth.SSOOrgID == "" && h.service.SSORequiredFor(user.CurrentOrgID, h.memberRole(user, user.CurrentOrgID)) {
+		alt := h.service.OrgWithoutSSORequirement(user.ID)
+		if alt == "" {
+			auditLog.UserID = user.ID
+			auditLog.OrgID = user.CurrentOrgID
+			auditLog.Status = "failed"
+			auditLog.Message = "Organization requires SSO login"
+			h.recordAuditLog(auditLog)
+			metrics.RecordBusinessOperation(c.Request.Context(), "console_login", false, time.Since(start), "sso_required")
+			JSONError(c, CodeForbidden, "所在组织要求通过企业 SSO 登录")
+			return
+		}
+		user.CurrentOrgID = alt
+	}
+
+	// 更新最后登录时间
+	now := time.Now()
+	user.LastLoginAt = &now
+	if err := h.service.DB.Save(user).Error; err != nil {
 		logger.GetLogger().WithError(err).Error("更新登录时间失败")
 	}
 
@@ -175,13 +252,7 @@ func (h *ConsoleAuthHandler) Login(c *gin.Context) { // ignore_security_alert
 	if err := h.service.DB.Where("organization_id = ? AND user_id = ?", orgIDToUse, user.ID).First(&member).Error; err == nil && member.Role != "" {
 		roleToUse = member.Role
 	}
-	var permIDs []string
-	var rows []struct{ PermissionID string }
-	if err := h.service.DB.Table("role_permissions").Select("permission_id").Where("role_id = ?", roleToUse).Scan(&rows).Error; err == nil {
-		for _, r := range rows {
-			permIDs = append(permIDs, r.PermissionID)
-		}
-	}
+	permIDs := h.service.RolePermissions(c.Request.Context(), orgIDToUse, roleToUse)
 
 	// 获取组织信息（以选定的 orgID 为准）
 	var org models.Organization
@@ -195,8 +266,19 @@ func (h *ConsoleAuthHandler) Login(c *gin.Context) { // ignore_security_alert
 	user.CurrentOrgID = orgIDToUse
 	user.OrgRole = roleToUse
 	user.OrgID = orgIDToUse
-	// 生成JWT令牌（绑定当前选定组织）
-	accessToken, err := h.generateUserJWT(&user, &org)
+	// 创建服务端会话并签发访问令牌（绑定当前选定组织）
+	sess, refreshToken, err := h.service.CreateConsoleSession(user.ID, auth, service.SessionClient{
+		DeviceName: deviceName,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	})
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("创建会话失败")
+		metrics.RecordBusinessOperation(c.Request.Context(), "console_login", false, time.Since(start), "session_create_failed")
+		JSONError(c, CodeDatabaseError, "系统错误")
+		return
+	}
+	accessToken, err := h.generateUserJWT(user, &org, sess)
 	if err != nil {
 		logger.GetLogger().WithError(err).Error("生成JWT失败")
 		metrics.RecordBusinessOperation(c.Request.Context(), "console_login", false, time.Since(start), "jwt_generation_failed")
@@ -243,7 +325,14 @@ func (h *ConsoleAuthHandler) Login(c *gin.Context) { // ignore_security_alert
 	}
 
 	//JSONSuccess(c, userProfile)
-	JSONSuccess(c, ConsoleLoginResponse{AccessToken: accessToken, User: userProfile})
+	JSONSuccess(c, ConsoleLoginResponse{
+		AccessToken:           accessToken,
+		RefreshToken:          refreshToken,
+		ExpiresIn:             int(h.service.SessionAccessTTL().Seconds()),
+		SessionID:             sess.ID,
+		User:                  userProfile,
+		MFAEnrollmentRequired: !service.HasAMR(auth.AMR, service.AMRMFA) && h.service.MFARequired(user, orgIDToUse),
+	})
 }
 
 func (h *ConsoleAuthHandler) Me(c *gin.Context) {
@@ -270,13 +359,7 @@ func (h *ConsoleAuthHandler) Me(c *gin.Context) {
 	if err := h.service.DB.Where("organization_id = ? AND user_id = ?", orgIDToUse, user.ID).First(&member).Error; err == nil && member.Role != "" {
 		roleToUse = member.Role
 	}
-	var permIDs []string
-	var rows []struct{ PermissionID string }
-	if err := h.service.DB.Table("role_permissions").Select("permission_id").Where("role_id = ?", roleToUse).Scan(&rows).Error; err == nil {
-		for _, r := range rows {
-			permIDs = append(permIDs, r.PermissionID)
-		}
-	}
+	permIDs := h.service.RolePermissions(c.Request.Context(), orgIDToUse, roleToUse)
 	resp := &ConsoleUserProfile{
 		ID:              user.ID,
 		Email:           user.Email,
@@ -368,35 +451,13 @@ func (h *ConsoleAuthHandler) Register(c *gin.Context) { // ignore_security_alert
 		return
 	}
 
-	var raw string
-	if err := tx.Raw("SELECT quota_config::text FROM plans WHERE id = ?", org.PlanID).Scan(&raw).Error; err != nil {
-		JSONError(c, CodeDatabaseError, "组织创建失败")
-		return
-	}
-	if raw == "" {
+	if err := service.SyncOrganizationQuotasTx(tx, org.ID, org.PlanID, true); err != nil {
+		tx.Rollback()
+		logger.GetLogger().WithError(err).Error("初始化组织配额失败")
 		JSONError(c, CodeDatabaseError, "组织创建失败")
 		return
 	}
 
-	var m map[string]map[string]interface{}
-	_ = json.Unmarshal([]byte(raw), &m)
-	for svc, v := range m {
-		alloc := 0
-		if l, ok := v["limit"].(float64); ok {
-			alloc = int(l)
-		}
-		var reset interface{}
-		if p, ok := v["period"].(string); ok && p == "monthly" {
-			nm := time.Date(time.Now().Year(), time.Now().Month()+1, 1, 0, 0, 0, 0, time.Now().Location())
-			reset = nm
-		} else {
-			reset = nil
-		}
-
-		tx.Exec("INSERT INTO organization_quotas(id, organization_id, service_type, allocation, consumed, reset_at, updated_at) VALUES(?, ?, ?, ?, 0, ?, NOW()) ON CONFLICT (organization_id, service_type) DO UPDATE SET allocation = EXCLUDED.allocation, consumed = LEAST(organization_quotas.consumed, EXCLUDED.allocation), reset_at = EXCLUDED.reset_at, updated_at = NOW()", utils.GenerateID(), org.ID, svc, alloc, reset)
-		metrics.SetOrgQuotaLimit(context.Background(), org.ID, svc, alloc)
-	}
-
 	// 加密密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -487,8 +548,8 @@ func (s *ConsoleAuthHandler) SyncOrganizationQuotasWithPolicy(orgID string, plan
 	return nil
 }
 
-// generateUserJWT 生成用户JWT令牌
-func (h *ConsoleAuthHandler) generateUserJWT(user *models.User, org *models.Organization) (string, error) {
+// generateUserJWT 为会话签发短期访问令牌，amr 沿用会话记录的认证方式
+func (h *ConsoleAuthHandler) generateUserJWT(user *models.User, org *models.Organization, sess *models.ConsoleSession) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":  user.ID,
 		"email":    user.Email,
@@ -496,12 +557,19 @@ func (h *ConsoleAuthHandler) generateUserJWT(user *models.User, org *models.Orga
 		"org_id":   user.OrgID,
 		"org_role": user.OrgRole,
 		"plan_id":  org.PlanID,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
+		"sid":      sess.ID,
+		"exp":      time.Now().Add(h.service.SessionAccessTTL()).Unix(),
 		"iat":      time.Now().Unix(),
 	}
+	if sess.SSOOrgID != "" {
+		claims["sso_org"] = sess.SSOOrgID
+	}
+	mfaAt := time.Time{}
+	if sess.MFAAt != nil {
+		mfaAt = *sess.MFAAt
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.service.Config.Security.JWTSecret))
+	return h.service.SignJWT(service.SessionAuthClaims(claims, service.SessionAMR(sess), mfaAt))
 }
 
 // createDefaultAPIKey 创建默认API密钥
@@ -569,6 +637,15 @@ func (h *ConsoleAuthHandler) generateAPIKeySecret() string {
 	return "sk_live_" + strings.ToLower(encoded)
 }
 
+// memberRole 用户在组织中的角色，非成员时回退到用户记录的角色
+func (h *ConsoleAuthHandler) memberRole(user *models.User, orgID string) string {
+	var member models.OrganizationMember
+	if err := h.service.DB.Where("organization_id = ? AND user_id = ?", orgID, user.ID).First(&member).Error; err == nil && member.Role != "" {
+		return member.Role
+	}
+	return user.OrgRole
+}
+
 // recordAuditLog 记录审计日志
 func (h *ConsoleAuthHandler) recordAuditLog(log *models.AuditLog) {
 	if err := h.service.DB.Create(log).Error; err != nil {
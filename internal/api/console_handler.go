@@ -1023,6 +1023,9 @@ func (h *ConsoleHandler) DeleteMe(c *gin.Context) {
 		JSONError(c, CodeDatabaseError, "事务提交失败")
 		return
 	}
+	if err := h.service.RevokeTokensBy(c.Request.Context(), service.RevocationByUser, userID, "account_deleted"); err != nil {
+		logger.GetLogger().WithError(err).Error("吊销用户令牌失败")
+	}
 	JSONSuccess(c, gin.H{"deleted": true})
 }
 
@@ -1075,10 +1078,13 @@ func (h *ConsoleHandler) MarkNotificationRead(c *gin.Context) {
 }
 
 type QuotaStatusItem struct {
-	Limit     int     `json:"limit"`
-	Used      int     `json:"used"`
-	Remaining int     `json:"remaining"`
-	ResetAt   *string `json:"reset_at"`
+	Limit          int     `json:"limit"`
+	Used           int     `json:"used"`
+	Remaining      int     `json:"remaining"`
+	OverageEnabled bool    `json:"overage_enabled"`
+	Overage        int     `json:"overage"`              // 当期超额计费用量
+	HardLimit      int     `json:"hard_limit,omitempty"` // 超额硬上限（当期总用量），0 表示不限
+	ResetAt        *string `json:"reset_at"`
 }
 
 func (h *ConsoleHandler) GetQuotaStatus(c *gin.Context) {
@@ -1092,7 +1098,10 @@ func (h *ConsoleHandler) GetQuotaStatus(c *gin.Context) {
 			s := q.ResetAt.UTC().Format("2006-01-02T15:04:05Z")
 			resetStr = &s
 		}
-		m[q.ServiceType] = QuotaStatusItem{Limit: q.Allocation, Used: q.Consumed, Remaining: q.Allocation - q.Consumed, ResetAt: resetStr}
+		m[q.ServiceType] = QuotaStatusItem{
+			Limit: q.Allocation, Used: q.Consumed, Remaining: max(q.Allocation-q.Consumed, 0),
+			OverageEnabled: q.OverageEnabled, Overage: q.Overage, HardLimit: q.HardLimit, ResetAt: resetStr,
+		}
 	}
 	JSONSuccess(c, m)
 }
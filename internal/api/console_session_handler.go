@@ -0,0 +1,180 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshSessionRequest 刷新访问令牌请求
+type RefreshSessionRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ConsoleSessionResponse 会话列表项
+type ConsoleSessionResponse struct {
+	models.ConsoleSession
+	Current bool `json:"current"`
+}
+
+// Refresh 用刷新令牌换取新的访问令牌，刷新令牌同时轮换
+// @Summary 刷新控制台会话
+// @Tags Console Auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshSessionRequest true "刷新令牌"
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/auth/refresh [post]
+func (h *ConsoleAuthHandler) Refresh(c *gin.Context) {
+	var req RefreshSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	sess, refreshToken, err := h.service.RefreshConsoleSession(req.RefreshToken, service.SessionClient{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrSessionInvalid) || errors.Is(err, service.ErrSessionReuse) {
+			JSONError(c, CodeUnauthorized, "会话已失效，请重新登录")
+			return
+		}
+		logger.GetLogger().WithError(err).Error("刷新会话失败")
+		JSONError(c, CodeDatabaseError, "系统错误")
+		return
+	}
+
+	var user models.User
+	if err := h.service.DB.First(&user, "id = ? AND status = ?", sess.UserID, "active").Error; err != nil {
+		_ = h.service.RevokeConsoleSession(sess.UserID, sess.ID, "user_inactive")
+		JSONError(c, CodeUnauthorized, "用户不存在或已禁用")
+		return
+	}
+	// 与登录一致：令牌绑定用户当前组织及其在该组织的角色；SSO 会话固定绑定登录的组织
+	orgID := user.CurrentOrgID
+	if orgID == "" {
+		orgID = user.OrgID
+	}
+	if sess.SSOOrgID != "" {
+		orgID = sess.SSOOrgID
+	}
+	var member models.OrganizationMember
+	if err := h.service.DB.Where("organization_id = ? AND user_id = ?", orgID, user.ID).First(&member).Error; err == nil && member.Role != "" {
+		user.OrgRole = member.Role
+	}
+	var org models.Organization
+	if err := h.service.DB.First(&org, "id = ?", orgID).Error; err != nil {
+		JSONError(c, CodeInternalError, "组织信息错误")
+		return
+	}
+	user.OrgID = orgID
+	accessToken, err := h.generateUserJWT(&user, &org, sess)
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("生成JWT失败")
+		JSONError(c, CodeInternalError, "令牌生成失败")
+		return
+	}
+	JSONSuccess(c, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(h.service.SessionAccessTTL().Seconds()),
+		"session_id":    sess.ID,
+	})
+}
+
+// Logout 退出当前会话
+// @Summary 退出登录
+// @Tags Console Auth
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/auth/logout [post]
+func (h *ConsoleAuthHandler) Logout(c *gin.Context) {
+	userID := c.GetString("userID")
+	sid := c.GetString("sessionID")
+	if err := h.service.RevokeConsoleSession(userID, sid, service.SessionRevokeLogout); err != nil && !errors.Is(err, service.ErrSessionInvalid) {
+		logger.GetLogger().WithError(err).Error("退出登录失败")
+		JSONError(c, CodeDatabaseError, "系统错误")
+		return
+	}
+	h.sessionAudit(c, "session.logout", fmt.Sprintf("Session %s signed out", sid))
+	JSONSuccess(c, gin.H{"logged_out": true})
+}
+
+// ListSessions 当前用户的登录设备
+// @Summary 会话列表
+// @Tags Console
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/console/sessions [get]
+func (h *ConsoleAuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.service.ListConsoleSessions(c.GetString("userID"))
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "查询会话失败")
+		return
+	}
+	current := c.GetString("sessionID")
+	out := make([]ConsoleSessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, ConsoleSessionResponse{ConsoleSession: s, Current: s.ID == current})
+	}
+	JSONSuccess(c, gin.H{"sessions": out})
+}
+
+// RevokeSession 远程下线某个会话
+// @Summary 终止会话
+// @Tags Console
+// @Produce json
+// @Param id path string true "会话ID"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/console/sessions/{id} [delete]
+func (h *ConsoleAuthHandler) RevokeSession(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.service.RevokeConsoleSession(c.GetString("userID"), id, service.SessionRevokeRemote); err != nil {
+		if errors.Is(err, service.ErrSessionInvalid) {
+			JSONError(c, CodeNotFound, "会话不存在")
+			return
+		}
+		JSONError(c, CodeDatabaseError, "操作失败")
+		return
+	}
+	h.sessionAudit(c, "session.revoke", fmt.Sprintf("Session %s revoked", id))
+	JSONSuccess(c, gin.H{"revoked": true, "current": id == c.GetString("sessionID")})
+}
+
+// RevokeOtherSessions 下线除当前会话外的全部会话
+// @Summary 终止其他会话
+// @Tags Console
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/console/sessions [delete]
+func (h *ConsoleAuthHandler) RevokeOtherSessions(c *gin.Context) {
+	n, err := h.service.RevokeConsoleSessions(c.GetString("userID"), c.GetString("sessionID"), service.SessionRevokeRemote)
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "操作失败")
+		return
+	}
+	h.sessionAudit(c, "session.revoke_others", fmt.Sprintf("%d sessions revoked", n))
+	JSONSuccess(c, gin.H{"revoked": n})
+}
+
+func (h *ConsoleAuthHandler) sessionAudit(c *gin.Context, action, msg string) {
+	h.recordAuditLog(&models.AuditLog{
+		UserID:    c.GetString("userID"),
+		OrgID:     c.GetString("orgID"),
+		Action:    action,
+		Resource:  "session",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Status:    "success",
+		Message:   msg,
+		CreatedAt: time.Now(),
+	})
+}
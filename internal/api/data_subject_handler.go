@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"kyc-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dataSubjectQuery 解析查找条件并查找数据主体；出错时已写入响应
+func (h *OrganizationHandler) dataSubjectQuery(c *gin.Context) (service.DataSubjectQuery, *service.DataSubjectRecords, bool) {
+	var q service.DataSubjectQuery
+	if err := c.ShouldBindJSON(&q); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数错误")
+		return q, nil, false
+	}
+	recs, err := h.service.FindDataSubject(c.Request.Context(), c.GetString("orgID"), q)
+	switch {
+	case err == nil:
+		return q, recs, true
+	case errors.Is(err, service.ErrDataSubjectQueryEmpty), errors.Is(err, service.ErrDataSubjectTooMany):
+		JSONError(c, CodeInvalidParameter, err.Error())
+	case errors.Is(err, service.ErrDataSubjectNotFound):
+		JSONError(c, CodeNotFound, "未找到该数据主体的数据")
+	default:
+		JSONError(c, CodeDatabaseError, "查找数据主体失败")
+	}
+	return q, nil, false
+}
+
+// @Summary 查找数据主体
+// @Description 按证件号（或其哈希）、手机号或请求ID查找本组织内该数据主体的KYC请求、图片、视频、异步任务、人脸搜索引用、动作活体会话与请求日志，只返回ID与状态
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body service.DataSubjectQuery true "查找条件，至少一项"
+// @Success 200 {object} service.DataSubjectSummary
+// @Router /api/v1/orgs/data-subjects/search [post]
+func (h *OrganizationHandler) SearchDataSubject(c *gin.Context) {
+	q, recs, ok := h.dataSubjectQuery(c)
+	if !ok {
+		return
+	}
+	sum := recs.Summary(h.service.DataSubjectRef(c.GetString("orgID"), q))
+	h.service.RecordAuditLog(c, "data_subject.search", "data_subject", sum.SubjectRef, "success", "")
+	JSONSuccess(c, sum)
+}
+
+// @Summary 导出数据主体数据
+// @Description 返回 zip 导出包：subject.json、images/、videos/、manifest.json 与 manifest.sig（JWT，可用 JWKS 或回执验证接口验证）
+// @Tags Organization
+// @Accept json
+// @Produce application/zip
+// @Param request body service.DataSubjectQuery true "查找条件，至少一项"
+// @Router /api/v1/orgs/data-subjects/export [post]
+func (h *OrganizationHandler) ExportDataSubject(c *gin.Context) {
+	q, recs, ok := h.dataSubjectQuery(c)
+	if !ok {
+		return
+	}
+	orgID := c.GetString("orgID")
+	subjectRef := h.service.DataSubjectRef(orgID, q)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="data-subject-`+subjectRef[:16]+`.zip"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	exportID, err := h.service.ExportDataSubject(c.Request.Context(), c.Writer, orgID, c.GetString("userID"), q, recs)
+	if err != nil {
+		// 响应已开始输出，只能中断并记录
+		h.service.RecordAuditLog(c, "data_subject.export", "data_subject", subjectRef, "failed", err.Error())
+		c.Abort()
+		return
+	}
+	h.service.RecordAuditLog(c, "data_subject.export", "data_subject", subjectRef, "success", exportID)
+}
+
+// @Summary 删除数据主体数据
+// @Description 删除该数据主体在本组织内的KYC请求、图片、视频、异步任务、人脸搜索引用、动作活体会话与请求日志，返回列出各项ID的签名删除回执
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body service.DataSubjectQuery true "查找条件，至少一项"
+// @Success 200 {object} service.ErasureReceipt
+// @Router /api/v1/orgs/data-subjects/erase [post]
+func (h *OrganizationHandler) EraseDataSubject(c *gin.Context) {
+	q, _, ok := h.dataSubjectQuery(c)
+	if !ok {
+		return
+	}
+	orgID := c.GetString("orgID")
+	subjectRef := h.service.DataSubjectRef(orgID, q)
+	receipt, err := h.service.EraseDataSubject(c.Request.Context(), orgID, c.GetString("userID"), q)
+	if err != nil {
+		h.service.RecordAuditLog(c, "data_subject.erase", "data_subject", subjectRef, "failed", err.Error())
+		JSONError(c, CodeBusinessError, "删除数据主体数据失败，可重试")
+		return
+	}
+	h.service.RecordAuditLog(c, "data_subject.erase", "data_subject", subjectRef, "success", receipt.ReceiptID)
+	JSONSuccess(c, receipt)
+}
+
+// @Summary 验证删除回执或导出签名
+// @Description 验证删除回执 token 或导出包中的 manifest.sig 是否由本服务为本组织签发
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body object true "{\"token\": \"...\"}"
+// @Success 200 {object} service.ReceiptVerification
+// @Router /api/v1/orgs/data-subjects/receipts/verify [post]
+func (h *OrganizationHandler) VerifyDataSubjectReceipt(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数错误")
+		return
+	}
+	res, err := h.service.VerifyDataSubjectToken(c.Request.Context(), c.GetString("orgID"), req.Token)
+	if err != nil {
+		JSONSuccess(c, &service.ReceiptVerification{})
+		return
+	}
+	JSONSuccess(c, res)
+}
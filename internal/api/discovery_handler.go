@@ -1,27 +1,43 @@
 package api
 
 import (
+	"kyc-service/internal/service"
+
 	"github.com/gin-gonic/gin"
 )
 
-type DiscoveryHandler struct{}
+type DiscoveryHandler struct {
+	service *service.KYCService
+}
+
+func NewDiscoveryHandler(svc *service.KYCService) *DiscoveryHandler {
+	return &DiscoveryHandler{service: svc}
+}
 
-func NewDiscoveryHandler() *DiscoveryHandler { return &DiscoveryHandler{} }
+// discoveryIssuer 签发者标识，也是客户端断言可使用的 aud
+const discoveryIssuer = "kyc-service"
 
 func (h *DiscoveryHandler) WellKnown(c *gin.Context) {
 	base := "/api/v1"
 	c.JSON(200, gin.H{
-		"issuer":                   "kyc-service",
-		"token_endpoint":           base + "/oauth/token",
-		"revocation_endpoint":      base + "/oauth/revoke",
-		"introspection_endpoint":   base + "/oauth/introspect",
-		"jwks_uri":                 "/jwks.json",
-		"grant_types_supported":    []string{"client_credentials"},
-		"response_types_supported": []string{"token"},
-		"scopes_supported":         []string{"ocr:read", "face:read", "liveness:read", "kyc:verify"},
+		"issuer":                                           discoveryIssuer,
+		"authorization_endpoint":                           base + "/oauth/authorize",
+		"token_endpoint":                                   base + "/oauth/token",
+		"revocation_endpoint":                              base + "/oauth/revoke",
+		"introspection_endpoint":                           base + "/oauth/introspect",
+		"jwks_uri":                                         "/jwks.json",
+		"grant_types_supported":                            []string{"client_credentials", "authorization_code", "refresh_token"},
+		"response_types_supported":                         []string{"code"},
+		"code_challenge_methods_supported":                 []string{"S256"},
+		"token_endpoint_auth_methods_supported":            []string{"client_secret_post", "private_key_jwt", "none"},
+		"token_endpoint_auth_signing_alg_values_supported": []string{"RS256", "ES256"},
+		"scopes_supported":                                 []string{"ocr:read", "face:read", "liveness:read", "kyc:verify"},
 	})
 }
 
+// JWKS 发布当前可验签的公钥（含提前发布的下一把密钥），下游按 kid 选择公钥验签
 func (h *DiscoveryHandler) JWKS(c *gin.Context) {
-	c.JSON(200, gin.H{"keys": []interface{}{}})
+	// 缓存时间应小于 jwt_signing.publish_ahead，保证新密钥生效前下游已拿到
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, h.service.JWKS())
 }
@@ -27,7 +27,7 @@ func (h *FaceImageHandler) GetImage(c *gin.Context) {
 	}
 
 	//name := path.Base(ref.SafeFilename)
-	name, err := subpathAfterData(ref.SafeFilename)
+	name, err := subpathAfterData(h.service.Config.Storage.FaceDataDir, ref.SafeFilename)
 	if err != nil {
 		JSONError(c, CodeNotFound, "图片获取错误")
 		return
@@ -38,8 +38,8 @@ func (h *FaceImageHandler) GetImage(c *gin.Context) {
 	c.Status(200)
 }
 
-func subpathAfterData(fullPath string) (string, error) {
-	base := "/root/test/vrlFaceServer/vrlFace/data"
+// subpathAfterData 人脸库文件相对 storage.face_data_dir 的路径
+func subpathAfterData(base, fullPath string) (string, error) {
 	full := filepath.Clean(fullPath)
 	baseClean := filepath.Clean(base)
 	rel, err := filepath.Rel(baseClean, full)
@@ -34,7 +34,8 @@ func NewGoogleOAuthHandler(svc *service.KYCService) *GoogleOAuthHandler {
 
 // GoogleTokenRequest Google令牌请求
 type GoogleTokenRequest struct {
-	IDToken string `json:"id_token" binding:"required"`
+	IDToken    string `json:"id_token" binding:"required"`
+	DeviceName string `json:"device_name"`
 }
 
 // GoogleUserInfo Google用户信息
@@ -113,6 +114,18 @@ func (h *GoogleOAuthHandler) GoogleLogin(c *gin.Context) {
 		metrics.RecordBusinessOperation(c.Request.Context(), "google_oauth", true, time.Since(start), "user_login")
 	}
 
+	// 所在组织要求通过企业 SSO 登录
+	if h.service.SSORequiredFor(user.OrgID, user.OrgRole) {
+		metrics.RecordBusinessOperation(c.Request.Context(), "google_oauth", false, time.Since(start), "sso_required")
+		JSONError(c, CodeForbidden, "所在组织要求通过企业 SSO 登录")
+		return
+	}
+
+	// 已启用第二因素的账号需先完成MFA验证
+	if startMFAChallenge(c, h.service, &user, []string{service.AMRFederated}, "") {
+		return
+	}
+
 	// 获取组织信息
 	var org models.Organization
 	if err := h.service.DB.First(&org, "id = ?", user.OrgID).Error; err != nil {
@@ -122,8 +135,19 @@ func (h *GoogleOAuthHandler) GoogleLogin(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT令牌
-	accessToken, err := h.generateUserJWT(&user, &org)
+	// 创建服务端会话并签发访问令牌
+	sess, refreshToken, err := h.service.CreateConsoleSession(user.ID, service.SessionAuth{AMR: []string{service.AMRFederated}}, service.SessionClient{
+		DeviceName: req.DeviceName,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	})
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("创建会话失败")
+		metrics.RecordBusinessOperation(c.Request.Context(), "google_oauth", false, time.Since(start), "session_create_failed")
+		JSONError(c, CodeDatabaseError, "系统错误")
+		return
+	}
+	accessToken, err := h.generateUserJWT(&user, &org, sess)
 	if err != nil {
 		logger.GetLogger().WithError(err).Error("生成JWT失败")
 		metrics.RecordBusinessOperation(c.Request.Context(), "google_oauth", false, time.Since(start), "jwt_generation_failed")
@@ -157,7 +181,11 @@ func (h *GoogleOAuthHandler) GoogleLogin(c *gin.Context) {
 
 	// 返回用户信息
 	JSONSuccess(c, ConsoleLoginResponse{
-		AccessToken: accessToken,
+		AccessToken:           accessToken,
+		RefreshToken:          refreshToken,
+		ExpiresIn:             int(h.service.SessionAccessTTL().Seconds()),
+		SessionID:             sess.ID,
+		MFAEnrollmentRequired: h.service.MFARequired(&user, user.OrgID),
 		User: &ConsoleUserProfile{
 			ID:        user.ID,
 			Email:     user.Email,
@@ -314,8 +342,8 @@ func (h *GoogleOAuthHandler) createUserFromGoogle(googleUser *GoogleUserInfo) (m
 	return user, nil
 }
 
-// generateUserJWT 生成用户JWT令牌
-func (h *GoogleOAuthHandler) generateUserJWT(user *models.User, org *models.Organization) (string, error) {
+// generateUserJWT 为会话签发短期访问令牌
+func (h *GoogleOAuthHandler) generateUserJWT(user *models.User, org *models.Organization, sess *models.ConsoleSession) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":  user.ID,
 		"email":    user.Email,
@@ -323,10 +351,10 @@ func (h *GoogleOAuthHandler) generateUserJWT(user *models.User, org *models.Orga
 		"org_id":   user.OrgID,
 		"org_role": user.OrgRole,
 		"plan_id":  org.PlanID,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
+		"sid":      sess.ID,
+		"exp":      time.Now().Add(h.service.SessionAccessTTL()).Unix(),
 		"iat":      time.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.service.Config.Security.JWTSecret))
+	return h.service.SignJWT(service.SessionAuthClaims(claims, service.SessionAMR(sess), time.Time{}))
 }
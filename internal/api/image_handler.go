@@ -1,10 +1,17 @@
 package api
 
 import (
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"kyc-service/internal/models"
 	"kyc-service/internal/service"
-	"net/http"
+	"kyc-service/pkg/blobstore"
 )
 
 type ImageHandler struct{ service *service.KYCService }
@@ -29,21 +36,113 @@ func (h *ImageHandler) Upload(c *gin.Context) {
 		"id":            asset.ID,
 		"hash":          asset.Hash,
 		"path":          asset.FilePath,
+		"storage_key":   asset.StorageKey,
 		"safe_filename": asset.SafeFilename,
 		"thumb_url":     "",
 		"image_url":     "/api/v1/images/" + asset.ID + "/image",
 	})
 }
 
-func (h *ImageHandler) GetImage(c *gin.Context) {
+func (h *ImageHandler) findAsset(c *gin.Context) (*models.ImageAsset, bool) {
 	orgID := c.GetString("orgID")
 	id := c.Param("id")
 	var asset models.ImageAsset
 	if err := h.service.DB.First(&asset, "id = ? AND organization_id = ?", id, orgID).Error; err != nil {
 		JSONError(c, CodeNotFound, "图片不存在")
+		return nil, false
+	}
+	return &asset, true
+}
+
+// GetImage 加密存储的图片解密后由服务输出；未加密时 s3 后端跳转到预签名地址，
+// local 后端配置了 accel_redirect_prefix 时交由 nginx，否则直接输出
+func (h *ImageHandler) GetImage(c *gin.Context) {
+	asset, ok := h.findAsset(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	plain := asset.DataKeyID == "" && !h.service.BlobEncryptionEnabled()
+	if plain && h.service.Blobs.Backend() != blobstore.BackendLocal {
+		u, _, err := h.service.AssetDownloadURL(ctx, asset.StorageKey, asset.FilePath)
+		if err != nil {
+			JSONError(c, CodeNotFound, "图片获取错误")
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, u)
 		return
 	}
-	c.Header("Content-Type", "image/jpeg")
-	c.Header("X-Accel-Redirect", "/_protected/images/"+asset.SafeFilename)
-	c.Status(http.StatusOK)
+	ct := asset.ContentType
+	if ct == "" {
+		ct = "image/jpeg"
+	}
+	if prefix := h.service.Config.Storage.AccelRedirectPrefix; plain && prefix != "" {
+		key := asset.StorageKey
+		if key == "" {
+			key = asset.SafeFilename
+		}
+		c.Header("Content-Type", ct)
+		c.Header("X-Accel-Redirect", strings.TrimRight(prefix, "/")+"/"+key)
+		c.Status(http.StatusOK)
+		return
+	}
+	rc, err := h.service.OpenAsset(ctx, asset.StorageKey, asset.FilePath, asset.DataKeyID)
+	if errors.Is(err, service.ErrDataKeyDestroyed) {
+		JSONError(c, CodeNotFound, "图片已销毁")
+		return
+	}
+	if err != nil {
+		JSONError(c, CodeNotFound, "图片获取错误")
+		return
+	}
+	defer rc.Close()
+	c.Header("Cache-Control", "private, max-age=60")
+	c.DataFromReader(http.StatusOK, asset.SizeBytes, ct, rc, nil)
+}
+
+// GetImageURL 返回图片的短期签名下载地址
+func (h *ImageHandler) GetImageURL(c *gin.Context) {
+	asset, ok := h.findAsset(c)
+	if !ok {
+		return
+	}
+	u, expiresAt, err := h.service.AssetDownloadURL(c.Request.Context(), asset.StorageKey, asset.FilePath)
+	if err != nil {
+		JSONError(c, CodeBusinessError, "生成下载地址失败")
+		return
+	}
+	JSONSuccess(c, gin.H{"url": u, "expires_at": expiresAt.Unix()})
+}
+
+// ServeSignedBlob 签名下载地址，校验 expires 与 signature 后输出（解密后的）文件
+func (h *ImageHandler) ServeSignedBlob(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	err := h.service.BlobURLSigner().Verify(key, c.Query("expires"), c.Query("signature"), time.Now())
+	if errors.Is(err, blobstore.ErrURLExpired) {
+		JSONError(c, CodeForbidden, "下载地址已过期")
+		return
+	}
+	if err != nil {
+		JSONError(c, CodeForbidden, "下载地址无效")
+		return
+	}
+	ctx := c.Request.Context()
+	dataKeyID, err := h.service.BlobDataKeyID(ctx, key)
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "资源获取错误")
+		return
+	}
+	rc, _, err := h.service.OpenBlob(ctx, key, dataKeyID)
+	if err != nil {
+		JSONError(c, CodeNotFound, "资源不存在")
+		return
+	}
+	defer rc.Close()
+	ct := mime.TypeByExtension(path.Ext(key))
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.DataFromReader(http.StatusOK, -1, ct, rc, nil)
 }
@@ -29,7 +29,7 @@ func NewKYCHandler(svc *service.KYCService) *KYCHandler {
 // @Tags OCR
 // @Accept multipart/form-data
 // @Produce json
-// @Param picture formData file true "ID card image"
+// @Param picture formData file true "ID card image (JPEG/PNG/GIF/WebP/BMP/TIFF; HEIC is not supported)"
 // @Param language formData string false "Language default:thai" Enums(thai,vietnamese,indonesian,chinese,english,tagalog,malay) example(thai)
 // @Success 200 {object} OCRSuccessResponse
 // @Router /kyc/ocr [post]
@@ -73,6 +73,10 @@ func (h *KYCHandler) OCR(c *gin.Context) {
 			JSONErrorWithStatus(c, CodeThirdPartyError, err.Error(), http.StatusBadGateway)
 			return
 		}
+		if errors.Is(err, service.ErrImageRejected) {
+			JSONError(c, CodeInvalidParameter, err.Error())
+			return
+		}
 		JSONError(c, CodeOCRFailed, err.Error())
 		return
 	}
@@ -131,7 +135,7 @@ func GetSupportLang(lang string) string {
 // @Tags Public
 // @Accept multipart/form-data
 // @Produce json
-// @Param picture formData file true "Face image"
+// @Param picture formData file true "Face image (JPEG/PNG/GIF/WebP/BMP/TIFF; HEIC is not supported)"
 // @Success 200 {object} FaceSearchSuccessResponse
 // @Router /kyc/face/search [post]
 // @Security ApiKeyAuth
@@ -153,6 +157,10 @@ func (h *KYCHandler) FaceSearch(c *gin.Context) {
 			JSONErrorWithStatus(c, CodeThirdPartyError, e.Error(), http.StatusBadGateway)
 			return
 		}
+		if errors.Is(e, service.ErrImageRejected) {
+			JSONError(c, CodeInvalidParameter, e.Error())
+			return
+		}
 		JSONError(c, CodeBusinessError, e.Error())
 		return
 	}
@@ -165,8 +173,8 @@ func (h *KYCHandler) FaceSearch(c *gin.Context) {
 // @Tags Public
 // @Accept multipart/form-data
 // @Produce json
-// @Param source_image formData file true "Source image"
-// @Param target_image formData file true "Target image"
+// @Param source_image formData file true "Source image (JPEG/PNG/GIF/WebP/BMP/TIFF; HEIC is not supported)"
+// @Param target_image formData file true "Target image (JPEG/PNG/GIF/WebP/BMP/TIFF; HEIC is not supported)"
 // @Success 200 {object} FaceCompareSuccessResponse
 // @Router /kyc/face/compare [post]
 // @Security ApiKeyAuth
@@ -189,6 +197,10 @@ func (h *KYCHandler) FaceCompare(c *gin.Context) {
 			JSONErrorWithStatus(c, CodeThirdPartyError, e.Error(), http.StatusBadGateway)
 			return
 		}
+		if errors.Is(e, service.ErrImageRejected) {
+			JSONError(c, CodeInvalidParameter, e.Error())
+			return
+		}
 		JSONError(c, CodeBusinessError, e.Error())
 		return
 	}
@@ -201,7 +213,7 @@ func (h *KYCHandler) FaceCompare(c *gin.Context) {
 // @Tags Public
 // @Accept multipart/form-data
 // @Produce json
-// @Param picture formData file true "Face image"
+// @Param picture formData file true "Face image (JPEG/PNG/GIF/WebP/BMP/TIFF; HEIC is not supported)"
 // @Success 200 {object} FaceDetectSuccessResponse
 // @Router /kyc/face/detect [post]
 // @Security ApiKeyAuth
@@ -223,6 +235,10 @@ func (h *KYCHandler) FaceDetect(c *gin.Context) {
 			JSONErrorWithStatus(c, CodeThirdPartyError, e.Error(), http.StatusBadGateway)
 			return
 		}
+		if errors.Is(e, service.ErrImageRejected) {
+			JSONError(c, CodeInvalidParameter, e.Error())
+			return
+		}
 		JSONError(c, CodeBusinessError, e.Error())
 		return
 	}
@@ -235,7 +251,7 @@ func (h *KYCHandler) FaceDetect(c *gin.Context) {
 // @Tags Public
 // @Accept multipart/form-data
 // @Produce json
-// @Param picture formData file true "Image"
+// @Param picture formData file true "Image (JPEG/PNG/GIF/WebP/BMP/TIFF; HEIC is not supported)"
 // @Param language formData string false "Language" Enums(zh,zh-CN,en,en-US) example(zh-CN)
 // @Success 200 {object} LivenessSilentSuccessResponse
 // @Router /kyc/liveness/silent [post]
@@ -259,6 +275,10 @@ func (h *KYCHandler) LivenessSilent(c *gin.Context) {
 			JSONErrorWithStatus(c, CodeThirdPartyError, e.Error(), http.StatusBadGateway)
 			return
 		}
+		if errors.Is(e, service.ErrImageRejected) {
+			JSONError(c, CodeInvalidParameter, e.Error())
+			return
+		}
 		JSONError(c, CodeBusinessError, e.Error())
 		return
 	}
@@ -326,11 +346,12 @@ func (h *KYCHandler) LivenessWebSocket(c *gin.Context) {
 // @Tags KYC
 // @Accept multipart/form-data
 // @Produce json
-// @Param idcard_image formData file true "ID card image"
-// @Param face_image formData file true "Face image"
+// @Param idcard_image formData file true "ID card image (JPEG/PNG/GIF/WebP/BMP/TIFF; HEIC is not supported)"
+// @Param face_image formData file true "Face image (JPEG/PNG/GIF/WebP/BMP/TIFF; HEIC is not supported)"
 // @Param name formData string true "Name"
 // @Param idcard formData string true "ID card number"
 // @Param phone formData string false "Phone number"
+// @Param async query bool false "Queue the job and return request_id immediately"
 // @Success 200 {object} SuccessResponse
 // @Router /kyc/verify [post]
 // @Security ApiKeyAuth
@@ -340,12 +361,16 @@ func (h *KYCHandler) CompleteKYC(c *gin.Context) {
 		JSONError(c, CodeInvalidParameter, "Invalid request body")
 		return
 	}
+	if c.Query("async") == "true" {
+		req.Async = true
+	}
 
 	result, err := h.service.CompleteKYC(c.Request.Context(), &req)
 	if err != nil {
 		JSONError(c, CodeKYCFailed, err.Error())
 		return
 	}
+	setKYCRequestID(c, result.RequestID)
 
 	JSONSuccess(c, result)
 }
@@ -365,6 +390,7 @@ func (h *KYCHandler) GetKYCStatus(c *gin.Context) { //ignore_security_alert IDOR
 		JSONError(c, CodeMissingParameter, "请求ID不能为空")
 		return
 	}
+	setKYCRequestID(c, requestID)
 
 	result, err := h.service.GetKYCStatus(c.Request.Context(), requestID)
 	if err != nil {
@@ -374,3 +400,10 @@ func (h *KYCHandler) GetKYCStatus(c *gin.Context) { //ignore_security_alert IDOR
 
 	JSONSuccess(c, result)
 }
+
+// setKYCRequestID 标记本次调用涉及的KYC请求，请求日志据此关联到数据主体
+func setKYCRequestID(c *gin.Context, id string) {
+	if id != "" {
+		c.Set("kycRequestID", id)
+	}
+}
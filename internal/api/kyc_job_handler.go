@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+
+	"kyc-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetKYCJob
+// @Summary Get async KYC job
+// @Description Get the status of an async KYC job (request_id returned by /kyc/verify?async=true)
+// @Tags KYC
+// @Produce json
+// @Param id path string true "Request ID"
+// @Success 200 {object} SuccessResponse
+// @Router /kyc/jobs/{id} [get]
+// @Security ApiKeyAuth
+func (h *KYCHandler) GetKYCJob(c *gin.Context) {
+	setKYCRequestID(c, c.Param("id"))
+	job, err := h.service.GetKYCJob(c.GetString("orgID"), c.Param("id"))
+	if err != nil {
+		writeKYCJobError(c, err)
+		return
+	}
+	JSONSuccess(c, job)
+}
+
+// CancelKYCJob
+// @Summary Cancel async KYC job
+// @Description Cancel a pending or processing async KYC job
+// @Tags KYC
+// @Produce json
+// @Param id path string true "Request ID"
+// @Success 200 {object} SuccessResponse
+// @Router /kyc/jobs/{id}/cancel [post]
+// @Security ApiKeyAuth
+func (h *KYCHandler) CancelKYCJob(c *gin.Context) {
+	setKYCRequestID(c, c.Param("id"))
+	job, err := h.service.CancelKYCJob(c.Request.Context(), c.GetString("orgID"), c.Param("id"))
+	if err != nil {
+		writeKYCJobError(c, err)
+		return
+	}
+	JSONSuccess(c, job)
+}
+
+// RetryKYCJob
+// @Summary Retry async KYC job
+// @Description Requeue a failed or cancelled async KYC job; completed steps are not repeated
+// @Tags KYC
+// @Produce json
+// @Param id path string true "Request ID"
+// @Success 200 {object} SuccessResponse
+// @Router /kyc/jobs/{id}/retry [post]
+// @Security ApiKeyAuth
+func (h *KYCHandler) RetryKYCJob(c *gin.Context) {
+	setKYCRequestID(c, c.Param("id"))
+	job, err := h.service.RetryKYCJob(c.Request.Context(), c.GetString("orgID"), c.Param("id"))
+	if err != nil {
+		writeKYCJobError(c, err)
+		return
+	}
+	JSONSuccess(c, job)
+}
+
+func writeKYCJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrKYCJobNotFound):
+		JSONError(c, CodeNotFound, "任务不存在")
+	case errors.Is(err, service.ErrKYCJobStopping):
+		JSONError(c, CodeConflict, "任务正在停止，请稍后重试")
+	case errors.Is(err, service.ErrKYCJobInvalidState):
+		JSONError(c, CodeConflict, "当前任务状态不允许该操作")
+	default:
+		JSONError(c, CodeDatabaseError, err.Error())
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 获取KYC判定规则
+// @Description 获取当前组织完整KYC的阈值与规则（未配置时返回默认值）
+// @Tags Organization
+// @Produce json
+// @Success 200 {object} service.KYCDecisionRules
+// @Router /api/v1/orgs/kyc-rules [get]
+func (h *OrganizationHandler) GetKYCRules(c *gin.Context) {
+	JSONSuccess(c, h.service.GetKYCDecisionRules(c.GetString("orgID")))
+}
+
+// @Summary 更新KYC判定规则
+// @Description 更新当前组织完整KYC的阈值与规则，未提供的字段保持原值
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body service.KYCDecisionRules true "规则"
+// @Success 200 {object} service.KYCDecisionRules
+// @Router /api/v1/orgs/kyc-rules [put]
+func (h *OrganizationHandler) UpdateKYCRules(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	rules := h.service.GetKYCDecisionRules(orgID)
+	raw, err := c.GetRawData()
+	if err != nil || json.Unmarshal(raw, &rules) != nil {
+		JSONError(c, CodeInvalidParameter, "Invalid request body")
+		return
+	}
+	if err := rules.Validate(); err != nil {
+		JSONError(c, CodeInvalidParameter, err.Error())
+		return
+	}
+	if err := h.service.SaveKYCDecisionRules(orgID, rules); err != nil {
+		JSONError(c, CodeDatabaseError, "保存规则失败")
+		return
+	}
+	b, _ := json.Marshal(rules)
+	h.service.RecordAuditLog(c, "org.kyc_rules.update", "organization", orgID, "success", string(b))
+	JSONSuccess(c, rules)
+}
@@ -1,51 +1,73 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
+	"kyc-service/internal/service"
+
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-// Action Liveness (MVP): only used to unblock Playground/QA Stage1.
-// It returns a session_id and echoes trace info.
+// Action Liveness: 会话下发随机动作序列，客户端按顺序逐个上传动作视频，最后统一校验。
+
+type ActionLivenessSessionRequest struct {
+	Language string `json:"language"`
+}
 
-type ActionLivenessSessionResponse struct {
-	SessionID string `json:"session_id"`
-	// Trace/request id is put here for client debug convenience.
-	RequestID string `json:"request_id,omitempty"`
+type ActionLivenessVerifyRequest struct {
+	SessionID string `json:"session_id" binding:"required"`
 }
 
-// @Summary Action liveness create session (MVP)
-// @Description Create an action-liveness session. (Placeholder implementation)
+// @Summary Action liveness create session
+// @Description Create an action-liveness session with a randomized challenge sequence (blink/nod/smile)
 // @Tags KYC
 // @Accept json
 // @Produce json
-// @Success 200 {object} ActionLivenessSessionResponse
+// @Param request body ActionLivenessSessionRequest false "Session options"
+// @Success 200 {object} service.ActionLivenessSession
 // @Router /kyc/liveness/action/session [post]
 // @Security ApiKeyAuth
 func (h *KYCHandler) LivenessActionSession(c *gin.Context) {
-	// NOTE: keep it simple: generate a UUID session id.
-	// request/trace id: middleware.TraceMiddleware should set header "X-Request-ID".
-	reqID := c.GetHeader("X-Request-ID")
-	if reqID == "" {
-		reqID = c.GetHeader("X-Trace-Id")
+	var req ActionLivenessSessionRequest
+	_ = c.ShouldBindJSON(&req)
+	sess, err := h.service.CreateActionLivenessSession(actionLivenessContext(c), req.Language)
+	if err != nil {
+		writeActionLivenessError(c, err)
+		return
 	}
+	setKYCRequestID(c, sess.ID)
+	JSONSuccess(c, sess)
+}
 
-	c.JSON(http.StatusOK, ActionLivenessSessionResponse{
-		SessionID: uuid.New().String(),
-		RequestID: reqID,
-	})
+// @Summary Action liveness session status
+// @Description Get an action-liveness session and its per-action results
+// @Tags KYC
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} service.ActionLivenessSession
+// @Router /kyc/liveness/action/session/{id} [get]
+// @Security ApiKeyAuth
+func (h *KYCHandler) GetLivenessActionSession(c *gin.Context) {
+	setKYCRequestID(c, c.Param("id"))
+	sess, err := h.service.GetActionLivenessSession(c.Request.Context(), c.GetString("orgID"), c.Param("id"))
+	if err != nil {
+		writeActionLivenessError(c, err)
+		return
+	}
+	JSONSuccess(c, sess)
 }
 
-// @Summary Action liveness upload (MVP)
-// @Description Upload media for action-liveness session. (Placeholder implementation)
+// @Summary Action liveness upload
+// @Description Upload the video for the next pending action of a session. The liveness vendor must confirm the requested action was performed; clips it cannot verify fail with fail_reason action_unverified or action_mismatch
 // @Tags KYC
 // @Accept multipart/form-data
 // @Produce json
 // @Param session_id formData string true "Session ID"
-// @Param video formData file false "Video"
-// @Success 200 {object} map[string]any
+// @Param action formData string false "Action (defaults to next pending action)"
+// @Param video formData file true "Video"
+// @Success 200 {object} service.ActionLivenessSession
 // @Router /kyc/liveness/action/upload [post]
 // @Security ApiKeyAuth
 func (h *KYCHandler) LivenessActionUpload(c *gin.Context) {
@@ -54,25 +76,68 @@ func (h *KYCHandler) LivenessActionUpload(c *gin.Context) {
 		JSONError(c, CodeMissingParameter, "Missing session_id")
 		return
 	}
-	JSONSuccess(c, gin.H{"session_id": sid, "uploaded": true})
+	setKYCRequestID(c, sid)
+	file, err := c.FormFile("video")
+	if err != nil {
+		JSONError(c, CodeInvalidParameter, "Missing video")
+		return
+	}
+	sess, err := h.service.UploadActionLiveness(actionLivenessContext(c), sid, c.PostForm("action"), file)
+	if err != nil {
+		writeActionLivenessError(c, err)
+		return
+	}
+	JSONSuccess(c, sess)
 }
 
-// @Summary Action liveness verify (MVP)
-// @Description Verify action-liveness session. (Placeholder implementation)
+// @Summary Action liveness verify
+// @Description Verify an action-liveness session; each session can only be verified once
 // @Tags KYC
 // @Accept json
 // @Produce json
-// @Success 200 {object} map[string]any
+// @Param request body ActionLivenessVerifyRequest true "Session"
+// @Success 200 {object} service.ActionLivenessSession
 // @Router /kyc/liveness/action/verify [post]
 // @Security ApiKeyAuth
 func (h *KYCHandler) LivenessActionVerify(c *gin.Context) {
-	var body struct {
-		SessionID string `json:"session_id"`
-	}
-	_ = c.ShouldBindJSON(&body)
-	if body.SessionID == "" {
+	var body ActionLivenessVerifyRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
 		JSONError(c, CodeMissingParameter, "Missing session_id")
 		return
 	}
-	JSONSuccess(c, gin.H{"session_id": body.SessionID, "is_live": true, "score": 1.0})
+	setKYCRequestID(c, body.SessionID)
+	sess, err := h.service.VerifyActionLiveness(actionLivenessContext(c), body.SessionID)
+	if err != nil {
+		writeActionLivenessError(c, err)
+		return
+	}
+	JSONSuccess(c, sess)
+}
+
+func actionLivenessContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "org_id", c.GetString("orgID"))
+	if uid := c.GetString("userID"); uid != "" {
+		ctx = context.WithValue(ctx, "user_id", uid)
+	}
+	return ctx
+}
+
+func writeActionLivenessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrActionSessionNotFound):
+		JSONError(c, CodeNotFound, err.Error())
+	case errors.Is(err, service.ErrActionSessionExpired):
+		JSONErrorWithStatus(c, CodeBusinessError, err.Error(), http.StatusGone)
+	case errors.Is(err, service.ErrActionSessionVerified):
+		JSONError(c, CodeConflict, err.Error())
+	case errors.Is(err, service.ErrActionSessionPending), errors.Is(err, service.ErrActionMismatch):
+		JSONError(c, CodeInvalidParameter, err.Error())
+	case errors.Is(err, service.ErrUpstreamTimeout):
+		JSONErrorWithStatus(c, CodeThirdPartyError, err.Error(), http.StatusGatewayTimeout)
+	case errors.Is(err, service.ErrUpstreamUnavailable):
+		JSONErrorWithStatus(c, CodeThirdPartyError, err.Error(), http.StatusBadGateway)
+	default:
+		JSONError(c, CodeBusinessError, err.Error())
+	}
 }
@@ -27,8 +27,9 @@ type RoleWithPerms struct {
 }
 
 func (h *MetaHandler) GetRoles(c *gin.Context) {
+	// 仅返回全局角色，组织自定义角色通过 /orgs/roles 查询
 	var roles []models.Role
-	if err := h.service.DB.Order("created_at ASC").Find(&roles).Error; err != nil {
+	if err := h.service.DB.Where("org_id = '' OR org_id IS NULL").Order("created_at ASC").Find(&roles).Error; err != nil {
 		JSONError(c, CodeDatabaseError, "查询失败")
 		return
 	}
@@ -72,6 +73,7 @@ func (h *MetaHandler) CreateRole(c *gin.Context) {
 		JSONError(c, CodeDatabaseError, "创建失败")
 		return
 	}
+	h.service.InvalidateRolePermissions(c.Request.Context(), id)
 	JSONSuccess(c, role)
 }
 
@@ -151,6 +153,7 @@ func (h *MetaHandler) UpdateRole(c *gin.Context) {
 				return
 			}
 		}
+		h.service.InvalidateRolePermissions(c.Request.Context(), id)
 	}
 	JSONSuccess(c, role)
 }
@@ -175,5 +178,6 @@ func (h *MetaHandler) DeleteRole(c *gin.Context) {
 		JSONError(c, CodeDatabaseError, "删除失败")
 		return
 	}
+	h.service.InvalidateRolePermissions(c.Request.Context(), id)
 	JSONSuccess(c, gin.H{"deleted": id})
 }
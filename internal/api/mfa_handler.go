@@ -0,0 +1,463 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/webauthn"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MFAChallengeResponse 登录第一步通过、等待第二因素时的响应
+type MFAChallengeResponse struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	Methods     []string `json:"methods"`
+	ExpiresAt   int64    `json:"expires_at"`
+}
+
+// MFAVerifyRequest 登录第二步请求
+type MFAVerifyRequest struct {
+	MFAToken   string `json:"mfa_token" binding:"required"`
+	DeviceName string `json:"device_name"`
+	service.MFAVerification
+}
+
+// MFATokenRequest 仅携带挑战令牌的请求
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// TOTPConfirmRequest 确认启用TOTP
+type TOTPConfirmRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// WebAuthnRegisterRequest 完成安全密钥注册
+type WebAuthnRegisterRequest struct {
+	Name       string                              `json:"name"`
+	Credential webauthn.CredentialCreationResponse `json:"credential" binding:"required"`
+}
+
+// startMFAChallenge 用户已启用第二因素时签发挑战令牌并写出响应，返回 true 表示调用方应直接返回；
+// ssoOrgID 非空表示第一因素为该组织的 SSO 登录，完成第二步后会话仍绑定该组织
+func startMFAChallenge(c *gin.Context, svc *service.KYCService, user *models.User, firstFactor []string, ssoOrgID string) bool {
+	st, err := svc.GetMFAStatus(user)
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("查询MFA状态失败")
+		JSONError(c, CodeDatabaseError, "系统错误")
+		return true
+	}
+	if !st.Enrolled() {
+		return false
+	}
+	token, exp, err := svc.IssueMFAChallenge(user, firstFactor, ssoOrgID)
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("签发MFA挑战令牌失败")
+		JSONError(c, CodeInternalError, "令牌生成失败")
+		return true
+	}
+	JSONSuccess(c, MFAChallengeResponse{MFARequired: true, MFAToken: token, Methods: st.Methods(), ExpiresAt: exp.Unix()})
+	return true
+}
+
+// mfaError 将MFA相关错误映射为接口错误码
+func mfaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrMFAInvalidCode), errors.Is(err, service.ErrMFAChallengeInvalid):
+		JSONError(c, CodeUnauthorized, "验证失败")
+	case errors.Is(err, service.ErrMFATooManyAttempts):
+		JSONError(c, CodeTooManyRequests, "尝试次数过多，请重新登录")
+	case errors.Is(err, service.ErrMFAAlreadyEnrolled):
+		JSONError(c, CodeConflict, "已启用该验证方式")
+	case errors.Is(err, service.ErrMFANotEnrolled), errors.Is(err, service.ErrWebAuthnDisabled),
+		errors.Is(err, service.ErrWebAuthnChallengeStale), errors.Is(err, service.ErrMFAUnsupportedMethod):
+		JSONError(c, CodeBadRequest, err.Error())
+	case errors.Is(err, service.ErrMFALastFactorRequired):
+		JSONError(c, CodeForbidden, "当前组织要求多因素认证，不能移除最后一种验证方式")
+	case errors.Is(err, service.ErrMFAEncryptionRequired):
+		JSONError(c, CodeEncryptionError, "未配置加密密钥")
+	case errors.Is(err, webauthn.ErrChallengeMismatch), errors.Is(err, webauthn.ErrOriginNotAllowed),
+		errors.Is(err, webauthn.ErrRPIDMismatch), errors.Is(err, webauthn.ErrUserNotPresent),
+		errors.Is(err, webauthn.ErrBadSignature):
+		JSONError(c, CodeBadRequest, err.Error())
+	default:
+		logger.GetLogger().WithError(err).Error("MFA操作失败")
+		JSONError(c, CodeInternalError, "系统错误")
+	}
+}
+
+// currentUser 加载当前会话用户
+func (h *ConsoleAuthHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := h.service.DB.First(&user, "id = ? AND status = ?", c.GetString("userID"), "active").Error; err != nil {
+		JSONError(c, CodeUnauthorized, "未授权访问")
+		return nil, false
+	}
+	return &user, true
+}
+
+// sessionAMR 当前会话的认证方式
+func sessionAMR(c *gin.Context) []string {
+	amr, _ := c.Get("amr")
+	out, _ := amr.([]string)
+	return out
+}
+
+// requireMFASessionIfEnrolled 已启用第二因素的用户修改MFA配置时，会话本身必须经过MFA
+func (h *ConsoleAuthHandler) requireMFASessionIfEnrolled(c *gin.Context, st *service.MFAStatus) bool {
+	if st.Enrolled() && !service.HasAMR(sessionAMR(c), service.AMRMFA) {
+		JSONError(c, CodeForbidden, "请先完成多因素验证")
+		return false
+	}
+	return true
+}
+
+// reissueSession 在当前会话基础上签发带新 amr 的令牌（会话记录同步更新，刷新后沿用），不延长访问令牌有效期
+func (h *ConsoleAuthHandler) reissueSession(c *gin.Context, amr []string, mfaAt time.Time) (string, error) {
+	current, _ := c.Get("user")
+	currentClaims, _ := current.(jwt.MapClaims)
+	claims := jwt.MapClaims{}
+	for k, v := range currentClaims {
+		claims[k] = v
+	}
+	delete(claims, "jti")
+	if err := h.service.UpdateConsoleSessionAuth(c.GetString("sessionID"), amr, mfaAt); err != nil {
+		return "", err
+	}
+	claims["iat"] = time.Now().Unix()
+	return h.service.SignJWT(service.SessionAuthClaims(claims, amr, mfaAt))
+}
+
+func (h *ConsoleAuthHandler) mfaAudit(c *gin.Context, userID, action, status, msg string) {
+	h.recordAuditLog(&models.AuditLog{
+		UserID:    userID,
+		OrgID:     c.GetString("orgID"),
+		Action:    action,
+		Resource:  "mfa",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Status:    status,
+		Message:   msg,
+	})
+}
+
+// VerifyMFA 登录第二步：校验第二因素并建立会话
+// @Summary 完成MFA登录
+// @Tags Console Auth
+// @Accept json
+// @Produce json
+// @Param request body MFAVerifyRequest true "MFA验证"
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/auth/mfa/verify [post]
+func (h *ConsoleAuthHandler) VerifyMFA(c *gin.Context) {
+	start := time.Now()
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	ctx := c.Request.Context()
+	ch, err := h.service.ParseMFAChallenge(ctx, req.MFAToken)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	var user models.User
+	if err := h.service.DB.First(&user, "id = ? AND status = ?", ch.UserID, "active").Error; err != nil {
+		JSONError(c, CodeUnauthorized, "用户不存在或已禁用")
+		return
+	}
+	// 第二步与密码登录一样按账号/IP递增延迟与锁定，避免换发挑战令牌绕过单个挑战的次数限制
+	if block, blocked := rejectBlockedAuth(c, h.service, service.AuthGuardMFA, user.Email); blocked {
+		h.mfaAudit(c, user.ID, "login_mfa_blocked", "failed", "Second factor blocked: "+block.Reason)
+		return
+	}
+	second, err := h.service.VerifyMFA(ctx, &user, &req.MFAVerification)
+	if err != nil {
+		h.mfaAudit(c, user.ID, "login_mfa", "failed", fmt.Sprintf("Second factor %s rejected", req.Method))
+		h.recordMFAFailure(c, &user, err)
+		mfaError(c, err)
+		return
+	}
+	if err := h.service.ConsumeMFAChallenge(ctx, ch); err != nil {
+		mfaError(c, err)
+		return
+	}
+	h.service.ClearAuthAttempts(ctx, service.AuthGuardMFA, user.Email)
+
+	auditLog := &models.AuditLog{
+		Action:    "login_attempt",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	h.completeLogin(c, &user, auditLog, service.SessionAuth{
+		AMR:      service.CompleteAMR(ch.FirstFactor, second),
+		MFAAt:    time.Now(),
+		SSOOrgID: ch.SSOOrgID,
+	}, req.DeviceName, start)
+}
+
+// recordMFAFailure 第二因素校验失败计入登录防护；配置或系统错误不计数。触发锁定时写审计并邮件通知
+func (h *ConsoleAuthHandler) recordMFAFailure(c *gin.Context, user *models.User, err error) {
+	if !errors.Is(err, service.ErrMFAInvalidCode) && !errors.Is(err, webauthn.ErrBadSignature) &&
+		!errors.Is(err, webauthn.ErrChallengeMismatch) && !errors.Is(err, service.ErrWebAuthnChallengeStale) {
+		return
+	}
+	res := recordAuthFailure(c, h.service, service.AuthGuardMFA, user.Email, "invalid_mfa")
+	if !res.AccountLocked && !res.IPLocked {
+		return
+	}
+	h.mfaAudit(c, user.ID, "mfa_locked", "locked",
+		fmt.Sprintf("Second factor locked for %s (email=%s, ip=%s) after repeated failures", res.LockedFor, user.Email, c.ClientIP()))
+	if res.AccountLocked {
+		h.service.NotifyAccountLocked(user.Email, c.ClientIP(), res.LockedFor)
+	}
+}
+
+// MFALoginWebAuthnChallenge 登录第二步使用安全密钥时获取断言参数
+// @Router /api/v1/auth/mfa/webauthn/challenge [post]
+func (h *ConsoleAuthHandler) MFALoginWebAuthnChallenge(c *gin.Context) {
+	var req MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	ch, err := h.service.ParseMFAChallenge(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	opts, err := h.service.BeginWebAuthnLogin(c.Request.Context(), ch.UserID)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	JSONSuccess(c, opts)
+}
+
+// GetMFAStatus 查询当前用户的MFA状态
+// @Router /api/v1/auth/mfa [get]
+func (h *ConsoleAuthHandler) GetMFAStatus(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	st, err := h.service.GetMFAStatus(user)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	JSONSuccess(c, gin.H{
+		"totp_enabled":         st.TOTPEnabled,
+		"webauthn_credentials": st.WebAuthn,
+		"webauthn_available":   st.WebAuthnAvailable,
+		"recovery_codes_left":  st.RecoveryCodesLeft,
+		"required":             h.service.MFARequired(user, c.GetString("orgID")),
+		"session_amr":          sessionAMR(c),
+	})
+}
+
+// EnrollTOTP 开始启用TOTP，返回密钥与 otpauth 链接
+// @Router /api/v1/auth/mfa/totp/enroll [post]
+func (h *ConsoleAuthHandler) EnrollTOTP(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	st, err := h.service.GetMFAStatus(user)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	if !h.requireMFASessionIfEnrolled(c, st) {
+		return
+	}
+	secret, uri, err := h.service.BeginTOTPEnrollment(user)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	JSONSuccess(c, gin.H{"secret": secret, "otpauth_uri": uri})
+}
+
+// ConfirmTOTP 用首个口令确认启用TOTP；返回恢复码（仅此一次）和已完成MFA的新令牌
+// @Router /api/v1/auth/mfa/totp/confirm [post]
+func (h *ConsoleAuthHandler) ConfirmTOTP(c *gin.Context) {
+	var req TOTPConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := h.service.ConfirmTOTPEnrollment(c.Request.Context(), user, req.Code)
+	if err != nil {
+		h.mfaAudit(c, user.ID, "mfa.totp.enable", "failed", err.Error())
+		mfaError(c, err)
+		return
+	}
+	token, err := h.reissueSession(c, service.CompleteAMR(sessionAMR(c), service.AMROTP), time.Now())
+	if err != nil {
+		JSONError(c, CodeInternalError, "令牌生成失败")
+		return
+	}
+	h.mfaAudit(c, user.ID, "mfa.totp.enable", "success", "TOTP enabled")
+	JSONSuccess(c, gin.H{"access_token": token, "recovery_codes": codes})
+}
+
+// DisableTOTP 停用TOTP（需近期完成MFA验证）
+// @Router /api/v1/auth/mfa/totp [delete]
+func (h *ConsoleAuthHandler) DisableTOTP(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DisableTOTP(user, c.GetString("orgID")); err != nil {
+		mfaError(c, err)
+		return
+	}
+	h.mfaAudit(c, user.ID, "mfa.totp.disable", "success", "TOTP disabled")
+	JSONSuccess(c, gin.H{"totp_enabled": false})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码（需近期完成MFA验证）
+// @Router /api/v1/auth/mfa/recovery-codes [post]
+func (h *ConsoleAuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := h.service.RegenerateRecoveryCodes(user)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	h.mfaAudit(c, user.ID, "mfa.recovery_codes.regenerate", "success", "Recovery codes regenerated")
+	JSONSuccess(c, gin.H{"recovery_codes": codes})
+}
+
+// BeginWebAuthnRegistration 获取安全密钥注册参数
+// @Router /api/v1/auth/mfa/webauthn/register/begin [post]
+func (h *ConsoleAuthHandler) BeginWebAuthnRegistration(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	st, err := h.service.GetMFAStatus(user)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	if !h.requireMFASessionIfEnrolled(c, st) {
+		return
+	}
+	opts, err := h.service.BeginWebAuthnRegistration(c.Request.Context(), user)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	JSONSuccess(c, opts)
+}
+
+// FinishWebAuthnRegistration 保存安全密钥；首次启用MFA时返回恢复码
+// @Router /api/v1/auth/mfa/webauthn/register/finish [post]
+func (h *ConsoleAuthHandler) FinishWebAuthnRegistration(c *gin.Context) {
+	var req WebAuthnRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	st, err := h.service.GetMFAStatus(user)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	if !h.requireMFASessionIfEnrolled(c, st) {
+		return
+	}
+	cred, codes, err := h.service.FinishWebAuthnRegistration(c.Request.Context(), user, req.Name, &req.Credential)
+	if err != nil {
+		h.mfaAudit(c, user.ID, "mfa.webauthn.register", "failed", err.Error())
+		mfaError(c, err)
+		return
+	}
+	token, err := h.reissueSession(c, service.CompleteAMR(sessionAMR(c), service.AMRHardwareKey), time.Now())
+	if err != nil {
+		JSONError(c, CodeInternalError, "令牌生成失败")
+		return
+	}
+	h.mfaAudit(c, user.ID, "mfa.webauthn.register", "success", fmt.Sprintf("Security key %s registered", cred.ID))
+	JSONSuccess(c, gin.H{"credential": cred, "recovery_codes": codes, "access_token": token})
+}
+
+// DeleteWebAuthnCredential 删除安全密钥（需近期完成MFA验证）
+// @Router /api/v1/auth/mfa/webauthn/{id} [delete]
+func (h *ConsoleAuthHandler) DeleteWebAuthnCredential(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	if err := h.service.DeleteWebAuthnCredential(user, c.GetString("orgID"), id); err != nil {
+		if errors.Is(err, service.ErrMFANotEnrolled) {
+			JSONError(c, CodeNotFound, "安全密钥不存在")
+			return
+		}
+		mfaError(c, err)
+		return
+	}
+	h.mfaAudit(c, user.ID, "mfa.webauthn.delete", "success", fmt.Sprintf("Security key %s removed", id))
+	JSONSuccess(c, gin.H{"deleted": true})
+}
+
+// StepUpWebAuthnChallenge 二次验证使用安全密钥时获取断言参数
+// @Router /api/v1/auth/mfa/step-up/webauthn/challenge [post]
+func (h *ConsoleAuthHandler) StepUpWebAuthnChallenge(c *gin.Context) {
+	opts, err := h.service.BeginWebAuthnLogin(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	JSONSuccess(c, opts)
+}
+
+// StepUp 敏感操作前重新验证第二因素，返回刷新了 mfa_at 的令牌
+// @Router /api/v1/auth/mfa/step-up [post]
+func (h *ConsoleAuthHandler) StepUp(c *gin.Context) {
+	var req service.MFAVerification
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	second, err := h.service.VerifyMFA(c.Request.Context(), user, &req)
+	if err != nil {
+		h.mfaAudit(c, user.ID, "mfa.step_up", "failed", fmt.Sprintf("Second factor %s rejected", req.Method))
+		mfaError(c, err)
+		return
+	}
+	token, err := h.reissueSession(c, service.CompleteAMR(sessionAMR(c), second), time.Now())
+	if err != nil {
+		JSONError(c, CodeInternalError, "令牌生成失败")
+		return
+	}
+	h.mfaAudit(c, user.ID, "mfa.step_up", "success", "Step-up authentication completed")
+	JSONSuccess(c, gin.H{"access_token": token, "expires_in": int(h.service.StepUpMaxAge().Seconds())})
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+
+	"kyc-service/internal/models"
+	"kyc-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthorizeRequest 授权请求（授权码模式，强制 PKCE S256）
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" form:"response_type" binding:"required"`
+	ClientID            string `json:"client_id" form:"client_id" binding:"required"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri" binding:"required"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge" binding:"required"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"`
+}
+
+// AuthorizeDecisionRequest 用户在同意页上的选择
+type AuthorizeDecisionRequest struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// AuthorizeConsentResponse 同意页展示信息
+type AuthorizeConsentResponse struct {
+	Client struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"client"`
+	OrgID       string   `json:"org_id"`
+	Scopes      []string `json:"scopes"`
+	RedirectURI string   `json:"redirect_uri"`
+	State       string   `json:"state,omitempty"`
+}
+
+// AuthorizeDecisionResponse 前端据此跳转回合作方应用
+type AuthorizeDecisionResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// @Summary 获取授权同意信息
+// @Description 校验授权请求（client_id、redirect_uri、scope、PKCE），返回同意页需要展示的应用与权限
+// @Tags Auth
+// @Produce json
+// @Param client_id query string true "客户端ID"
+// @Param redirect_uri query string true "回调地址（须与登记的地址一致）"
+// @Param response_type query string true "固定为 code"
+// @Param scope query string false "申请的权限，空格分隔"
+// @Param state query string false "原样回传的状态值"
+// @Param code_challenge query string true "PKCE challenge"
+// @Param code_challenge_method query string false "固定为 S256"
+// @Success 200 {object} AuthorizeConsentResponse
+// @Router /api/v1/oauth/authorize [get]
+func (h *AuthHandler) Authorize(c *gin.Context) {
+	var req AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "invalid_request")
+		return
+	}
+	client, scopes, ok := h.validateAuthorize(c, &req)
+	if !ok {
+		return
+	}
+	resp := AuthorizeConsentResponse{OrgID: c.GetString("orgID"), Scopes: scopes, RedirectURI: req.RedirectURI, State: req.State}
+	resp.Client.ID = client.ID
+	resp.Client.Name = client.Name
+	resp.Client.Description = client.Description
+	JSONSuccess(c, resp)
+}
+
+// @Summary 提交授权决定
+// @Description 用户同意后生成一次性授权码（10分钟有效），拒绝时返回 access_denied；均通过 redirect_to 回到合作方应用
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body AuthorizeDecisionRequest true "授权决定"
+// @Success 200 {object} AuthorizeDecisionResponse
+// @Router /api/v1/oauth/authorize [post]
+func (h *AuthHandler) AuthorizeDecision(c *gin.Context) {
+	var req AuthorizeDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "invalid_request")
+		return
+	}
+	client, scopes, ok := h.validateAuthorize(c, &req.AuthorizeRequest)
+	if !ok {
+		return
+	}
+	q := url.Values{}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	if !req.Approve {
+		q.Set("error", "access_denied")
+		h.service.RecordAuditLog(c, "oauth.authorize", "oauth", client.ID, "denied", "")
+		JSONSuccess(c, AuthorizeDecisionResponse{RedirectTo: appendQuery(req.RedirectURI, q)})
+		return
+	}
+	code, err := h.service.CreateAuthorizationCode(service.AuthorizationCodeRequest{
+		ClientID: client.ID, UserID: c.GetString("userID"), OrgID: c.GetString("orgID"), RedirectURI: req.RedirectURI,
+		Scope: strings.Join(scopes, " "), CodeChallenge: req.CodeChallenge, CodeChallengeMethod: req.CodeChallengeMethod,
+	})
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "生成授权码失败")
+		return
+	}
+	q.Set("code", code)
+	h.service.RecordAuditLog(c, "oauth.authorize", "oauth", client.ID, "success", strings.Join(scopes, " "))
+	JSONSuccess(c, AuthorizeDecisionResponse{RedirectTo: appendQuery(req.RedirectURI, q)})
+}
+
+// validateAuthorize 校验客户端、回调地址、scope 与 PKCE 参数，返回最终授予的 scope
+func (h *AuthHandler) validateAuthorize(c *gin.Context, req *AuthorizeRequest) (*models.OAuthClient, []string, bool) {
+	if req.ResponseType != "code" {
+		JSONError(c, CodeInvalidParameter, "unsupported_response_type")
+		return nil, nil, false
+	}
+	if req.CodeChallengeMethod == "" {
+		req.CodeChallengeMethod = "S256"
+	}
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43 {
+		JSONError(c, CodeInvalidParameter, "code_challenge must be a S256 challenge")
+		return nil, nil, false
+	}
+	var client models.OAuthClient
+	if err := h.service.DB.Where("id = ? AND status = ?", req.ClientID, "active").First(&client).Error; err != nil {
+		JSONError(c, CodeInvalidParameter, "invalid_client")
+		return nil, nil, false
+	}
+	if !service.ValidateRedirectURI(&client, req.RedirectURI) {
+		JSONError(c, CodeInvalidParameter, "redirect_uri does not match the registered value")
+		return nil, nil, false
+	}
+	allowed := strings.Fields(client.Scopes)
+	requested := strings.Fields(req.Scope)
+	if len(requested) == 0 {
+		requested = allowed
+	}
+	allowedSet := map[string]struct{}{}
+	for _, s := range allowed {
+		allowedSet[s] = struct{}{}
+	}
+	for _, s := range requested {
+		if _, ok := allowedSet[s]; !ok {
+			JSONError(c, CodeInvalidParameter, "invalid_scope")
+			return nil, nil, false
+		}
+	}
+	return &client, requested, true
+}
+
+func appendQuery(rawURL string, q url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	existing := u.Query()
+	for k, v := range q {
+		existing[k] = v
+	}
+	u.RawQuery = existing.Encode()
+	return u.String()
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimDomainRequest 认领邮箱域名
+type ClaimDomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+func orgDomainError(c *gin.Context, err error) {
+	var cfgErr *service.SSOConfigError
+	switch {
+	case errors.As(err, &cfgErr):
+		JSONError(c, CodeInvalidParameter, cfgErr.Message)
+	case errors.Is(err, service.ErrOrgDomainNotFound):
+		JSONError(c, CodeNotFound, "组织未认领该域名")
+	case errors.Is(err, service.ErrOrgDomainTaken):
+		JSONError(c, CodeConflict, "该域名已被其他组织验证")
+	case errors.Is(err, service.ErrOrgDomainTXTNotFound):
+		JSONError(c, CodeBadRequest, "未找到验证 TXT 记录，请确认已发布且 DNS 已生效")
+	default:
+		logger.GetLogger().WithError(err).Error("组织域名操作失败")
+		JSONError(c, CodeDatabaseError, "操作失败")
+	}
+}
+
+// @Summary 列出组织认领的邮箱域名
+// @Tags Organization
+// @Produce json
+// @Success 200 {array} service.OrgDomainView
+// @Router /api/v1/orgs/domains [get]
+func (h *OrganizationHandler) ListDomains(c *gin.Context) {
+	claims, err := h.service.ListOrgDomains(c.GetString("orgID"))
+	if err != nil {
+		orgDomainError(c, err)
+		return
+	}
+	out := make([]service.OrgDomainView, 0, len(claims))
+	for i := range claims {
+		out = append(out, service.NewOrgDomainView(&claims[i]))
+	}
+	JSONSuccess(c, out)
+}
+
+// @Summary 认领邮箱域名
+// @Description 返回需要发布的 TXT 记录（记录名 _kyc-service-verification.<domain>）；验证通过后该域名才用于 SSO 路由、即时开通与 SCIM 关联已有账号。公共邮箱域名不能认领
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body ClaimDomainRequest true "域名"
+// @Success 200 {object} service.OrgDomainView
+// @Router /api/v1/orgs/domains [post]
+func (h *OrganizationHandler) ClaimDomain(c *gin.Context) {
+	var req ClaimDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	orgID := c.GetString("orgID")
+	rec, err := h.service.ClaimOrgDomain(orgID, c.GetString("userID"), req.Domain)
+	if err != nil {
+		orgDomainError(c, err)
+		return
+	}
+	h.service.RecordAuditLog(c, "org.domain.claim", "organization", orgID, "success", rec.Domain)
+	JSONSuccess(c, service.NewOrgDomainView(rec))
+}
+
+// @Summary 验证邮箱域名
+// @Description 查询 DNS TXT 记录完成所有权验证
+// @Tags Organization
+// @Produce json
+// @Param domain path string true "域名"
+// @Success 200 {object} service.OrgDomainView
+// @Router /api/v1/orgs/domains/{domain}/verify [post]
+func (h *OrganizationHandler) VerifyDomain(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	rec, err := h.service.VerifyOrgDomain(c.Request.Context(), orgID, c.Param("domain"))
+	if err != nil {
+		h.service.RecordAuditLog(c, "org.domain.verify", "organization", orgID, "failed", c.Param("domain")+": "+err.Error())
+		orgDomainError(c, err)
+		return
+	}
+	h.service.RecordAuditLog(c, "org.domain.verify", "organization", orgID, "success", rec.Domain)
+	JSONSuccess(c, service.NewOrgDomainView(rec))
+}
+
+// @Summary 取消认领邮箱域名
+// @Tags Organization
+// @Produce json
+// @Param domain path string true "域名"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/domains/{domain} [delete]
+func (h *OrganizationHandler) DeleteDomain(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	if err := h.service.DeleteOrgDomain(orgID, c.Param("domain")); err != nil {
+		orgDomainError(c, err)
+		return
+	}
+	h.service.RecordAuditLog(c, "org.domain.delete", "organization", orgID, "success", c.Param("domain"))
+	JSONSuccess(c, gin.H{"deleted": true})
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrgRoleRequest 创建/更新组织自定义角色；更新时省略 permissions 表示不修改权限
+type OrgRoleRequest struct {
+	Name        string   `json:"name" binding:"omitempty,max=100"`
+	Description string   `json:"description" binding:"max=500"`
+	Permissions []string `json:"permissions" binding:"omitempty,dive,max=50"`
+}
+
+// grantorPermissions 当前操作者可授出的权限，nil 表示不受限（组织所有者、平台管理员）
+func grantorPermissions(c *gin.Context) []string {
+	if c.GetBool("isPlatformAdmin") || c.GetString("orgRole") == "owner" {
+		return nil
+	}
+	perms, _ := c.Get("permissions")
+	if ps, ok := perms.([]string); ok && ps != nil {
+		return ps
+	}
+	return []string{}
+}
+
+// roleError 将角色相关错误映射为响应，无法识别时按 fallback 报数据库错误
+func roleError(c *gin.Context, err error, fallback string) {
+	var pe *service.PermissionError
+	switch {
+	case errors.As(err, &pe) && errors.Is(err, service.ErrUnknownPermission):
+		JSONError(c, CodeInvalidParameter, "权限不存在: "+strings.Join(pe.Permissions, ","))
+	case errors.As(err, &pe):
+		JSONError(c, CodeForbidden, "无权授予权限: "+strings.Join(pe.Permissions, ","))
+	case errors.Is(err, service.ErrRoleNotFound):
+		JSONError(c, CodeNotFound, "角色不存在")
+	case errors.Is(err, service.ErrRoleNotAssignable):
+		JSONError(c, CodeInvalidParameter, "角色不存在或不可分配")
+	case errors.Is(err, service.ErrSystemRoleReadonly):
+		JSONError(c, CodeForbidden, "系统角色不可修改")
+	case errors.Is(err, service.ErrRoleNameTaken):
+		JSONError(c, CodeConflict, "角色名称已存在")
+	case errors.Is(err, service.ErrRoleInUse):
+		JSONError(c, CodeConflict, "仍有成员或待处理邀请使用该角色")
+	case errors.Is(err, service.ErrRoleLimitReached):
+		JSONError(c, CodeConflict, fmt.Sprintf("自定义角色数量已达上限(%d)", service.MaxOrgCustomRoles))
+	default:
+		logger.GetLogger().WithError(err).Error(fallback)
+		JSONError(c, CodeDatabaseError, fallback)
+	}
+}
+
+// checkAssignableRole 校验角色可分配给本组织成员，且操作者拥有该角色的全部权限
+func (h *OrganizationHandler) checkAssignableRole(c *gin.Context, orgID, roleID string) bool {
+	role, err := h.service.AssignableRole(orgID, roleID)
+	if err == nil {
+		err = service.CheckGrantable(grantorPermissions(c), role.Permissions)
+	}
+	if err != nil {
+		roleError(c, err, "校验角色失败")
+		return false
+	}
+	return true
+}
+
+// @Summary 列出组织角色
+// @Description 包含系统角色与本组织自定义角色及其权限、成员数
+// @Tags Organization
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/roles [get]
+func (h *OrganizationHandler) ListOrgRoles(c *gin.Context) {
+	roles, err := h.service.ListOrgRoles(c.GetString("orgID"))
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "查询失败")
+		return
+	}
+	JSONSuccess(c, gin.H{"roles": roles})
+}
+
+// @Summary 获取组织角色
+// @Tags Organization
+// @Produce json
+// @Param id path string true "角色ID"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/roles/{id} [get]
+func (h *OrganizationHandler) GetOrgRole(c *gin.Context) {
+	role, err := h.service.GetOrgRole(c.GetString("orgID"), c.Param("id"))
+	if err != nil {
+		roleError(c, err, "查询失败")
+		return
+	}
+	JSONSuccess(c, role)
+}
+
+// @Summary 创建组织自定义角色
+// @Description 权限须取自权限目录，且只能包含操作者自己拥有的权限；org.delete、billing.write 仅属于所有者
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body OrgRoleRequest true "角色定义"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/roles [post]
+func (h *OrganizationHandler) CreateOrgRole(c *gin.Context) {
+	var req OrgRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	orgID := c.GetString("orgID")
+	role, err := h.service.CreateOrgRole(c.Request.Context(), orgID, c.GetString("userID"),
+		service.OrgRoleInput{Name: req.Name, Description: req.Description, Permissions: req.Permissions}, grantorPermissions(c))
+	if err != nil {
+		roleError(c, err, "创建角色失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.role.create", "role", role.ID, "success", fmt.Sprintf("org=%s name=%s permissions=%s", orgID, role.Name, strings.Join(role.Permissions, ",")))
+	JSONSuccess(c, role)
+}
+
+// @Summary 更新组织自定义角色
+// @Description 权限变更对持有该角色的成员立即生效；操作者须拥有角色原有及新设的全部权限
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param id path string true "角色ID"
+// @Param request body OrgRoleRequest true "角色定义"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/roles/{id} [put]
+func (h *OrganizationHandler) UpdateOrgRole(c *gin.Context) {
+	var req OrgRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	orgID := c.GetString("orgID")
+	role, err := h.service.UpdateOrgRole(c.Request.Context(), orgID, c.Param("id"),
+		service.OrgRoleInput{Name: req.Name, Description: req.Description, Permissions: req.Permissions}, grantorPermissions(c))
+	if err != nil {
+		roleError(c, err, "更新角色失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.role.update", "role", role.ID, "success", fmt.Sprintf("org=%s name=%s permissions=%s", orgID, role.Name, strings.Join(role.Permissions, ",")))
+	JSONSuccess(c, role)
+}
+
+// @Summary 删除组织自定义角色
+// @Description 仍有成员或待处理邀请使用该角色时不可删除
+// @Tags Organization
+// @Produce json
+// @Param id path string true "角色ID"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/roles/{id} [delete]
+func (h *OrganizationHandler) DeleteOrgRole(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	id := c.Param("id")
+	if err := h.service.DeleteOrgRole(c.Request.Context(), orgID, id, grantorPermissions(c)); err != nil {
+		roleError(c, err, "删除角色失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.role.delete", "role", id, "success", "org="+orgID)
+	JSONSuccess(c, gin.H{"deleted": id})
+}
+
+// @Summary 模拟角色权限
+// @Description 以指定角色身份对照权限目录，预览其被授予与未被授予的权限
+// @Tags Organization
+// @Produce json
+// @Param id path string true "角色ID"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/roles/{id}/simulate [get]
+func (h *OrganizationHandler) SimulateOrgRole(c *gin.Context) {
+	sim, err := h.service.SimulateRole(c.Request.Context(), c.GetString("orgID"), c.Param("id"))
+	if err != nil {
+		roleError(c, err, "查询失败")
+		return
+	}
+	JSONSuccess(c, sim)
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateSCIMTokenRequest 创建 SCIM 令牌
+type CreateSCIMTokenRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// CreateSCIMTokenResponse 令牌明文只在创建时返回一次
+type CreateSCIMTokenResponse struct {
+	models.SCIMToken
+	Token   string `json:"token"`
+	BaseURL string `json:"scim_base_url"`
+}
+
+// UpdateSCIMGroupRoleRequest 设置 SCIM 组映射的角色，空串表示取消映射
+type UpdateSCIMGroupRoleRequest struct {
+	Role string `json:"role" binding:"omitempty,oneof=admin developer viewer"`
+}
+
+// @Summary 创建SCIM令牌
+// @Description 令牌可开通/停用本组织成员，需近期完成多因素验证
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body CreateSCIMTokenRequest true "令牌名称"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/scim/tokens [post]
+func (h *OrganizationHandler) CreateSCIMToken(c *gin.Context) {
+	var req CreateSCIMTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	orgID := c.GetString("orgID")
+	tok, raw, err := h.service.CreateSCIMToken(orgID, req.Name, c.GetString("userID"))
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("创建SCIM令牌失败")
+		JSONError(c, CodeDatabaseError, "创建失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.scim.token.create", "organization", orgID, "success", fmt.Sprintf("token=%s name=%s", tok.ID, tok.Name))
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	JSONSuccess(c, CreateSCIMTokenResponse{SCIMToken: *tok, Token: raw, BaseURL: scheme + "://" + c.Request.Host + "/scim/v2"})
+}
+
+// @Summary 列出SCIM令牌
+// @Tags Organization
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/scim/tokens [get]
+func (h *OrganizationHandler) ListSCIMTokens(c *gin.Context) {
+	tokens, err := h.service.ListSCIMTokens(c.GetString("orgID"))
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "查询失败")
+		return
+	}
+	JSONSuccess(c, gin.H{"tokens": tokens})
+}
+
+// @Summary 吊销SCIM令牌
+// @Tags Organization
+// @Produce json
+// @Param id path string true "令牌ID"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/scim/tokens/{id} [delete]
+func (h *OrganizationHandler) RevokeSCIMToken(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	id := c.Param("id")
+	if err := h.service.RevokeSCIMToken(orgID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			JSONError(c, CodeNotFound, "令牌不存在")
+			return
+		}
+		JSONError(c, CodeDatabaseError, "吊销失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.scim.token.revoke", "organization", orgID, "success", "token="+id)
+	JSONSuccess(c, gin.H{"revoked": id})
+}
+
+// @Summary 列出SCIM组及映射角色
+// @Tags Organization
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/scim/groups [get]
+func (h *OrganizationHandler) ListSCIMGroups(c *gin.Context) {
+	groups, err := h.service.ListSCIMGroupMappings(c.GetString("orgID"))
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "查询失败")
+		return
+	}
+	JSONSuccess(c, gin.H{"groups": groups})
+}
+
+// @Summary 设置SCIM组映射角色
+// @Description 组织存在已映射角色的组后，SCIM 开通的成员角色取其所属组中最高的映射角色，不属于任何已映射组时为 viewer；所有者不受影响
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param id path string true "SCIM组ID"
+// @Param request body UpdateSCIMGroupRoleRequest true "映射角色"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/scim/groups/{id}/role [put]
+func (h *OrganizationHandler) UpdateSCIMGroupRole(c *gin.Context) {
+	var req UpdateSCIMGroupRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	orgID := c.GetString("orgID")
+	g, err := h.service.SetSCIMGroupRole(orgID, c.Param("id"), req.Role)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			JSONError(c, CodeNotFound, "组不存在")
+			return
+		}
+		logger.GetLogger().WithError(err).Error("更新SCIM组角色失败")
+		JSONError(c, CodeDatabaseError, "更新失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.scim.group.role", "organization", orgID, "success", fmt.Sprintf("group=%s (%s) role=%q", g.ID, g.DisplayName, req.Role))
+	JSONSuccess(c, g)
+}
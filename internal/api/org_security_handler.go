@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+
+	"kyc-service/internal/models"
+	"kyc-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrgSecuritySettings 组织安全设置
+type OrgSecuritySettings struct {
+	RequireMFA *bool `json:"require_mfa" binding:"required"`
+}
+
+// @Summary 获取组织安全设置
+// @Tags Organization
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/security [get]
+func (h *OrganizationHandler) GetSecuritySettings(c *gin.Context) {
+	var org models.Organization
+	if err := h.service.DB.Select("id", "require_mfa").First(&org, "id = ?", c.GetString("orgID")).Error; err != nil {
+		JSONError(c, CodeNotFound, "组织不存在")
+		return
+	}
+	JSONSuccess(c, gin.H{"require_mfa": org.RequireMFA})
+}
+
+// @Summary 更新组织安全设置
+// @Description 开启强制MFA后，未启用第二因素的成员只能访问MFA注册接口；开启者本人必须已完成MFA
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body OrgSecuritySettings true "安全设置"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/orgs/security [put]
+func (h *OrganizationHandler) UpdateSecuritySettings(c *gin.Context) {
+	var req OrgSecuritySettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	orgID := c.GetString("orgID")
+	if *req.RequireMFA && !service.HasAMR(sessionAMR(c), service.AMRMFA) {
+		JSONError(c, CodeForbidden, "请先为自己的账号启用并完成多因素认证")
+		return
+	}
+	res := h.service.DB.Model(&models.Organization{}).Where("id = ?", orgID).Update("require_mfa", *req.RequireMFA)
+	if res.Error != nil {
+		JSONError(c, CodeDatabaseError, "保存失败")
+		return
+	}
+	if res.RowsAffected == 0 {
+		JSONError(c, CodeNotFound, "组织不存在")
+		return
+	}
+	h.service.RecordAuditLog(c, "org.security.update", "organization", orgID, "success", fmt.Sprintf("require_mfa=%t", *req.RequireMFA))
+	JSONSuccess(c, gin.H{"require_mfa": *req.RequireMFA})
+}
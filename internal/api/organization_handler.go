@@ -67,6 +67,16 @@ func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
 		JSONError(c, CodeForbidden, "非组织成员或未激活")
 		return
 	}
+	// 通过组织 SSO 建立的会话只能访问该组织；其他会话不能进入要求 SSO 的组织
+	ssoOrg := c.GetString("ssoOrgID")
+	if ssoOrg != "" && ssoOrg != req.OrgID {
+		JSONError(c, CodeForbidden, "当前会话通过组织 SSO 登录，切换组织需重新登录")
+		return
+	}
+	if ssoOrg == "" && h.service.SSORequiredFor(req.OrgID, member.Role) {
+		JSONError(c, CodeForbidden, "该组织要求通过企业 SSO 登录")
+		return
+	}
 	// 更新用户当前组织
 	if err := h.service.DB.Model(&models.User{}).Where("id = ?", userID).Update("current_org_id", req.OrgID).Error; err != nil {
 		JSONError(c, CodeDatabaseError, "切换失败")
@@ -95,7 +105,9 @@ func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
 	user.OrgID = req.OrgID // 注意：有些Token逻辑可能用OrgID字段，有些用CurrentOrgID，这里统一下
 
 	// 生成新Token
-	newToken, err := h.generateTokenForSwitch(&user, &org)
+	current, _ := c.Get("user")
+	currentClaims, _ := current.(jwt.MapClaims)
+	newToken, err := h.generateTokenForSwitch(&user, &org, currentClaims)
 	if err != nil {
 		logger.GetLogger().WithError(err).Error("生成切换Token失败")
 		// 降级处理：仅返回ID，前端可能需要重新登录或容忍旧Token（不推荐）
@@ -105,13 +117,7 @@ func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
 	}
 
 	// 获取新权限列表
-	var permIDs []string
-	var rows []struct{ PermissionID string }
-	if err := h.service.DB.Table("role_permissions").Select("permission_id").Where("role_id = ?", member.Role).Scan(&rows).Error; err == nil {
-		for _, r := range rows {
-			permIDs = append(permIDs, r.PermissionID)
-		}
-	}
+	permIDs := h.service.RolePermissions(c.Request.Context(), req.OrgID, member.Role)
 
 	metrics.RecordAuditEvent(c.Request.Context(), "org.switch", "organization", "success")
 
@@ -123,8 +129,8 @@ func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
 	})
 }
 
-// 辅助方法：生成Token (复用 ConsoleAuthHandler 逻辑)
-func (h *OrganizationHandler) generateTokenForSwitch(user *models.User, org *models.Organization) (string, error) {
+// 辅助方法：生成Token (复用 ConsoleAuthHandler 逻辑)，沿用当前会话（sid）及其认证方式（amr/mfa_at/sso_org）
+func (h *OrganizationHandler) generateTokenForSwitch(user *models.User, org *models.Organization, current jwt.MapClaims) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":  user.ID,
 		"email":    user.Email,
@@ -132,12 +138,16 @@ func (h *OrganizationHandler) generateTokenForSwitch(user *models.User, org *mod
 		"org_id":   user.CurrentOrgID, // 切换后，Token应当绑定当前选中的Org
 		"org_role": user.OrgRole,
 		"plan_id":  org.PlanID,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
+		"exp":      time.Now().Add(h.service.SessionAccessTTL()).Unix(),
 		"iat":      time.Now().Unix(),
 	}
+	for _, k := range []string{"sid", "amr", "mfa_at", "sso_org"} {
+		if v, ok := current[k]; ok {
+			claims[k] = v
+		}
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.service.Config.Security.JWTSecret))
+	return h.service.SignJWT(claims)
 }
 
 // 创建组织
@@ -399,6 +409,10 @@ func (h *OrganizationHandler) InviteOrganizationMember(c *gin.Context) {
 		JSONError(c, CodeInvalidParameter, "参数验证失败")
 		return
 	}
+	if !h.checkAssignableRole(c, orgID, req.Role) {
+		middleware.RecordBusinessOperation("invite_org_member", false, time.Since(start), "invalid_role")
+		return
+	}
 
 	// 检查被邀请用户是否已存在
 	var existingUser models.User
@@ -504,10 +518,12 @@ type BillingResponse struct {
 		RequestsLimit int    `json:"requestsLimit"`
 	} `json:"plan"`
 	UsageSummary struct {
-		TotalRequests int64   `json:"totalRequests"`
-		Limit         int     `json:"limit"`
-		PercentUsed   float64 `json:"percentUsed"`
-		Period        string  `json:"period"`
+		TotalRequests int64          `json:"totalRequests"`
+		Limit         int            `json:"limit"`
+		PercentUsed   float64        `json:"percentUsed"`
+		Period        string         `json:"period"`
+		OverageUnits  int            `json:"overageUnits"`      // 当期超额计费用量合计
+		Overage       map[string]int `json:"overage,omitempty"` // 按服务类型
 	} `json:"usageSummary"`
 	Invoices []struct {
 		ID     string `json:"id"`
@@ -564,6 +580,15 @@ func (h *OrganizationHandler) GetBilling(c *gin.Context) {
 	resp.UsageSummary.Limit = pm.Limit
 	resp.UsageSummary.PercentUsed = percent
 	resp.UsageSummary.Period = time.Now().Format("2006-01")
+	var quotas []models.OrganizationQuotas
+	_ = h.service.DB.Select("service_type", "overage").Where("organization_id = ? AND overage > 0", orgID).Find(&quotas).Error
+	for _, q := range quotas {
+		if resp.UsageSummary.Overage == nil {
+			resp.UsageSummary.Overage = map[string]int{}
+		}
+		resp.UsageSummary.Overage[q.ServiceType] = q.Overage
+		resp.UsageSummary.OverageUnits += q.Overage
+	}
 	resp.Invoices = []struct {
 		ID     string `json:"id"`
 		Amount int    `json:"amount"`
@@ -950,7 +975,7 @@ func (h *OrganizationHandler) UpdatePlan(c *gin.Context) {
 
 // UpdateMemberRole 修改成员角色
 type UpdateMemberRoleRequest struct {
-	Role string `json:"role" binding:"required,oneof=admin developer viewer"`
+	Role string `json:"role" binding:"required,max=64"` // 系统角色或本组织自定义角色ID
 }
 
 type ResetMemberPasswordRequest struct {
@@ -1024,12 +1049,6 @@ func (h *OrganizationHandler) UpdateMemberRole(c *gin.Context) {
 		JSONError(c, CodeInvalidParameter, "参数验证失败")
 		return
 	}
-	// 角色必须存在
-	var roleCount int64
-	if err := h.service.DB.Model(&models.Role{}).Where("id = ?", req.Role).Count(&roleCount).Error; err != nil || roleCount == 0 {
-		JSONError(c, CodeInvalidParameter, "角色不存在")
-		return
-	}
 	var member models.OrganizationMember
 	if err := h.service.DB.Where("id = ? AND organization_id = ?", memberID, orgID).First(&member).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -1044,6 +1063,14 @@ func (h *OrganizationHandler) UpdateMemberRole(c *gin.Context) {
 		JSONError(c, CodeForbidden, "不允许修改所有者角色")
 		return
 	}
+	// 新角色须可分配且操作者拥有其全部权限；也不能调整权限多于自己的成员
+	if !h.checkAssignableRole(c, orgID, req.Role) {
+		return
+	}
+	if err := service.CheckGrantable(grantorPermissions(c), h.service.RolePermissions(c.Request.Context(), orgID, member.Role)); err != nil {
+		roleError(c, err, "校验角色失败")
+		return
+	}
 	if err := h.service.DB.Model(&member).Update("role", req.Role).Error; err != nil {
 		logger.GetLogger().WithError(err).Error("更新成员角色失败")
 		JSONError(c, CodeDatabaseError, "更新失败")
@@ -1131,6 +1158,10 @@ func (h *OrganizationHandler) UpdateMemberStatus(c *gin.Context) {
 	action := "member.activate"
 	if req.Status == "suspended" {
 		action = "member.suspend"
+		// 停用成员后其已签发的会话与代表其签发的 OAuth 令牌立即失效
+		if err := h.service.RevokeTokensBy(c.Request.Context(), service.RevocationByUser, member.UserID, "member_suspended"); err != nil {
+			logger.GetLogger().WithError(err).Error("吊销成员令牌失败")
+		}
 	}
 	auditLog := &models.AuditLog{UserID: c.GetString("userID"), OrgID: orgID, Action: action, IP: c.ClientIP(), UserAgent: c.Request.UserAgent(), Status: "success", Message: fmt.Sprintf("Member %s status -> %s", memberID, req.Status)}
 	if err := h.service.DB.Create(auditLog).Error; err != nil {
@@ -1269,7 +1300,7 @@ func (h *OrganizationHandler) ExportOrgAuditLogs(c *gin.Context) {
 // CreateInvitation 发送邀请
 type CreateInvitationRequest struct {
 	Email string `json:"email" binding:"required,email"`
-	Role  string `json:"role" binding:"required,oneof=admin viewer developer"`
+	Role  string `json:"role" binding:"required,max=64"` // 系统角色或本组织自定义角色ID
 }
 
 func (h *OrganizationHandler) CreateInvitation(c *gin.Context) {
@@ -1285,6 +1316,9 @@ func (h *OrganizationHandler) CreateInvitation(c *gin.Context) {
 		JSONError(c, CodeInvalidParameter, "参数验证失败")
 		return
 	}
+	if !h.checkAssignableRole(c, orgID, req.Role) {
+		return
+	}
 	var dup int64
 	_ = h.service.DB.Model(&models.Invitation{}).Where("org_id = ? AND email = ? AND status = ?", orgID, req.Email, "pending").Count(&dup).Error
 	if dup > 0 {
@@ -57,6 +57,24 @@ func (h *PasswordResetHandler) RequestPasswordReset(c *gin.Context) {
 		return
 	}
 
+	// 按邮箱与IP限制重置请求频率：每次请求都计数，超过阈值后临时锁定
+	if block, blocked := rejectBlockedAuth(c, h.service, service.AuthGuardPasswordReset, req.Email); blocked {
+		metrics.RecordBusinessOperation(c.Request.Context(), "password_reset_request", false, time.Since(start), block.Reason)
+		return
+	}
+	if res := h.service.RecordAuthAttemptFailure(c.Request.Context(), service.AuthGuardPasswordReset, req.Email, c.ClientIP()); res.AccountLocked || res.IPLocked {
+		lock := &models.AuditLog{
+			Action:    "password_reset_locked",
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Status:    "locked",
+			Message:   fmt.Sprintf("Password reset locked for %s (email=%s, ip=%s) after repeated requests", res.LockedFor, req.Email, c.ClientIP()),
+		}
+		if err := h.service.DB.Create(lock).Error; err != nil {
+			logger.GetLogger().WithError(err).Error("记录审计日志失败")
+		}
+	}
+
 	// 查找用户
 	var user models.User
 	if err := h.service.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
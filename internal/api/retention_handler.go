@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+
+	"kyc-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 获取数据保留策略
+// @Description 当前组织生效的各类数据保留天数及来源（系统默认、套餐或组织策略），0 表示永久保留
+// @Tags Organization
+// @Produce json
+// @Success 200 {object} service.EffectiveRetention
+// @Router /api/v1/orgs/retention-policy [get]
+func (h *OrganizationHandler) GetRetentionPolicy(c *gin.Context) {
+	JSONSuccess(c, h.service.EffectiveRetentionPolicy(c.GetString("orgID")))
+}
+
+// @Summary 更新数据保留策略
+// @Description 以请求体替换组织策略，如 {"raw_images":30,"request_logs":90}；未列出的类别继承套餐
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param request body service.RetentionPolicy true "各数据类别保留天数"
+// @Success 200 {object} service.EffectiveRetention
+// @Router /api/v1/orgs/retention-policy [put]
+func (h *OrganizationHandler) UpdateRetentionPolicy(c *gin.Context) {
+	orgID := c.GetString("orgID")
+	raw, err := c.GetRawData()
+	if err != nil {
+		JSONError(c, CodeInvalidParameter, "Invalid request body")
+		return
+	}
+	policy, err := service.ParseRetentionPolicy(string(raw))
+	if err != nil {
+		JSONError(c, CodeInvalidParameter, err.Error())
+		return
+	}
+	if err := h.service.SaveOrgRetentionPolicy(orgID, policy); err != nil {
+		JSONError(c, CodeDatabaseError, "保存保留策略失败")
+		return
+	}
+	b, _ := json.Marshal(policy)
+	h.service.RecordAuditLog(c, "org.retention_policy.update", "organization", orgID, "success", string(b))
+	JSONSuccess(c, h.service.EffectiveRetentionPolicy(orgID))
+}
+
+// @Summary 预览保留期清理
+// @Description 按当前策略统计本组织将被清理的数据（dry-run，不删除，不写审计）
+// @Tags Organization
+// @Produce json
+// @Success 200 {object} service.OrgRetentionReport
+// @Router /api/v1/orgs/retention-policy/preview [get]
+func (h *OrganizationHandler) PreviewRetentionPurge(c *gin.Context) {
+	JSONSuccess(c, h.service.PreviewOrgRetention(c.Request.Context(), c.GetString("orgID")))
+}
+
+// RetentionRunRequest 管理端手动执行保留期清理
+type RetentionRunRequest struct {
+	DryRun bool   `json:"dry_run"`
+	OrgID  string `json:"org_id"` // 为空时清理全部组织
+}
+
+// RunRetentionPurge 管理端手动执行（或预览）保留期清理
+func (h *AdminHandler) RunRetentionPurge(c *gin.Context) {
+	var req RetentionRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数错误")
+		return
+	}
+	actor := c.GetString("userID")
+	var report interface{}
+	var err error
+	if req.OrgID != "" {
+		report, err = h.service.RunOrgRetentionPurge(c.Request.Context(), req.OrgID, req.DryRun, actor)
+	} else {
+		report, err = h.service.RunRetentionPurge(c.Request.Context(), req.DryRun, actor)
+	}
+	if errors.Is(err, service.ErrRetentionRunning) {
+		JSONError(c, CodeConflict, "保留期清理正在执行")
+		return
+	}
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "保留期清理失败")
+		return
+	}
+	JSONSuccess(c, report)
+}
@@ -0,0 +1,355 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kyc-service/internal/middleware"
+	"kyc-service/internal/models"
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/scim"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMHandler SCIM 2.0 成员开通接口（/scim/v2），由组织的 SCIM 令牌认证
+type SCIMHandler struct {
+	service *service.KYCService
+}
+
+// NewSCIMHandler 创建 SCIM 处理器
+func NewSCIMHandler(svc *service.KYCService) *SCIMHandler {
+	return &SCIMHandler{service: svc}
+}
+
+func scimJSON(c *gin.Context, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		middleware.AbortSCIM(c, scim.NewError(http.StatusInternalServerError, "", "internal error"))
+		return
+	}
+	c.Data(status, scim.ContentType, body)
+}
+
+// scimFail 协议错误原样返回，其余记录日志后返回 500
+func scimFail(c *gin.Context, err error) {
+	var se *scim.Error
+	if errors.As(err, &se) {
+		middleware.AbortSCIM(c, se)
+		return
+	}
+	logger.GetLogger().WithError(err).Error("SCIM请求处理失败")
+	middleware.AbortSCIM(c, scim.NewError(http.StatusInternalServerError, "", "internal error"))
+}
+
+func scimBind(c *gin.Context, v interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(v); err != nil {
+		middleware.AbortSCIM(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "invalid JSON body"))
+		return false
+	}
+	return true
+}
+
+// location 资源的绝对地址
+func (h *SCIMHandler) location(c *gin.Context, kind, id string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/scim/v2/%s/%s", scheme, c.Request.Host, kind, id)
+}
+
+func (h *SCIMHandler) withUserLocation(c *gin.Context, u *scim.User) *scim.User {
+	if u.Meta != nil {
+		u.Meta.Location = h.location(c, "Users", u.ID)
+	}
+	return u
+}
+
+func (h *SCIMHandler) withGroupLocation(c *gin.Context, g *scim.Group) *scim.Group {
+	if g.Meta != nil {
+		g.Meta.Location = h.location(c, "Groups", g.ID)
+	}
+	for i := range g.Members {
+		g.Members[i].Ref = h.location(c, "Users", g.Members[i].Value)
+	}
+	return g
+}
+
+func (h *SCIMHandler) audit(c *gin.Context, action, resourceID, msg string) {
+	auditLog := &models.AuditLog{
+		RequestID: c.GetString("request_id"),
+		OrgID:     c.GetString("orgID"),
+		Action:    action,
+		Resource:  "scim",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Status:    "success",
+		Message:   fmt.Sprintf("%s (resource=%s, scim_token=%s)", msg, resourceID, c.GetString("scimTokenID")),
+	}
+	if err := h.service.CreateAuditLog(auditLog); err != nil {
+		logger.GetLogger().WithError(err).Error("记录审计日志失败")
+	}
+}
+
+// withMembers 是否返回组成员（excludedAttributes=members 时省略，大组同步时常用）
+func withMembers(c *gin.Context) bool {
+	for _, a := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(a), "members") {
+			return false
+		}
+	}
+	return true
+}
+
+// ServiceProviderConfig SCIM 能力声明
+// @Summary SCIM服务能力
+// @Tags SCIM
+// @Produce json
+// @Router /scim/v2/ServiceProviderConfig [get]
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, scim.ServiceProviderConfig(service.SCIMMaxResults))
+}
+
+// ListUsers 查询组织成员
+// @Summary SCIM查询用户
+// @Tags SCIM
+// @Produce json
+// @Param filter query string false "如 userName eq \"a@example.com\""
+// @Param startIndex query int false "起始序号（从1开始）"
+// @Param count query int false "每页数量"
+// @Router /scim/v2/Users [get]
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	f, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	start, count := scim.Pagination(c.Query("startIndex"), c.Query("count"), service.SCIMMaxResults)
+	users, total, err := h.service.ListSCIMUsers(c.GetString("orgID"), f, start, count)
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	for _, u := range users {
+		h.withUserLocation(c, u)
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(users, len(users), total, start))
+}
+
+// GetUser 查询单个成员
+// @Summary SCIM获取用户
+// @Tags SCIM
+// @Produce json
+// @Param id path string true "用户ID"
+// @Router /scim/v2/Users/{id} [get]
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	u, err := h.service.GetSCIMUser(c.GetString("orgID"), c.Param("id"))
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, h.withUserLocation(c, u))
+}
+
+// CreateUser 开通成员
+// @Summary SCIM创建用户
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Router /scim/v2/Users [post]
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var in scim.User
+	if !scimBind(c, &in) {
+		return
+	}
+	u, err := h.service.CreateSCIMUser(c.GetString("orgID"), &in)
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	h.audit(c, "scim.user.create", u.ID, fmt.Sprintf("Provisioned %s (active=%t)", u.UserName, u.IsActive()))
+	c.Header("Location", h.location(c, "Users", u.ID))
+	scimJSON(c, http.StatusCreated, h.withUserLocation(c, u))
+}
+
+// ReplaceUser 整体更新成员
+// @Summary SCIM替换用户
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "用户ID"
+// @Router /scim/v2/Users/{id} [put]
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	var in scim.User
+	if !scimBind(c, &in) {
+		return
+	}
+	u, err := h.service.ReplaceSCIMUser(c.Request.Context(), c.GetString("orgID"), c.Param("id"), &in)
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	h.audit(c, "scim.user.update", u.ID, fmt.Sprintf("Replaced %s (active=%t)", u.UserName, u.IsActive()))
+	scimJSON(c, http.StatusOK, h.withUserLocation(c, u))
+}
+
+// PatchUser 部分更新成员（常用于停用/启用）
+// @Summary SCIM修改用户
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "用户ID"
+// @Router /scim/v2/Users/{id} [patch]
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	var req scim.PatchRequest
+	if !scimBind(c, &req) {
+		return
+	}
+	u, err := h.service.PatchSCIMUser(c.Request.Context(), c.GetString("orgID"), c.Param("id"), req.Operations)
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	h.audit(c, "scim.user.update", u.ID, fmt.Sprintf("Patched %s (active=%t)", u.UserName, u.IsActive()))
+	scimJSON(c, http.StatusOK, h.withUserLocation(c, u))
+}
+
+// DeleteUser 取消开通：将用户移出组织
+// @Summary SCIM删除用户
+// @Tags SCIM
+// @Param id path string true "用户ID"
+// @Router /scim/v2/Users/{id} [delete]
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.service.DeleteSCIMUser(c.Request.Context(), c.GetString("orgID"), id); err != nil {
+		scimFail(c, err)
+		return
+	}
+	h.audit(c, "scim.user.delete", id, "Deprovisioned user")
+	c.Status(http.StatusNoContent)
+}
+
+// ListGroups 查询组
+// @Summary SCIM查询组
+// @Tags SCIM
+// @Produce json
+// @Param filter query string false "如 displayName eq \"KYC Admins\""
+// @Router /scim/v2/Groups [get]
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	f, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	start, count := scim.Pagination(c.Query("startIndex"), c.Query("count"), service.SCIMMaxResults)
+	groups, total, err := h.service.ListSCIMGroups(c.GetString("orgID"), f, start, count, withMembers(c))
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	for _, g := range groups {
+		h.withGroupLocation(c, g)
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(groups, len(groups), total, start))
+}
+
+// GetGroup 查询单个组
+// @Summary SCIM获取组
+// @Tags SCIM
+// @Produce json
+// @Param id path string true "组ID"
+// @Router /scim/v2/Groups/{id} [get]
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	g, err := h.service.GetSCIMGroup(c.GetString("orgID"), c.Param("id"), withMembers(c))
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, h.withGroupLocation(c, g))
+}
+
+// CreateGroup 创建组
+// @Summary SCIM创建组
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Router /scim/v2/Groups [post]
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var in scim.Group
+	if !scimBind(c, &in) {
+		return
+	}
+	g, err := h.service.CreateSCIMGroup(c.GetString("orgID"), &in)
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	h.audit(c, "scim.group.create", g.ID, fmt.Sprintf("Created group %q with %d members", g.DisplayName, len(g.Members)))
+	c.Header("Location", h.location(c, "Groups", g.ID))
+	scimJSON(c, http.StatusCreated, h.withGroupLocation(c, g))
+}
+
+// ReplaceGroup 整体更新组
+// @Summary SCIM替换组
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "组ID"
+// @Router /scim/v2/Groups/{id} [put]
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	var in scim.Group
+	if !scimBind(c, &in) {
+		return
+	}
+	g, err := h.service.ReplaceSCIMGroup(c.GetString("orgID"), c.Param("id"), &in)
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	h.audit(c, "scim.group.update", g.ID, fmt.Sprintf("Replaced group %q with %d members", g.DisplayName, len(g.Members)))
+	scimJSON(c, http.StatusOK, h.withGroupLocation(c, g))
+}
+
+// PatchGroup 部分更新组（增删成员）
+// @Summary SCIM修改组
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "组ID"
+// @Router /scim/v2/Groups/{id} [patch]
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	var req scim.PatchRequest
+	if !scimBind(c, &req) {
+		return
+	}
+	g, err := h.service.PatchSCIMGroup(c.GetString("orgID"), c.Param("id"), req.Operations)
+	if err != nil {
+		scimFail(c, err)
+		return
+	}
+	h.audit(c, "scim.group.update", g.ID, fmt.Sprintf("Patched group %q, now %d members", g.DisplayName, len(g.Members)))
+	if !withMembers(c) {
+		g.Members = nil
+	}
+	scimJSON(c, http.StatusOK, h.withGroupLocation(c, g))
+}
+
+// DeleteGroup 删除组
+// @Summary SCIM删除组
+// @Tags SCIM
+// @Param id path string true "组ID"
+// @Router /scim/v2/Groups/{id} [delete]
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.service.DeleteSCIMGroup(c.GetString("orgID"), id); err != nil {
+		scimFail(c, err)
+		return
+	}
+	h.audit(c, "scim.group.delete", id, "Deleted group")
+	c.Status(http.StatusNoContent)
+}
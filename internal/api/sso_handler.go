@@ -0,0 +1,337 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/metrics"
+	"kyc-service/pkg/saml"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ssoStateCookie 将 state 绑定到发起登录的浏览器，防止登录 CSRF
+const (
+	ssoStateCookie     = "kyc_sso_state"
+	ssoStateCookiePath = "/api/v1/auth/sso"
+)
+
+// SSODiscoverResponse 按邮箱查询组织 SSO 配置的结果
+type SSODiscoverResponse struct {
+	SSO      bool   `json:"sso"`
+	OrgID    string `json:"org_id,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Enforced bool   `json:"enforced,omitempty"`
+	LoginURL string `json:"login_url,omitempty"`
+}
+
+// SSOExchangeRequest 控制台用一次性 code 换取会话
+type SSOExchangeRequest struct {
+	Code       string `json:"code" binding:"required"`
+	DeviceName string `json:"device_name"`
+}
+
+// ssoFailureReason 回调失败时附加到控制台地址的错误标识（不暴露内部细节）
+func ssoFailureReason(err error) string {
+	var status *saml.StatusError
+	switch {
+	case errors.Is(err, service.ErrSSOStateInvalid):
+		return "state_invalid"
+	case errors.Is(err, service.ErrSSONotConfigured):
+		return "not_configured"
+	case errors.Is(err, service.ErrSSODomainNotAllowed):
+		return "domain_not_allowed"
+	case errors.Is(err, service.ErrSSOEmailUnverified):
+		return "email_unverified"
+	case errors.Is(err, service.ErrSSOAccountNotMember):
+		return "not_member"
+	case errors.Is(err, service.ErrSSOProvisioningDisabled):
+		return "provisioning_disabled"
+	case errors.Is(err, service.ErrOrgDomainUnverified):
+		return "domain_unverified"
+	case errors.Is(err, service.ErrSSOUserInactive):
+		return "user_inactive"
+	case errors.Is(err, service.ErrSSOAssertionReplay):
+		return "assertion_replay"
+	case errors.Is(err, service.ErrSSOIdPDenied), errors.As(err, &status):
+		return "idp_denied"
+	default:
+		return "sso_failed"
+	}
+}
+
+// ssoCookieSecure 对外地址为 https 时使用 Secure + SameSite=None（SAML ACS 为 IdP 跨站 POST）
+func (h *ConsoleAuthHandler) ssoCookieSecure() bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(h.service.Config.Security.SSO.BaseURL)), "https://")
+}
+
+func (h *ConsoleAuthHandler) setSSOStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := h.ssoCookieSecure()
+	if secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(ssoStateCookie, value, maxAge, ssoStateCookiePath, "", secure, true)
+}
+
+// checkSSOStateCookie 比对回调中的 state 与浏览器 Cookie，并清除 Cookie
+func (h *ConsoleAuthHandler) checkSSOStateCookie(c *gin.Context, state string) bool {
+	cookie, err := c.Cookie(ssoStateCookie)
+	h.setSSOStateCookie(c, "", -1)
+	return err == nil && state != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) == 1
+}
+
+// ssoRedirect 回到控制台；未配置控制台地址时以 JSON 返回，便于直接调用接口调试
+func (h *ConsoleAuthHandler) ssoRedirect(c *gin.Context, params url.Values) {
+	console := h.service.SSOConsoleURL()
+	if console == "" {
+		if reason := params.Get("error"); reason != "" {
+			JSONError(c, CodeUnauthorized, reason)
+			return
+		}
+		JSONSuccess(c, gin.H{"code": params.Get("code")})
+		return
+	}
+	sep := "?"
+	if strings.Contains(console, "?") {
+		sep = "&"
+	}
+	c.Redirect(http.StatusFound, console+sep+params.Encode())
+}
+
+func (h *ConsoleAuthHandler) ssoAudit(c *gin.Context, userID, orgID, action, status, msg string) {
+	h.recordAuditLog(&models.AuditLog{
+		UserID:    userID,
+		OrgID:     orgID,
+		Action:    action,
+		Resource:  "sso",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Status:    status,
+		Message:   msg,
+	})
+}
+
+// DiscoverSSO 按邮箱域名查询是否需要/可以使用企业 SSO 登录
+// @Summary 查询企业SSO
+// @Tags Console Auth
+// @Produce json
+// @Param email query string true "邮箱"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/auth/sso/discover [get]
+func (h *ConsoleAuthHandler) DiscoverSSO(c *gin.Context) {
+	email := strings.TrimSpace(c.Query("email"))
+	if email == "" {
+		JSONError(c, CodeMissingParameter, "缺少邮箱")
+		return
+	}
+	cfg, err := h.service.FindSSOConfigForEmail(email)
+	if errors.Is(err, service.ErrSSONotConfigured) {
+		JSONSuccess(c, SSODiscoverResponse{})
+		return
+	}
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("查询SSO配置失败")
+		JSONError(c, CodeDatabaseError, "系统错误")
+		return
+	}
+	JSONSuccess(c, SSODiscoverResponse{
+		SSO:      true,
+		OrgID:    cfg.OrgID,
+		Protocol: cfg.Protocol,
+		Enforced: cfg.EnforceSSO,
+		LoginURL: ssoStateCookiePath + "/start?org_id=" + url.QueryEscape(cfg.OrgID),
+	})
+}
+
+// StartSSO 发起企业 SSO 登录并重定向到 IdP
+// @Summary 发起企业SSO登录
+// @Tags Console Auth
+// @Param org_id query string false "组织ID"
+// @Param email query string false "邮箱（按域名查找组织）"
+// @Success 302
+// @Router /api/v1/auth/sso/start [get]
+func (h *ConsoleAuthHandler) StartSSO(c *gin.Context) {
+	var (
+		cfg *models.OrgSSOConfig
+		err error
+	)
+	if orgID := c.Query("org_id"); orgID != "" {
+		cfg, err = h.service.EnabledSSOConfig(orgID)
+	} else if email := c.Query("email"); email != "" {
+		cfg, err = h.service.FindSSOConfigForEmail(email)
+	} else {
+		JSONError(c, CodeMissingParameter, "缺少组织或邮箱")
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, service.ErrSSONotConfigured) {
+			logger.GetLogger().WithError(err).Error("查询SSO配置失败")
+		}
+		h.ssoRedirect(c, url.Values{"error": {ssoFailureReason(err)}})
+		return
+	}
+	loginURL, state, err := h.service.BeginSSOLogin(c.Request.Context(), cfg)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("org_id", cfg.OrgID).Error("发起SSO登录失败")
+		h.ssoRedirect(c, url.Values{"error": {"sso_failed"}})
+		return
+	}
+	h.setSSOStateCookie(c, state, int(h.service.SSOStateTTL().Seconds()))
+	c.Redirect(http.StatusFound, loginURL)
+}
+
+// OIDCCallback OIDC 授权码回调
+// @Summary OIDC登录回调
+// @Tags Console Auth
+// @Success 302
+// @Router /api/v1/auth/sso/oidc/callback [get]
+func (h *ConsoleAuthHandler) OIDCCallback(c *gin.Context) {
+	state := c.Query("state")
+	if !h.checkSSOStateCookie(c, state) {
+		h.finishSSO(c, nil, service.ErrSSOStateInvalid)
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		h.service.AbortSSOLogin(c.Request.Context(), state)
+		h.finishSSO(c, nil, fmt.Errorf("%w: %s %s", service.ErrSSOIdPDenied, e, c.Query("error_description")))
+		return
+	}
+	id, err := h.service.CompleteOIDCLogin(c.Request.Context(), state, c.Query("code"))
+	h.finishSSO(c, id, err)
+}
+
+// SAMLACS SAML 断言消费地址（HTTP-POST 绑定）
+// @Summary SAML断言消费
+// @Tags Console Auth
+// @Accept x-www-form-urlencoded
+// @Success 302
+// @Router /api/v1/auth/sso/saml/acs [post]
+func (h *ConsoleAuthHandler) SAMLACS(c *gin.Context) {
+	relayState := c.PostForm("RelayState")
+	if !h.checkSSOStateCookie(c, relayState) {
+		h.finishSSO(c, nil, service.ErrSSOStateInvalid)
+		return
+	}
+	id, err := h.service.CompleteSAMLLogin(c.Request.Context(), relayState, c.PostForm("SAMLResponse"))
+	h.finishSSO(c, id, err)
+}
+
+// finishSSO IdP 身份校验完成后开通/匹配本地账号并签发一次性 code
+func (h *ConsoleAuthHandler) finishSSO(c *gin.Context, id *service.SSOIdentity, err error) {
+	var user *models.User
+	created := false
+	if err == nil {
+		user, created, err = h.service.ProvisionSSOUser(id)
+	}
+	if err != nil {
+		reason := ssoFailureReason(err)
+		entry := logger.GetLogger().WithError(err).WithField("reason", reason)
+		orgID, email := "", ""
+		if id != nil {
+			orgID, email = id.OrgID, id.Email
+			entry = entry.WithField("org_id", orgID)
+		}
+		entry.Warn("SSO登录失败")
+		h.ssoAudit(c, "", orgID, "sso_login", "failed", fmt.Sprintf("SSO login failed for %s: %s (%v)", email, reason, err))
+		metrics.RecordBusinessOperation(c.Request.Context(), "sso_login", false, 0, reason)
+		h.ssoRedirect(c, url.Values{"error": {reason}})
+		return
+	}
+	if created {
+		h.ssoAudit(c, user.ID, id.OrgID, "sso.user_provisioned", "success",
+			fmt.Sprintf("Provisioned %s via %s (subject=%s)", user.Email, id.Protocol, id.Subject))
+	}
+	code, err := h.service.IssueSSOLoginCode(c.Request.Context(), user.ID, id.OrgID)
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("签发SSO登录code失败")
+		h.ssoRedirect(c, url.Values{"error": {"sso_failed"}})
+		return
+	}
+	h.ssoRedirect(c, url.Values{"code": {code}})
+}
+
+// ExchangeSSOCode 用一次性 code 建立控制台会话（已启用第二因素的账号返回MFA挑战）
+// @Summary 兑换SSO登录code
+// @Tags Console Auth
+// @Accept json
+// @Produce json
+// @Param request body SSOExchangeRequest true "一次性code"
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/auth/sso/exchange [post]
+func (h *ConsoleAuthHandler) ExchangeSSOCode(c *gin.Context) {
+	start := time.Now()
+	var req SSOExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "参数验证失败")
+		return
+	}
+	userID, orgID, err := h.service.RedeemSSOLoginCode(c.Request.Context(), req.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrSSOUnavailable) {
+			JSONError(c, CodeServiceUnavailable, "SSO 登录暂不可用")
+			return
+		}
+		metrics.RecordBusinessOperation(c.Request.Context(), "sso_login", false, time.Since(start), "invalid_code")
+		JSONError(c, CodeUnauthorized, "登录凭据无效或已过期")
+		return
+	}
+	var user models.User
+	if err := h.service.DB.First(&user, "id = ? AND status = ?", userID, "active").Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.GetLogger().WithError(err).Error("查询用户失败")
+		}
+		JSONError(c, CodeUnauthorized, "用户不存在或已禁用")
+		return
+	}
+
+	auditLog := &models.AuditLog{
+		Action:    "sso_login",
+		Resource:  "sso",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Status:    "pending",
+	}
+	if startMFAChallenge(c, h.service, &user, []string{service.AMRFederated}, orgID) {
+		auditLog.UserID = user.ID
+		auditLog.OrgID = orgID
+		auditLog.Status = "mfa_required"
+		auditLog.Message = "SSO accepted, awaiting second factor"
+		h.recordAuditLog(auditLog)
+		metrics.RecordBusinessOperation(c.Request.Context(), "sso_login", true, time.Since(start), "mfa_required")
+		return
+	}
+	h.completeLogin(c, &user, auditLog, service.SessionAuth{AMR: []string{service.AMRFederated}, SSOOrgID: orgID}, req.DeviceName, start)
+}
+
+// SAMLMetadata 组织对应的 SP 元数据，供 IdP 导入
+// @Summary SAML SP元数据
+// @Tags Console Auth
+// @Produce xml
+// @Param org_id path string true "组织ID"
+// @Success 200
+// @Router /api/v1/auth/sso/saml/metadata/{org_id} [get]
+func (h *ConsoleAuthHandler) SAMLMetadata(c *gin.Context) {
+	orgID := c.Param("org_id")
+	if _, err := h.service.GetOrgSSOConfig(orgID); err != nil {
+		JSONError(c, CodeNotFound, "组织未配置SSO")
+		return
+	}
+	sp, err := h.service.SAMLServiceProvider(orgID)
+	if err != nil {
+		JSONError(c, CodeServiceUnavailable, "未配置 security.sso.base_url")
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", sp.Metadata())
+}
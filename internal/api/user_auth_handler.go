@@ -112,14 +112,17 @@ func (h *UserAuthHandler) UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	JSONSuccess(c, gin.H{"message": "Password updated successfully"})
+	// 修改密码后其他设备上的会话全部下线，保留当前会话
+	revoked, err := h.service.RevokeConsoleSessions(user.ID, c.GetString("sessionID"), service.SessionRevokePasswordChange)
+	if err != nil {
+		logger.GetLogger().WithError(err).Error("Failed to revoke sessions after password change")
+	}
+
+	JSONSuccess(c, gin.H{"message": "Password updated successfully", "sessions_revoked": revoked})
 }
 
 // generateJWT 生成JWT令牌
 func (h *UserAuthHandler) generateJWT(user *models.User) (string, error) {
-	// 这里简化实现，实际应该使用配置的密钥
-	secret := []byte("your-jwt-secret-key")
-
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
@@ -129,8 +132,7 @@ func (h *UserAuthHandler) generateJWT(user *models.User) (string, error) {
 		"iat":     time.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secret)
+	return h.service.SignJWT(claims)
 }
 
 // recordAuditLog 记录审计日志
@@ -1,15 +1,315 @@
 package api
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
 	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
 )
 
 type WebhookHandler struct{ service *service.KYCService }
 
 func NewWebhookHandler(s *service.KYCService) *WebhookHandler { return &WebhookHandler{service: s} }
 
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	Description *string  `json:"description"` // 更新时省略则保持不变
+	Events      []string `json:"events"`
+	Status      string   `json:"status"`
+}
+
+type WebhookResponse struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Status      string    `json:"status"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func toWebhookResponse(w *models.Webhook) WebhookResponse {
+	events := []string(w.Events)
+	if events == nil {
+		events = []string{}
+	}
+	return WebhookResponse{ID: w.ID, URL: w.URL, Description: w.Description, Events: events, Status: w.Status, CreatedAt: w.CreatedAt, UpdatedAt: w.UpdatedAt}
+}
+
+func validateWebhookEvents(events []string) error {
+	for _, e := range events {
+		if e == "*" || utils.Contains(service.WebhookEventTypes, e) {
+			continue
+		}
+		return errors.New("unsupported event: " + e)
+	}
+	return nil
+}
+
+// ListWebhooks
+// @Summary List webhooks
+// @Description List webhook endpoints of current organization
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /orgs/webhooks [get]
 func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
-	JSONSuccess(c, gin.H{"items": []gin.H{}})
+	orgID := c.GetString("orgID")
+	var hooks []models.Webhook
+	if err := h.service.DB.Where("org_id = ?", orgID).Order("created_at DESC").Find(&hooks).Error; err != nil {
+		JSONError(c, CodeDatabaseError, "查询Webhook失败")
+		return
+	}
+	items := make([]WebhookResponse, 0, len(hooks))
+	for i := range hooks {
+		items = append(items, toWebhookResponse(&hooks[i]))
+	}
+	JSONSuccess(c, gin.H{"items": items, "event_types": service.WebhookEventTypes})
+}
+
+// CreateWebhook
+// @Summary Create webhook
+// @Description Register a webhook endpoint; the signing secret is only returned once
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param request body WebhookRequest true "Webhook"
+// @Success 200 {object} SuccessResponse
+// @Router /orgs/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "Invalid request body")
+		return
+	}
+	if err := service.ValidateWebhookURL(req.URL); err != nil {
+		JSONError(c, CodeInvalidParameter, err.Error())
+		return
+	}
+	if err := validateWebhookEvents(req.Events); err != nil {
+		JSONError(c, CodeInvalidParameter, err.Error())
+		return
+	}
+	if h.service.Encryptor == nil {
+		JSONError(c, CodeEncryptionError, "未配置加密密钥，无法保存签名密钥")
+		return
+	}
+	secret := service.GenerateWebhookSecret()
+	enc, err := h.service.Encryptor.Encrypt(secret)
+	if err != nil {
+		JSONError(c, CodeEncryptionError, "签名密钥加密失败")
+		return
+	}
+	hook := models.Webhook{
+		ID:        utils.GenerateID(),
+		OrgID:     c.GetString("orgID"),
+		URL:       strings.TrimSpace(req.URL),
+		SecretEnc: enc,
+		Events:    pq.StringArray(req.Events),
+		Status:    "active",
+		CreatedBy: c.GetString("userID"),
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	if err := h.service.DB.Create(&hook).Error; err != nil {
+		logger.GetLogger().WithError(err).Error("create webhook failed")
+		JSONError(c, CodeDatabaseError, "创建Webhook失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "webhook.create", "webhook", hook.ID, "success", hook.URL)
+	resp := toWebhookResponse(&hook)
+	resp.Secret = secret
+	JSONSuccess(c, resp)
+}
+
+// UpdateWebhook
+// @Summary Update webhook
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param request body WebhookRequest true "Webhook"
+// @Success 200 {object} SuccessResponse
+// @Router /orgs/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		JSONError(c, CodeInvalidParameter, "Invalid request body")
+		return
+	}
+	if req.URL != "" {
+		if err := service.ValidateWebhookURL(req.URL); err != nil {
+			JSONError(c, CodeInvalidParameter, err.Error())
+			return
+		}
+		hook.URL = strings.TrimSpace(req.URL)
+	}
+	if req.Events != nil {
+		if err := validateWebhookEvents(req.Events); err != nil {
+			JSONError(c, CodeInvalidParameter, err.Error())
+			return
+		}
+		hook.Events = pq.StringArray(req.Events)
+	}
+	if req.Status != "" {
+		if req.Status != "active" && req.Status != "disabled" {
+			JSONError(c, CodeInvalidParameter, "status must be active or disabled")
+			return
+		}
+		hook.Status = req.Status
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	if err := h.service.DB.Save(hook).Error; err != nil {
+		JSONError(c, CodeDatabaseError, "更新Webhook失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "webhook.update", "webhook", hook.ID, "success", "")
+	JSONSuccess(c, toWebhookResponse(hook))
+}
+
+// DeleteWebhook
+// @Summary Delete webhook
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} SuccessResponse
+// @Router /orgs/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	err := h.service.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hook).Error
+	})
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "删除Webhook失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "webhook.delete", "webhook", hook.ID, "success", "")
+	JSONSuccess(c, gin.H{"id": hook.ID, "deleted": true})
+}
+
+// TestWebhook
+// @Summary Send test event
+// @Description Synchronously deliver a webhook.ping event and return the delivery result
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} SuccessResponse
+// @Router /orgs/webhooks/{id}/test [post]
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	d, err := h.service.SendTestWebhook(c.Request.Context(), hook)
+	if err != nil {
+		JSONError(c, CodeDatabaseError, "测试投递失败")
+		return
+	}
+	JSONSuccess(c, d)
+}
+
+// ListDeliveries
+// @Summary List webhook deliveries
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param status query string false "pending|success|dead"
+// @Success 200 {object} PaginatedResponse
+// @Router /orgs/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	h.listDeliveries(c, h.service.DB.Where("org_id = ? AND webhook_id = ?", hook.OrgID, hook.ID), c.Query("status"))
+}
+
+// ListDeadLetters
+// @Summary List dead-lettered deliveries
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {object} PaginatedResponse
+// @Router /orgs/webhooks/dead-letters [get]
+func (h *WebhookHandler) ListDeadLetters(c *gin.Context) {
+	h.listDeliveries(c, h.service.DB.Where("org_id = ?", c.GetString("orgID")), "dead")
+}
+
+// RedeliverDelivery
+// @Summary Redeliver a delivery
+// @Description Requeue a failed or dead-lettered delivery
+// @Tags Webhooks
+// @Produce json
+// @Param delivery_id path string true "Delivery ID"
+// @Success 200 {object} SuccessResponse
+// @Router /orgs/webhooks/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookHandler) RedeliverDelivery(c *gin.Context) {
+	id := c.Param("delivery_id")
+	if err := h.service.RedeliverWebhook(c.GetString("orgID"), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			JSONError(c, CodeNotFound, "投递记录不存在或已成功")
+			return
+		}
+		JSONError(c, CodeDatabaseError, "重新投递失败")
+		return
+	}
+	h.service.RecordAuditLog(c, "webhook.redeliver", "webhook_delivery", id, "success", "")
+	JSONSuccess(c, gin.H{"id": id, "status": "pending"})
+}
+
+func (h *WebhookHandler) listDeliveries(c *gin.Context, q *gorm.DB, status string) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	q = q.Model(&models.WebhookDelivery{})
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		JSONError(c, CodeDatabaseError, "查询投递记录失败")
+		return
+	}
+	var items []models.WebhookDelivery
+	if err := q.Order("created_at DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&items).Error; err != nil {
+		JSONError(c, CodeDatabaseError, "查询投递记录失败")
+		return
+	}
+	JSONPaginated(c, items, page, pageSize, int(total))
+}
+
+func (h *WebhookHandler) loadWebhook(c *gin.Context) (*models.Webhook, bool) {
+	var hook models.Webhook
+	if err := h.service.DB.First(&hook, "id = ? AND org_id = ?", c.Param("id"), c.GetString("orgID")).Error; err != nil {
+		JSONError(c, CodeNotFound, "Webhook不存在")
+		return nil, false
+	}
+	return &hook, true
 }
@@ -14,6 +14,9 @@ type Config struct {
 	LogLevel string `mapstructure:"log_level"`
 	UseMock  bool   `mapstructure:"use_mock"`
 
+	// MockVendor use_mock 开启时内置模拟供应商的参数
+	MockVendor MockVendorConfig `mapstructure:"mock_vendor"`
+
 	Database DatabaseConfig `mapstructure:"database"`
 	Redis    RedisConfig    `mapstructure:"redis"`
 
@@ -24,6 +27,12 @@ type Config struct {
 	Monitoring MonitoringConfig `mapstructure:"monitoring"`
 
 	Storage StorageConfig `mapstructure:"storage"`
+
+	Async AsyncConfig `mapstructure:"async"`
+
+	Retention RetentionConfig `mapstructure:"retention"`
+
+	ImagePreprocess ImagePreprocessConfig `mapstructure:"image_preprocess"`
 }
 
 type MonitoringConfig struct {
@@ -58,17 +67,148 @@ type RedisConfig struct {
 }
 
 type SecurityConfig struct {
-	JWTSecret          string        `mapstructure:"jwt_secret"`
-	JWTExpiration      time.Duration `mapstructure:"jwt_expiration"`
-	EncryptionKey      string        `mapstructure:"encryption_key"`
-	RateLimitPerSecond int           `mapstructure:"rate_limit_per_second"`
-	RateLimitBurst     int           `mapstructure:"rate_limit_burst"`
-	KongSharedSecret   string        `mapstructure:"kong_shared_secret"`
-	ServiceSecretKey   string        `mapstructure:"service_secret_key"`
+	JWTSecret          string                `mapstructure:"jwt_secret"`
+	JWTExpiration      time.Duration         `mapstructure:"jwt_expiration"`
+	EncryptionKey      string                `mapstructure:"encryption_key"`
+	RateLimitPerSecond int                   `mapstructure:"rate_limit_per_second"`
+	RateLimitBurst     int                   `mapstructure:"rate_limit_burst"`
+	KongSharedSecret   string                `mapstructure:"kong_shared_secret"`
+	ServiceSecretKey   string                `mapstructure:"service_secret_key"`
+	JWTSigning         JWTSigningConfig      `mapstructure:"jwt_signing"`
+	MFA                MFAConfig             `mapstructure:"mfa"`
+	Session            SessionConfig         `mapstructure:"session"`
+	LoginProtection    LoginProtectionConfig `mapstructure:"login_protection"`
+	SSO                SSOConfig             `mapstructure:"sso"`
+}
+
+// SSOConfig 组织级企业 SSO（OIDC/SAML）配置
+type SSOConfig struct {
+	BaseURL       string        `mapstructure:"base_url"`       // 本服务对外地址，用于生成 OIDC 回调与 SAML ACS/EntityID，也是客户端断言 aud 的来源
+	ConsoleURL    string        `mapstructure:"console_url"`    // 控制台接收登录结果的页面，IdP 回调后重定向到此地址并附带一次性 code
+	StateTTL      time.Duration `mapstructure:"state_ttl"`      // 发起登录到 IdP 回调的最长时间
+	ExchangeTTL   time.Duration `mapstructure:"exchange_ttl"`   // 一次性 code 的有效期
+	HTTPTimeout   time.Duration `mapstructure:"http_timeout"`   // 请求 IdP（发现文档、JWKS、令牌端点、元数据）的超时
+	ProviderTTL   time.Duration `mapstructure:"provider_ttl"`   // OIDC 发现文档与 JWKS 的缓存时间
+	AllowInsecure bool          `mapstructure:"allow_insecure"` // 允许 http 与内网地址的 IdP（仅用于本地 Keycloak 等测试环境）
+}
+
+// LoginProtectionConfig 登录防暴力破解：按账号递增延迟与临时锁定，按IP临时锁定
+type LoginProtectionConfig struct {
+	Window             time.Duration `mapstructure:"window"`               // 失败计数窗口
+	DelayAfter         int           `mapstructure:"delay_after"`          // 账号连续失败该次数后开始递增延迟
+	BaseDelay          time.Duration `mapstructure:"base_delay"`           // 首次延迟，之后每次失败翻倍
+	MaxDelay           time.Duration `mapstructure:"max_delay"`            // 延迟上限
+	AccountMaxFailures int           `mapstructure:"account_max_failures"` // 账号失败达到该次数后锁定
+	IPMaxFailures      int           `mapstructure:"ip_max_failures"`      // 单个IP失败达到该次数后锁定
+	LockoutDuration    time.Duration `mapstructure:"lockout_duration"`     // 首次锁定时长，再次锁定翻倍
+	MaxLockoutDuration time.Duration `mapstructure:"max_lockout_duration"` // 锁定时长上限
+}
+
+// SessionConfig 控制台会话配置：短期访问令牌 + 可轮换的刷新令牌
+type SessionConfig struct {
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`  // 访问令牌有效期
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"` // 空闲超时：超过该时长未刷新的会话失效
+	MaxLifetime     time.Duration `mapstructure:"max_lifetime"`      // 会话最长存活时间，到期必须重新登录
+}
+
+// MFAConfig 控制台多因素认证配置
+type MFAConfig struct {
+	Issuer               string        `mapstructure:"issuer"`                 // 身份验证器应用中显示的名称
+	RequirePlatformAdmin bool          `mapstructure:"require_platform_admin"` // 平台管理员必须启用MFA
+	StepUpMaxAge         time.Duration `mapstructure:"step_up_max_age"`        // 敏感操作要求的最近一次MFA验证时间
+	WebAuthnRPID         string        `mapstructure:"webauthn_rp_id"`         // 为空表示不启用 WebAuthn
+	WebAuthnRPName       string        `mapstructure:"webauthn_rp_name"`
+	WebAuthnOrigins      []string      `mapstructure:"webauthn_origins"`
+}
+
+// JWTSigningConfig 非对称JWT签名与密钥轮换配置
+type JWTSigningConfig struct {
+	Algorithm        string        `mapstructure:"algorithm"`         // RS256 或 ES256；HS256 表示继续使用 jwt_secret 签名
+	RotationInterval time.Duration `mapstructure:"rotation_interval"` // 签名密钥轮换周期
+	PublishAhead     time.Duration `mapstructure:"publish_ahead"`     // 新密钥提前发布到 JWKS 的时长，应大于下游 JWKS 缓存时间
+	OverlapWindow    time.Duration `mapstructure:"overlap_window"`    // 轮换后旧密钥继续验签的时长，应不小于最长令牌有效期
+	AcceptHMAC       bool          `mapstructure:"accept_hmac"`       // 迁移期间继续接受以 jwt_secret 签名的 HS256 令牌
 }
 
 type StorageConfig struct {
 	IngestDir string `mapstructure:"ingest_dir"`
+	// Backend 对象存储后端：local（默认，存于 IngestDir）或 s3（S3 兼容存储，可多副本部署）
+	Backend string `mapstructure:"backend"`
+	// KeyPrefix 对象键前缀模板，{org_id} 替换为组织ID
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// SignedURLTTL 签名下载地址有效期
+	SignedURLTTL time.Duration `mapstructure:"signed_url_ttl"`
+	// PublicBaseURL 本服务对外地址，local 后端据此生成签名下载地址；为空时返回相对路径
+	PublicBaseURL string `mapstructure:"public_base_url"`
+	// URLSecret local 后端签名下载地址的 HMAC 密钥，为空时使用 security.jwt_secret
+	URLSecret string `mapstructure:"url_secret"`
+	// AccelRedirectPrefix local 后端下载图片时交由 nginx 内部跳转的前缀（如 /_protected/images/），为空时由服务直接输出
+	AccelRedirectPrefix string `mapstructure:"accel_redirect_prefix"`
+	// FaceDataDir 人脸库供应商的数据目录，人脸检索结果中的图片路径须位于其下
+	FaceDataDir string                  `mapstructure:"face_data_dir"`
+	S3          S3StorageConfig         `mapstructure:"s3"`
+	Encryption  StorageEncryptionConfig `mapstructure:"encryption"`
+}
+
+// StorageEncryptionConfig 图片/视频的信封加密：每个组织一个数据密钥，数据密钥由主密钥包裹
+type StorageEncryptionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MasterKey base64 编码的 32 字节主密钥，启用加密时必填（不与 security.encryption_key 共用）
+	MasterKey string `mapstructure:"master_key"`
+}
+
+// S3StorageConfig S3 兼容存储（AWS S3、MinIO 等）
+type S3StorageConfig struct {
+	Endpoint  string        `mapstructure:"endpoint"`
+	Region    string        `mapstructure:"region"`
+	Bucket    string        `mapstructure:"bucket"`
+	AccessKey string        `mapstructure:"access_key"`
+	SecretKey string        `mapstructure:"secret_key"`
+	PathStyle bool          `mapstructure:"path_style"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+// AsyncConfig 异步KYC任务配置
+type AsyncConfig struct {
+	Workers      int           `mapstructure:"workers"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	JobTimeout   time.Duration `mapstructure:"job_timeout"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+}
+
+// RetentionConfig 数据保留与定期清理
+type RetentionConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`    // 是否定期清理超期数据
+	Interval  time.Duration `mapstructure:"interval"`   // 清理周期
+	BatchSize int           `mapstructure:"batch_size"` // 每批删除的行数
+	DryRun    bool          `mapstructure:"dry_run"`    // 只统计待清理数据、写审计，不删除
+	// Defaults 系统默认保留天数（按数据类别：raw_images、extracted_pii、results、request_logs），0 或未设置表示永久保留
+	Defaults map[string]int `mapstructure:"defaults"`
+}
+
+// ImagePreprocessConfig 发送给供应商前的图片预处理（摆正、缩小、重新编码为 JPEG 并去除元数据），按能力分别配置
+type ImagePreprocessConfig struct {
+	OCR      ImagePrepOptions `mapstructure:"ocr"`
+	Face     ImagePrepOptions `mapstructure:"face"`
+	Liveness ImagePrepOptions `mapstructure:"liveness"` // 仅静默活体，视频不做处理
+}
+
+// ImagePrepOptions 单项能力的图片预处理参数
+type ImagePrepOptions struct {
+	Enabled      bool `mapstructure:"enabled"`
+	MaxDimension int  `mapstructure:"max_dimension"` // 长边上限（像素），超过时等比缩小；0 表示不缩放
+	MinDimension int  `mapstructure:"min_dimension"` // 短边下限（像素），不足时拒绝；0 表示不检查
+	JPEGQuality  int  `mapstructure:"jpeg_quality"`  // 重新编码的 JPEG 质量 1~100
+}
+
+// MockVendorConfig 内置模拟供应商（OCR/人脸/活体）配置
+type MockVendorConfig struct {
+	Addr      string        `mapstructure:"addr"`
+	Latency   time.Duration `mapstructure:"latency"`
+	Jitter    time.Duration `mapstructure:"jitter"`
+	ErrorRate float64       `mapstructure:"error_rate"`
+	Seed      int64         `mapstructure:"seed"`
+	Fixtures  string        `mapstructure:"fixtures"` // fixture JSON 文件路径，可选
 }
 
 type ThirdPartyConfig struct {
@@ -99,6 +239,41 @@ type ThirdPartyConfig struct {
 		Timeout    int    `mapstructure:"timeout"`
 		RetryCount int    `mapstructure:"retry_count"`
 	} `mapstructure:"liveness_video"`
+
+	// Providers 按能力配置多个供应商；某能力未配置时回退到上面的单一服务地址
+	Providers ProvidersConfig `mapstructure:"providers"`
+
+	// CircuitBreaker 每个供应商独立的熔断与并发限制
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+}
+
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failure_threshold"`
+	OpenTimeout      time.Duration `mapstructure:"open_timeout"`
+	HalfOpenMaxCalls int           `mapstructure:"half_open_max_calls"`
+	MaxConcurrent    int           `mapstructure:"max_concurrent"`
+}
+
+type ProvidersConfig struct {
+	OCR      []ProviderConfig `mapstructure:"ocr"`
+	Face     []ProviderConfig `mapstructure:"face"`
+	Liveness []ProviderConfig `mapstructure:"liveness"`
+}
+
+// ProviderConfig 单个供应商配置
+type ProviderConfig struct {
+	Name          string   `mapstructure:"name"`
+	Type          string   `mapstructure:"type"` // 接口协议，默认 vrl
+	URL           string   `mapstructure:"url"`
+	VideoURL      string   `mapstructure:"video_url"` // 仅活体：动态活体地址
+	APIKey        string   `mapstructure:"api_key"`
+	Timeout       int      `mapstructure:"timeout"`
+	RetryCount    int      `mapstructure:"retry_count"`
+	Priority      int      `mapstructure:"priority"`       // 越小越优先，失败时切换到下一优先级
+	Weight        int      `mapstructure:"weight"`         // 同优先级内按权重分流，默认1
+	DocTypes      []string `mapstructure:"doc_types"`      // 仅OCR：支持的证件类型，为空表示全部
+	MaxConcurrent int      `mapstructure:"max_concurrent"` // 覆盖 circuit_breaker.max_concurrent
+	Disabled      bool     `mapstructure:"disabled"`
 }
 
 func Load(configFile string) *Config {
@@ -171,10 +346,71 @@ func setDefaults() {
 	viper.SetDefault("security.rate_limit_burst", 200)
 	viper.SetDefault("security.kong_shared_secret", "kong-shared-secret-key-2024")
 	viper.SetDefault("security.service_secret_key", "kyc-service-secret-key-2024")
+	viper.SetDefault("security.jwt_signing.algorithm", "RS256")
+	viper.SetDefault("security.jwt_signing.rotation_interval", "720h")
+	viper.SetDefault("security.jwt_signing.publish_ahead", "1h")
+	viper.SetDefault("security.jwt_signing.overlap_window", "192h")
+	viper.SetDefault("security.jwt_signing.accept_hmac", true)
+	viper.SetDefault("security.mfa.issuer", "KYC Console")
+	viper.SetDefault("security.mfa.require_platform_admin", true)
+	viper.SetDefault("security.mfa.step_up_max_age", "10m")
+	viper.SetDefault("security.mfa.webauthn_rp_name", "KYC Console")
+	viper.SetDefault("security.session.access_token_ttl", "15m")
+	viper.SetDefault("security.session.refresh_token_ttl", "168h")
+	viper.SetDefault("security.session.max_lifetime", "720h")
+	viper.SetDefault("security.login_protection.window", "15m")
+	viper.SetDefault("security.login_protection.delay_after", 3)
+	viper.SetDefault("security.login_protection.base_delay", "1s")
+	viper.SetDefault("security.login_protection.max_delay", "30s")
+	viper.SetDefault("security.login_protection.account_max_failures", 10)
+	viper.SetDefault("security.login_protection.ip_max_failures", 50)
+	viper.SetDefault("security.login_protection.lockout_duration", "15m")
+	viper.SetDefault("security.login_protection.max_lockout_duration", "24h")
+	viper.SetDefault("security.sso.state_ttl", "10m")
+	viper.SetDefault("security.sso.exchange_ttl", "1m")
+	viper.SetDefault("security.sso.http_timeout", "10s")
+	viper.SetDefault("security.sso.provider_ttl", "1h")
 
 	viper.SetDefault("storage.ingest_dir", "/data/ingest")
+	viper.SetDefault("storage.backend", "local")
+	viper.SetDefault("storage.key_prefix", "{org_id}")
+	viper.SetDefault("storage.signed_url_ttl", "5m")
+	viper.SetDefault("storage.face_data_dir", "/root/test/vrlFaceServer/vrlFace/data")
+	viper.SetDefault("storage.s3.region", "us-east-1")
+	viper.SetDefault("storage.encryption.enabled", true)
+	viper.SetDefault("storage.encryption.master_key", "") // 无默认值，仅为允许 KYC_STORAGE_ENCRYPTION_MASTER_KEY 覆盖
+	viper.SetDefault("storage.s3.timeout", "60s")
+
+	// 异步任务默认值
+	viper.SetDefault("async.workers", 4)
+	viper.SetDefault("async.max_attempts", 3)
+	viper.SetDefault("async.job_timeout", "10m")
+	viper.SetDefault("async.poll_interval", "5s")
+	viper.SetDefault("retention.enabled", true)
+	viper.SetDefault("retention.interval", "6h")
+	viper.SetDefault("retention.batch_size", 500)
+	viper.SetDefault("image_preprocess.ocr.enabled", true)
+	viper.SetDefault("image_preprocess.ocr.max_dimension", 2048)
+	viper.SetDefault("image_preprocess.ocr.min_dimension", 400)
+	viper.SetDefault("image_preprocess.ocr.jpeg_quality", 90)
+	viper.SetDefault("image_preprocess.face.enabled", true)
+	viper.SetDefault("image_preprocess.face.max_dimension", 1280)
+	viper.SetDefault("image_preprocess.face.min_dimension", 160)
+	viper.SetDefault("image_preprocess.face.jpeg_quality", 90)
+	viper.SetDefault("image_preprocess.liveness.enabled", true)
+	viper.SetDefault("image_preprocess.liveness.max_dimension", 1280)
+	viper.SetDefault("image_preprocess.liveness.min_dimension", 240)
+	viper.SetDefault("image_preprocess.liveness.jpeg_quality", 92)
+
+	// 模拟供应商默认值
+	viper.SetDefault("mock_vendor.addr", "127.0.0.1:18090")
+	viper.SetDefault("mock_vendor.seed", 1)
 
 	// 第三方服务默认值
+	viper.SetDefault("third_party.circuit_breaker.failure_threshold", 5)
+	viper.SetDefault("third_party.circuit_breaker.open_timeout", "30s")
+	viper.SetDefault("third_party.circuit_breaker.half_open_max_calls", 1)
+	viper.SetDefault("third_party.circuit_breaker.max_concurrent", 50)
 	viper.SetDefault("third_party.ocr_service.timeout", 30)
 	viper.SetDefault("third_party.ocr_service.retry_count", 3)
 	viper.SetDefault("third_party.face_service.timeout", 30)
@@ -1,5 +1,6 @@
 package config
 
+// PermissionMeta 权限目录项
 type PermissionMeta struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
@@ -7,6 +8,7 @@ type PermissionMeta struct {
 	Description string `json:"description"`
 }
 
+// PermissionList 内置权限目录，启动时写入 permissions 表；组织自定义角色只能从权限表中选取权限
 var PermissionList = []PermissionMeta{
 	{ID: "org.read", Name: "读取组织信息", Category: "Organization", Description: "读取组织基本信息"},
 	{ID: "org.update", Name: "更新组织设置", Category: "Organization", Description: "更新组织配置与设置"},
@@ -15,8 +17,13 @@ var PermissionList = []PermissionMeta{
 	{ID: "team.invite", Name: "邀请成员", Category: "Team", Description: "邀请新成员加入"},
 	{ID: "team.write", Name: "修改/删除成员", Category: "Team", Description: "修改或移除组织成员"},
 	{ID: "billing.read", Name: "查看账单", Category: "Billing", Description: "查看计费与账单"},
+	{ID: "org.billing.read", Name: "查看组织账单", Category: "Billing", Description: "查看组织级计费与账期"},
 	{ID: "billing.write", Name: "修改支付方式/订阅", Category: "Billing", Description: "修改支付方式与订阅"},
 	{ID: "keys.read", Name: "查看API Key", Category: "API Keys", Description: "查看密钥列表"},
 	{ID: "keys.write", Name: "创建/撤销 API Key", Category: "API Keys", Description: "创建或撤销密钥"},
 	{ID: "logs.read", Name: "查看审计日志", Category: "Logs", Description: "查看审计与请求日志"},
+	{ID: "org.usage.read", Name: "查看用量", Category: "Logs", Description: "查看组织用量统计"},
+	{ID: "org.audit", Name: "导出审计日志", Category: "Logs", Description: "查看审计动作并导出组织审计日志"},
+	{ID: "privacy.read", Name: "查找/导出数据主体", Category: "Privacy", Description: "按证件号、手机号或请求ID查找并导出被核验者的数据"},
+	{ID: "privacy.erase", Name: "删除数据主体", Category: "Privacy", Description: "删除被核验者的全部数据并出具删除回执"},
 }
@@ -34,6 +34,9 @@ func APIRequestLogMiddleware(kyc *service.KYCService) gin.HandlerFunc {
 			ClientIP:   ip,
 			CreatedAt:  time.Now(),
 		}
+		if rid := c.GetString("kycRequestID"); rid != "" {
+			log.KYCRequestID = &rid
+		}
 		// 非关键路径失败可忽略
 		_ = kyc.DB.Create(log).Error
 	}
@@ -174,7 +174,19 @@ func generateNonce() string {
 
 // BidirectionalHealthCheck 双向健康检查
 type BidirectionalHealthCheck struct {
-	auth *BidirectionalAuth
+	auth   *BidirectionalAuth
+	checks map[string]HealthCheckFunc
+}
+
+// HealthCheckFunc 依赖组件的健康检查：返回详情及是否健康
+type HealthCheckFunc func() (interface{}, bool)
+
+// AddCheck 注册依赖组件的健康检查，结果以 name 为键输出；任一检查不健康时整体状态为 degraded
+func (h *BidirectionalHealthCheck) AddCheck(name string, fn HealthCheckFunc) {
+	if h.checks == nil {
+		h.checks = map[string]HealthCheckFunc{}
+	}
+	h.checks[name] = fn
 }
 
 // NewBidirectionalHealthCheck 创建双向健康检查
@@ -205,6 +217,13 @@ func (h *BidirectionalHealthCheck) HealthCheckHandler(c *gin.Context) {
 		"kong_verified": true,
 		"version":       "1.0.0",
 	}
+	for name, check := range h.checks {
+		detail, ok := check()
+		healthInfo[name] = detail
+		if !ok {
+			healthInfo["status"] = "degraded"
+		}
+	}
 
 	// 生成服务响应签名
 	serviceToken := h.auth.generateServiceToken("/health", "GET")
@@ -13,6 +13,21 @@ import (
 
 // JWTAuth JWT认证中间件
 func JWTAuth(service *service.KYCService) gin.HandlerFunc {
+	return jwtAuth(service, true)
+}
+
+// JWTAuthForMFASetup 用于MFA注册、退出登录等接口：组织的 MFA/SSO 要求尚未满足时仍允许访问
+func JWTAuthForMFASetup(service *service.KYCService) gin.HandlerFunc {
+	return jwtAuth(service, false)
+}
+
+// sessionAMR 读取会话的认证方式，并判断是否已完成第二因素
+func sessionAMR(claims jwt.MapClaims) ([]string, bool) {
+	amr := service.ClaimsAMR(claims)
+	return amr, service.HasAMR(amr, service.AMRMFA)
+}
+
+func jwtAuth(service *service.KYCService, enforceOrgPolicy bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取Authorization头
 		authHeader := c.GetHeader("Authorization")
@@ -49,14 +64,8 @@ func JWTAuth(service *service.KYCService) gin.HandlerFunc {
 		tokenString := parts[1]
 
 		// 解析JWT令牌
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// 验证签名方法
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			// 返回密钥
-			return []byte(service.Config.Security.JWTSecret), nil
-		})
+		// 按 kid 选择 RS256/ES256 公钥，迁移期间兼容 HS256
+		token, err := jwt.Parse(tokenString, service.JWTKeyfunc)
 
 		if err != nil || !token.Valid {
 			c.JSON(401, gin.H{
@@ -105,6 +114,36 @@ func JWTAuth(service *service.KYCService) gin.HandlerFunc {
 			}
 		}
 
+		// OAuth 令牌（含代表用户签发的访问/刷新令牌）只能访问开放接口，不能作为控制台会话使用
+		if cid, _ := claims["client_id"].(string); cid != "" {
+			c.JSON(401, gin.H{
+				"code":       1001,
+				"message":    "未授权访问",
+				"error":      "OAuth tokens are not accepted for console sessions",
+				"timestamp":  time.Now().UnixMilli(),
+				"request_id": c.GetString("request_id"),
+				"path":       c.Request.URL.Path,
+				"method":     c.Request.Method,
+			})
+			c.Abort()
+			return
+		}
+
+		// MFA 挑战令牌只能用于完成登录第二步
+		if use, _ := claims["token_use"].(string); use != "" {
+			c.JSON(401, gin.H{
+				"code":       1001,
+				"message":    "未授权访问",
+				"error":      "Token is not a console session",
+				"timestamp":  time.Now().UnixMilli(),
+				"request_id": c.GetString("request_id"),
+				"path":       c.Request.URL.Path,
+				"method":     c.Request.Method,
+			})
+			c.Abort()
+			return
+		}
+
 		// 提取用户信息
 		userID, ok := claims["user_id"].(string)
 		if !ok {
@@ -137,42 +176,122 @@ func JWTAuth(service *service.KYCService) gin.HandlerFunc {
 			return
 		}
 
+		// 控制台访问令牌必须关联仍然有效的服务端会话（登出、远程下线、改密后立即失效）
+		sid, _ := claims["sid"].(string)
+		if sid == "" || !service.ConsoleSessionActive(sid, user.ID) {
+			c.JSON(401, gin.H{
+				"code":       1001,
+				"message":    "未授权访问",
+				"error":      "Session expired or revoked",
+				"timestamp":  time.Now().UnixMilli(),
+				"request_id": c.GetString("request_id"),
+				"path":       c.Request.URL.Path,
+				"method":     c.Request.Method,
+			})
+			c.Abort()
+			return
+		}
+
+		// 吊销检查：单个令牌（jti）以及用户/组织维度的批量吊销
+		currentOrgID := user.CurrentOrgID
+		if currentOrgID == "" {
+			currentOrgID = user.OrgID
+		}
+		// 通过组织 SSO 建立的会话固定在该组织，不受用户其他会话切换组织的影响
+		ssoOrgID, _ := claims["sso_org"].(string)
+		if ssoOrgID != "" {
+			currentOrgID = ssoOrgID
+		}
+		if service.IsTokenRevoked(c.Request.Context(), claims, currentOrgID) {
+			c.JSON(401, gin.H{
+				"code":       1001,
+				"message":    "未授权访问",
+				"error":      "Token has been revoked",
+				"timestamp":  time.Now().UnixMilli(),
+				"request_id": c.GetString("request_id"),
+				"path":       c.Request.URL.Path,
+				"method":     c.Request.Method,
+			})
+			c.Abort()
+			return
+		}
+
+		// 组织（或平台管理员）要求MFA时，未完成第二因素的会话只能访问MFA注册接口
+		amr, mfaDone := sessionAMR(claims)
+		if enforceOrgPolicy && !mfaDone && service.MFARequired(&user, currentOrgID) {
+			c.JSON(403, gin.H{
+				"code":                    1002,
+				"message":                 "需要多因素认证",
+				"error":                   "MFA required",
+				"mfa_enrollment_required": true,
+				"timestamp":               time.Now().UnixMilli(),
+				"request_id":              c.GetString("request_id"),
+				"path":                    c.Request.URL.Path,
+				"method":                  c.Request.Method,
+			})
+			c.Abort()
+			return
+		}
+
 		// 设置用户信息到上下文
 		c.Set("user", claims)
+		c.Set("amr", amr)
+		c.Set("sessionID", sid)
 		c.Set("userID", user.ID)
 		c.Set("userEmail", user.Email)
 		c.Set("userRole", user.Role)
-		// 解析当前组织上下文：优先 CurrentOrgID，否则回退到用户OrgID
-		currentOrgID := user.CurrentOrgID
-		if currentOrgID == "" {
-			currentOrgID = user.OrgID
-		}
+		// 当前组织上下文：优先 CurrentOrgID，否则回退到用户OrgID
 		c.Set("orgID", currentOrgID)
 		// 从成员表解析组织角色
 		var member models.OrganizationMember
 		if currentOrgID != "" {
 			_ = service.DB.Where("organization_id = ? AND user_id = ? AND status = ?", currentOrgID, user.ID, "active").First(&member).Error
 		}
+		if ssoOrgID != "" && member.ID == "" {
+			c.JSON(401, gin.H{
+				"code":       1001,
+				"message":    "未授权访问",
+				"error":      "SSO organization membership inactive",
+				"timestamp":  time.Now().UnixMilli(),
+				"request_id": c.GetString("request_id"),
+				"path":       c.Request.URL.Path,
+				"method":     c.Request.Method,
+			})
+			c.Abort()
+			return
+		}
 		orgRole := member.Role
 		if orgRole == "" {
 			orgRole = user.OrgRole
 		}
+
+		// 组织开启强制 SSO 后，非该组织 SSO 建立的会话不能访问（所有者除外）
+		if enforceOrgPolicy && ssoOrgID == "" && service.SSORequiredFor(currentOrgID, orgRole) {
+			c.JSON(403, gin.H{
+				"code":         1002,
+				"message":      "该组织要求通过企业 SSO 登录",
+				"error":        "SSO required",
+				"sso_required": true,
+				"org_id":       currentOrgID,
+				"timestamp":    time.Now().UnixMilli(),
+				"request_id":   c.GetString("request_id"),
+				"path":         c.Request.URL.Path,
+				"method":       c.Request.Method,
+			})
+			c.Abort()
+			return
+		}
 		c.Set("orgRole", orgRole)
 		c.Set("isPlatformAdmin", user.IsPlatformAdmin)
-		if user.CurrentOrgID != "" {
+		if ssoOrgID != "" {
+			c.Set("currentOrgID", ssoOrgID)
+			c.Set("ssoOrgID", ssoOrgID)
+		} else if user.CurrentOrgID != "" {
 			c.Set("currentOrgID", user.CurrentOrgID)
 		}
 
-		// 加载角色权限列表
-		var permIDs []string
-		type rp struct{ PermissionID string }
-		var rows []rp
-		if err := service.DB.Table("role_permissions").Select("permission_id").Where("role_id = ?", orgRole).Scan(&rows).Error; err == nil {
-			for _, r := range rows {
-				permIDs = append(permIDs, r.PermissionID)
-			}
-		}
-		c.Set("permissions", permIDs)
+		// 加载角色权限列表（带缓存，角色变更时失效）
+		c.Set("permissions", service.RolePermissions(c.Request.Context(), currentOrgID, orgRole))
 
 		c.Next()
 	}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"kyc-service/internal/models"
+	"kyc-service/internal/service"
 	"kyc-service/internal/storage"
 	"kyc-service/pkg/logger"
 	"kyc-service/pkg/metrics"
@@ -281,7 +282,10 @@ func Quota(redisClient *redis.Client, svc interface {
 		} else if strings.Contains(p, "/kyc/verify") {
 			serviceType = "kyc"
 		}
-		type Q struct{ Allocation, Consumed int }
+		type Q struct {
+			Allocation, Consumed, HardLimit int
+			OverageEnabled                  bool
+		}
 		var q Q
 		ctx := context.Background()
 		if redisClient != nil {
@@ -291,7 +295,7 @@ func Quota(redisClient *redis.Client, svc interface {
 			}
 		}
 		if q.Allocation == 0 && q.Consumed == 0 {
-			_ = storage.GetDB().Raw("SELECT allocation, consumed FROM organization_quotas WHERE organization_id = ? AND service_type = ?", orgID, serviceType).Scan(&q).Error
+			_ = storage.GetDB().Raw("SELECT allocation, consumed, overage_enabled, hard_limit FROM organization_quotas WHERE organization_id = ? AND service_type = ?", orgID, serviceType).Scan(&q).Error
 		}
 		if q.Allocation == 0 {
 			var raw string
@@ -313,7 +317,12 @@ func Quota(redisClient *redis.Client, svc interface {
 		}
 		remaining := q.Allocation - q.Consumed
 		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", q.Allocation))
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", max(remaining, 0)))
+		if q.OverageEnabled && remaining <= 0 {
+			// 超额计费：额度用完后继续服务，直到组织设置的硬上限
+			c.Header("X-Quota-Overage", fmt.Sprintf("%d", -remaining))
+			remaining = service.QuotaCeiling(models.OrganizationQuotas{Allocation: q.Allocation, OverageEnabled: true, HardLimit: q.HardLimit}) - q.Consumed
+		}
 		if remaining <= 0 {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "QUOTA_EXHAUSTED"})
 			c.Abort()
@@ -29,12 +29,7 @@ func OAuth2ClientAuth(svc *service.KYCService) gin.HandlerFunc {
 		}
 		tokenString := parts[1]
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(svc.Config.Security.JWTSecret), nil
-		})
+		token, err := jwt.Parse(tokenString, svc.JWTKeyfunc)
 		if err != nil || !token.Valid {
 			c.Next()
 			return
@@ -54,7 +49,7 @@ func OAuth2ClientAuth(svc *service.KYCService) gin.HandlerFunc {
 		clientID, _ := claims["client_id"].(string)
 		scopeStr, _ := claims["scope"].(string)
 		claimOrgID, _ := claims["org_id"].(string)
-		if clientID == "" {
+		if clientID == "" || !oauthAccessTokenUsable(c, svc, claims) {
 			c.Next()
 			return
 		}
@@ -104,7 +99,7 @@ func APIOrOAuthAuth(svc *service.KYCService) gin.HandlerFunc {
 		credential := parts[1]
 
 		// 尝试作为OAuth2访问令牌解析
-		if tok, err := jwt.Parse(credential, func(token *jwt.Token) (interface{}, error) { return []byte(svc.Config.Security.JWTSecret), nil }); err == nil && tok.Valid {
+		if tok, err := jwt.Parse(credential, svc.JWTKeyfunc); err == nil && tok.Valid {
 			if claims, ok := tok.Claims.(jwt.MapClaims); ok {
 				// 过期校验
 				if v, ok := claims["exp"].(float64); ok && time.Now().Unix() > int64(v) {
@@ -120,6 +115,15 @@ func APIOrOAuthAuth(svc *service.KYCService) gin.HandlerFunc {
 					c.Abort()
 					return
 				}
+				if !oauthAccessTokenUsable(c, svc, claims) {
+					c.JSON(401, gin.H{"error": "token revoked"})
+					c.Abort()
+					return
+				}
+				if uid, _ := claims["user_id"].(string); uid != "" {
+					// 授权码模式：合作方应用代表控制台用户调用
+					c.Set("userID", uid)
+				}
 				var client models.OAuthClient
 				if err := svc.DB.Where("id = ? AND status = ?", clientID, "active").First(&client).Error; err != nil {
 					c.JSON(401, gin.H{"error": "invalid client"})
@@ -219,3 +223,15 @@ func APIOrOAuthAuth(svc *service.KYCService) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// oauthAccessTokenUsable 拒绝刷新令牌、吊销列表中的令牌及已被吊销令牌族中的访问令牌
+func oauthAccessTokenUsable(c *gin.Context, svc *service.KYCService, claims jwt.MapClaims) bool {
+	if use, _ := claims["token_use"].(string); use == service.OAuthTokenUseRefresh {
+		return false
+	}
+	if svc.IsTokenRevoked(c.Request.Context(), claims) {
+		return false
+	}
+	fid, _ := claims["fid"].(string)
+	return !svc.IsOAuthFamilyRevoked(c.Request.Context(), fid)
+}
@@ -102,11 +102,13 @@ func RequireOrganizationHeader(svc *service.KYCService) gin.HandlerFunc {
 		}
 
 		c.Set("orgID", orgID)
+		orgRole := member.Role
 		if user.IsPlatformAdmin {
-			c.Set("orgRole", "owner")
-		} else {
-			c.Set("orgRole", member.Role)
+			orgRole = "owner"
 		}
+		c.Set("orgRole", orgRole)
+		// 按请求头指定的组织重新解析权限，与 JWT 中的当前组织无关
+		c.Set("permissions", svc.RolePermissions(c.Request.Context(), orgID, orgRole))
 		c.Next()
 	}
 }
@@ -120,6 +120,9 @@ func RequestLogger(service *service.KYCService) gin.HandlerFunc {
 				ResponseBody:  responseBody,
 				CreatedAt:     time.Now(),
 			}
+			if rid := c.GetString("kycRequestID"); rid != "" {
+				log.KYCRequestID = &rid
+			}
 
 			// 保存到数据库
 			if err := service.DB.Create(log).Error; err != nil {
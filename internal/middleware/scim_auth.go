@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"kyc-service/internal/models"
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/scim"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AbortSCIM 以 SCIM 错误格式结束请求
+func AbortSCIM(c *gin.Context, e *scim.Error) {
+	body, _ := json.Marshal(e)
+	c.Data(e.HTTPStatus(), scim.ContentType, body)
+	c.Abort()
+}
+
+// SCIMAuth SCIM 接口认证：Bearer 为组织的 SCIM 令牌，通过后设置 orgID 与 scimTokenID
+func SCIMAuth(svc *service.KYCService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if len(authHeader) < 7 || !strings.EqualFold(authHeader[:7], "Bearer ") {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			AbortSCIM(c, scim.NewError(401, "", "missing bearer token"))
+			return
+		}
+		tok, err := svc.AuthenticateSCIMToken(strings.TrimSpace(authHeader[7:]), c.ClientIP())
+		if err != nil {
+			if !errors.Is(err, service.ErrSCIMTokenInvalid) {
+				logger.GetLogger().WithError(err).Error("SCIM令牌校验失败")
+				AbortSCIM(c, scim.NewError(500, "", "internal error"))
+				return
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+			AbortSCIM(c, scim.NewError(401, "", "invalid token"))
+			return
+		}
+		var n int64
+		if err := svc.DB.Model(&models.Organization{}).Where("id = ?", tok.OrgID).Count(&n).Error; err != nil || n == 0 {
+			AbortSCIM(c, scim.NewError(401, "", "organization not found"))
+			return
+		}
+		c.Set("orgID", tok.OrgID)
+		c.Set("scimTokenID", tok.ID)
+		c.Next()
+	}
+}
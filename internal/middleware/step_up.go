@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"time"
+
+	"kyc-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RequireStepUp 敏感操作要求最近完成过第二因素验证（需在 JWTAuth 之后使用）
+func RequireStepUp(svc *service.KYCService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, _ := c.Get("user")
+		mc, _ := claims.(jwt.MapClaims)
+		if !service.StepUpFresh(mc, svc.StepUpMaxAge(), time.Now()) {
+			c.JSON(401, gin.H{
+				"code":             1001,
+				"message":          "需要重新进行多因素验证",
+				"error":            "Step-up authentication required",
+				"step_up_required": true,
+				"timestamp":        time.Now().UnixMilli(),
+				"request_id":       c.GetString("request_id"),
+				"path":             c.Request.URL.Path,
+				"method":           c.Request.Method,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
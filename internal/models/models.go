@@ -12,12 +12,14 @@ import (
 type KYCRequest struct {
 	ID           string         `gorm:"primaryKey" json:"id"`
 	UserID       string         `gorm:"index" json:"user_id"`
+	OrgID        string         `gorm:"index" json:"org_id"`
 	RequestType  string         `json:"request_type"`   // ocr, face, liveness, complete
 	Status       string         `json:"status"`         // pending, processing, success, failed
 	IDCardHash   string         `gorm:"index" json:"-"` // 身份证号哈希，用于索引
 	IDCard       string         `json:"-"`              // 加密的身份证号
 	Name         string         `json:"-"`              // 加密的姓名
 	Phone        string         `json:"-"`              // 加密的手机号
+	PhoneHash    string         `gorm:"index" json:"-"` // 手机号哈希，用于数据主体查找
 	FaceImage    string         `json:"-"`              // 人脸图片URL
 	IDCardImage  string         `json:"-"`              // 身份证图片URL
 	LivenessData string         `json:"-"`              // 活体检测数据
@@ -25,6 +27,7 @@ type KYCRequest struct {
 	ErrorMessage string         `json:"error_message,omitempty"`
 	IPAddress    string         `json:"ip_address"`
 	UserAgent    string         `json:"user_agent"`
+	PIIPurgedAt  *time.Time     `gorm:"index" json:"pii_purged_at,omitempty"` // 保留期满后已清除个人信息的时间
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
@@ -67,24 +70,83 @@ type User struct {
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 	Organization    Organization   `gorm:"foreignKey:OrgID" json:"organization,omitempty"`
 	IsPlatformAdmin bool           `gorm:"default:false" json:"is_platform_admin"`
+	TOTPSecretEnc   string         `json:"-"` // 加密保存的TOTP密钥，TOTPEnabled 为 false 时表示待确认
+	TOTPEnabled     bool           `gorm:"default:false" json:"totp_enabled"`
+}
+
+// MFARecoveryCode MFA恢复码（仅保存哈希，一次性使用）
+type MFARecoveryCode struct {
+	ID        string     `gorm:"primaryKey" json:"id"`
+	UserID    string     `gorm:"index" json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// WebAuthnCredential 用户注册的安全密钥
+type WebAuthnCredential struct {
+	ID         string     `gorm:"primaryKey" json:"id"` // credential id（base64url）
+	UserID     string     `gorm:"index" json:"user_id"`
+	Name       string     `json:"name"`
+	PublicKey  string     `gorm:"type:text" json:"-"` // PKIX PEM
+	Algorithm  int        `json:"algorithm"`
+	SignCount  uint32     `json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// MFAChallengeUse 未部署 Redis 时记录MFA挑战令牌的尝试次数与使用状态
+type MFAChallengeUse struct {
+	JTI        string     `gorm:"primaryKey" json:"jti"`
+	UserID     string     `gorm:"index" json:"user_id"`
+	Attempts   int        `gorm:"default:0" json:"attempts"`
+	ConsumedAt *time.Time `json:"consumed_at"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ConsoleSession 控制台登录会话（服务端保存，访问令牌通过 sid 关联）
+type ConsoleSession struct {
+	ID                  string     `gorm:"primaryKey" json:"id"`
+	UserID              string     `gorm:"index" json:"user_id"`
+	RefreshHash         string     `gorm:"index" json:"-"`
+	PreviousRefreshHash string     `gorm:"index" json:"-"` // 上一个刷新令牌，用于发现重放
+	AMR                 string     `json:"amr"`            // 逗号分隔的认证方式
+	MFAAt               *time.Time `json:"mfa_at,omitempty"`
+	SSOOrgID            string     `json:"sso_org_id,omitempty"` // 通过组织 SSO 登录时绑定该组织
+	DeviceName          string     `json:"device_name"`
+	IP                  string     `json:"ip"`
+	UserAgent           string     `json:"user_agent"`
+	CreatedAt           time.Time  `json:"created_at"`
+	LastSeenAt          time.Time  `json:"last_seen_at"`
+	ExpiresAt           time.Time  `gorm:"index" json:"expires_at"` // 空闲超时，每次刷新顺延
+	MaxExpiresAt        time.Time  `json:"max_expires_at"`
+	RevokedAt           *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokeReason        string     `json:"revoke_reason,omitempty"`
 }
 
 // OAuthClient OAuth客户端
 type OAuthClient struct {
-	ID              string         `gorm:"primaryKey" json:"id"`
-	Secret          string         `json:"-"`
-	Name            string         `json:"name"`
-	Description     string         `json:"description"`
-	RedirectURI     string         `json:"redirect_uri"`
-	Scopes          string         `json:"scopes"`
-	Status          string         `json:"status"`
-	OrgID           string         `json:"org_id"`
-	OwnerID         string         `json:"owner_id"`
-	TokenTTLSeconds int            `json:"token_ttl_seconds"`
-	IPWhitelist     pq.StringArray `gorm:"type:text[]" json:"ip_whitelist"`
-	RateLimitPerSec int            `json:"rate_limit_per_sec"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
+	ID                      string         `gorm:"primaryKey" json:"id"`
+	Secret                  string         `json:"-"` // 已废弃：旧版明文密钥，启动时迁移为哈希后清空
+	SecretHash              string         `gorm:"index" json:"-"`
+	SecretEnc               string         `json:"-"`
+	PreviousSecretHash      string         `json:"-"`                                                            // 轮换前的密钥哈希，宽限期内仍可使用
+	PreviousSecretExpiresAt *time.Time     `json:"previous_secret_expires_at"`                                   // 旧密钥宽限期截止时间
+	AuthMethod              string         `gorm:"default:client_secret_post" json:"token_endpoint_auth_method"` // client_secret_post | none | private_key_jwt
+	JWKS                    string         `gorm:"type:text" json:"jwks,omitempty"`                              // private_key_jwt 登记的 JWK Set 或 PEM 公钥
+	Name                    string         `json:"name"`
+	Description             string         `json:"description"`
+	RedirectURI             string         `json:"redirect_uri"`
+	Scopes                  string         `json:"scopes"`
+	Status                  string         `json:"status"`
+	OrgID                   string         `json:"org_id"`
+	OwnerID                 string         `json:"owner_id"`
+	TokenTTLSeconds         int            `json:"token_ttl_seconds"`
+	IPWhitelist             pq.StringArray `gorm:"type:text[]" json:"ip_whitelist"`
+	RateLimitPerSec         int            `json:"rate_limit_per_sec"`
+	CreatedAt               time.Time      `json:"created_at"`
+	UpdatedAt               time.Time      `json:"updated_at"`
 }
 
 // Organization 组织/租户表
@@ -97,6 +159,8 @@ type Organization struct {
 	Status           string         `json:"status"`
 	OwnerID          string         `json:"owner_id"`
 	UsageSummary     datatypes.JSON `gorm:"type:jsonb" json:"usage_summary,omitempty"`
+	Timezone         string         `json:"timezone,omitempty"` // IANA 时区，计费周期按该时区切分；为空使用服务器时区
+	RequireMFA       bool           `gorm:"default:false" json:"require_mfa"`
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
 }
@@ -106,12 +170,79 @@ type OrganizationMember struct {
 	ID             string    `gorm:"primaryKey" json:"id"`
 	OrganizationID string    `gorm:"index" json:"org_id"`
 	UserID         string    `gorm:"index" json:"user_id"`
-	Role           string    `json:"role"`   // owner, admin, developer, viewer
-	Status         string    `json:"status"` // active, pending
+	Role           string    `json:"role"`                                    // owner, admin, developer, viewer
+	Status         string    `json:"status"`                                  // active, pending
+	SCIMExternalID string    `gorm:"index" json:"scim_external_id,omitempty"` // IdP 通过 SCIM 开通时的 externalId
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+// OrgSSOConfig 组织的企业 SSO 配置（每个组织一条）
+type OrgSSOConfig struct {
+	ID                  string    `gorm:"primaryKey" json:"id"`
+	OrgID               string    `gorm:"uniqueIndex" json:"org_id"`
+	Protocol            string    `json:"protocol"` // oidc, saml
+	Enabled             bool      `gorm:"default:false" json:"enabled"`
+	OIDCIssuer          string    `json:"oidc_issuer,omitempty"` // issuer 或发现文档地址
+	OIDCClientID        string    `json:"oidc_client_id,omitempty"`
+	OIDCClientSecretEnc string    `json:"-"`
+	SAMLMetadataURL     string    `json:"saml_metadata_url,omitempty"`
+	SAMLMetadata        string    `gorm:"type:text" json:"-"`               // IdP 元数据原文；通过 URL 配置时在保存时拉取
+	SAMLIdPEntityID     string    `json:"saml_idp_entity_id,omitempty"`     // 解析自元数据
+	AllowedDomains      string    `json:"allowed_domains"`                  // 逗号分隔的邮箱域名（小写）
+	DefaultRole         string    `json:"default_role"`                     // 即时开通成员的角色
+	JITProvisioning     bool      `json:"jit_provisioning"`                 // 首次登录时自动创建用户并加入组织
+	EnforceSSO          bool      `gorm:"default:false" json:"enforce_sso"` // 成员只能通过 SSO 访问该组织（所有者除外，作为应急入口）
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// OrgDomain 组织认领的邮箱域名；在 DNS 发布 TXT 记录验证所有权后才用于按邮箱路由 SSO、即时开通与 SCIM 关联已有账号。
+// 多个组织可同时认领同一域名，但只有一个组织能完成验证
+type OrgDomain struct {
+	ID         string     `gorm:"primaryKey" json:"id"`
+	OrgID      string     `gorm:"uniqueIndex:idx_org_domains_org_domain" json:"org_id"`
+	Domain     string     `gorm:"uniqueIndex:idx_org_domains_org_domain;uniqueIndex:idx_org_domains_verified,where:verified_at IS NOT NULL" json:"domain"`
+	Token      string     `json:"-"` // TXT 记录中的验证值
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// SCIMToken 组织的 SCIM 访问令牌（仅保存哈希）
+type SCIMToken struct {
+	ID         string     `gorm:"primaryKey" json:"id"`
+	OrgID      string     `gorm:"index" json:"org_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	Prefix     string     `json:"prefix"`
+	CreatedBy  string     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// SCIMGroup IdP 通过 SCIM 推送的组；Role 为组织管理员配置的映射角色，为空表示不影响成员角色
+type SCIMGroup struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	OrgID       string    `gorm:"index" json:"org_id"`
+	DisplayName string    `json:"display_name"`
+	ExternalID  string    `gorm:"index" json:"external_id,omitempty"`
+	Role        string    `json:"role,omitempty"` // admin, developer, viewer
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SCIMGroupMember SCIM 组成员关系
+type SCIMGroupMember struct {
+	GroupID   string    `gorm:"primaryKey" json:"group_id"`
+	UserID    string    `gorm:"primaryKey;index" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // APIKey API密钥表
 type APIKey struct {
 	ID              string         `gorm:"primaryKey" json:"id"`
@@ -151,14 +282,55 @@ type UsageLog struct {
 
 // OAuthToken OAuth令牌
 type OAuthToken struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	AccessToken  string    `gorm:"uniqueIndex" json:"-"`
-	RefreshToken string    `gorm:"uniqueIndex" json:"-"`
-	UserID       string    `json:"user_id"`
-	ClientID     string    `json:"client_id"`
-	Scopes       string    `json:"scopes"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	AccessToken      string     `gorm:"uniqueIndex" json:"-"`
+	RefreshToken     string     `gorm:"uniqueIndex" json:"-"`
+	UserID           string     `json:"user_id"` // 授权码模式下代表的控制台用户，客户端凭证模式为空
+	OrgID            string     `json:"org_id"`
+	ClientID         string     `json:"client_id"`
+	Scopes           string     `json:"scopes"`
+	FamilyID         string     `gorm:"index" json:"family_id"` // 同一次授权经刷新轮换产生的令牌属于同一族
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
+	RotatedAt        *time.Time `json:"rotated_at,omitempty"` // 刷新令牌已被使用（轮换）的时间
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// TokenRevocation 令牌吊销记录，与 Redis 吊销列表同键（revoked:jti:<jti> / revoked:<client|user|org>:<id>）；
+// Redis 未配置或不可用时认证中间件据此判断
+type TokenRevocation struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	Watermark int64     `json:"watermark,omitempty"` // 批量吊销：在此时间（unix 秒）及之前签发的令牌无效；单令牌吊销为 0
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OAuthAuthorizationCode 授权码（仅保存哈希），绑定 PKCE challenge 与 redirect_uri，一次性使用
+type OAuthAuthorizationCode struct {
+	CodeHash            string     `gorm:"primaryKey" json:"-"`
+	ClientID            string     `gorm:"index" json:"client_id"`
+	UserID              string     `json:"user_id"`
+	OrgID               string     `json:"org_id"`
+	RedirectURI         string     `json:"redirect_uri"`
+	Scopes              string     `json:"scopes"`
+	CodeChallenge       string     `json:"-"`
+	CodeChallengeMethod string     `json:"code_challenge_method"`
+	FamilyID            string     `json:"family_id"`
+	ExpiresAt           time.Time  `json:"expires_at"`
+	UsedAt              *time.Time `json:"used_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// JWTSigningKey JWT签名密钥（私钥以 EncryptionKey 加密保存），kid 为主键
+type JWTSigningKey struct {
+	ID            string     `gorm:"primaryKey" json:"kid"`
+	Algorithm     string     `json:"algorithm"`
+	PrivateKeyEnc string     `json:"-"`
+	PublicKey     string     `json:"public_key"`
+	ActivatesAt   time.Time  `gorm:"index" json:"activates_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
 }
 
 // PasswordReset 密码重置表
@@ -194,12 +366,14 @@ type Permission struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
-// Role 角色定义
+// Role 角色定义；OrgID 为空的是全局角色，非空的是该组织自定义的角色
 type Role struct {
 	ID          string    `gorm:"primaryKey" json:"id"`
+	OrgID       string    `gorm:"index" json:"org_id,omitempty"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	IsSystem    bool      `gorm:"default:false" json:"is_system"`
+	CreatedBy   string    `json:"created_by,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -223,6 +397,7 @@ type APIRequestLog struct {
 	StatusCode    int            `gorm:"not null" json:"status_code"`
 	LatencyMs     int            `gorm:"not null" json:"latency_ms"`
 	ClientIP      string         `gorm:"index" json:"client_ip"`
+	KYCRequestID  *string        `gorm:"index" json:"kyc_request_id,omitempty"` // 请求涉及的KYC请求，用于数据主体导出与删除
 	RequestBody   datatypes.JSON `gorm:"type:jsonb" json:"request_body,omitempty"`
 	ResponseBody  datatypes.JSON `gorm:"type:jsonb" json:"response_body,omitempty"`
 	CreatedAt     time.Time      `json:"created_at"`
@@ -265,8 +440,10 @@ type Plan struct {
 	ID          string         `gorm:"primaryKey" json:"id"`
 	Name        string         `json:"name"`
 	QuotaConfig datatypes.JSON `gorm:"type:jsonb" json:"quota_config"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
+	// RetentionConfig 套餐默认数据保留天数，如 {"raw_images":30,"request_logs":90}
+	RetentionConfig datatypes.JSON `gorm:"type:jsonb" json:"retention_config"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
 }
 
 type GlobalConfig struct {
@@ -281,30 +458,100 @@ type OrganizationQuotas struct {
 	ServiceType    string     `gorm:"index" json:"service_type"`
 	Allocation     int        `json:"allocation"`
 	Consumed       int        `json:"consumed"`
+	BaseAllocation int        `json:"base_allocation"`  // 套餐额度，allocation = base_allocation + rolled_over
+	RolledOver     int        `json:"rolled_over"`      // 上一周期结转到本周期的额度
+	Period         string     `json:"period,omitempty"` // daily, weekly, monthly, annual, lifetime
+	Rollover       bool       `json:"rollover"`
+	RolloverCap    int        `json:"rollover_cap"`
+	OverageEnabled bool       `json:"overage_enabled"` // 超出 allocation 后继续服务并计入 overage
+	HardLimit      int        `json:"hard_limit"`      // 开启超额时的硬上限（当期总用量），<=0 表示不限
+	Overage        int        `json:"overage"`         // 当期超额计费用量
+	PeriodStartAt  *time.Time `json:"period_start_at,omitempty"`
 	ResetAt        *time.Time `json:"reset_at,omitempty"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
-type ImageAsset struct {
+// QuotaPeriodHistory 已关闭的计费周期快照，供财务按周期与账单对账
+type QuotaPeriodHistory struct {
 	ID             string    `gorm:"primaryKey" json:"id"`
-	OrganizationID string    `gorm:"index" json:"organization_id"`
-	Hash           string    `gorm:"uniqueIndex" json:"hash"`
-	FilePath       string    `json:"file_path"`
-	SafeFilename   string    `json:"safe_filename"`
-	ContentType    string    `json:"content_type"`
-	SizeBytes      int64     `json:"size_bytes"`
-	CreatedAt      time.Time `json:"created_at"`
+	OrganizationID string    `gorm:"index:idx_quota_period_org_service" json:"organization_id"`
+	ServiceType    string    `gorm:"index:idx_quota_period_org_service" json:"service_type"`
+	Period         string    `json:"period"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `gorm:"index" json:"period_end"`
+	BaseAllocation int       `json:"base_allocation"`
+	RolledOverIn   int       `json:"rolled_over_in"`
+	Allocation     int       `json:"allocation"`
+	Consumed       int       `json:"consumed"`
+	Overage        int       `json:"overage"`
+	RolledOverOut  int       `json:"rolled_over_out"`
+	ClosedAt       time.Time `json:"closed_at"`
 }
 
-type VideoAsset struct {
+// QuotaLedgerEntry 配额流水（只追加）：reserve 预占 +1，commit 确认 0，refund 退还 -1，adjust 为重置/同步产生的差额
+type QuotaLedgerEntry struct {
 	ID             string    `gorm:"primaryKey" json:"id"`
-	OrganizationID string    `gorm:"index" json:"organization_id"`
-	Hash           string    `gorm:"uniqueIndex" json:"hash"`
-	FilePath       string    `json:"file_path"`
-	SafeFilename   string    `json:"safe_filename"`
-	ContentType    string    `json:"content_type"`
-	SizeBytes      int64     `json:"size_bytes"`
-	CreatedAt      time.Time `json:"created_at"`
+	ReservationID  string    `gorm:"uniqueIndex:idx_quota_ledger_reservation_entry" json:"reservation_id"`
+	EntryType      string    `gorm:"uniqueIndex:idx_quota_ledger_reservation_entry" json:"entry_type"`
+	OrganizationID string    `gorm:"index:idx_quota_ledger_org_service" json:"organization_id"`
+	ServiceType    string    `gorm:"index:idx_quota_ledger_org_service" json:"service_type"`
+	RequestID      string    `gorm:"index" json:"request_id"`
+	Amount         int       `json:"amount"`
+	Reason         string    `json:"reason,omitempty"`
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+}
+
+type ImageAsset struct {
+	ID             string     `gorm:"primaryKey" json:"id"`
+	OrganizationID string     `gorm:"index" json:"organization_id"`
+	Hash           string     `gorm:"uniqueIndex" json:"hash"`
+	StorageKey     string     `gorm:"index" json:"storage_key"`           // 对象存储中的键，早期记录为空
+	FilePath       string     `json:"file_path"`                          // 本地存储时的文件路径
+	DataKeyID      string     `gorm:"index" json:"data_key_id,omitempty"` // 加密所用的组织数据密钥，明文存储时为空
+	SafeFilename   string     `json:"safe_filename"`
+	ContentType    string     `json:"content_type"`
+	SizeBytes      int64      `json:"size_bytes"`
+	LastUsedAt     *time.Time `gorm:"index" json:"last_used_at,omitempty"` // 最近一次入库（含去重命中）的时间，保留期按此计算；早期记录为空时按 CreatedAt
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type VideoAsset struct {
+	ID             string     `gorm:"primaryKey" json:"id"`
+	OrganizationID string     `gorm:"index" json:"organization_id"`
+	Hash           string     `gorm:"uniqueIndex" json:"hash"`
+	StorageKey     string     `gorm:"index" json:"storage_key"`           // 对象存储中的键，早期记录为空
+	FilePath       string     `json:"file_path"`                          // 本地存储时的文件路径
+	DataKeyID      string     `gorm:"index" json:"data_key_id,omitempty"` // 加密所用的组织数据密钥，明文存储时为空
+	SafeFilename   string     `json:"safe_filename"`
+	ContentType    string     `json:"content_type"`
+	SizeBytes      int64      `json:"size_bytes"`
+	RequestID      string     `gorm:"index" json:"request_id,omitempty"`   // 最近一次使用该视频的KYC请求（动作活体为会话ID）
+	LastUsedAt     *time.Time `gorm:"index" json:"last_used_at,omitempty"` // 最近一次入库（含去重命中）的时间，保留期按此计算；早期记录为空时按 CreatedAt
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// OrgDataKey 组织数据密钥，用于加密该组织的图片/视频；以主密钥包裹后存储。
+// 销毁（清空 WrappedKey）后，用它加密的文件全部无法解密
+type OrgDataKey struct {
+	ID             string     `gorm:"primaryKey" json:"id"`
+	OrganizationID string     `gorm:"index" json:"organization_id"`
+	WrappedKey     string     `json:"-"`
+	MasterKeyID    string     `json:"master_key_id"` // 包裹所用主密钥的指纹
+	CreatedAt      time.Time  `json:"created_at"`
+	DestroyedAt    *time.Time `gorm:"index" json:"destroyed_at,omitempty"`
+	DestroyedBy    string     `json:"destroyed_by,omitempty"`
+}
+
+// DataSubjectRequest 数据主体权利请求（导出/删除）的记录，Signature 为签名的导出清单或删除回执
+type DataSubjectRequest struct {
+	ID         string         `gorm:"primaryKey" json:"id"`
+	OrgID      string         `gorm:"index" json:"org_id"`
+	Action     string         `json:"action"`                    // export, erase
+	SubjectRef string         `gorm:"index" json:"subject_ref"`  // 查找条件的哈希，不含原始证件号/手机号
+	Summary    datatypes.JSON `gorm:"type:jsonb" json:"summary"` // 涉及的请求、图片、任务ID及数量
+	Signature  string         `json:"signature"`
+	CreatedBy  string         `json:"created_by"`
+	CreatedAt  time.Time      `json:"created_at"`
 }
 
 type FaceImageRef struct {
@@ -312,5 +559,59 @@ type FaceImageRef struct {
 	OrganizationID string    `gorm:"index" json:"organization_id"`
 	FilePath       string    `json:"file_path"`
 	SafeFilename   string    `json:"safe_filename"`
+	RequestID      string    `gorm:"index" json:"request_id,omitempty"` // 产生该引用的人脸搜索请求
 	CreatedAt      time.Time `json:"created_at"`
 }
+
+// Webhook 组织配置的出站Webhook端点
+type Webhook struct {
+	ID          string         `gorm:"primaryKey" json:"id"`
+	OrgID       string         `gorm:"index" json:"org_id"`
+	URL         string         `json:"url"`
+	Description string         `json:"description"`
+	SecretEnc   string         `json:"-"`
+	Events      pq.StringArray `gorm:"type:text[]" json:"events"` // 订阅事件，空表示全部
+	Status      string         `json:"status"`                    // active, disabled
+	CreatedBy   string         `json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// WebhookDelivery Webhook投递记录（含重试与死信）
+type WebhookDelivery struct {
+	ID             string         `gorm:"primaryKey" json:"id"`
+	WebhookID      string         `gorm:"index" json:"webhook_id"`
+	OrgID          string         `gorm:"index" json:"org_id"`
+	EventID        string         `gorm:"index" json:"event_id"`
+	EventType      string         `json:"event_type"`
+	Payload        datatypes.JSON `gorm:"type:jsonb" json:"payload"`
+	Status         string         `gorm:"index" json:"status"` // pending, success, dead
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  *time.Time     `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastStatusCode int            `json:"last_status_code"`
+	LastError      string         `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// KYCJob 异步KYC任务（与 KYCRequest 一一对应，ID 相同）
+type KYCJob struct {
+	ID          string         `gorm:"primaryKey" json:"id"`
+	OrgID       string         `gorm:"index" json:"org_id"`
+	UserID      string         `json:"user_id"`
+	JobType     string         `json:"job_type"`            // complete
+	Status      string         `gorm:"index" json:"status"` // pending, processing, success, failed, cancelled
+	Attempts    int            `json:"attempts"`
+	MaxAttempts int            `json:"max_attempts"`
+	Input       datatypes.JSON `gorm:"type:jsonb" json:"-"` // 任务输入（图片资产ID等，敏感字段已加密）
+	Checkpoint  datatypes.JSON `gorm:"type:jsonb" json:"-"` // 已完成步骤的中间结果，用于断点续跑
+	NextRunAt   time.Time      `gorm:"index" json:"next_run_at"`
+	LockedUntil *time.Time     `json:"locked_until,omitempty"` // 处理中任务的租约；取消后保留到工作协程真正停止
+	ClaimToken  string         `json:"-"`                      // 每次领取生成，工作协程写检查点与结果时校验，防止被接管后继续写入
+	LastError   string         `json:"last_error,omitempty"`
+	CancelledAt *time.Time     `json:"cancelled_at,omitempty"`
+	FinishedAt  *time.Time     `json:"finished_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/crypto"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDataKeyDestroyed 对象所用的组织数据密钥已销毁，内容无法再解密
+	ErrDataKeyDestroyed = errors.New("data key destroyed")
+	// ErrBlobNotEncrypted 资产记录为加密存储，但对象不是用记录的数据密钥加密的密文（可能被替换）
+	ErrBlobNotEncrypted = errors.New("blob is not encrypted with the recorded data key")
+)
+
+// BlobEncryptionEnabled 新入库的图片/视频是否加密存储
+func (s *KYCService) BlobEncryptionEnabled() bool {
+	return s.Config.Storage.Encryption.Enabled
+}
+
+// blobMasterKey 包裹数据密钥的主密钥及其指纹；必须单独配置，不与 security.encryption_key 共用
+func (s *KYCService) blobMasterKey() ([]byte, string, error) {
+	raw := s.Config.Storage.Encryption.MasterKey
+	if raw == "" {
+		return nil, "", errors.New("storage.encryption.master_key is required when storage encryption is enabled")
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid storage master key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, "", errors.New("storage master key must be 32 bytes")
+	}
+	if sk := s.Config.Security.EncryptionKey; sk != "" && (string(key) == sk || raw == sk) {
+		return nil, "", errors.New("storage master key must not reuse security.encryption_key")
+	}
+	sum := sha256.Sum256(key)
+	return key, hex.EncodeToString(sum[:8]), nil
+}
+
+// activeDataKey 组织当前的数据密钥，没有时生成一个
+func (s *KYCService) activeDataKey(ctx context.Context, orgID string) (string, []byte, error) {
+	master, masterID, err := s.blobMasterKey()
+	if err != nil {
+		return "", nil, err
+	}
+	var dk models.OrgDataKey
+	err = s.DB.WithContext(ctx).Where("organization_id = ? AND destroyed_at IS NULL AND master_key_id = ?", orgID, masterID).
+		Order("created_at DESC").First(&dk).Error
+	if err == nil {
+		return s.unwrapDataKey(&dk, master, masterID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, err
+	}
+	key, err := crypto.GenerateDataKey()
+	if err != nil {
+		return "", nil, err
+	}
+	wrapped, err := crypto.WrapKey(master, key)
+	if err != nil {
+		return "", nil, err
+	}
+	dk = models.OrgDataKey{
+		ID:             "dk_" + utils.GenerateID(),
+		OrganizationID: orgID,
+		WrappedKey:     base64.StdEncoding.EncodeToString(wrapped),
+		MasterKeyID:    masterID,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.DB.WithContext(ctx).Create(&dk).Error; err != nil {
+		return "", nil, err
+	}
+	return dk.ID, key, nil
+}
+
+func (s *KYCService) unwrapDataKey(dk *models.OrgDataKey, master []byte, masterID string) (string, []byte, error) {
+	if dk.DestroyedAt != nil || dk.WrappedKey == "" {
+		return "", nil, ErrDataKeyDestroyed
+	}
+	if dk.MasterKeyID != masterID {
+		return "", nil, fmt.Errorf("data key %s wrapped by master key %s, current is %s", dk.ID, dk.MasterKeyID, masterID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(dk.WrappedKey)
+	if err != nil {
+		return "", nil, err
+	}
+	key, err := crypto.UnwrapKey(master, wrapped)
+	if err != nil {
+		return "", nil, fmt.Errorf("unwrap data key %s: %w", dk.ID, err)
+	}
+	return dk.ID, key, nil
+}
+
+// dataKeyByID 按ID取得数据密钥明文；已销毁或不存在时返回 ErrDataKeyDestroyed
+func (s *KYCService) dataKeyByID(ctx context.Context, id string) ([]byte, error) {
+	master, masterID, err := s.blobMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	var dk models.OrgDataKey
+	if err := s.DB.WithContext(ctx).First(&dk, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataKeyDestroyed
+		}
+		return nil, err
+	}
+	_, key, err := s.unwrapDataKey(&dk, master, masterID)
+	return key, err
+}
+
+// assetReusable 入库去重命中时能否直接复用已有对象：数据密钥已销毁的不能复用；
+// 启用加密后，早期明文存储（空ID）的也不能复用，需重新加密写入
+func (s *KYCService) assetReusable(ctx context.Context, id string) bool {
+	if id == "" {
+		return !s.BlobEncryptionEnabled()
+	}
+	var n int64
+	s.DB.WithContext(ctx).Model(&models.OrgDataKey{}).Where("id = ? AND destroyed_at IS NULL", id).Count(&n)
+	return n > 0
+}
+
+// sealBlob 未启用加密时原样返回；否则以组织数据密钥加密，返回密文 Reader、密文长度与数据密钥ID
+func (s *KYCService) sealBlob(ctx context.Context, orgID string, r io.Reader, size int64) (io.Reader, int64, string, error) {
+	if !s.BlobEncryptionEnabled() {
+		return r, size, "", nil
+	}
+	keyID, key, err := s.activeDataKey(ctx, orgID)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("load data key failed: %w", err)
+	}
+	er, err := crypto.NewEncryptReader(r, key, keyID)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return er, crypto.EncryptedSize(size, keyID), keyID, nil
+}
+
+type blobReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// decryptBlob 按头部判断对象是否为密文，是则返回解密后的流；明文原样返回。
+// dataKeyID 为资产记录的数据密钥，非空时对象必须是用该密钥加密的密文，否则返回 ErrBlobNotEncrypted
+func (s *KYCService) decryptBlob(ctx context.Context, raw io.ReadCloser, dataKeyID string) (io.ReadCloser, bool, error) {
+	br := bufio.NewReader(raw)
+	head, _ := br.Peek(8)
+	if !crypto.IsEncryptedStream(head) {
+		if dataKeyID != "" {
+			raw.Close()
+			return nil, false, ErrBlobNotEncrypted
+		}
+		return blobReadCloser{br, raw}, false, nil
+	}
+	dr, err := crypto.NewDecryptReader(br, func(keyID string) ([]byte, error) {
+		if dataKeyID != "" && keyID != dataKeyID {
+			return nil, fmt.Errorf("%w: header names %s, asset records %s", ErrBlobNotEncrypted, keyID, dataKeyID)
+		}
+		return s.dataKeyByID(ctx, keyID)
+	})
+	if err != nil {
+		raw.Close()
+		return nil, true, err
+	}
+	return blobReadCloser{dr, raw}, true, nil
+}
+
+// BlobDataKeyID 对象键所属资产记录的数据密钥ID；没有记录或明文存储时为空
+func (s *KYCService) BlobDataKeyID(ctx context.Context, key string) (string, error) {
+	for _, m := range []interface{}{&models.ImageAsset{}, &models.VideoAsset{}} {
+		var ids []string
+		if err := s.DB.WithContext(ctx).Model(m).Where("storage_key = ? AND data_key_id <> ''", key).
+			Limit(1).Pluck("data_key_id", &ids).Error; err != nil {
+			return "", err
+		}
+		if len(ids) > 0 {
+			return ids[0], nil
+		}
+	}
+	return "", nil
+}
+
+// ShredResult 销毁组织数据密钥的结果
+type ShredResult struct {
+	KeysDestroyed int64 `json:"keys_destroyed"`
+	ImageAssets   int64 `json:"image_assets"`
+	VideoAssets   int64 `json:"video_assets"`
+}
+
+// ListOrgDataKeys 组织的全部数据密钥（含已销毁）
+func (s *KYCService) ListOrgDataKeys(ctx context.Context, orgID string) ([]models.OrgDataKey, error) {
+	var keys []models.OrgDataKey
+	err := s.DB.WithContext(ctx).Where("organization_id = ?", orgID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// ShredOrgDataKeys 加密销毁：清除组织全部数据密钥，用它们加密的图片/视频随即不可解密。
+// 密钥记录保留用于审计；之后再入库的文件会使用新生成的数据密钥
+func (s *KYCService) ShredOrgDataKeys(ctx context.Context, orgID, actorID string) (*ShredResult, error) {
+	res := &ShredResult{}
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		if err := tx.Model(&models.OrgDataKey{}).Where("organization_id = ? AND destroyed_at IS NULL", orgID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		now := time.Now()
+		r := tx.Model(&models.OrgDataKey{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"wrapped_key": "", "destroyed_at": now, "destroyed_by": actorID})
+		if r.Error != nil {
+			return r.Error
+		}
+		res.KeysDestroyed = r.RowsAffected
+		tx.Model(&models.ImageAsset{}).Where("data_key_id IN ?", ids).Count(&res.ImageAssets)
+		tx.Model(&models.VideoAsset{}).Where("data_key_id IN ?", ids).Count(&res.VideoAssets)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// dropReplacedBlob 资产重新写入到新键后删除旧键下的对象（旧明文或已无法解密的密文）
+func (s *KYCService) dropReplacedBlob(ctx context.Context, oldKey, newKey string) {
+	if oldKey == "" || oldKey == newKey {
+		return
+	}
+	if err := s.Blobs.Delete(ctx, oldKey); err != nil {
+		logger.GetLogger().WithError(err).Warnf("删除被替换的对象失败: %s", oldKey)
+	}
+}
+
+// SealPlaintextBlobs 将启用加密前以明文存储的图片/视频改为用组织数据密钥加密（原键覆盖写入），
+// 使其同样受数据密钥销毁约束；每批处理 batch 条，返回加密的资产数。未启用加密时不做任何事
+func (s *KYCService) SealPlaintextBlobs(ctx context.Context, batch int) (int, error) {
+	if !s.BlobEncryptionEnabled() {
+		return 0, nil
+	}
+	if batch <= 0 {
+		batch = 100
+	}
+	sealed := 0
+	for _, kind := range []struct {
+		model interface{}
+		table string
+	}{{&models.ImageAsset{}, "image_assets"}, {&models.VideoAsset{}, "video_assets"}} {
+		cursor := ""
+		for {
+			var rows []struct {
+				ID             string
+				OrganizationID string
+				StorageKey     string
+				FilePath       string
+				ContentType    string
+				SizeBytes      int64
+			}
+			if err := s.DB.WithContext(ctx).Model(kind.model).
+				Select("id, organization_id, storage_key, file_path, content_type, size_bytes").
+				Where("data_key_id = '' AND id > ?", cursor).Order("id").Limit(batch).Scan(&rows).Error; err != nil {
+				return sealed, err
+			}
+			if len(rows) == 0 {
+				break
+			}
+			for _, r := range rows {
+				cursor = r.ID
+				key := s.assetBlobKey(r.StorageKey, r.FilePath)
+				if key == "" {
+					continue
+				}
+				keyID, err := s.sealStoredBlob(ctx, r.OrganizationID, key, r.SizeBytes)
+				if err != nil {
+					logger.GetLogger().WithError(err).Warnf("加密明文对象失败: %s %s", kind.table, r.ID)
+					continue
+				}
+				if err := s.DB.WithContext(ctx).Table(kind.table).Where("id = ? AND data_key_id = ''", r.ID).
+					Updates(map[string]interface{}{"storage_key": key, "data_key_id": keyID}).Error; err != nil {
+					return sealed, err
+				}
+				sealed++
+			}
+		}
+	}
+	return sealed, nil
+}
+
+// sealStoredBlob 读出键下的明文对象，加密后写回同一键，返回所用数据密钥ID
+func (s *KYCService) sealStoredBlob(ctx context.Context, orgID, key string, size int64) (string, error) {
+	raw, err := s.Blobs.Open(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer raw.Close()
+	br := bufio.NewReader(raw)
+	if head, _ := br.Peek(8); crypto.IsEncryptedStream(head) {
+		return "", errors.New("blob is already encrypted")
+	}
+	body, bodySize, keyID, err := s.sealBlob(ctx, orgID, br, size)
+	if err != nil {
+		return "", err
+	}
+	if err := s.Blobs.Put(ctx, key, body, bodySize, "application/octet-stream"); err != nil {
+		return "", err
+	}
+	return keyID, nil
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	"kyc-service/internal/config"
+	"kyc-service/internal/models"
+	"kyc-service/pkg/crypto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestBlobMasterKey(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	s := &KYCService{Config: cfg}
+	// 不再回退到 security.encryption_key
+	_, _, err := s.blobMasterKey()
+	assert.Error(t, err)
+
+	master := bytes.Repeat([]byte{1}, 32)
+	cfg.Storage.Encryption.MasterKey = base64.StdEncoding.EncodeToString(master)
+	key, id, err := s.blobMasterKey()
+	require.NoError(t, err)
+	assert.Equal(t, master, key)
+	assert.Len(t, id, 16)
+
+	cfg.Storage.Encryption.MasterKey = base64.StdEncoding.EncodeToString([]byte("short"))
+	_, _, err = s.blobMasterKey()
+	assert.Error(t, err)
+
+	cfg.Storage.Encryption.MasterKey = cfg.Security.EncryptionKey
+	_, _, err = s.blobMasterKey()
+	assert.Error(t, err)
+
+	// 与 security.encryption_key 相同的密钥（base64 编码后）同样拒绝
+	cfg.Storage.Encryption.MasterKey = base64.StdEncoding.EncodeToString([]byte(cfg.Security.EncryptionKey))
+	_, _, err = s.blobMasterKey()
+	assert.Error(t, err)
+}
+
+func TestSealBlobDisabledAndPlaintextPassthrough(t *testing.T) {
+	s := &KYCService{Config: &config.Config{}}
+	r, size, keyID, err := s.sealBlob(context.Background(), "org-1", bytes.NewReader([]byte("abc")), 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), size)
+	assert.Empty(t, keyID)
+
+	rc, encrypted, err := s.decryptBlob(context.Background(), io.NopCloser(r), "")
+	require.NoError(t, err)
+	assert.False(t, encrypted)
+	b, _ := io.ReadAll(rc)
+	assert.Equal(t, "abc", string(b))
+}
+
+func TestDecryptBlobWithoutDataKeyFails(t *testing.T) {
+	// 头部可识别但主密钥缺失时不应按明文返回
+	key, _ := crypto.GenerateDataKey()
+	er, err := crypto.NewEncryptReader(bytes.NewReader([]byte("secret")), key, "dk_1")
+	require.NoError(t, err)
+	sealed, _ := io.ReadAll(er)
+
+	s := &KYCService{Config: &config.Config{}}
+	_, encrypted, err := s.decryptBlob(context.Background(), io.NopCloser(bytes.NewReader(sealed)), "")
+	assert.True(t, encrypted)
+	assert.Error(t, err)
+}
+
+func TestDecryptBlobRequiresRecordedDataKey(t *testing.T) {
+	s := &KYCService{Config: &config.Config{}}
+	// 资产记录为加密存储时，明文对象视为被替换
+	_, _, err := s.decryptBlob(context.Background(), io.NopCloser(bytes.NewReader([]byte("plain"))), "dk_1")
+	assert.ErrorIs(t, err, ErrBlobNotEncrypted)
+
+	key, _ := crypto.GenerateDataKey()
+	er, err := crypto.NewEncryptReader(bytes.NewReader([]byte("secret")), key, "dk_other")
+	require.NoError(t, err)
+	sealed, _ := io.ReadAll(er)
+	_, encrypted, err := s.decryptBlob(context.Background(), io.NopCloser(bytes.NewReader(sealed)), "dk_1")
+	assert.True(t, encrypted)
+	assert.ErrorIs(t, err, ErrBlobNotEncrypted)
+}
+
+func TestInitBlobStoreRequiresMasterKey(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Storage.IngestDir = t.TempDir()
+	cfg.Storage.Encryption.Enabled = true
+	cfg.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	s := &KYCService{Config: cfg}
+	assert.Error(t, s.InitBlobStore())
+
+	cfg.Storage.Encryption.MasterKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	assert.NoError(t, s.InitBlobStore())
+}
+
+func TestSealPlaintextBlobs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&models.ImageAsset{}, &models.VideoAsset{}, &models.OrgDataKey{}))
+
+	s := newBlobTestService(t)
+	s.DB = db
+	ctx := context.Background()
+	// 未启用加密时明文资产可直接复用
+	assert.True(t, s.assetReusable(ctx, ""))
+
+	s.Config.Storage.Encryption.Enabled = true
+	s.Config.Storage.Encryption.MasterKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{3}, 32))
+	// 启用加密后明文资产不能复用，去重命中时需重新加密写入
+	assert.False(t, s.assetReusable(ctx, ""))
+
+	key := s.blobKey("org-1", blobKindImages, "h1.jpg")
+	require.NoError(t, s.Blobs.Put(ctx, key, bytes.NewReader([]byte("plain image")), 11, "image/jpeg"))
+	require.NoError(t, db.Create(&models.ImageAsset{ID: "img_1", OrganizationID: "org-1", Hash: "h1", StorageKey: key, SizeBytes: 11}).Error)
+
+	n, err := s.SealPlaintextBlobs(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	var asset models.ImageAsset
+	require.NoError(t, db.First(&asset, "id = ?", "img_1").Error)
+	require.NotEmpty(t, asset.DataKeyID)
+	assert.True(t, s.assetReusable(ctx, asset.DataKeyID))
+
+	raw, err := s.Blobs.Open(ctx, key)
+	require.NoError(t, err)
+	stored, _ := io.ReadAll(raw)
+	raw.Close()
+	assert.True(t, crypto.IsEncryptedStream(stored))
+
+	rc, encrypted, err := s.OpenBlob(ctx, key, asset.DataKeyID)
+	require.NoError(t, err)
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	assert.True(t, encrypted)
+	assert.Equal(t, "plain image", string(b))
+
+	// 已加密的不再处理
+	n, err = s.SealPlaintextBlobs(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
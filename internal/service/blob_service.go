@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kyc-service/pkg/blobstore"
+)
+
+// BlobDownloadPath local 后端签名下载地址的路由前缀
+const BlobDownloadPath = "/api/v1/blobs"
+
+// 对象键中的资源类别
+const (
+	blobKindImages = "images"
+	blobKindVideos = "videos"
+)
+
+var ErrBlobUnavailable = errors.New("asset blob unavailable")
+
+func (s *KYCService) blobURLParams() (string, []byte) {
+	cfg := s.Config.Storage
+	secret := cfg.URLSecret
+	if secret == "" {
+		secret = s.Config.Security.JWTSecret
+	}
+	return strings.TrimRight(cfg.PublicBaseURL, "/") + BlobDownloadPath, []byte(secret)
+}
+
+// newLocalBlobStore 以 IngestDir 为根目录的本地存储
+func (s *KYCService) newLocalBlobStore() *blobstore.Local {
+	base, secret := s.blobURLParams()
+	return blobstore.NewLocal(s.Config.Storage.IngestDir, base, secret)
+}
+
+// BlobURLSigner 经本服务下载（并解密）对象的签名地址
+func (s *KYCService) BlobURLSigner() *blobstore.URLSigner {
+	return blobstore.NewURLSigner(s.blobURLParams())
+}
+
+// InitBlobStore 按 storage.backend 初始化对象存储；NewKYCService 默认使用本地存储
+func (s *KYCService) InitBlobStore() error {
+	cfg := s.Config.Storage
+	switch strings.ToLower(cfg.Backend) {
+	case "", blobstore.BackendLocal:
+		s.Blobs = s.newLocalBlobStore()
+	case blobstore.BackendS3:
+		store, err := blobstore.NewS3(blobstore.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			PathStyle: cfg.S3.PathStyle,
+			Timeout:   cfg.S3.Timeout,
+		})
+		if err != nil {
+			return err
+		}
+		s.Blobs = store
+	default:
+		return fmt.Errorf("unknown storage backend: %q", cfg.Backend)
+	}
+	if s.BlobEncryptionEnabled() {
+		if _, _, err := s.blobMasterKey(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blobKey 组织前缀 + 类别 + 文件名，如 <org_id>/images/<sha256>.jpg
+func (s *KYCService) blobKey(orgID, kind, name string) string {
+	return blobstore.OrgPrefix(s.Config.Storage.KeyPrefix, orgID) + kind + "/" + name
+}
+
+// assetBlobKey 资产在对象存储中的键；早期记录没有 StorageKey，文件位于本地 IngestDir 下时按相对路径推算
+func (s *KYCService) assetBlobKey(storageKey, legacyPath string) string {
+	if storageKey != "" {
+		return storageKey
+	}
+	if legacyPath == "" || s.Blobs == nil || s.Blobs.Backend() != blobstore.BackendLocal {
+		return ""
+	}
+	rel, err := filepath.Rel(filepath.Clean(s.Config.Storage.IngestDir), filepath.Clean(legacyPath))
+	if err != nil || blobstore.ValidateKey(filepath.ToSlash(rel)) != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// OpenBlob 读取对象并透明解密；返回值 encrypted 表示对象是否为密文存储。
+// dataKeyID 为资产记录的数据密钥，非空时拒绝明文对象
+func (s *KYCService) OpenBlob(ctx context.Context, key, dataKeyID string) (rc io.ReadCloser, encrypted bool, err error) {
+	raw, err := s.Blobs.Open(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.decryptBlob(ctx, raw, dataKeyID)
+}
+
+// openAsset 读取资产（已解密），localPath 为可直接交给供应商的明文本地路径，没有时为空
+func (s *KYCService) openAsset(ctx context.Context, storageKey, legacyPath, dataKeyID string) (rc io.ReadCloser, localPath string, err error) {
+	var encrypted bool
+	if key := s.assetBlobKey(storageKey, legacyPath); key != "" {
+		if rc, encrypted, err = s.OpenBlob(ctx, key, dataKeyID); err != nil {
+			return nil, "", err
+		}
+		if lp, ok := s.Blobs.(blobstore.LocalPather); ok && !encrypted {
+			localPath, _ = lp.LocalPath(key)
+		}
+		return rc, localPath, nil
+	}
+	if legacyPath == "" {
+		return nil, "", ErrBlobUnavailable
+	}
+	f, err := os.Open(legacyPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if rc, encrypted, err = s.decryptBlob(ctx, f, dataKeyID); err != nil {
+		return nil, "", err
+	}
+	if !encrypted {
+		localPath = legacyPath
+	}
+	return rc, localPath, nil
+}
+
+// OpenAsset 读取已入库的图片/视频（已解密）；不在对象存储中的早期记录直接读取本地路径
+func (s *KYCService) OpenAsset(ctx context.Context, storageKey, legacyPath, dataKeyID string) (io.ReadCloser, error) {
+	rc, _, err := s.openAsset(ctx, storageKey, legacyPath, dataKeyID)
+	return rc, err
+}
+
+// AssetLocalPath 供按本地路径调用的供应商使用：本地明文文件直接返回路径；
+// 加密或非本地存储的对象解密下载到 IngestDir 下的临时文件，调用方用完后执行 cleanup 删除
+func (s *KYCService) AssetLocalPath(ctx context.Context, storageKey, legacyPath, dataKeyID string) (string, func(), error) {
+	noop := func() {}
+	rc, localPath, err := s.openAsset(ctx, storageKey, legacyPath, dataKeyID)
+	if err != nil {
+		return "", noop, err
+	}
+	defer rc.Close()
+	if localPath != "" {
+		return localPath, noop, nil
+	}
+	ext := filepath.Ext(storageKey)
+	if ext == "" {
+		ext = filepath.Ext(legacyPath)
+	}
+	return s.spoolTemp(ext, rc)
+}
+
+// spoolTemp 将内容写入 ingest_dir/.spool 下的临时文件，用完后调用返回的 cleanup 删除
+func (s *KYCService) spoolTemp(ext string, r io.Reader) (string, func(), error) {
+	noop := func() {}
+	dir := filepath.Join(s.Config.Storage.IngestDir, ".spool")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", noop, err
+	}
+	f, err := os.CreateTemp(dir, "*"+ext)
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() { _ = os.Remove(f.Name()) }
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", noop, err
+	}
+	return f.Name(), cleanup, nil
+}
+
+// AssetDownloadURL 资产的短期签名下载地址；启用加密时指向本服务的解密下载接口
+func (s *KYCService) AssetDownloadURL(ctx context.Context, storageKey, legacyPath string) (string, time.Time, error) {
+	key := s.assetBlobKey(storageKey, legacyPath)
+	if key == "" {
+		return "", time.Time{}, ErrBlobUnavailable
+	}
+	ttl := s.Config.Storage.SignedURLTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	var u string
+	var err error
+	if s.BlobEncryptionEnabled() {
+		u, err = s.BlobURLSigner().Sign(key, ttl)
+	} else {
+		u, err = s.Blobs.SignedURL(ctx, key, ttl)
+	}
+	return u, time.Now().Add(ttl), err
+}
+
+// DeleteAsset 删除资产对应的对象；早期记录删除本地文件，文件已不存在视为成功
+func (s *KYCService) DeleteAsset(ctx context.Context, storageKey, legacyPath string) error {
+	if key := s.assetBlobKey(storageKey, legacyPath); key != "" {
+		return s.Blobs.Delete(ctx, key)
+	}
+	if legacyPath == "" {
+		return nil
+	}
+	if err := os.Remove(legacyPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
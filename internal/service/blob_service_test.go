@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kyc-service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeExt(t *testing.T) {
+	assert.Equal(t, ".jpg", safeExt("a.JPG"))
+	assert.Equal(t, ".webp", safeExt("dir/x.webp"))
+	assert.Equal(t, "", safeExt("noext"))
+	assert.Equal(t, "", safeExt("a.j/pg"))
+	assert.Equal(t, "", safeExt("a.verylongext"))
+	assert.Equal(t, "", safeExt("a.p%g"))
+}
+
+func TestSniffContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	assert.Equal(t, "image/png", sniffContentType(png, "x.jpg", "image/", imageExtTypes))
+	// 文件头无法识别时按扩展名推断
+	assert.Equal(t, "video/quicktime", sniffContentType([]byte("????"), "x.MOV", "video/", videoExtTypes))
+	assert.Equal(t, "text/plain; charset=utf-8", sniffContentType([]byte("hello"), "x.txt", "image/", imageExtTypes))
+}
+
+func newBlobTestService(t *testing.T) *KYCService {
+	cfg := &config.Config{}
+	cfg.Storage.IngestDir = t.TempDir()
+	cfg.Storage.KeyPrefix = "orgs/{org_id}"
+	cfg.Security.JWTSecret = "secret"
+	s := &KYCService{Config: cfg}
+	require.NoError(t, s.InitBlobStore())
+	return s
+}
+
+func TestBlobKeyAndLegacyAssets(t *testing.T) {
+	s := newBlobTestService(t)
+	assert.Equal(t, "orgs/org-1/images/abc.jpg", s.blobKey("org-1", blobKindImages, "abc.jpg"))
+
+	// 早期记录只有 IngestDir 下的本地路径
+	legacy := filepath.Join(s.Config.Storage.IngestDir, "old.jpg")
+	require.NoError(t, os.WriteFile(legacy, []byte("legacy"), 0644))
+	assert.Equal(t, "old.jpg", s.assetBlobKey("", legacy))
+	rc, err := s.OpenAsset(context.Background(), "", legacy, "")
+	require.NoError(t, err)
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	assert.Equal(t, "legacy", string(b))
+
+	p, cleanup, err := s.AssetLocalPath(context.Background(), "", legacy, "")
+	require.NoError(t, err)
+	cleanup()
+	assert.Equal(t, legacy, p)
+
+	u, _, err := s.AssetDownloadURL(context.Background(), "", legacy)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u, BlobDownloadPath+"/old.jpg?"))
+
+	_, _, err = s.AssetDownloadURL(context.Background(), "", "")
+	assert.ErrorIs(t, err, ErrBlobUnavailable)
+}
+
+func TestInitBlobStoreUnknownBackend(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Storage.Backend = "ftp"
+	s := &KYCService{Config: cfg}
+	assert.Error(t, s.InitBlobStore())
+}
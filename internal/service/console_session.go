@@ -0,0 +1,283 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSessionAccessTTL  = 15 * time.Minute
+	defaultSessionRefreshTTL = 7 * 24 * time.Hour
+	defaultSessionMaxLife    = 30 * 24 * time.Hour
+	// sessionTouchInterval 最后活跃时间的最小更新间隔，避免每个请求都写库
+	sessionTouchInterval = time.Minute
+	consoleRefreshPrefix = "crt_"
+)
+
+// 会话失效原因
+const (
+	SessionRevokeLogout         = "logout"
+	SessionRevokeRemote         = "remote_logout"
+	SessionRevokePasswordChange = "password_changed"
+	SessionRevokeRefreshReuse   = "refresh_token_reuse"
+)
+
+var (
+	ErrSessionInvalid = errors.New("session invalid or expired")
+	ErrSessionReuse   = errors.New("refresh token reuse detected")
+)
+
+// SessionClient 建立或刷新会话的客户端信息
+type SessionClient struct {
+	DeviceName string
+	IP         string
+	UserAgent  string
+}
+
+// SessionAuth 会话的认证方式；SSOOrgID 非空表示通过该组织的 SSO 登录，会话仅能访问该组织
+type SessionAuth struct {
+	AMR      []string
+	MFAAt    time.Time
+	SSOOrgID string
+}
+
+// SessionAccessTTL 控制台访问令牌有效期
+func (s *KYCService) SessionAccessTTL() time.Duration {
+	if d := s.Config.Security.Session.AccessTokenTTL; d > 0 {
+		return d
+	}
+	return defaultSessionAccessTTL
+}
+
+func (s *KYCService) sessionRefreshTTL() time.Duration {
+	if d := s.Config.Security.Session.RefreshTokenTTL; d > 0 {
+		return d
+	}
+	return defaultSessionRefreshTTL
+}
+
+func (s *KYCService) sessionMaxLifetime() time.Duration {
+	if d := s.Config.Security.Session.MaxLifetime; d > 0 {
+		return d
+	}
+	return defaultSessionMaxLife
+}
+
+func newConsoleRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return consoleRefreshPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// DescribeUserAgent 从 User-Agent 粗略识别浏览器与系统，作为默认设备名
+func DescribeUserAgent(ua string) string {
+	browser := ""
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+	system := ""
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		system = "iOS"
+	case strings.Contains(ua, "Android"):
+		system = "Android"
+	case strings.Contains(ua, "Mac OS X"):
+		system = "macOS"
+	case strings.Contains(ua, "Windows"):
+		system = "Windows"
+	case strings.Contains(ua, "Linux"):
+		system = "Linux"
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return "Unknown device"
+}
+
+// SessionAMR 会话记录的认证方式
+func SessionAMR(sess *models.ConsoleSession) []string {
+	if sess.AMR == "" {
+		return nil
+	}
+	return strings.Split(sess.AMR, ",")
+}
+
+// CreateConsoleSession 登录成功后创建会话，返回刷新令牌明文（仅此一次）
+func (s *KYCService) CreateConsoleSession(userID string, auth SessionAuth, client SessionClient) (*models.ConsoleSession, string, error) {
+	refresh, err := newConsoleRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	if client.DeviceName == "" {
+		client.DeviceName = DescribeUserAgent(client.UserAgent)
+	}
+	sess := &models.ConsoleSession{
+		ID:           utils.GenerateID(),
+		UserID:       userID,
+		RefreshHash:  HashClientSecret(refresh),
+		AMR:          strings.Join(auth.AMR, ","),
+		SSOOrgID:     auth.SSOOrgID,
+		DeviceName:   client.DeviceName,
+		IP:           client.IP,
+		UserAgent:    client.UserAgent,
+		CreatedAt:    now,
+		LastSeenAt:   now,
+		MaxExpiresAt: now.Add(s.sessionMaxLifetime()),
+	}
+	sess.ExpiresAt = minTime(now.Add(s.sessionRefreshTTL()), sess.MaxExpiresAt)
+	if !auth.MFAAt.IsZero() {
+		sess.MFAAt = &auth.MFAAt
+	}
+	if err := s.DB.Create(sess).Error; err != nil {
+		return nil, "", err
+	}
+	return sess, refresh, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// RefreshConsoleSession 用刷新令牌换取新的刷新令牌（轮换）；旧令牌再次出现时视为泄露并终止会话
+func (s *KYCService) RefreshConsoleSession(refreshToken string, client SessionClient) (*models.ConsoleSession, string, error) {
+	if !strings.HasPrefix(refreshToken, consoleRefreshPrefix) {
+		return nil, "", ErrSessionInvalid
+	}
+	hash := HashClientSecret(refreshToken)
+	now := time.Now()
+	var sess models.ConsoleSession
+	if err := s.DB.Where("refresh_hash = ? AND revoked_at IS NULL", hash).First(&sess).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", err
+		}
+		var reused models.ConsoleSession
+		if s.DB.Where("previous_refresh_hash = ? AND revoked_at IS NULL", hash).First(&reused).Error == nil {
+			_, _ = s.revokeConsoleSessions(s.DB.Where("id = ?", reused.ID), SessionRevokeRefreshReuse)
+			logger.GetLogger().Warnf("控制台刷新令牌被重放，已终止会话: user=%s session=%s", reused.UserID, reused.ID)
+			return nil, "", ErrSessionReuse
+		}
+		return nil, "", ErrSessionInvalid
+	}
+	if now.After(sess.ExpiresAt) || now.After(sess.MaxExpiresAt) {
+		return nil, "", ErrSessionInvalid
+	}
+	next, err := newConsoleRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	updates := map[string]interface{}{
+		"refresh_hash":          HashClientSecret(next),
+		"previous_refresh_hash": hash,
+		"last_seen_at":          now,
+		"expires_at":            minTime(now.Add(s.sessionRefreshTTL()), sess.MaxExpiresAt),
+	}
+	if client.IP != "" {
+		updates["ip"] = client.IP
+	}
+	if client.UserAgent != "" {
+		updates["user_agent"] = client.UserAgent
+	}
+	// 并发刷新时只有一个请求能完成轮换
+	res := s.DB.Model(&models.ConsoleSession{}).Where("id = ? AND refresh_hash = ? AND revoked_at IS NULL", sess.ID, hash).Updates(updates)
+	if res.Error != nil {
+		return nil, "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, "", ErrSessionInvalid
+	}
+	if err := s.DB.First(&sess, "id = ?", sess.ID).Error; err != nil {
+		return nil, "", err
+	}
+	return &sess, next, nil
+}
+
+// ConsoleSessionActive 校验访问令牌所属会话仍然有效，并按间隔更新最后活跃时间
+func (s *KYCService) ConsoleSessionActive(sid, userID string) bool {
+	var sess models.ConsoleSession
+	if err := s.DB.Select("id", "user_id", "last_seen_at", "max_expires_at", "revoked_at").
+		Where("id = ?", sid).First(&sess).Error; err != nil {
+		return false
+	}
+	now := time.Now()
+	if sess.UserID != userID || sess.RevokedAt != nil || now.After(sess.MaxExpiresAt) {
+		return false
+	}
+	if now.Sub(sess.LastSeenAt) > sessionTouchInterval {
+		_ = s.DB.Model(&models.ConsoleSession{}).Where("id = ?", sid).Update("last_seen_at", now).Error
+	}
+	return true
+}
+
+// UpdateConsoleSessionAuth 会话完成第二因素后更新认证方式，刷新后的访问令牌沿用
+func (s *KYCService) UpdateConsoleSessionAuth(sid string, amr []string, mfaAt time.Time) error {
+	if sid == "" {
+		return nil
+	}
+	return s.DB.Model(&models.ConsoleSession{}).Where("id = ?", sid).
+		Updates(map[string]interface{}{"amr": strings.Join(amr, ","), "mfa_at": mfaAt}).Error
+}
+
+// ListConsoleSessions 用户的有效会话，按最近活跃排序
+func (s *KYCService) ListConsoleSessions(userID string) ([]models.ConsoleSession, error) {
+	var out []models.ConsoleSession
+	now := time.Now()
+	err := s.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ? AND max_expires_at > ?", userID, now, now).
+		Order("last_seen_at DESC").Find(&out).Error
+	return out, err
+}
+
+// RevokeConsoleSession 终止用户的某个会话，不存在时返回 ErrSessionInvalid
+func (s *KYCService) RevokeConsoleSession(userID, sid, reason string) error {
+	n, err := s.revokeConsoleSessions(s.DB.Where("id = ? AND user_id = ?", sid, userID), reason)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionInvalid
+	}
+	return nil
+}
+
+// RevokeConsoleSessions 终止用户的全部会话；exceptSID 非空时保留该会话（如修改密码的当前会话）
+func (s *KYCService) RevokeConsoleSessions(userID, exceptSID, reason string) (int64, error) {
+	q := s.DB.Where("user_id = ?", userID)
+	if exceptSID != "" {
+		q = q.Where("id <> ?", exceptSID)
+	}
+	return s.revokeConsoleSessions(q, reason)
+}
+
+func (s *KYCService) revokeConsoleSessions(q *gorm.DB, reason string) (int64, error) {
+	res := q.Model(&models.ConsoleSession{}).Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason})
+	return res.RowsAffected, res.Error
+}
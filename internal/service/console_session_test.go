@@ -0,0 +1,42 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"kyc-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want string
+	}{
+		{"Mac上的Chrome", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36", "Chrome on macOS"},
+		{"Windows上的Edge", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0", "Edge on Windows"},
+		{"iPhone上的Safari", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1", "Safari on iOS"},
+		{"Linux上的Firefox", "Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0", "Firefox on Linux"},
+		{"命令行工具", "curl/8.5.0", "Unknown device"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DescribeUserAgent(tt.ua))
+		})
+	}
+}
+
+func TestConsoleRefreshTokenAndAMR(t *testing.T) {
+	a, err := newConsoleRefreshToken()
+	require.NoError(t, err)
+	b, err := newConsoleRefreshToken()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(a, consoleRefreshPrefix))
+	assert.NotEqual(t, a, b)
+
+	assert.Nil(t, SessionAMR(&models.ConsoleSession{}))
+	assert.Equal(t, []string{AMRPassword, AMROTP, AMRMFA}, SessionAMR(&models.ConsoleSession{AMR: "pwd,otp,mfa"}))
+}
@@ -0,0 +1,582 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/crypto"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// 数据主体签名文件的 token_use，不能作为登录令牌使用
+const (
+	DataSubjectExportTokenUse  = "data_subject_export"
+	DataSubjectErasureTokenUse = "data_subject_erasure"
+)
+
+// 单个数据主体最多匹配的请求数，超出时应缩小查找条件
+const maxDataSubjectRequests = 1000
+
+var (
+	ErrDataSubjectQueryEmpty = errors.New("id_card, id_card_hash, phone or request_id is required")
+	ErrDataSubjectNotFound   = errors.New("no data found for data subject")
+	ErrDataSubjectTooMany    = errors.New("too many requests matched, narrow the query")
+	ErrReceiptInvalid        = errors.New("invalid receipt")
+)
+
+// DataSubjectQuery 数据主体查找条件，至少一项；多项之间为“或”。
+// 按手机号查找只能匹配记录了手机号哈希之后提交的请求
+type DataSubjectQuery struct {
+	IDCard     string `json:"id_card"`
+	IDCardHash string `json:"id_card_hash"`
+	Phone      string `json:"phone"`
+	RequestID  string `json:"request_id"`
+}
+
+// dataSubjectKeys 归一化后的查找键：证件号哈希、手机号哈希（HMAC）、请求ID
+func (s *KYCService) dataSubjectKeys(q DataSubjectQuery) (idHashes []string, phoneHash, requestID string) {
+	if id := strings.TrimSpace(q.IDCard); id != "" {
+		idHashes = append(idHashes, crypto.HashIDCard(id))
+		if up := strings.ToUpper(id); up != id {
+			idHashes = append(idHashes, crypto.HashIDCard(up))
+		}
+	}
+	if h := strings.ToLower(strings.TrimSpace(q.IDCardHash)); h != "" {
+		idHashes = append(idHashes, h)
+	}
+	return idHashes, s.hashPhone(q.Phone), strings.TrimSpace(q.RequestID)
+}
+
+func (q DataSubjectQuery) empty() bool {
+	return strings.TrimSpace(q.IDCard) == "" && strings.TrimSpace(q.IDCardHash) == "" &&
+		normalizePhone(q.Phone) == "" && strings.TrimSpace(q.RequestID) == ""
+}
+
+// DataSubjectRef 查找条件的不可逆引用，用于审计与回执（不含原始证件号/手机号）
+func (s *KYCService) DataSubjectRef(orgID string, q DataSubjectQuery) string {
+	ids, phone, req := s.dataSubjectKeys(q)
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(orgID + "|" + strings.Join(ids, ",") + "|" + phone + "|" + req))
+	return hex.EncodeToString(sum[:])
+}
+
+// DataSubjectRecords 数据主体在组织内的全部数据
+type DataSubjectRecords struct {
+	Requests           []models.KYCRequest
+	Images             []models.ImageAsset
+	Videos             []models.VideoAsset
+	Jobs               []models.KYCJob
+	FaceImageRefs      []models.FaceImageRef
+	LivenessSessionIDs []string // 动作活体会话（Redis），ID 与对应的KYC请求相同
+	RequestLogs        []models.APIRequestLog
+}
+
+// FindDataSubject 在组织内查找数据主体的KYC请求（含已软删除的）及关联的图片、视频、异步任务、
+// 人脸搜索引用、动作活体会话与请求日志。按请求ID查找时，没有KYC请求记录的调用（如动态活体）
+// 也按该ID匹配视频与请求日志
+func (s *KYCService) FindDataSubject(ctx context.Context, orgID string, q DataSubjectQuery) (*DataSubjectRecords, error) {
+	if q.empty() {
+		return nil, ErrDataSubjectQueryEmpty
+	}
+	idHashes, phoneHash, requestID := s.dataSubjectKeys(q)
+	db := s.DB.WithContext(ctx)
+	cond := db.Where("1 = 0")
+	if len(idHashes) > 0 {
+		cond = cond.Or("id_card_hash IN ?", idHashes)
+	}
+	if phoneHash != "" {
+		cond = cond.Or("phone_hash = ?", phoneHash)
+	}
+	if requestID != "" {
+		cond = cond.Or("id = ?", requestID)
+	}
+	recs := &DataSubjectRecords{}
+	if err := db.Unscoped().Where("org_id = ?", orgID).Where(cond).Order("created_at").
+		Limit(maxDataSubjectRequests + 1).Find(&recs.Requests).Error; err != nil {
+		return nil, err
+	}
+	if len(recs.Requests) > maxDataSubjectRequests {
+		return nil, ErrDataSubjectTooMany
+	}
+	var reqIDs, assetIDs, videoIDs []string
+	for _, r := range recs.Requests {
+		reqIDs = append(reqIDs, r.ID)
+		for _, a := range []string{r.IDCardImage, r.FaceImage} {
+			if a != "" {
+				assetIDs = append(assetIDs, a)
+			}
+		}
+		if r.RequestType == "liveness_action" {
+			recs.LivenessSessionIDs = append(recs.LivenessSessionIDs, r.ID)
+			// 早期视频记录没有 request_id，从会话结果中取
+			videoIDs = append(videoIDs, actionVideoIDs(r.Result)...)
+		}
+	}
+	linkIDs := reqIDs
+	if requestID != "" && !slices.Contains(reqIDs, requestID) {
+		linkIDs = append(append([]string{}, reqIDs...), requestID)
+	}
+	if len(assetIDs) > 0 {
+		if err := db.Where("organization_id = ? AND id IN ?", orgID, assetIDs).Order("created_at").Find(&recs.Images).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(linkIDs) > 0 {
+		vq := db.Where("request_id IN ?", linkIDs)
+		if len(videoIDs) > 0 {
+			vq = vq.Or("id IN ?", videoIDs)
+		}
+		if err := db.Where("organization_id = ?", orgID).Where(vq).Order("created_at").Find(&recs.Videos).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("org_id = ? AND kyc_request_id IN ?", orgID, linkIDs).Order("created_at").Find(&recs.RequestLogs).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(recs.Requests) == 0 && len(recs.Videos) == 0 && len(recs.RequestLogs) == 0 {
+		return nil, ErrDataSubjectNotFound
+	}
+	if len(reqIDs) > 0 {
+		if err := db.Where("org_id = ? AND id IN ?", orgID, reqIDs).Find(&recs.Jobs).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("organization_id = ? AND request_id IN ?", orgID, reqIDs).Order("created_at").Find(&recs.FaceImageRefs).Error; err != nil {
+			return nil, err
+		}
+	}
+	return recs, nil
+}
+
+// actionVideoIDs 动作活体会话结果（KYCRequest.Result）中各动作的视频ID
+func actionVideoIDs(result string) []string {
+	var sess struct {
+		Actions []struct {
+			AssetID string `json:"asset_id"`
+		} `json:"actions"`
+	}
+	if json.Unmarshal([]byte(result), &sess) != nil {
+		return nil
+	}
+	var ids []string
+	for _, a := range sess.Actions {
+		if a.AssetID != "" {
+			ids = append(ids, a.AssetID)
+		}
+	}
+	return ids
+}
+
+// DataSubjectSummary 查找结果摘要（不含个人信息）
+type DataSubjectSummary struct {
+	SubjectRef         string                   `json:"subject_ref"`
+	Requests           []DataSubjectRequestView `json:"requests"`
+	ImageIDs           []string                 `json:"image_ids"`
+	VideoIDs           []string                 `json:"video_ids"`
+	JobIDs             []string                 `json:"job_ids"`
+	FaceImageRefIDs    []string                 `json:"face_image_ref_ids"`
+	LivenessSessionIDs []string                 `json:"liveness_session_ids"`
+	RequestLogIDs      []string                 `json:"request_log_ids"`
+}
+
+// DataSubjectRequestView 导出/摘要中的单条KYC请求；个人信息字段只在导出时填充
+type DataSubjectRequestView struct {
+	ID           string          `json:"id"`
+	RequestType  string          `json:"request_type"`
+	Status       string          `json:"status"`
+	Name         string          `json:"name,omitempty"`
+	IDCard       string          `json:"id_card,omitempty"`
+	Phone        string          `json:"phone,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+	IPAddress    string          `json:"ip_address,omitempty"`
+	UserAgent    string          `json:"user_agent,omitempty"`
+	IDCardImage  string          `json:"id_card_image,omitempty"`
+	FaceImage    string          `json:"face_image,omitempty"`
+	PIIPurgedAt  *time.Time      `json:"pii_purged_at,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// Summary 查找结果摘要
+func (r *DataSubjectRecords) Summary(subjectRef string) *DataSubjectSummary {
+	sum := &DataSubjectSummary{
+		SubjectRef:         subjectRef,
+		Requests:           []DataSubjectRequestView{},
+		ImageIDs:           []string{},
+		VideoIDs:           []string{},
+		JobIDs:             []string{},
+		FaceImageRefIDs:    []string{},
+		LivenessSessionIDs: append([]string{}, r.LivenessSessionIDs...),
+		RequestLogIDs:      []string{},
+	}
+	for _, req := range r.Requests {
+		sum.Requests = append(sum.Requests, DataSubjectRequestView{ID: req.ID, RequestType: req.RequestType, Status: req.Status, PIIPurgedAt: req.PIIPurgedAt, CreatedAt: req.CreatedAt})
+	}
+	for _, img := range r.Images {
+		sum.ImageIDs = append(sum.ImageIDs, img.ID)
+	}
+	for _, v := range r.Videos {
+		sum.VideoIDs = append(sum.VideoIDs, v.ID)
+	}
+	for _, j := range r.Jobs {
+		sum.JobIDs = append(sum.JobIDs, j.ID)
+	}
+	for _, f := range r.FaceImageRefs {
+		sum.FaceImageRefIDs = append(sum.FaceImageRefIDs, f.ID)
+	}
+	for _, l := range r.RequestLogs {
+		sum.RequestLogIDs = append(sum.RequestLogIDs, l.ID)
+	}
+	return sum
+}
+
+// decryptField 解密请求中的个人信息；已清除或解密失败时返回空
+func (s *KYCService) decryptField(v string) string {
+	if v == "" || s.Encryptor == nil {
+		return ""
+	}
+	plain, err := s.Encryptor.Decrypt(v)
+	if err != nil {
+		return ""
+	}
+	return plain
+}
+
+// dataSubjectManifest 导出包清单：各文件的 sha256，签名覆盖清单本身
+type dataSubjectManifest struct {
+	ExportID   string            `json:"export_id"`
+	OrgID      string            `json:"org_id"`
+	SubjectRef string            `json:"subject_ref"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Files      map[string]string `json:"files"`
+}
+
+// ExportDataSubject 将数据主体的数据写为 zip 导出包：subject.json（含解密后的个人信息、人脸搜索引用、
+// 动作活体会话与请求日志）、images/ 与 videos/ 下的原文件、manifest.json（各文件 sha256）与 manifest.sig（对清单 sha256 的 JWT 签名，可用 JWKS 验证）
+func (s *KYCService) ExportDataSubject(ctx context.Context, w io.Writer, orgID, actorID string, q DataSubjectQuery, recs *DataSubjectRecords) (string, error) {
+	exportID := "dsx_" + utils.GenerateID()
+	subjectRef := s.DataSubjectRef(orgID, q)
+	now := time.Now().UTC()
+	zw := zip.NewWriter(w)
+	manifest := dataSubjectManifest{ExportID: exportID, OrgID: orgID, SubjectRef: subjectRef, CreatedAt: now, Files: map[string]string{}}
+
+	writeFile := func(name string, r io.Reader) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(fw, h), r); err != nil {
+			return err
+		}
+		manifest.Files[name] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	}
+
+	type exportAsset struct {
+		ID          string    `json:"id"`
+		File        string    `json:"file,omitempty"`
+		ContentType string    `json:"content_type"`
+		SizeBytes   int64     `json:"size_bytes"`
+		SHA256      string    `json:"sha256"`
+		Error       string    `json:"error,omitempty"`
+		CreatedAt   time.Time `json:"created_at"`
+	}
+	// 人脸搜索引用只导出引用本身：指向的是人脸库中的相似图片，不属于该数据主体
+	type exportFaceImageRef struct {
+		ID        string    `json:"id"`
+		RequestID string    `json:"request_id"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	doc := struct {
+		ExportID         string                     `json:"export_id"`
+		OrgID            string                     `json:"org_id"`
+		SubjectRef       string                     `json:"subject_ref"`
+		ExportedAt       time.Time                  `json:"exported_at"`
+		Requests         []DataSubjectRequestView   `json:"requests"`
+		Images           []exportAsset              `json:"images"`
+		Videos           []exportAsset              `json:"videos"`
+		Jobs             []models.KYCJob            `json:"jobs"`
+		FaceImageRefs    []exportFaceImageRef       `json:"face_image_refs"`
+		LivenessSessions map[string]json.RawMessage `json:"liveness_sessions"`
+		RequestLogs      []models.APIRequestLog     `json:"request_logs"`
+	}{ExportID: exportID, OrgID: orgID, SubjectRef: subjectRef, ExportedAt: now, Jobs: recs.Jobs, LivenessSessions: map[string]json.RawMessage{}, RequestLogs: recs.RequestLogs}
+
+	exportFile := func(dir, id, safeName string, item exportAsset, storageKey, filePath, dataKeyID string) (exportAsset, error) {
+		rc, err := s.OpenAsset(ctx, storageKey, filePath, dataKeyID)
+		if err != nil {
+			item.Error = err.Error()
+			return item, nil
+		}
+		defer rc.Close()
+		item.File = dir + "/" + id + safeExt(safeName)
+		return item, writeFile(item.File, rc)
+	}
+	for _, img := range recs.Images {
+		item, err := exportFile("images", img.ID, img.SafeFilename, exportAsset{ID: img.ID, ContentType: img.ContentType, SizeBytes: img.SizeBytes, SHA256: img.Hash, CreatedAt: img.CreatedAt}, img.StorageKey, img.FilePath, img.DataKeyID)
+		if err != nil {
+			return "", err
+		}
+		doc.Images = append(doc.Images, item)
+	}
+	for _, v := range recs.Videos {
+		item, err := exportFile("videos", v.ID, v.SafeFilename, exportAsset{ID: v.ID, ContentType: v.ContentType, SizeBytes: v.SizeBytes, SHA256: v.Hash, CreatedAt: v.CreatedAt}, v.StorageKey, v.FilePath, v.DataKeyID)
+		if err != nil {
+			return "", err
+		}
+		doc.Videos = append(doc.Videos, item)
+	}
+	for _, f := range recs.FaceImageRefs {
+		doc.FaceImageRefs = append(doc.FaceImageRefs, exportFaceImageRef{ID: f.ID, RequestID: f.RequestID, CreatedAt: f.CreatedAt})
+	}
+	if s.Redis != nil {
+		for _, id := range recs.LivenessSessionIDs {
+			// 会话过期后已从 Redis 清除，其结论保存在对应请求的 result 中
+			if b, err := s.Redis.Get(ctx, actionSessionKey(id)).Bytes(); err == nil && json.Valid(b) {
+				doc.LivenessSessions[id] = b
+			}
+		}
+	}
+	for _, r := range recs.Requests {
+		item := DataSubjectRequestView{
+			ID: r.ID, RequestType: r.RequestType, Status: r.Status,
+			Name: s.decryptField(r.Name), IDCard: s.decryptField(r.IDCard), Phone: s.decryptField(r.Phone),
+			ErrorMessage: r.ErrorMessage, IPAddress: r.IPAddress, UserAgent: r.UserAgent,
+			IDCardImage: r.IDCardImage, FaceImage: r.FaceImage, PIIPurgedAt: r.PIIPurgedAt, CreatedAt: r.CreatedAt,
+		}
+		if r.Result != "" {
+			if json.Valid([]byte(r.Result)) {
+				item.Result = json.RawMessage(r.Result)
+			} else {
+				item.Result, _ = json.Marshal(r.Result)
+			}
+		}
+		doc.Requests = append(doc.Requests, item)
+	}
+	b, _ := json.MarshalIndent(doc, "", "  ")
+	if err := writeFile("subject.json", strings.NewReader(string(b))); err != nil {
+		return "", err
+	}
+
+	mb, _ := json.MarshalIndent(manifest, "", "  ")
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return "", err
+	}
+	if _, err := mw.Write(mb); err != nil {
+		return "", err
+	}
+	msum := sha256.Sum256(mb)
+	sig, err := s.SignJWT(jwt.MapClaims{
+		"iss":             "kyc-service",
+		"token_use":       DataSubjectExportTokenUse,
+		"jti":             exportID,
+		"org_id":          orgID,
+		"subject_ref":     subjectRef,
+		"manifest_sha256": hex.EncodeToString(msum[:]),
+		"iat":             now.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("sign manifest failed: %w", err)
+	}
+	sw, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.sig", Method: zip.Store, Modified: now})
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(sw, sig); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	summary, _ := json.Marshal(map[string]interface{}{
+		"requests": len(recs.Requests), "images": len(recs.Images), "videos": len(recs.Videos), "jobs": len(recs.Jobs),
+		"face_image_refs": len(recs.FaceImageRefs), "liveness_sessions": len(doc.LivenessSessions), "request_logs": len(recs.RequestLogs),
+		"manifest_sha256": hex.EncodeToString(msum[:]),
+	})
+	if err := s.saveDataSubjectRequest(ctx, exportID, orgID, "export", subjectRef, summary, sig, actorID); err != nil {
+		logger.GetLogger().WithError(err).Warnf("保存数据主体导出记录失败 %s", exportID)
+	}
+	return exportID, nil
+}
+
+func (s *KYCService) saveDataSubjectRequest(ctx context.Context, id, orgID, action, subjectRef string, summary []byte, signature, actorID string) error {
+	return s.DB.WithContext(ctx).Create(&models.DataSubjectRequest{
+		ID:         id,
+		OrgID:      orgID,
+		Action:     action,
+		SubjectRef: subjectRef,
+		Summary:    datatypes.JSON(summary),
+		Signature:  signature,
+		CreatedBy:  actorID,
+		CreatedAt:  time.Now(),
+	}).Error
+}
+
+// ErasureReceipt 删除回执；Token 为对回执内容的 JWT 签名，可用 JWKS 或 VerifyDataSubjectToken 验证
+type ErasureReceipt struct {
+	ReceiptID          string    `json:"receipt_id"`
+	OrgID              string    `json:"org_id"`
+	SubjectRef         string    `json:"subject_ref"`
+	RequestIDs         []string  `json:"request_ids"`
+	ImageIDs           []string  `json:"image_ids"`
+	VideoIDs           []string  `json:"video_ids"`
+	JobIDs             []string  `json:"job_ids"`
+	FaceImageRefIDs    []string  `json:"face_image_ref_ids"`
+	LivenessSessionIDs []string  `json:"liveness_session_ids"`
+	RequestLogIDs      []string  `json:"request_log_ids"`
+	ErasedAt           time.Time `json:"erased_at"`
+	Token              string    `json:"token"`
+}
+
+// EraseDataSubject 删除数据主体的全部数据：先删除图片与视频对象及 Redis 中的动作活体会话（任一失败则中止，可重试），
+// 再在同一事务中删除图片与视频记录、异步任务、人脸搜索引用、请求日志与KYC请求（含已软删除的），最后签发并保存删除回执
+func (s *KYCService) EraseDataSubject(ctx context.Context, orgID, actorID string, q DataSubjectQuery) (*ErasureReceipt, error) {
+	recs, err := s.FindDataSubject(ctx, orgID, q)
+	if err != nil {
+		return nil, err
+	}
+	sum := recs.Summary("")
+	receipt := &ErasureReceipt{
+		ReceiptID:          "dse_" + utils.GenerateID(),
+		OrgID:              orgID,
+		SubjectRef:         s.DataSubjectRef(orgID, q),
+		RequestIDs:         []string{},
+		ImageIDs:           sum.ImageIDs,
+		VideoIDs:           sum.VideoIDs,
+		JobIDs:             sum.JobIDs,
+		FaceImageRefIDs:    sum.FaceImageRefIDs,
+		LivenessSessionIDs: sum.LivenessSessionIDs,
+		RequestLogIDs:      sum.RequestLogIDs,
+	}
+	for _, r := range recs.Requests {
+		receipt.RequestIDs = append(receipt.RequestIDs, r.ID)
+	}
+	for _, img := range recs.Images {
+		if err := s.DeleteAsset(ctx, img.StorageKey, img.FilePath); err != nil {
+			return nil, fmt.Errorf("delete image %s failed: %w", img.ID, err)
+		}
+	}
+	for _, v := range recs.Videos {
+		if err := s.DeleteAsset(ctx, v.StorageKey, v.FilePath); err != nil {
+			return nil, fmt.Errorf("delete video %s failed: %w", v.ID, err)
+		}
+	}
+	if s.Redis != nil && len(receipt.LivenessSessionIDs) > 0 {
+		keys := make([]string, 0, len(receipt.LivenessSessionIDs))
+		for _, id := range receipt.LivenessSessionIDs {
+			keys = append(keys, actionSessionKey(id))
+		}
+		if err := s.Redis.Del(ctx, keys...).Err(); err != nil {
+			return nil, fmt.Errorf("delete liveness sessions failed: %w", err)
+		}
+	}
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, d := range []struct {
+			model  interface{}
+			orgCol string
+			ids    []string
+		}{
+			{&models.ImageAsset{}, "organization_id", receipt.ImageIDs},
+			{&models.VideoAsset{}, "organization_id", receipt.VideoIDs},
+			{&models.KYCJob{}, "org_id", receipt.JobIDs},
+			{&models.FaceImageRef{}, "organization_id", receipt.FaceImageRefIDs},
+			{&models.APIRequestLog{}, "org_id", receipt.RequestLogIDs},
+		} {
+			if len(d.ids) == 0 {
+				continue
+			}
+			if err := tx.Where(d.orgCol+" = ? AND id IN ?", orgID, d.ids).Delete(d.model).Error; err != nil {
+				return err
+			}
+		}
+		if len(receipt.RequestIDs) == 0 {
+			return nil
+		}
+		return tx.Unscoped().Where("org_id = ? AND id IN ?", orgID, receipt.RequestIDs).Delete(&models.KYCRequest{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	receipt.ErasedAt = time.Now().UTC()
+	receipt.Token, err = s.SignJWT(jwt.MapClaims{
+		"iss":                  "kyc-service",
+		"token_use":            DataSubjectErasureTokenUse,
+		"jti":                  receipt.ReceiptID,
+		"org_id":               orgID,
+		"subject_ref":          receipt.SubjectRef,
+		"request_ids":          receipt.RequestIDs,
+		"image_ids":            receipt.ImageIDs,
+		"video_ids":            receipt.VideoIDs,
+		"job_ids":              receipt.JobIDs,
+		"face_image_ref_ids":   receipt.FaceImageRefIDs,
+		"liveness_session_ids": receipt.LivenessSessionIDs,
+		"request_log_ids":      receipt.RequestLogIDs,
+		"iat":                  receipt.ErasedAt.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sign receipt failed: %w", err)
+	}
+	summary, _ := json.Marshal(map[string]interface{}{
+		"request_ids": receipt.RequestIDs, "image_ids": receipt.ImageIDs, "video_ids": receipt.VideoIDs, "job_ids": receipt.JobIDs,
+		"face_image_ref_ids": receipt.FaceImageRefIDs, "liveness_session_ids": receipt.LivenessSessionIDs, "request_log_ids": receipt.RequestLogIDs,
+	})
+	if err := s.saveDataSubjectRequest(ctx, receipt.ReceiptID, orgID, "erase", receipt.SubjectRef, summary, receipt.Token, actorID); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// ReceiptVerification 回执/导出签名的验证结果
+type ReceiptVerification struct {
+	Valid             bool                   `json:"valid"`
+	SignatureVerified bool                   `json:"signature_verified"` // 签名密钥已轮换清除时为 false，此时以服务端留存记录为准
+	Recorded          bool                   `json:"recorded"`
+	Claims            map[string]interface{} `json:"claims,omitempty"`
+}
+
+// VerifyDataSubjectToken 验证删除回执或导出包的 manifest.sig：签名有效或与服务端留存记录一致，且属于本组织
+func (s *KYCService) VerifyDataSubjectToken(ctx context.Context, orgID, token string) (*ReceiptVerification, error) {
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, s.JWTKeyfunc)
+	res := &ReceiptVerification{SignatureVerified: err == nil && parsed.Valid}
+	if !res.SignatureVerified {
+		// 仅在签名密钥已不可用时回退到留存记录；内容须原样一致
+		claims = jwt.MapClaims{}
+		if _, _, perr := jwt.NewParser().ParseUnverified(token, claims); perr != nil {
+			return nil, ErrReceiptInvalid
+		}
+	}
+	use, _ := claims["token_use"].(string)
+	if use != DataSubjectErasureTokenUse && use != DataSubjectExportTokenUse {
+		return nil, ErrReceiptInvalid
+	}
+	if org, _ := claims["org_id"].(string); org != orgID {
+		return nil, ErrReceiptInvalid
+	}
+	id, _ := claims["jti"].(string)
+	var rec models.DataSubjectRequest
+	if id != "" && s.DB.WithContext(ctx).First(&rec, "id = ? AND org_id = ?", id, orgID).Error == nil {
+		res.Recorded = rec.Signature == token
+	}
+	res.Valid = res.SignatureVerified || res.Recorded
+	if res.Valid {
+		res.Claims = claims
+	}
+	return res, nil
+}
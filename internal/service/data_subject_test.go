@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyc-service/internal/config"
+	"kyc-service/internal/models"
+	"kyc-service/pkg/crypto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestDataSubjectKeys(t *testing.T) {
+	s := &KYCService{Config: &config.Config{}}
+	s.Config.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	q := DataSubjectQuery{IDCard: " 11010519491231002x ", Phone: "138-0013 8000", RequestID: " req_1 "}
+	ids, phone, req := s.dataSubjectKeys(q)
+	assert.Equal(t, []string{crypto.HashIDCard("11010519491231002x"), crypto.HashIDCard("11010519491231002X")}, ids)
+	assert.Equal(t, s.hashPhone("13800138000"), phone)
+	assert.NotEmpty(t, phone)
+	assert.Equal(t, "req_1", req)
+
+	assert.True(t, DataSubjectQuery{Phone: "  "}.empty())
+	assert.False(t, DataSubjectQuery{IDCardHash: "ABC"}.empty())
+}
+
+func TestHashPhoneIsKeyed(t *testing.T) {
+	s := &KYCService{Config: &config.Config{}}
+	s.Config.Security.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	h := s.hashPhone("13800138000")
+	// 不能是手机号的普通 SHA-256（可被枚举还原）
+	plain, _ := crypto.HashString("13800138000")
+	assert.NotEqual(t, plain, h)
+
+	other := &KYCService{Config: &config.Config{}}
+	other.Config.Security.EncryptionKey = "fedcba9876543210fedcba9876543210"
+	assert.NotEqual(t, h, other.hashPhone("13800138000"))
+	assert.Empty(t, s.hashPhone(" - "))
+}
+
+func TestDataSubjectRef(t *testing.T) {
+	s := &KYCService{Config: &config.Config{}}
+	a := DataSubjectQuery{IDCard: "11010519491231002X"}
+	b := DataSubjectQuery{IDCardHash: crypto.HashIDCard("11010519491231002X")}
+	assert.Equal(t, s.DataSubjectRef("org_1", a), s.DataSubjectRef("org_1", b))
+	assert.NotEqual(t, s.DataSubjectRef("org_1", a), s.DataSubjectRef("org_2", a))
+	assert.NotContains(t, s.DataSubjectRef("org_1", a), "11010519491231002X")
+}
+
+func TestDataSubjectSummaryOmitsPII(t *testing.T) {
+	recs := &DataSubjectRecords{
+		Requests: []models.KYCRequest{{ID: "req_1", RequestType: "complete", Status: "success", Name: "enc-name", IDCard: "enc-id", CreatedAt: time.Now()}},
+		Images:   []models.ImageAsset{{ID: "img_1"}},
+		Jobs:     []models.KYCJob{{ID: "req_1"}},
+	}
+	sum := recs.Summary("ref")
+	assert.Equal(t, "ref", sum.SubjectRef)
+	assert.Len(t, sum.Requests, 1)
+	assert.Empty(t, sum.Requests[0].Name)
+	assert.Empty(t, sum.Requests[0].IDCard)
+	assert.Equal(t, []string{"img_1"}, sum.ImageIDs)
+	assert.Equal(t, []string{"req_1"}, sum.JobIDs)
+}
+
+func newDataSubjectTestService(t *testing.T) *KYCService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		Logger:                                   gormlogger.Discard,
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&models.KYCRequest{}, &models.ImageAsset{}, &models.VideoAsset{}, &models.KYCJob{}, &models.FaceImageRef{}))
+	// api_request_logs 的 uuid 默认值依赖 Postgres，这里手工建表
+	require.NoError(t, db.Exec(`CREATE TABLE api_request_logs (id TEXT PRIMARY KEY, org_id TEXT, user_id TEXT, api_key_id TEXT, api_key_name TEXT,
+		api_key_owner_id TEXT, method TEXT, path TEXT, status_code INTEGER, latency_ms INTEGER, client_ip TEXT, kyc_request_id TEXT,
+		request_body TEXT, response_body TEXT, created_at DATETIME)`).Error)
+	return &KYCService{DB: db, Config: &config.Config{}}
+}
+
+func TestFindDataSubjectIncludesEveryArtifact(t *testing.T) {
+	s := newDataSubjectTestService(t)
+	ctx := context.Background()
+	idHash := crypto.HashIDCard("11010519491231002X")
+	reqLog := func(id, kycReq string) *models.APIRequestLog {
+		return &models.APIRequestLog{ID: id, OrgID: "org_1", Method: "GET", Path: "/p", KYCRequestID: &kycReq, CreatedAt: time.Now()}
+	}
+	for _, v := range []interface{}{
+		&models.KYCRequest{ID: "req_face", OrgID: "org_1", RequestType: "face_search", IDCardHash: idHash},
+		&models.KYCRequest{ID: "sess_1", OrgID: "org_1", RequestType: "liveness_action", IDCardHash: idHash,
+			Result: `{"session_id":"sess_1","actions":[{"action":"blink","asset_id":"vid_old"},{"action":"nod","asset_id":"vid_new"}]}`},
+		&models.KYCRequest{ID: "req_other", OrgID: "org_1", RequestType: "complete", IDCardHash: "other"},
+		&models.VideoAsset{ID: "vid_old", OrganizationID: "org_1", Hash: "h1"},
+		&models.VideoAsset{ID: "vid_new", OrganizationID: "org_1", Hash: "h2", RequestID: "sess_1"},
+		&models.VideoAsset{ID: "vid_http", OrganizationID: "org_1", Hash: "h3", RequestID: "http_1"},
+		&models.VideoAsset{ID: "vid_foreign", OrganizationID: "org_2", Hash: "h4", RequestID: "sess_1"},
+		&models.FaceImageRef{ID: "ref_1", OrganizationID: "org_1", RequestID: "req_face"},
+		&models.FaceImageRef{ID: "ref_2", OrganizationID: "org_1", RequestID: "req_other"},
+		reqLog("log_1", "sess_1"),
+		reqLog("log_2", "http_1"),
+		reqLog("log_3", "req_other"),
+	} {
+		require.NoError(t, s.DB.Create(v).Error)
+	}
+
+	recs, err := s.FindDataSubject(ctx, "org_1", DataSubjectQuery{IDCardHash: idHash})
+	require.NoError(t, err)
+	sum := recs.Summary("ref")
+	assert.ElementsMatch(t, []string{"vid_old", "vid_new"}, sum.VideoIDs)
+	assert.Equal(t, []string{"ref_1"}, sum.FaceImageRefIDs)
+	assert.Equal(t, []string{"sess_1"}, sum.LivenessSessionIDs)
+	assert.Equal(t, []string{"log_1"}, sum.RequestLogIDs)
+
+	// 动态活体没有KYC请求记录，按请求ID仍能找到视频与请求日志
+	recs, err = s.FindDataSubject(ctx, "org_1", DataSubjectQuery{RequestID: "http_1"})
+	require.NoError(t, err)
+	assert.Empty(t, recs.Requests)
+	sum = recs.Summary("ref")
+	assert.Equal(t, []string{"vid_http"}, sum.VideoIDs)
+	assert.Equal(t, []string{"log_2"}, sum.RequestLogIDs)
+
+	_, err = s.FindDataSubject(ctx, "org_1", DataSubjectQuery{RequestID: "missing"})
+	assert.ErrorIs(t, err, ErrDataSubjectNotFound)
+}
@@ -5,4 +5,17 @@ import "errors"
 var (
 	ErrUpstreamUnavailable = errors.New("UPSTREAM_UNAVAILABLE")
 	ErrUpstreamTimeout     = errors.New("UPSTREAM_TIMEOUT")
+	ErrVendorRejected      = errors.New("VENDOR_REJECTED")
+	ErrQuotaExceeded       = errors.New("QUOTA_EXCEEDED")
+	ErrImageRejected       = errors.New("IMAGE_REJECTED") // 图片未通过预处理（分辨率过低、无法处理的格式），重试无意义
+
+	ErrActionSessionNotFound = errors.New("ACTION_SESSION_NOT_FOUND")
+	ErrActionSessionExpired  = errors.New("ACTION_SESSION_EXPIRED")
+	ErrActionSessionVerified = errors.New("ACTION_SESSION_ALREADY_VERIFIED")
+	ErrActionSessionPending  = errors.New("ACTION_SESSION_INCOMPLETE")
+	ErrActionMismatch        = errors.New("ACTION_MISMATCH")
+
+	ErrKYCJobNotFound     = errors.New("KYC_JOB_NOT_FOUND")
+	ErrKYCJobInvalidState = errors.New("KYC_JOB_INVALID_STATE")
+	ErrKYCJobStopping     = errors.New("KYC_JOB_STOPPING") // 已取消但工作协程尚未停止，暂不能重试
 )
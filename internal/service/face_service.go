@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"strings"
 	"time"
@@ -59,6 +60,7 @@ func (s *KYCService) FaceSearch(ctx context.Context, file *multipart.FileHeader)
 	kycRequest := &models.KYCRequest{
 		ID:          uuid.New().String(),
 		UserID:      getUserID(ctx),
+		OrgID:       getOrgID(ctx),
 		RequestType: "face_search",
 		Status:      "processing",
 		IPAddress:   getClientIP(ctx),
@@ -75,7 +77,8 @@ func (s *KYCService) FaceSearch(ctx context.Context, file *multipart.FileHeader)
 		return nil, fmt.Errorf("missing organization context")
 	}
 
-	if err := s.checkAndConsumeQuota(ctx, orgID, "face", func() error { return nil }); err != nil {
+	reservation, err := s.reserveQuota(ctx, orgID, "face")
+	if err != nil {
 		if strings.Contains(err.Error(), "QUOTA_EXCEEDED") {
 			if s.faceVerifySuccessRate != nil {
 				s.faceVerifySuccessRate.Record(ctx, 0.0)
@@ -90,15 +93,15 @@ func (s *KYCService) FaceSearch(ctx context.Context, file *multipart.FileHeader)
 		return nil, err
 	}
 
-	f, err := file.Open()
+	image, filename, err := s.readPreparedUpload(ctx, CapabilityFace, file)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		return nil, fmt.Errorf("open image failed: %w", err)
 	}
-	defer f.Close()
 
-	tp := NewThirdPartyService(s.Config)
-	out, err := tp.CallFaceSearch(ctx, f, file.Filename)
+	out, err := s.Providers.FaceSearch(ctx, image, filename)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		kycRequest.Status = "failed"
 		kycRequest.ErrorMessage = err.Error()
 		s.DB.Save(kycRequest)
@@ -106,14 +109,17 @@ func (s *KYCService) FaceSearch(ctx context.Context, file *multipart.FileHeader)
 			s.faceVerifySuccessRate.Record(ctx, 0.0)
 		}
 		metrics.RecordBusinessOperation(ctx, "face_search", false, time.Since(start), "third_party_error")
+		s.emitKYCEvent(ctx, WebhookEventFaceSearch, kycRequest.ID, kycRequest.RequestType, "failed", "")
 		return nil, err
 	}
+	s.commitQuota(ctx, reservation)
 
 	if out.Code != 0 {
 		if s.faceVerifySuccessRate != nil {
 			s.faceVerifySuccessRate.Record(ctx, 0.0)
 		}
 		metrics.RecordBusinessOperation(ctx, "face_search", false, time.Since(start), "third_party_code")
+		s.emitKYCEvent(ctx, WebhookEventFaceSearch, kycRequest.ID, kycRequest.RequestType, "failed", out.Msg)
 		return nil, fmt.Errorf("FACE_VERIFY_FAILED")
 	}
 
@@ -124,7 +130,7 @@ func (s *KYCService) FaceSearch(ctx context.Context, file *multipart.FileHeader)
 			continue
 		}
 		id := uuid.New().String()
-		_ = s.DB.Create(&models.FaceImageRef{ID: id, OrganizationID: orgID, FilePath: pic, SafeFilename: pic, CreatedAt: time.Now()}).Error
+		_ = s.DB.Create(&models.FaceImageRef{ID: id, OrganizationID: orgID, FilePath: pic, SafeFilename: pic, RequestID: kycRequest.ID, CreatedAt: time.Now()}).Error
 		out.SearchingResults.SearchedSimilarPictures[i].ID = id
 	}
 
@@ -137,6 +143,7 @@ func (s *KYCService) FaceSearch(ctx context.Context, file *multipart.FileHeader)
 	}
 
 	s.RecordAuditLog(ctx, "face.search", "face", "", "success", "")
+	s.emitKYCEvent(ctx, WebhookEventFaceSearch, kycRequest.ID, kycRequest.RequestType, "success", "")
 	return out, nil
 }
 
@@ -162,7 +169,8 @@ func (s *KYCService) FaceCompare(ctx context.Context, src, dst *multipart.FileHe
 		return nil, fmt.Errorf("missing organization context")
 	}
 	start := time.Now()
-	if err := s.checkAndConsumeQuota(ctx, orgID, "face", func() error { return nil }); err != nil {
+	reservation, err := s.reserveQuota(ctx, orgID, "face")
+	if err != nil {
 		if strings.Contains(err.Error(), "QUOTA_EXCEEDED") {
 			if s.faceVerifySuccessRate != nil {
 				s.faceVerifySuccessRate.Record(ctx, 0.0)
@@ -176,27 +184,38 @@ func (s *KYCService) FaceCompare(ctx context.Context, src, dst *multipart.FileHe
 		metrics.RecordBusinessOperation(ctx, "face_compare", false, time.Since(start), "quota_error")
 		return nil, err
 	}
-	f1, err := src.Open()
+	image1, name1, err := s.readPreparedUpload(ctx, CapabilityFace, src)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		return nil, fmt.Errorf("open image1 failed: %w", err)
 	}
-	defer f1.Close()
-	f2, err := dst.Open()
+	image2, name2, err := s.readPreparedUpload(ctx, CapabilityFace, dst)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		return nil, fmt.Errorf("open image2 failed: %w", err)
 	}
-	defer f2.Close()
 
-	tp := NewThirdPartyService(s.Config)
-	out, err := tp.CallFaceCompare(ctx, f1, src.Filename, f2, dst.Filename)
+	out, err := s.Providers.FaceCompare(ctx, image1, name1, image2, name2)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		if s.faceVerifySuccessRate != nil {
 			s.faceVerifySuccessRate.Record(ctx, 0.0)
 		}
 		metrics.RecordBusinessOperation(ctx, "face_compare", false, time.Since(start), "third_party_error")
+		s.emitKYCEvent(ctx, WebhookEventFaceCompare, getRequestID(ctx), "face_compare", "failed", "")
 		return nil, err
 	}
+	s.commitQuota(ctx, reservation)
+	if out.Code != 0 {
+		if s.faceVerifySuccessRate != nil {
+			s.faceVerifySuccessRate.Record(ctx, 0.0)
+		}
+		metrics.RecordBusinessOperation(ctx, "face_compare", false, time.Since(start), "third_party_code")
+		s.emitKYCEvent(ctx, WebhookEventFaceCompare, getRequestID(ctx), "face_compare", "failed", out.Msg)
+		return nil, fmt.Errorf("face compare failed: code=%d msg=%s", out.Code, out.Msg)
+	}
 	s.RecordAuditLog(ctx, "face.compare", "face", "", "success", "")
+	s.emitKYCEvent(ctx, WebhookEventFaceCompare, getRequestID(ctx), "face_compare", "success", "")
 	if s.faceVerifySuccessRate != nil {
 		s.faceVerifySuccessRate.Record(ctx, 1.0)
 	}
@@ -234,7 +253,8 @@ func (s *KYCService) FaceDetect(ctx context.Context, file *multipart.FileHeader)
 		return nil, fmt.Errorf("missing organization context")
 	}
 	start := time.Now()
-	if err := s.checkAndConsumeQuota(ctx, orgID, "face", func() error { return nil }); err != nil {
+	reservation, err := s.reserveQuota(ctx, orgID, "face")
+	if err != nil {
 		if strings.Contains(err.Error(), "QUOTA_EXCEEDED") {
 			if s.faceVerifySuccessRate != nil {
 				s.faceVerifySuccessRate.Record(ctx, 0.0)
@@ -248,25 +268,46 @@ func (s *KYCService) FaceDetect(ctx context.Context, file *multipart.FileHeader)
 		metrics.RecordBusinessOperation(ctx, "face_detect", false, time.Since(start), "quota_error")
 		return nil, err
 	}
-	f, err := file.Open()
+	image, filename, err := s.readPreparedUpload(ctx, CapabilityFace, file)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		return nil, fmt.Errorf("open image failed: %w", err)
 	}
-	defer f.Close()
 
-	tp := NewThirdPartyService(s.Config)
-	out, err := tp.CallFaceDetect(ctx, f, file.Filename)
+	out, err := s.Providers.FaceDetect(ctx, image, filename)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		if s.faceVerifySuccessRate != nil {
 			s.faceVerifySuccessRate.Record(ctx, 0.0)
 		}
 		metrics.RecordBusinessOperation(ctx, "face_detect", false, time.Since(start), "third_party_error")
+		s.emitKYCEvent(ctx, WebhookEventFaceDetect, getRequestID(ctx), "face_detect", "failed", "")
 		return nil, err
 	}
+	s.commitQuota(ctx, reservation)
+	if out.Code != 0 {
+		if s.faceVerifySuccessRate != nil {
+			s.faceVerifySuccessRate.Record(ctx, 0.0)
+		}
+		metrics.RecordBusinessOperation(ctx, "face_detect", false, time.Since(start), "third_party_code")
+		s.emitKYCEvent(ctx, WebhookEventFaceDetect, getRequestID(ctx), "face_detect", "failed", out.Msg)
+		return nil, fmt.Errorf("face detect failed: code=%d msg=%s", out.Code, out.Msg)
+	}
 	s.RecordAuditLog(ctx, "face.detect", "face", "", "success", "")
+	s.emitKYCEvent(ctx, WebhookEventFaceDetect, getRequestID(ctx), "face_detect", "success", "")
 	if s.faceVerifySuccessRate != nil {
 		s.faceVerifySuccessRate.Record(ctx, 1.0)
 	}
 	metrics.RecordBusinessOperation(ctx, "face_detect", true, time.Since(start), "")
 	return out, nil
 }
+
+// readUpload 读取上传文件全部内容（供应商切换时需要重复发送）
+func readUpload(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kyc-service/internal/config"
+	"kyc-service/pkg/imageprep"
+	"kyc-service/pkg/logger"
+)
+
+// imagePrepOptions 能力对应的预处理配置
+func (s *KYCService) imagePrepOptions(capability string) config.ImagePrepOptions {
+	switch capability {
+	case CapabilityOCR:
+		return s.Config.ImagePreprocess.OCR
+	case CapabilityFace:
+		return s.Config.ImagePreprocess.Face
+	case CapabilityLiveness:
+		return s.Config.ImagePreprocess.Liveness
+	}
+	return config.ImagePrepOptions{}
+}
+
+// jpegFilename 预处理后统一为 JPEG，文件名扩展名随之改为 .jpg
+func jpegFilename(filename string) string {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	if base == "" || base == "." || base == string(filepath.Separator) {
+		base = "image"
+	}
+	return base + ".jpg"
+}
+
+// prepareImage 按能力配置预处理发送给供应商的图片，返回处理后的内容与文件名。
+// 未启用时原样返回；无法识别的文件头原样发送，由供应商判断（与未预处理时一致）；
+// HEIC（错误信息提示转换为 JPEG/PNG）、分辨率过低、过大或已损坏的图片返回 ErrImageRejected
+func (s *KYCService) prepareImage(ctx context.Context, capability string, data []byte, filename string) ([]byte, string, error) {
+	opt := s.imagePrepOptions(capability)
+	if !opt.Enabled {
+		return data, filename, nil
+	}
+	res, err := imageprep.Process(data, imageprep.Options{MaxDimension: opt.MaxDimension, MinDimension: opt.MinDimension, Quality: opt.JPEGQuality})
+	if err != nil {
+		format := imageprep.DetectFormat(data)
+		if format == "" && errors.Is(err, imageprep.ErrUnsupportedFormat) {
+			return data, filename, nil
+		}
+		logger.GetLogger().WithError(err).Infof("图片预处理拒绝：capability=%s format=%s org=%s", capability, format, getOrgID(ctx))
+		return nil, "", fmt.Errorf("%w: %v", ErrImageRejected, err)
+	}
+	return res.Data, jpegFilename(filename), nil
+}
+
+// prepareImageFile 按路径调用的供应商（静默活体）使用：预处理后写入临时文件，用完后调用返回的 cleanup；
+// 无需处理时返回原路径
+func (s *KYCService) prepareImageFile(ctx context.Context, capability, path string) (string, func(), error) {
+	noop := func() {}
+	if !s.imagePrepOptions(capability).Enabled {
+		return path, noop, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", noop, err
+	}
+	out, _, err := s.prepareImage(ctx, capability, data, path)
+	if err != nil {
+		return "", noop, err
+	}
+	if bytes.Equal(out, data) {
+		return path, noop, nil
+	}
+	return s.spoolTemp(".jpg", bytes.NewReader(out))
+}
+
+// readPreparedUpload 读取上传图片并按能力预处理
+func (s *KYCService) readPreparedUpload(ctx context.Context, capability string, fh *multipart.FileHeader) ([]byte, string, error) {
+	data, err := readUpload(fh)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.prepareImage(ctx, capability, data, fh.Filename)
+}
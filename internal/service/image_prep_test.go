@@ -0,0 +1,57 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"kyc-service/internal/config"
+	"kyc-service/pkg/imageprep"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareImage(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.ImagePreprocess.Face = config.ImagePrepOptions{Enabled: true, MaxDimension: 100, MinDimension: 20, JPEGQuality: 85}
+	s := &KYCService{Config: cfg}
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 200))))
+	out, name, err := s.prepareImage(ctx, CapabilityFace, buf.Bytes(), "selfie.PNG")
+	require.NoError(t, err)
+	assert.Equal(t, "selfie.jpg", name)
+	assert.Equal(t, imageprep.FormatJPEG, imageprep.DetectFormat(out))
+	cfgOut, _, err := image.DecodeConfig(bytes.NewReader(out))
+	require.NoError(t, err)
+	assert.Equal(t, 100, cfgOut.Width)
+	assert.Equal(t, 50, cfgOut.Height)
+
+	// 未启用的能力原样返回
+	out, name, err = s.prepareImage(ctx, CapabilityOCR, buf.Bytes(), "card.png")
+	require.NoError(t, err)
+	assert.Equal(t, buf.Bytes(), out)
+	assert.Equal(t, "card.png", name)
+
+	// 无法识别的内容原样发送；损坏的 webp 与 HEIC 拒绝，HEIC 提示转换格式
+	unknown := []byte("not an image")
+	out, _, err = s.prepareImage(ctx, CapabilityFace, unknown, "a.bin")
+	require.NoError(t, err)
+	assert.Equal(t, unknown, out)
+	_, _, err = s.prepareImage(ctx, CapabilityFace, []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "a.webp")
+	assert.True(t, errors.Is(err, ErrImageRejected))
+	heic := append([]byte{0, 0, 0, 24}, []byte("ftypheic\x00\x00\x00\x00mif1heic")...)
+	_, _, err = s.prepareImage(ctx, CapabilityFace, heic, "a.heic")
+	assert.True(t, errors.Is(err, ErrImageRejected))
+	assert.Contains(t, err.Error(), "convert to JPEG or PNG")
+
+	var small bytes.Buffer
+	require.NoError(t, png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	_, _, err = s.prepareImage(ctx, CapabilityFace, small.Bytes(), "tiny.png")
+	assert.True(t, errors.Is(err, ErrImageRejected))
+}
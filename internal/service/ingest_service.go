@@ -9,85 +9,157 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"kyc-service/internal/models"
+	"kyc-service/pkg/blobstore"
 	"kyc-service/pkg/utils"
 )
 
-func (s *KYCService) IngestImage(ctx context.Context, orgID string, file *multipart.FileHeader) (*models.ImageAsset, error) {
-	f, err := file.Open()
-	if err != nil {
-		return nil, fmt.Errorf("open file failed: %w", err)
+var imageExtTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+}
+
+var videoExtTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+	".flv":  "video/x-flv",
+	".3gp":  "video/3gpp",
+}
+
+// storedUpload 已写入对象存储的上传文件
+type storedUpload struct {
+	Key          string
+	Path         string // 本地后端的文件路径，其他后端为空
+	SafeFilename string
+	DataKeyID    string // 加密存储时所用的数据密钥
+}
+
+// safeExt 原文件扩展名，仅保留 1~8 位小写字母数字
+func safeExt(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if len(ext) < 2 || len(ext) > 9 {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
 	}
-	defer f.Close()
+	return ext
+}
+
+// sniffContentType 按文件头判断类型，不符合 prefix 时按扩展名推断
+func sniffContentType(head []byte, filename, prefix string, byExt map[string]string) string {
+	ct := ""
+	if len(head) > 0 {
+		ct = http.DetectContentType(head)
+	}
+	if strings.HasPrefix(ct, prefix) {
+		return ct
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	if v, ok := byExt[ext]; ok {
+		return v
+	}
+	if v := mime.TypeByExtension(ext); v != "" {
+		return v
+	}
+	return ct
+}
+
+// hashUpload 流式计算文件 sha256 与长度，并读出文件头用于类型判断
+func hashUpload(f multipart.File) (sum string, size int64, head []byte, err error) {
 	h := sha256.New()
-	size, err := io.Copy(h, f)
+	size, err = io.Copy(h, f)
 	if err != nil {
-		return nil, fmt.Errorf("read file failed: %w", err)
-	}
-	sum := hex.EncodeToString(h.Sum(nil))
-	var exist models.ImageAsset
-	if err := s.DB.Where("organization_id = ? AND hash = ?", orgID, sum).First(&exist).Error; err == nil {
-		return &exist, nil
+		return "", 0, nil, fmt.Errorf("read file failed: %w", err)
 	}
 	if _, err := f.Seek(0, io.SeekStart); err != nil {
-		return nil, err
-	}
-	buf := make([]byte, 512)
-	n, _ := f.Read(buf)
-	ct := ""
-	if n > 0 {
-		ct = http.DetectContentType(buf[:n])
-	}
-	if ct == "" || !strings.HasPrefix(ct, "image/") {
-		ext := strings.ToLower(filepath.Ext(file.Filename))
-		switch ext {
-		case ".jpg", ".jpeg":
-			ct = "image/jpeg"
-		case ".png":
-			ct = "image/png"
-		case ".gif":
-			ct = "image/gif"
-		case ".webp":
-			ct = "image/webp"
-		case ".bmp":
-			ct = "image/bmp"
-		case ".tif", ".tiff":
-			ct = "image/tiff"
-		default:
-			if v := mime.TypeByExtension(ext); v != "" {
-				ct = v
-			}
-		}
+		return "", 0, nil, err
 	}
+	head = make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
 	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", 0, nil, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, head[:n], nil
+}
+
+// storeUpload 将上传文件流式写入对象存储（启用加密时写入密文），键为 <组织前缀>/<kind>/<sha256><ext>
+func (s *KYCService) storeUpload(ctx context.Context, orgID, kind string, f multipart.File, filename, sum string, size int64, ct string) (*storedUpload, error) {
+	safe := sum + safeExt(filename)
+	key := s.blobKey(orgID, kind, safe)
+	body, bodySize, keyID, err := s.sealBlob(ctx, orgID, f, size)
+	if err != nil {
 		return nil, err
 	}
-	ingestRoot := s.Config.Storage.IngestDir
-	safe := sum + filepath.Ext(file.Filename)
-	if strings.Contains(safe, "..") {
-		return nil, fmt.Errorf("invalid filename")
+	if keyID != "" {
+		ct = "application/octet-stream"
 	}
-	absPath := filepath.Join(ingestRoot, safe)
-	if err := os.MkdirAll(ingestRoot, 0755); err != nil {
-		return nil, err
+	if err := s.Blobs.Put(ctx, key, body, bodySize, ct); err != nil {
+		return nil, fmt.Errorf("store file failed: %w", err)
+	}
+	out := &storedUpload{Key: key, SafeFilename: safe, DataKeyID: keyID}
+	if lp, ok := s.Blobs.(blobstore.LocalPather); ok {
+		out.Path, _ = lp.LocalPath(key)
 	}
-	out, err := os.Create(absPath)
+	return out, nil
+}
+
+func (s *KYCService) IngestImage(ctx context.Context, orgID string, file *multipart.FileHeader) (*models.ImageAsset, error) {
+	f, err := file.Open()
 	if err != nil {
-		return nil, fmt.Errorf("create file failed: %w", err)
+		return nil, fmt.Errorf("open file failed: %w", err)
 	}
-	defer out.Close()
-	if _, err := io.Copy(out, f); err != nil {
+	defer f.Close()
+	sum, size, head, err := hashUpload(f)
+	if err != nil {
 		return nil, err
 	}
-	if ct == "" || !strings.HasPrefix(ct, "image/") {
+	// 已入库且已按当前配置存储的直接复用；数据密钥已销毁或仍为明文的重新写入，并删除旧键下的对象
+	var exist models.ImageAsset
+	found := s.DB.Where("organization_id = ? AND hash = ?", orgID, sum).First(&exist).Error == nil
+	now := time.Now()
+	if found && s.assetReusable(ctx, exist.DataKeyID) {
+		// 刷新使用时间，避免仍在使用的图片按首次入库时间被保留期清理
+		if err := s.DB.Model(&exist).Update("last_used_at", now).Error; err != nil {
+			return nil, err
+		}
+		exist.LastUsedAt = &now
+		return &exist, nil
+	}
+	ct := sniffContentType(head, file.Filename, "image/", imageExtTypes)
+	if !strings.HasPrefix(ct, "image/") {
 		return nil, fmt.Errorf("unsupported file type: %v", ct)
 	}
-	asset := &models.ImageAsset{ID: utils.GenerateID(), OrganizationID: orgID, Hash: sum, FilePath: absPath, SafeFilename: safe, ContentType: ct, SizeBytes: size, CreatedAt: time.Now()}
+	up, err := s.storeUpload(ctx, orgID, blobKindImages, f, file.Filename, sum, size, ct)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		oldKey := s.assetBlobKey(exist.StorageKey, exist.FilePath)
+		exist.StorageKey, exist.FilePath, exist.DataKeyID, exist.LastUsedAt = up.Key, up.Path, up.DataKeyID, &now
+		if err := s.DB.Model(&exist).Updates(map[string]interface{}{"storage_key": up.Key, "file_path": up.Path, "data_key_id": up.DataKeyID, "last_used_at": now}).Error; err != nil {
+			return nil, err
+		}
+		s.dropReplacedBlob(ctx, oldKey, up.Key)
+		return &exist, nil
+	}
+	asset := &models.ImageAsset{ID: utils.GenerateID(), OrganizationID: orgID, Hash: sum, StorageKey: up.Key, FilePath: up.Path, DataKeyID: up.DataKeyID, SafeFilename: up.SafeFilename, ContentType: ct, SizeBytes: size, LastUsedAt: &now, CreatedAt: now}
 	if err := s.DB.Create(asset).Error; err != nil {
 		return nil, err
 	}
@@ -95,80 +167,52 @@ func (s *KYCService) IngestImage(ctx context.Context, orgID string, file *multip
 	return asset, nil
 }
 
-func (s *KYCService) IngestVideo(ctx context.Context, orgID string, file *multipart.FileHeader) (*models.VideoAsset, error) {
+// IngestVideo 入库视频并关联到 requestID（数据主体导出/删除按此查找）；同一视频被再次使用时关联到最近的请求
+func (s *KYCService) IngestVideo(ctx context.Context, orgID, requestID string, file *multipart.FileHeader) (*models.VideoAsset, error) {
 	f, err := file.Open()
 	if err != nil {
 		return nil, fmt.Errorf("open file failed: %w", err)
 	}
 	defer f.Close()
-	h := sha256.New()
-	size, err := io.Copy(h, f)
+	sum, size, head, err := hashUpload(f)
 	if err != nil {
-		return nil, fmt.Errorf("read file failed: %w", err)
-	}
-	sum := hex.EncodeToString(h.Sum(nil))
-	var exist models.VideoAsset
-	if err := s.DB.Where("organization_id = ? AND hash = ?", orgID, sum).First(&exist).Error; err == nil {
-		return &exist, nil
-	}
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
-	buf := make([]byte, 512)
-	n, _ := f.Read(buf)
-	vct := ""
-	if n > 0 {
-		vct = http.DetectContentType(buf[:n])
-	}
-	if vct == "" || !strings.HasPrefix(vct, "video/") {
-		ext := strings.ToLower(filepath.Ext(file.Filename))
-		switch ext {
-		case ".mp4", ".m4v":
-			vct = "video/mp4"
-		case ".mov":
-			vct = "video/quicktime"
-		case ".webm":
-			vct = "video/webm"
-		case ".mkv":
-			vct = "video/x-matroska"
-		case ".avi":
-			vct = "video/x-msvideo"
-		case ".flv":
-			vct = "video/x-flv"
-		case ".3gp":
-			vct = "video/3gpp"
-		default:
-			if v := mime.TypeByExtension(ext); v != "" {
-				vct = v
-			}
+	// 已入库且已按当前配置存储的直接复用；数据密钥已销毁或仍为明文的重新写入，并删除旧键下的对象
+	var exist models.VideoAsset
+	found := s.DB.Where("organization_id = ? AND hash = ?", orgID, sum).First(&exist).Error == nil
+	now := time.Now()
+	if found && s.assetReusable(ctx, exist.DataKeyID) {
+		// 刷新使用时间，避免仍在使用的视频按首次入库时间被保留期清理
+		updates := map[string]interface{}{"last_used_at": now}
+		if requestID != "" {
+			updates["request_id"] = requestID
+			exist.RequestID = requestID
 		}
+		if err := s.DB.Model(&exist).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+		exist.LastUsedAt = &now
+		return &exist, nil
 	}
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
-		return nil, err
-	}
-	ingestRoot := s.Config.Storage.IngestDir
-	safe := sum + filepath.Ext(file.Filename)
-	if strings.Contains(safe, "..") {
-		return nil, fmt.Errorf("invalid filename")
-	}
-	absPath := filepath.Join(ingestRoot, safe)
-	if err := os.MkdirAll(ingestRoot, 0755); err != nil {
-		return nil, err
+	ct := sniffContentType(head, file.Filename, "video/", videoExtTypes)
+	if !strings.HasPrefix(ct, "video/") {
+		return nil, fmt.Errorf("unsupported file type: %v, %v", ct, file.Filename)
 	}
-	out, err := os.Create(absPath)
+	up, err := s.storeUpload(ctx, orgID, blobKindVideos, f, file.Filename, sum, size, ct)
 	if err != nil {
 		return nil, err
 	}
-	defer out.Close()
-	if _, err := io.Copy(out, f); err != nil {
-		return nil, err
-	}
-	ct := vct
-	if ct == "" || !strings.HasPrefix(ct, "video/") {
-		return nil, fmt.Errorf("unsupported file type: %v, %v", ct, file.Filename)
+	if found {
+		oldKey := s.assetBlobKey(exist.StorageKey, exist.FilePath)
+		exist.StorageKey, exist.FilePath, exist.DataKeyID, exist.RequestID, exist.LastUsedAt = up.Key, up.Path, up.DataKeyID, requestID, &now
+		if err := s.DB.Model(&exist).Updates(map[string]interface{}{"storage_key": up.Key, "file_path": up.Path, "data_key_id": up.DataKeyID, "request_id": requestID, "last_used_at": now}).Error; err != nil {
+			return nil, err
+		}
+		s.dropReplacedBlob(ctx, oldKey, up.Key)
+		return &exist, nil
 	}
-
-	asset := &models.VideoAsset{ID: utils.GenerateID(), OrganizationID: orgID, Hash: sum, FilePath: absPath, SafeFilename: safe, ContentType: ct, SizeBytes: size, CreatedAt: time.Now()}
+	asset := &models.VideoAsset{ID: utils.GenerateID(), OrganizationID: orgID, Hash: sum, StorageKey: up.Key, FilePath: up.Path, DataKeyID: up.DataKeyID, SafeFilename: up.SafeFilename, ContentType: ct, SizeBytes: size, RequestID: requestID, LastUsedAt: &now, CreatedAt: now}
 	if err := s.DB.Create(asset).Error; err != nil {
 		return nil, err
 	}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/jwtkeys"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// jwtSigningEnabled 是否启用非对称签名（algorithm 为 HS256 或初始化失败时继续使用 jwt_secret）
+func (s *KYCService) jwtSigningEnabled() bool {
+	return s.JWTKeys != nil && s.jwtAsymmetric
+}
+
+// InitJWTKeys 加载签名密钥，库中没有可用密钥时生成第一把
+func (s *KYCService) InitJWTKeys(ctx context.Context) error {
+	cfg := s.Config.Security.JWTSigning
+	alg := strings.ToUpper(cfg.Algorithm)
+	if alg != jwtkeys.AlgRS256 && alg != jwtkeys.AlgES256 {
+		s.JWTKeys = jwtkeys.NewSet(nil, s.Config.Security.JWTSecret)
+		return nil
+	}
+	if s.Encryptor == nil {
+		s.JWTKeys = jwtkeys.NewSet(nil, s.Config.Security.JWTSecret)
+		return fmt.Errorf("jwt signing requires a valid security.encryption_key to protect private keys")
+	}
+	secret := ""
+	if cfg.AcceptHMAC {
+		secret = s.Config.Security.JWTSecret
+	}
+	keys := jwtkeys.NewSet(s.loadJWTKeys, secret)
+	s.JWTKeys, s.jwtAsymmetric = keys, true
+	if _, err := s.RotateJWTKeysIfDue(ctx); err != nil {
+		s.JWTKeys, s.jwtAsymmetric = jwtkeys.NewSet(nil, s.Config.Security.JWTSecret), false
+		return err
+	}
+	return nil
+}
+
+// loadJWTKeys 从数据库加载全部未过期密钥
+func (s *KYCService) loadJWTKeys() ([]*jwtkeys.Key, error) {
+	var rows []models.JWTSigningKey
+	if err := s.DB.Where("expires_at IS NULL OR expires_at > ?", time.Now()).Order("activates_at").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*jwtkeys.Key, 0, len(rows))
+	for _, r := range rows {
+		pub, err := jwtkeys.ParsePublicKey(r.PublicKey)
+		if err != nil {
+			logger.GetLogger().WithError(err).Warnf("JWT公钥解析失败: kid=%s", r.ID)
+			continue
+		}
+		k := &jwtkeys.Key{ID: r.ID, Algorithm: r.Algorithm, Public: pub, ActivatesAt: r.ActivatesAt, ExpiresAt: r.ExpiresAt, CreatedAt: r.CreatedAt}
+		if pemStr, err := s.Encryptor.Decrypt(r.PrivateKeyEnc); err == nil {
+			k.Private, err = jwtkeys.ParsePrivateKey(pemStr)
+			if err != nil {
+				logger.GetLogger().WithError(err).Warnf("JWT私钥解析失败，仅用于验签: kid=%s", r.ID)
+			}
+		} else {
+			logger.GetLogger().WithError(err).Warnf("JWT私钥解密失败，仅用于验签: kid=%s", r.ID)
+		}
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+// RotateJWTKeysIfDue 最新密钥到期时生成下一把：新密钥提前 publish_ahead 发布，生效后旧密钥再保留 overlap_window 用于验签。
+// 多实例通过 advisory lock 保证同一时刻只有一个实例轮换。
+func (s *KYCService) RotateJWTKeysIfDue(ctx context.Context) (bool, error) {
+	if !s.jwtSigningEnabled() {
+		return false, nil
+	}
+	cfg := s.Config.Security.JWTSigning
+	rotated := false
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('jwt_key_rotation'))").Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		var latest models.JWTSigningKey
+		err := tx.Where("expires_at IS NULL OR expires_at > ?", now).Order("activates_at DESC").Limit(1).Find(&latest).Error
+		if err != nil {
+			return err
+		}
+		activatesAt := now
+		if latest.ID != "" {
+			if now.Before(latest.ActivatesAt.Add(cfg.RotationInterval - cfg.PublishAhead)) {
+				return nil
+			}
+			activatesAt = now.Add(cfg.PublishAhead)
+		}
+		k, err := jwtkeys.GenerateKey(strings.ToUpper(cfg.Algorithm), activatesAt)
+		if err != nil {
+			return err
+		}
+		privPEM, err := jwtkeys.MarshalPrivateKey(k)
+		if err != nil {
+			return err
+		}
+		enc, err := s.Encryptor.Encrypt(privPEM)
+		if err != nil {
+			return err
+		}
+		pubPEM, err := jwtkeys.MarshalPublicKey(k.Public)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&models.JWTSigningKey{
+			ID: k.ID, Algorithm: k.Algorithm, PrivateKeyEnc: enc, PublicKey: pubPEM, ActivatesAt: activatesAt,
+		}).Error; err != nil {
+			return err
+		}
+		// 之前的密钥在新密钥生效后继续验签 overlap_window
+		if err := tx.Model(&models.JWTSigningKey{}).Where("id <> ? AND expires_at IS NULL", k.ID).
+			Update("expires_at", activatesAt.Add(cfg.OverlapWindow)).Error; err != nil {
+			return err
+		}
+		rotated = true
+		logger.GetLogger().Infof("已生成新的JWT签名密钥: kid=%s alg=%s activates_at=%s", k.ID, k.Algorithm, activatesAt.Format(time.RFC3339))
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	// 其它实例轮换的密钥也在此时加载
+	return rotated, s.JWTKeys.Reload()
+}
+
+// PurgeExpiredJWTKeys 删除已过期的密钥
+func (s *KYCService) PurgeExpiredJWTKeys(ctx context.Context) (int64, error) {
+	res := s.DB.WithContext(ctx).Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).Delete(&models.JWTSigningKey{})
+	return res.RowsAffected, res.Error
+}
+
+// SignJWT 签发令牌：启用非对称签名时使用当前密钥（带 kid），否则使用 jwt_secret 的 HS256。
+// 未携带 jti/iat 的 MapClaims 会自动补齐，以便按 jti 吊销、按签发时间批量吊销。
+func (s *KYCService) SignJWT(claims jwt.Claims) (string, error) {
+	if mc, ok := claims.(jwt.MapClaims); ok {
+		if _, ok := mc["jti"]; !ok {
+			mc["jti"] = utils.GenerateID()
+		}
+		if _, ok := mc["iat"]; !ok {
+			mc["iat"] = time.Now().Unix()
+		}
+	}
+	if s.jwtSigningEnabled() {
+		return s.JWTKeys.Sign(claims)
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.Config.Security.JWTSecret))
+}
+
+// JWTKeyfunc 供 jwt.Parse 使用的验签密钥选择
+func (s *KYCService) JWTKeyfunc(token *jwt.Token) (interface{}, error) {
+	if s.JWTKeys != nil {
+		return s.JWTKeys.Keyfunc(token)
+	}
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return []byte(s.Config.Security.JWTSecret), nil
+}
+
+// JWKS 当前可验签的公钥集合
+func (s *KYCService) JWKS() jwtkeys.JWKSet {
+	if s.JWTKeys == nil {
+		return jwtkeys.JWKSet{Keys: []jwtkeys.JWK{}}
+	}
+	return s.JWTKeys.JWKS()
+}
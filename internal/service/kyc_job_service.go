@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/tracing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KYC异步任务状态
+const (
+	KYCJobPending    = "pending"
+	KYCJobProcessing = "processing"
+	KYCJobSuccess    = "success"
+	KYCJobFailed     = "failed"
+	KYCJobCancelled  = "cancelled"
+)
+
+// KYCJobQueueKey Redis任务队列（仅存放任务ID，任务本身以数据库为准）
+const KYCJobQueueKey = "kyc:jobs"
+
+const (
+	kycJobBaseBackoff   = 30 * time.Second
+	kycJobMaxBackoff    = 10 * time.Minute
+	kycJobWatchInterval = 2 * time.Second
+)
+
+// kycJobInput 异步任务输入（只保存资产ID，姓名等敏感字段从加密的 KYCRequest 读取）
+type kycJobInput struct {
+	IDCardAssetID string `json:"idcard_asset_id"`
+	FaceAssetID   string `json:"face_asset_id"`
+}
+
+func (s *KYCService) kycJobMaxAttempts() int {
+	if s.Config != nil && s.Config.Async.MaxAttempts > 0 {
+		return s.Config.Async.MaxAttempts
+	}
+	return 3
+}
+
+func (s *KYCService) kycJobTimeout() time.Duration {
+	if s.Config != nil && s.Config.Async.JobTimeout > 0 {
+		return s.Config.Async.JobTimeout
+	}
+	return 10 * time.Minute
+}
+
+// kycJobBackoff 第n次失败后的重试间隔
+func kycJobBackoff(attempts int) time.Duration {
+	d := kycJobBaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= kycJobMaxBackoff {
+			return kycJobMaxBackoff
+		}
+	}
+	return d
+}
+
+// SubmitCompleteKYCJob 落盘图片并创建异步任务，立即返回 request_id
+func (s *KYCService) SubmitCompleteKYCJob(ctx context.Context, req *CompleteKYCRequest) (*StandardCompleteKYCResponse, error) {
+	ctx, span := tracing.StartSpan(ctx, "KYCService.SubmitCompleteKYCJob")
+	defer span.End()
+	orgID := getOrgID(ctx)
+	if orgID == "" {
+		return nil, fmt.Errorf("缺少组织信息")
+	}
+	idAsset, err := s.IngestImage(ctx, orgID, req.IDCardImage)
+	if err != nil {
+		return nil, err
+	}
+	faceAsset, err := s.IngestImage(ctx, orgID, req.FaceImage)
+	if err != nil {
+		return nil, err
+	}
+
+	kycRequest := s.newCompleteKYCRequest(ctx, req, KYCJobPending)
+	kycRequest.IDCardImage = idAsset.ID
+	kycRequest.FaceImage = faceAsset.ID
+	input, _ := json.Marshal(kycJobInput{IDCardAssetID: idAsset.ID, FaceAssetID: faceAsset.ID})
+	job := &models.KYCJob{
+		ID:          kycRequest.ID,
+		OrgID:       orgID,
+		UserID:      kycRequest.UserID,
+		JobType:     "complete",
+		Status:      KYCJobPending,
+		MaxAttempts: s.kycJobMaxAttempts(),
+		Input:       input,
+		NextRunAt:   time.Now(),
+	}
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(kycRequest).Error; err != nil {
+			return err
+		}
+		return tx.Create(job).Error
+	}); err != nil {
+		return nil, fmt.Errorf("创建请求失败")
+	}
+	s.enqueueKYCJob(ctx, job.ID)
+	s.RecordAuditLog(ctx, "kyc.job.submit", "kyc_request", job.ID, "success", "")
+
+	return &StandardCompleteKYCResponse{
+		RequestID: kycRequest.ID,
+		Status:    KYCJobPending,
+		Message:   "任务已提交",
+	}, nil
+}
+
+// enqueueKYCJob 推入Redis队列；失败时依赖工作池轮询数据库兜底
+func (s *KYCService) enqueueKYCJob(ctx context.Context, id string) {
+	if s.Redis == nil {
+		return
+	}
+	if err := s.Redis.LPush(ctx, KYCJobQueueKey, id).Err(); err != nil {
+		logger.GetLogger().WithError(err).Warn("KYC任务入队失败，将由数据库轮询处理")
+	}
+}
+
+// GetKYCJob 查询组织内的异步任务
+func (s *KYCService) GetKYCJob(orgID, id string) (*models.KYCJob, error) {
+	var job models.KYCJob
+	if err := s.DB.First(&job, "id = ? AND org_id = ?", id, orgID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKYCJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelKYCJob 取消排队中或处理中的任务；处理中的任务由工作协程感知后中止，
+// 其租约（locked_until）保留到工作协程停止后才清除，期间不能重试
+func (s *KYCService) CancelKYCJob(ctx context.Context, orgID, id string) (*models.KYCJob, error) {
+	now := time.Now()
+	res := s.DB.Model(&models.KYCJob{}).
+		Where("id = ? AND org_id = ? AND status IN ?", id, orgID, []string{KYCJobPending, KYCJobProcessing}).
+		Updates(map[string]interface{}{"status": KYCJobCancelled, "cancelled_at": now, "updated_at": now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		if _, err := s.GetKYCJob(orgID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrKYCJobInvalidState
+	}
+	_ = s.DB.Model(&models.KYCRequest{}).Where("id = ?", id).Updates(map[string]interface{}{"status": KYCJobCancelled, "error_message": "cancelled"}).Error
+	s.RecordAuditLog(ctx, "kyc.job.cancel", "kyc_request", id, "success", "")
+	return s.GetKYCJob(orgID, id)
+}
+
+// RetryKYCJob 重新执行失败或已取消的任务，已完成的步骤不会重复调用第三方。
+// 取消后工作协程仍在运行（租约未释放且未过期）时返回 ErrKYCJobStopping，避免两个工作协程同时执行
+func (s *KYCService) RetryKYCJob(ctx context.Context, orgID, id string) (*models.KYCJob, error) {
+	now := time.Now()
+	res := s.DB.Model(&models.KYCJob{}).
+		Where("id = ? AND org_id = ? AND status IN ?", id, orgID, []string{KYCJobFailed, KYCJobCancelled}).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Updates(map[string]interface{}{"status": KYCJobPending, "attempts": 0, "next_run_at": now, "locked_until": nil, "cancelled_at": nil, "finished_at": nil, "last_error": "", "updated_at": now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		job, err := s.GetKYCJob(orgID, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Status == KYCJobCancelled && job.LockedUntil != nil {
+			return nil, ErrKYCJobStopping
+		}
+		return nil, ErrKYCJobInvalidState
+	}
+	_ = s.DB.Model(&models.KYCRequest{}).Where("id = ?", id).Updates(map[string]interface{}{"status": KYCJobPending, "error_message": ""}).Error
+	s.enqueueKYCJob(ctx, id)
+	s.RecordAuditLog(ctx, "kyc.job.retry", "kyc_request", id, "success", "")
+	return s.GetKYCJob(orgID, id)
+}
+
+// kycJobClaimUpdates 领取任务时的更新：置为处理中、计次、设置租约与新的领取令牌
+func (s *KYCService) kycJobClaimUpdates(now time.Time, token string) map[string]interface{} {
+	return map[string]interface{}{"status": KYCJobProcessing, "attempts": gorm.Expr("attempts + 1"),
+		"locked_until": now.Add(s.kycJobTimeout()), "claim_token": token, "updated_at": now}
+}
+
+// ClaimKYCJob 领取指定任务（来自Redis队列），并发领取时只有一个成功
+func (s *KYCService) ClaimKYCJob(id string) (*models.KYCJob, bool) {
+	now := time.Now()
+	token := uuid.NewString()
+	var job models.KYCJob
+	res := s.DB.Model(&job).Clauses(clause.Returning{}).
+		Where("id = ? AND status = ? AND next_run_at <= ?", id, KYCJobPending, now).
+		Updates(s.kycJobClaimUpdates(now, token))
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, false
+	}
+	job.ClaimToken = token
+	return &job, true
+}
+
+// ClaimNextKYCJob 从数据库领取一个到期任务（含租约过期的处理中任务），作为Redis不可用时的兜底
+func (s *KYCService) ClaimNextKYCJob() (*models.KYCJob, bool) {
+	var job models.KYCJob
+	now := time.Now()
+	token := uuid.NewString()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_run_at <= ?) OR (status = ? AND locked_until < ?)", KYCJobPending, now, KYCJobProcessing, now).
+			Order("next_run_at").First(&job).Error; err != nil {
+			return err
+		}
+		return tx.Model(&job).Clauses(clause.Returning{}).Updates(s.kycJobClaimUpdates(now, token)).Error
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.GetLogger().WithError(err).Warn("领取KYC任务失败")
+		}
+		return nil, false
+	}
+	job.ClaimToken = token
+	return &job, true
+}
+
+// ProcessKYCJob 执行已领取的任务：可重试错误按退避重新排队，超过次数后标记失败
+func (s *KYCService) ProcessKYCJob(ctx context.Context, job *models.KYCJob) {
+	var kycRequest models.KYCRequest
+	if err := s.DB.First(&kycRequest, "id = ?", job.ID).Error; err != nil {
+		s.failKYCJob(job, "request not found")
+		return
+	}
+	var input kycJobInput
+	_ = json.Unmarshal(job.Input, &input)
+	var cp completeKYCCheckpoint
+	if len(job.Checkpoint) > 0 {
+		_ = json.Unmarshal(job.Checkpoint, &cp)
+	}
+	// 解密失败（如密钥不一致）时不能以空的姓名/证件号继续执行
+	name, err := s.Encryptor.Decrypt(kycRequest.Name)
+	if err != nil {
+		logger.GetLogger().WithError(err).Errorf("KYC任务 %s 解密姓名失败", job.ID)
+		s.failKYCJobRequest(job, &kycRequest, "decrypt request data failed")
+		return
+	}
+	idCard, err := s.Encryptor.Decrypt(kycRequest.IDCard)
+	if err != nil {
+		logger.GetLogger().WithError(err).Errorf("KYC任务 %s 解密证件号失败", job.ID)
+		s.failKYCJobRequest(job, &kycRequest, "decrypt request data failed")
+		return
+	}
+
+	ctx = context.WithValue(ctx, "org_id", job.OrgID)
+	ctx = context.WithValue(ctx, "user_id", job.UserID)
+	ctx = context.WithValue(ctx, "request_id", job.ID)
+	ctx = context.WithValue(ctx, "client_ip", kycRequest.IPAddress)
+	ctx = context.WithValue(ctx, "user_agent", kycRequest.UserAgent)
+	ctx, span := tracing.StartSpan(ctx, "KYCService.ProcessKYCJob")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, s.kycJobTimeout())
+	defer cancel()
+
+	_ = s.DB.Model(&kycRequest).Update("status", KYCJobProcessing).Error
+
+	// 无论以何种方式结束，已被取消的任务都在此释放租约，之后才允许重试
+	defer func() {
+		_ = s.DB.Model(&models.KYCJob{}).Where("id = ? AND status = ? AND claim_token = ?", job.ID, KYCJobCancelled, job.ClaimToken).
+			Update("locked_until", nil).Error
+	}()
+
+	// 监听取消并续租
+	cancelled := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(kycJobWatchInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				var cur models.KYCJob
+				_ = s.DB.Model(&models.KYCJob{}).Select("status", "claim_token").Where("id = ?", job.ID).Scan(&cur).Error
+				// 已取消，或租约过期后被其他工作协程重新领取
+				if cur.Status == KYCJobCancelled || (cur.Status != "" && cur.ClaimToken != job.ClaimToken) {
+					close(cancelled)
+					cancel()
+					return
+				}
+				_ = s.kycJobOwned(job).Update("locked_until", time.Now().Add(s.kycJobTimeout())).Error
+			}
+		}
+	}()
+
+	in := &completeKYCInput{
+		Name:       name,
+		IDCard:     idCard,
+		OpenIDCard: s.imageAssetOpener(input.IDCardAssetID),
+		OpenFace:   s.imageAssetOpener(input.FaceAssetID),
+		FacePath:   s.imageAssetPath(input.FaceAssetID),
+		Save: func(cp *completeKYCCheckpoint) error {
+			b, err := json.Marshal(cp)
+			if err != nil {
+				return err
+			}
+			res := s.kycJobOwned(job).Update("checkpoint", b)
+			if res.Error == nil && res.RowsAffected == 0 {
+				return errKYCJobLost
+			}
+			return res.Error
+		},
+	}
+	verdict, err := s.runCompleteKYC(ctx, in, &cp)
+
+	select {
+	case <-cancelled:
+		logger.GetLogger().WithField("job_id", job.ID).Info("KYC任务已取消或已被其他工作协程接管")
+		return
+	default:
+	}
+	if errors.Is(err, errKYCJobLost) {
+		logger.GetLogger().WithField("job_id", job.ID).Warn("KYC任务已被其他工作协程接管，放弃本次执行")
+		return
+	}
+
+	if err != nil {
+		if job.Attempts < job.MaxAttempts {
+			next := time.Now().Add(kycJobBackoff(job.Attempts))
+			_ = s.kycJobOwned(job).
+				Updates(map[string]interface{}{"status": KYCJobPending, "next_run_at": next, "locked_until": nil, "last_error": err.Error(), "updated_at": time.Now()}).Error
+			_ = s.DB.Model(&kycRequest).Update("status", KYCJobPending).Error
+			logger.GetLogger().WithError(err).WithField("job_id", job.ID).Warnf("KYC任务第%d次执行失败，稍后重试", job.Attempts)
+			return
+		}
+		verdict = &kycVerdict{Status: "failed", ErrorMessage: err.Error(), Message: "第三方服务暂不可用"}
+	}
+
+	now := time.Now()
+	res := s.kycJobOwned(job).
+		Updates(map[string]interface{}{"status": verdict.Status, "finished_at": now, "locked_until": nil, "last_error": verdict.ErrorMessage, "updated_at": now})
+	if res.Error != nil || res.RowsAffected == 0 {
+		// 期间被取消或被其他工作协程接管
+		return
+	}
+	s.finishKYCRequest(ctx, &kycRequest, verdict)
+}
+
+// errKYCJobLost 任务已被取消或被其他工作协程领取，本次执行的结果不再写入
+var errKYCJobLost = errors.New("kyc job no longer owned by this worker")
+
+// kycJobOwned 仅匹配仍由本次领取持有的任务（处理中且领取令牌一致）
+func (s *KYCService) kycJobOwned(job *models.KYCJob) *gorm.DB {
+	return s.DB.Model(&models.KYCJob{}).Where("id = ? AND status = ? AND claim_token = ?", job.ID, KYCJobProcessing, job.ClaimToken)
+}
+
+func (s *KYCService) failKYCJob(job *models.KYCJob, reason string) {
+	now := time.Now()
+	_ = s.kycJobOwned(job).
+		Updates(map[string]interface{}{"status": KYCJobFailed, "finished_at": now, "locked_until": nil, "last_error": reason, "updated_at": now}).Error
+}
+
+// failKYCJobRequest 任务无法执行（不可重试）时同时将KYC请求标记为失败
+func (s *KYCService) failKYCJobRequest(job *models.KYCJob, kycRequest *models.KYCRequest, reason string) {
+	s.failKYCJob(job, reason)
+	_ = s.DB.Model(kycRequest).Updates(map[string]interface{}{"status": "failed", "error_message": reason}).Error
+}
+
+func (s *KYCService) imageAssetOpener(assetID string) func() (io.ReadCloser, string, error) {
+	return func() (io.ReadCloser, string, error) {
+		var asset models.ImageAsset
+		if err := s.DB.First(&asset, "id = ?", assetID).Error; err != nil {
+			return nil, "", fmt.Errorf("image asset not found: %w", err)
+		}
+		f, err := s.OpenAsset(context.Background(), asset.StorageKey, asset.FilePath, asset.DataKeyID)
+		return f, asset.SafeFilename, err
+	}
+}
+
+func (s *KYCService) imageAssetPath(assetID string) func() (string, func(), error) {
+	return func() (string, func(), error) {
+		var asset models.ImageAsset
+		if err := s.DB.First(&asset, "id = ?", assetID).Error; err != nil {
+			return "", func() {}, fmt.Errorf("image asset not found: %w", err)
+		}
+		return s.AssetLocalPath(context.Background(), asset.StorageKey, asset.FilePath, asset.DataKeyID)
+	}
+}
+
+// PopKYCJobID 从Redis队列阻塞获取任务ID
+func (s *KYCService) PopKYCJobID(ctx context.Context, timeout time.Duration) (string, error) {
+	res, err := s.Redis.BRPop(ctx, timeout, KYCJobQueueKey).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(res) != 2 {
+		return "", redis.Nil
+	}
+	return res[1], nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyc-service/internal/config"
+	"kyc-service/internal/models"
+	"kyc-service/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newKYCJobTestService(t *testing.T) *KYCService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&models.KYCJob{}, &models.KYCRequest{}, &models.AuditLog{}))
+	// 取消/重试会写审计，审计指标需先初始化
+	require.NoError(t, metrics.InitOTelMetrics())
+	return &KYCService{DB: db, Config: &config.Config{}}
+}
+
+func TestRetryWaitsForCancelledWorker(t *testing.T) {
+	s := newKYCJobTestService(t)
+	ctx := context.Background()
+	require.NoError(t, s.DB.Create(&models.KYCJob{ID: "job_1", OrgID: "org_1", Status: KYCJobPending, MaxAttempts: 3, NextRunAt: time.Now().Add(-time.Second)}).Error)
+
+	job, ok := s.ClaimKYCJob("job_1")
+	require.True(t, ok)
+	require.NotEmpty(t, job.ClaimToken)
+
+	_, err := s.CancelKYCJob(ctx, "org_1", "job_1")
+	require.NoError(t, err)
+	// 工作协程尚未停止（租约未释放），不能重试
+	_, err = s.RetryKYCJob(ctx, "org_1", "job_1")
+	assert.ErrorIs(t, err, ErrKYCJobStopping)
+
+	// 被取消的执行不能再写检查点
+	res := s.kycJobOwned(job).Update("checkpoint", []byte(`{}`))
+	require.NoError(t, res.Error)
+	assert.Zero(t, res.RowsAffected)
+
+	// 工作协程停止后释放租约，重试重新排队
+	require.NoError(t, s.DB.Model(&models.KYCJob{}).Where("id = ?", "job_1").Update("locked_until", nil).Error)
+	retried, err := s.RetryKYCJob(ctx, "org_1", "job_1")
+	require.NoError(t, err)
+	assert.Equal(t, KYCJobPending, retried.Status)
+
+	// 重新领取后令牌更换，旧的执行仍不能写入
+	again, ok := s.ClaimKYCJob("job_1")
+	require.True(t, ok)
+	assert.NotEqual(t, job.ClaimToken, again.ClaimToken)
+	res = s.kycJobOwned(job).Update("checkpoint", []byte(`{}`))
+	require.NoError(t, res.Error)
+	assert.Zero(t, res.RowsAffected)
+	res = s.kycJobOwned(again).Update("checkpoint", []byte(`{}`))
+	require.NoError(t, res.Error)
+	assert.Equal(t, int64(1), res.RowsAffected)
+}
+
+func TestRetryAfterCancelledLeaseExpires(t *testing.T) {
+	s := newKYCJobTestService(t)
+	ctx := context.Background()
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, s.DB.Create(&models.KYCJob{ID: "job_2", OrgID: "org_1", Status: KYCJobCancelled, LockedUntil: &expired}).Error)
+	// 工作协程崩溃未释放租约时，租约过期后允许重试
+	job, err := s.RetryKYCJob(ctx, "org_1", "job_2")
+	require.NoError(t, err)
+	assert.Equal(t, KYCJobPending, job.Status)
+	assert.Nil(t, job.LockedUntil)
+}
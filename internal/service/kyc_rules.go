@@ -0,0 +1,255 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// KYCDecisionRules 组织级完整KYC判定规则（存于 global_configs，key 为 kyc_rules:<org_id>）
+type KYCDecisionRules struct {
+	OCRType              string   `json:"ocr_type"`
+	OCRLanguage          string   `json:"ocr_language"`
+	NameFields           []string `json:"name_fields"`      // OCR结果中姓名字段名（按顺序匹配）
+	IDNumberFields       []string `json:"id_number_fields"` // OCR结果中证件号字段名
+	RequireNameMatch     bool     `json:"require_name_match"`
+	RequireIDNumberMatch bool     `json:"require_id_number_match"`
+	MinOCRConfidence     float64  `json:"min_ocr_confidence"`
+	MinFaceScore         float64  `json:"min_face_score"`
+	RequireLiveness      bool     `json:"require_liveness"`
+	MinLivenessScore     float64  `json:"min_liveness_score"`
+}
+
+// DefaultKYCDecisionRules 默认判定规则
+func DefaultKYCDecisionRules() KYCDecisionRules {
+	return KYCDecisionRules{
+		OCRType:              "id_card",
+		NameFields:           []string{"name", "full_name", "nama", "姓名"},
+		IDNumberFields:       []string{"id_number", "id_card", "idcard", "nik", "公民身份号码"},
+		RequireNameMatch:     true,
+		RequireIDNumberMatch: true,
+		MinOCRConfidence:     0.6,
+		MinFaceScore:         0.8,
+		RequireLiveness:      false,
+		MinLivenessScore:     0.5,
+	}
+}
+
+func kycRulesKey(orgID string) string { return "kyc_rules:" + orgID }
+
+// GetKYCDecisionRules 读取组织判定规则，未配置的字段使用默认值
+func (s *KYCService) GetKYCDecisionRules(orgID string) KYCDecisionRules {
+	rules := DefaultKYCDecisionRules()
+	if orgID == "" {
+		return rules
+	}
+	var raw string
+	_ = s.DB.Raw("SELECT value FROM global_configs WHERE key = ?", kycRulesKey(orgID)).Scan(&raw).Error
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &rules)
+	}
+	return rules
+}
+
+// Validate 校验规则取值
+func (r *KYCDecisionRules) Validate() error {
+	for name, v := range map[string]float64{"min_ocr_confidence": r.MinOCRConfidence, "min_face_score": r.MinFaceScore, "min_liveness_score": r.MinLivenessScore} {
+		if v < 0 || v > 1 {
+			return fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+	if ocrGetProcessor(r.OCRType) == nil {
+		return fmt.Errorf("unsupported ocr_type: %s", r.OCRType)
+	}
+	if r.RequireNameMatch && len(r.NameFields) == 0 {
+		return fmt.Errorf("name_fields is required when require_name_match is enabled")
+	}
+	if r.RequireIDNumberMatch && len(r.IDNumberFields) == 0 {
+		return fmt.Errorf("id_number_fields is required when require_id_number_match is enabled")
+	}
+	return nil
+}
+
+// SaveKYCDecisionRules 保存组织判定规则
+func (s *KYCService) SaveKYCDecisionRules(orgID string, rules KYCDecisionRules) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return s.DB.Exec("INSERT INTO global_configs(key, value, updated_at) VALUES(?, ?, ?) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at", kycRulesKey(orgID), string(b), time.Now()).Error
+}
+
+// KYCResultDetail 写入 KYCRequest.Result 的结构化结果（不含PII）
+type KYCResultDetail struct {
+	Decision        string           `json:"decision"`
+	Reasons         []string         `json:"reasons,omitempty"`
+	OCR             *KYCOCRStep      `json:"ocr,omitempty"`
+	Face            *KYCFaceStep     `json:"face,omitempty"`
+	Liveness        *KYCLivenessStep `json:"liveness,omitempty"`
+	OCRScore        float64          `json:"ocr_score"`
+	FaceVerifyScore float64          `json:"face_verify_score"`
+	LivenessScore   float64          `json:"liveness_score,omitempty"`
+	Confidence      float64          `json:"confidence"`
+	Rules           KYCDecisionRules `json:"rules"`
+}
+
+type KYCOCRStep struct {
+	VendorCode    int     `json:"vendor_code"`
+	Score         float64 `json:"score"`
+	NameFound     bool    `json:"name_found"`
+	NameMatch     bool    `json:"name_match"`
+	IDNumberFound bool    `json:"id_number_found"`
+	IDNumberMatch bool    `json:"id_number_match"`
+	Passed        bool    `json:"passed"`
+}
+
+type KYCFaceStep struct {
+	VendorCode int     `json:"vendor_code"`
+	Score      float64 `json:"score"`
+	IsSameFace bool    `json:"is_same_face"`
+	Passed     bool    `json:"passed"`
+}
+
+type KYCLivenessStep struct {
+	VendorCode int     `json:"vendor_code"`
+	Score      float64 `json:"score"`
+	IsLive     bool    `json:"is_live"`
+	Passed     bool    `json:"passed"`
+}
+
+// ocrField 按候选字段名（忽略大小写）在OCR结果中查找字段
+func ocrField(res *OCRResponse, keys []string) (OCRItem, bool) {
+	if res == nil {
+		return OCRItem{}, false
+	}
+	for _, k := range keys {
+		if v, ok := res.ParsingResults[k]; ok && strings.TrimSpace(v.Text) != "" {
+			return v, true
+		}
+		for rk, v := range res.ParsingResults {
+			if strings.EqualFold(rk, k) && strings.TrimSpace(v.Text) != "" {
+				return v, true
+			}
+		}
+	}
+	return OCRItem{}, false
+}
+
+// normalizeName 去除空白与常见分隔符并忽略大小写
+func normalizeName(v string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(v) {
+		if unicode.IsSpace(r) || r == '·' || r == '.' || r == ',' || r == '-' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// normalizeIDNumber 去除空白与分隔符并统一大写
+func normalizeIDNumber(v string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(v) {
+		if unicode.IsSpace(r) || r == '-' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// evaluateOCR 按规则校验OCR结果中的姓名与证件号
+func evaluateOCR(res *OCRResponse, name, idNumber string, rules KYCDecisionRules) (*KYCOCRStep, []string) {
+	step := &KYCOCRStep{VendorCode: res.Code}
+	if res.Code != 0 {
+		return step, []string{"ocr_failed"}
+	}
+	var reasons []string
+	var confSum float64
+	var confN int
+	if v, ok := ocrField(res, rules.NameFields); ok {
+		step.NameFound = true
+		step.NameMatch = normalizeName(v.Text) == normalizeName(name)
+		confSum += v.Confidence
+		confN++
+	}
+	if v, ok := ocrField(res, rules.IDNumberFields); ok {
+		step.IDNumberFound = true
+		step.IDNumberMatch = normalizeIDNumber(v.Text) == normalizeIDNumber(idNumber)
+		confSum += v.Confidence
+		confN++
+	}
+	if confN > 0 {
+		step.Score = confSum / float64(confN)
+	}
+	if rules.RequireNameMatch {
+		if !step.NameFound {
+			reasons = append(reasons, "ocr_name_missing")
+		} else if !step.NameMatch {
+			reasons = append(reasons, "name_mismatch")
+		}
+	}
+	if rules.RequireIDNumberMatch {
+		if !step.IDNumberFound {
+			reasons = append(reasons, "ocr_id_number_missing")
+		} else if !step.IDNumberMatch {
+			reasons = append(reasons, "id_number_mismatch")
+		}
+	}
+	if confN > 0 && step.Score < rules.MinOCRConfidence {
+		reasons = append(reasons, "ocr_low_confidence")
+	}
+	step.Passed = len(reasons) == 0
+	return step, reasons
+}
+
+// evaluateFace 按规则校验证件照与自拍的比对结果
+func evaluateFace(res *FaceCompareResponse, rules KYCDecisionRules) (*KYCFaceStep, []string) {
+	step := &KYCFaceStep{VendorCode: res.Code, Score: res.ComparisonResults.Confidence, IsSameFace: res.ComparisonResults.IsSameFace == 1}
+	switch {
+	case res.Code != 0:
+		return step, []string{"face_compare_failed"}
+	case !step.IsSameFace || step.Score < rules.MinFaceScore:
+		return step, []string{"face_mismatch"}
+	}
+	step.Passed = true
+	return step, nil
+}
+
+// evaluateLiveness 按规则校验静默活体结果
+func evaluateLiveness(res *LivenessSilentResponse, rules KYCDecisionRules) (*KYCLivenessStep, []string) {
+	step := &KYCLivenessStep{VendorCode: res.Code, Score: res.LivenessResults.Confidence, IsLive: res.LivenessResults.IsLiveness == 1}
+	switch {
+	case res.Code != 0:
+		return step, []string{"liveness_failed"}
+	case !step.IsLive || step.Score < rules.MinLivenessScore:
+		return step, []string{"liveness_not_passed"}
+	}
+	step.Passed = true
+	return step, nil
+}
+
+// kycReasonMessage 失败原因对应的提示信息
+func kycReasonMessage(reason string) string {
+	switch reason {
+	case "ocr_failed", "ocr_name_missing", "ocr_id_number_missing":
+		return "身份证识别失败"
+	case "name_mismatch", "id_number_mismatch":
+		return "身份信息不匹配"
+	case "ocr_low_confidence":
+		return "证件识别置信度过低"
+	case "face_compare_failed", "face_mismatch":
+		return "人脸比对未通过"
+	case "liveness_failed", "liveness_not_passed":
+		return "活体检测未通过"
+	case "image_rejected":
+		return "图片不符合要求（分辨率过低或格式不支持）"
+	}
+	return "KYC认证失败"
+}
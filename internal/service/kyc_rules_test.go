@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateOCR(t *testing.T) {
+	rules := DefaultKYCDecisionRules()
+	ocr := func(name, id string) *OCRResponse {
+		return &OCRResponse{ParsingResults: map[string]OCRItem{
+			"Name":      {Text: name, Confidence: 0.9},
+			"id_number": {Text: id, Confidence: 0.8},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		res     *OCRResponse
+		reasons []string
+	}{
+		{"姓名与证件号一致", ocr("Zhang San", "11010119900101123x"), nil},
+		{"忽略大小写与空白", ocr("ZHANG  SAN", "110101 19900101 123X"), nil},
+		{"姓名不一致", ocr("Li Si", "11010119900101123X"), []string{"name_mismatch"}},
+		{"证件号不一致", ocr("Zhang San", "110101199001011230"), []string{"id_number_mismatch"}},
+		{"缺少字段", &OCRResponse{ParsingResults: map[string]OCRItem{}}, []string{"ocr_name_missing", "ocr_id_number_missing"}},
+		{"识别失败", &OCRResponse{Code: 500}, []string{"ocr_failed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, reasons := evaluateOCR(tt.res, "Zhang San", "11010119900101123X", rules)
+			assert.Equal(t, tt.reasons, reasons)
+			assert.Equal(t, len(tt.reasons) == 0, step.Passed)
+		})
+	}
+}
+
+func TestEvaluateFaceThreshold(t *testing.T) {
+	rules := DefaultKYCDecisionRules()
+	res := &FaceCompareResponse{}
+	res.ComparisonResults.IsSameFace = 1
+	res.ComparisonResults.Confidence = 0.79
+	_, reasons := evaluateFace(res, rules)
+	assert.Equal(t, []string{"face_mismatch"}, reasons)
+
+	rules.MinFaceScore = 0.7
+	step, reasons := evaluateFace(res, rules)
+	assert.Empty(t, reasons)
+	assert.True(t, step.Passed)
+
+	// 供应商判定不是同一人时，分数再高也不通过
+	res.ComparisonResults.IsSameFace = 0
+	res.ComparisonResults.Confidence = 0.99
+	step, reasons = evaluateFace(res, rules)
+	assert.Equal(t, []string{"face_mismatch"}, reasons)
+	assert.False(t, step.Passed)
+}
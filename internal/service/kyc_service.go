@@ -2,16 +2,24 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
+	"strings"
 	"time"
 
 	"kyc-service/internal/config"
 	"kyc-service/internal/models"
+	"kyc-service/pkg/blobstore"
 	"kyc-service/pkg/crypto"
 	"kyc-service/pkg/httpclient"
+	"kyc-service/pkg/jwtkeys"
 	"kyc-service/pkg/logger"
 	"kyc-service/pkg/metrics"
 	"kyc-service/pkg/tracing"
@@ -29,7 +37,13 @@ type KYCService struct {
 	Config     *config.Config
 	Encryptor  *crypto.Encryptor
 	Upgrader   websocket.Upgrader
-	HTTPClient *httpclient.Client // 新增HTTP客户端
+	HTTPClient *httpclient.Client  // 新增HTTP客户端
+	Providers  *ProviderRegistry   // OCR/人脸/活体供应商路由
+	JWTKeys    *jwtkeys.Set        // JWT签名/验签密钥，由 InitJWTKeys 初始化
+	Blobs      blobstore.BlobStore // 图片/视频对象存储，默认本地，由 InitBlobStore 按配置切换
+
+	providerGuard *providerGuard
+	jwtAsymmetric bool // InitJWTKeys 成功启用 RS256/ES256 签名
 
 	// OTel指标
 	ocrSuccessRate        metric.Float64Gauge
@@ -119,6 +133,9 @@ func NewKYCService(db *gorm.DB, redis *redis.Client, cfg *config.Config) *KYCSer
 		},
 	}
 
+	service.Providers = service.newProviderRegistry()
+	service.Blobs = service.newLocalBlobStore()
+
 	// 初始化OTel指标
 	if cfg.Monitoring.Metrics.Enabled {
 		meter := tracing.GetMeter()
@@ -195,25 +212,19 @@ type CompleteKYCRequest struct {
 	Name        string                `form:"name" binding:"required"`
 	IDCard      string                `form:"idcard" binding:"required"`
 	Phone       string                `form:"phone"`
-}
-
-// CompleteKYCResponse 完整KYC响应
-type CompleteKYCResponse struct {
-	RequestID string `json:"request_id"`
-	Status    string `json:"status"`
-	Message   string `json:"message"`
+	Async       bool                  `form:"async"` // 为 true 时仅入队并立即返回 request_id
 }
 
 // StandardCompleteKYCResponse 标准完整KYC响应
 type StandardCompleteKYCResponse struct {
-	RequestID       string    `json:"request_id"`
-	Status          string    `json:"status"`
-	Message         string    `json:"message"`
-	OCRScore        float64   `json:"ocr_score"`
-	FaceVerifyScore float64   `json:"face_verify_score"`
-	Confidence      float64   `json:"confidence"`
-	ProcessingTime  int64     `json:"processing_time_ms"`
-	CompletedAt     time.Time `json:"completed_at"`
+	RequestID       string     `json:"request_id"`
+	Status          string     `json:"status"`
+	Message         string     `json:"message"`
+	OCRScore        float64    `json:"ocr_score"`
+	FaceVerifyScore float64    `json:"face_verify_score"`
+	Confidence      float64    `json:"confidence"`
+	ProcessingTime  int64      `json:"processing_time_ms"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 }
 
 // OCR 方法已迁移至 ocr_service.go
@@ -238,6 +249,7 @@ func (s *KYCService) LivenessWebSocket(ctx context.Context, conn *websocket.Conn
 	kycRequest := &models.KYCRequest{
 		ID:          uuid.New().String(),
 		UserID:      getUserID(ctx),
+		OrgID:       getOrgID(ctx),
 		RequestType: "liveness",
 		Status:      "processing",
 		IPAddress:   getClientIP(ctx),
@@ -300,7 +312,11 @@ func (s *KYCService) LivenessWebSocket(ctx context.Context, conn *websocket.Conn
 }
 
 // CompleteKYC 执行完整KYC流程
-func (s *KYCService) CompleteKYC(ctx context.Context, req *CompleteKYCRequest) (*CompleteKYCResponse, error) {
+func (s *KYCService) CompleteKYC(ctx context.Context, req *CompleteKYCRequest) (*StandardCompleteKYCResponse, error) {
+	if req.Async {
+		return s.SubmitCompleteKYCJob(ctx, req)
+	}
+
 	ctx, span := tracing.StartSpan(ctx, "KYCService.CompleteKYC")
 	defer span.End()
 
@@ -312,11 +328,41 @@ func (s *KYCService) CompleteKYC(ctx context.Context, req *CompleteKYCRequest) (
 	}()
 
 	// 创建KYC请求记录
+	kycRequest := s.newCompleteKYCRequest(ctx, req, "processing")
+	if err := s.DB.Create(kycRequest).Error; err != nil {
+		return nil, fmt.Errorf("创建请求失败")
+	}
+
+	in := &completeKYCInput{
+		Name:       req.Name,
+		IDCard:     req.IDCard,
+		OpenIDCard: multipartOpener(req.IDCardImage),
+		OpenFace:   multipartOpener(req.FaceImage),
+		FacePath: func() (string, func(), error) {
+			asset, err := s.IngestImage(ctx, getOrgID(ctx), req.FaceImage)
+			if err != nil {
+				return "", func() {}, err
+			}
+			// 关联自拍资产，便于按数据主体导出/删除
+			_ = s.DB.Model(kycRequest).Update("face_image", asset.ID).Error
+			return s.AssetLocalPath(ctx, asset.StorageKey, asset.FilePath, asset.DataKeyID)
+		},
+	}
+	verdict, err := s.runCompleteKYC(ctx, in, &completeKYCCheckpoint{})
+	if err != nil {
+		verdict = &kycVerdict{Status: "failed", ErrorMessage: err.Error(), Message: "第三方服务暂不可用"}
+	}
+	return s.finishKYCRequest(ctx, kycRequest, verdict), nil
+}
+
+// newCompleteKYCRequest 构造完整KYC请求记录（敏感字段加密存储）
+func (s *KYCService) newCompleteKYCRequest(ctx context.Context, req *CompleteKYCRequest, status string) *models.KYCRequest {
 	kycRequest := &models.KYCRequest{
 		ID:          uuid.New().String(),
 		UserID:      getUserID(ctx),
+		OrgID:       getOrgID(ctx),
 		RequestType: "complete",
-		Status:      "processing",
+		Status:      status,
 		IPAddress:   getClientIP(ctx),
 		UserAgent:   getUserAgent(ctx),
 	}
@@ -330,82 +376,237 @@ func (s *KYCService) CompleteKYC(ctx context.Context, req *CompleteKYCRequest) (
 	kycRequest.Name = encryptedName
 	kycRequest.Phone = encryptedPhone
 	kycRequest.IDCardHash = crypto.HashIDCard(req.IDCard)
+	kycRequest.PhoneHash = s.hashPhone(req.Phone)
+	return kycRequest
+}
 
-	if err := s.DB.Create(kycRequest).Error; err != nil {
-		return nil, fmt.Errorf("创建请求失败")
+// completeKYCInput 完整KYC的处理输入；同步请求读取上传文件，异步任务读取已落盘的图片
+type completeKYCInput struct {
+	Name       string
+	IDCard     string
+	OpenIDCard func() (io.ReadCloser, string, error)
+	OpenFace   func() (io.ReadCloser, string, error)
+	// FacePath 自拍的本地文件路径（静默活体按路径调用），用完后调用返回的 cleanup
+	FacePath func() (string, func(), error)
+	// Save 每完成一个步骤后保存中间结果（同步请求为空）
+	Save func(*completeKYCCheckpoint) error
+}
+
+// completeKYCCheckpoint 已完成步骤的中间结果，重试时跳过已完成的步骤
+type completeKYCCheckpoint struct {
+	OCR      *OCRResponse            `json:"ocr,omitempty"`
+	Face     *FaceCompareResponse    `json:"face,omitempty"`
+	Liveness *LivenessSilentResponse `json:"liveness,omitempty"`
+}
+
+// kycVerdict 完整KYC的最终结论
+type kycVerdict struct {
+	Status       string
+	ErrorMessage string
+	Message      string
+	Detail       *KYCResultDetail
+}
+
+func multipartOpener(fh *multipart.FileHeader) func() (io.ReadCloser, string, error) {
+	return func() (io.ReadCloser, string, error) {
+		if fh == nil {
+			return nil, "", fmt.Errorf("missing file")
+		}
+		f, err := fh.Open()
+		return f, fh.Filename, err
+	}
+}
+
+// readOpened 读出图片全部内容
+func readOpened(open func() (io.ReadCloser, string, error)) ([]byte, string, error) {
+	f, filename, err := open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	return b, filename, err
+}
+
+// save 保存断点；任务已不归本次执行所有时返回 errKYCJobLost，调用方须停止后续步骤，其他错误只记录
+func (cp *completeKYCCheckpoint) save(in *completeKYCInput) error {
+	if in.Save == nil {
+		return nil
+	}
+	err := in.Save(cp)
+	if errors.Is(err, errKYCJobLost) {
+		return err
+	}
+	if err != nil {
+		logger.GetLogger().WithError(err).Warn("保存KYC任务断点失败")
+	}
+	return nil
+}
+
+// runCompleteKYC 依次执行 OCR → 人脸比对 → 静默活体（可选），按组织规则给出结论；
+// 返回的 error 表示上游暂时不可用等可重试错误
+func (s *KYCService) runCompleteKYC(ctx context.Context, in *completeKYCInput, cp *completeKYCCheckpoint) (*kycVerdict, error) {
+	rules := s.GetKYCDecisionRules(getOrgID(ctx))
+	detail := &KYCResultDetail{Rules: rules}
+	fail := func(reasons []string) *kycVerdict {
+		detail.Decision = "failed"
+		detail.Reasons = reasons
+		detail.Confidence = kycConfidence(detail)
+		return &kycVerdict{Status: "failed", ErrorMessage: strings.Join(reasons, ","), Message: kycReasonMessage(reasons[0]), Detail: detail}
 	}
 
 	// 执行OCR识别
-	ocrResult, err := s.callOCRService(ctx, &OCRRequest{Picture: req.IDCardImage})
-	if err != nil || ocrResult.Code != 0 {
-		kycRequest.Status = "failed"
-		kycRequest.ErrorMessage = "ocr task error"
-		s.DB.Save(kycRequest)
-
-		// 记录失败指标
-		if s.kycSuccessRate != nil {
-			s.kycSuccessRate.Record(ctx, 0.0)
+	if cp.OCR == nil {
+		f, filename, err := in.OpenIDCard()
+		if err != nil {
+			return fail([]string{"ocr_failed"}), nil
 		}
+		ocrResult, err := s.callOCRReader(ctx, f, filename, rules.OCRType, rules.OCRLanguage)
+		f.Close()
+		if errors.Is(err, ErrImageRejected) {
+			return fail([]string{"image_rejected"}), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cp.OCR = ocrResult
+		if err := cp.save(in); err != nil {
+			return nil, err
+		}
+	}
+	ocrStep, reasons := evaluateOCR(cp.OCR, in.Name, in.IDCard, rules)
+	detail.OCR, detail.OCRScore = ocrStep, ocrStep.Score
+	if len(reasons) > 0 {
+		return fail(reasons), nil
+	}
 
-		return &CompleteKYCResponse{
-			RequestID: kycRequest.ID,
-			Status:    "failed",
-			Message:   "身份证识别失败",
-		}, nil
+	// 证件照与自拍人脸比对
+	if cp.Face == nil {
+		idImage, idName, err := readOpened(in.OpenIDCard)
+		if err != nil {
+			return fail([]string{"face_compare_failed"}), nil
+		}
+		faceImage, faceName, err := readOpened(in.OpenFace)
+		if err != nil {
+			return fail([]string{"face_compare_failed"}), nil
+		}
+		if idImage, idName, err = s.prepareImage(ctx, CapabilityFace, idImage, idName); err != nil {
+			return fail([]string{"image_rejected"}), nil
+		}
+		if faceImage, faceName, err = s.prepareImage(ctx, CapabilityFace, faceImage, faceName); err != nil {
+			return fail([]string{"image_rejected"}), nil
+		}
+		faceResult, err := s.Providers.FaceCompare(ctx, idImage, idName, faceImage, faceName)
+		if err != nil {
+			return nil, err
+		}
+		cp.Face = faceResult
+		if err := cp.save(in); err != nil {
+			return nil, err
+		}
+	}
+	faceStep, reasons := evaluateFace(cp.Face, rules)
+	detail.Face, detail.FaceVerifyScore = faceStep, faceStep.Score
+	if len(reasons) > 0 {
+		return fail(reasons), nil
 	}
 
-	// 验证身份信息
-	if ocrResult.Filename != req.Name {
-		kycRequest.Status = "failed"
-		kycRequest.ErrorMessage = "身份信息不匹配"
-		s.DB.Save(kycRequest)
+	// 静默活体（按组织规则启用）
+	if rules.RequireLiveness {
+		if cp.Liveness == nil {
+			path, cleanup, err := in.FacePath()
+			if err != nil {
+				return fail([]string{"liveness_failed"}), nil
+			}
+			prepPath, prepCleanup, err := s.prepareImageFile(ctx, CapabilityLiveness, path)
+			if err != nil {
+				cleanup()
+				if errors.Is(err, ErrImageRejected) {
+					return fail([]string{"image_rejected"}), nil
+				}
+				return fail([]string{"liveness_failed"}), nil
+			}
+			livenessResult, err := s.Providers.LivenessSilent(ctx, prepPath, rules.OCRLanguage)
+			prepCleanup()
+			cleanup()
+			if err != nil {
+				return nil, err
+			}
+			cp.Liveness = livenessResult
+			if err := cp.save(in); err != nil {
+				return nil, err
+			}
+		}
+		livenessStep, reasons := evaluateLiveness(cp.Liveness, rules)
+		detail.Liveness, detail.LivenessScore = livenessStep, livenessStep.Score
+		if len(reasons) > 0 {
+			return fail(reasons), nil
+		}
+	}
 
-		// 记录失败指标
-		if s.kycSuccessRate != nil {
-			s.kycSuccessRate.Record(ctx, 0.0)
+	detail.Decision = "success"
+	detail.Confidence = kycConfidence(detail)
+	return &kycVerdict{Status: "success", Message: "KYC认证成功", Detail: detail}, nil
+}
+
+// kycConfidence 综合置信度取已执行步骤得分的最小值
+func kycConfidence(d *KYCResultDetail) float64 {
+	var scores []float64
+	if d.OCR != nil {
+		scores = append(scores, d.OCRScore)
+	}
+	if d.Face != nil {
+		scores = append(scores, d.FaceVerifyScore)
+	}
+	if d.Liveness != nil {
+		scores = append(scores, d.LivenessScore)
+	}
+	if len(scores) == 0 {
+		return 0
+	}
+	min := scores[0]
+	for _, v := range scores[1:] {
+		if v < min {
+			min = v
 		}
+	}
+	return min
+}
 
-		return &CompleteKYCResponse{
-			RequestID: kycRequest.ID,
-			Status:    "failed",
-			Message:   "身份信息不匹配",
-		}, nil
-	}
-
-	// 执行人脸识别
-	//faceResult, err := s.callFaceService(ctx, req.IDCardImage, req.FaceImage)
-	//if err != nil || !faceResult.Success || faceResult.Score < 0.8 {
-	//	kycRequest.Status = "failed"
-	//	kycRequest.ErrorMessage = "人脸识别失败"
-	//	s.DB.Save(kycRequest)
-	//
-	//	// 记录失败指标
-	//	if s.kycSuccessRate != nil {
-	//		s.kycSuccessRate.Record(ctx, 0.0)
-	//	}
-	//
-	//	return &CompleteKYCResponse{
-	//		RequestID: kycRequest.ID,
-	//		Status:    "failed",
-	//		Message:   "人脸识别失败",
-	//	}, nil
-	//}
-
-	// 更新状态为成功
-	kycRequest.Status = "success"
-	//kycRequest.Result = fmt.Sprintf("score:%f", faceResult.Score)
+// finishKYCRequest 保存结论、记录指标并发送事件
+func (s *KYCService) finishKYCRequest(ctx context.Context, kycRequest *models.KYCRequest, v *kycVerdict) *StandardCompleteKYCResponse {
+	kycRequest.Status = v.Status
+	kycRequest.ErrorMessage = v.ErrorMessage
+	if v.Detail != nil {
+		if b, err := json.Marshal(v.Detail); err == nil {
+			kycRequest.Result = string(b)
+		}
+	}
 	s.DB.Save(kycRequest)
 
-	// 记录成功指标
 	if s.kycSuccessRate != nil {
-		s.kycSuccessRate.Record(ctx, 1.0)
+		if v.Status == "success" {
+			s.kycSuccessRate.Record(ctx, 1.0)
+		} else {
+			s.kycSuccessRate.Record(ctx, 0.0)
+		}
 	}
+	s.emitKYCEvent(ctx, WebhookEventKYCCompleted, kycRequest.ID, kycRequest.RequestType, v.Status, v.Message)
 
-	return &CompleteKYCResponse{
-		RequestID: kycRequest.ID,
-		Status:    "success",
-		Message:   "KYC认证成功",
-	}, nil
+	now := time.Now()
+	resp := &StandardCompleteKYCResponse{
+		RequestID:      kycRequest.ID,
+		Status:         v.Status,
+		Message:        v.Message,
+		ProcessingTime: now.Sub(kycRequest.CreatedAt).Milliseconds(),
+		CompletedAt:    &now,
+	}
+	if v.Detail != nil {
+		resp.OCRScore = v.Detail.OCRScore
+		resp.FaceVerifyScore = v.Detail.FaceVerifyScore
+		resp.Confidence = v.Detail.Confidence
+	}
+	return resp
 }
 
 // GetKYCStatus 获取KYC状态
@@ -448,6 +649,13 @@ func getOrgID(ctx context.Context) string {
 	return ""
 }
 
+func getRequestID(ctx context.Context) string {
+	if v, ok := ctx.Value("request_id").(string); ok {
+		return v
+	}
+	return ""
+}
+
 func getClientIP(ctx context.Context) string {
 	if ip, ok := ctx.Value("client_ip").(string); ok {
 		return ip
@@ -628,3 +836,28 @@ func (s *KYCService) GetAPIKeyBySecretHash(hash string) (*models.APIKey, error)
 // LivenessVideo migrated to liveness_service.go
 
 // LivenessSilent migrated to liveness_service.go
+
+// normalizePhone 去除手机号中的空格、横线、括号
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+}
+
+// hashPhone 手机号的查找键：归一化后以服务端密钥计算 HMAC-SHA256，为空时返回空。
+// 手机号取值空间小，不加密钥的哈希可被枚举还原；密钥由 security.encryption_key 派生，
+// 与加密个人信息的密钥同生命周期，更换后需重新计算 phone_hash
+func (s *KYCService) hashPhone(phone string) string {
+	phone = normalizePhone(phone)
+	if phone == "" {
+		return ""
+	}
+	key := sha256.Sum256([]byte("kyc-phone-lookup:" + s.Config.Security.EncryptionKey))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(phone))
+	return hex.EncodeToString(mac.Sum(nil))
+}
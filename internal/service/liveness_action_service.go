@@ -0,0 +1,419 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"mime/multipart"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/metrics"
+	"kyc-service/pkg/tracing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 动作活体会话状态
+const (
+	ActionSessionPending   = "pending"   // 已创建，等待上传
+	ActionSessionUploading = "uploading" // 部分动作已上传
+	ActionSessionReady     = "ready"     // 全部动作已上传，等待校验
+	ActionSessionPassed    = "passed"
+	ActionSessionFailed    = "failed"
+)
+
+const (
+	actionSessionTTL            = 5 * time.Minute
+	actionSessionExpiredGrace   = 10 * time.Minute // 过期后Redis中再保留一段时间，期间访问返回“已过期”而非“不存在”
+	actionSessionResultTTL      = 24 * time.Hour
+	actionSessionMinActions     = 2
+	actionSessionMaxActions     = 3
+	actionLivenessMinConfidence = 0.5
+)
+
+// LivenessActions 支持的动作（与 LivenessRequest.Action 一致）
+var LivenessActions = []string{"blink", "nod", "smile"}
+
+// ActionLivenessResult 单个动作的检测结果
+type ActionLivenessResult struct {
+	Action        string     `json:"action"`
+	AssetID       string     `json:"asset_id,omitempty"`
+	Uploaded      bool       `json:"uploaded"`
+	IsLive        bool       `json:"is_live"`
+	Confidence    float64    `json:"confidence"`
+	FaceExist     bool       `json:"face_exist"`
+	ActionMatched bool       `json:"action_matched"` // 供应商确认视频中完成了要求的动作
+	VendorCode    int        `json:"vendor_code"`
+	VendorMsg     string     `json:"vendor_msg,omitempty"`
+	UploadedAt    *time.Time `json:"uploaded_at,omitempty"`
+	FailReason    string     `json:"fail_reason,omitempty"`
+}
+
+// ActionLivenessSession 动作活体会话（存于Redis，按组织隔离）
+type ActionLivenessSession struct {
+	ID         string                 `json:"session_id"`
+	OrgID      string                 `json:"-"`
+	UserID     string                 `json:"-"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Language   string                 `json:"language,omitempty"`
+	Status     string                 `json:"status"`
+	Actions    []ActionLivenessResult `json:"actions"`
+	IsLive     bool                   `json:"is_live"`
+	Score      float64                `json:"score"`
+	CreatedAt  time.Time              `json:"created_at"`
+	ExpiresAt  time.Time              `json:"expires_at"`
+	VerifiedAt *time.Time             `json:"verified_at,omitempty"`
+}
+
+// actionSessionRecord 序列化到Redis的完整结构（包含不对外输出的字段）
+type actionSessionRecord struct {
+	ActionLivenessSession
+	OrgID  string `json:"org_id"`
+	UserID string `json:"user_id"`
+}
+
+func actionSessionKey(id string) string { return "liveness:action:" + id }
+
+// NextAction 返回下一个待上传的动作，全部上传后返回空
+func (a *ActionLivenessSession) NextAction() string {
+	for _, r := range a.Actions {
+		if !r.Uploaded {
+			return r.Action
+		}
+	}
+	return ""
+}
+
+// randomIntn 密码学安全的 [0, n) 随机数，动作序列不可被预测
+func randomIntn(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(fmt.Sprintf("crypto/rand unavailable: %v", err))
+	}
+	return int(v.Int64())
+}
+
+func randomActionSequence() []string {
+	actions := append([]string(nil), LivenessActions...)
+	for i := len(actions) - 1; i > 0; i-- {
+		j := randomIntn(i + 1)
+		actions[i], actions[j] = actions[j], actions[i]
+	}
+	n := actionSessionMinActions + randomIntn(actionSessionMaxActions-actionSessionMinActions+1)
+	return actions[:n]
+}
+
+// applyActionUpload 将一个动作的检测结果写入会话；须为下一个待上传的动作
+func applyActionUpload(cur *ActionLivenessSession, res ActionLivenessResult, now time.Time) error {
+	if cur.VerifiedAt != nil {
+		return ErrActionSessionVerified
+	}
+	if now.After(cur.ExpiresAt) {
+		return ErrActionSessionExpired
+	}
+	// 并发上传时以先写入者为准
+	if cur.NextAction() != res.Action {
+		return fmt.Errorf("%w: expected %s", ErrActionMismatch, cur.NextAction())
+	}
+	for i := range cur.Actions {
+		if !cur.Actions[i].Uploaded {
+			cur.Actions[i] = res
+			break
+		}
+	}
+	if cur.NextAction() == "" {
+		cur.Status = ActionSessionReady
+	} else {
+		cur.Status = ActionSessionUploading
+	}
+	return nil
+}
+
+// finalizeActionSession 汇总各动作结果：全部为活体才通过，得分取最低置信度
+func finalizeActionSession(cur *ActionLivenessSession, now time.Time) error {
+	if cur.VerifiedAt != nil {
+		return ErrActionSessionVerified
+	}
+	if now.After(cur.ExpiresAt) {
+		return ErrActionSessionExpired
+	}
+	if cur.NextAction() != "" {
+		return fmt.Errorf("%w: pending action %s", ErrActionSessionPending, cur.NextAction())
+	}
+	live := true
+	score := 1.0
+	for _, r := range cur.Actions {
+		if !r.IsLive {
+			live = false
+		}
+		if r.Confidence < score {
+			score = r.Confidence
+		}
+	}
+	cur.IsLive, cur.Score, cur.VerifiedAt = live, score, &now
+	if live {
+		cur.Status = ActionSessionPassed
+	} else {
+		cur.Status = ActionSessionFailed
+	}
+	return nil
+}
+
+func (s *KYCService) loadActionSession(ctx context.Context, get func(string) *redis.StringCmd, orgID, id string) (*ActionLivenessSession, error) {
+	raw, err := get(actionSessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrActionSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec actionSessionRecord
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, err
+	}
+	// 跨组织访问视为不存在
+	if rec.OrgID != orgID {
+		return nil, ErrActionSessionNotFound
+	}
+	sess := rec.ActionLivenessSession
+	sess.OrgID, sess.UserID = rec.OrgID, rec.UserID
+	return &sess, nil
+}
+
+func encodeActionSession(sess *ActionLivenessSession) ([]byte, error) {
+	return json.Marshal(actionSessionRecord{ActionLivenessSession: *sess, OrgID: sess.OrgID, UserID: sess.UserID})
+}
+
+// updateActionSession 以乐观锁方式读取-修改-写回会话
+func (s *KYCService) updateActionSession(ctx context.Context, orgID, id string, mutate func(*ActionLivenessSession) (time.Duration, error)) (*ActionLivenessSession, error) {
+	var out *ActionLivenessSession
+	key := actionSessionKey(id)
+	for i := 0; i < 3; i++ {
+		err := s.Redis.Watch(ctx, func(tx *redis.Tx) error {
+			sess, err := s.loadActionSession(ctx, func(k string) *redis.StringCmd { return tx.Get(ctx, k) }, orgID, id)
+			if err != nil {
+				return err
+			}
+			ttl, err := mutate(sess)
+			if err != nil {
+				return err
+			}
+			b, err := encodeActionSession(sess)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.Set(ctx, key, b, ttl)
+				return nil
+			})
+			if err == nil {
+				out = sess
+			}
+			return err
+		}, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return out, err
+	}
+	return nil, fmt.Errorf("会话并发更新冲突，请重试")
+}
+
+// GetActionLivenessSession 查询会话
+func (s *KYCService) GetActionLivenessSession(ctx context.Context, orgID, id string) (*ActionLivenessSession, error) {
+	sess, err := s.loadActionSession(ctx, func(k string) *redis.StringCmd { return s.Redis.Get(ctx, k) }, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sess.VerifiedAt == nil && time.Now().After(sess.ExpiresAt) {
+		return nil, ErrActionSessionExpired
+	}
+	return sess, nil
+}
+
+// CreateActionLivenessSession 创建动作活体会话，随机生成动作序列；按会话扣减一次活体配额
+func (s *KYCService) CreateActionLivenessSession(ctx context.Context, language string) (*ActionLivenessSession, error) {
+	ctx, span := tracing.StartSpan(ctx, "KYCService.CreateActionLivenessSession")
+	defer span.End()
+	orgID := getOrgID(ctx)
+	if orgID == "" {
+		return nil, fmt.Errorf("缺少组织信息")
+	}
+	span.SetAttributes(attribute.String("service.name", "liveness-service"), attribute.String("liveness.op", "action"), attribute.String("org.id", orgID))
+
+	now := time.Now().UTC()
+	sess := &ActionLivenessSession{
+		ID:        uuid.New().String(),
+		OrgID:     orgID,
+		UserID:    getUserID(ctx),
+		RequestID: getRequestID(ctx),
+		Language:  language,
+		Status:    ActionSessionPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(actionSessionTTL),
+	}
+	for _, a := range randomActionSequence() {
+		sess.Actions = append(sess.Actions, ActionLivenessResult{Action: a})
+	}
+	b, err := encodeActionSession(sess)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkAndConsumeQuota(ctx, orgID, "liveness", func() error {
+		return s.Redis.Set(ctx, actionSessionKey(sess.ID), b, actionSessionTTL+actionSessionExpiredGrace).Err()
+	}); err != nil {
+		if strings.Contains(err.Error(), "QUOTA_EXCEEDED") {
+			return nil, fmt.Errorf("Quota exceeded. Please upgrade your plan.")
+		}
+		return nil, err
+	}
+	s.RecordAuditLog(ctx, "liveness.action.session", "liveness", sess.ID, "success", strings.Join(sessionActionNames(sess), ","))
+	return sess, nil
+}
+
+func sessionActionNames(sess *ActionLivenessSession) []string {
+	names := make([]string, 0, len(sess.Actions))
+	for _, r := range sess.Actions {
+		names = append(names, r.Action)
+	}
+	return names
+}
+
+// UploadActionLiveness 上传某个动作的视频，须按会话给定顺序上传；action 为空时默认为下一个动作
+func (s *KYCService) UploadActionLiveness(ctx context.Context, sessionID, action string, file *multipart.FileHeader) (*ActionLivenessSession, error) {
+	ctx, span := tracing.StartSpan(ctx, "KYCService.UploadActionLiveness")
+	defer span.End()
+	orgID := getOrgID(ctx)
+	if orgID == "" {
+		return nil, fmt.Errorf("缺少组织信息")
+	}
+	sess, err := s.GetActionLivenessSession(ctx, orgID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess.VerifiedAt != nil {
+		return nil, ErrActionSessionVerified
+	}
+	expected := sess.NextAction()
+	if expected == "" {
+		return nil, fmt.Errorf("%w: all actions already uploaded", ErrActionMismatch)
+	}
+	if action == "" {
+		action = expected
+	}
+	if action != expected {
+		return nil, fmt.Errorf("%w: expected %s", ErrActionMismatch, expected)
+	}
+
+	asset, err := s.IngestVideo(ctx, orgID, sess.ID, file)
+	if err != nil {
+		return nil, err
+	}
+	path, cleanup, err := s.AssetLocalPath(ctx, asset.StorageKey, asset.FilePath, asset.DataKeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	start := time.Now()
+	out, err := s.Providers.LivenessVideo(ctx, path, sess.Language, action)
+	if err != nil {
+		metrics.RecordBusinessOperation(ctx, "liveness_action", false, time.Since(start), "third_party_error")
+		return nil, err
+	}
+	metrics.RecordBusinessOperation(ctx, "liveness_action", out.Code == 0, time.Since(start), "")
+
+	now := time.Now().UTC()
+	res := judgeActionClip(action, out)
+	res.AssetID = asset.ID
+	res.UploadedAt = &now
+
+	return s.updateActionSession(ctx, orgID, sessionID, func(cur *ActionLivenessSession) (time.Duration, error) {
+		if err := applyActionUpload(cur, res, time.Now().UTC()); err != nil {
+			return 0, err
+		}
+		return time.Until(cur.ExpiresAt) + actionSessionExpiredGrace, nil
+	})
+}
+
+// judgeActionClip 根据供应商结果判定单个动作：除活体外，供应商必须确认视频中完成的正是要求的动作，
+// 未返回 action_results（供应商不支持动作校验）时按失败处理
+func judgeActionClip(action string, out *LivenessVideoResponse) ActionLivenessResult {
+	res := ActionLivenessResult{
+		Action:     action,
+		Uploaded:   true,
+		VendorCode: out.Code,
+		VendorMsg:  out.Msg,
+		Confidence: out.LivenessResults.Confidence,
+		FaceExist:  out.LivenessResults.IsFaceExist > 0,
+	}
+	ar := out.ActionResults
+	res.ActionMatched = ar != nil && ar.Action == action && ar.IsActionMatched == 1
+	switch {
+	case out.Code != 0:
+		res.FailReason = "vendor_error"
+	case !res.FaceExist:
+		res.FailReason = "no_face"
+	case out.LivenessResults.IsLiveness != 1:
+		res.FailReason = "not_live"
+	case res.Confidence < actionLivenessMinConfidence:
+		res.FailReason = "low_confidence"
+	case ar == nil:
+		res.FailReason = "action_unverified"
+	case !res.ActionMatched:
+		res.FailReason = "action_mismatch"
+	default:
+		res.IsLive = true
+	}
+	return res
+}
+
+// VerifyActionLiveness 汇总各动作结果给出最终结论；每个会话只能校验一次
+func (s *KYCService) VerifyActionLiveness(ctx context.Context, sessionID string) (*ActionLivenessSession, error) {
+	ctx, span := tracing.StartSpan(ctx, "KYCService.VerifyActionLiveness")
+	defer span.End()
+	orgID := getOrgID(ctx)
+	if orgID == "" {
+		return nil, fmt.Errorf("缺少组织信息")
+	}
+	sess, err := s.updateActionSession(ctx, orgID, sessionID, func(cur *ActionLivenessSession) (time.Duration, error) {
+		if err := finalizeActionSession(cur, time.Now().UTC()); err != nil {
+			return 0, err
+		}
+		// 结论保留一段时间供查询，同时阻止重复校验
+		return actionSessionResultTTL, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, _ := json.Marshal(sess)
+	kycRequest := &models.KYCRequest{
+		ID:           sess.ID,
+		UserID:       sess.UserID,
+		OrgID:        sess.OrgID,
+		RequestType:  "liveness_action",
+		Status:       "success",
+		LivenessData: strings.Join(sessionActionNames(sess), ","),
+		Result:       string(result),
+		IPAddress:    getClientIP(ctx),
+		UserAgent:    getUserAgent(ctx),
+	}
+	if !sess.IsLive {
+		kycRequest.Status = "failed"
+		kycRequest.ErrorMessage = "动作活体未通过"
+	}
+	if err := s.DB.Create(kycRequest).Error; err != nil {
+		logger.GetLogger().WithError(err).Warn("保存动作活体结果失败")
+	}
+
+	s.RecordAuditLog(ctx, "liveness.action.verify", "liveness", sess.ID, kycRequest.Status, "")
+	s.emitKYCEvent(ctx, WebhookEventLivenessAction, sess.ID, "liveness_action", kycRequest.Status, "")
+	return sess, nil
+}
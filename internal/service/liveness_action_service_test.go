@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestActionSession(now time.Time, actions ...string) *ActionLivenessSession {
+	sess := &ActionLivenessSession{ID: "sess_1", OrgID: "org_1", Status: ActionSessionPending, CreatedAt: now, ExpiresAt: now.Add(actionSessionTTL)}
+	for _, a := range actions {
+		sess.Actions = append(sess.Actions, ActionLivenessResult{Action: a})
+	}
+	return sess
+}
+
+func TestRandomActionSequence(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		seq := randomActionSequence()
+		require.GreaterOrEqual(t, len(seq), actionSessionMinActions)
+		require.LessOrEqual(t, len(seq), actionSessionMaxActions)
+		seen := map[string]bool{}
+		for _, a := range seq {
+			assert.Contains(t, LivenessActions, a)
+			assert.False(t, seen[a], "duplicate action %s", a)
+			seen[a] = true
+		}
+	}
+}
+
+func TestActionSessionUploadOrder(t *testing.T) {
+	now := time.Now()
+	sess := newTestActionSession(now, "nod", "blink")
+	assert.Equal(t, "nod", sess.NextAction())
+
+	err := applyActionUpload(sess, ActionLivenessResult{Action: "blink", Uploaded: true}, now)
+	assert.True(t, errors.Is(err, ErrActionMismatch))
+	assert.Equal(t, ActionSessionPending, sess.Status)
+
+	require.NoError(t, applyActionUpload(sess, ActionLivenessResult{Action: "nod", Uploaded: true, IsLive: true, Confidence: 0.9}, now))
+	assert.Equal(t, ActionSessionUploading, sess.Status)
+	assert.Equal(t, "blink", sess.NextAction())
+
+	err = finalizeActionSession(sess, now)
+	assert.True(t, errors.Is(err, ErrActionSessionPending))
+
+	require.NoError(t, applyActionUpload(sess, ActionLivenessResult{Action: "blink", Uploaded: true, IsLive: true, Confidence: 0.7}, now))
+	assert.Equal(t, ActionSessionReady, sess.Status)
+	assert.Equal(t, "", sess.NextAction())
+	err = applyActionUpload(sess, ActionLivenessResult{Action: "smile", Uploaded: true}, now)
+	assert.True(t, errors.Is(err, ErrActionMismatch))
+}
+
+func TestActionSessionFinalize(t *testing.T) {
+	now := time.Now()
+	sess := newTestActionSession(now, "nod", "smile")
+	require.NoError(t, applyActionUpload(sess, ActionLivenessResult{Action: "nod", Uploaded: true, IsLive: true, Confidence: 0.9}, now))
+	require.NoError(t, applyActionUpload(sess, ActionLivenessResult{Action: "smile", Uploaded: true, IsLive: true, Confidence: 0.6}, now))
+	require.NoError(t, finalizeActionSession(sess, now))
+	assert.Equal(t, ActionSessionPassed, sess.Status)
+	assert.True(t, sess.IsLive)
+	assert.InDelta(t, 0.6, sess.Score, 1e-9)
+	require.NotNil(t, sess.VerifiedAt)
+
+	// 只能校验一次
+	assert.True(t, errors.Is(finalizeActionSession(sess, now), ErrActionSessionVerified))
+	assert.True(t, errors.Is(applyActionUpload(sess, ActionLivenessResult{Action: "nod"}, now), ErrActionSessionVerified))
+
+	failed := newTestActionSession(now, "nod", "blink")
+	require.NoError(t, applyActionUpload(failed, ActionLivenessResult{Action: "nod", Uploaded: true, IsLive: true, Confidence: 0.9}, now))
+	require.NoError(t, applyActionUpload(failed, ActionLivenessResult{Action: "blink", Uploaded: true, FailReason: "not_live", Confidence: 0.2}, now))
+	require.NoError(t, finalizeActionSession(failed, now))
+	assert.Equal(t, ActionSessionFailed, failed.Status)
+	assert.False(t, failed.IsLive)
+}
+
+func TestActionSessionExpiry(t *testing.T) {
+	now := time.Now()
+	sess := newTestActionSession(now, "nod", "blink")
+	later := now.Add(actionSessionTTL + time.Second)
+	assert.True(t, errors.Is(applyActionUpload(sess, ActionLivenessResult{Action: "nod", Uploaded: true}, later), ErrActionSessionExpired))
+	assert.True(t, errors.Is(finalizeActionSession(sess, later), ErrActionSessionExpired))
+}
+
+func TestLoadActionSessionOrgIsolation(t *testing.T) {
+	s := &KYCService{}
+	sess := newTestActionSession(time.Now(), "nod", "blink")
+	sess.UserID = "user_1"
+	b, err := encodeActionSession(sess)
+	require.NoError(t, err)
+	get := func(string) *redis.StringCmd { return redis.NewStringResult(string(b), nil) }
+
+	loaded, err := s.loadActionSession(context.Background(), get, "org_1", sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "user_1", loaded.UserID)
+	assert.Equal(t, []string{"nod", "blink"}, sessionActionNames(loaded))
+
+	_, err = s.loadActionSession(context.Background(), get, "org_2", sess.ID)
+	assert.True(t, errors.Is(err, ErrActionSessionNotFound))
+
+	missing := func(string) *redis.StringCmd { return redis.NewStringResult("", redis.Nil) }
+	_, err = s.loadActionSession(context.Background(), missing, "org_1", sess.ID)
+	assert.True(t, errors.Is(err, ErrActionSessionNotFound))
+}
+
+func TestJudgeActionClip(t *testing.T) {
+	live := func(action string, matched int) *LivenessVideoResponse {
+		out := &LivenessVideoResponse{}
+		out.LivenessResults.IsLiveness = 1
+		out.LivenessResults.IsFaceExist = 1
+		out.LivenessResults.Confidence = 0.9
+		if action != "" {
+			out.ActionResults = &LivenessActionResults{Action: action, IsActionMatched: matched}
+		}
+		return out
+	}
+
+	res := judgeActionClip("nod", live("nod", 1))
+	assert.True(t, res.IsLive)
+	assert.True(t, res.ActionMatched)
+	assert.Empty(t, res.FailReason)
+
+	res = judgeActionClip("nod", live("", 0))
+	assert.False(t, res.IsLive)
+	assert.Equal(t, "action_unverified", res.FailReason)
+
+	res = judgeActionClip("nod", live("nod", 0))
+	assert.False(t, res.IsLive)
+	assert.Equal(t, "action_mismatch", res.FailReason)
+
+	res = judgeActionClip("nod", live("blink", 1))
+	assert.False(t, res.IsLive)
+	assert.Equal(t, "action_mismatch", res.FailReason)
+}
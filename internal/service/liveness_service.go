@@ -34,7 +34,16 @@ type LivenessVideoResponse struct {
 		IsFaceExist         float64 `json:"is_face_exist"`
 		FaceExistConfidence float64 `json:"face_exist_confidence"`
 	} `json:"liveness_results"`
-	Filename string `json:"filename"`
+	// ActionResults 仅在请求携带 action 时返回；缺失表示供应商未校验动作
+	ActionResults *LivenessActionResults `json:"action_results,omitempty"`
+	Filename      string                 `json:"filename"`
+}
+
+// LivenessActionResults 动态活体对要求动作的校验结果
+type LivenessActionResults struct {
+	Action          string  `json:"action"`
+	IsActionMatched int     `json:"is_action_matched"`
+	Confidence      float64 `json:"confidence"`
 }
 
 func (s *KYCService) LivenessSilent(ctx context.Context, file *multipart.FileHeader, language string) (*LivenessSilentResponse, error) {
@@ -45,7 +54,8 @@ func (s *KYCService) LivenessSilent(ctx context.Context, file *multipart.FileHea
 	if orgID == "" {
 		return nil, fmt.Errorf("缺少组织信息")
 	}
-	if err := s.checkAndConsumeQuota(ctx, orgID, "liveness", func() error { return nil }); err != nil {
+	reservation, err := s.reserveQuota(ctx, orgID, "liveness")
+	if err != nil {
 		if strings.Contains(err.Error(), "QUOTA_EXCEEDED") {
 			return nil, fmt.Errorf("Quota exceeded. Please upgrade your plan.")
 		}
@@ -53,21 +63,38 @@ func (s *KYCService) LivenessSilent(ctx context.Context, file *multipart.FileHea
 	}
 	asset, err := s.IngestImage(ctx, orgID, file)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
+		return nil, err
+	}
+	path, cleanup, err := s.AssetLocalPath(ctx, asset.StorageKey, asset.FilePath, asset.DataKeyID)
+	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		return nil, err
 	}
+	defer cleanup()
+	path, prepCleanup, err := s.prepareImageFile(ctx, CapabilityLiveness, path)
+	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
+		return nil, err
+	}
+	defer prepCleanup()
 	start := time.Now()
-	tp := NewThirdPartyService(s.Config)
-	out, err := tp.CallLivenessSilent(ctx, asset.FilePath, language)
+	out, err := s.Providers.LivenessSilent(ctx, path, language)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		metrics.RecordBusinessOperation(ctx, "liveness_silent", false, time.Since(start), "third_party_error")
+		s.emitKYCEvent(ctx, WebhookEventLivenessSilent, getRequestID(ctx), "liveness_silent", "failed", "")
 		return nil, err
 	}
+	s.commitQuota(ctx, reservation)
 	if out.Code != 0 {
 		metrics.RecordBusinessOperation(ctx, "liveness_silent", false, time.Since(start), "third_party_code")
+		s.emitKYCEvent(ctx, WebhookEventLivenessSilent, getRequestID(ctx), "liveness_silent", "failed", out.Msg)
 		return nil, fmt.Errorf("静态活体失败: code=%d msg=%s", out.Code, out.Msg)
 	}
 	metrics.RecordBusinessOperation(ctx, "liveness_silent", true, time.Since(start), "")
 	s.RecordAuditLog(ctx, "liveness.silent", "liveness", asset.ID, "success", "")
+	s.emitKYCEvent(ctx, WebhookEventLivenessSilent, getRequestID(ctx), "liveness_silent", "success", "")
 	return out, nil
 }
 
@@ -79,28 +106,40 @@ func (s *KYCService) LivenessVideo(ctx context.Context, file *multipart.FileHead
 	if orgID == "" {
 		return nil, fmt.Errorf("缺少组织信息")
 	}
-	if err := s.checkAndConsumeQuota(ctx, orgID, "liveness", func() error { return nil }); err != nil {
+	reservation, err := s.reserveQuota(ctx, orgID, "liveness")
+	if err != nil {
 		if strings.Contains(err.Error(), "QUOTA_EXCEEDED") {
 			return nil, fmt.Errorf("Quota exceeded. Please upgrade your plan.")
 		}
 		return nil, err
 	}
-	asset, err := s.IngestVideo(ctx, orgID, file)
+	asset, err := s.IngestVideo(ctx, orgID, getRequestID(ctx), file)
+	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
+		return nil, err
+	}
+	path, cleanup, err := s.AssetLocalPath(ctx, asset.StorageKey, asset.FilePath, asset.DataKeyID)
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		return nil, err
 	}
+	defer cleanup()
 	start := time.Now()
-	tp := NewThirdPartyService(s.Config)
-	out, err := tp.CallLivenessVideo(ctx, asset.FilePath, language)
+	out, err := s.Providers.LivenessVideo(ctx, path, language, "")
 	if err != nil {
+		s.refundQuota(ctx, reservation, err.Error())
 		metrics.RecordBusinessOperation(ctx, "liveness_video", false, time.Since(start), "third_party_error")
+		s.emitKYCEvent(ctx, WebhookEventLivenessVideo, getRequestID(ctx), "liveness_video", "failed", "")
 		return nil, err
 	}
+	s.commitQuota(ctx, reservation)
 	if out.Code != 0 {
 		metrics.RecordBusinessOperation(ctx, "liveness_video", false, time.Since(start), "third_party_code")
+		s.emitKYCEvent(ctx, WebhookEventLivenessVideo, getRequestID(ctx), "liveness_video", "failed", out.Msg)
 		return nil, fmt.Errorf("动态活体失败: code=%d msg=%s", out.Code, out.Msg)
 	}
 	metrics.RecordBusinessOperation(ctx, "liveness_video", true, time.Since(start), "")
 	s.RecordAuditLog(ctx, "liveness.video", "liveness", asset.ID, "success", "")
+	s.emitKYCEvent(ctx, WebhookEventLivenessVideo, getRequestID(ctx), "liveness_video", "success", "")
 	return out, nil
 }
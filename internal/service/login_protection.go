@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/mail"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 受保护的认证入口，各自独立计数
+const (
+	AuthGuardConsoleLogin  = "login"
+	AuthGuardPasswordReset = "password_reset"
+	AuthGuardOAuthToken    = "oauth_token"
+	AuthGuardMFA           = "mfa" // 登录第二步，按账号邮箱计数
+)
+
+// 拦截原因
+const (
+	AuthBlockThrottled     = "throttled"
+	AuthBlockAccountLocked = "account_locked"
+	AuthBlockIPLocked      = "ip_locked"
+)
+
+const (
+	authScopeAccount = "acct"
+	authScopeIP      = "ip"
+	// authLockCountTTL 锁定次数的保留时间，期间再次锁定时长翻倍
+	authLockCountTTL = 24 * time.Hour
+)
+
+var authGuardPurposes = []string{AuthGuardConsoleLogin, AuthGuardPasswordReset, AuthGuardOAuthToken, AuthGuardMFA}
+
+// AuthBlock 请求被拦截的原因与可重试时间
+type AuthBlock struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+// AuthFailureResult 记录失败后的状态；AccountLocked/IPLocked 表示本次失败触发了锁定
+type AuthFailureResult struct {
+	Failures      int64
+	AccountLocked bool
+	IPLocked      bool
+	LockedFor     time.Duration
+}
+
+func authGuardKey(purpose, scope, id, suffix string) string {
+	return "authguard:" + purpose + ":" + scope + ":" + id + ":" + suffix
+}
+
+func normalizeAuthAccount(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+// progressiveDelay 连续失败 failures 次后的等待时间：达到 after 次起从 base 开始逐次翻倍，不超过 max
+func progressiveDelay(failures int64, after int, base, max time.Duration) time.Duration {
+	if after <= 0 || failures < int64(after) || base <= 0 {
+		return 0
+	}
+	d := base
+	for i := int64(after); i < failures && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+// lockoutDuration 第 n 次锁定的时长：从 base 开始逐次翻倍，不超过 max
+func lockoutDuration(n int64, base, max time.Duration) time.Duration {
+	d := base
+	for i := int64(1); i < n && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+// CheckAuthAttempt 认证前检查账号/IP是否处于锁定或延迟期；Redis 不可用时放行
+func (s *KYCService) CheckAuthAttempt(ctx context.Context, purpose, account, ip string) *AuthBlock {
+	if s.Redis == nil {
+		return nil
+	}
+	account = normalizeAuthAccount(account)
+	type probe struct {
+		key    string
+		reason string
+	}
+	probes := []probe{}
+	if account != "" {
+		probes = append(probes,
+			probe{authGuardKey(purpose, authScopeAccount, account, "lock"), AuthBlockAccountLocked},
+			probe{authGuardKey(purpose, authScopeAccount, account, "next"), AuthBlockThrottled})
+	}
+	if ip != "" {
+		probes = append(probes, probe{authGuardKey(purpose, authScopeIP, ip, "lock"), AuthBlockIPLocked})
+	}
+	pipe := s.Redis.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(probes))
+	for i, p := range probes {
+		cmds[i] = pipe.PTTL(ctx, p.key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		logger.GetLogger().WithError(err).Warn("查询登录防护状态失败")
+		return nil
+	}
+	for i, cmd := range cmds {
+		if ttl := cmd.Val(); ttl > 0 {
+			return &AuthBlock{Reason: probes[i].reason, RetryAfter: ttl}
+		}
+	}
+	return nil
+}
+
+// RecordAuthAttemptFailure 记录一次失败（密码错误、第二因素错误、客户端凭证无效或重置请求），按配置设置递增延迟并在达到阈值时锁定
+func (s *KYCService) RecordAuthAttemptFailure(ctx context.Context, purpose, account, ip string) AuthFailureResult {
+	var res AuthFailureResult
+	if s.Redis == nil {
+		return res
+	}
+	cfg := s.Config.Security.LoginProtection
+	ctx = context.WithoutCancel(ctx)
+	account = normalizeAuthAccount(account)
+	if account != "" {
+		res.Failures = s.incrAuthCounter(ctx, authGuardKey(purpose, authScopeAccount, account, "fails"), cfg.Window)
+		if cfg.AccountMaxFailures > 0 && res.Failures >= int64(cfg.AccountMaxFailures) {
+			res.LockedFor = s.lockAuthScope(ctx, purpose, authScopeAccount, account)
+			res.AccountLocked = true
+		} else if d := progressiveDelay(res.Failures, cfg.DelayAfter, cfg.BaseDelay, cfg.MaxDelay); d > 0 {
+			_ = s.Redis.Set(ctx, authGuardKey(purpose, authScopeAccount, account, "next"), 1, d).Err()
+		}
+	}
+	if ip != "" {
+		n := s.incrAuthCounter(ctx, authGuardKey(purpose, authScopeIP, ip, "fails"), cfg.Window)
+		if cfg.IPMaxFailures > 0 && n >= int64(cfg.IPMaxFailures) {
+			d := s.lockAuthScope(ctx, purpose, authScopeIP, ip)
+			res.IPLocked = true
+			if d > res.LockedFor {
+				res.LockedFor = d
+			}
+		}
+	}
+	return res
+}
+
+func (s *KYCService) incrAuthCounter(ctx context.Context, key string, window time.Duration) int64 {
+	n, err := s.Redis.Incr(ctx, key).Result()
+	if err != nil {
+		logger.GetLogger().WithError(err).Warn("记录认证失败次数失败")
+		return 0
+	}
+	if n == 1 && window > 0 {
+		_ = s.Redis.Expire(ctx, key, window).Err()
+	}
+	return n
+}
+
+// lockAuthScope 锁定账号或IP并清零失败计数，返回锁定时长
+func (s *KYCService) lockAuthScope(ctx context.Context, purpose, scope, id string) time.Duration {
+	cfg := s.Config.Security.LoginProtection
+	countKey := authGuardKey(purpose, scope, id, "lockcount")
+	n := s.incrAuthCounter(ctx, countKey, authLockCountTTL)
+	d := lockoutDuration(n, cfg.LockoutDuration, cfg.MaxLockoutDuration)
+	if d <= 0 {
+		return 0
+	}
+	_ = s.Redis.Set(ctx, authGuardKey(purpose, scope, id, "lock"), 1, d).Err()
+	_ = s.Redis.Del(ctx, authGuardKey(purpose, scope, id, "fails"), authGuardKey(purpose, scope, id, "next")).Err()
+	return d
+}
+
+// ClearAuthAttempts 认证成功后清除账号的失败计数与延迟（锁定次数保留，用于计算再次锁定的时长）
+func (s *KYCService) ClearAuthAttempts(ctx context.Context, purpose, account string) {
+	account = normalizeAuthAccount(account)
+	if s.Redis == nil || account == "" {
+		return
+	}
+	_ = s.Redis.Del(context.WithoutCancel(ctx),
+		authGuardKey(purpose, authScopeAccount, account, "fails"),
+		authGuardKey(purpose, authScopeAccount, account, "next")).Err()
+}
+
+// UnlockAccount 管理员解锁账号：清除所有入口下该账号的锁定、延迟与计数
+func (s *KYCService) UnlockAccount(ctx context.Context, account string) error {
+	account = normalizeAuthAccount(account)
+	if s.Redis == nil || account == "" {
+		return nil
+	}
+	keys := []string{}
+	for _, p := range authGuardPurposes {
+		for _, suffix := range []string{"lock", "next", "fails", "lockcount"} {
+			keys = append(keys, authGuardKey(p, authScopeAccount, account, suffix))
+		}
+	}
+	return s.Redis.Del(ctx, keys...).Err()
+}
+
+// AccountLockedFor 账号在控制台登录入口的剩余锁定时间，未锁定返回 0
+func (s *KYCService) AccountLockedFor(ctx context.Context, account string) time.Duration {
+	account = normalizeAuthAccount(account)
+	if s.Redis == nil || account == "" {
+		return 0
+	}
+	ttl, err := s.Redis.PTTL(ctx, authGuardKey(AuthGuardConsoleLogin, authScopeAccount, account, "lock")).Result()
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// NotifyAccountLocked 邮件通知用户账号因多次登录失败被临时锁定（未配置SMTP时跳过）
+func (s *KYCService) NotifyAccountLocked(email, ip string, lockedFor time.Duration) {
+	cfg := s.Config.Monitoring.Alerting
+	if cfg.EmailSMTP == "" || email == "" {
+		return
+	}
+	smtpCfg := mail.SMTPConfig{Host: cfg.EmailSMTP, Port: cfg.EmailPort, Username: cfg.EmailUser, Password: cfg.EmailPassword, From: cfg.EmailFrom, TLS: cfg.EmailTLS}
+	until := time.Now().Add(lockedFor).UTC().Format("2006-01-02 15:04 MST")
+	body := fmt.Sprintf(`<p>您的账号因多次登录失败已被临时锁定，将于 %s 自动解锁。</p>
+<p>最近一次失败请求来自 IP：%s。如果这不是您本人的操作，建议在解锁后立即修改密码并启用多因素认证；如需提前解锁请联系管理员。</p>`,
+		html.EscapeString(until), html.EscapeString(ip))
+	go func() {
+		if err := mail.SendSMTP(smtpCfg, email, "账号已临时锁定", body, ""); err != nil {
+			logger.GetLogger().WithError(err).Warnf("发送账号锁定通知失败: %s", email)
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyc-service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressiveDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int64
+		want     time.Duration
+	}{
+		{"未达到阈值", 2, 0},
+		{"刚达到阈值", 3, time.Second},
+		{"逐次翻倍", 5, 4 * time.Second},
+		{"不超过上限", 20, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, progressiveDelay(tt.failures, 3, time.Second, 30*time.Second))
+		})
+	}
+	assert.Zero(t, progressiveDelay(10, 0, time.Second, time.Minute), "未配置阈值时不延迟")
+}
+
+func TestLockoutDuration(t *testing.T) {
+	assert.Equal(t, 15*time.Minute, lockoutDuration(1, 15*time.Minute, 24*time.Hour))
+	assert.Equal(t, 30*time.Minute, lockoutDuration(2, 15*time.Minute, 24*time.Hour))
+	assert.Equal(t, 24*time.Hour, lockoutDuration(12, 15*time.Minute, 24*time.Hour))
+}
+
+func TestAuthGuardWithoutRedis(t *testing.T) {
+	s := &KYCService{Config: &config.Config{}}
+	ctx := context.Background()
+	assert.Nil(t, s.CheckAuthAttempt(ctx, AuthGuardConsoleLogin, "User@Example.com", "10.0.0.1"))
+	res := s.RecordAuthAttemptFailure(ctx, AuthGuardConsoleLogin, "user@example.com", "10.0.0.1")
+	assert.False(t, res.AccountLocked)
+	assert.NoError(t, s.UnlockAccount(ctx, "user@example.com"))
+	assert.Equal(t, authGuardKey(AuthGuardConsoleLogin, authScopeAccount, "user@example.com", "lock"),
+		authGuardKey(AuthGuardConsoleLogin, authScopeAccount, normalizeAuthAccount(" User@Example.com "), "lock"))
+}
@@ -0,0 +1,689 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/jwtkeys"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/totp"
+	"kyc-service/pkg/utils"
+	"kyc-service/pkg/webauthn"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 认证方式引用（RFC 8176 amr），写入控制台会话令牌
+const (
+	AMRPassword    = "pwd"
+	AMRFederated   = "fed" // 第三方登录（Google、组织 SSO）
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk"
+	AMRRecovery    = "kba"
+	AMRMFA         = "mfa"
+)
+
+// 第二因素类型
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodRecovery = "recovery_code"
+	MFAMethodWebAuthn = "webauthn"
+)
+
+// TokenUseMFAChallenge 登录第一步签发的挑战令牌，只能用于完成第二步验证
+const TokenUseMFAChallenge = "mfa_challenge"
+
+const (
+	mfaChallengeTTL       = 5 * time.Minute
+	mfaChallengeMaxTries  = 5
+	webAuthnChallengeTTL  = 5 * time.Minute
+	mfaRecoveryCodeCount  = 10
+	defaultStepUpMaxAge   = 10 * time.Minute
+	recoveryCodeAlphabet  = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeHalfWidth = 5
+)
+
+var (
+	ErrMFAInvalidCode         = errors.New("invalid verification code")
+	ErrMFAChallengeInvalid    = errors.New("mfa challenge invalid or expired")
+	ErrMFATooManyAttempts     = errors.New("too many mfa attempts")
+	ErrMFANotEnrolled         = errors.New("mfa method not enrolled")
+	ErrMFAAlreadyEnrolled     = errors.New("mfa method already enrolled")
+	ErrMFAEncryptionRequired  = errors.New("encryption key is required to store mfa secrets")
+	ErrWebAuthnDisabled       = errors.New("webauthn is not configured")
+	ErrMFALastFactorRequired  = errors.New("mfa is required for this account and cannot be removed")
+	ErrMFAUnsupportedMethod   = errors.New("unsupported mfa method")
+	ErrWebAuthnChallengeStale = errors.New("webauthn challenge expired")
+)
+
+// MFAStatus 用户已启用的第二因素
+type MFAStatus struct {
+	TOTPEnabled       bool                        `json:"totp_enabled"`
+	WebAuthn          []models.WebAuthnCredential `json:"webauthn_credentials"`
+	RecoveryCodesLeft int64                       `json:"recovery_codes_left"`
+	WebAuthnAvailable bool                        `json:"webauthn_available"`
+}
+
+// Enrolled 是否至少启用了一种第二因素
+func (st *MFAStatus) Enrolled() bool {
+	return st.TOTPEnabled || len(st.WebAuthn) > 0
+}
+
+// Methods 登录第二步可用的验证方式
+func (st *MFAStatus) Methods() []string {
+	var out []string
+	if st.TOTPEnabled {
+		out = append(out, MFAMethodTOTP)
+	}
+	if len(st.WebAuthn) > 0 {
+		out = append(out, MFAMethodWebAuthn)
+	}
+	if st.RecoveryCodesLeft > 0 {
+		out = append(out, MFAMethodRecovery)
+	}
+	return out
+}
+
+// MFAVerification 第二步提交的验证信息
+type MFAVerification struct {
+	Method   string                                `json:"method" binding:"required,oneof=totp recovery_code webauthn"`
+	Code     string                                `json:"code"`
+	WebAuthn *webauthn.CredentialAssertionResponse `json:"webauthn"`
+}
+
+// GetMFAStatus 查询用户的MFA启用情况
+func (s *KYCService) GetMFAStatus(user *models.User) (*MFAStatus, error) {
+	st := &MFAStatus{TOTPEnabled: user.TOTPEnabled, WebAuthnAvailable: s.webAuthnEnabled()}
+	if err := s.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&st.WebAuthn).Error; err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(&models.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&st.RecoveryCodesLeft).Error; err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// MFARequired 用户在当前组织下是否必须使用MFA（组织策略或平台管理员）
+func (s *KYCService) MFARequired(user *models.User, orgID string) bool {
+	if user.IsPlatformAdmin && s.Config.Security.MFA.RequirePlatformAdmin {
+		return true
+	}
+	if orgID == "" {
+		return false
+	}
+	var org models.Organization
+	if err := s.DB.Select("require_mfa").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return false
+	}
+	return org.RequireMFA
+}
+
+// StepUpMaxAge 敏感操作要求的MFA新鲜度
+func (s *KYCService) StepUpMaxAge() time.Duration {
+	if d := s.Config.Security.MFA.StepUpMaxAge; d > 0 {
+		return d
+	}
+	return defaultStepUpMaxAge
+}
+
+// SessionAuthClaims 向控制台会话声明写入 amr；包含 mfa 时同时记录验证时间 mfa_at（供敏感操作判断新鲜度）
+func SessionAuthClaims(claims jwt.MapClaims, amr []string, mfaAt time.Time) jwt.MapClaims {
+	if len(amr) == 0 {
+		return claims
+	}
+	claims["amr"] = amr
+	if HasAMR(amr, AMRMFA) && !mfaAt.IsZero() {
+		claims["mfa_at"] = mfaAt.Unix()
+	}
+	return claims
+}
+
+// ClaimsAMR 读取令牌中的 amr 声明
+func ClaimsAMR(claims jwt.MapClaims) []string {
+	raw, _ := claims["amr"].([]interface{})
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// StepUpFresh 会话是否在 maxAge 内完成过第二因素验证
+func StepUpFresh(claims jwt.MapClaims, maxAge time.Duration, now time.Time) bool {
+	if claims == nil || !HasAMR(ClaimsAMR(claims), AMRMFA) {
+		return false
+	}
+	at, ok := claims["mfa_at"].(float64)
+	if !ok {
+		return false
+	}
+	return now.Sub(time.Unix(int64(at), 0)) <= maxAge
+}
+
+// HasAMR amr 中是否包含指定方式
+func HasAMR(amr []string, method string) bool {
+	for _, m := range amr {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// CompleteAMR 第一因素加上第二因素后的完整 amr
+func CompleteAMR(first []string, second string) []string {
+	out := append([]string{}, first...)
+	if second != "" && !HasAMR(out, second) {
+		out = append(out, second)
+	}
+	if !HasAMR(out, AMRMFA) {
+		out = append(out, AMRMFA)
+	}
+	return out
+}
+
+// IssueMFAChallenge 第一因素通过后签发短期挑战令牌；ssoOrgID 非空表示第一因素为该组织的 SSO 登录
+func (s *KYCService) IssueMFAChallenge(user *models.User, firstFactor []string, ssoOrgID string) (string, time.Time, error) {
+	exp := time.Now().Add(mfaChallengeTTL)
+	claims := jwt.MapClaims{
+		"user_id":   user.ID,
+		"token_use": TokenUseMFAChallenge,
+		"amr":       firstFactor,
+		"exp":       exp.Unix(),
+	}
+	if ssoOrgID != "" {
+		claims["sso_org"] = ssoOrgID
+	}
+	tok, err := s.SignJWT(claims)
+	return tok, exp, err
+}
+
+// MFAChallenge 已校验的挑战令牌
+type MFAChallenge struct {
+	UserID      string
+	FirstFactor []string
+	SSOOrgID    string
+	jti         string
+	exp         time.Time
+}
+
+// ParseMFAChallenge 校验挑战令牌并计数尝试次数（同一挑战最多尝试5次）
+func (s *KYCService) ParseMFAChallenge(ctx context.Context, tokenString string) (*MFAChallenge, error) {
+	claims := jwt.MapClaims{}
+	tok, err := jwt.ParseWithClaims(tokenString, claims, s.JWTKeyfunc, jwt.WithExpirationRequired())
+	if err != nil || !tok.Valid {
+		return nil, ErrMFAChallengeInvalid
+	}
+	if use, _ := claims["token_use"].(string); use != TokenUseMFAChallenge {
+		return nil, ErrMFAChallengeInvalid
+	}
+	if s.IsTokenRevoked(ctx, claims) {
+		return nil, ErrMFAChallengeInvalid
+	}
+	ch := &MFAChallenge{FirstFactor: ClaimsAMR(claims)}
+	ch.UserID, _ = claims["user_id"].(string)
+	ch.SSOOrgID, _ = claims["sso_org"].(string)
+	ch.jti, _ = claims["jti"].(string)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		ch.exp = exp.Time
+	}
+	if ch.UserID == "" || ch.jti == "" {
+		return nil, ErrMFAChallengeInvalid
+	}
+	if s.Redis == nil {
+		if err := s.countMFAChallengeAttemptDB(ch); err != nil {
+			return nil, err
+		}
+		return ch, nil
+	}
+	key := "mfa:attempts:" + ch.jti
+	n, err := s.Redis.Incr(ctx, key).Result()
+	if err == nil {
+		_ = s.Redis.ExpireAt(ctx, key, ch.exp).Err()
+		if n > mfaChallengeMaxTries {
+			return nil, ErrMFATooManyAttempts
+		}
+	}
+	return ch, nil
+}
+
+// countMFAChallengeAttemptDB 未部署 Redis 时在数据库中计数尝试次数，已使用的挑战不可再用
+func (s *KYCService) countMFAChallengeAttemptDB(ch *MFAChallenge) error {
+	rec := models.MFAChallengeUse{JTI: ch.jti, UserID: ch.UserID, ExpiresAt: ch.exp}
+	if err := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rec).Error; err != nil {
+		return err
+	}
+	res := s.DB.Model(&models.MFAChallengeUse{}).
+		Where("jti = ? AND consumed_at IS NULL AND attempts < ?", ch.jti, mfaChallengeMaxTries).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+	if err := s.DB.First(&rec, "jti = ?", ch.jti).Error; err != nil {
+		return err
+	}
+	if rec.ConsumedAt != nil {
+		return ErrMFAChallengeInvalid
+	}
+	return ErrMFATooManyAttempts
+}
+
+// ConsumeMFAChallenge 挑战令牌一次性使用；并发请求中只有一个能成功，其余返回 ErrMFAChallengeInvalid
+func (s *KYCService) ConsumeMFAChallenge(ctx context.Context, ch *MFAChallenge) error {
+	if s.Redis == nil {
+		now := time.Now()
+		res := s.DB.Model(&models.MFAChallengeUse{}).Where("jti = ? AND consumed_at IS NULL", ch.jti).Update("consumed_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrMFAChallengeInvalid
+		}
+		// 顺带清理已过期的记录
+		if err := s.DB.Where("expires_at < ?", now).Delete(&models.MFAChallengeUse{}).Error; err != nil {
+			logger.GetLogger().WithError(err).Warn("清理过期MFA挑战记录失败")
+		}
+		return nil
+	}
+	ttl := time.Until(ch.exp)
+	if ttl <= 0 {
+		return ErrMFAChallengeInvalid
+	}
+	fresh, err := s.Redis.SetNX(context.WithoutCancel(ctx), revokedJTIKey(ch.jti), 1, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrMFAChallengeInvalid
+	}
+	return nil
+}
+
+// VerifyMFA 校验第二因素，返回对应的 amr 值
+func (s *KYCService) VerifyMFA(ctx context.Context, user *models.User, v *MFAVerification) (string, error) {
+	switch v.Method {
+	case MFAMethodTOTP:
+		if !user.TOTPEnabled {
+			return "", ErrMFANotEnrolled
+		}
+		if err := s.verifyTOTP(ctx, user, v.Code); err != nil {
+			return "", err
+		}
+		return AMROTP, nil
+	case MFAMethodRecovery:
+		if err := s.useRecoveryCode(user.ID, v.Code); err != nil {
+			return "", err
+		}
+		return AMRRecovery, nil
+	case MFAMethodWebAuthn:
+		if v.WebAuthn == nil {
+			return "", ErrMFAInvalidCode
+		}
+		if err := s.finishWebAuthnLogin(ctx, user.ID, v.WebAuthn); err != nil {
+			return "", err
+		}
+		return AMRHardwareKey, nil
+	}
+	return "", ErrMFAUnsupportedMethod
+}
+
+func (s *KYCService) totpSecret(user *models.User) (string, error) {
+	if s.Encryptor == nil {
+		return "", ErrMFAEncryptionRequired
+	}
+	if user.TOTPSecretEnc == "" {
+		return "", ErrMFANotEnrolled
+	}
+	return s.Encryptor.Decrypt(user.TOTPSecretEnc)
+}
+
+// verifyTOTP 校验口令，同一时间步的口令只能使用一次
+func (s *KYCService) verifyTOTP(ctx context.Context, user *models.User, code string) error {
+	secret, err := s.totpSecret(user)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrMFAInvalidCode
+	}
+	if s.Redis != nil {
+		key := fmt.Sprintf("mfa:totp_used:%s:%d", user.ID, step)
+		fresh, err := s.Redis.SetNX(ctx, key, 1, (2*totp.Skew+1)*totp.Period).Result()
+		if err == nil && !fresh {
+			return ErrMFAInvalidCode
+		}
+	}
+	return nil
+}
+
+// BeginTOTPEnrollment 生成待确认的TOTP密钥，确认前不生效
+func (s *KYCService) BeginTOTPEnrollment(user *models.User) (secret, uri string, err error) {
+	if user.TOTPEnabled {
+		return "", "", ErrMFAAlreadyEnrolled
+	}
+	if s.Encryptor == nil {
+		return "", "", ErrMFAEncryptionRequired
+	}
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	enc, err := s.Encryptor.Encrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_secret_enc", enc).Error; err != nil {
+		return "", "", err
+	}
+	user.TOTPSecretEnc = enc
+	return secret, totp.URI(s.Config.Security.MFA.Issuer, user.Email, secret), nil
+}
+
+// ConfirmTOTPEnrollment 以首个口令确认启用TOTP；用户此前没有恢复码时一并生成
+func (s *KYCService) ConfirmTOTPEnrollment(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	if err := s.verifyTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+	var codes []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = ensureRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	return codes, nil
+}
+
+// DisableTOTP 停用TOTP；组织或平台要求MFA时不能移除最后一种因素
+func (s *KYCService) DisableTOTP(user *models.User, orgID string) error {
+	if !user.TOTPEnabled {
+		return ErrMFANotEnrolled
+	}
+	st, err := s.GetMFAStatus(user)
+	if err != nil {
+		return err
+	}
+	if len(st.WebAuthn) == 0 && s.MFARequired(user, orgID) {
+		return ErrMFALastFactorRequired
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+			Updates(map[string]interface{}{"totp_enabled": false, "totp_secret_enc": ""}).Error; err != nil {
+			return err
+		}
+		if len(st.WebAuthn) == 0 {
+			return tx.Where("user_id = ?", user.ID).Delete(&models.MFARecoveryCode{}).Error
+		}
+		return nil
+	})
+}
+
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 2*recoveryCodeHalfWidth)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	out := make([]byte, len(buf))
+	for i, b := range buf {
+		out[i] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+	}
+	return string(out[:recoveryCodeHalfWidth]) + "-" + string(out[recoveryCodeHalfWidth:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// ensureRecoveryCodes 用户没有可用恢复码时生成一组；已有时返回 nil
+func ensureRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	var n int64
+	if err := tx.Model(&models.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, nil
+	}
+	return replaceRecoveryCodes(tx, userID)
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, mfaRecoveryCodeCount)
+	rows := make([]models.MFARecoveryCode, 0, mfaRecoveryCodeCount)
+	for i := 0; i < mfaRecoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, models.MFARecoveryCode{ID: utils.GenerateID(), UserID: userID, CodeHash: HashClientSecret(normalizeRecoveryCode(code))})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes 作废旧恢复码并生成新的一组
+func (s *KYCService) RegenerateRecoveryCodes(user *models.User) ([]string, error) {
+	st, err := s.GetMFAStatus(user)
+	if err != nil {
+		return nil, err
+	}
+	if !st.Enrolled() {
+		return nil, ErrMFANotEnrolled
+	}
+	var codes []string
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// useRecoveryCode 使用一次性恢复码（并发下只有一个请求能成功）
+func (s *KYCService) useRecoveryCode(userID, code string) error {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return ErrMFAInvalidCode
+	}
+	res := s.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashClientSecret(code)).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMFAInvalidCode
+	}
+	return nil
+}
+
+func (s *KYCService) webAuthnEnabled() bool {
+	return s.Config != nil && s.Config.Security.MFA.WebAuthnRPID != "" && s.Redis != nil
+}
+
+func (s *KYCService) relyingParty() webauthn.RelyingParty {
+	cfg := s.Config.Security.MFA
+	return webauthn.RelyingParty{ID: cfg.WebAuthnRPID, Name: cfg.WebAuthnRPName, Origins: cfg.WebAuthnOrigins}
+}
+
+func webAuthnChallengeKey(kind, userID string) string { return "mfa:webauthn:" + kind + ":" + userID }
+
+// takeWebAuthnChallenge 取出并删除挑战，保证一次性
+func (s *KYCService) takeWebAuthnChallenge(ctx context.Context, kind, userID string) (string, error) {
+	ch, err := s.Redis.GetDel(ctx, webAuthnChallengeKey(kind, userID)).Result()
+	if err != nil || ch == "" {
+		return "", ErrWebAuthnChallengeStale
+	}
+	return ch, nil
+}
+
+// BeginWebAuthnRegistration 生成安全密钥注册参数
+func (s *KYCService) BeginWebAuthnRegistration(ctx context.Context, user *models.User) (map[string]interface{}, error) {
+	if !s.webAuthnEnabled() {
+		return nil, ErrWebAuthnDisabled
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Redis.Set(ctx, webAuthnChallengeKey("reg", user.ID), challenge, webAuthnChallengeTTL).Err(); err != nil {
+		return nil, err
+	}
+	var existing []string
+	_ = s.DB.Model(&models.WebAuthnCredential{}).Where("user_id = ?", user.ID).Pluck("id", &existing).Error
+	name := user.FullName
+	if name == "" {
+		name = user.Name
+	}
+	return s.relyingParty().CreationOptions(challenge, user.ID, user.Email, name, existing), nil
+}
+
+// FinishWebAuthnRegistration 校验并保存安全密钥，首次启用MFA时生成恢复码
+func (s *KYCService) FinishWebAuthnRegistration(ctx context.Context, user *models.User, name string, resp *webauthn.CredentialCreationResponse) (*models.WebAuthnCredential, []string, error) {
+	if !s.webAuthnEnabled() {
+		return nil, nil, ErrWebAuthnDisabled
+	}
+	challenge, err := s.takeWebAuthnChallenge(ctx, "reg", user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cred, err := s.relyingParty().VerifyRegistration(resp, challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+	pemKey, err := jwtkeys.MarshalPublicKey(cred.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if name == "" {
+		name = "Security key"
+	}
+	row := &models.WebAuthnCredential{ID: cred.ID, UserID: user.ID, Name: name, PublicKey: pemKey, Algorithm: cred.Algorithm, SignCount: cred.SignCount}
+	var codes []string
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(row).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = ensureRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return row, codes, nil
+}
+
+// BeginWebAuthnLogin 生成安全密钥验证参数
+func (s *KYCService) BeginWebAuthnLogin(ctx context.Context, userID string) (map[string]interface{}, error) {
+	if !s.webAuthnEnabled() {
+		return nil, ErrWebAuthnDisabled
+	}
+	var ids []string
+	if err := s.DB.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrMFANotEnrolled
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Redis.Set(ctx, webAuthnChallengeKey("login", userID), challenge, webAuthnChallengeTTL).Err(); err != nil {
+		return nil, err
+	}
+	return s.relyingParty().AssertionOptions(challenge, ids), nil
+}
+
+func (s *KYCService) finishWebAuthnLogin(ctx context.Context, userID string, resp *webauthn.CredentialAssertionResponse) error {
+	if !s.webAuthnEnabled() {
+		return ErrWebAuthnDisabled
+	}
+	challenge, err := s.takeWebAuthnChallenge(ctx, "login", userID)
+	if err != nil {
+		return err
+	}
+	credID := resp.RawID
+	if credID == "" {
+		credID = resp.ID
+	}
+	var row models.WebAuthnCredential
+	if err := s.DB.Where("id = ? AND user_id = ?", credID, userID).First(&row).Error; err != nil {
+		return ErrMFAInvalidCode
+	}
+	pub, err := jwtkeys.ParsePublicKey(row.PublicKey)
+	if err != nil {
+		return err
+	}
+	count, err := s.relyingParty().VerifyAssertion(resp, challenge, &webauthn.Credential{ID: row.ID, PublicKey: pub, Algorithm: row.Algorithm, SignCount: row.SignCount})
+	if err != nil {
+		logger.GetLogger().WithError(err).Warnf("WebAuthn 断言校验失败: user=%s credential=%s", userID, row.ID)
+		return ErrMFAInvalidCode
+	}
+	now := time.Now()
+	return s.DB.Model(&row).Updates(map[string]interface{}{"sign_count": count, "last_used_at": &now}).Error
+}
+
+// DeleteWebAuthnCredential 删除安全密钥；组织或平台要求MFA时不能移除最后一种因素
+func (s *KYCService) DeleteWebAuthnCredential(user *models.User, orgID, credentialID string) error {
+	st, err := s.GetMFAStatus(user)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, c := range st.WebAuthn {
+		if c.ID == credentialID {
+			found = true
+		}
+	}
+	if !found {
+		return ErrMFANotEnrolled
+	}
+	if !st.TOTPEnabled && len(st.WebAuthn) == 1 && s.MFARequired(user, orgID) {
+		return ErrMFALastFactorRequired
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", credentialID, user.ID).Delete(&models.WebAuthnCredential{}).Error; err != nil {
+			return err
+		}
+		if !st.TOTPEnabled && len(st.WebAuthn) == 1 {
+			return tx.Where("user_id = ?", user.ID).Delete(&models.MFARecoveryCode{}).Error
+		}
+		return nil
+	})
+}
+
+// String 摘要形式，便于审计日志记录
+func (st *MFAStatus) String() string {
+	b, _ := json.Marshal(map[string]interface{}{"totp": st.TOTPEnabled, "webauthn": len(st.WebAuthn), "recovery_codes_left": st.RecoveryCodesLeft})
+	return string(b)
+}
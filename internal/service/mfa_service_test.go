@@ -0,0 +1,76 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionAuthClaimsAndStepUp(t *testing.T) {
+	now := time.Now()
+	// 模拟令牌解析后的声明（数组为 []interface{}，数字为 float64）
+	parsed := func(c jwt.MapClaims) jwt.MapClaims {
+		out := jwt.MapClaims{}
+		if amr, ok := c["amr"].([]string); ok {
+			raw := make([]interface{}, len(amr))
+			for i, v := range amr {
+				raw[i] = v
+			}
+			out["amr"] = raw
+		}
+		if at, ok := c["mfa_at"].(int64); ok {
+			out["mfa_at"] = float64(at)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		amr    []string
+		mfaAt  time.Time
+		check  time.Time
+		fresh  bool
+		hasMFA bool
+	}{
+		{"仅密码登录", []string{AMRPassword}, now, now, false, false},
+		{"刚完成MFA", CompleteAMR([]string{AMRPassword}, AMROTP), now, now.Add(time.Minute), true, true},
+		{"MFA已过期", CompleteAMR([]string{AMRFederated}, AMRHardwareKey), now, now.Add(11 * time.Minute), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := parsed(SessionAuthClaims(jwt.MapClaims{}, tt.amr, tt.mfaAt))
+			assert.Equal(t, tt.hasMFA, HasAMR(ClaimsAMR(claims), AMRMFA))
+			assert.Equal(t, tt.fresh, StepUpFresh(claims, 10*time.Minute, tt.check))
+		})
+	}
+	assert.Equal(t, []string{AMRPassword, AMRRecovery, AMRMFA}, CompleteAMR([]string{AMRPassword}, AMRRecovery))
+	assert.False(t, StepUpFresh(nil, time.Hour, now))
+}
+
+func TestRecoveryCodeFormat(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		code, err := newRecoveryCode()
+		require.NoError(t, err)
+		require.Len(t, code, 2*recoveryCodeHalfWidth+1)
+		assert.Equal(t, "-", code[recoveryCodeHalfWidth:recoveryCodeHalfWidth+1])
+		assert.False(t, seen[code])
+		seen[code] = true
+		// 用户输入时大小写与空白不影响匹配
+		assert.Equal(t, HashClientSecret(normalizeRecoveryCode(code)), HashClientSecret(normalizeRecoveryCode(" "+strings.ToUpper(code)+" ")))
+	}
+}
+
+func TestMFAStatusMethods(t *testing.T) {
+	st := &MFAStatus{}
+	assert.False(t, st.Enrolled())
+	assert.Empty(t, st.Methods())
+	st.TOTPEnabled = true
+	st.RecoveryCodesLeft = 3
+	assert.True(t, st.Enrolled())
+	assert.Equal(t, []string{MFAMethodTOTP, MFAMethodRecovery}, st.Methods())
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/crypto"
+	"kyc-service/pkg/jwtkeys"
+	"kyc-service/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// ClientAuthPrivateKeyJWT 以客户端私钥签名的断言认证（RFC 7523），与 ClientAuthSecretPost、ClientAuthNone 并列
+	ClientAuthPrivateKeyJWT = "private_key_jwt"
+
+	// ClientAssertionTypeJWTBearer RFC 7523 客户端断言类型
+	ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// DefaultClientSecretGrace 轮换密钥后旧密钥的默认宽限期
+	DefaultClientSecretGrace = 24 * time.Hour
+	// MaxClientSecretGrace 旧密钥宽限期上限
+	MaxClientSecretGrace = 7 * 24 * time.Hour
+
+	// 客户端断言最长有效期，防止签发长期可用的断言
+	maxClientAssertionLifetime = 10 * time.Minute
+)
+
+var (
+	ErrOAuthInvalidClient   = errors.New("invalid_client")
+	ErrInvalidClientAuthKey = errors.New("invalid client public key")
+)
+
+// ClientCredentials 令牌端点提交的客户端认证信息
+type ClientCredentials struct {
+	ClientID        string
+	ClientSecret    string
+	AssertionType   string
+	Assertion       string
+	AllowPublic     bool     // 授权码/刷新模式允许登记为 none 的公开客户端不提交凭证
+	ExpectAudiences []string // client_assertion 的 aud 须包含其一（令牌端点地址）
+}
+
+// HashClientSecret 客户端密钥哈希（与 API Key 一致）
+func HashClientSecret(secret string) string {
+	h, _ := crypto.HashString(secret)
+	return h
+}
+
+// SetClientSecret 设置新的客户端密钥：当前密钥转为旧密钥并在宽限期内继续可用，grace<=0 时立即失效
+func (s *KYCService) SetClientSecret(client *models.OAuthClient, secret string, grace time.Duration) {
+	client.PreviousSecretHash = ""
+	client.PreviousSecretExpiresAt = nil
+	if client.SecretHash != "" && grace > 0 {
+		exp := time.Now().Add(grace)
+		client.PreviousSecretHash = client.SecretHash
+		client.PreviousSecretExpiresAt = &exp
+	}
+	client.Secret = ""
+	client.SecretHash = HashClientSecret(secret)
+	client.SecretEnc = ""
+	if s.Encryptor != nil {
+		if enc, err := s.Encryptor.Encrypt(secret); err == nil {
+			client.SecretEnc = enc
+		} else {
+			logger.GetLogger().WithError(err).Warn("加密客户端密钥失败，改为不保存密钥副本")
+		}
+	}
+}
+
+// VerifyClientSecret 校验客户端密钥（当前密钥，或宽限期内的旧密钥）
+func VerifyClientSecret(client *models.OAuthClient, secret string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	h := HashClientSecret(secret)
+	if client.SecretHash != "" && subtle.ConstantTimeCompare([]byte(h), []byte(client.SecretHash)) == 1 {
+		return true
+	}
+	if client.PreviousSecretHash != "" && client.PreviousSecretExpiresAt != nil && now.Before(*client.PreviousSecretExpiresAt) &&
+		subtle.ConstantTimeCompare([]byte(h), []byte(client.PreviousSecretHash)) == 1 {
+		return true
+	}
+	return false
+}
+
+// ValidateClientPublicKeys 校验客户端登记的 JWK Set / PEM 公钥
+func ValidateClientPublicKeys(jwks string) error {
+	if _, err := jwtkeys.ParsePublicKeys(jwks); err != nil {
+		return errors.Join(ErrInvalidClientAuthKey, err)
+	}
+	return nil
+}
+
+// AuthenticateOAuthClient 令牌端点客户端认证：client_secret_post、private_key_jwt（RFC 7523）或公开客户端（none）
+func (s *KYCService) AuthenticateOAuthClient(ctx context.Context, cred ClientCredentials) (*models.OAuthClient, error) {
+	clientID := cred.ClientID
+	if cred.Assertion != "" && clientID == "" {
+		// RFC 7523 允许省略 client_id，由断言的 sub 确定客户端（签名稍后校验）
+		if tok, _, err := jwt.NewParser().ParseUnverified(cred.Assertion, jwt.MapClaims{}); err == nil {
+			clientID, _ = tok.Claims.(jwt.MapClaims)["sub"].(string)
+		}
+	}
+	if clientID == "" {
+		return nil, ErrOAuthInvalidClient
+	}
+	var client models.OAuthClient
+	if err := s.DB.Where("id = ? AND status = ?", clientID, "active").First(&client).Error; err != nil {
+		return nil, ErrOAuthInvalidClient
+	}
+	if err := s.verifyClientCredentials(ctx, &client, cred); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// verifyClientCredentials 按客户端登记的认证方式校验凭证。
+// 只有登记为 none 的公开客户端可以不提交凭证，其余客户端省略凭证一律视为认证失败。
+func (s *KYCService) verifyClientCredentials(ctx context.Context, client *models.OAuthClient, cred ClientCredentials) error {
+	switch client.AuthMethod {
+	case ClientAuthNone:
+		if !cred.AllowPublic || cred.ClientSecret != "" || cred.Assertion != "" {
+			return ErrOAuthInvalidClient
+		}
+		return nil
+	case ClientAuthPrivateKeyJWT:
+		if cred.AssertionType != ClientAssertionTypeJWTBearer || cred.Assertion == "" || cred.ClientSecret != "" {
+			return ErrOAuthInvalidClient
+		}
+		if err := s.verifyClientAssertion(ctx, client, cred.Assertion, cred.ExpectAudiences); err != nil {
+			logger.GetLogger().WithError(err).Warnf("客户端断言校验失败: client=%s", client.ID)
+			return ErrOAuthInvalidClient
+		}
+		return nil
+	}
+	if cred.Assertion != "" || !VerifyClientSecret(client, cred.ClientSecret, time.Now()) {
+		return ErrOAuthInvalidClient
+	}
+	return nil
+}
+
+// verifyClientAssertion 校验客户端断言：签名、iss/sub、aud、有效期，并以 jti 防重放
+func (s *KYCService) verifyClientAssertion(ctx context.Context, client *models.OAuthClient, assertion string, audiences []string) error {
+	keys, err := jwtkeys.ParsePublicKeys(client.JWKS)
+	if err != nil {
+		return err
+	}
+	claims := jwt.MapClaims{}
+	tok, err := jwt.ParseWithClaims(assertion, claims, jwtkeys.StaticKeyfunc(keys),
+		jwt.WithValidMethods([]string{jwtkeys.AlgRS256, jwtkeys.AlgES256}),
+		jwt.WithExpirationRequired(), jwt.WithIssuer(client.ID), jwt.WithSubject(client.ID))
+	if err != nil || !tok.Valid {
+		return errors.Join(errors.New("invalid client assertion"), err)
+	}
+	aud, _ := claims.GetAudience()
+	if !audienceMatches(aud, audiences) {
+		return errors.New("client assertion audience mismatch")
+	}
+	exp, _ := claims.GetExpirationTime()
+	if time.Until(exp.Time) > maxClientAssertionLifetime {
+		return errors.New("client assertion lifetime too long")
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return errors.New("client assertion missing jti")
+	}
+	if s.Redis != nil {
+		ok, err := s.Redis.SetNX(ctx, "oauth:client_assertion:"+client.ID+":"+jti, 1, time.Until(exp.Time)+time.Minute).Result()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("client assertion replayed")
+		}
+	}
+	return nil
+}
+
+func audienceMatches(aud, expected []string) bool {
+	for _, a := range aud {
+		for _, e := range expected {
+			if a == e {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/jwtkeys"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSecretRotationGrace(t *testing.T) {
+	s := &KYCService{}
+	client := &models.OAuthClient{ID: "c1"}
+	s.SetClientSecret(client, "old-secret", DefaultClientSecretGrace)
+	assert.Nil(t, client.PreviousSecretExpiresAt, "首次设置密钥不应产生旧密钥")
+	assert.NotEqual(t, "old-secret", client.SecretHash)
+
+	s.SetClientSecret(client, "new-secret", time.Hour)
+	now := time.Now()
+	tests := []struct {
+		name   string
+		secret string
+		at     time.Time
+		want   bool
+	}{
+		{"新密钥", "new-secret", now, true},
+		{"宽限期内旧密钥", "old-secret", now, true},
+		{"宽限期后旧密钥", "old-secret", now.Add(2 * time.Hour), false},
+		{"错误密钥", "other", now, false},
+		{"空密钥", "", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, VerifyClientSecret(client, tt.secret, tt.at))
+		})
+	}
+
+	s.SetClientSecret(client, "newest", 0)
+	assert.False(t, VerifyClientSecret(client, "new-secret", now), "grace=0 时旧密钥立即失效")
+}
+
+func TestVerifyClientAssertion(t *testing.T) {
+	key, err := jwtkeys.GenerateKey(jwtkeys.AlgRS256, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	signer := jwtkeys.NewSet(nil, "")
+	signer.Replace([]*jwtkeys.Key{key})
+	pub, err := jwtkeys.MarshalPublicKey(key.Public)
+	require.NoError(t, err)
+
+	s := &KYCService{}
+	client := &models.OAuthClient{ID: "c1", AuthMethod: ClientAuthPrivateKeyJWT, JWKS: pub}
+	aud := []string{"https://kyc.example.com/api/v1/oauth/token"}
+	assertion := func(claims jwt.MapClaims) string {
+		base := jwt.MapClaims{"iss": "c1", "sub": "c1", "aud": aud[0], "jti": "j1", "exp": time.Now().Add(time.Minute).Unix()}
+		for k, v := range claims {
+			base[k] = v
+		}
+		tok, err := signer.Sign(base)
+		require.NoError(t, err)
+		return tok
+	}
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{"有效断言", nil, false},
+		{"sub不是客户端", jwt.MapClaims{"sub": "c2"}, true},
+		{"aud不匹配", jwt.MapClaims{"aud": "https://other.example.com/token"}, true},
+		{"有效期过长", jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()}, true},
+		{"缺少jti", jwt.MapClaims{"jti": ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.verifyClientAssertion(context.Background(), client, assertion(tt.claims), aud)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyClientCredentialsPublicClient(t *testing.T) {
+	s := &KYCService{}
+	confidential := &models.OAuthClient{ID: "c1", AuthMethod: ClientAuthSecretPost}
+	s.SetClientSecret(confidential, "secret", 0)
+	public := &models.OAuthClient{ID: "c2", AuthMethod: ClientAuthNone}
+	tests := []struct {
+		name    string
+		client  *models.OAuthClient
+		cred    ClientCredentials
+		wantErr bool
+	}{
+		{"机密客户端正确密钥", confidential, ClientCredentials{ClientSecret: "secret"}, false},
+		{"机密客户端省略密钥", confidential, ClientCredentials{AllowPublic: true}, true},
+		{"机密客户端错误密钥", confidential, ClientCredentials{ClientSecret: "other", AllowPublic: true}, true},
+		{"公开客户端授权码/刷新模式", public, ClientCredentials{AllowPublic: true}, false},
+		{"公开客户端用于client_credentials", public, ClientCredentials{}, true},
+		{"公开客户端提交密钥", public, ClientCredentials{ClientSecret: "secret", AllowPublic: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.verifyClientCredentials(context.Background(), tt.client, tt.cred)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrOAuthInvalidClient)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	oauthCodeTTL    = 10 * time.Minute
+	oauthRefreshTTL = 7 * 24 * time.Hour
+
+	// OAuthTokenUseAccess / OAuthTokenUseRefresh 写入令牌的 token_use 声明，防止刷新令牌被当作访问令牌使用
+	OAuthTokenUseAccess  = "access"
+	OAuthTokenUseRefresh = "refresh"
+
+	// 客户端令牌端点认证方式
+	ClientAuthSecretPost = "client_secret_post"
+	ClientAuthNone       = "none" // 公开客户端：不持有凭证，只能用于授权码（PKCE）与刷新模式
+)
+
+var (
+	ErrOAuthInvalidGrant   = errors.New("invalid_grant")
+	ErrOAuthRefreshReused  = errors.New("refresh token reuse detected")
+	ErrOAuthInvalidRequest = errors.New("invalid_request")
+)
+
+// OAuthGrant 一次令牌签发的主体信息
+type OAuthGrant struct {
+	ClientID string
+	UserID   string // 代表的控制台用户，客户端凭证模式为空
+	OrgID    string
+	Scope    string
+	FamilyID string        // 为空时开启新的令牌族
+	TTL      time.Duration // 访问令牌有效期
+}
+
+// OAuthAccessTTL 客户端配置的访问令牌有效期，默认24小时
+func OAuthAccessTTL(client *models.OAuthClient) time.Duration {
+	if client != nil && client.TokenTTLSeconds > 0 {
+		return time.Duration(client.TokenTTLSeconds) * time.Second
+	}
+	return 24 * time.Hour
+}
+
+// IssueOAuthToken 签发访问令牌与刷新令牌并落库
+func (s *KYCService) IssueOAuthToken(tx *gorm.DB, g OAuthGrant) (*models.OAuthToken, error) {
+	now := time.Now()
+	if g.FamilyID == "" {
+		g.FamilyID = utils.GenerateID()
+	}
+	access, err := s.SignJWT(jwt.MapClaims{
+		"client_id": g.ClientID,
+		"user_id":   g.UserID,
+		"scope":     g.Scope,
+		"org_id":    g.OrgID,
+		"fid":       g.FamilyID,
+		"token_use": OAuthTokenUseAccess,
+		"jti":       utils.GenerateID(),
+		"exp":       now.Add(g.TTL).Unix(),
+		"iat":       now.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	refreshExp := now.Add(oauthRefreshTTL)
+	refresh, err := s.SignJWT(jwt.MapClaims{
+		"client_id": g.ClientID,
+		"user_id":   g.UserID,
+		"fid":       g.FamilyID,
+		"token_use": OAuthTokenUseRefresh,
+		"jti":       utils.GenerateID(),
+		"exp":       refreshExp.Unix(),
+		"iat":       now.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tok := &models.OAuthToken{
+		AccessToken: access, RefreshToken: refresh, UserID: g.UserID, OrgID: g.OrgID, ClientID: g.ClientID,
+		Scopes: g.Scope, FamilyID: g.FamilyID, ExpiresAt: now.Add(g.TTL), RefreshExpiresAt: &refreshExp,
+	}
+	if err := tx.Create(tok).Error; err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+// VerifyPKCE 校验 code_verifier 与授权时提交的 code_challenge（仅支持 S256）
+func VerifyPKCE(verifier, challenge, method string) bool {
+	if method != "S256" || len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func hashOAuthCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuthorizationCodeRequest 用户同意授权后生成授权码所需的信息
+type AuthorizationCodeRequest struct {
+	ClientID            string
+	UserID              string
+	OrgID               string
+	RedirectURI         string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// CreateAuthorizationCode 生成一次性授权码，库中仅保存其哈希
+func (s *KYCService) CreateAuthorizationCode(req AuthorizationCodeRequest) (string, error) {
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return "", ErrOAuthInvalidRequest
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(buf)
+	err := s.DB.Create(&models.OAuthAuthorizationCode{
+		CodeHash: hashOAuthCode(code), ClientID: req.ClientID, UserID: req.UserID, OrgID: req.OrgID,
+		RedirectURI: req.RedirectURI, Scopes: req.Scope, CodeChallenge: req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod, FamilyID: utils.GenerateID(), ExpiresAt: time.Now().Add(oauthCodeTTL),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ExchangeAuthorizationCode 以授权码 + code_verifier 换取令牌。授权码被重复使用时吊销由它签发的整个令牌族。
+func (s *KYCService) ExchangeAuthorizationCode(ctx context.Context, client *models.OAuthClient, code, redirectURI, verifier string) (*models.OAuthToken, error) {
+	var ac models.OAuthAuthorizationCode
+	if err := s.DB.Where("code_hash = ? AND client_id = ?", hashOAuthCode(code), client.ID).First(&ac).Error; err != nil {
+		return nil, ErrOAuthInvalidGrant
+	}
+	if ac.UsedAt != nil {
+		s.RevokeOAuthFamily(ctx, ac.FamilyID, "authorization_code_reuse")
+		return nil, ErrOAuthInvalidGrant
+	}
+	if time.Now().After(ac.ExpiresAt) || ac.RedirectURI != redirectURI || !VerifyPKCE(verifier, ac.CodeChallenge, ac.CodeChallengeMethod) {
+		return nil, ErrOAuthInvalidGrant
+	}
+	var tok *models.OAuthToken
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.OAuthAuthorizationCode{}).Where("code_hash = ? AND used_at IS NULL", ac.CodeHash).Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrOAuthInvalidGrant
+		}
+		var err error
+		tok, err = s.IssueOAuthToken(tx, OAuthGrant{
+			ClientID: client.ID, UserID: ac.UserID, OrgID: ac.OrgID, Scope: ac.Scopes, FamilyID: ac.FamilyID, TTL: OAuthAccessTTL(client),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+// RotateRefreshToken 刷新令牌轮换：旧刷新令牌标记为已轮换并签发新令牌（同一族）。
+// 已轮换的刷新令牌再次出现视为泄露，吊销整个令牌族。
+func (s *KYCService) RotateRefreshToken(ctx context.Context, client *models.OAuthClient, refreshToken string) (*models.OAuthToken, error) {
+	var old models.OAuthToken
+	if err := s.DB.Where("refresh_token = ? AND client_id = ?", refreshToken, client.ID).First(&old).Error; err != nil {
+		return nil, ErrOAuthInvalidGrant
+	}
+	if old.RevokedAt != nil {
+		return nil, ErrOAuthInvalidGrant
+	}
+	if old.RotatedAt != nil {
+		s.RevokeOAuthFamily(ctx, old.FamilyID, "refresh_token_reuse")
+		return nil, ErrOAuthRefreshReused
+	}
+	refreshExp := old.ExpiresAt
+	if old.RefreshExpiresAt != nil {
+		refreshExp = *old.RefreshExpiresAt
+	}
+	if time.Now().After(refreshExp) {
+		return nil, ErrOAuthInvalidGrant
+	}
+	family := old.FamilyID
+	if family == "" {
+		// 升级前签发的令牌没有族ID，以旧记录ID开启新族
+		family = utils.GenerateID()
+	}
+	var tok *models.OAuthToken
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.OAuthToken{}).Where("id = ? AND rotated_at IS NULL", old.ID).
+			Updates(map[string]interface{}{"rotated_at": time.Now(), "family_id": family})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// 并发刷新：另一请求已使用该刷新令牌
+			return ErrOAuthRefreshReused
+		}
+		var err error
+		tok, err = s.IssueOAuthToken(tx, OAuthGrant{
+			ClientID: client.ID, UserID: old.UserID, OrgID: old.OrgID, Scope: old.Scopes, FamilyID: family, TTL: OAuthAccessTTL(client),
+		})
+		return err
+	})
+	if errors.Is(err, ErrOAuthRefreshReused) {
+		s.RevokeOAuthFamily(ctx, family, "refresh_token_reuse")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+func oauthFamilyRevokedKey(familyID string) string { return "oauth:family_revoked:" + familyID }
+
+// RevokeOAuthFamily 吊销令牌族中的全部令牌（含仍在有效期内的访问令牌）
+func (s *KYCService) RevokeOAuthFamily(ctx context.Context, familyID, reason string) {
+	if familyID == "" {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	var tokens []models.OAuthToken
+	_ = s.DB.Select("client_id", "scopes", "user_id").Where("family_id = ? AND revoked_at IS NULL", familyID).Find(&tokens).Error
+	if err := s.DB.Model(&models.OAuthToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).Update("revoked_at", time.Now()).Error; err != nil {
+		logger.GetLogger().WithError(err).Errorf("吊销OAuth令牌族失败: family=%s", familyID)
+		return
+	}
+	if s.Redis != nil {
+		_ = s.Redis.Set(ctx, oauthFamilyRevokedKey(familyID), reason, oauthRefreshTTL).Err()
+		for _, t := range tokens {
+			if t.UserID == "" {
+				_ = s.Redis.Del(ctx, "oauth:token:"+t.ClientID+":"+t.Scopes).Err()
+			}
+		}
+	}
+	logger.GetLogger().Warnf("OAuth令牌族已吊销: family=%s reason=%s", familyID, reason)
+}
+
+// IsOAuthFamilyRevoked 令牌族是否已被吊销（优先查 Redis 标记）
+func (s *KYCService) IsOAuthFamilyRevoked(ctx context.Context, familyID string) bool {
+	if familyID == "" {
+		return false
+	}
+	if s.Redis != nil {
+		if n, err := s.Redis.Exists(ctx, oauthFamilyRevokedKey(familyID)).Result(); err == nil {
+			return n > 0
+		}
+	}
+	var n int64
+	_ = s.DB.Model(&models.OAuthToken{}).Where("family_id = ? AND revoked_at IS NOT NULL", familyID).Limit(1).Count(&n).Error
+	return n > 0
+}
+
+// ValidateRedirectURI redirect_uri 必须与客户端登记的地址之一完全一致（多个地址以空白或逗号分隔）
+func ValidateRedirectURI(client *models.OAuthClient, redirectURI string) bool {
+	if redirectURI == "" {
+		return false
+	}
+	for _, u := range strings.FieldsFunc(client.RedirectURI, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		if u == redirectURI {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"kyc-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		method    string
+		want      bool
+	}{
+		{"S256匹配", verifier, challenge, "S256", true},
+		{"verifier不匹配", strings.Repeat("b", 43), challenge, "S256", false},
+		{"plain方法不支持", verifier, verifier, "plain", false},
+		{"verifier过短", "short", challenge, "S256", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, VerifyPKCE(tt.verifier, tt.challenge, tt.method))
+		})
+	}
+}
+
+func TestValidateRedirectURI(t *testing.T) {
+	client := &models.OAuthClient{RedirectURI: "https://a.example.com/cb, https://b.example.com/cb"}
+	tests := []struct {
+		name string
+		uri  string
+		want bool
+	}{
+		{"登记地址", "https://b.example.com/cb", true},
+		{"前缀不算匹配", "https://a.example.com/cb/evil", false},
+		{"空地址", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidateRedirectURI(client, tt.uri))
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"net"
 	"time"
@@ -13,7 +14,6 @@ import (
 	"kyc-service/pkg/logger"
 	"kyc-service/pkg/metrics"
 	"kyc-service/pkg/tracing"
-	"kyc-service/pkg/utils"
 
 	"go.opentelemetry.io/otel/attribute"
 )
@@ -130,6 +130,7 @@ func (s *KYCService) OCR(ctx context.Context, req *OCRRequest) (*OCRResponse, er
 		}
 		metrics.RecordBusinessOperation(ctx, "ocr", false, time.Since(start), "ocr_service_error")
 		metrics.RecordDependencyCall(ctx, "ocr_service", "recognize", false, time.Since(start))
+		s.emitKYCEvent(ctx, WebhookEventOCRCompleted, getRequestID(ctx), "ocr_"+ocrType, "failed", "")
 		return nil, err
 	}
 	fmt.Printf("output: %v, %v\n", "end1", time.Since(now1))
@@ -155,10 +156,37 @@ func (s *KYCService) OCR(ctx context.Context, req *OCRRequest) (*OCRResponse, er
 
 	//s.DB.Save(kycRequest)
 	s.RecordAuditLog(ctx, "ocr."+ocrType+".scan", "ocr", "", "success", "")
+	s.emitKYCEvent(ctx, WebhookEventOCRCompleted, getRequestID(ctx), "ocr_"+ocrType, "success", "")
 	return ocrResult, nil
 }
 
 func (s *KYCService) callOCRService(ctx context.Context, request *OCRRequest) (*OCRResponse, error) {
+	file, err := request.Picture.Open()
+	if err != nil {
+		metrics.RecordThirdPartyRequest(ctx, "ocr_service", metrics.ResultRequestPrepareFailed, "", 0)
+		return nil, fmt.Errorf("open image file failed: %w", err)
+	}
+	defer file.Close()
+	return s.callOCRReader(ctx, file, request.Picture.Filename, request.Type, request.Language)
+}
+
+// callOCRReader 以任意数据源调用OCR服务（异步任务从已落盘的图片读取），按证件类型路由到可用供应商
+func (s *KYCService) callOCRReader(ctx context.Context, file io.Reader, filename, ocrType, language string) (*OCRResponse, error) {
+	image, err := io.ReadAll(file)
+	if err != nil {
+		metrics.RecordThirdPartyRequest(ctx, "ocr_service", metrics.ResultRequestPrepareFailed, "", 0)
+		return nil, fmt.Errorf("read image file failed: %w", err)
+	}
+	image, filename, err = s.prepareImage(ctx, CapabilityOCR, image, filename)
+	if err != nil {
+		metrics.RecordThirdPartyRequest(ctx, "ocr_service", metrics.ResultRequestPrepareFailed, "", 0)
+		return nil, err
+	}
+	return s.Providers.RecognizeOCR(ctx, image, filename, ocrType, language)
+}
+
+// callOCRHTTP 调用 vrlOCR 接口
+func (s *KYCService) callOCRHTTP(ctx context.Context, client *httpclient.Client, url string, file io.Reader, filename, ocrType, language string) (*OCRResponse, error) {
 	ctx, span := tracing.StartSpan(ctx, "KYCService.callOCRService")
 	defer span.End()
 
@@ -171,52 +199,30 @@ func (s *KYCService) callOCRService(ctx context.Context, request *OCRRequest) (*
 		metrics.RecordThirdPartyRequest(ctx, "ocr_service", result, httpStatusCode, duration)
 	}()
 
-	file, err := request.Picture.Open()
-	if err != nil {
-		result = metrics.ResultRequestPrepareFailed
-		return nil, fmt.Errorf("open image file failed: %w", err)
-	}
-	defer file.Close()
-
 	data := map[string]string{
 		//"token":      "testuser",
-		"type":       request.Type,
-		"request_id": ctx.Value("request_id").(string),
+		"type":       ocrType,
+		"request_id": getRequestID(ctx),
 		//"language":   request.Language,
-		"country": request.Language,
+		"country": language,
 	}
 
-	var respBody []byte
-	if s.Config.UseMock {
-		time.Sleep(time.Duration(utils.GenerateRandomNumbers(5)) * time.Second)
-		errmsg := "{\n  \"code\": 500,\n  \"error\": \"mock error\",\n  \"msg\": \"recognition error\"\n}"
-		succmsg := `{"code":0,"msg":"recognition success","parsing_results":{},"full_text":"","filename":"t1.jpg"}`
-		if utils.GenerateRandomBool() {
-			respBody = []byte(succmsg)
-		} else {
-			respBody = []byte(errmsg)
+	now3 := time.Now()
+	respBody, err := client.PostMultipart(ctx, url, data, file, filename)
+	fmt.Printf("output: %v, %v\n", "end3", time.Since(now3))
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			result = metrics.ResultRequestTimeout
+			return nil, fmt.Errorf("%w: %v", ErrUpstreamTimeout, err)
 		}
-	} else {
-		ocrConfig := s.Config.ThirdParty.OCRService
-		url := ocrConfig.URL
-		s.HTTPClient.SetConfig(ocrConfig.RetryCount, ocrConfig.Timeout)
-		now3 := time.Now()
-		respBody, err = s.HTTPClient.PostMultipart(ctx, url, data, file, request.Picture.Filename)
-		fmt.Printf("output: %v, %v\n", "end3", time.Since(now3))
-		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				result = metrics.ResultRequestTimeout
-				return nil, fmt.Errorf("%w: %v", ErrUpstreamTimeout, err)
-			}
-			if errors.Is(err, context.Canceled) {
-				result = metrics.ResultContextCanceled
-				return nil, fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)
-			}
-			result = metrics.ResultDialError
+		if errors.Is(err, context.Canceled) {
+			result = metrics.ResultContextCanceled
 			return nil, fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)
 		}
-		httpStatusCode = "200"
+		result = metrics.ResultDialError
+		return nil, fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)
 	}
+	httpStatusCode = "200"
 
 	logger.GetLogger().Infof("callOCRService resp: %v", string(respBody))
 
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// OrgDomainTXTPrefix 验证记录名前缀：_kyc-service-verification.<domain>
+	OrgDomainTXTPrefix = "_kyc-service-verification."
+	// OrgDomainTXTValuePrefix 验证记录值前缀，其后为认领时生成的随机值
+	OrgDomainTXTValuePrefix = "kyc-service-verification="
+)
+
+var (
+	ErrOrgDomainTaken       = errors.New("domain is already verified by another organization")
+	ErrOrgDomainNotFound    = errors.New("domain has not been claimed by this organization")
+	ErrOrgDomainTXTNotFound = errors.New("verification TXT record not found")
+	ErrOrgDomainUnverified  = errors.New("email domain has not been verified by this organization")
+)
+
+// lookupTXT 查询 TXT 记录，测试中替换
+var lookupTXT = net.DefaultResolver.LookupTXT
+
+// publicMailDomains 公共邮箱服务的域名，任何组织都不能认领
+var publicMailDomains = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "outlook.com": true, "hotmail.com": true, "live.com": true,
+	"msn.com": true, "yahoo.com": true, "ymail.com": true, "icloud.com": true, "me.com": true, "mac.com": true,
+	"aol.com": true, "proton.me": true, "protonmail.com": true, "gmx.com": true, "gmx.net": true, "mail.com": true,
+	"zoho.com": true, "yandex.com": true, "yandex.ru": true, "mail.ru": true, "qq.com": true, "foxmail.com": true,
+	"163.com": true, "126.com": true, "yeah.net": true, "139.com": true, "sina.com": true, "sina.cn": true,
+	"sohu.com": true, "aliyun.com": true, "tom.com": true,
+}
+
+// IsPublicMailDomain 是否为公共邮箱服务域名
+func IsPublicMailDomain(domain string) bool {
+	return publicMailDomains[strings.ToLower(strings.TrimSpace(domain))]
+}
+
+// OrgDomainView 域名认领状态及需要在 DNS 中发布的 TXT 记录
+type OrgDomainView struct {
+	Domain     string     `json:"domain"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	TXTName    string     `json:"txt_record_name"`
+	TXTValue   string     `json:"txt_record_value"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewOrgDomainView 生成认领记录的展示信息
+func NewOrgDomainView(d *models.OrgDomain) OrgDomainView {
+	return OrgDomainView{
+		Domain:     d.Domain,
+		Verified:   d.VerifiedAt != nil,
+		VerifiedAt: d.VerifiedAt,
+		TXTName:    OrgDomainTXTPrefix + d.Domain,
+		TXTValue:   OrgDomainTXTValuePrefix + d.Token,
+		CreatedAt:  d.CreatedAt,
+	}
+}
+
+// normalizeOrgDomain 规范化单个域名，拒绝公共邮箱域名；无效时返回 *SSOConfigError
+func normalizeOrgDomain(domain string) (string, error) {
+	ds, err := normalizeEmailDomains([]string{domain})
+	if err != nil {
+		return "", err
+	}
+	return ds[0], nil
+}
+
+// ListOrgDomains 组织认领的域名
+func (s *KYCService) ListOrgDomains(orgID string) ([]models.OrgDomain, error) {
+	var out []models.OrgDomain
+	err := s.DB.Where("org_id = ?", orgID).Order("domain").Find(&out).Error
+	return out, err
+}
+
+// ClaimOrgDomain 认领域名并生成 TXT 验证值；已认领时返回原记录
+func (s *KYCService) ClaimOrgDomain(orgID, userID, domain string) (*models.OrgDomain, error) {
+	domain, err := normalizeOrgDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	var rec models.OrgDomain
+	err = s.DB.Where("org_id = ? AND domain = ?", orgID, domain).First(&rec).Error
+	if err == nil {
+		return &rec, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if owner, err := s.VerifiedOrgForDomain(domain); err != nil {
+		return nil, err
+	} else if owner != "" {
+		return nil, ErrOrgDomainTaken
+	}
+	token, err := randomURLToken(24)
+	if err != nil {
+		return nil, err
+	}
+	rec = models.OrgDomain{ID: utils.GenerateID(), OrgID: orgID, Domain: domain, Token: token, CreatedBy: userID}
+	if err := s.DB.Create(&rec).Error; err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// ensureOrgDomainClaims 为 SSO 配置中尚未认领的域名创建待验证记录；域名已被其他组织验证时拒绝
+func (s *KYCService) ensureOrgDomainClaims(orgID, userID string, domains []string) error {
+	for _, d := range domains {
+		if _, err := s.ClaimOrgDomain(orgID, userID, d); err != nil {
+			if errors.Is(err, ErrOrgDomainTaken) {
+				return &SSOConfigError{Message: "邮箱域名 " + d + " 已被其他组织验证"}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyOrgDomain 查询 TXT 记录完成所有权验证
+func (s *KYCService) VerifyOrgDomain(ctx context.Context, orgID, domain string) (*models.OrgDomain, error) {
+	domain, err := normalizeOrgDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	var rec models.OrgDomain
+	if err := s.DB.Where("org_id = ? AND domain = ?", orgID, domain).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrgDomainNotFound
+		}
+		return nil, err
+	}
+	if rec.VerifiedAt != nil {
+		return &rec, nil
+	}
+	if owner, err := s.VerifiedOrgForDomain(domain); err != nil {
+		return nil, err
+	} else if owner != "" {
+		return nil, ErrOrgDomainTaken
+	}
+	records, err := lookupTXT(ctx, OrgDomainTXTPrefix+domain)
+	if err != nil || !hasDomainVerificationRecord(records, rec.Token) {
+		return nil, ErrOrgDomainTXTNotFound
+	}
+	now := time.Now()
+	// 部分唯一索引保证并发验证时只有一个组织成功
+	res := s.DB.Model(&models.OrgDomain{}).Where("id = ? AND verified_at IS NULL", rec.ID).Update("verified_at", now)
+	if res.Error != nil {
+		if owner, _ := s.VerifiedOrgForDomain(domain); owner != "" && owner != orgID {
+			return nil, ErrOrgDomainTaken
+		}
+		return nil, res.Error
+	}
+	rec.VerifiedAt = &now
+	return &rec, nil
+}
+
+func hasDomainVerificationRecord(records []string, token string) bool {
+	if token == "" {
+		return false
+	}
+	want := OrgDomainTXTValuePrefix + token
+	for _, r := range records {
+		if strings.TrimSpace(r) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteOrgDomain 取消认领；已验证的域名删除后不再用于 SSO 路由与即时开通
+func (s *KYCService) DeleteOrgDomain(orgID, domain string) error {
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "@")
+	res := s.DB.Where("org_id = ? AND domain = ?", orgID, domain).Delete(&models.OrgDomain{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrOrgDomainNotFound
+	}
+	return nil
+}
+
+// VerifiedOrgForDomain 已验证该域名的组织，没有时返回空串
+func (s *KYCService) VerifiedOrgForDomain(domain string) (string, error) {
+	var rec models.OrgDomain
+	err := s.DB.Select("org_id").Where("domain = ? AND verified_at IS NOT NULL", domain).First(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return rec.OrgID, err
+}
+
+// OrgOwnsEmailDomain 邮箱的域名是否已由该组织验证
+func (s *KYCService) OrgOwnsEmailDomain(orgID, email string) (bool, error) {
+	d := emailDomain(email)
+	if d == "" || orgID == "" {
+		return false, nil
+	}
+	owner, err := s.VerifiedOrgForDomain(d)
+	return owner == orgID, err
+}
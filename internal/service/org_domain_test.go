@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+
+	"kyc-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrgDomainVerificationRecord(t *testing.T) {
+	v := NewOrgDomainView(&models.OrgDomain{Domain: "example.com", Token: "tok123"})
+	assert.False(t, v.Verified)
+	assert.Equal(t, "_kyc-service-verification.example.com", v.TXTName)
+	assert.Equal(t, "kyc-service-verification=tok123", v.TXTValue)
+
+	assert.True(t, hasDomainVerificationRecord([]string{"v=spf1 -all", " kyc-service-verification=tok123 "}, "tok123"))
+	assert.False(t, hasDomainVerificationRecord([]string{"kyc-service-verification=other"}, "tok123"))
+	assert.False(t, hasDomainVerificationRecord(nil, "tok123"))
+	// 空 token 不能被空值或前缀匹配
+	assert.False(t, hasDomainVerificationRecord([]string{"kyc-service-verification="}, ""))
+}
+
+func TestIsPublicMailDomain(t *testing.T) {
+	assert.True(t, IsPublicMailDomain(" Gmail.COM"))
+	assert.True(t, IsPublicMailDomain("163.com"))
+	assert.False(t, IsPublicMailDomain("example.com"))
+}
@@ -0,0 +1,511 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// 角色权限缓存：键为角色ID，角色或其权限变更时删除
+const (
+	rolePermCachePrefix = "rbac:role:"
+	rolePermCacheTTL    = 10 * time.Minute
+	customRoleIDPrefix  = "role_"
+	// MaxOrgCustomRoles 每个组织可自定义的角色数上限
+	MaxOrgCustomRoles = 50
+)
+
+var (
+	ErrRoleNotFound           = errors.New("role not found")
+	ErrRoleNotAssignable      = errors.New("role not assignable")
+	ErrRoleNameTaken          = errors.New("role name already used")
+	ErrRoleInUse              = errors.New("role still assigned")
+	ErrRoleLimitReached       = errors.New("custom role limit reached")
+	ErrSystemRoleReadonly     = errors.New("system role is read-only")
+	ErrUnknownPermission      = errors.New("unknown permission")
+	ErrPermissionNotGrantable = errors.New("permission not grantable")
+)
+
+// OwnerOnlyPermissions 只属于组织所有者，不能授予自定义角色
+var OwnerOnlyPermissions = []string{"org.delete", "billing.write"}
+
+// PermissionError 携带导致失败的权限ID
+type PermissionError struct {
+	Err         error
+	Permissions []string
+}
+
+func (e *PermissionError) Error() string {
+	return e.Err.Error() + ": " + strings.Join(e.Permissions, ",")
+}
+
+func (e *PermissionError) Unwrap() error { return e.Err }
+
+// RoleDetail 角色及其权限
+type RoleDetail struct {
+	models.Role
+	Permissions []string `json:"permissions"`
+	MemberCount int64    `json:"member_count"`
+}
+
+// OrgRoleInput 创建/更新组织自定义角色；更新时 Permissions 为 nil 表示不修改
+type OrgRoleInput struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// SimulatedPermission 权限目录中的一项及模拟角色是否拥有
+type SimulatedPermission struct {
+	models.Permission
+	Granted bool `json:"granted"`
+}
+
+// RoleSimulation 以某角色身份预览其可用权限
+type RoleSimulation struct {
+	Role        RoleDetail            `json:"role"`
+	Permissions []SimulatedPermission `json:"permissions"`
+	Granted     int                   `json:"granted"`
+	Total       int                   `json:"total"`
+}
+
+type rolePermCacheEntry struct {
+	OrgID       string   `json:"org_id"`
+	Permissions []string `json:"permissions"`
+}
+
+func rolePermCacheKey(roleID string) string { return rolePermCachePrefix + roleID }
+
+// normalizePermissionIDs 去空白、去重并排序
+func normalizePermissionIDs(ids []string) []string {
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// missingPermissions want 中不被 have 覆盖的权限（have 含 "*" 时全部覆盖）
+func missingPermissions(have, want []string) []string {
+	set := map[string]struct{}{}
+	for _, p := range have {
+		if p == "*" {
+			return nil
+		}
+		set[p] = struct{}{}
+	}
+	var out []string
+	for _, p := range want {
+		if _, ok := set[p]; !ok {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ownerOnly want 中只属于所有者的权限
+func ownerOnly(want []string) []string {
+	var out []string
+	for _, p := range want {
+		for _, o := range OwnerOnlyPermissions {
+			if p == o {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+func (s *KYCService) loadRolePermissions(roleID string) (*rolePermCacheEntry, error) {
+	var role models.Role
+	if err := s.DB.Select("id", "org_id").First(&role, "id = ?", roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &rolePermCacheEntry{Permissions: []string{}}, nil
+		}
+		return nil, err
+	}
+	perms := []string{}
+	if err := s.DB.Table("role_permissions").Where("role_id = ?", roleID).Order("permission_id").Pluck("permission_id", &perms).Error; err != nil {
+		return nil, err
+	}
+	return &rolePermCacheEntry{OrgID: role.OrgID, Permissions: perms}, nil
+}
+
+// RolePermissions 解析组织成员角色的有效权限：优先读 Redis 缓存，未命中时查库回填。
+// 其他组织的自定义角色在本组织内不授予任何权限；查询失败时返回空集合。
+func (s *KYCService) RolePermissions(ctx context.Context, orgID, roleID string) []string {
+	if roleID == "" {
+		return []string{}
+	}
+	var entry *rolePermCacheEntry
+	if s.Redis != nil {
+		if raw, err := s.Redis.Get(ctx, rolePermCacheKey(roleID)).Bytes(); err == nil {
+			var e rolePermCacheEntry
+			if json.Unmarshal(raw, &e) == nil {
+				entry = &e
+			}
+		}
+	}
+	if entry == nil {
+		e, err := s.loadRolePermissions(roleID)
+		if err != nil {
+			logger.GetLogger().WithError(err).Warn("加载角色权限失败")
+			return []string{}
+		}
+		entry = e
+		if s.Redis != nil {
+			if raw, err := json.Marshal(entry); err == nil {
+				_ = s.Redis.Set(context.WithoutCancel(ctx), rolePermCacheKey(roleID), raw, rolePermCacheTTL).Err()
+			}
+		}
+	}
+	if entry.OrgID != "" && entry.OrgID != orgID {
+		return []string{}
+	}
+	if entry.Permissions == nil {
+		return []string{}
+	}
+	return entry.Permissions
+}
+
+// InvalidateRolePermissions 角色或其权限变更后清除缓存
+func (s *KYCService) InvalidateRolePermissions(ctx context.Context, roleIDs ...string) {
+	if s.Redis == nil || len(roleIDs) == 0 {
+		return
+	}
+	keys := make([]string, len(roleIDs))
+	for i, id := range roleIDs {
+		keys[i] = rolePermCacheKey(id)
+	}
+	if err := s.Redis.Del(context.WithoutCancel(ctx), keys...).Err(); err != nil {
+		logger.GetLogger().WithError(err).Warn("清除角色权限缓存失败")
+	}
+}
+
+// InvalidateAllRolePermissions 权限目录变更（如删除权限）后清除全部角色缓存
+func (s *KYCService) InvalidateAllRolePermissions(ctx context.Context) {
+	if s.Redis == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	iter := s.Redis.Scan(ctx, 0, rolePermCachePrefix+"*", 200).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		logger.GetLogger().WithError(err).Warn("扫描角色权限缓存失败")
+	}
+	if len(keys) > 0 {
+		_ = s.Redis.Del(ctx, keys...).Err()
+	}
+}
+
+// orgRoleScope 本组织可见的角色：全局角色与本组织自定义角色
+func (s *KYCService) orgRoleScope(orgID string) *gorm.DB {
+	return s.DB.Model(&models.Role{}).Where("org_id = '' OR org_id IS NULL OR org_id = ?", orgID)
+}
+
+func (s *KYCService) roleDetails(orgID string, roles []models.Role) ([]RoleDetail, error) {
+	out := make([]RoleDetail, len(roles))
+	if len(roles) == 0 {
+		return out, nil
+	}
+	ids := make([]string, len(roles))
+	for i, r := range roles {
+		ids[i] = r.ID
+		out[i] = RoleDetail{Role: r, Permissions: []string{}}
+	}
+	var rps []models.RolePermission
+	if err := s.DB.Table("role_permissions").Where("role_id IN ?", ids).Order("permission_id").Find(&rps).Error; err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		Role string
+		N    int64
+	}
+	if err := s.DB.Model(&models.OrganizationMember{}).Select("role, COUNT(*) AS n").
+		Where("organization_id = ? AND role IN ?", orgID, ids).Group("role").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	idx := map[string]int{}
+	for i, id := range ids {
+		idx[id] = i
+	}
+	for _, rp := range rps {
+		out[idx[rp.RoleID]].Permissions = append(out[idx[rp.RoleID]].Permissions, rp.PermissionID)
+	}
+	for _, c := range counts {
+		out[idx[c.Role]].MemberCount = c.N
+	}
+	return out, nil
+}
+
+// ListOrgRoles 组织可用的角色（全局角色在前）及权限、成员数
+func (s *KYCService) ListOrgRoles(orgID string) ([]RoleDetail, error) {
+	var roles []models.Role
+	if err := s.orgRoleScope(orgID).Order("is_system DESC, created_at ASC").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return s.roleDetails(orgID, roles)
+}
+
+// GetOrgRole 组织可见的单个角色，不存在或属于其他组织时返回 ErrRoleNotFound
+func (s *KYCService) GetOrgRole(orgID, roleID string) (*RoleDetail, error) {
+	var role models.Role
+	if err := s.orgRoleScope(orgID).Where("id = ?", roleID).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	details, err := s.roleDetails(orgID, []models.Role{role})
+	if err != nil {
+		return nil, err
+	}
+	return &details[0], nil
+}
+
+// AssignableRole 可分配给本组织成员的角色：所有者以外的全局角色或本组织的自定义角色
+func (s *KYCService) AssignableRole(orgID, roleID string) (*RoleDetail, error) {
+	if roleID == "owner" {
+		return nil, ErrRoleNotAssignable
+	}
+	role, err := s.GetOrgRole(orgID, roleID)
+	if errors.Is(err, ErrRoleNotFound) {
+		return nil, ErrRoleNotAssignable
+	}
+	return role, err
+}
+
+// CheckGrantable 校验授予方能否授出这些权限（分配角色、编辑角色时只能授出自己拥有的权限）；
+// grantor 为授予方自己的权限，nil 表示不受限（组织所有者、平台管理员）。
+func CheckGrantable(grantor, perms []string) error {
+	if grantor == nil {
+		return nil
+	}
+	if bad := missingPermissions(grantor, perms); len(bad) > 0 {
+		return &PermissionError{Err: ErrPermissionNotGrantable, Permissions: bad}
+	}
+	return nil
+}
+
+// checkCustomRolePermissions 自定义角色的权限集合：所有者专属权限一律不可包含，其余须授予方可授出
+func checkCustomRolePermissions(grantor, perms []string) error {
+	if bad := ownerOnly(perms); len(bad) > 0 {
+		return &PermissionError{Err: ErrPermissionNotGrantable, Permissions: bad}
+	}
+	return CheckGrantable(grantor, perms)
+}
+
+// validatePermissionIDs 权限须存在于权限目录中
+func (s *KYCService) validatePermissionIDs(perms []string) error {
+	if len(perms) == 0 {
+		return nil
+	}
+	var known []string
+	if err := s.DB.Model(&models.Permission{}).Where("id IN ?", perms).Pluck("id", &known).Error; err != nil {
+		return err
+	}
+	if bad := missingPermissions(known, perms); len(bad) > 0 {
+		return &PermissionError{Err: ErrUnknownPermission, Permissions: bad}
+	}
+	return nil
+}
+
+func (s *KYCService) roleNameTaken(orgID, name, exceptID string) (bool, error) {
+	var n int64
+	err := s.orgRoleScope(orgID).Where("LOWER(name) = ? AND id <> ?", strings.ToLower(strings.TrimSpace(name)), exceptID).Count(&n).Error
+	return n > 0, err
+}
+
+// CreateOrgRole 以权限目录中的权限创建组织自定义角色
+func (s *KYCService) CreateOrgRole(ctx context.Context, orgID, createdBy string, in OrgRoleInput, grantor []string) (*RoleDetail, error) {
+	perms := normalizePermissionIDs(in.Permissions)
+	if err := checkCustomRolePermissions(grantor, perms); err != nil {
+		return nil, err
+	}
+	if err := s.validatePermissionIDs(perms); err != nil {
+		return nil, err
+	}
+	var n int64
+	if err := s.DB.Model(&models.Role{}).Where("org_id = ?", orgID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n >= MaxOrgCustomRoles {
+		return nil, ErrRoleLimitReached
+	}
+	if taken, err := s.roleNameTaken(orgID, in.Name, ""); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrRoleNameTaken
+	}
+	now := time.Now()
+	role := models.Role{
+		ID:          customRoleIDPrefix + utils.GenerateID(),
+		OrgID:       orgID,
+		Name:        strings.TrimSpace(in.Name),
+		Description: in.Description,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		for _, p := range perms {
+			if err := tx.Create(&models.RolePermission{RoleID: role.ID, PermissionID: p}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.InvalidateRolePermissions(ctx, role.ID)
+	return &RoleDetail{Role: role, Permissions: perms}, nil
+}
+
+// orgCustomRole 本组织的自定义角色；全局角色返回 ErrSystemRoleReadonly
+func (s *KYCService) orgCustomRole(orgID, roleID string) (*RoleDetail, error) {
+	role, err := s.GetOrgRole(orgID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if role.OrgID != orgID {
+		return nil, ErrSystemRoleReadonly
+	}
+	return role, nil
+}
+
+// UpdateOrgRole 修改组织自定义角色；授予方须同时拥有角色原有与新增的权限，避免借改角色提权或降级他人
+func (s *KYCService) UpdateOrgRole(ctx context.Context, orgID, roleID string, in OrgRoleInput, grantor []string) (*RoleDetail, error) {
+	role, err := s.orgCustomRole(orgID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckGrantable(grantor, role.Permissions); err != nil {
+		return nil, err
+	}
+	perms := role.Permissions
+	if in.Permissions != nil {
+		perms = normalizePermissionIDs(in.Permissions)
+		if err := checkCustomRolePermissions(grantor, perms); err != nil {
+			return nil, err
+		}
+		if err := s.validatePermissionIDs(perms); err != nil {
+			return nil, err
+		}
+	}
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if name := strings.TrimSpace(in.Name); name != "" && name != role.Name {
+		if taken, err := s.roleNameTaken(orgID, name, roleID); err != nil {
+			return nil, err
+		} else if taken {
+			return nil, ErrRoleNameTaken
+		}
+		updates["name"] = name
+	}
+	if in.Description != "" {
+		updates["description"] = in.Description
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Role{}).Where("id = ?", roleID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if in.Permissions == nil {
+			return nil
+		}
+		if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		for _, p := range perms {
+			if err := tx.Create(&models.RolePermission{RoleID: roleID, PermissionID: p}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.InvalidateRolePermissions(ctx, roleID)
+	return s.GetOrgRole(orgID, roleID)
+}
+
+// DeleteOrgRole 删除组织自定义角色；仍有成员或待处理邀请使用时返回 ErrRoleInUse
+func (s *KYCService) DeleteOrgRole(ctx context.Context, orgID, roleID string, grantor []string) error {
+	role, err := s.orgCustomRole(orgID, roleID)
+	if err != nil {
+		return err
+	}
+	if err := CheckGrantable(grantor, role.Permissions); err != nil {
+		return err
+	}
+	var pending, pendingLegacy int64
+	if err := s.DB.Model(&models.Invitation{}).Where("org_id = ? AND role = ? AND status = ?", orgID, roleID, "pending").Count(&pending).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Model(&models.OrganizationInvitation{}).Where("org_id = ? AND role = ? AND status = ?", orgID, roleID, "pending").Count(&pendingLegacy).Error; err != nil {
+		return err
+	}
+	if role.MemberCount > 0 || pending+pendingLegacy > 0 {
+		return ErrRoleInUse
+	}
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ? AND org_id = ?", roleID, orgID).Delete(&models.Role{}).Error
+	}); err != nil {
+		return err
+	}
+	s.InvalidateRolePermissions(ctx, roleID)
+	return nil
+}
+
+// SimulateRole 以某角色身份对照权限目录，预览其授予与未授予的权限
+func (s *KYCService) SimulateRole(ctx context.Context, orgID, roleID string) (*RoleSimulation, error) {
+	role, err := s.GetOrgRole(orgID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	var catalog []models.Permission
+	if err := s.DB.Order("category ASC, id ASC").Find(&catalog).Error; err != nil {
+		return nil, err
+	}
+	granted := map[string]struct{}{}
+	for _, p := range s.RolePermissions(ctx, orgID, roleID) {
+		granted[p] = struct{}{}
+	}
+	sim := &RoleSimulation{Role: *role, Permissions: make([]SimulatedPermission, len(catalog)), Total: len(catalog)}
+	for i, p := range catalog {
+		_, ok := granted[p.ID]
+		sim.Permissions[i] = SimulatedPermission{Permission: p, Granted: ok}
+		if ok {
+			sim.Granted++
+		}
+	}
+	return sim, nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePermissionIDs(t *testing.T) {
+	assert.Equal(t, []string{"keys.read", "logs.read"}, normalizePermissionIDs([]string{" logs.read", "keys.read", "", "logs.read"}))
+	assert.Equal(t, []string{}, normalizePermissionIDs(nil))
+}
+
+func TestCheckGrantable(t *testing.T) {
+	// 所有者/平台管理员不受限
+	assert.NoError(t, CheckGrantable(nil, []string{"org.update", "team.write"}))
+	assert.NoError(t, CheckGrantable([]string{"*"}, []string{"org.update"}))
+	assert.NoError(t, CheckGrantable([]string{"keys.read", "keys.write"}, []string{"keys.read"}))
+
+	err := CheckGrantable([]string{"keys.read"}, []string{"keys.read", "keys.write", "team.write"})
+	var pe *PermissionError
+	require.True(t, errors.As(err, &pe))
+	assert.ErrorIs(t, err, ErrPermissionNotGrantable)
+	assert.Equal(t, []string{"keys.write", "team.write"}, pe.Permissions)
+
+	// 空权限集合可授予给任何人，但操作者没有任何权限时不能授出权限
+	assert.NoError(t, CheckGrantable([]string{}, nil))
+	assert.Error(t, CheckGrantable([]string{}, []string{"org.read"}))
+}
+
+func TestCheckCustomRolePermissions(t *testing.T) {
+	// 所有者专属权限即使由所有者操作也不可放入自定义角色
+	err := checkCustomRolePermissions(nil, []string{"billing.read", "billing.write", "org.delete"})
+	var pe *PermissionError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, []string{"billing.write", "org.delete"}, pe.Permissions)
+
+	assert.NoError(t, checkCustomRolePermissions(nil, []string{"org.update", "keys.write"}))
+	assert.Error(t, checkCustomRolePermissions([]string{"keys.read"}, []string{"keys.write"}))
+}
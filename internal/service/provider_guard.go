@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"kyc-service/internal/config"
+	"kyc-service/pkg/breaker"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/metrics"
+)
+
+// providerGuard 为每个供应商维护独立的熔断器与并发舱壁
+type providerGuard struct {
+	cfg      config.CircuitBreakerConfig
+	mu       sync.RWMutex
+	breakers map[string]*breaker.Breaker
+}
+
+func newProviderGuard(cfg config.CircuitBreakerConfig) *providerGuard {
+	return &providerGuard{cfg: cfg, breakers: map[string]*breaker.Breaker{}}
+}
+
+func providerKey(capability, name string) string { return capability + "/" + name }
+
+// add 登记供应商熔断器；maxConcurrent > 0 时覆盖全局并发上限
+func (g *providerGuard) add(capability, name string, maxConcurrent int) bool {
+	key := providerKey(capability, name)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.breakers[key]; ok {
+		return false
+	}
+	bc := breaker.Config{
+		FailureThreshold: g.cfg.FailureThreshold,
+		OpenTimeout:      g.cfg.OpenTimeout,
+		HalfOpenMaxCalls: g.cfg.HalfOpenMaxCalls,
+		MaxConcurrent:    g.cfg.MaxConcurrent,
+	}
+	if maxConcurrent > 0 {
+		bc.MaxConcurrent = maxConcurrent
+	}
+	g.breakers[key] = breaker.New(key, bc, func(name string, from, to breaker.State) {
+		metrics.SetCircuitBreakerState(context.Background(), name, int(to))
+		logger.GetLogger().Warnf("供应商熔断器 %s 状态变更: %s -> %s", name, from, to)
+	})
+	metrics.SetCircuitBreakerState(context.Background(), key, int(breaker.StateClosed))
+	return true
+}
+
+func (g *providerGuard) get(capability, name string) *breaker.Breaker {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.breakers[providerKey(capability, name)]
+}
+
+func (g *providerGuard) Allow(capability, name string) error {
+	b := g.get(capability, name)
+	if b == nil {
+		return nil
+	}
+	if err := b.Allow(); err != nil {
+		reason := "open"
+		if errors.Is(err, breaker.ErrTooManyRequests) {
+			reason = "bulkhead"
+		}
+		metrics.RecordCircuitBreakerRejection(context.Background(), b.Name(), reason)
+		return fmt.Errorf("%w: %s: %w", ErrUpstreamUnavailable, b.Name(), err)
+	}
+	return nil
+}
+
+func (g *providerGuard) Done(capability, name string, err error) {
+	b := g.get(capability, name)
+	if b == nil {
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		b.Cancel()
+		return
+	}
+	b.Done(err == nil)
+}
+
+// Snapshots 所有供应商熔断器状态（按名称排序）
+func (g *providerGuard) Snapshots() []breaker.Snapshot {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	out := make([]breaker.Snapshot, 0, len(g.breakers))
+	for _, b := range g.breakers {
+		out = append(out, b.Snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// ProviderHealth 第三方供应商健康信息；某能力的供应商全部熔断时视为不健康
+func (s *KYCService) ProviderHealth() (interface{}, bool) {
+	if s.providerGuard == nil {
+		return nil, true
+	}
+	snaps := s.providerGuard.Snapshots()
+	available := map[string]bool{}
+	for _, snap := range snaps {
+		capability, _, _ := strings.Cut(snap.Name, "/")
+		available[capability] = available[capability] || snap.State != breaker.StateOpen.String()
+	}
+	healthy := true
+	for _, ok := range available {
+		healthy = healthy && ok
+	}
+	return snaps, healthy
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"kyc-service/internal/config"
+	"kyc-service/pkg/httpclient"
+	"kyc-service/pkg/logger"
+)
+
+const providerTypeVRL = "vrl"
+
+// vrlOCRProvider 现有 vrlOCR HTTP 接口
+type vrlOCRProvider struct {
+	name     string
+	cfg      config.ProviderConfig
+	docTypes map[string]bool
+	svc      *KYCService
+	client   *httpclient.Client
+}
+
+func (p *vrlOCRProvider) Name() string { return p.name }
+
+func (p *vrlOCRProvider) SupportsDocType(docType string) bool {
+	return len(p.docTypes) == 0 || docType == "" || p.docTypes[docType]
+}
+
+func (p *vrlOCRProvider) Recognize(ctx context.Context, image []byte, filename, docType, language string) (*OCRResponse, error) {
+	return p.svc.callOCRHTTP(ctx, p.client, p.cfg.URL, bytes.NewReader(image), filename, docType, language)
+}
+
+// vrlFaceProvider 现有 vrlFace* HTTP 接口
+type vrlFaceProvider struct {
+	name string
+	tp   *ThirdPartyService
+}
+
+func (p *vrlFaceProvider) Name() string { return p.name }
+
+func (p *vrlFaceProvider) Search(ctx context.Context, image []byte, filename string) (*FaceSearchResponse, error) {
+	return vendorAnswer(p.tp.CallFaceSearch(ctx, bytes.NewReader(image), filename))
+}
+
+func (p *vrlFaceProvider) Detect(ctx context.Context, image []byte, filename string) (*FaceDetectResponse, error) {
+	return vendorAnswer(p.tp.CallFaceDetect(ctx, bytes.NewReader(image), filename))
+}
+
+func (p *vrlFaceProvider) Compare(ctx context.Context, src []byte, srcName string, dst []byte, dstName string) (*FaceCompareResponse, error) {
+	return vendorAnswer(p.tp.CallFaceCompare(ctx, bytes.NewReader(src), srcName, bytes.NewReader(dst), dstName))
+}
+
+// vrlLivenessProvider 现有 vrlSilentLiveness / vrlMoveLiveness HTTP 接口
+type vrlLivenessProvider struct {
+	name string
+	tp   *ThirdPartyService
+}
+
+func (p *vrlLivenessProvider) Name() string { return p.name }
+
+func (p *vrlLivenessProvider) Silent(ctx context.Context, picturePath, language string) (*LivenessSilentResponse, error) {
+	return vendorAnswer(p.tp.CallLivenessSilent(ctx, picturePath, language))
+}
+
+func (p *vrlLivenessProvider) Video(ctx context.Context, videoPath, language, action string) (*LivenessVideoResponse, error) {
+	return vendorAnswer(p.tp.CallLivenessVideo(ctx, videoPath, language, action))
+}
+
+// vendorAnswer 业务拒绝属于供应商的有效结论，交由调用方按 code 处理，不触发切换
+func vendorAnswer[R any](res *R, err error) (*R, error) {
+	if err != nil && res != nil && errors.Is(err, ErrVendorRejected) {
+		return res, nil
+	}
+	return res, err
+}
+
+// providerThirdParty 以供应商配置覆盖对应能力的地址与密钥，并使用该供应商独享的 HTTP 客户端
+func providerThirdParty(cfg *config.Config, capability string, pc config.ProviderConfig) *ThirdPartyService {
+	c := *cfg
+	switch capability {
+	case CapabilityFace:
+		c.ThirdParty.FaceService.URL = pc.URL
+		c.ThirdParty.FaceService.APIKey = pc.APIKey
+		c.ThirdParty.FaceService.Timeout = pc.Timeout
+		c.ThirdParty.FaceService.RetryCount = pc.RetryCount
+	case CapabilityLiveness:
+		c.ThirdParty.LivenessSlient.URL = pc.URL
+		c.ThirdParty.LivenessSlient.APIKey = pc.APIKey
+		c.ThirdParty.LivenessSlient.Timeout = pc.Timeout
+		c.ThirdParty.LivenessSlient.RetryCount = pc.RetryCount
+		c.ThirdParty.LivenessVideo.URL = pc.VideoURL
+		c.ThirdParty.LivenessVideo.APIKey = pc.APIKey
+		c.ThirdParty.LivenessVideo.Timeout = pc.Timeout
+		c.ThirdParty.LivenessVideo.RetryCount = pc.RetryCount
+	}
+	tp := NewThirdPartyService(&c)
+	tp.client = providerHTTPClient(pc.Timeout)
+	return tp
+}
+
+// providerHTTPClient 供应商独享的 HTTP 客户端（复用连接），timeout 单位为秒，未配置时为30秒
+func providerHTTPClient(timeout int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 32
+	return &http.Client{Timeout: providerTimeout(timeout), Transport: transport}
+}
+
+func providerTimeout(timeout int) time.Duration {
+	if timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// defaultProviderConfigs 未配置 third_party.providers 时，由原单一服务配置生成默认供应商
+func defaultProviderConfigs(tp config.ThirdPartyConfig) config.ProvidersConfig {
+	out := tp.Providers
+	if len(out.OCR) == 0 {
+		out.OCR = []config.ProviderConfig{{Name: "default", URL: tp.OCRService.URL, APIKey: tp.OCRService.APIKey,
+			Timeout: tp.OCRService.Timeout, RetryCount: tp.OCRService.RetryCount}}
+	}
+	if len(out.Face) == 0 {
+		out.Face = []config.ProviderConfig{{Name: "default", URL: tp.FaceService.URL, APIKey: tp.FaceService.APIKey,
+			Timeout: tp.FaceService.Timeout, RetryCount: tp.FaceService.RetryCount}}
+	}
+	if len(out.Liveness) == 0 {
+		out.Liveness = []config.ProviderConfig{{Name: "default", URL: tp.LivenessSlient.URL, VideoURL: tp.LivenessVideo.URL,
+			APIKey: tp.LivenessSlient.APIKey, Timeout: max(tp.LivenessSlient.Timeout, tp.LivenessVideo.Timeout),
+			RetryCount: tp.LivenessSlient.RetryCount}}
+	}
+	return out
+}
+
+// newProviderRegistry 根据 third_party.providers 构建供应商路由，并为每个供应商挂载熔断器
+func (s *KYCService) newProviderRegistry() *ProviderRegistry {
+	reg := NewProviderRegistry()
+	guard := newProviderGuard(s.Config.ThirdParty.CircuitBreaker)
+	reg.SetGuard(guard)
+	s.providerGuard = guard
+	log := logger.GetLogger()
+
+	usable := func(capability string, pc config.ProviderConfig) bool {
+		if pc.Disabled {
+			return false
+		}
+		if pc.Type != "" && pc.Type != providerTypeVRL {
+			log.Warnf("忽略不支持的%s供应商类型: %s (%s)", capability, pc.Type, pc.Name)
+			return false
+		}
+		if !guard.add(capability, pc.Name, pc.MaxConcurrent) {
+			log.Warnf("忽略重复的%s供应商: %s", capability, pc.Name)
+			return false
+		}
+		return true
+	}
+
+	providers := defaultProviderConfigs(s.Config.ThirdParty)
+	for _, pc := range providers.OCR {
+		if !usable(CapabilityOCR, pc) {
+			continue
+		}
+		p := &vrlOCRProvider{name: pc.Name, cfg: pc, svc: s, docTypes: map[string]bool{}, client: httpclient.New(httpclient.Config{
+			Timeout:       providerTimeout(pc.Timeout),
+			RetryCount:    pc.RetryCount,
+			RetryInterval: 3 * time.Second,
+			Logger:        log,
+		})}
+		for _, t := range pc.DocTypes {
+			p.docTypes[strings.TrimSpace(t)] = true
+		}
+		reg.RegisterOCR(p, pc.Priority, pc.Weight)
+	}
+	for _, pc := range providers.Face {
+		if usable(CapabilityFace, pc) {
+			reg.RegisterFace(&vrlFaceProvider{name: pc.Name, tp: providerThirdParty(s.Config, CapabilityFace, pc)}, pc.Priority, pc.Weight)
+		}
+	}
+	for _, pc := range providers.Liveness {
+		if usable(CapabilityLiveness, pc) {
+			reg.RegisterLiveness(&vrlLivenessProvider{name: pc.Name, tp: providerThirdParty(s.Config, CapabilityLiveness, pc)}, pc.Priority, pc.Weight)
+		}
+	}
+	return reg
+}
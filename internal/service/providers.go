@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/metrics"
+)
+
+// 供应商能力
+const (
+	CapabilityOCR      = "ocr"
+	CapabilityFace     = "face"
+	CapabilityLiveness = "liveness"
+)
+
+// 供应商接口约定：供应商已给出结论（包括 code != 0 的业务失败）时返回响应且 error 为 nil；
+// 只有网络错误、超时、5xx 等"供应商不可用"的情况才返回 error，路由层据此切换到下一个供应商。
+// 图片以 []byte 传入，便于切换时重复发送。
+
+// OCRProvider 证件OCR供应商
+type OCRProvider interface {
+	Name() string
+	SupportsDocType(docType string) bool
+	Recognize(ctx context.Context, image []byte, filename, docType, language string) (*OCRResponse, error)
+}
+
+// FaceProvider 人脸检测/比对/搜索供应商
+type FaceProvider interface {
+	Name() string
+	Search(ctx context.Context, image []byte, filename string) (*FaceSearchResponse, error)
+	Detect(ctx context.Context, image []byte, filename string) (*FaceDetectResponse, error)
+	Compare(ctx context.Context, src []byte, srcName string, dst []byte, dstName string) (*FaceCompareResponse, error)
+}
+
+// LivenessProvider 静默/动作活体供应商（按已落盘文件路径调用）
+type LivenessProvider interface {
+	Name() string
+	Silent(ctx context.Context, picturePath, language string) (*LivenessSilentResponse, error)
+	// Video 的 action 为要求用户完成的动作，供应商须在 action_results 中确认；为空表示只做活体判断
+	Video(ctx context.Context, videoPath, language, action string) (*LivenessVideoResponse, error)
+}
+
+// ProviderGuard 调用供应商前后的保护钩子（熔断等）；Allow 返回错误时跳过该供应商
+type ProviderGuard interface {
+	Allow(capability, name string) error
+	Done(capability, name string, err error)
+}
+
+type routedProvider[P any] struct {
+	name     string
+	priority int
+	weight   int
+	provider P
+}
+
+// ProviderRegistry 按能力登记供应商，并按优先级/权重路由、失败切换
+type ProviderRegistry struct {
+	mu       sync.RWMutex
+	ocr      []routedProvider[OCRProvider]
+	face     []routedProvider[FaceProvider]
+	liveness []routedProvider[LivenessProvider]
+	guard    ProviderGuard
+	intn     func(n int) int
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{intn: rand.Intn}
+}
+
+// SetGuard 设置保护钩子
+func (r *ProviderRegistry) SetGuard(g ProviderGuard) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.guard = g
+}
+
+func (r *ProviderRegistry) RegisterOCR(p OCRProvider, priority, weight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ocr = append(r.ocr, routedProvider[OCRProvider]{name: p.Name(), priority: priority, weight: weight, provider: p})
+}
+
+func (r *ProviderRegistry) RegisterFace(p FaceProvider, priority, weight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.face = append(r.face, routedProvider[FaceProvider]{name: p.Name(), priority: priority, weight: weight, provider: p})
+}
+
+func (r *ProviderRegistry) RegisterLiveness(p LivenessProvider, priority, weight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.liveness = append(r.liveness, routedProvider[LivenessProvider]{name: p.Name(), priority: priority, weight: weight, provider: p})
+}
+
+// ProviderNames 返回各能力已登记的供应商名称
+func (r *ProviderRegistry) ProviderNames() map[string][]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := map[string][]string{}
+	for _, p := range r.ocr {
+		out[CapabilityOCR] = append(out[CapabilityOCR], p.name)
+	}
+	for _, p := range r.face {
+		out[CapabilityFace] = append(out[CapabilityFace], p.name)
+	}
+	for _, p := range r.liveness {
+		out[CapabilityLiveness] = append(out[CapabilityLiveness], p.name)
+	}
+	return out
+}
+
+// routeOrder 按优先级升序分组，组内按权重随机排序
+func routeOrder[P any](list []routedProvider[P], intn func(n int) int) []routedProvider[P] {
+	sorted := append([]routedProvider[P](nil), list...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].priority < sorted[j].priority })
+	out := make([]routedProvider[P], 0, len(sorted))
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j].priority == sorted[i].priority {
+			j++
+		}
+		group := append([]routedProvider[P](nil), sorted[i:j]...)
+		for len(group) > 0 {
+			total := 0
+			for _, p := range group {
+				total += max(p.weight, 1)
+			}
+			pick, n := 0, intn(total)
+			for k, p := range group {
+				if n -= max(p.weight, 1); n < 0 {
+					pick = k
+					break
+				}
+			}
+			out = append(out, group[pick])
+			group = append(group[:pick], group[pick+1:]...)
+		}
+		i = j
+	}
+	return out
+}
+
+// callWithFailover 按路由顺序调用供应商，不可用时切换到下一个
+func callWithFailover[P any, R any](ctx context.Context, r *ProviderRegistry, capability, op string, list []routedProvider[P], call func(P) (R, error)) (R, error) {
+	var zero R
+	r.mu.RLock()
+	guard, intn := r.guard, r.intn
+	r.mu.RUnlock()
+	if len(list) == 0 {
+		return zero, fmt.Errorf("%w: no %s provider configured", ErrUpstreamUnavailable, capability)
+	}
+
+	var lastErr error
+	for _, p := range routeOrder(list, intn) {
+		if guard != nil {
+			if err := guard.Allow(capability, p.name); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		res, err := call(p.provider)
+		if guard != nil {
+			guard.Done(capability, p.name, err)
+		}
+		if err == nil {
+			return res, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return zero, err
+		}
+		metrics.RecordThirdPartyError(ctx, p.name, capability+"_"+op, "failover")
+		logger.GetLogger().WithError(err).Warnf("供应商 %s/%s %s 调用失败，尝试下一个", capability, p.name, op)
+	}
+	if errors.Is(lastErr, ErrUpstreamUnavailable) || errors.Is(lastErr, ErrUpstreamTimeout) {
+		return zero, lastErr
+	}
+	return zero, fmt.Errorf("%w: %v", ErrUpstreamUnavailable, lastErr)
+}
+
+func (r *ProviderRegistry) snapshotOCR(docType string) []routedProvider[OCRProvider] {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []routedProvider[OCRProvider]
+	for _, p := range r.ocr {
+		if p.provider.SupportsDocType(docType) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (r *ProviderRegistry) snapshotFace() []routedProvider[FaceProvider] {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]routedProvider[FaceProvider](nil), r.face...)
+}
+
+func (r *ProviderRegistry) snapshotLiveness() []routedProvider[LivenessProvider] {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]routedProvider[LivenessProvider](nil), r.liveness...)
+}
+
+// RecognizeOCR 在支持该证件类型的供应商间路由OCR请求
+func (r *ProviderRegistry) RecognizeOCR(ctx context.Context, image []byte, filename, docType, language string) (*OCRResponse, error) {
+	return callWithFailover(ctx, r, CapabilityOCR, "recognize", r.snapshotOCR(docType), func(p OCRProvider) (*OCRResponse, error) {
+		return p.Recognize(ctx, image, filename, docType, language)
+	})
+}
+
+func (r *ProviderRegistry) FaceSearch(ctx context.Context, image []byte, filename string) (*FaceSearchResponse, error) {
+	return callWithFailover(ctx, r, CapabilityFace, "search", r.snapshotFace(), func(p FaceProvider) (*FaceSearchResponse, error) {
+		return p.Search(ctx, image, filename)
+	})
+}
+
+func (r *ProviderRegistry) FaceDetect(ctx context.Context, image []byte, filename string) (*FaceDetectResponse, error) {
+	return callWithFailover(ctx, r, CapabilityFace, "detect", r.snapshotFace(), func(p FaceProvider) (*FaceDetectResponse, error) {
+		return p.Detect(ctx, image, filename)
+	})
+}
+
+func (r *ProviderRegistry) FaceCompare(ctx context.Context, src []byte, srcName string, dst []byte, dstName string) (*FaceCompareResponse, error) {
+	return callWithFailover(ctx, r, CapabilityFace, "compare", r.snapshotFace(), func(p FaceProvider) (*FaceCompareResponse, error) {
+		return p.Compare(ctx, src, srcName, dst, dstName)
+	})
+}
+
+func (r *ProviderRegistry) LivenessSilent(ctx context.Context, picturePath, language string) (*LivenessSilentResponse, error) {
+	return callWithFailover(ctx, r, CapabilityLiveness, "silent", r.snapshotLiveness(), func(p LivenessProvider) (*LivenessSilentResponse, error) {
+		return p.Silent(ctx, picturePath, language)
+	})
+}
+
+func (r *ProviderRegistry) LivenessVideo(ctx context.Context, videoPath, language, action string) (*LivenessVideoResponse, error) {
+	return callWithFailover(ctx, r, CapabilityLiveness, "video", r.snapshotLiveness(), func(p LivenessProvider) (*LivenessVideoResponse, error) {
+		return p.Video(ctx, videoPath, language, action)
+	})
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOCRProvider struct {
+	name     string
+	docTypes []string
+	err      error
+	calls    int
+}
+
+func (p *fakeOCRProvider) Name() string { return p.name }
+
+func (p *fakeOCRProvider) SupportsDocType(docType string) bool {
+	if len(p.docTypes) == 0 {
+		return true
+	}
+	for _, t := range p.docTypes {
+		if t == docType {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *fakeOCRProvider) Recognize(ctx context.Context, image []byte, filename, docType, language string) (*OCRResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &OCRResponse{Msg: p.name}, nil
+}
+
+func TestProviderFailover(t *testing.T) {
+	primary := &fakeOCRProvider{name: "primary", err: ErrUpstreamTimeout}
+	backup := &fakeOCRProvider{name: "backup"}
+	reg := NewProviderRegistry()
+	reg.RegisterOCR(backup, 2, 1)
+	reg.RegisterOCR(primary, 1, 1)
+
+	res, err := reg.RecognizeOCR(context.Background(), []byte("img"), "a.jpg", "id_card", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "backup", res.Msg)
+	assert.Equal(t, 1, primary.calls)
+
+	backup.err = errors.New("connection refused")
+	_, err = reg.RecognizeOCR(context.Background(), []byte("img"), "a.jpg", "id_card", "")
+	assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+}
+
+func TestProviderDocTypeRouting(t *testing.T) {
+	general := &fakeOCRProvider{name: "general"}
+	npwp := &fakeOCRProvider{name: "npwp", docTypes: []string{"NPWP"}}
+	reg := NewProviderRegistry()
+	reg.RegisterOCR(npwp, 1, 1)
+	reg.RegisterOCR(general, 2, 1)
+
+	res, _ := reg.RecognizeOCR(context.Background(), nil, "a.jpg", "NPWP", "")
+	assert.Equal(t, "npwp", res.Msg)
+	res, _ = reg.RecognizeOCR(context.Background(), nil, "a.jpg", "id_card", "")
+	assert.Equal(t, "general", res.Msg)
+}
+
+func TestRouteOrderWeighted(t *testing.T) {
+	list := []routedProvider[string]{
+		{name: "a", priority: 1, weight: 1},
+		{name: "b", priority: 1, weight: 3},
+		{name: "c", priority: 0, weight: 1},
+	}
+	// intn 恒返回 n-1：总是落在最后一个权重区间
+	order := routeOrder(list, func(n int) int { return n - 1 })
+	var names []string
+	for _, p := range order {
+		names = append(names, p.name)
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, names)
+}
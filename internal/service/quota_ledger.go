@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/metrics"
+	"kyc-service/pkg/utils"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 配额流水类型
+const (
+	QuotaEntryReserve = "reserve"
+	QuotaEntryCommit  = "commit"
+	QuotaEntryRefund  = "refund"
+	QuotaEntryAdjust  = "adjust"
+)
+
+// quotaStaleReservationAge 超过该时长仍未确认或退还的预占视为悬挂
+const quotaStaleReservationAge = 30 * time.Minute
+
+// appendQuotaAdjustment 记录重置、套餐同步等直接修改 consumed 产生的差额
+func appendQuotaAdjustment(tx *gorm.DB, orgID, serviceType string, delta int, reason string) error {
+	if delta == 0 {
+		return nil
+	}
+	id := utils.GenerateID()
+	return tx.Create(&models.QuotaLedgerEntry{
+		ID: id, ReservationID: id, EntryType: QuotaEntryAdjust,
+		OrganizationID: orgID, ServiceType: serviceType, Amount: delta, Reason: reason,
+	}).Error
+}
+
+// QuotaDrift 单个组织/服务的对账结果
+type QuotaDrift struct {
+	OrganizationID    string `json:"organization_id"`
+	ServiceType       string `json:"service_type"`
+	Consumed          int    `json:"consumed"`                 // organization_quotas.consumed
+	LedgerBalance     int    `json:"ledger_balance"`           // 流水 amount 合计
+	RedisConsumed     *int   `json:"redis_consumed,omitempty"` // Redis 计数器（缓存存在时）
+	StaleReservations int    `json:"stale_reservations"`       // 悬挂的预占数
+}
+
+// Drifted 是否存在任一偏差
+func (d QuotaDrift) Drifted() bool {
+	return d.Consumed != d.LedgerBalance || d.StaleReservations > 0 || (d.RedisConsumed != nil && *d.RedisConsumed != d.Consumed)
+}
+
+// ReconcileQuotas 比对 Redis 计数器、organization_quotas.consumed 与配额流水，返回存在偏差的记录。
+// 尚无任何流水的配额会先补一条期初 adjust；Redis 计数器偏差时删除缓存，下次请求从数据库重新加载。
+func (s *KYCService) ReconcileQuotas(ctx context.Context) ([]QuotaDrift, error) {
+	type quotaRow struct {
+		OrganizationID string
+		ServiceType    string
+		Consumed       int
+		LedgerBalance  *int
+	}
+	var rows []quotaRow
+	if err := s.DB.WithContext(ctx).Raw(`
+		SELECT q.organization_id, q.service_type, q.consumed, l.balance AS ledger_balance
+		FROM organization_quotas q
+		LEFT JOIN (
+			SELECT organization_id, service_type, SUM(amount) AS balance
+			FROM quota_ledger_entries GROUP BY organization_id, service_type
+		) l ON l.organization_id = q.organization_id AND l.service_type = q.service_type`).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询配额对账数据失败: %w", err)
+	}
+
+	type staleRow struct {
+		OrganizationID string
+		ServiceType    string
+		Count          int
+	}
+	var stale []staleRow
+	if err := s.DB.WithContext(ctx).Raw(`
+		SELECT r.organization_id, r.service_type, COUNT(*) AS count
+		FROM quota_ledger_entries r
+		WHERE r.entry_type = ? AND r.created_at < ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM quota_ledger_entries e
+			WHERE e.reservation_id = r.reservation_id AND e.entry_type IN (?, ?))
+		GROUP BY r.organization_id, r.service_type`,
+		QuotaEntryReserve, time.Now().Add(-quotaStaleReservationAge), QuotaEntryCommit, QuotaEntryRefund).Scan(&stale).Error; err != nil {
+		return nil, fmt.Errorf("查询悬挂预占失败: %w", err)
+	}
+	staleByKey := map[string]int{}
+	for _, r := range stale {
+		staleByKey[r.OrganizationID+":"+r.ServiceType] = r.Count
+	}
+
+	log := logger.GetLogger()
+	var drifts []QuotaDrift
+	for _, row := range rows {
+		d := QuotaDrift{OrganizationID: row.OrganizationID, ServiceType: row.ServiceType, Consumed: row.Consumed}
+		if row.LedgerBalance != nil {
+			d.LedgerBalance = *row.LedgerBalance
+		} else {
+			// 流水上线前已有的用量：补期初余额
+			if err := appendQuotaAdjustment(s.DB.WithContext(ctx), d.OrganizationID, d.ServiceType, d.Consumed, "opening_balance"); err != nil {
+				log.WithError(err).Warnf("写入期初配额流水失败: %s/%s", d.OrganizationID, d.ServiceType)
+				continue
+			}
+			d.LedgerBalance = d.Consumed
+		}
+		d.StaleReservations = staleByKey[d.OrganizationID+":"+d.ServiceType]
+		if s.Redis != nil {
+			v, err := s.Redis.Get(ctx, quotaConsumedKey(d.OrganizationID, d.ServiceType)).Result()
+			if err == nil {
+				if n, perr := strconv.Atoi(v); perr == nil {
+					d.RedisConsumed = &n
+				}
+			} else if err != redis.Nil {
+				log.WithError(err).Warn("读取Redis配额计数失败")
+			}
+		}
+
+		metrics.SetQuotaDrift(ctx, d.OrganizationID, d.ServiceType, "ledger", d.Consumed-d.LedgerBalance)
+		metrics.SetQuotaDrift(ctx, d.OrganizationID, d.ServiceType, "stale_reservations", d.StaleReservations)
+		redisDrift := 0
+		if d.RedisConsumed != nil {
+			redisDrift = *d.RedisConsumed - d.Consumed
+		}
+		metrics.SetQuotaDrift(ctx, d.OrganizationID, d.ServiceType, "redis", redisDrift)
+		if !d.Drifted() {
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"org_id":             d.OrganizationID,
+			"service_type":       d.ServiceType,
+			"consumed":           d.Consumed,
+			"ledger_balance":     d.LedgerBalance,
+			"redis_consumed":     d.RedisConsumed,
+			"stale_reservations": d.StaleReservations,
+		}).Error("配额对账发现偏差")
+		if redisDrift != 0 {
+			_ = s.Redis.Del(ctx, quotaConsumedKey(d.OrganizationID, d.ServiceType)).Err()
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, nil
+}
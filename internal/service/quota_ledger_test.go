@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaDriftDetection(t *testing.T) {
+	n := func(v int) *int { return &v }
+	tests := []struct {
+		name    string
+		d       QuotaDrift
+		drifted bool
+	}{
+		{"一致", QuotaDrift{Consumed: 3, LedgerBalance: 3, RedisConsumed: n(3)}, false},
+		{"无Redis缓存", QuotaDrift{Consumed: 3, LedgerBalance: 3}, false},
+		{"流水偏差", QuotaDrift{Consumed: 4, LedgerBalance: 3}, true},
+		{"Redis偏差", QuotaDrift{Consumed: 3, LedgerBalance: 3, RedisConsumed: n(5)}, true},
+		{"悬挂预占", QuotaDrift{Consumed: 3, LedgerBalance: 3, StaleReservations: 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.drifted, tt.d.Drifted())
+		})
+	}
+}
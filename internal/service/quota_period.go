@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/metrics"
+	"kyc-service/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// 配额计费周期
+const (
+	QuotaPeriodDaily    = "daily"
+	QuotaPeriodWeekly   = "weekly"
+	QuotaPeriodMonthly  = "monthly"
+	QuotaPeriodAnnual   = "annual"
+	QuotaPeriodLifetime = "lifetime" // 不重置
+)
+
+// QuotaPlanEntry 套餐 quota_config 中单个服务的配置
+type QuotaPlanEntry struct {
+	Limit       int    `json:"limit"`
+	Period      string `json:"period"`
+	Rollover    bool   `json:"rollover"`     // 未用完的额度是否结转到下一周期
+	RolloverCap int    `json:"rollover_cap"` // 结转上限，<=0 时以 limit 为上限
+	Overage     bool   `json:"overage"`      // 超出额度后继续服务，超出部分按量计费
+	HardLimit   int    `json:"hard_limit"`   // 新组织的默认硬上限（当期总用量），组织可自行调整；<=0 表示不限
+}
+
+// NormalizeQuotaPeriod 统一周期写法，未知取值返回错误
+func NormalizeQuotaPeriod(p string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(p)) {
+	case "", QuotaPeriodLifetime:
+		return QuotaPeriodLifetime, nil
+	case QuotaPeriodDaily:
+		return QuotaPeriodDaily, nil
+	case QuotaPeriodWeekly:
+		return QuotaPeriodWeekly, nil
+	case QuotaPeriodMonthly:
+		return QuotaPeriodMonthly, nil
+	case QuotaPeriodAnnual, "yearly":
+		return QuotaPeriodAnnual, nil
+	}
+	return "", fmt.Errorf("unsupported quota period: %s", p)
+}
+
+// ParseQuotaConfig 解析套餐 quota_config；不认识的周期按 lifetime 处理
+func ParseQuotaConfig(raw string) (map[string]QuotaPlanEntry, error) {
+	out := map[string]QuotaPlanEntry{}
+	if strings.TrimSpace(raw) == "" {
+		return out, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return nil, fmt.Errorf("invalid quota_config: %w", err)
+	}
+	for svc, e := range out {
+		p, err := NormalizeQuotaPeriod(e.Period)
+		if err != nil {
+			logger.GetLogger().Warnf("套餐服务 %s 的配额周期 %q 无效，按 lifetime 处理", svc, e.Period)
+		}
+		if p == "" {
+			p = QuotaPeriodLifetime
+		}
+		e.Period = p
+		out[svc] = e
+	}
+	return out, nil
+}
+
+// rolloverAmount 周期结束时结转到下一周期的额度
+func (e QuotaPlanEntry) rolloverAmount(allocation, consumed int) int {
+	if !e.Rollover {
+		return 0
+	}
+	unused := allocation - consumed
+	if unused <= 0 {
+		return 0
+	}
+	limit := e.RolloverCap
+	if limit <= 0 {
+		limit = e.Limit
+	}
+	return min(unused, limit)
+}
+
+// OrgLocation 组织时区，未设置或无效时使用服务器本地时区
+func OrgLocation(tz string) *time.Location {
+	if tz == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// QuotaPeriodBounds 返回 t 所在计费周期的起止时间。周期以 anchor（组织注册时间）在组织时区下的日期为锚点，
+// 从当天0点起算；按月/年时锚点日不存在（如31日、2月29日）取当月最后一天。lifetime 返回 ok=false。
+func QuotaPeriodBounds(period string, anchor, t time.Time, loc *time.Location) (start, end time.Time, ok bool) {
+	a := anchor.In(loc)
+	t = t.In(loc)
+	y, m, d := a.Date()
+	boundary := func(k int) time.Time {
+		switch period {
+		case QuotaPeriodDaily:
+			return time.Date(y, m, d+k, 0, 0, 0, 0, loc)
+		case QuotaPeriodWeekly:
+			return time.Date(y, m, d+7*k, 0, 0, 0, 0, loc)
+		case QuotaPeriodMonthly:
+			return clampedDate(y, m+time.Month(k), d, loc)
+		default: // annual
+			return clampedDate(y+k, m, d, loc)
+		}
+	}
+
+	var k int
+	switch period {
+	case QuotaPeriodDaily:
+		k = int(t.Sub(boundary(0)).Hours() / 24)
+	case QuotaPeriodWeekly:
+		k = int(t.Sub(boundary(0)).Hours() / (24 * 7))
+	case QuotaPeriodMonthly:
+		k = (t.Year()-y)*12 + int(t.Month()-m)
+	case QuotaPeriodAnnual:
+		k = t.Year() - y
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+	for boundary(k).After(t) {
+		k--
+	}
+	for !boundary(k + 1).After(t) {
+		k++
+	}
+	return boundary(k), boundary(k + 1), true
+}
+
+// clampedDate 构造日期，日超出当月天数时取当月最后一天
+func clampedDate(y int, m time.Month, d int, loc *time.Location) time.Time {
+	first := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(d, last), 0, 0, 0, 0, loc)
+}
+
+// SyncOrganizationQuotasTx 按套餐 quota_config 在事务内同步组织配额：allocation = limit + 本周期已结转额度，
+// 周期起止按组织注册日与时区重新计算；resetUsage 时清空用量与结转。
+func SyncOrganizationQuotasTx(tx *gorm.DB, orgID, planID string, resetUsage bool) error {
+	var raw string
+	if err := tx.Raw("SELECT quota_config::text FROM plans WHERE id = ?", planID).Scan(&raw).Error; err != nil {
+		return err
+	}
+	if raw == "" {
+		return nil
+	}
+	entries, err := ParseQuotaConfig(raw)
+	if err != nil {
+		return err
+	}
+	var org models.Organization
+	if err := tx.Select("id", "timezone", "created_at").First(&org, "id = ?", orgID).Error; err != nil {
+		return err
+	}
+	loc := OrgLocation(org.Timezone)
+	now := time.Now()
+
+	for svc, e := range entries {
+		var periodStart, resetAt interface{}
+		if start, end, ok := QuotaPeriodBounds(e.Period, org.CreatedAt, now, loc); ok {
+			periodStart, resetAt = start, end
+		}
+		var before models.OrganizationQuotas
+		if err := tx.Raw("SELECT * FROM organization_quotas WHERE organization_id = ? AND service_type = ? FOR UPDATE", orgID, svc).Scan(&before).Error; err != nil {
+			return err
+		}
+		rolledOver, consumed := before.RolledOver, before.Consumed
+		if resetUsage || !e.Rollover {
+			rolledOver = 0
+		}
+		if resetUsage {
+			consumed = 0
+		}
+		alloc := e.Limit + rolledOver
+		if !e.Overage {
+			consumed = min(consumed, alloc)
+		}
+		overage := max(consumed-alloc, 0)
+		// hard_limit 仅在首次创建时取套餐默认值，之后由组织自行维护
+		if err := tx.Exec(`INSERT INTO organization_quotas(id, organization_id, service_type, allocation, consumed, base_allocation, rolled_over, period, rollover, rollover_cap, overage_enabled, hard_limit, overage, period_start_at, reset_at, updated_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
+			ON CONFLICT (organization_id, service_type) DO UPDATE SET allocation = EXCLUDED.allocation, consumed = EXCLUDED.consumed,
+				base_allocation = EXCLUDED.base_allocation, rolled_over = EXCLUDED.rolled_over, period = EXCLUDED.period,
+				rollover = EXCLUDED.rollover, rollover_cap = EXCLUDED.rollover_cap, overage_enabled = EXCLUDED.overage_enabled,
+				overage = EXCLUDED.overage, period_start_at = EXCLUDED.period_start_at, reset_at = EXCLUDED.reset_at, updated_at = NOW()`,
+			utils.GenerateID(), orgID, svc, alloc, consumed, e.Limit, rolledOver, e.Period, e.Rollover, e.RolloverCap, e.Overage, e.HardLimit, overage, periodStart, resetAt).Error; err != nil {
+			return err
+		}
+		if err := appendQuotaAdjustment(tx, orgID, svc, consumed-before.Consumed, "plan_sync"); err != nil {
+			return err
+		}
+		metrics.SetOrgQuotaLimit(context.Background(), orgID, svc, alloc)
+	}
+	return nil
+}
+
+// ResetDueQuotas 关闭已到期的计费周期：写入周期历史与 adjust 流水，按组织时区与注册日开启新周期并结转未用额度
+func (s *KYCService) ResetDueQuotas(ctx context.Context) (int, error) {
+	type dueRow struct {
+		models.OrganizationQuotas
+		Timezone     string
+		OrgCreatedAt time.Time
+	}
+	var rows []dueRow
+	now := time.Now()
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(`SELECT q.*, COALESCE(o.timezone, '') AS timezone, o.created_at AS org_created_at
+			FROM organization_quotas q JOIN organizations o ON o.id = q.organization_id
+			WHERE q.reset_at IS NOT NULL AND q.reset_at <= ? FOR UPDATE OF q`, now).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, q := range rows {
+			period, err := NormalizeQuotaPeriod(q.Period)
+			if err != nil || period == QuotaPeriodLifetime {
+				// 旧数据未记录周期时按月处理
+				period = QuotaPeriodMonthly
+			}
+			start, end, _ := QuotaPeriodBounds(period, q.OrgCreatedAt, now, OrgLocation(q.Timezone))
+			entry := QuotaPlanEntry{Limit: q.BaseAllocation, Period: period, Rollover: q.Rollover, RolloverCap: q.RolloverCap}
+			if entry.Limit == 0 {
+				entry.Limit = q.Allocation - q.RolledOver
+			}
+			carry := entry.rolloverAmount(q.Allocation, q.Consumed)
+
+			periodStart := q.PeriodStartAt
+			if periodStart == nil {
+				// 升级前的记录没有周期起点，按上一周期推算
+				ps, _, _ := QuotaPeriodBounds(period, q.OrgCreatedAt, q.ResetAt.Add(-time.Nanosecond), OrgLocation(q.Timezone))
+				periodStart = &ps
+			}
+			if err := tx.Create(&models.QuotaPeriodHistory{
+				ID: utils.GenerateID(), OrganizationID: q.OrganizationID, ServiceType: q.ServiceType, Period: period,
+				PeriodStart: *periodStart, PeriodEnd: *q.ResetAt, BaseAllocation: entry.Limit, RolledOverIn: q.RolledOver,
+				Allocation: q.Allocation, Consumed: q.Consumed, Overage: q.Overage, RolledOverOut: carry, ClosedAt: now,
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`UPDATE organization_quotas SET consumed = 0, overage = 0, allocation = ?, base_allocation = ?, rolled_over = ?, period = ?,
+				period_start_at = ?, reset_at = ?, updated_at = NOW() WHERE id = ?`,
+				entry.Limit+carry, entry.Limit, carry, period, start, end, q.ID).Error; err != nil {
+				return err
+			}
+			if err := appendQuotaAdjustment(tx, q.OrganizationID, q.ServiceType, -q.Consumed, "period_reset"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, q := range rows {
+		if s.Redis != nil {
+			_ = s.Redis.Del(ctx, quotaLimitKey(q.OrganizationID, q.ServiceType), quotaConsumedKey(q.OrganizationID, q.ServiceType)).Err()
+		}
+	}
+	return len(rows), nil
+}
+
+// ListQuotaPeriods 查询组织已关闭的计费周期（按结束时间倒序），serviceType 为空表示全部
+func (s *KYCService) ListQuotaPeriods(orgID, serviceType string, from, to *time.Time, limit, offset int) ([]models.QuotaPeriodHistory, int64, error) {
+	q := s.DB.Model(&models.QuotaPeriodHistory{}).Where("organization_id = ?", orgID)
+	if serviceType != "" {
+		q = q.Where("service_type = ?", serviceType)
+	}
+	if from != nil {
+		q = q.Where("period_end > ?", *from)
+	}
+	if to != nil {
+		q = q.Where("period_start < ?", *to)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []models.QuotaPeriodHistory
+	if err := q.Order("period_end DESC").Limit(limit).Offset(offset).Find(&out).Error; err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// UpdateOrganizationTimezone 修改组织时区，并按新时区重算当前计费周期
+func (s *KYCService) UpdateOrganizationTimezone(orgID, tz string) error {
+	if _, err := time.LoadLocation(tz); err != nil || tz == "" {
+		return fmt.Errorf("invalid timezone: %s", tz)
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var org models.Organization
+		if err := tx.First(&org, "id = ?", orgID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Organization{}).Where("id = ?", orgID).Update("timezone", tz).Error; err != nil {
+			return err
+		}
+		return SyncOrganizationQuotasTx(tx, orgID, org.PlanID, false)
+	})
+}
+
+// SetQuotaHardLimit 设置组织某项服务的超额硬上限（当期总用量，<=0 表示不限），套餐同步不会覆盖该值
+func (s *KYCService) SetQuotaHardLimit(ctx context.Context, orgID, serviceType string, hardLimit int) error {
+	res := s.DB.WithContext(ctx).Model(&models.OrganizationQuotas{}).
+		Where("organization_id = ? AND service_type = ?", orgID, serviceType).
+		Updates(map[string]interface{}{"hard_limit": max(hardLimit, 0), "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if s.Redis != nil {
+		_ = s.Redis.Del(ctx, quotaLimitKey(orgID, serviceType)).Err()
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaPeriodBounds(t *testing.T) {
+	sh, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, sh) }
+
+	tests := []struct {
+		name       string
+		period     string
+		anchor     time.Time
+		now        time.Time
+		start, end time.Time
+	}{
+		{"按日", QuotaPeriodDaily, day(2024, 1, 10).Add(15 * time.Hour), day(2024, 3, 5).Add(time.Hour), day(2024, 3, 5), day(2024, 3, 6)},
+		{"按周以注册日为锚点", QuotaPeriodWeekly, day(2024, 1, 3), day(2024, 1, 20), day(2024, 1, 17), day(2024, 1, 24)},
+		{"按月", QuotaPeriodMonthly, day(2024, 1, 15), day(2024, 3, 20), day(2024, 3, 15), day(2024, 4, 15)},
+		{"按月锚点日前", QuotaPeriodMonthly, day(2024, 1, 15), day(2024, 3, 14), day(2024, 2, 15), day(2024, 3, 15)},
+		{"按月月末截断", QuotaPeriodMonthly, day(2024, 1, 31), day(2024, 3, 1), day(2024, 2, 29), day(2024, 3, 31)},
+		{"按年闰日", QuotaPeriodAnnual, day(2024, 2, 29), day(2025, 6, 1), day(2025, 2, 28), day(2026, 2, 28)},
+		// UTC 2024-03-14 17:00 在上海已是 3月15日
+		{"组织时区", QuotaPeriodMonthly, day(2024, 1, 15), time.Date(2024, 3, 14, 17, 0, 0, 0, time.UTC), day(2024, 3, 15), day(2024, 4, 15)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := QuotaPeriodBounds(tt.period, tt.anchor, tt.now, sh)
+			assert.True(t, ok)
+			assert.True(t, tt.start.Equal(start), "start=%v", start)
+			assert.True(t, tt.end.Equal(end), "end=%v", end)
+		})
+	}
+
+	_, _, ok := QuotaPeriodBounds(QuotaPeriodLifetime, day(2024, 1, 1), day(2024, 2, 1), sh)
+	assert.False(t, ok)
+}
+
+func TestQuotaRolloverAmount(t *testing.T) {
+	tests := []struct {
+		name                 string
+		entry                QuotaPlanEntry
+		allocation, consumed int
+		want                 int
+	}{
+		{"未开启结转", QuotaPlanEntry{Limit: 100}, 100, 40, 0},
+		{"结转剩余额度", QuotaPlanEntry{Limit: 100, Rollover: true, RolloverCap: 100}, 100, 40, 60},
+		{"受上限限制", QuotaPlanEntry{Limit: 100, Rollover: true, RolloverCap: 50}, 150, 20, 50},
+		{"上限缺省为套餐额度", QuotaPlanEntry{Limit: 100, Rollover: true}, 180, 10, 100},
+		{"已用完", QuotaPlanEntry{Limit: 100, Rollover: true}, 100, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.entry.rolloverAmount(tt.allocation, tt.consumed))
+		})
+	}
+}
+
+func TestNormalizeQuotaPeriod(t *testing.T) {
+	p, err := NormalizeQuotaPeriod("Yearly")
+	assert.NoError(t, err)
+	assert.Equal(t, QuotaPeriodAnnual, p)
+	p, err = NormalizeQuotaPeriod("")
+	assert.NoError(t, err)
+	assert.Equal(t, QuotaPeriodLifetime, p)
+	_, err = NormalizeQuotaPeriod("hourly")
+	assert.Error(t, err)
+}
@@ -2,69 +2,158 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"kyc-service/internal/models"
 	"kyc-service/pkg/logger"
 	"kyc-service/pkg/metrics"
 	"kyc-service/pkg/utils"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// checkAndConsumeQuota 预占一次配额后执行 run：成功则确认，失败则自动退还
 func (s *KYCService) checkAndConsumeQuota(ctx context.Context, orgID, serviceType string, run func() error) error {
+	r, err := s.reserveQuota(ctx, orgID, serviceType)
+	if err != nil {
+		return err
+	}
+	if err := run(); err != nil {
+		s.refundQuota(ctx, r, err.Error())
+		return err
+	}
+	s.commitQuota(ctx, r)
+	return nil
+}
+
+// quotaReservation 一次配额预占，对应流水中的 reserve 记录
+type quotaReservation struct {
+	ID          string
+	OrgID       string
+	ServiceType string
+	RequestID   string
+	Overage     bool // 超出套餐额度、按超额计费的预占
+	redis       bool // 是否已在 Redis 计数器中预占
+}
+
+// reserveQuota 预占一次配额：Redis 计数器做快速判断，organization_quotas 与 reserve 流水在同一事务中落库。
+// 开启超额的组织在额度用完后继续放行（直到 hard_limit），超出部分计入 overage。
+func (s *KYCService) reserveQuota(ctx context.Context, orgID, serviceType string) (*quotaReservation, error) {
+	r := &quotaReservation{ID: utils.GenerateID(), OrgID: orgID, ServiceType: serviceType, RequestID: getRequestID(ctx)}
 	if s.Redis != nil {
 		ok, rerr := s.consumeQuotaRedis(ctx, orgID, serviceType)
-		if rerr == nil && ok {
-			metrics.IncOrgQuotaUsed(ctx, orgID, serviceType, 1)
-			if err := run(); err != nil {
-				_ = s.Redis.Decr(ctx, quotaConsumedKey(orgID, serviceType)).Err()
-				metrics.IncOrgQuotaUsed(ctx, orgID, serviceType, -1)
-				return err
-			}
-			go func() {
-				type rid struct{ ID string }
-				var r rid
-				var perr error
-				for i := 0; i < 3; i++ {
-					perr = s.DB.Raw("UPDATE organization_quotas SET consumed = consumed + 1 WHERE organization_id = ? AND service_type = ? AND consumed < allocation RETURNING id", orgID, serviceType).Scan(&r).Error
-					if perr == nil {
-						break
-					}
-					time.Sleep(time.Duration(i+1) * 200 * time.Millisecond)
-				}
-				if perr != nil || r.ID == "" {
-					logger.GetLogger().WithError(perr).Error("quota db persist failed, reverting redis")
-					_ = s.Redis.Decr(context.Background(), quotaConsumedKey(orgID, serviceType)).Err()
-					metrics.IncOrgQuotaUsed(context.Background(), orgID, serviceType, -1)
-					metrics.RecordQuotaPersistFailure(context.Background(), orgID, serviceType, "db_update_failed")
-				}
-			}()
-			return nil
+		r.redis = rerr == nil && ok
+		// 额度用完是正常结果，直接返回；只有 Redis 不可用时才回退到数据库判断
+		if errors.Is(rerr, ErrQuotaExceeded) {
+			return nil, rerr
 		}
 		if rerr != nil && rerr != redis.Nil {
 			logger.GetLogger().WithError(rerr).Warn("quota redis path error, fallback to db")
 		}
 	}
-	return s.DB.Transaction(func(tx *gorm.DB) error {
-		type rid struct{ ID string }
-		var r rid
-		if err := tx.Raw("UPDATE organization_quotas SET consumed = consumed + 1 WHERE organization_id = ? AND service_type = ? AND consumed < allocation RETURNING id", orgID, serviceType).Scan(&r).Error; err != nil {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		type rid struct {
+			ID      string
+			Overage bool
+		}
+		var q rid
+		if err := tx.Raw(`UPDATE organization_quotas SET consumed = consumed + 1, overage = overage + CASE WHEN consumed >= allocation THEN 1 ELSE 0 END
+			WHERE organization_id = ? AND service_type = ?
+				AND (consumed < allocation OR (overage_enabled AND (hard_limit <= 0 OR consumed < hard_limit)))
+			RETURNING id, consumed > allocation AS overage`, orgID, serviceType).Scan(&q).Error; err != nil {
 			return err
 		}
-		if r.ID == "" {
-			return fmt.Errorf("QUOTA_EXCEEDED")
+		if q.ID == "" {
+			return ErrQuotaExceeded
 		}
-		metrics.IncOrgQuotaUsed(ctx, orgID, serviceType, 1)
-		if err := run(); err != nil {
-			_ = tx.Exec("UPDATE organization_quotas SET consumed = consumed - 1 WHERE id = ?", r.ID).Error
-			metrics.IncOrgQuotaUsed(ctx, orgID, serviceType, -1)
-			return err
+		r.Overage = q.Overage
+		entry := &models.QuotaLedgerEntry{
+			ID: r.ID, ReservationID: r.ID, EntryType: QuotaEntryReserve,
+			OrganizationID: orgID, ServiceType: serviceType, RequestID: r.RequestID, Amount: 1,
+		}
+		if r.Overage {
+			entry.Reason = "overage"
 		}
-		return nil
+		return tx.Create(entry).Error
 	})
+	if err != nil {
+		if r.redis {
+			_ = s.Redis.Decr(context.WithoutCancel(ctx), quotaConsumedKey(orgID, serviceType)).Err()
+		}
+		if !errors.Is(err, ErrQuotaExceeded) {
+			logger.GetLogger().WithError(err).Error("quota reserve failed")
+			metrics.RecordQuotaPersistFailure(ctx, orgID, serviceType, "reserve_failed")
+		}
+		return nil, err
+	}
+	metrics.IncOrgQuotaUsed(ctx, orgID, serviceType, 1)
+	if r.Overage {
+		metrics.IncOrgQuotaOverage(ctx, orgID, serviceType, 1)
+	}
+	return r, nil
+}
+
+// commitQuota 确认预占（流水记 0，不改变余额）
+func (s *KYCService) commitQuota(ctx context.Context, r *quotaReservation) {
+	entry := &models.QuotaLedgerEntry{
+		ID: utils.GenerateID(), ReservationID: r.ID, EntryType: QuotaEntryCommit,
+		OrganizationID: r.OrgID, ServiceType: r.ServiceType, RequestID: r.RequestID,
+	}
+	if err := s.DB.WithContext(context.WithoutCancel(ctx)).Create(entry).Error; err != nil {
+		logger.GetLogger().WithError(err).Warnf("quota commit failed: reservation=%s", r.ID)
+		metrics.RecordQuotaPersistFailure(ctx, r.OrgID, r.ServiceType, "commit_failed")
+	}
+}
+
+// refundQuota 退还预占：refund 流水与 consumed 回退在同一事务中完成，同一预占只会退还一次
+func (s *KYCService) refundQuota(ctx context.Context, r *quotaReservation, reason string) {
+	ctx = context.WithoutCancel(ctx)
+	refunded := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.QuotaLedgerEntry{
+			ID: utils.GenerateID(), ReservationID: r.ID, EntryType: QuotaEntryRefund,
+			OrganizationID: r.OrgID, ServiceType: r.ServiceType, RequestID: r.RequestID, Amount: -1, Reason: reason,
+		})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		refunded = true
+		// overage 始终等于 max(consumed - allocation, 0)，与预占先后无关
+		return tx.Exec(`UPDATE organization_quotas SET consumed = consumed - 1,
+				overage = overage - CASE WHEN consumed > allocation AND overage > 0 THEN 1 ELSE 0 END
+			WHERE organization_id = ? AND service_type = ? AND consumed > 0`, r.OrgID, r.ServiceType).Error
+	})
+	if err != nil {
+		logger.GetLogger().WithError(err).Errorf("quota refund failed: reservation=%s", r.ID)
+		metrics.RecordQuotaPersistFailure(ctx, r.OrgID, r.ServiceType, "refund_failed")
+		return
+	}
+	if !refunded {
+		return
+	}
+	if r.redis {
+		_ = s.Redis.Decr(ctx, quotaConsumedKey(r.OrgID, r.ServiceType)).Err()
+	}
+	metrics.IncOrgQuotaUsed(ctx, r.OrgID, r.ServiceType, -1)
+	if r.Overage {
+		metrics.IncOrgQuotaOverage(ctx, r.OrgID, r.ServiceType, -1)
+	}
+}
+
+// QuotaCeiling 组织当期可用的总用量上限：未开启超额为 allocation，开启后为 hard_limit（不限时返回 math.MaxInt32）
+func QuotaCeiling(q models.OrganizationQuotas) int {
+	if !q.OverageEnabled {
+		return q.Allocation
+	}
+	if q.HardLimit <= 0 {
+		return math.MaxInt32
+	}
+	return max(q.HardLimit, q.Allocation)
 }
 
 func quotaLimitKey(orgID, serviceType string) string {
@@ -80,15 +169,11 @@ func (s *KYCService) consumeQuotaRedis(ctx context.Context, orgID, serviceType s
 	consumedKey := quotaConsumedKey(orgID, serviceType)
 	limitStr, err := s.Redis.Get(ctx, limitKey).Result()
 	if err == redis.Nil || limitStr == "" {
-		type row struct {
-			Allocation int
-			Consumed   int
-		}
-		var q row
-		if err := s.DB.Raw("SELECT allocation, consumed FROM organization_quotas WHERE organization_id = ? AND service_type = ?", orgID, serviceType).Scan(&q).Error; err != nil {
+		var q models.OrganizationQuotas
+		if err := s.DB.Raw("SELECT allocation, consumed, overage_enabled, hard_limit FROM organization_quotas WHERE organization_id = ? AND service_type = ?", orgID, serviceType).Scan(&q).Error; err != nil {
 			return false, err
 		}
-		_ = s.Redis.Set(ctx, limitKey, fmt.Sprintf("%d", q.Allocation), 30*time.Second).Err()
+		_ = s.Redis.Set(ctx, limitKey, fmt.Sprintf("%d", QuotaCeiling(q)), 30*time.Second).Err()
 		_ = s.Redis.Set(ctx, consumedKey, fmt.Sprintf("%d", q.Consumed), 30*time.Second).Err()
 	}
 	// atomic check and increment
@@ -108,7 +193,7 @@ func (s *KYCService) consumeQuotaRedis(ctx context.Context, orgID, serviceType s
 		return true, nil
 	}
 	if r == -1 {
-		return false, fmt.Errorf("QUOTA_EXCEEDED")
+		return false, ErrQuotaExceeded
 	}
 	return false, fmt.Errorf("QUOTA_UNAVAILABLE")
 }
@@ -117,34 +202,9 @@ func (s *KYCService) SyncOrganizationQuotas(orgID string, planID string) error {
 	return s.SyncOrganizationQuotasWithPolicy(orgID, planID, false)
 }
 
+// SyncOrganizationQuotasWithPolicy 按套餐同步组织配额，resetUsage 为 true 时清空当期用量
 func (s *KYCService) SyncOrganizationQuotasWithPolicy(orgID string, planID string, resetUsage bool) error {
-	var raw string
-	if err := s.DB.Raw("SELECT quota_config::text FROM plans WHERE id = ?", planID).Scan(&raw).Error; err != nil {
-		return err
-	}
-	if raw == "" {
-		return nil
-	}
-	var m map[string]map[string]interface{}
-	_ = json.Unmarshal([]byte(raw), &m)
-	for svc, v := range m {
-		alloc := 0
-		if l, ok := v["limit"].(float64); ok {
-			alloc = int(l)
-		}
-		var reset interface{}
-		if p, ok := v["period"].(string); ok && p == "monthly" {
-			nm := time.Date(time.Now().Year(), time.Now().Month()+1, 1, 0, 0, 0, 0, time.Now().Location())
-			reset = nm
-		} else {
-			reset = nil
-		}
-		if resetUsage {
-			_ = s.DB.Exec("INSERT INTO organization_quotas(id, organization_id, service_type, allocation, consumed, reset_at, updated_at) VALUES(?, ?, ?, ?, 0, ?, NOW()) ON CONFLICT (organization_id, service_type) DO UPDATE SET allocation = EXCLUDED.allocation, consumed = 0, reset_at = EXCLUDED.reset_at, updated_at = NOW()", utils.GenerateID(), orgID, svc, alloc, reset).Error
-		} else {
-			_ = s.DB.Exec("INSERT INTO organization_quotas(id, organization_id, service_type, allocation, consumed, reset_at, updated_at) VALUES(?, ?, ?, ?, 0, ?, NOW()) ON CONFLICT (organization_id, service_type) DO UPDATE SET allocation = EXCLUDED.allocation, consumed = LEAST(organization_quotas.consumed, EXCLUDED.allocation), reset_at = EXCLUDED.reset_at, updated_at = NOW()", utils.GenerateID(), orgID, svc, alloc, reset).Error
-		}
-		metrics.SetOrgQuotaLimit(context.Background(), orgID, svc, alloc)
-	}
-	return nil
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return SyncOrganizationQuotasTx(tx, orgID, planID, resetUsage)
+	})
 }
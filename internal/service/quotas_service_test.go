@@ -0,0 +1,28 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"kyc-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaCeiling(t *testing.T) {
+	tests := []struct {
+		name string
+		q    models.OrganizationQuotas
+		want int
+	}{
+		{"未开启超额", models.OrganizationQuotas{Allocation: 100, HardLimit: 500}, 100},
+		{"超额不限", models.OrganizationQuotas{Allocation: 100, OverageEnabled: true}, math.MaxInt32},
+		{"超额硬上限", models.OrganizationQuotas{Allocation: 100, OverageEnabled: true, HardLimit: 150}, 150},
+		{"硬上限低于额度", models.OrganizationQuotas{Allocation: 100, OverageEnabled: true, HardLimit: 50}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, QuotaCeiling(tt.q))
+		})
+	}
+}
@@ -0,0 +1,430 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+)
+
+// 数据保留的数据类别
+const (
+	RetentionRawImages    = "raw_images"    // 身份证/自拍图片、活体视频及人脸库引用
+	RetentionExtractedPII = "extracted_pii" // KYC请求中的姓名、证件号、手机号等，以及包含它们的异步任务
+	RetentionResults      = "results"       // KYC请求记录及判定结果
+	RetentionRequestLogs  = "request_logs"  // API请求日志
+)
+
+// RetentionDataClasses 全部数据类别
+var RetentionDataClasses = []string{RetentionRawImages, RetentionExtractedPII, RetentionResults, RetentionRequestLogs}
+
+const maxRetentionDays = 3650
+
+// 保留天数的来源
+const (
+	RetentionSourceDefault = "default"
+	RetentionSourcePlan    = "plan"
+	RetentionSourceOrg     = "org"
+)
+
+var ErrRetentionRunning = errors.New("retention purge already running")
+
+// RetentionPolicy 数据类别 -> 保留天数；0 表示永久保留，未出现的类别继承上一级（组织策略 > 套餐 > 系统默认）
+type RetentionPolicy map[string]int
+
+// Validate 校验类别与天数
+func (p RetentionPolicy) Validate() error {
+	for class, days := range p {
+		if !isRetentionDataClass(class) {
+			return fmt.Errorf("unknown data class: %s", class)
+		}
+		if days < 0 || days > maxRetentionDays {
+			return fmt.Errorf("%s must be between 0 and %d days", class, maxRetentionDays)
+		}
+	}
+	return nil
+}
+
+func isRetentionDataClass(class string) bool {
+	for _, c := range RetentionDataClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRetentionPolicy 解析套餐 retention_config 或组织策略 JSON
+func ParseRetentionPolicy(raw string) (RetentionPolicy, error) {
+	p := RetentionPolicy{}
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return nil, err
+	}
+	return p, p.Validate()
+}
+
+// EffectiveRetention 组织生效的保留策略
+type EffectiveRetention struct {
+	Policy    RetentionPolicy   `json:"policy"`
+	Sources   map[string]string `json:"sources"` // 各类别天数的来源：default、plan、org
+	OrgPolicy RetentionPolicy   `json:"org_policy"`
+}
+
+// resolveRetention 按 默认 < 套餐 < 组织 逐层覆盖
+func resolveRetention(defaults, plan, org RetentionPolicy) *EffectiveRetention {
+	eff := &EffectiveRetention{Policy: RetentionPolicy{}, Sources: map[string]string{}, OrgPolicy: org}
+	if eff.OrgPolicy == nil {
+		eff.OrgPolicy = RetentionPolicy{}
+	}
+	for _, class := range RetentionDataClasses {
+		eff.Policy[class], eff.Sources[class] = 0, RetentionSourceDefault
+		for _, layer := range []struct {
+			p      RetentionPolicy
+			source string
+		}{{defaults, RetentionSourceDefault}, {plan, RetentionSourcePlan}, {org, RetentionSourceOrg}} {
+			if days, ok := layer.p[class]; ok {
+				eff.Policy[class], eff.Sources[class] = days, layer.source
+			}
+		}
+	}
+	return eff
+}
+
+func retentionPolicyKey(orgID string) string { return "retention_policy:" + orgID }
+
+// GetOrgRetentionPolicy 组织自行设置的保留策略（存于 global_configs）
+func (s *KYCService) GetOrgRetentionPolicy(orgID string) RetentionPolicy {
+	var raw string
+	_ = s.DB.Raw("SELECT value FROM global_configs WHERE key = ?", retentionPolicyKey(orgID)).Scan(&raw).Error
+	p, err := ParseRetentionPolicy(raw)
+	if err != nil {
+		logger.GetLogger().WithError(err).Warnf("组织 %s 的保留策略无效，已忽略", orgID)
+		return RetentionPolicy{}
+	}
+	return p
+}
+
+// SaveOrgRetentionPolicy 保存组织保留策略；空策略表示完全继承套餐
+func (s *KYCService) SaveOrgRetentionPolicy(orgID string, p RetentionPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.DB.Exec("INSERT INTO global_configs(key, value, updated_at) VALUES(?, ?, ?) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at", retentionPolicyKey(orgID), string(b), time.Now()).Error
+}
+
+func (s *KYCService) planRetentionPolicy(orgID string) RetentionPolicy {
+	var raw string
+	_ = s.DB.Raw("SELECT COALESCE(p.retention_config::text, '') FROM organizations o JOIN plans p ON p.id = o.plan_id WHERE o.id = ?", orgID).Scan(&raw).Error
+	p, err := ParseRetentionPolicy(raw)
+	if err != nil {
+		logger.GetLogger().WithError(err).Warnf("组织 %s 所属套餐的保留配置无效，已忽略", orgID)
+		return RetentionPolicy{}
+	}
+	return p
+}
+
+// EffectiveRetentionPolicy 组织生效的保留策略；orgID 为空（不属于任何组织的早期数据）只使用系统默认
+func (s *KYCService) EffectiveRetentionPolicy(orgID string) *EffectiveRetention {
+	defaults := RetentionPolicy(s.Config.Retention.Defaults)
+	if orgID == "" {
+		return resolveRetention(defaults, nil, nil)
+	}
+	return resolveRetention(defaults, s.planRetentionPolicy(orgID), s.GetOrgRetentionPolicy(orgID))
+}
+
+const assetUsedAtCol = "COALESCE(last_used_at, created_at)"
+
+// retentionTarget 一张表在某数据类别下的清理方式
+type retentionTarget struct {
+	Class    string
+	Table    string
+	OrgCol   string
+	Extra    string // 额外条件
+	TimeCol  string // 计算保留期的时间列，为空时为 created_at
+	Set      string // 非空时为 UPDATE 的 SET 子句（清除字段），否则删除行
+	BlobKind bool   // 删除行前先删除对应对象
+}
+
+// 图片/视频去重复用，按最近一次入库时间计算保留期（早期记录没有时按创建时间）；
+// 人脸库引用指向供应商人脸库中的文件，由供应商管理，这里只删除引用记录
+var retentionTargets = []retentionTarget{
+	{Class: RetentionRawImages, Table: "image_assets", OrgCol: "organization_id", TimeCol: assetUsedAtCol, BlobKind: true},
+	{Class: RetentionRawImages, Table: "video_assets", OrgCol: "organization_id", TimeCol: assetUsedAtCol, BlobKind: true},
+	{Class: RetentionRawImages, Table: "face_image_refs", OrgCol: "organization_id"},
+	{Class: RetentionExtractedPII, Table: "kyc_requests", OrgCol: "org_id", Extra: "pii_purged_at IS NULL",
+		Set: "id_card_hash = '', id_card = '', name = '', phone = '', phone_hash = '', face_image = '', id_card_image = '', liveness_data = '', pii_purged_at = NOW()"},
+	{Class: RetentionExtractedPII, Table: "kyc_jobs", OrgCol: "org_id", Extra: "status IN ('success', 'failed', 'cancelled')"},
+	{Class: RetentionResults, Table: "kyc_requests", OrgCol: "org_id"},
+	{Class: RetentionRequestLogs, Table: "api_request_logs", OrgCol: "org_id"},
+}
+
+// where 组织与截止时间条件；orgID 为空时匹配未关联组织的早期数据
+func (t retentionTarget) where(orgID string, cutoff time.Time) (string, []interface{}) {
+	cond := t.OrgCol + " = ?"
+	args := []interface{}{orgID}
+	if orgID == "" {
+		cond = "(" + t.OrgCol + " IS NULL OR " + t.OrgCol + " = '')"
+		args = nil
+	}
+	timeCol := t.TimeCol
+	if timeCol == "" {
+		timeCol = "created_at"
+	}
+	cond += " AND " + timeCol + " < ?"
+	args = append(args, cutoff)
+	if t.Extra != "" {
+		cond += " AND " + t.Extra
+	}
+	return cond, args
+}
+
+// RetentionItem 一张表的清理结果；dry-run 时 Rows 为待清理行数
+type RetentionItem struct {
+	DataClass  string    `json:"data_class"`
+	Table      string    `json:"table"`
+	Action     string    `json:"action"` // delete 或 scrub
+	Cutoff     time.Time `json:"cutoff"`
+	Rows       int64     `json:"rows"`
+	Blobs      int64     `json:"blobs,omitempty"`
+	BlobErrors int64     `json:"blob_errors,omitempty"`
+}
+
+// OrgRetentionReport 单个组织的清理报告
+type OrgRetentionReport struct {
+	OrgID  string          `json:"org_id"`
+	Policy RetentionPolicy `json:"policy"`
+	Items  []RetentionItem `json:"items"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// RetentionReport 一次清理的报告
+type RetentionReport struct {
+	DryRun     bool                 `json:"dry_run"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+	Orgs       []OrgRetentionReport `json:"orgs"`
+}
+
+func (s *KYCService) retentionBatchSize() int {
+	if n := s.Config.Retention.BatchSize; n > 0 {
+		return n
+	}
+	return 500
+}
+
+const retentionLockKey = "retention:purge:lock"
+
+// lockRetentionPurge 获取保留期清理锁，多实例、全量与单组织清理之间互斥；已被持有时返回 ErrRetentionRunning
+func (s *KYCService) lockRetentionPurge(ctx context.Context, actorID string) (func(), error) {
+	ok, err := s.Redis.SetNX(ctx, retentionLockKey, actorID, 2*time.Hour).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRetentionRunning
+	}
+	return func() { s.Redis.Del(context.Background(), retentionLockKey) }, nil
+}
+
+// RunRetentionPurge 按各组织生效策略清理超期数据（含不属于任何组织的早期数据），多实例间以 Redis 锁互斥。
+// dryRun 时只统计待清理行数，同样写入审计
+func (s *KYCService) RunRetentionPurge(ctx context.Context, dryRun bool, actorID string) (*RetentionReport, error) {
+	if !dryRun {
+		unlock, err := s.lockRetentionPurge(ctx, actorID)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	var orgIDs []string
+	if err := s.DB.WithContext(ctx).Model(&models.Organization{}).Order("id").Pluck("id", &orgIDs).Error; err != nil {
+		return nil, err
+	}
+	report := &RetentionReport{DryRun: dryRun, StartedAt: time.Now()}
+	for _, orgID := range append([]string{""}, orgIDs...) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		r := s.PurgeOrgRetention(ctx, orgID, dryRun, actorID)
+		if len(r.Items) > 0 || r.Error != "" {
+			report.Orgs = append(report.Orgs, *r)
+		}
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// RunOrgRetentionPurge 只清理单个组织，与全量清理共用同一把锁；dryRun 时不加锁
+func (s *KYCService) RunOrgRetentionPurge(ctx context.Context, orgID string, dryRun bool, actorID string) (*OrgRetentionReport, error) {
+	if !dryRun {
+		unlock, err := s.lockRetentionPurge(ctx, actorID)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	return s.PurgeOrgRetention(ctx, orgID, dryRun, actorID), nil
+}
+
+// PreviewOrgRetention 统计单个组织将被清理的数据，不删除也不写审计
+func (s *KYCService) PreviewOrgRetention(ctx context.Context, orgID string) *OrgRetentionReport {
+	return s.purgeOrgRetention(ctx, orgID, true)
+}
+
+// PurgeOrgRetention 清理单个组织的超期数据并写审计；没有任何超期数据时不写审计。
+// 调用方须持有清理锁（见 RunOrgRetentionPurge）
+func (s *KYCService) PurgeOrgRetention(ctx context.Context, orgID string, dryRun bool, actorID string) *OrgRetentionReport {
+	report := s.purgeOrgRetention(ctx, orgID, dryRun)
+	if len(report.Items) > 0 || report.Error != "" {
+		s.recordRetentionAudit(ctx, report, dryRun, actorID)
+	}
+	return report
+}
+
+func (s *KYCService) purgeOrgRetention(ctx context.Context, orgID string, dryRun bool) *OrgRetentionReport {
+	eff := s.EffectiveRetentionPolicy(orgID)
+	report := &OrgRetentionReport{OrgID: orgID, Policy: eff.Policy, Items: []RetentionItem{}}
+	now := time.Now()
+	var errs []string
+	for _, t := range retentionTargets {
+		days := eff.Policy[t.Class]
+		if days <= 0 {
+			continue
+		}
+		item := RetentionItem{DataClass: t.Class, Table: t.Table, Action: "delete", Cutoff: now.AddDate(0, 0, -days)}
+		if t.Set != "" {
+			item.Action = "scrub"
+		}
+		var err error
+		switch {
+		case dryRun:
+			cond, args := t.where(orgID, item.Cutoff)
+			err = s.DB.WithContext(ctx).Table(t.Table).Where(cond, args...).Count(&item.Rows).Error
+		case t.BlobKind:
+			err = s.purgeAssetRows(ctx, t, orgID, &item)
+		default:
+			item.Rows, err = s.purgeRows(ctx, t, orgID, item.Cutoff)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s.%s: %v", t.Class, t.Table, err))
+		}
+		if item.Rows > 0 || item.BlobErrors > 0 {
+			report.Items = append(report.Items, item)
+		}
+	}
+	report.Error = strings.Join(errs, "; ")
+	return report
+}
+
+// purgeRows 分批删除（或清除字段），每批一条语句，直到不足一批
+func (s *KYCService) purgeRows(ctx context.Context, t retentionTarget, orgID string, cutoff time.Time) (int64, error) {
+	cond, args := t.where(orgID, cutoff)
+	batch := s.retentionBatchSize()
+	stmt := "DELETE FROM " + t.Table
+	if t.Set != "" {
+		stmt = "UPDATE " + t.Table + " SET " + t.Set
+	}
+	stmt += " WHERE id IN (SELECT id FROM " + t.Table + " WHERE " + cond + " LIMIT ?)"
+	var total int64
+	for {
+		res := s.DB.WithContext(ctx).Exec(stmt, append(args, batch)...)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < int64(batch) {
+			return total, nil
+		}
+	}
+}
+
+// purgeAssetRows 分批删除图片/视频：先删对象再删行；对象删除失败的行保留到下次重试
+func (s *KYCService) purgeAssetRows(ctx context.Context, t retentionTarget, orgID string, item *RetentionItem) error {
+	cond, args := t.where(orgID, item.Cutoff)
+	batch := s.retentionBatchSize()
+	type assetRow struct {
+		ID         string
+		StorageKey string
+		FilePath   string
+	}
+	lastID := ""
+	for {
+		var rows []assetRow
+		err := s.DB.WithContext(ctx).Table(t.Table).Select("id, storage_key, file_path").
+			Where(cond, args...).Where("id > ?", lastID).Order("id").Limit(batch).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(rows))
+		for _, r := range rows {
+			lastID = r.ID
+			if err := s.DeleteAsset(ctx, r.StorageKey, r.FilePath); err != nil {
+				item.BlobErrors++
+				logger.GetLogger().WithError(err).Warnf("保留期清理：删除对象失败 %s %s", t.Table, r.ID)
+				continue
+			}
+			item.Blobs++
+			ids = append(ids, r.ID)
+		}
+		if len(ids) > 0 {
+			res := s.DB.WithContext(ctx).Exec("DELETE FROM "+t.Table+" WHERE id IN ?", ids)
+			if res.Error != nil {
+				return res.Error
+			}
+			item.Rows += res.RowsAffected
+		}
+		if len(rows) < batch {
+			return nil
+		}
+	}
+}
+
+func (s *KYCService) recordRetentionAudit(ctx context.Context, r *OrgRetentionReport, dryRun bool, actorID string) {
+	action, status := "retention.purge", "success"
+	if dryRun {
+		action = "retention.dry_run"
+	}
+	if r.Error != "" {
+		status = "failed"
+	}
+	var parts []string
+	for _, it := range r.Items {
+		parts = append(parts, fmt.Sprintf("%s/%s %s %d", it.DataClass, it.Table, it.Action, it.Rows))
+	}
+	sort.Strings(parts)
+	details, _ := json.Marshal(r)
+	msg := strings.Join(parts, ", ")
+	if r.Error != "" {
+		msg = strings.TrimPrefix(msg+"; "+r.Error, "; ")
+	}
+	log := &models.AuditLog{
+		UserID:    actorID,
+		OrgID:     r.OrgID,
+		Action:    action,
+		Resource:  "organization",
+		Details:   string(details),
+		Status:    status,
+		Message:   msg,
+		CreatedAt: time.Now(),
+	}
+	if err := s.DB.WithContext(ctx).Create(log).Error; err != nil {
+		logger.GetLogger().WithError(err).Error("记录审计日志失败")
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyc-service/internal/config"
+	"kyc-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	p, err := ParseRetentionPolicy(`{"raw_images":30,"request_logs":0}`)
+	require.NoError(t, err)
+	assert.Equal(t, RetentionPolicy{"raw_images": 30, "request_logs": 0}, p)
+
+	p, err = ParseRetentionPolicy("")
+	require.NoError(t, err)
+	assert.Empty(t, p)
+
+	_, err = ParseRetentionPolicy(`{"selfies":30}`)
+	assert.Error(t, err)
+	_, err = ParseRetentionPolicy(`{"results":-1}`)
+	assert.Error(t, err)
+	_, err = ParseRetentionPolicy(`{"results":99999}`)
+	assert.Error(t, err)
+}
+
+func TestResolveRetention(t *testing.T) {
+	eff := resolveRetention(
+		RetentionPolicy{"raw_images": 90, "request_logs": 180},
+		RetentionPolicy{"raw_images": 30, "results": 365},
+		RetentionPolicy{"request_logs": 0},
+	)
+	assert.Equal(t, RetentionPolicy{"raw_images": 30, "extracted_pii": 0, "results": 365, "request_logs": 0}, eff.Policy)
+	assert.Equal(t, map[string]string{
+		"raw_images":    RetentionSourcePlan,
+		"extracted_pii": RetentionSourceDefault,
+		"results":       RetentionSourcePlan,
+		"request_logs":  RetentionSourceOrg,
+	}, eff.Sources)
+	assert.NotNil(t, resolveRetention(nil, nil, nil).OrgPolicy)
+}
+
+func TestRetentionTargetWhere(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tgt := retentionTarget{Table: "kyc_requests", OrgCol: "org_id", Extra: "pii_purged_at IS NULL"}
+	cond, args := tgt.where("org-1", cutoff)
+	assert.Equal(t, "org_id = ? AND created_at < ? AND pii_purged_at IS NULL", cond)
+	assert.Equal(t, []interface{}{"org-1", cutoff}, args)
+
+	// 未关联组织的早期数据
+	cond, args = tgt.where("", cutoff)
+	assert.Equal(t, "(org_id IS NULL OR org_id = '') AND created_at < ? AND pii_purged_at IS NULL", cond)
+	assert.Equal(t, []interface{}{cutoff}, args)
+
+	// 图片/视频按最近使用时间
+	cond, _ = retentionTargets[0].where("org-1", cutoff)
+	assert.Equal(t, "organization_id = ? AND COALESCE(last_used_at, created_at) < ?", cond)
+}
+
+func TestRetentionKeepsRecentlyReusedAssets(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&models.ImageAsset{}, &models.VideoAsset{}, &models.FaceImageRef{}))
+	s := &KYCService{DB: db, Config: &config.Config{Retention: config.RetentionConfig{Defaults: map[string]int{RetentionRawImages: 30}}}}
+	old, recent := time.Now().AddDate(0, 0, -90), time.Now()
+	require.NoError(t, db.Create(&models.ImageAsset{ID: "img_reused", OrganizationID: "org_1", Hash: "h1", CreatedAt: old, LastUsedAt: &recent}).Error)
+	require.NoError(t, db.Create(&models.ImageAsset{ID: "img_legacy", OrganizationID: "org_1", Hash: "h2", CreatedAt: old}).Error)
+
+	report := s.PreviewOrgRetention(context.Background(), "org_1")
+	require.Empty(t, report.Error)
+	rows := map[string]int64{}
+	for _, it := range report.Items {
+		rows[it.Table] = it.Rows
+	}
+	// 早期记录没有 last_used_at，按创建时间计算
+	assert.Equal(t, int64(1), rows["image_assets"])
+}
+
+func TestRetentionTargetsCoverAllClasses(t *testing.T) {
+	seen := map[string]bool{}
+	for _, tgt := range retentionTargets {
+		assert.True(t, isRetentionDataClass(tgt.Class), tgt.Table)
+		seen[tgt.Class] = true
+	}
+	for _, c := range RetentionDataClasses {
+		assert.True(t, seen[c], c)
+	}
+}
+
+func TestPreviewOrgRetentionIsNotAudited(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&models.KYCRequest{}, &models.AuditLog{}))
+	s := &KYCService{DB: db, Config: &config.Config{Retention: config.RetentionConfig{Defaults: map[string]int{RetentionResults: 30}}}}
+	require.NoError(t, db.Create(&models.KYCRequest{ID: "req_1", OrgID: "org_1", CreatedAt: time.Now().AddDate(0, 0, -31)}).Error)
+
+	ctx := context.Background()
+	report := s.PreviewOrgRetention(ctx, "org_1")
+	require.Empty(t, report.Error)
+	require.Len(t, report.Items, 1)
+	assert.Equal(t, int64(1), report.Items[0].Rows)
+	var audits int64
+	require.NoError(t, db.Model(&models.AuditLog{}).Count(&audits).Error)
+	assert.Zero(t, audits)
+
+	s.PurgeOrgRetention(ctx, "org_1", true, "admin_1")
+	require.NoError(t, db.Model(&models.AuditLog{}).Where("action = ?", "retention.dry_run").Count(&audits).Error)
+	assert.Equal(t, int64(1), audits)
+}
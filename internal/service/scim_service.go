@@ -0,0 +1,706 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/scim"
+	"kyc-service/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	scimTokenPrefix = "scim_"
+	// SCIMMaxResults 单次列表查询返回的最大条数
+	SCIMMaxResults = 200
+	// defaultSCIMRole 通过 SCIM 开通、且不属于任何已映射角色的组的成员角色
+	defaultSCIMRole = "viewer"
+)
+
+var ErrSCIMTokenInvalid = errors.New("invalid scim token")
+
+// scimRoleRank 成员同时属于多个组时取权限最高的映射角色
+var scimRoleRank = map[string]int{"viewer": 1, "developer": 2, "admin": 3}
+
+func highestSCIMRole(roles []string) string {
+	best := ""
+	for _, r := range roles {
+		if scimRoleRank[r] > scimRoleRank[best] {
+			best = r
+		}
+	}
+	return best
+}
+
+func scimNotFound(kind, id string) error {
+	return scim.NewError(404, "", fmt.Sprintf("%s %s not found", kind, id))
+}
+
+// CreateSCIMToken 为组织签发 SCIM 令牌，明文仅在创建时返回
+func (s *KYCService) CreateSCIMToken(orgID, name, createdBy string) (*models.SCIMToken, string, error) {
+	secret, err := randomURLToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	raw := scimTokenPrefix + secret
+	tok := &models.SCIMToken{
+		ID:        utils.GenerateID(),
+		OrgID:     orgID,
+		Name:      name,
+		TokenHash: HashClientSecret(raw),
+		Prefix:    raw[:len(scimTokenPrefix)+6],
+		CreatedBy: createdBy,
+	}
+	if err := s.DB.Create(tok).Error; err != nil {
+		return nil, "", err
+	}
+	return tok, raw, nil
+}
+
+// ListSCIMTokens 组织未吊销的 SCIM 令牌
+func (s *KYCService) ListSCIMTokens(orgID string) ([]models.SCIMToken, error) {
+	var tokens []models.SCIMToken
+	err := s.DB.Where("org_id = ? AND revoked_at IS NULL", orgID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeSCIMToken 吊销令牌，不存在时返回 gorm.ErrRecordNotFound
+func (s *KYCService) RevokeSCIMToken(orgID, id string) error {
+	res := s.DB.Model(&models.SCIMToken{}).Where("id = ? AND org_id = ? AND revoked_at IS NULL", id, orgID).Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AuthenticateSCIMToken 校验 Bearer 令牌并记录最近使用
+func (s *KYCService) AuthenticateSCIMToken(raw, ip string) (*models.SCIMToken, error) {
+	if !strings.HasPrefix(raw, scimTokenPrefix) {
+		return nil, ErrSCIMTokenInvalid
+	}
+	var tok models.SCIMToken
+	if err := s.DB.Where("token_hash = ? AND revoked_at IS NULL", HashClientSecret(raw)).First(&tok).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSCIMTokenInvalid
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.DB.Model(&tok).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error; err != nil {
+		logger.GetLogger().WithError(err).Warn("更新SCIM令牌使用时间失败")
+	}
+	return &tok, nil
+}
+
+// scimUserResource 组装 SCIM 用户表示
+func scimUserResource(u *models.User, m *models.OrganizationMember, groups []scim.Ref) *scim.User {
+	active := m.Status == "active"
+	created, modified := m.CreatedAt, m.UpdatedAt
+	return &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          u.ID,
+		ExternalID:  m.SCIMExternalID,
+		UserName:    u.Email,
+		Name:        &scim.Name{Formatted: u.Name},
+		DisplayName: u.Name,
+		Emails:      []scim.Email{{Value: u.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Groups:      groups,
+		Meta:        &scim.Meta{ResourceType: "User", Created: &created, LastModified: &modified},
+	}
+}
+
+// scimUserGroups 用户在组织内所属的 SCIM 组
+func (s *KYCService) scimUserGroups(orgID string, userIDs []string) (map[string][]scim.Ref, error) {
+	var rows []struct {
+		UserID      string
+		GroupID     string
+		DisplayName string
+	}
+	err := s.DB.Table("scim_group_members gm").
+		Select("gm.user_id, gm.group_id, g.display_name").
+		Joins("JOIN scim_groups g ON g.id = gm.group_id").
+		Where("g.org_id = ? AND gm.user_id IN ?", orgID, userIDs).
+		Order("g.display_name").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := map[string][]scim.Ref{}
+	for _, r := range rows {
+		out[r.UserID] = append(out[r.UserID], scim.Ref{Value: r.GroupID, Display: r.DisplayName})
+	}
+	return out, nil
+}
+
+// ListSCIMUsers 组织成员列表，支持 userName / emails.value / externalId / id / active 相等过滤
+func (s *KYCService) ListSCIMUsers(orgID string, f *scim.Filter, startIndex, count int) ([]*scim.User, int64, error) {
+	q := s.DB.Model(&models.OrganizationMember{}).
+		Joins("JOIN users ON users.id = organization_members.user_id AND users.deleted_at IS NULL").
+		Where("organization_members.organization_id = ?", orgID)
+	if f != nil {
+		switch f.Attr {
+		case "username", "emails.value", "emails":
+			q = q.Where("LOWER(users.email) = ?", strings.ToLower(f.Value))
+		case "externalid":
+			q = q.Where("organization_members.scim_external_id = ?", f.Value)
+		case "id":
+			q = q.Where("users.id = ?", f.Value)
+		case "active":
+			if f.Value == "true" {
+				q = q.Where("organization_members.status = ?", "active")
+			} else {
+				q = q.Where("organization_members.status <> ?", "active")
+			}
+		default:
+			return nil, 0, scim.NewError(400, scim.ErrInvalidFilter, "unsupported filter attribute "+f.Attr)
+		}
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var members []models.OrganizationMember
+	if count > 0 {
+		if err := q.Select("organization_members.*").Order("organization_members.created_at, organization_members.id").
+			Offset(startIndex - 1).Limit(count).Find(&members).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	if len(members) == 0 {
+		return []*scim.User{}, total, nil
+	}
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserID)
+	}
+	var users []models.User
+	if err := s.DB.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]*models.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	groups, err := s.scimUserGroups(orgID, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]*scim.User, 0, len(members))
+	for i := range members {
+		if u := byID[members[i].UserID]; u != nil {
+			out = append(out, scimUserResource(u, &members[i], groups[u.ID]))
+		}
+	}
+	return out, total, nil
+}
+
+// scimMember 加载组织内的用户与成员记录
+func (s *KYCService) scimMember(db *gorm.DB, orgID, userID string) (*models.User, *models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, scimNotFound("User", userID)
+		}
+		return nil, nil, err
+	}
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, scimNotFound("User", userID)
+		}
+		return nil, nil, err
+	}
+	return &user, &member, nil
+}
+
+// GetSCIMUser 单个成员
+func (s *KYCService) GetSCIMUser(orgID, userID string) (*scim.User, error) {
+	user, member, err := s.scimMember(s.DB, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.scimUserGroups(orgID, []string{userID})
+	if err != nil {
+		return nil, err
+	}
+	return scimUserResource(user, member, groups[userID]), nil
+}
+
+func scimUserName(in *scim.User) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(in.UserName))
+	if err != nil || addr.Address != strings.TrimSpace(in.UserName) {
+		return "", scim.NewError(400, scim.ErrInvalidValue, "userName must be an email address")
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+func scimMemberStatus(active bool) string {
+	if active {
+		return "active"
+	}
+	return "suspended"
+}
+
+// CreateSCIMUser 开通成员：新邮箱创建账号；已注册的账号仅在组织验证过其邮箱域名时加入组织，
+// 否则返回 409，需通过邀请由账号本人接受，避免 SCIM 令牌持有者把任意账号拉入组织
+func (s *KYCService) CreateSCIMUser(orgID string, in *scim.User) (*scim.User, error) {
+	email, err := scimUserName(in)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	isNew := false
+	err = s.DB.Unscoped().Where("LOWER(email) = ?", email).First(&user).Error
+	switch {
+	case err == nil && user.DeletedAt.Valid:
+		return nil, scim.NewError(409, scim.ErrUniqueness, "an account with this userName was deleted")
+	case err == nil:
+		var n int64
+		if err := s.DB.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgID, user.ID).Count(&n).Error; err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return nil, scim.NewError(409, scim.ErrUniqueness, "user already exists in this organization")
+		}
+		owns, err := s.OrgOwnsEmailDomain(orgID, email)
+		if err != nil {
+			return nil, err
+		}
+		if !owns {
+			return nil, scim.NewError(409, scim.ErrUniqueness,
+				"an account with this userName already exists; verify its email domain for this organization or invite the user instead")
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		name := in.FullName()
+		if name == "" {
+			name = strings.Split(email, "@")[0]
+		}
+		u, err := newProvisionedUser(email, name, orgID, defaultSCIMRole)
+		if err != nil {
+			return nil, err
+		}
+		user, isNew = *u, true
+	default:
+		return nil, err
+	}
+	member := models.OrganizationMember{
+		ID:             utils.GenerateID(),
+		OrganizationID: orgID,
+		UserID:         user.ID,
+		Role:           defaultSCIMRole,
+		Status:         scimMemberStatus(in.IsActive()),
+		SCIMExternalID: in.ExternalID,
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if isNew {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scimUserResource(&user, &member, nil), nil
+}
+
+// ReplaceSCIMUser 整体更新成员：姓名（仅限以该组织为主组织的账号）、externalId 与启用状态；
+// 停用时吊销该用户已签发的令牌
+func (s *KYCService) ReplaceSCIMUser(ctx context.Context, orgID, userID string, in *scim.User) (*scim.User, error) {
+	user, member, err := s.scimMember(s.DB, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if in.UserName != "" && !strings.EqualFold(strings.TrimSpace(in.UserName), user.Email) {
+		return nil, scim.NewError(400, scim.ErrMutability, "userName cannot be changed")
+	}
+	active := in.IsActive()
+	if member.Role == "owner" && !active {
+		return nil, scim.NewError(403, "", "the organization owner cannot be deactivated via SCIM")
+	}
+	wasActive := member.Status == "active"
+	member.SCIMExternalID = in.ExternalID
+	member.Status = scimMemberStatus(active)
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(member).Updates(map[string]interface{}{"scim_external_id": member.SCIMExternalID, "status": member.Status}).Error; err != nil {
+			return err
+		}
+		// 账号资料为全局信息，只允许其主组织修改
+		if name := in.FullName(); name != "" && user.OrgID == orgID && name != user.Name {
+			user.Name, user.FullName = name, name
+			return tx.Model(user).Updates(map[string]interface{}{"name": name, "full_name": name}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if wasActive && !active {
+		if err := s.RevokeTokensBy(ctx, RevocationByUser, userID, "scim_deactivated"); err != nil {
+			logger.GetLogger().WithError(err).Error("吊销成员令牌失败")
+		}
+	}
+	return s.GetSCIMUser(orgID, userID)
+}
+
+// PatchSCIMUser 按 PATCH 操作更新成员
+func (s *KYCService) PatchSCIMUser(ctx context.Context, orgID, userID string, ops []scim.PatchOp) (*scim.User, error) {
+	current, err := s.GetSCIMUser(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := current.ApplyPatch(ops); err != nil {
+		return nil, err
+	}
+	return s.ReplaceSCIMUser(ctx, orgID, userID, current)
+}
+
+// DeleteSCIMUser 将用户移出组织（账号本身保留），同时移出该组织的 SCIM 组并吊销其令牌
+func (s *KYCService) DeleteSCIMUser(ctx context.Context, orgID, userID string) error {
+	_, member, err := s.scimMember(s.DB, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == "owner" {
+		return scim.NewError(403, "", "the organization owner cannot be removed via SCIM")
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND group_id IN (?)", userID,
+			tx.Model(&models.SCIMGroup{}).Select("id").Where("org_id = ?", orgID)).
+			Delete(&models.SCIMGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(member).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.RevokeTokensBy(ctx, RevocationByUser, userID, "scim_deprovisioned"); err != nil {
+		logger.GetLogger().WithError(err).Error("吊销成员令牌失败")
+	}
+	return nil
+}
+
+// syncSCIMRoles 按组映射重新计算成员角色：组织存在已映射角色的组时，成员角色为其所属组中最高的映射角色，
+// 不属于任何已映射组时为默认角色；所有者不受影响
+func (s *KYCService) syncSCIMRoles(tx *gorm.DB, orgID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var mapped int64
+	if err := tx.Model(&models.SCIMGroup{}).Where("org_id = ? AND role <> ''", orgID).Count(&mapped).Error; err != nil {
+		return err
+	}
+	if mapped == 0 {
+		return nil
+	}
+	for _, uid := range userIDs {
+		var roles []string
+		if err := tx.Table("scim_group_members gm").
+			Joins("JOIN scim_groups g ON g.id = gm.group_id").
+			Where("g.org_id = ? AND gm.user_id = ? AND g.role <> ''", orgID, uid).
+			Pluck("g.role", &roles).Error; err != nil {
+			return err
+		}
+		role := highestSCIMRole(roles)
+		if role == "" {
+			role = defaultSCIMRole
+		}
+		if err := tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ? AND role <> ?", orgID, uid, "owner").
+			Update("role", role).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scimGroupResource 组装 SCIM 组表示
+func scimGroupResource(g *models.SCIMGroup, members []scim.Ref) *scim.Group {
+	created, modified := g.CreatedAt, g.UpdatedAt
+	return &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          g.ID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Members:     members,
+		Meta:        &scim.Meta{ResourceType: "Group", Created: &created, LastModified: &modified},
+	}
+}
+
+func (s *KYCService) scimGroupMembers(groupIDs []string) (map[string][]scim.Ref, error) {
+	var rows []struct {
+		GroupID string
+		UserID  string
+		Email   string
+	}
+	err := s.DB.Table("scim_group_members gm").
+		Select("gm.group_id, gm.user_id, users.email").
+		Joins("JOIN users ON users.id = gm.user_id").
+		Where("gm.group_id IN ?", groupIDs).
+		Order("users.email").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := map[string][]scim.Ref{}
+	for _, r := range rows {
+		out[r.GroupID] = append(out[r.GroupID], scim.Ref{Value: r.UserID, Display: r.Email})
+	}
+	return out, nil
+}
+
+// ListSCIMGroups 组织的 SCIM 组，支持 displayName / externalId / id 相等过滤；withMembers 为 false 时不返回成员
+func (s *KYCService) ListSCIMGroups(orgID string, f *scim.Filter, startIndex, count int, withMembers bool) ([]*scim.Group, int64, error) {
+	q := s.DB.Model(&models.SCIMGroup{}).Where("org_id = ?", orgID)
+	if f != nil {
+		switch f.Attr {
+		case "displayname":
+			q = q.Where("LOWER(display_name) = ?", strings.ToLower(f.Value))
+		case "externalid":
+			q = q.Where("external_id = ?", f.Value)
+		case "id":
+			q = q.Where("id = ?", f.Value)
+		default:
+			return nil, 0, scim.NewError(400, scim.ErrInvalidFilter, "unsupported filter attribute "+f.Attr)
+		}
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var groups []models.SCIMGroup
+	if count > 0 {
+		if err := q.Order("created_at, id").Offset(startIndex - 1).Limit(count).Find(&groups).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	members := map[string][]scim.Ref{}
+	if withMembers && len(groups) > 0 {
+		ids := make([]string, 0, len(groups))
+		for _, g := range groups {
+			ids = append(ids, g.ID)
+		}
+		var err error
+		if members, err = s.scimGroupMembers(ids); err != nil {
+			return nil, 0, err
+		}
+	}
+	out := make([]*scim.Group, 0, len(groups))
+	for i := range groups {
+		out = append(out, scimGroupResource(&groups[i], members[groups[i].ID]))
+	}
+	return out, total, nil
+}
+
+func (s *KYCService) scimGroup(db *gorm.DB, orgID, groupID string) (*models.SCIMGroup, error) {
+	var g models.SCIMGroup
+	if err := db.Where("id = ? AND org_id = ?", groupID, orgID).First(&g).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scimNotFound("Group", groupID)
+		}
+		return nil, err
+	}
+	return &g, nil
+}
+
+// GetSCIMGroup 单个组
+func (s *KYCService) GetSCIMGroup(orgID, groupID string, withMembers bool) (*scim.Group, error) {
+	g, err := s.scimGroup(s.DB, orgID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	var members []scim.Ref
+	if withMembers {
+		m, err := s.scimGroupMembers([]string{g.ID})
+		if err != nil {
+			return nil, err
+		}
+		members = m[g.ID]
+	}
+	return scimGroupResource(g, members), nil
+}
+
+// setSCIMGroupMembers 将组成员替换为 refs（必须均为组织成员），并重新计算受影响成员的角色
+func (s *KYCService) setSCIMGroupMembers(tx *gorm.DB, g *models.SCIMGroup, refs []scim.Ref) error {
+	want := map[string]bool{}
+	for _, r := range refs {
+		if r.Value != "" {
+			want[r.Value] = true
+		}
+	}
+	ids := make([]string, 0, len(want))
+	for id := range want {
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		var n int64
+		if err := tx.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id IN ?", g.OrgID, ids).Count(&n).Error; err != nil {
+			return err
+		}
+		if int(n) != len(ids) {
+			return scim.NewError(400, scim.ErrInvalidValue, "group members must be users of this organization")
+		}
+	}
+	var current []string
+	if err := tx.Model(&models.SCIMGroupMember{}).Where("group_id = ?", g.ID).Pluck("user_id", &current).Error; err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	var removed, affected []string
+	for _, id := range current {
+		have[id] = true
+		if !want[id] {
+			removed = append(removed, id)
+			affected = append(affected, id)
+		}
+	}
+	if len(removed) > 0 {
+		if err := tx.Where("group_id = ? AND user_id IN ?", g.ID, removed).Delete(&models.SCIMGroupMember{}).Error; err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		if have[id] {
+			continue
+		}
+		if err := tx.Create(&models.SCIMGroupMember{GroupID: g.ID, UserID: id}).Error; err != nil {
+			return err
+		}
+		affected = append(affected, id)
+	}
+	if g.Role == "" {
+		return nil
+	}
+	return s.syncSCIMRoles(tx, g.OrgID, affected)
+}
+
+func (s *KYCService) checkSCIMGroupName(tx *gorm.DB, orgID, groupID, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return scim.NewError(400, scim.ErrInvalidValue, "displayName is required")
+	}
+	var n int64
+	if err := tx.Model(&models.SCIMGroup{}).Where("org_id = ? AND id <> ? AND LOWER(display_name) = ?", orgID, groupID, strings.ToLower(name)).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return scim.NewError(409, scim.ErrUniqueness, "a group with this displayName already exists")
+	}
+	return nil
+}
+
+// CreateSCIMGroup 创建组；新组没有映射角色，需组织管理员在控制台配置
+func (s *KYCService) CreateSCIMGroup(orgID string, in *scim.Group) (*scim.Group, error) {
+	g := &models.SCIMGroup{ID: utils.GenerateID(), OrgID: orgID, DisplayName: strings.TrimSpace(in.DisplayName), ExternalID: in.ExternalID}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkSCIMGroupName(tx, orgID, g.ID, g.DisplayName); err != nil {
+			return err
+		}
+		if err := tx.Create(g).Error; err != nil {
+			return err
+		}
+		return s.setSCIMGroupMembers(tx, g, in.Members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSCIMGroup(orgID, g.ID, true)
+}
+
+// ReplaceSCIMGroup 整体更新组（含成员）
+func (s *KYCService) ReplaceSCIMGroup(orgID, groupID string, in *scim.Group) (*scim.Group, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		g, err := s.scimGroup(tx, orgID, groupID)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSpace(in.DisplayName)
+		if err := s.checkSCIMGroupName(tx, orgID, g.ID, name); err != nil {
+			return err
+		}
+		if err := tx.Model(g).Updates(map[string]interface{}{"display_name": name, "external_id": in.ExternalID}).Error; err != nil {
+			return err
+		}
+		return s.setSCIMGroupMembers(tx, g, in.Members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSCIMGroup(orgID, groupID, true)
+}
+
+// PatchSCIMGroup 按 PATCH 操作更新组
+func (s *KYCService) PatchSCIMGroup(orgID, groupID string, ops []scim.PatchOp) (*scim.Group, error) {
+	current, err := s.GetSCIMGroup(orgID, groupID, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := current.ApplyPatch(ops); err != nil {
+		return nil, err
+	}
+	return s.ReplaceSCIMGroup(orgID, groupID, current)
+}
+
+// DeleteSCIMGroup 删除组并重新计算原成员的角色
+func (s *KYCService) DeleteSCIMGroup(orgID, groupID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		g, err := s.scimGroup(tx, orgID, groupID)
+		if err != nil {
+			return err
+		}
+		var members []string
+		if err := tx.Model(&models.SCIMGroupMember{}).Where("group_id = ?", g.ID).Pluck("user_id", &members).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", g.ID).Delete(&models.SCIMGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(g).Error; err != nil {
+			return err
+		}
+		if g.Role == "" {
+			return nil
+		}
+		return s.syncSCIMRoles(tx, orgID, members)
+	})
+}
+
+// ListSCIMGroupMappings 组织管理员查看 SCIM 组及其映射角色
+func (s *KYCService) ListSCIMGroupMappings(orgID string) ([]models.SCIMGroup, error) {
+	var groups []models.SCIMGroup
+	err := s.DB.Where("org_id = ?", orgID).Order("display_name").Find(&groups).Error
+	return groups, err
+}
+
+// SetSCIMGroupRole 设置组映射的角色（空串表示取消映射），并重新计算组成员的角色
+func (s *KYCService) SetSCIMGroupRole(orgID, groupID, role string) (*models.SCIMGroup, error) {
+	var g models.SCIMGroup
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND org_id = ?", groupID, orgID).First(&g).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&g).Update("role", role).Error; err != nil {
+			return err
+		}
+		g.Role = role
+		var members []string
+		if err := tx.Model(&models.SCIMGroupMember{}).Where("group_id = ?", g.ID).Pluck("user_id", &members).Error; err != nil {
+			return err
+		}
+		return s.syncSCIMRoles(tx, orgID, members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyc-service/internal/config"
+	"kyc-service/internal/models"
+	"kyc-service/pkg/scim"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestHighestSCIMRole(t *testing.T) {
+	assert.Equal(t, "", highestSCIMRole(nil))
+	assert.Equal(t, "developer", highestSCIMRole([]string{"viewer", "developer"}))
+	assert.Equal(t, "admin", highestSCIMRole([]string{"developer", "admin", "viewer"}))
+	// 所有者等未知角色不会通过组映射授予
+	assert.Equal(t, "viewer", highestSCIMRole([]string{"owner", "viewer"}))
+}
+
+// newSCIMTestService 使用内存 SQLite 的服务，只迁移 SCIM 相关的表
+func newSCIMTestService(t *testing.T) *KYCService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		Logger:                                   gormlogger.Discard,
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.OrganizationMember{}, &models.OrgDomain{},
+		&models.SCIMGroup{}, &models.SCIMGroupMember{}, &models.OAuthToken{}, &models.TokenRevocation{}, &models.ConsoleSession{}))
+	return &KYCService{DB: db, Config: &config.Config{}}
+}
+
+func requireSCIMStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var se *scim.Error
+	require.ErrorAs(t, err, &se)
+	assert.Equal(t, status, se.HTTPStatus())
+}
+
+func memberRole(t *testing.T, s *KYCService, orgID, userID string) string {
+	t.Helper()
+	var m models.OrganizationMember
+	require.NoError(t, s.DB.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&m).Error)
+	return m.Role
+}
+
+func TestCreateSCIMUser(t *testing.T) {
+	s := newSCIMTestService(t)
+
+	u, err := s.CreateSCIMUser("org1", &scim.User{UserName: "Alice@Acme.com", DisplayName: "Alice", ExternalID: "ext-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice@acme.com", u.UserName)
+	assert.True(t, u.IsActive())
+	assert.Equal(t, defaultSCIMRole, memberRole(t, s, "org1", u.ID))
+
+	_, err = s.CreateSCIMUser("org1", &scim.User{UserName: "alice@acme.com"})
+	requireSCIMStatus(t, err, 409)
+
+	_, err = s.CreateSCIMUser("org1", &scim.User{UserName: "not-an-email"})
+	requireSCIMStatus(t, err, 400)
+}
+
+func TestCreateSCIMUserExistingAccount(t *testing.T) {
+	s := newSCIMTestService(t)
+	victim, err := s.CreateSCIMUser("org1", &scim.User{UserName: "bob@acme.com"})
+	require.NoError(t, err)
+
+	// 未验证邮箱域名的组织不能把已有账号拉入组织
+	_, err = s.CreateSCIMUser("org2", &scim.User{UserName: "bob@acme.com"})
+	requireSCIMStatus(t, err, 409)
+	var n int64
+	require.NoError(t, s.DB.Model(&models.OrganizationMember{}).Where("organization_id = ?", "org2").Count(&n).Error)
+	assert.Zero(t, n)
+
+	// 只认领未验证同样不行
+	now := time.Now()
+	require.NoError(t, s.DB.Create(&models.OrgDomain{ID: "d1", OrgID: "org2", Domain: "acme.com", Token: "t"}).Error)
+	_, err = s.CreateSCIMUser("org2", &scim.User{UserName: "bob@acme.com"})
+	requireSCIMStatus(t, err, 409)
+
+	require.NoError(t, s.DB.Model(&models.OrgDomain{}).Where("id = ?", "d1").Update("verified_at", now).Error)
+	u, err := s.CreateSCIMUser("org2", &scim.User{UserName: "bob@acme.com", DisplayName: "Not Bob"})
+	require.NoError(t, err)
+	assert.Equal(t, victim.ID, u.ID)
+	assert.Equal(t, defaultSCIMRole, memberRole(t, s, "org2", u.ID))
+
+	// 账号资料与主组织不受影响
+	var user models.User
+	require.NoError(t, s.DB.First(&user, "id = ?", u.ID).Error)
+	assert.Equal(t, "org1", user.OrgID)
+	assert.Equal(t, "bob", user.Name)
+}
+
+func TestSCIMGroupRoleSync(t *testing.T) {
+	s := newSCIMTestService(t)
+	alice, err := s.CreateSCIMUser("org1", &scim.User{UserName: "alice@acme.com"})
+	require.NoError(t, err)
+	bob, err := s.CreateSCIMUser("org1", &scim.User{UserName: "bob@acme.com"})
+	require.NoError(t, err)
+	require.NoError(t, s.DB.Create(&models.OrganizationMember{ID: "m-owner", OrganizationID: "org1", UserID: "owner", Role: "owner", Status: "active"}).Error)
+
+	admins, err := s.CreateSCIMGroup("org1", &scim.Group{DisplayName: "Admins", Members: []scim.Ref{{Value: alice.ID}, {Value: "owner"}}})
+	require.NoError(t, err)
+	devs, err := s.CreateSCIMGroup("org1", &scim.Group{DisplayName: "Devs", Members: []scim.Ref{{Value: alice.ID}, {Value: bob.ID}}})
+	require.NoError(t, err)
+	// 未映射角色的组不影响成员角色
+	assert.Equal(t, defaultSCIMRole, memberRole(t, s, "org1", alice.ID))
+
+	_, err = s.SetSCIMGroupRole("org1", admins.ID, "admin")
+	require.NoError(t, err)
+	_, err = s.SetSCIMGroupRole("org1", devs.ID, "developer")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", memberRole(t, s, "org1", alice.ID))
+	assert.Equal(t, "developer", memberRole(t, s, "org1", bob.ID))
+	assert.Equal(t, "owner", memberRole(t, s, "org1", "owner"))
+
+	// 移出管理员组后降为其余组中最高的角色
+	_, err = s.ReplaceSCIMGroup("org1", admins.ID, &scim.Group{DisplayName: "Admins", Members: []scim.Ref{{Value: "owner"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "developer", memberRole(t, s, "org1", alice.ID))
+
+	// 删除组后不属于任何已映射组的成员回到默认角色
+	require.NoError(t, s.DeleteSCIMGroup("org1", devs.ID))
+	assert.Equal(t, defaultSCIMRole, memberRole(t, s, "org1", alice.ID))
+	assert.Equal(t, defaultSCIMRole, memberRole(t, s, "org1", bob.ID))
+
+	// 组成员必须是本组织成员
+	_, err = s.CreateSCIMGroup("org1", &scim.Group{DisplayName: "Outsiders", Members: []scim.Ref{{Value: "stranger"}}})
+	requireSCIMStatus(t, err, 400)
+}
+
+func TestSCIMDeprovisioning(t *testing.T) {
+	s := newSCIMTestService(t)
+	ctx := context.Background()
+	u, err := s.CreateSCIMUser("org1", &scim.User{UserName: "carol@acme.com"})
+	require.NoError(t, err)
+	g, err := s.CreateSCIMGroup("org1", &scim.Group{DisplayName: "Staff", Members: []scim.Ref{{Value: u.ID}}})
+	require.NoError(t, err)
+	require.NoError(t, s.DB.Create(&models.OAuthToken{AccessToken: "at1", RefreshToken: "rt1", UserID: u.ID, OrgID: "org1"}).Error)
+	require.NoError(t, s.DB.Create(&models.ConsoleSession{ID: "sess1", UserID: u.ID}).Error)
+
+	inactive := false
+	out, err := s.ReplaceSCIMUser(ctx, "org1", u.ID, &scim.User{UserName: u.UserName, Active: &inactive})
+	require.NoError(t, err)
+	assert.False(t, out.IsActive())
+	var tok models.OAuthToken
+	require.NoError(t, s.DB.First(&tok, "access_token = ?", "at1").Error)
+	assert.NotNil(t, tok.RevokedAt)
+	var sess models.ConsoleSession
+	require.NoError(t, s.DB.First(&sess, "id = ?", "sess1").Error)
+	assert.NotNil(t, sess.RevokedAt)
+
+	require.NoError(t, s.DeleteSCIMUser(ctx, "org1", u.ID))
+	_, err = s.GetSCIMUser("org1", u.ID)
+	requireSCIMStatus(t, err, 404)
+	var n int64
+	require.NoError(t, s.DB.Model(&models.SCIMGroupMember{}).Where("group_id = ?", g.ID).Count(&n).Error)
+	assert.Zero(t, n)
+	// 账号本身保留
+	require.NoError(t, s.DB.Model(&models.User{}).Where("id = ?", u.ID).Count(&n).Error)
+	assert.EqualValues(t, 1, n)
+
+	require.NoError(t, s.DB.Create(&models.OrganizationMember{ID: "m-owner", OrganizationID: "org1", UserID: "owner", Role: "owner", Status: "active"}).Error)
+	require.NoError(t, s.DB.Create(&models.User{ID: "owner", Email: "owner@acme.com"}).Error)
+	_, err = s.ReplaceSCIMUser(ctx, "org1", "owner", &scim.User{Active: &inactive})
+	requireSCIMStatus(t, err, 403)
+	requireSCIMStatus(t, s.DeleteSCIMUser(ctx, "org1", "owner"), 403)
+}
@@ -0,0 +1,827 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/jwtkeys"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/oidc"
+	"kyc-service/pkg/saml"
+	"kyc-service/pkg/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// SSO 协议
+const (
+	SSOProtocolOIDC = "oidc"
+	SSOProtocolSAML = "saml"
+)
+
+const (
+	defaultSSORole        = "viewer"
+	defaultSSOStateTTL    = 10 * time.Minute
+	defaultSSOExchangeTTL = time.Minute
+	defaultSSOHTTPTimeout = 10 * time.Second
+	defaultSSOProviderTTL = time.Hour
+	// ssoKeyRefreshInterval ID Token 验签失败时重新拉取 JWKS 的最小间隔（应对 IdP 轮换密钥）
+	ssoKeyRefreshInterval = time.Minute
+	ssoMaxMetadataSize    = 1 << 20
+
+	ssoStatePrefix     = "sso:state:"
+	ssoCodePrefix      = "sso:code:"
+	ssoAssertionPrefix = "sso:assertion:"
+
+	// SSO 相关路由，用于生成回调地址与 SP 标识
+	SSOOIDCCallbackPath  = "/api/v1/auth/sso/oidc/callback"
+	SSOSAMLACSPath       = "/api/v1/auth/sso/saml/acs"
+	SSOSAMLMetadataPath  = "/api/v1/auth/sso/saml/metadata/"
+	ssoDefaultOIDCScopes = "openid email profile"
+)
+
+var (
+	ErrSSONotConfigured        = errors.New("sso is not configured for this organization")
+	ErrSSOBaseURLMissing       = errors.New("security.sso.base_url is not configured")
+	ErrSSOUnavailable          = errors.New("sso login requires redis")
+	ErrSSOStateInvalid         = errors.New("sso state invalid or expired")
+	ErrSSODomainNotAllowed     = errors.New("email domain is not allowed for this organization")
+	ErrSSOEmailUnverified      = errors.New("identity provider did not verify the email address")
+	ErrSSOAccountNotMember     = errors.New("existing account is not a member of this organization")
+	ErrSSOProvisioningDisabled = errors.New("just-in-time provisioning is disabled for this organization")
+	ErrSSOUserInactive         = errors.New("user or membership is inactive")
+	ErrSSOAssertionReplay      = errors.New("saml assertion has already been used")
+	ErrSSOIdPDenied            = errors.New("identity provider denied the login")
+	ErrSSOInsecureURL          = errors.New("identity provider url must use https and resolve to a public address")
+	ErrSSOEncryptionRequired   = errors.New("encryption key is required to store the oidc client secret")
+)
+
+// SSOConfigError 配置校验失败，Message 可直接返回给调用方
+type SSOConfigError struct {
+	Message string
+}
+
+func (e *SSOConfigError) Error() string { return e.Message }
+
+// SSOConfigInput 组织管理员提交的 SSO 配置
+type SSOConfigInput struct {
+	Protocol         string   `json:"protocol" binding:"required,oneof=oidc saml"`
+	Enabled          bool     `json:"enabled"`
+	OIDCIssuer       string   `json:"oidc_issuer"`        // issuer 或发现文档地址
+	OIDCClientID     string   `json:"oidc_client_id"`     // IdP 中为本服务创建的客户端
+	OIDCClientSecret string   `json:"oidc_client_secret"` // 更新时留空表示保留原值
+	SAMLMetadataURL  string   `json:"saml_metadata_url"`  // 与 saml_metadata 二选一
+	SAMLMetadata     string   `json:"saml_metadata"`
+	AllowedDomains   []string `json:"allowed_domains" binding:"required,min=1"`
+	DefaultRole      string   `json:"default_role" binding:"omitempty,oneof=admin developer viewer"`
+	JITProvisioning  bool     `json:"jit_provisioning"`
+	EnforceSSO       bool     `json:"enforce_sso"`
+}
+
+// SSOIdentity IdP 登录成功后得到的身份
+type SSOIdentity struct {
+	OrgID    string
+	Protocol string
+	Subject  string
+	Email    string
+	Name     string
+}
+
+// ssoState 发起登录到 IdP 回调之间保存在 Redis 的上下文
+type ssoState struct {
+	OrgID     string `json:"org_id"`
+	Protocol  string `json:"protocol"`
+	Nonce     string `json:"nonce,omitempty"`
+	Verifier  string `json:"verifier,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ssoLoginCode 回调完成后换取控制台会话的一次性凭据
+type ssoLoginCode struct {
+	UserID string `json:"user_id"`
+	OrgID  string `json:"org_id"`
+}
+
+func (s *KYCService) ssoDuration(v, def time.Duration) time.Duration {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func (s *KYCService) ssoBaseURL() (string, error) {
+	base := strings.TrimRight(strings.TrimSpace(s.Config.Security.SSO.BaseURL), "/")
+	if base == "" {
+		return "", ErrSSOBaseURLMissing
+	}
+	return base, nil
+}
+
+// SSOConsoleURL 登录完成后重定向的控制台页面，未配置时返回空串
+func (s *KYCService) SSOConsoleURL() string {
+	return strings.TrimSpace(s.Config.Security.SSO.ConsoleURL)
+}
+
+// SSOStateTTL 发起登录到 IdP 回调的最长时间
+func (s *KYCService) SSOStateTTL() time.Duration {
+	return s.ssoDuration(s.Config.Security.SSO.StateTTL, defaultSSOStateTTL)
+}
+
+// SAMLServiceProvider 组织对应的 SP 标识：每个组织独立的 EntityID（即元数据地址），共用 ACS
+func (s *KYCService) SAMLServiceProvider(orgID string) (*saml.ServiceProvider, error) {
+	base, err := s.ssoBaseURL()
+	if err != nil {
+		return nil, err
+	}
+	return &saml.ServiceProvider{EntityID: base + SSOSAMLMetadataPath + orgID, ACSURL: base + SSOSAMLACSPath}, nil
+}
+
+func (s *KYCService) oidcRedirectURI() (string, error) {
+	base, err := s.ssoBaseURL()
+	if err != nil {
+		return "", err
+	}
+	return base + SSOOIDCCallbackPath, nil
+}
+
+// normalizeEmailDomains 校验并规范化邮箱域名列表（小写、去重、去掉前导 @），拒绝公共邮箱域名
+func normalizeEmailDomains(in []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, d := range in {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "@")
+		if d == "" {
+			continue
+		}
+		if !strings.Contains(d, ".") || strings.ContainsAny(d, " /@,") || strings.HasPrefix(d, ".") || strings.HasSuffix(d, ".") {
+			return nil, &SSOConfigError{Message: fmt.Sprintf("无效的邮箱域名: %s", d)}
+		}
+		if IsPublicMailDomain(d) {
+			return nil, &SSOConfigError{Message: fmt.Sprintf("不能使用公共邮箱域名: %s", d)}
+		}
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	if len(out) == 0 {
+		return nil, &SSOConfigError{Message: "至少需要一个邮箱域名"}
+	}
+	return out, nil
+}
+
+// emailDomain 邮箱的域名部分（小写）
+func emailDomain(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[i+1:]))
+}
+
+// SSOConfigDomains 配置的邮箱域名列表
+func SSOConfigDomains(cfg *models.OrgSSOConfig) []string {
+	if cfg.AllowedDomains == "" {
+		return nil
+	}
+	return strings.Split(cfg.AllowedDomains, ",")
+}
+
+func ssoDomainAllowed(cfg *models.OrgSSOConfig, email string) bool {
+	d := emailDomain(email)
+	if d == "" {
+		return false
+	}
+	for _, allowed := range SSOConfigDomains(cfg) {
+		if d == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIdPURL 校验组织管理员填写的 IdP 地址：必须为 https，IP 字面量必须为公网地址
+func checkIdPURL(raw string, allowInsecure bool) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return &SSOConfigError{Message: "无效的 IdP 地址"}
+	}
+	if allowInsecure {
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return &SSOConfigError{Message: "IdP 地址必须为 http(s)"}
+		}
+		return nil
+	}
+	if u.Scheme != "https" {
+		return ErrSSOInsecureURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !publicIP(ip) {
+		return ErrSSOInsecureURL
+	}
+	return nil
+}
+
+// ssoHTTPClient 访问 IdP 的客户端；未允许不安全地址时在建立连接前拒绝内网地址（覆盖 DNS 解析结果）
+func (s *KYCService) ssoHTTPClient() *http.Client {
+	cfg := s.Config.Security.SSO
+	timeout := s.ssoDuration(cfg.HTTPTimeout, defaultSSOHTTPTimeout)
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowInsecure {
+		dialer.Control = publicDialControl(ErrSSOInsecureURL)
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !cfg.AllowInsecure && req.URL.Scheme != "https" {
+				return ErrSSOInsecureURL
+			}
+			if len(via) >= 3 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// oidcProviderEntry 缓存的发现文档与验签公钥
+type oidcProviderEntry struct {
+	provider  *oidc.Provider
+	keys      []*jwtkeys.Key
+	fetchedAt time.Time
+	keysAt    time.Time
+}
+
+var oidcProviders = struct {
+	sync.Mutex
+	m map[string]*oidcProviderEntry
+}{m: map[string]*oidcProviderEntry{}}
+
+// oidcProvider 按发现地址缓存 IdP 元数据与 JWKS；refreshKeys 为 true 时重新拉取 JWKS（受最小间隔限制）
+func (s *KYCService) oidcProvider(ctx context.Context, issuer string, refreshKeys bool) (*oidcProviderEntry, error) {
+	key := oidc.DiscoveryURL(issuer)
+	now := time.Now()
+	oidcProviders.Lock()
+	entry := oidcProviders.m[key]
+	oidcProviders.Unlock()
+	ttl := s.ssoDuration(s.Config.Security.SSO.ProviderTTL, defaultSSOProviderTTL)
+	client := s.ssoHTTPClient()
+	if entry == nil || now.Sub(entry.fetchedAt) > ttl {
+		p, err := oidc.Discover(ctx, client, issuer)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := p.FetchKeys(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		entry = &oidcProviderEntry{provider: p, keys: keys, fetchedAt: now, keysAt: now}
+	} else if refreshKeys && now.Sub(entry.keysAt) > ssoKeyRefreshInterval {
+		keys, err := entry.provider.FetchKeys(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		entry = &oidcProviderEntry{provider: entry.provider, keys: keys, fetchedAt: entry.fetchedAt, keysAt: now}
+	} else {
+		return entry, nil
+	}
+	oidcProviders.Lock()
+	oidcProviders.m[key] = entry
+	oidcProviders.Unlock()
+	return entry, nil
+}
+
+func forgetOIDCProvider(issuer string) {
+	oidcProviders.Lock()
+	delete(oidcProviders.m, oidc.DiscoveryURL(issuer))
+	oidcProviders.Unlock()
+}
+
+// fetchSAMLMetadata 拉取 IdP 元数据
+func (s *KYCService) fetchSAMLMetadata(ctx context.Context, metadataURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.ssoHTTPClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata url returned %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ssoMaxMetadataSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// GetOrgSSOConfig 组织的 SSO 配置，不存在时返回 gorm.ErrRecordNotFound
+func (s *KYCService) GetOrgSSOConfig(orgID string) (*models.OrgSSOConfig, error) {
+	var cfg models.OrgSSOConfig
+	if err := s.DB.Where("org_id = ?", orgID).First(&cfg).Error; err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// SaveOrgSSOConfig 校验并保存组织的 SSO 配置：OIDC 会请求发现文档与 JWKS，SAML 会解析（或拉取）元数据。
+// 配置中尚未认领的域名会创建待验证记录，完成 DNS 验证前不用于按邮箱路由与即时开通
+func (s *KYCService) SaveOrgSSOConfig(ctx context.Context, orgID, userID string, in SSOConfigInput) (*models.OrgSSOConfig, error) {
+	domains, err := normalizeEmailDomains(in.AllowedDomains)
+	if err != nil {
+		return nil, err
+	}
+	// 已被其他组织验证的域名不能再配置
+	for _, d := range domains {
+		owner, err := s.VerifiedOrgForDomain(d)
+		if err != nil {
+			return nil, err
+		}
+		if owner != "" && owner != orgID {
+			return nil, &SSOConfigError{Message: fmt.Sprintf("邮箱域名 %s 已被其他组织验证", d)}
+		}
+	}
+
+	cfg, err := s.GetOrgSSOConfig(orgID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		cfg = &models.OrgSSOConfig{ID: utils.GenerateID(), OrgID: orgID}
+	} else if err != nil {
+		return nil, err
+	}
+	previousIssuer := cfg.OIDCIssuer
+	cfg.Protocol = in.Protocol
+	cfg.Enabled = in.Enabled
+	cfg.AllowedDomains = strings.Join(domains, ",")
+	cfg.DefaultRole = in.DefaultRole
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = defaultSSORole
+	}
+	cfg.JITProvisioning = in.JITProvisioning
+	cfg.EnforceSSO = in.EnforceSSO
+	allowInsecure := s.Config.Security.SSO.AllowInsecure
+
+	switch in.Protocol {
+	case SSOProtocolOIDC:
+		in.OIDCIssuer = strings.TrimSpace(in.OIDCIssuer)
+		if in.OIDCIssuer == "" || strings.TrimSpace(in.OIDCClientID) == "" {
+			return nil, &SSOConfigError{Message: "OIDC 需要 issuer 与 client_id"}
+		}
+		if err := checkIdPURL(in.OIDCIssuer, allowInsecure); err != nil {
+			return nil, err
+		}
+		if in.OIDCClientSecret == "" && cfg.OIDCClientSecretEnc == "" {
+			return nil, &SSOConfigError{Message: "OIDC 需要 client_secret"}
+		}
+		forgetOIDCProvider(in.OIDCIssuer)
+		if _, err := s.oidcProvider(ctx, in.OIDCIssuer, false); err != nil {
+			return nil, &SSOConfigError{Message: fmt.Sprintf("无法读取 OIDC 发现文档: %v", err)}
+		}
+		if in.OIDCClientSecret != "" {
+			if s.Encryptor == nil {
+				return nil, ErrSSOEncryptionRequired
+			}
+			enc, err := s.Encryptor.Encrypt(in.OIDCClientSecret)
+			if err != nil {
+				return nil, err
+			}
+			cfg.OIDCClientSecretEnc = enc
+		}
+		cfg.OIDCIssuer = in.OIDCIssuer
+		cfg.OIDCClientID = strings.TrimSpace(in.OIDCClientID)
+		cfg.SAMLMetadataURL, cfg.SAMLMetadata, cfg.SAMLIdPEntityID = "", "", ""
+	case SSOProtocolSAML:
+		metadata := strings.TrimSpace(in.SAMLMetadata)
+		metadataURL := strings.TrimSpace(in.SAMLMetadataURL)
+		if metadata == "" && metadataURL == "" {
+			return nil, &SSOConfigError{Message: "SAML 需要元数据或元数据地址"}
+		}
+		if metadata == "" {
+			if err := checkIdPURL(metadataURL, allowInsecure); err != nil {
+				return nil, err
+			}
+			if metadata, err = s.fetchSAMLMetadata(ctx, metadataURL); err != nil {
+				return nil, &SSOConfigError{Message: fmt.Sprintf("无法获取 SAML 元数据: %v", err)}
+			}
+		}
+		md, err := saml.ParseMetadata([]byte(metadata))
+		if err != nil {
+			return nil, &SSOConfigError{Message: err.Error()}
+		}
+		cfg.SAMLMetadataURL = metadataURL
+		cfg.SAMLMetadata = metadata
+		cfg.SAMLIdPEntityID = md.EntityID
+		cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCClientSecretEnc = "", "", ""
+	}
+	if previousIssuer != "" && previousIssuer != cfg.OIDCIssuer {
+		forgetOIDCProvider(previousIssuer)
+	}
+	if err := s.ensureOrgDomainClaims(orgID, userID, domains); err != nil {
+		return nil, err
+	}
+	if err := s.DB.Save(cfg).Error; err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// DeleteOrgSSOConfig 删除组织的 SSO 配置（同时取消强制 SSO）
+func (s *KYCService) DeleteOrgSSOConfig(orgID string) error {
+	res := s.DB.Where("org_id = ?", orgID).Delete(&models.OrgSSOConfig{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindSSOConfigForEmail 按邮箱域名查找启用了 SSO 的组织；只使用已通过 DNS 验证的域名
+func (s *KYCService) FindSSOConfigForEmail(email string) (*models.OrgSSOConfig, error) {
+	d := emailDomain(email)
+	if d == "" {
+		return nil, ErrSSONotConfigured
+	}
+	orgID, err := s.VerifiedOrgForDomain(d)
+	if err != nil {
+		return nil, err
+	}
+	if orgID == "" {
+		return nil, ErrSSONotConfigured
+	}
+	cfg, err := s.EnabledSSOConfig(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !ssoDomainAllowed(cfg, email) {
+		return nil, ErrSSONotConfigured
+	}
+	return cfg, nil
+}
+
+// EnabledSSOConfig 组织已启用的 SSO 配置
+func (s *KYCService) EnabledSSOConfig(orgID string) (*models.OrgSSOConfig, error) {
+	cfg, err := s.GetOrgSSOConfig(orgID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !cfg.Enabled) {
+		return nil, ErrSSONotConfigured
+	}
+	return cfg, err
+}
+
+// SSORequiredFor 组织是否要求成员通过 SSO 访问；所有者不受限制，避免 IdP 故障时无法进入组织
+func (s *KYCService) SSORequiredFor(orgID, role string) bool {
+	if orgID == "" || role == "owner" {
+		return false
+	}
+	var n int64
+	if err := s.DB.Model(&models.OrgSSOConfig{}).
+		Where("org_id = ? AND enabled = ? AND enforce_sso = ?", orgID, true, true).Count(&n).Error; err != nil {
+		logger.GetLogger().WithError(err).Warn("查询组织SSO强制策略失败")
+		return false
+	}
+	return n > 0
+}
+
+// OrgWithoutSSORequirement 用户可以不经 SSO 进入的第一个组织，没有时返回空串
+func (s *KYCService) OrgWithoutSSORequirement(userID string) string {
+	var members []models.OrganizationMember
+	if err := s.DB.Where("user_id = ? AND status = ?", userID, "active").Order("created_at").Find(&members).Error; err != nil {
+		return ""
+	}
+	for _, m := range members {
+		if !s.SSORequiredFor(m.OrganizationID, m.Role) {
+			return m.OrganizationID
+		}
+	}
+	return ""
+}
+
+func randomURLToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// BeginSSOLogin 生成 IdP 登录地址，返回地址与 state（调用方需将 state 绑定到浏览器，回调时比对）
+func (s *KYCService) BeginSSOLogin(ctx context.Context, cfg *models.OrgSSOConfig) (string, string, error) {
+	if s.Redis == nil {
+		return "", "", ErrSSOUnavailable
+	}
+	state, err := randomURLToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	st := ssoState{OrgID: cfg.OrgID, Protocol: cfg.Protocol}
+	var loginURL string
+	switch cfg.Protocol {
+	case SSOProtocolOIDC:
+		redirectURI, err := s.oidcRedirectURI()
+		if err != nil {
+			return "", "", err
+		}
+		entry, err := s.oidcProvider(ctx, cfg.OIDCIssuer, false)
+		if err != nil {
+			return "", "", err
+		}
+		if st.Nonce, err = randomURLToken(24); err != nil {
+			return "", "", err
+		}
+		if st.Verifier, err = randomURLToken(48); err != nil {
+			return "", "", err
+		}
+		loginURL = entry.provider.AuthCodeURL(cfg.OIDCClientID, redirectURI, state, st.Nonce, st.Verifier, strings.Fields(ssoDefaultOIDCScopes))
+	case SSOProtocolSAML:
+		sp, err := s.SAMLServiceProvider(cfg.OrgID)
+		if err != nil {
+			return "", "", err
+		}
+		md, err := saml.ParseMetadata([]byte(cfg.SAMLMetadata))
+		if err != nil {
+			return "", "", err
+		}
+		if loginURL, st.RequestID, err = sp.AuthnRequestURL(md, state, time.Now()); err != nil {
+			return "", "", err
+		}
+	default:
+		return "", "", ErrSSONotConfigured
+	}
+	raw, _ := json.Marshal(st)
+	if err := s.Redis.Set(ctx, ssoStatePrefix+state, raw, s.SSOStateTTL()).Err(); err != nil {
+		return "", "", err
+	}
+	return loginURL, state, nil
+}
+
+// takeSSOState 取出并作废 state（一次性）
+func (s *KYCService) takeSSOState(ctx context.Context, state, protocol string) (*ssoState, error) {
+	if s.Redis == nil {
+		return nil, ErrSSOUnavailable
+	}
+	if state == "" {
+		return nil, ErrSSOStateInvalid
+	}
+	raw, err := s.Redis.GetDel(ctx, ssoStatePrefix+state).Result()
+	if err != nil {
+		return nil, ErrSSOStateInvalid
+	}
+	var st ssoState
+	if err := json.Unmarshal([]byte(raw), &st); err != nil || st.Protocol != protocol {
+		return nil, ErrSSOStateInvalid
+	}
+	return &st, nil
+}
+
+// AbortSSOLogin 作废 state（IdP 返回错误时调用）
+func (s *KYCService) AbortSSOLogin(ctx context.Context, state string) {
+	if s.Redis == nil || state == "" {
+		return
+	}
+	s.Redis.Del(ctx, ssoStatePrefix+state)
+}
+
+// CompleteOIDCLogin 处理授权码回调：换取令牌并校验 ID Token
+func (s *KYCService) CompleteOIDCLogin(ctx context.Context, state, code string) (*SSOIdentity, error) {
+	st, err := s.takeSSOState(ctx, state, SSOProtocolOIDC)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := s.EnabledSSOConfig(st.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Protocol != SSOProtocolOIDC {
+		return nil, ErrSSOStateInvalid
+	}
+	redirectURI, err := s.oidcRedirectURI()
+	if err != nil {
+		return nil, err
+	}
+	if s.Encryptor == nil {
+		return nil, ErrSSOEncryptionRequired
+	}
+	secret, err := s.Encryptor.Decrypt(cfg.OIDCClientSecretEnc)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt oidc client secret: %w", err)
+	}
+	entry, err := s.oidcProvider(ctx, cfg.OIDCIssuer, false)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := entry.provider.Exchange(ctx, s.ssoHTTPClient(), cfg.OIDCClientID, secret, code, redirectURI, st.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := oidc.VerifyIDToken(tr.IDToken, entry.keys, entry.provider.Issuer, cfg.OIDCClientID, st.Nonce)
+	if err != nil {
+		// IdP 可能已轮换签名密钥，重新拉取 JWKS 后再试一次
+		if refreshed, rerr := s.oidcProvider(ctx, cfg.OIDCIssuer, true); rerr == nil && refreshed != entry {
+			claims, err = oidc.VerifyIDToken(tr.IDToken, refreshed.keys, refreshed.provider.Issuer, cfg.OIDCClientID, st.Nonce)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return nil, ErrSSOEmailUnverified
+	}
+	id := &SSOIdentity{
+		OrgID:    cfg.OrgID,
+		Protocol: SSOProtocolOIDC,
+		Subject:  claims.Subject,
+		Email:    strings.ToLower(strings.TrimSpace(claims.Email)),
+		Name:     claims.DisplayName(),
+	}
+	if !ssoDomainAllowed(cfg, id.Email) {
+		return nil, ErrSSODomainNotAllowed
+	}
+	return id, nil
+}
+
+// CompleteSAMLLogin 处理 ACS 收到的 SAMLResponse（仅支持本服务发起的登录，RelayState 即 state）
+func (s *KYCService) CompleteSAMLLogin(ctx context.Context, relayState, samlResponse string) (*SSOIdentity, error) {
+	st, err := s.takeSSOState(ctx, relayState, SSOProtocolSAML)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := s.EnabledSSOConfig(st.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Protocol != SSOProtocolSAML {
+		return nil, ErrSSOStateInvalid
+	}
+	sp, err := s.SAMLServiceProvider(cfg.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	md, err := saml.ParseMetadata([]byte(cfg.SAMLMetadata))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	a, err := sp.ParseResponse(samlResponse, md, st.RequestID, now)
+	if err != nil {
+		return nil, err
+	}
+	// 同一断言只能使用一次
+	ttl := a.NotOnOrAfter.Sub(now) + saml.ClockSkew
+	if ok, err := s.Redis.SetNX(ctx, ssoAssertionPrefix+cfg.OrgID+":"+a.ID, 1, ttl).Result(); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrSSOAssertionReplay
+	}
+	id := &SSOIdentity{
+		OrgID:    cfg.OrgID,
+		Protocol: SSOProtocolSAML,
+		Subject:  a.NameID,
+		Email:    strings.ToLower(a.Email()),
+		Name:     a.DisplayName(),
+	}
+	if !ssoDomainAllowed(cfg, id.Email) {
+		return nil, ErrSSODomainNotAllowed
+	}
+	return id, nil
+}
+
+// ProvisionSSOUser 将 IdP 身份映射为本地用户：新邮箱在开启即时开通且域名已验证时创建用户并加入组织（默认角色）；
+// 已有账号必须已是该组织成员（通过邀请加入），不会自动关联，避免组织借助自有 IdP 接管其他账号。
+func (s *KYCService) ProvisionSSOUser(id *SSOIdentity) (*models.User, bool, error) {
+	cfg, err := s.EnabledSSOConfig(id.OrgID)
+	if err != nil {
+		return nil, false, err
+	}
+	var user models.User
+	err = s.DB.Where("LOWER(email) = ?", id.Email).First(&user).Error
+	if err == nil {
+		if user.Status != "active" {
+			return nil, false, ErrSSOUserInactive
+		}
+		var member models.OrganizationMember
+		if err := s.DB.Where("organization_id = ? AND user_id = ?", id.OrgID, user.ID).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, false, ErrSSOAccountNotMember
+			}
+			return nil, false, err
+		}
+		if member.Status != "active" {
+			return nil, false, ErrSSOUserInactive
+		}
+		return &user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	if !cfg.JITProvisioning {
+		return nil, false, ErrSSOProvisioningDisabled
+	}
+	// 只为组织已验证所有权的域名开通新账号
+	if owned, err := s.OrgOwnsEmailDomain(id.OrgID, id.Email); err != nil {
+		return nil, false, err
+	} else if !owned {
+		return nil, false, ErrOrgDomainUnverified
+	}
+
+	name := id.Name
+	if name == "" {
+		name = strings.Split(id.Email, "@")[0]
+	}
+	created, err := newProvisionedUser(id.Email, name, id.OrgID, cfg.DefaultRole)
+	if err != nil {
+		return nil, false, err
+	}
+	user = *created
+	now := time.Now()
+	member := models.OrganizationMember{
+		ID:             utils.GenerateID(),
+		OrganizationID: id.OrgID,
+		UserID:         user.ID,
+		Role:           cfg.DefaultRole,
+		Status:         "active",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &user, true, nil
+}
+
+// newProvisionedUser 由 IdP 开通的新账号（尚未保存）：随机密码，SSO 用户不使用密码登录，如需可通过找回密码设置
+func newProvisionedUser(email, name, orgID, role string) (*models.User, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(utils.GenerateID()+utils.GenerateID()), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &models.User{
+		ID:           utils.GenerateID(),
+		Email:        email,
+		Password:     string(hashed),
+		Name:         name,
+		FullName:     name,
+		Role:         "user",
+		OrgID:        orgID,
+		OrgRole:      role,
+		CurrentOrgID: orgID,
+		Status:       "active",
+	}, nil
+}
+
+// IssueSSOLoginCode 回调完成后签发一次性 code，控制台凭此换取会话（令牌不出现在重定向地址中）
+func (s *KYCService) IssueSSOLoginCode(ctx context.Context, userID, orgID string) (string, error) {
+	if s.Redis == nil {
+		return "", ErrSSOUnavailable
+	}
+	code, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	raw, _ := json.Marshal(ssoLoginCode{UserID: userID, OrgID: orgID})
+	ttl := s.ssoDuration(s.Config.Security.SSO.ExchangeTTL, defaultSSOExchangeTTL)
+	if err := s.Redis.Set(ctx, ssoCodePrefix+HashClientSecret(code), raw, ttl).Err(); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemSSOLoginCode 兑换一次性 code，返回用户与 SSO 组织
+func (s *KYCService) RedeemSSOLoginCode(ctx context.Context, code string) (string, string, error) {
+	if s.Redis == nil {
+		return "", "", ErrSSOUnavailable
+	}
+	raw, err := s.Redis.GetDel(ctx, ssoCodePrefix+HashClientSecret(code)).Result()
+	if err != nil {
+		return "", "", ErrSSOStateInvalid
+	}
+	var lc ssoLoginCode
+	if err := json.Unmarshal([]byte(raw), &lc); err != nil || lc.UserID == "" || lc.OrgID == "" {
+		return "", "", ErrSSOStateInvalid
+	}
+	return lc.UserID, lc.OrgID, nil
+}
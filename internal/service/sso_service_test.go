@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kyc-service/internal/config"
+	"kyc-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeEmailDomains(t *testing.T) {
+	got, err := normalizeEmailDomains([]string{" Example.COM ", "@example.com", "", "corp.example.org"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "corp.example.org"}, got)
+
+	for _, bad := range [][]string{{}, {" "}, {"localhost"}, {"a@b.com"}, {".example.com"}, {"example.com."}, {"a,b.com"}, {"Gmail.com"}, {"example.com", "qq.com"}} {
+		_, err := normalizeEmailDomains(bad)
+		var cfgErr *SSOConfigError
+		assert.True(t, errors.As(err, &cfgErr), "%v 应被拒绝", bad)
+	}
+}
+
+func TestSSODomainAllowed(t *testing.T) {
+	cfg := &models.OrgSSOConfig{AllowedDomains: "example.com,corp.example.org"}
+	assert.True(t, ssoDomainAllowed(cfg, "alice@Example.com"))
+	assert.True(t, ssoDomainAllowed(cfg, "bob@corp.example.org"))
+	// 子域名、后缀相同的其他域名都不算
+	assert.False(t, ssoDomainAllowed(cfg, "eve@mail.example.com"))
+	assert.False(t, ssoDomainAllowed(cfg, "eve@badexample.com"))
+	assert.False(t, ssoDomainAllowed(cfg, "eve@example.com.evil.io"))
+	assert.False(t, ssoDomainAllowed(cfg, "no-at-sign"))
+	assert.False(t, ssoDomainAllowed(&models.OrgSSOConfig{}, "alice@example.com"))
+}
+
+func TestCheckIdPURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		insecure bool
+		ok       bool
+	}{
+		{"https://idp.example.com/realms/acme", false, true},
+		{"https://8.8.8.8/", false, true},
+		{"http://idp.example.com", false, false},
+		{"https://127.0.0.1:8443", false, false},
+		{"https://10.0.0.5", false, false},
+		{"https://169.254.169.254/latest", false, false},
+		{"https://[::1]/", false, false},
+		{"ftp://idp.example.com", false, false},
+		{"not a url", false, false},
+		{"http://localhost:8180/realms/dev", true, true},
+		{"ftp://localhost", true, false},
+	}
+	for _, tt := range tests {
+		err := checkIdPURL(tt.raw, tt.insecure)
+		assert.Equal(t, tt.ok, err == nil, "%s (insecure=%t): %v", tt.raw, tt.insecure, err)
+	}
+}
+
+func TestSSOHTTPClientRejectsPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// 即便域名解析到内网地址，也会在建立连接前被拒绝
+	s := &KYCService{Config: &config.Config{}}
+	_, err := s.ssoHTTPClient().Get(srv.URL)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrSSOInsecureURL), err.Error())
+
+	s.Config.Security.SSO.AllowInsecure = true
+	resp, err := s.ssoHTTPClient().Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.False(t, publicIP(net.ParseIP("192.168.1.1")))
+	assert.False(t, publicIP(net.ParseIP("fe80::1")))
+	assert.True(t, publicIP(net.ParseIP("2606:4700::1111")))
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/textproto"
 	"path/filepath"
@@ -14,6 +16,7 @@ import (
 	"time"
 
 	"kyc-service/internal/config"
+	"kyc-service/pkg/breaker"
 	"kyc-service/pkg/logger"
 	"kyc-service/pkg/metrics"
 
@@ -69,6 +72,23 @@ func contentTypeFromFilename(name, fallback string) string {
 	return fallback
 }
 
+// upstreamError 将网络层错误归类为超时或不可用，供路由层切换供应商
+func upstreamError(msg string, err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %s: %w", ErrUpstreamTimeout, msg, err)
+	}
+	return fmt.Errorf("%w: %s: %w", ErrUpstreamUnavailable, msg, err)
+}
+
+// vendorError 供应商返回失败：5xx 视为不可用，其余视为业务拒绝（同时返回响应体）
+func vendorError(httpStatus int, msg string) error {
+	if httpStatus >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: %s", ErrUpstreamUnavailable, msg)
+	}
+	return fmt.Errorf("%w: %s", ErrVendorRejected, msg)
+}
+
 // OCRServiceResponse OCR服务响应
 type OCRServiceResponse struct {
 	Code    int    `json:"code"`
@@ -347,19 +367,19 @@ func (t *ThirdPartyService) CallFaceSearch(ctx context.Context, reader io.Reader
 		httpCode = "client_error"
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "face", "search", metrics.ResultHTTPClientError)
-		return nil, fmt.Errorf("人脸搜索服务请求失败: %w", err)
+		return nil, upstreamError("人脸搜索服务请求失败", err)
 	}
 	defer resp.Body.Close()
 	httpCode = fmt.Sprintf("%d", resp.StatusCode)
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, upstreamError("读取响应失败", err)
 	}
 
 	var raw map[string]interface{}
 	if err := json.Unmarshal(respBody, &raw); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+		return nil, fmt.Errorf("%w: 解析响应失败: %v", ErrUpstreamUnavailable, err)
 	}
 
 	var out FaceSearchResponse
@@ -400,7 +420,7 @@ func (t *ThirdPartyService) CallFaceSearch(ctx context.Context, reader io.Reader
 	if out.Code != 0 {
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "face", "search", metrics.ResultBusinessFailed)
-		return nil, fmt.Errorf("face search failed: code=%d msg=%s", out.Code, out.Msg)
+		return &out, vendorError(resp.StatusCode, fmt.Sprintf("face search failed: code=%d msg=%s", out.Code, out.Msg))
 	}
 	return &out, nil
 }
@@ -447,22 +467,22 @@ func (t *ThirdPartyService) CallFaceDetect(ctx context.Context, reader io.Reader
 		httpCode = "client_error"
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "face", "detect", metrics.ResultHTTPClientError)
-		return nil, fmt.Errorf("人脸检测服务请求失败: %w", err)
+		return nil, upstreamError("人脸检测服务请求失败", err)
 	}
 	defer resp.Body.Close()
 	httpCode = fmt.Sprintf("%d", resp.StatusCode)
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, upstreamError("读取响应失败", err)
 	}
 	var out FaceDetectResponse
 	if err := json.Unmarshal(respBody, &out); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+		return nil, fmt.Errorf("%w: 解析响应失败: %v", ErrUpstreamUnavailable, err)
 	}
 	if resp.StatusCode != http.StatusOK || out.Code != 0 {
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "face", "detect", metrics.ResultBusinessFailed)
-		return nil, fmt.Errorf("face detect failed: code=%d msg=%s", out.Code, out.Msg)
+		return &out, vendorError(resp.StatusCode, fmt.Sprintf("face detect failed: code=%d msg=%s", out.Code, out.Msg))
 	}
 	return &out, nil
 }
@@ -519,22 +539,22 @@ func (t *ThirdPartyService) CallFaceCompare(ctx context.Context, src io.Reader,
 		httpCode = "client_error"
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "face", "compare", metrics.ResultHTTPClientError)
-		return nil, fmt.Errorf("人脸比对服务请求失败: %w", err)
+		return nil, upstreamError("人脸比对服务请求失败", err)
 	}
 	defer resp.Body.Close()
 	httpCode = fmt.Sprintf("%d", resp.StatusCode)
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, upstreamError("读取响应失败", err)
 	}
 	var out FaceCompareResponse
 	if err := json.Unmarshal(respBody, &out); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+		return nil, fmt.Errorf("%w: 解析响应失败: %v", ErrUpstreamUnavailable, err)
 	}
 	if resp.StatusCode != http.StatusOK || out.Code != 0 {
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "face", "compare", metrics.ResultBusinessFailed)
-		return nil, fmt.Errorf("face compare failed: code=%d msg=%s", out.Code, out.Msg)
+		return &out, vendorError(resp.StatusCode, fmt.Sprintf("face compare failed: code=%d msg=%s", out.Code, out.Msg))
 	}
 	return &out, nil
 }
@@ -562,28 +582,28 @@ func (t *ThirdPartyService) CallLivenessSilent(ctx context.Context, picturePath,
 		httpCode = "client_error"
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "liveness", "silent", metrics.ResultHTTPClientError)
-		return nil, fmt.Errorf("静态活体服务请求失败: %w", err)
+		return nil, upstreamError("静态活体服务请求失败", err)
 	}
 	defer resp.Body.Close()
 	httpCode = fmt.Sprintf("%d", resp.StatusCode)
 	rb, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, upstreamError("读取响应失败", err)
 	}
 	var out LivenessSilentResponse
 	if err := json.Unmarshal(rb, &out); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+		return nil, fmt.Errorf("%w: 解析响应失败: %v", ErrUpstreamUnavailable, err)
 	}
 	if resp.StatusCode != http.StatusOK || out.Code != 0 {
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "liveness", "silent", metrics.ResultBusinessFailed)
-		return nil, fmt.Errorf("静态活体失败: code=%d msg=%s", out.Code, out.Msg)
+		return &out, vendorError(resp.StatusCode, fmt.Sprintf("静态活体失败: code=%d msg=%s", out.Code, out.Msg))
 	}
 	return &out, nil
 }
 
-// CallLivenessVideo 调用动态活体服务
-func (t *ThirdPartyService) CallLivenessVideo(ctx context.Context, videoPath, language string) (*LivenessVideoResponse, error) {
+// CallLivenessVideo 调用动态活体服务；action 非空时要求服务校验视频中完成的动作
+func (t *ThirdPartyService) CallLivenessVideo(ctx context.Context, videoPath, language, action string) (*LivenessVideoResponse, error) {
 	start := time.Now()
 	status := "success"
 	var httpCode string
@@ -592,6 +612,9 @@ func (t *ThirdPartyService) CallLivenessVideo(ctx context.Context, videoPath, la
 	}()
 	url := t.config.ThirdParty.LivenessVideo.URL
 	payload := map[string]string{"video_path": videoPath, "language": language}
+	if action != "" {
+		payload["action"] = action
+	}
 	b, _ := json.Marshal(payload)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(b))
 	if err != nil {
@@ -605,22 +628,22 @@ func (t *ThirdPartyService) CallLivenessVideo(ctx context.Context, videoPath, la
 		httpCode = "client_error"
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "liveness", "video", metrics.ResultHTTPClientError)
-		return nil, fmt.Errorf("动态活体服务请求失败: %w", err)
+		return nil, upstreamError("动态活体服务请求失败", err)
 	}
 	defer resp.Body.Close()
 	httpCode = fmt.Sprintf("%d", resp.StatusCode)
 	rb, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, upstreamError("读取响应失败", err)
 	}
 	var out LivenessVideoResponse
 	if err := json.Unmarshal(rb, &out); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+		return nil, fmt.Errorf("%w: 解析响应失败: %v", ErrUpstreamUnavailable, err)
 	}
 	if resp.StatusCode != http.StatusOK || out.Code != 0 {
 		status = "failed"
 		metrics.RecordThirdPartyError(ctx, "liveness", "video", metrics.ResultBusinessFailed)
-		return nil, fmt.Errorf("动态活体失败: code=%d msg=%s", out.Code, out.Msg)
+		return &out, vendorError(resp.StatusCode, fmt.Sprintf("动态活体失败: code=%d msg=%s", out.Code, out.Msg))
 	}
 	return &out, nil
 }
@@ -720,13 +743,16 @@ func (t *ThirdPartyService) CallLivenessService(ctx context.Context, action stri
 	return &result, nil
 }
 
-// RetryWithBackoff 带退避重试的辅助函数
+// RetryWithBackoff 带退避重试的辅助函数；熔断或并发受限时不再重试
 func (t *ThirdPartyService) RetryWithBackoff(ctx context.Context, fn func() error, maxRetries int) error {
 	var err error
 	for i := 0; i < maxRetries; i++ {
 		if err = fn(); err == nil {
 			return nil
 		}
+		if errors.Is(err, breaker.ErrOpen) || errors.Is(err, breaker.ErrTooManyRequests) {
+			return err
+		}
 
 		// 计算退避时间
 		backoff := time.Duration(i+1) * time.Second
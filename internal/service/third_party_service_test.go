@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"kyc-service/internal/config"
+	"kyc-service/pkg/httpclient"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/mockvendor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThirdPartyServiceAgainstMockVendor(t *testing.T) {
+	srv := mockvendor.NewTestServer(mockvendor.Options{})
+	defer srv.Close()
+
+	cfg := &config.Config{}
+	cfg.ThirdParty.FaceService.URL = srv.URL
+	cfg.ThirdParty.LivenessSlient.URL = srv.URL + mockvendor.PathLivenessSilent
+	tp := NewThirdPartyService(cfg)
+	ctx := context.Background()
+
+	cmp, err := tp.CallFaceCompare(ctx, bytes.NewReader([]byte("face")), "a.jpg", bytes.NewReader([]byte("face")), "b.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, 1, cmp.ComparisonResults.IsSameFace)
+	assert.Equal(t, 0.99, cmp.ComparisonResults.Confidence)
+	assert.Equal(t, []string{"a.jpg", "b.jpg"}, cmp.Filename)
+
+	search, err := tp.CallFaceSearch(ctx, bytes.NewReader([]byte("face")), "a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, 1, search.SearchingResults.HasSimilarPicture)
+	assert.Len(t, search.SearchingResults.SearchedSimilarPictures, 1)
+
+	live, err := tp.CallLivenessSilent(ctx, "/data/ingest/mock_mismatch.jpg", "en")
+	require.NoError(t, err)
+	assert.Equal(t, 0, live.LivenessResults.IsLiveness)
+
+	_, err = tp.CallLivenessSilent(ctx, "/data/ingest/mock_500.jpg", "en")
+	assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+}
+
+func TestOCRAgainstMockVendor(t *testing.T) {
+	srv := mockvendor.NewTestServer(mockvendor.Options{Fixtures: []mockvendor.Fixture{
+		{Path: mockvendor.PathOCR, Match: "passport", Body: []byte(`{"code":0,"msg":"ok","parsing_results":{"name":{"text":"Li Si","confidence":0.9}}}`)},
+	}})
+	defer srv.Close()
+
+	s := &KYCService{}
+	client := httpclient.New(httpclient.Config{Timeout: 300 * time.Millisecond, Logger: logger.GetLogger()})
+	ctx := context.Background()
+
+	res, err := s.callOCRHTTP(ctx, client, srv.URL+mockvendor.PathOCR, bytes.NewReader([]byte("img")), "id.jpg", "id_card", "")
+	require.NoError(t, err)
+	assert.Equal(t, mockvendor.DefaultIDNumber, res.ParsingResults["id_number"].Text)
+
+	res, err = s.callOCRHTTP(ctx, client, srv.URL+mockvendor.PathOCR, bytes.NewReader([]byte("img")), "passport.jpg", "passport", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Li Si", res.ParsingResults["name"].Text)
+
+	_, err = s.callOCRHTTP(ctx, client, srv.URL+mockvendor.PathOCR, bytes.NewReader([]byte("img")), "mock_timeout.jpg", "id_card", "")
+	assert.ErrorIs(t, err, ErrUpstreamTimeout)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm/clause"
+)
+
+// 批量吊销的维度
+const (
+	RevocationByClient = "client"
+	RevocationByUser   = "user"
+	RevocationByOrg    = "org"
+)
+
+// tokenRevocationWatermarkTTL 批量吊销水位线的保留时间，须大于任何令牌的最长有效期（OAuth 刷新令牌7天）
+const tokenRevocationWatermarkTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRevocationScope = errors.New("invalid revocation scope")
+	// ErrRevocationStoreUnavailable 吊销记录未能写入任何存储，调用方不能报告吊销成功
+	ErrRevocationStoreUnavailable = errors.New("token revocation store unavailable")
+)
+
+func revokedJTIKey(jti string) string { return "revoked:jti:" + jti }
+
+func revokedWatermarkKey(kind, id string) string { return "revoked:" + kind + ":" + id }
+
+// saveRevocation 写入吊销记录：先持久化到库（Redis 不可用时的判断依据），再写 Redis 供认证中间件快速查询。
+// watermark 为 0 表示单令牌吊销
+func (s *KYCService) saveRevocation(ctx context.Context, key string, watermark int64, ttl time.Duration) error {
+	if s.DB == nil && s.Redis == nil {
+		return ErrRevocationStoreUnavailable
+	}
+	now := time.Now()
+	if s.DB != nil {
+		rec := models.TokenRevocation{ID: key, Watermark: watermark, ExpiresAt: now.Add(ttl), CreatedAt: now}
+		if err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"watermark", "expires_at"}),
+		}).Create(&rec).Error; err != nil {
+			return err
+		}
+		_ = s.DB.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.TokenRevocation{}).Error
+	}
+	if s.Redis != nil {
+		var val interface{} = 1
+		if watermark > 0 {
+			val = watermark
+		}
+		if err := s.Redis.Set(ctx, key, val, ttl).Err(); err != nil {
+			if s.DB == nil {
+				return err
+			}
+			logger.GetLogger().WithError(err).Warn("写入 Redis 吊销列表失败，按库内记录生效")
+		}
+	}
+	return nil
+}
+
+// RevokeJTI 将单个令牌加入吊销列表，保留到令牌过期；没有可用的吊销存储时返回错误
+func (s *KYCService) RevokeJTI(ctx context.Context, jti string, exp time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.saveRevocation(context.WithoutCancel(ctx), revokedJTIKey(jti), 0, ttl)
+}
+
+// RevokeTokenString 解析令牌并按 jti 吊销（签名无效或已过期的令牌无需处理）
+func (s *KYCService) RevokeTokenString(ctx context.Context, tokenString string) error {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, s.JWTKeyfunc); err != nil {
+		return nil
+	}
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil
+	}
+	return s.RevokeJTI(ctx, jti, exp.Time)
+}
+
+// RevokeTokensBy 批量吊销某客户端/用户/组织在此刻之前签发的全部令牌：
+// Redis 记录吊销水位线供各认证中间件比对 iat，同时标记库中的 OAuth 令牌并清理令牌缓存；
+// 按用户吊销时一并终止其控制台会话。
+func (s *KYCService) RevokeTokensBy(ctx context.Context, kind, id, reason string) error {
+	if id == "" {
+		return nil
+	}
+	column := ""
+	switch kind {
+	case RevocationByClient:
+		column = "client_id"
+	case RevocationByUser:
+		column = "user_id"
+	case RevocationByOrg:
+		column = "org_id"
+	default:
+		return ErrInvalidRevocationScope
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := s.saveRevocation(ctx, revokedWatermarkKey(kind, id), time.Now().Unix(), tokenRevocationWatermarkTTL); err != nil {
+		return err
+	}
+	var tokens []models.OAuthToken
+	_ = s.DB.Select("client_id", "scopes").Where(column+" = ? AND revoked_at IS NULL AND user_id = ''", id).Find(&tokens).Error
+	if err := s.DB.Model(&models.OAuthToken{}).Where(column+" = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	if s.Redis != nil {
+		for _, t := range tokens {
+			_ = s.Redis.Del(ctx, "oauth:token:"+t.ClientID+":"+t.Scopes).Err()
+		}
+	}
+	if kind == RevocationByUser {
+		if _, err := s.RevokeConsoleSessions(id, "", reason); err != nil {
+			return err
+		}
+	}
+	logger.GetLogger().Warnf("令牌已批量吊销: %s=%s reason=%s", kind, id, reason)
+	return nil
+}
+
+// IsTokenRevoked 检查令牌是否在吊销列表中（按 jti），或签发时间早于其客户端/用户/组织的吊销水位线。
+// extraOrgIDs 为中间件解析出的当前组织（控制台会话的组织可能与令牌声明不同）。
+// 优先查询 Redis；Redis 未配置或查询失败时按库内吊销记录判断，两者都不可用时按已吊销处理。
+func (s *KYCService) IsTokenRevoked(ctx context.Context, claims jwt.MapClaims, extraOrgIDs ...string) bool {
+	keys := []string{}
+	if jti, _ := claims["jti"].(string); jti != "" {
+		keys = append(keys, revokedJTIKey(jti))
+	}
+	nJTI := len(keys)
+	if v, _ := claims["client_id"].(string); v != "" {
+		keys = append(keys, revokedWatermarkKey(RevocationByClient, v))
+	}
+	if v, _ := claims["user_id"].(string); v != "" {
+		keys = append(keys, revokedWatermarkKey(RevocationByUser, v))
+	}
+	orgID, _ := claims["org_id"].(string)
+	for _, v := range append([]string{orgID}, extraOrgIDs...) {
+		if v != "" {
+			keys = append(keys, revokedWatermarkKey(RevocationByOrg, v))
+		}
+	}
+	if len(keys) == 0 {
+		return false
+	}
+	iat := int64(0)
+	if t, err := claims.GetIssuedAt(); err == nil && t != nil {
+		iat = t.Unix()
+	}
+	// revokedBy 第 i 个键的记录是否使令牌失效；水位线所在秒内签发的令牌同样视为已吊销，宁可让用户重新登录
+	revokedBy := func(i int, watermark int64, ok bool) bool {
+		return i < nJTI || !ok || iat <= watermark
+	}
+
+	if s.Redis != nil {
+		vals, err := s.Redis.MGet(ctx, keys...).Result()
+		if err == nil {
+			for i, v := range vals {
+				if v == nil {
+					continue
+				}
+				str, _ := v.(string)
+				watermark, perr := strconv.ParseInt(str, 10, 64)
+				if revokedBy(i, watermark, perr == nil) {
+					return true
+				}
+			}
+			return false
+		}
+		logger.GetLogger().WithError(err).Warn("查询 Redis 吊销列表失败，改查库内记录")
+	}
+	if s.DB == nil {
+		logger.GetLogger().Error("没有可用的令牌吊销存储，按已吊销处理")
+		return true
+	}
+	var recs []models.TokenRevocation
+	if err := s.DB.WithContext(ctx).Where("id IN ? AND expires_at > ?", keys, time.Now()).Find(&recs).Error; err != nil {
+		logger.GetLogger().WithError(err).Error("查询库内吊销记录失败，按已吊销处理")
+		return true
+	}
+	index := make(map[string]int, len(keys))
+	for i, k := range keys {
+		index[k] = i
+	}
+	for _, r := range recs {
+		if revokedBy(index[r.ID], r.Watermark, true) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/jwtkeys"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestSignJWTAddsRevocationClaims(t *testing.T) {
+	key, err := jwtkeys.GenerateKey(jwtkeys.AlgES256, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	set := jwtkeys.NewSet(nil, "")
+	set.Replace([]*jwtkeys.Key{key})
+	s := &KYCService{JWTKeys: set, jwtAsymmetric: true}
+
+	tok, err := s.SignJWT(jwt.MapClaims{"user_id": "u1", "exp": time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tok, claims, s.JWTKeyfunc)
+	require.NoError(t, err)
+	assert.NotEmpty(t, claims["jti"], "签发时应补齐 jti 以支持单令牌吊销")
+	assert.NotNil(t, claims["iat"], "签发时应补齐 iat 以支持批量吊销")
+
+	// 显式提供的声明保持不变
+	tok, err = s.SignJWT(jwt.MapClaims{"jti": "fixed", "iat": int64(100)})
+	require.NoError(t, err)
+	claims = jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(tok, claims)
+	require.NoError(t, err)
+	assert.Equal(t, "fixed", claims["jti"])
+	assert.EqualValues(t, 100, claims["iat"])
+}
+
+func TestRevocationWithoutStore(t *testing.T) {
+	s := &KYCService{}
+	ctx := context.Background()
+	// 没有任何吊销存储时不能报告成功，检查按已吊销处理
+	assert.ErrorIs(t, s.RevokeJTI(ctx, "j1", time.Now().Add(time.Minute)), ErrRevocationStoreUnavailable)
+	assert.True(t, s.IsTokenRevoked(ctx, jwt.MapClaims{"jti": "j1", "user_id": "u1"}))
+	assert.ErrorIs(t, s.RevokeTokensBy(ctx, "team", "x", ""), ErrInvalidRevocationScope)
+}
+
+func TestRevocationFallsBackToDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&models.TokenRevocation{}, &models.OAuthToken{}))
+	// Redis 不可达：写入与查询都失败，应以库内记录为准
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 50 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	s := &KYCService{DB: db, Redis: rdb}
+	ctx := context.Background()
+
+	require.NoError(t, s.RevokeJTI(ctx, "j1", time.Now().Add(time.Minute)))
+	assert.True(t, s.IsTokenRevoked(ctx, jwt.MapClaims{"jti": "j1"}))
+	assert.False(t, s.IsTokenRevoked(ctx, jwt.MapClaims{"jti": "j2"}))
+
+	iat := float64(time.Now().Add(-time.Minute).Unix())
+	require.NoError(t, s.RevokeTokensBy(ctx, RevocationByClient, "c1", "test"))
+	assert.True(t, s.IsTokenRevoked(ctx, jwt.MapClaims{"jti": "j3", "client_id": "c1", "iat": iat}))
+	assert.False(t, s.IsTokenRevoked(ctx, jwt.MapClaims{"jti": "j4", "client_id": "c1", "iat": float64(time.Now().Add(time.Minute).Unix())}))
+	assert.False(t, s.IsTokenRevoked(ctx, jwt.MapClaims{"jti": "j5", "client_id": "c2", "iat": iat}))
+
+	// 未配置 Redis 时同样按库内记录判断
+	s.Redis = nil
+	assert.True(t, s.IsTokenRevoked(ctx, jwt.MapClaims{"jti": "j1"}))
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"kyc-service/internal/models"
+	"kyc-service/pkg/logger"
+	"kyc-service/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Webhook 事件类型
+const (
+	WebhookEventKYCCompleted      = "kyc.completed"
+	WebhookEventOCRCompleted      = "ocr.completed"
+	WebhookEventFaceSearch        = "face.search.completed"
+	WebhookEventFaceCompare       = "face.compare.completed"
+	WebhookEventFaceDetect        = "face.detect.completed"
+	WebhookEventLivenessSilent    = "liveness.silent.completed"
+	WebhookEventLivenessVideo     = "liveness.video.completed"
+	WebhookEventLivenessAction    = "liveness.action.completed"
+	WebhookEventPing              = "webhook.ping"
+	webhookSignatureHeader        = "X-KYC-Signature"
+	webhookMaxAttempts            = 8
+	webhookBaseBackoff            = 10 * time.Second
+	webhookMaxBackoff             = time.Hour
+	webhookDeliveryTimeout        = 10 * time.Second
+	webhookSignatureToleranceSecs = 300
+)
+
+// WebhookEventTypes 可订阅的事件类型
+var WebhookEventTypes = []string{
+	WebhookEventKYCCompleted,
+	WebhookEventOCRCompleted,
+	WebhookEventFaceSearch,
+	WebhookEventFaceCompare,
+	WebhookEventFaceDetect,
+	WebhookEventLivenessSilent,
+	WebhookEventLivenessVideo,
+	WebhookEventLivenessAction,
+}
+
+// WebhookEvent 投递给客户的事件体
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	OrgID     string      `json:"org_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// KYCWebhookData KYC类事件的数据部分（不含PII）
+type KYCWebhookData struct {
+	RequestID   string `json:"request_id"`
+	RequestType string `json:"request_type"`
+	Status      string `json:"status"`
+	Message     string `json:"message,omitempty"`
+}
+
+// ErrWebhookURLNotPublic Webhook 地址指向回环、内网或链路本地地址
+var ErrWebhookURLNotPublic = errors.New("webhook url must resolve to a public address")
+
+// webhookHTTPClient 投递客户端：连接前按实际解析结果拒绝非公网地址，不跟随跳转
+var webhookHTTPClient = newWebhookHTTPClient()
+
+func newWebhookHTTPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookDeliveryTimeout, Control: publicDialControl(ErrWebhookURLNotPublic)}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookDeliveryTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// SignWebhookPayload 计算签名：hex(HMAC-SHA256(secret, "<timestamp>.<body>"))
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSignatureHeader 生成签名头：t=<timestamp>,v1=<signature>
+func WebhookSignatureHeader(secret string, timestamp int64, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, SignWebhookPayload(secret, timestamp, body))
+}
+
+// VerifyWebhookSignature 校验签名头（供接收方SDK与测试使用）
+func VerifyWebhookSignature(secret, header string, body []byte, now time.Time) bool {
+	var ts int64
+	var sig string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts, _ = strconv.ParseInt(kv[1], 10, 64)
+		case "v1":
+			sig = kv[1]
+		}
+	}
+	if ts == 0 || sig == "" {
+		return false
+	}
+	if d := now.Unix() - ts; d > webhookSignatureToleranceSecs || d < -webhookSignatureToleranceSecs {
+		return false
+	}
+	expected := SignWebhookPayload(secret, ts, body)
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
+
+// WebhookBackoff 第n次失败后的退避时间（指数增长，封顶1小时）
+func WebhookBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	d := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return d
+}
+
+// ValidateWebhookURL 校验Webhook地址：必须为 https，IP 字面量必须为公网地址（域名在连接时按解析结果检查）
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid webhook url")
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("webhook url must use https")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookURLNotPublic
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrWebhookURLNotPublic
+	}
+	return nil
+}
+
+// publicIP 是否为公网地址（拒绝回环、内网、链路本地等，防止借用户填写的地址探测内网）
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// publicDialControl 在建立连接前检查实际连接的地址（DNS 解析之后），非公网地址返回 reject
+func publicDialControl(reject error) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+			return reject
+		}
+		return nil
+	}
+}
+
+// GenerateWebhookSecret 生成Webhook签名密钥
+func GenerateWebhookSecret() string {
+	return "whsec_" + utils.GenerateAPISecret()
+}
+
+// WebhookSecret 解密Webhook签名密钥
+func (s *KYCService) WebhookSecret(w *models.Webhook) (string, error) {
+	if s.Encryptor == nil {
+		return "", fmt.Errorf("encryption key not configured")
+	}
+	return s.Encryptor.Decrypt(w.SecretEnc)
+}
+
+func webhookSubscribed(w *models.Webhook, eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// EmitWebhookEvent 为组织内订阅了该事件的端点创建投递记录，并立即尝试首次投递
+func (s *KYCService) EmitWebhookEvent(ctx context.Context, orgID, eventType string, data interface{}) {
+	if orgID == "" {
+		return
+	}
+	var hooks []models.Webhook
+	if err := s.DB.Where("org_id = ? AND status = ?", orgID, "active").Find(&hooks).Error; err != nil {
+		logger.GetLogger().WithError(err).Warn("查询Webhook失败")
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	ev := WebhookEvent{ID: "evt_" + utils.GenerateID(), Type: eventType, OrgID: orgID, CreatedAt: time.Now().UTC(), Data: data}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		logger.GetLogger().WithError(err).Warn("序列化Webhook事件失败")
+		return
+	}
+	for i := range hooks {
+		if !webhookSubscribed(&hooks[i], eventType) {
+			continue
+		}
+		d, err := s.enqueueWebhookDelivery(&hooks[i], ev.ID, eventType, payload)
+		if err != nil {
+			logger.GetLogger().WithError(err).Warn("创建Webhook投递记录失败")
+			continue
+		}
+		go func(id string) {
+			_ = s.AttemptWebhookDelivery(context.Background(), id)
+		}(d.ID)
+	}
+}
+
+// emitKYCEvent 发送不含PII的KYC完成事件
+func (s *KYCService) emitKYCEvent(ctx context.Context, eventType, requestID, requestType, status, message string) {
+	s.EmitWebhookEvent(ctx, getOrgID(ctx), eventType, KYCWebhookData{RequestID: requestID, RequestType: requestType, Status: status, Message: message})
+}
+
+// enqueueWebhookDelivery 创建投递记录；首次投递由调用方立即执行，
+// next_attempt_at 预留一个领取窗口，进程中途退出时由调度任务兜底
+func (s *KYCService) enqueueWebhookDelivery(w *models.Webhook, eventID, eventType string, payload []byte) (*models.WebhookDelivery, error) {
+	next := time.Now().Add(time.Minute)
+	d := &models.WebhookDelivery{
+		ID:            utils.GenerateID(),
+		WebhookID:     w.ID,
+		OrgID:         w.OrgID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        "pending",
+		NextAttemptAt: &next,
+	}
+	if err := s.DB.Create(d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SendTestWebhook 向指定端点发送 webhook.ping 事件
+func (s *KYCService) SendTestWebhook(ctx context.Context, w *models.Webhook) (*models.WebhookDelivery, error) {
+	ev := WebhookEvent{ID: "evt_" + utils.GenerateID(), Type: WebhookEventPing, OrgID: w.OrgID, CreatedAt: time.Now().UTC(), Data: map[string]string{"webhook_id": w.ID}}
+	payload, _ := json.Marshal(ev)
+	d, err := s.enqueueWebhookDelivery(w, ev.ID, ev.Type, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.AttemptWebhookDelivery(ctx, d.ID); err != nil {
+		logger.GetLogger().WithError(err).Warn("测试Webhook投递失败")
+	}
+	_ = s.DB.First(d, "id = ?", d.ID).Error
+	return d, nil
+}
+
+// RedeliverWebhook 将投递（通常为死信）重新放入队列
+func (s *KYCService) RedeliverWebhook(orgID, deliveryID string) error {
+	now := time.Now()
+	res := s.DB.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND org_id = ? AND status <> ?", deliveryID, orgID, "success").
+		Updates(map[string]interface{}{"status": "pending", "attempts": 0, "next_attempt_at": now, "updated_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DispatchDueWebhooks 投递到期的待发送记录，返回处理条数
+func (s *KYCService) DispatchDueWebhooks(ctx context.Context, limit int) int {
+	var ids []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(`SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= NOW() ORDER BY next_attempt_at LIMIT ? FOR UPDATE SKIP LOCKED`, limit).Scan(&ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		// 先推后下次时间，避免并发实例重复领取
+		return tx.Exec("UPDATE webhook_deliveries SET next_attempt_at = NOW() + INTERVAL '1 minute' WHERE id IN ?", ids).Error
+	})
+	if err != nil {
+		logger.GetLogger().WithError(err).Warn("领取Webhook投递任务失败")
+		return 0
+	}
+	for _, id := range ids {
+		_ = s.AttemptWebhookDelivery(ctx, id)
+	}
+	return len(ids)
+}
+
+// AttemptWebhookDelivery 执行一次投递，并根据结果更新状态/退避/死信
+func (s *KYCService) AttemptWebhookDelivery(ctx context.Context, deliveryID string) error {
+	var d models.WebhookDelivery
+	if err := s.DB.First(&d, "id = ?", deliveryID).Error; err != nil {
+		return err
+	}
+	if d.Status != "pending" {
+		return nil
+	}
+	var w models.Webhook
+	if err := s.DB.First(&w, "id = ?", d.WebhookID).Error; err != nil {
+		return s.markWebhookDead(&d, 0, "webhook not found")
+	}
+	if w.Status != "active" {
+		return s.markWebhookDead(&d, 0, "webhook disabled")
+	}
+	secret, err := s.WebhookSecret(&w)
+	if err != nil {
+		return s.markWebhookDead(&d, 0, "webhook secret unavailable")
+	}
+	// 早期创建的 http/内网地址不再投递
+	if err := ValidateWebhookURL(w.URL); err != nil {
+		return s.markWebhookDead(&d, 0, err.Error())
+	}
+
+	ts := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return s.markWebhookDead(&d, 0, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kyc-service-webhook/1.0")
+	req.Header.Set("X-KYC-Event", d.EventType)
+	req.Header.Set("X-KYC-Delivery", d.ID)
+	req.Header.Set("X-KYC-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set(webhookSignatureHeader, WebhookSignatureHeader(secret, ts, d.Payload))
+
+	d.Attempts++
+	resp, err := webhookHTTPClient.Do(req)
+	statusCode := 0
+	if err == nil {
+		statusCode = resp.StatusCode
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if statusCode >= 200 && statusCode < 300 {
+			now := time.Now()
+			return s.DB.Model(&d).Updates(map[string]interface{}{"status": "success", "attempts": d.Attempts, "last_status_code": statusCode, "last_error": "", "delivered_at": now, "next_attempt_at": nil, "updated_at": now}).Error
+		}
+		err = fmt.Errorf("unexpected status %d", statusCode)
+	}
+
+	logger.GetLogger().WithFields(logrus.Fields{
+		"webhook_id":  w.ID,
+		"delivery_id": d.ID,
+		"attempt":     d.Attempts,
+		"status_code": statusCode,
+	}).WithError(err).Warn("Webhook投递失败")
+
+	if d.Attempts >= webhookMaxAttempts {
+		return s.markWebhookDead(&d, statusCode, err.Error())
+	}
+	next := time.Now().Add(WebhookBackoff(d.Attempts))
+	return s.DB.Model(&d).Updates(map[string]interface{}{"attempts": d.Attempts, "last_status_code": statusCode, "last_error": err.Error(), "next_attempt_at": next, "updated_at": time.Now()}).Error
+}
+
+func (s *KYCService) markWebhookDead(d *models.WebhookDelivery, statusCode int, reason string) error {
+	return s.DB.Model(d).Updates(map[string]interface{}{"status": "dead", "attempts": d.Attempts, "last_status_code": statusCode, "last_error": reason, "next_attempt_at": nil, "updated_at": time.Now()}).Error
+}
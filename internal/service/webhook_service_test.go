@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"id":"evt_1","type":"kyc.completed"}`)
+	now := time.Unix(1700000000, 0)
+	header := WebhookSignatureHeader(secret, now.Unix(), body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   bool
+	}{
+		{"签名正确", secret, header, body, now, true},
+		{"容忍窗口内", secret, header, body, now.Add(4 * time.Minute), true},
+		{"时间戳过期", secret, header, body, now.Add(10 * time.Minute), false},
+		{"密钥错误", "whsec_other", header, body, now, false},
+		{"报文被篡改", secret, header, []byte(`{"id":"evt_2"}`), now, false},
+		{"签名头格式错误", secret, "v1=abc", body, now, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, VerifyWebhookSignature(tt.secret, tt.header, tt.body, tt.now))
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, WebhookBackoff(1))
+	assert.Equal(t, 20*time.Second, WebhookBackoff(2))
+	assert.Equal(t, 80*time.Second, WebhookBackoff(4))
+	assert.Equal(t, time.Hour, WebhookBackoff(20))
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	assert.NoError(t, ValidateWebhookURL("https://hooks.example.com/kyc"))
+	assert.NoError(t, ValidateWebhookURL("https://8.8.8.8/kyc"))
+	assert.Error(t, ValidateWebhookURL("http://hooks.example.com/kyc"))
+	assert.Error(t, ValidateWebhookURL("ftp://hooks.example.com"))
+	for _, raw := range []string{"https://localhost/x", "https://127.0.0.1/x", "https://10.0.0.5/x", "https://169.254.169.254/latest", "https://[::1]/x"} {
+		assert.ErrorIs(t, ValidateWebhookURL(raw), ErrWebhookURLNotPublic, raw)
+	}
+}
+
+func TestWebhookClientRejectsPrivateDial(t *testing.T) {
+	dial := publicDialControl(ErrWebhookURLNotPublic)
+	assert.ErrorIs(t, dial("tcp", "127.0.0.1:443", nil), ErrWebhookURLNotPublic)
+	assert.ErrorIs(t, dial("tcp", "[fd00::1]:443", nil), ErrWebhookURLNotPublic)
+	assert.NoError(t, dial("tcp", "93.184.216.34:443", nil))
+}
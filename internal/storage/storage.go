@@ -104,6 +104,17 @@ func autoMigrate(db *gorm.DB) error {
 		&models.User{},
 		&models.OAuthClient{},
 		&models.OAuthToken{},
+		&models.TokenRevocation{},
+		&models.OAuthAuthorizationCode{},
+		&models.MFARecoveryCode{},
+		&models.WebAuthnCredential{},
+		&models.MFAChallengeUse{},
+		&models.ConsoleSession{},
+		&models.OrgSSOConfig{},
+		&models.OrgDomain{},
+		&models.SCIMToken{},
+		&models.SCIMGroup{},
+		&models.SCIMGroupMember{},
 		&models.Organization{},
 		&models.OrganizationMember{},
 		&models.APIKey{},
@@ -117,9 +128,17 @@ func autoMigrate(db *gorm.DB) error {
 		&models.GlobalConfig{},
 		&models.APIRequestLog{},
 		&models.OrganizationQuotas{},
+		&models.QuotaLedgerEntry{},
+		&models.QuotaPeriodHistory{},
+		&models.JWTSigningKey{},
 		&models.FaceImageRef{},
 		&models.ImageAsset{},
 		&models.VideoAsset{},
+		&models.OrgDataKey{},
+		&models.DataSubjectRequest{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.KYCJob{},
 		&models.KYCRequest{}, // 确保 KYCRequest 模型被包含在自动迁移中
 	}
 
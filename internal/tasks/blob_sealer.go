@@ -0,0 +1,28 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+)
+
+// StartBlobSealer 启动后立即、之后周期将启用加密前以明文存储的图片/视频加密，
+// 保证组织数据密钥销毁后不再留有可读的旧文件
+func StartBlobSealer(svc *service.KYCService, interval time.Duration) {
+	go func() {
+		log := logger.GetLogger()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			n, err := svc.SealPlaintextBlobs(context.Background(), 100)
+			if err != nil {
+				log.WithError(err).Warn("加密明文图片/视频失败")
+			} else if n > 0 {
+				log.Infof("✅ 已加密 %d 个明文存储的图片/视频", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,31 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+)
+
+// StartJWTKeyRotator 周期检查签名密钥是否到期轮换，同时加载其它实例生成的密钥并清理已过期的密钥
+func StartJWTKeyRotator(svc *service.KYCService, interval time.Duration) {
+	go func() {
+		log := logger.GetLogger()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx := context.Background()
+			if rotated, err := svc.RotateJWTKeysIfDue(ctx); err != nil {
+				log.WithError(err).Warn("JWT签名密钥轮换失败")
+			} else if rotated {
+				log.Info("✅ 已轮换JWT签名密钥")
+			}
+			if n, err := svc.PurgeExpiredJWTKeys(ctx); err != nil {
+				log.WithError(err).Warn("清理过期JWT签名密钥失败")
+			} else if n > 0 {
+				log.Infof("已清理 %d 把过期JWT签名密钥", n)
+			}
+		}
+	}()
+}
@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// StartKYCJobWorkers 启动异步KYC工作池：消费Redis队列，同时按 pollInterval 固定间隔轮询数据库，
+// 队列持续有任务时只存在于数据库中的任务（延迟重试、入队失败、租约过期）也不会被饿死
+func StartKYCJobWorkers(svc *service.KYCService, workers int, pollInterval time.Duration) {
+	if workers <= 0 {
+		workers = 1
+	}
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+	for i := 0; i < workers; i++ {
+		go func(n int) {
+			ctx := context.Background()
+			nextPoll := time.Now()
+			for {
+				if !time.Now().Before(nextPoll) {
+					// 兜底：处理延迟重试、Redis入队失败及租约过期的任务
+					for {
+						job, ok := svc.ClaimNextKYCJob()
+						if !ok {
+							break
+						}
+						svc.ProcessKYCJob(ctx, job)
+					}
+					nextPoll = time.Now().Add(pollInterval)
+				}
+				if svc.Redis == nil {
+					time.Sleep(time.Until(nextPoll))
+					continue
+				}
+				// BRPOP 的超时以秒为单位且 0 表示一直阻塞，至少等 1 秒
+				wait := time.Until(nextPoll)
+				if wait < time.Second {
+					wait = time.Second
+				}
+				id, err := svc.PopKYCJobID(ctx, wait)
+				if err == nil {
+					if job, ok := svc.ClaimKYCJob(id); ok {
+						svc.ProcessKYCJob(ctx, job)
+					}
+					continue
+				}
+				if err != redis.Nil {
+					logger.GetLogger().WithError(err).Warnf("KYC工作协程%d读取队列失败，改用数据库轮询", n)
+					time.Sleep(time.Until(nextPoll))
+				}
+			}
+		}(i)
+	}
+}
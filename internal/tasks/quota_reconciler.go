@@ -0,0 +1,28 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+)
+
+// StartQuotaReconciler 周期比对 Redis 计数器、organization_quotas 与配额流水，偏差通过日志与 quota_drift 指标告警
+func StartQuotaReconciler(svc *service.KYCService, interval time.Duration) {
+	go func() {
+		log := logger.GetLogger()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			drifts, err := svc.ReconcileQuotas(context.Background())
+			if err != nil {
+				log.WithError(err).Warn("配额对账失败")
+				continue
+			}
+			if len(drifts) > 0 {
+				log.Errorf("配额对账发现 %d 处偏差", len(drifts))
+			}
+		}
+	}()
+}
@@ -1,26 +1,26 @@
 package tasks
 
 import (
-	"gorm.io/gorm"
-	"kyc-service/pkg/logger"
+	"context"
 	"time"
+
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
 )
 
-// StartQuotaResetter 周期重置组织配额（基于 reset_at）
-func StartQuotaResetter(db *gorm.DB, interval time.Duration) {
+// StartQuotaResetter 周期关闭已到期的计费周期（基于 reset_at）：写入周期历史、结转未用额度并开启下一周期
+func StartQuotaResetter(svc *service.KYCService, interval time.Duration) {
 	go func() {
 		log := logger.GetLogger()
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			<-ticker.C
-			// 将已到期的记录重置，并设置下一期时间（仅适用于存在 reset_at 的记录）
-			// 假设为月度周期：下期设为下个月1号0点
-			nextMonth := time.Date(time.Now().Year(), time.Now().Month()+1, 1, 0, 0, 0, 0, time.Now().Location())
-			if err := db.Exec(`UPDATE organization_quotas SET consumed = 0, updated_at = NOW(), reset_at = CASE WHEN reset_at IS NOT NULL THEN ? ELSE reset_at END WHERE reset_at IS NOT NULL AND reset_at <= NOW()`, nextMonth).Error; err != nil {
+			// 下一周期按各组织的注册日与时区计算（日/周/月/年）
+			if n, err := svc.ResetDueQuotas(context.Background()); err != nil {
 				log.WithError(err).Warn("配额重置失败")
 			} else {
-				log.Info("✅ 已执行周期性配额重置")
+				log.Infof("✅ 已执行周期性配额重置: %d 条", n)
 			}
 		}
 	}()
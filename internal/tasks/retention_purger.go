@@ -0,0 +1,37 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kyc-service/internal/service"
+	"kyc-service/pkg/logger"
+)
+
+// StartRetentionPurger 周期按保留策略清理超期的图片、个人信息、KYC结果与请求日志；dryRun 时只统计并写审计
+func StartRetentionPurger(svc *service.KYCService, interval time.Duration, dryRun bool) {
+	go func() {
+		log := logger.GetLogger()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			<-ticker.C
+			report, err := svc.RunRetentionPurge(context.Background(), dryRun, "system")
+			if errors.Is(err, service.ErrRetentionRunning) {
+				continue
+			}
+			if err != nil {
+				log.WithError(err).Warn("保留期清理失败")
+				continue
+			}
+			var rows int64
+			for _, org := range report.Orgs {
+				for _, it := range org.Items {
+					rows += it.Rows
+				}
+			}
+			log.Infof("✅ 已执行保留期清理(dry_run=%v): %d 个组织, %d 行, 耗时 %s", dryRun, len(report.Orgs), rows, report.FinishedAt.Sub(report.StartedAt).Round(time.Millisecond))
+		}
+	}()
+}
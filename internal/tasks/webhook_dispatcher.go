@@ -0,0 +1,21 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"kyc-service/internal/service"
+)
+
+// StartWebhookDispatcher 周期领取到期的Webhook投递（重试与进程中断后的兜底）
+func StartWebhookDispatcher(svc *service.KYCService, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			// 单批处理满时继续领取，直到没有到期任务
+			for svc.DispatchDueWebhooks(context.Background(), 50) == 50 {
+			}
+		}
+	}()
+}
@@ -0,0 +1,71 @@
+// Package blobstore 对象存储抽象：本地文件系统与 S3 兼容存储（AWS S3、MinIO 等）
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	ErrNotFound     = errors.New("blob not found")
+	ErrInvalidKey   = errors.New("invalid blob key")
+	ErrSizeMismatch = errors.New("blob size mismatch")
+	ErrURLExpired   = errors.New("signed url expired")
+	ErrURLSignature = errors.New("signed url signature invalid")
+)
+
+// BlobStore 按键存取对象；键为以 / 分隔的相对路径
+type BlobStore interface {
+	// Put 流式写入对象，size 为内容长度（未知时传 -1，S3 后端要求已知长度）
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open 读取对象，不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// Exists 对象是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+	// SignedURL 生成有效期为 ttl 的免认证下载地址
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// Backend 后端类型：local / s3
+	Backend() string
+}
+
+// LocalPather 可直接给出对象本地路径的后端（供按路径调用的供应商使用）
+type LocalPather interface {
+	LocalPath(key string) (string, error)
+}
+
+// ValidateKey 键不能为空、不能以 / 开头，且不能包含 ..、\ 或控制字符
+func ValidateKey(key string) error {
+	if key == "" || len(key) > 1024 || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return ErrInvalidKey
+		}
+	}
+	for _, r := range key {
+		if r < 0x20 || r == 0x7f {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// OrgPrefix 按模板生成组织的键前缀，模板中的 {org_id} 替换为组织ID；结果非空时以 / 结尾
+func OrgPrefix(template, orgID string) string {
+	p := strings.Trim(strings.ReplaceAll(template, "{org_id}", orgID), "/")
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
@@ -0,0 +1,105 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Local 本地文件系统后端；签名下载地址指向 URLBase（由本服务校验签名后返回文件）
+type Local struct {
+	root string
+	urls *URLSigner
+}
+
+// NewLocal root 为存储根目录，urlBase 为签名下载接口地址（如 https://kyc.example.com/api/v1/blobs），secret 为签名密钥
+func NewLocal(root, urlBase string, secret []byte) *Local {
+	return &Local{root: filepath.Clean(root), urls: NewURLSigner(urlBase, secret)}
+}
+
+func (l *Local) Backend() string { return BackendLocal }
+
+// LocalPath 对象在本地的绝对路径
+func (l *Local) LocalPath(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put 先写入同目录临时文件再改名，读者不会看到写了一半的对象
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := l.LocalPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("%w: wrote %d, expected %d", ErrSizeMismatch, n, size)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.LocalPath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.LocalPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Exists(ctx context.Context, key string) (bool, error) {
+	path, err := l.LocalPath(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SignedURL urlBase/<key>?expires=<unix>&signature=<hmac>
+func (l *Local) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return l.urls.Sign(key, ttl)
+}
+
+// VerifySignedURL 校验 SignedURL 生成的 expires 与 signature
+func (l *Local) VerifySignedURL(key, expires, signature string, now time.Time) error {
+	return l.urls.Verify(key, expires, signature, now)
+}
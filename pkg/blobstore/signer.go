@@ -0,0 +1,61 @@
+package blobstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URLSigner 由本服务校验的签名下载地址：<base>/<key>?expires=<unix>&signature=<hmac>
+type URLSigner struct {
+	base   string
+	secret []byte
+}
+
+func NewURLSigner(base string, secret []byte) *URLSigner {
+	return &URLSigner{base: strings.TrimRight(base, "/"), secret: secret}
+}
+
+func (u *URLSigner) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, u.secret)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign 生成有效期为 ttl 的下载地址
+func (u *URLSigner) Sign(key string, ttl time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	if len(u.secret) == 0 {
+		return "", errors.New("blob url secret not configured")
+	}
+	expires := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", u.sign(key, expires))
+	return u.base + "/" + escapePath(key) + "?" + q.Encode(), nil
+}
+
+// Verify 校验 Sign 生成的 expires 与 signature
+func (u *URLSigner) Verify(key, expires, signature string, now time.Time) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || len(u.secret) == 0 {
+		return ErrURLSignature
+	}
+	if !hmac.Equal([]byte(u.sign(key, exp)), []byte(signature)) {
+		return ErrURLSignature
+	}
+	if now.Unix() > exp {
+		return ErrURLExpired
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package blobstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4（仅覆盖 S3 用到的部分）
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	sigV4DateFormat  = "20060102"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+type sigV4Credentials struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+	Service   string
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// uriEncode 按 SigV4 规则编码：仅保留 A-Z a-z 0-9 - _ . ~，keepSlash 时保留 /
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+// escapePath 对象键的 URL 路径编码
+func escapePath(key string) string { return uriEncode(key, true) }
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func (c sigV4Credentials) scope(t time.Time) string {
+	return t.Format(sigV4DateFormat) + "/" + c.Region + "/" + c.Service + "/aws4_request"
+}
+
+func (c sigV4Credentials) signature(t time.Time, canonicalRequest string) string {
+	stringToSign := sigV4Algorithm + "\n" + t.Format(sigV4TimeFormat) + "\n" + c.scope(t) + "\n" + sha256Hex(canonicalRequest)
+	k := hmacSHA256([]byte("AWS4"+c.SecretKey), t.Format(sigV4DateFormat))
+	k = hmacSHA256(k, c.Region)
+	k = hmacSHA256(k, c.Service)
+	k = hmacSHA256(k, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(k, stringToSign))
+}
+
+// signRequest 以请求头方式签名；req.URL.Path 须为未编码的路径
+func (c sigV4Credentials) signRequest(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(sigV4TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var ch strings.Builder
+	for _, k := range names {
+		ch.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical := strings.Join([]string{req.Method, escapePath(req.URL.Path), canonicalQuery(req.URL.Query()), ch.String(), signed, payloadHash}, "\n")
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+c.AccessKey+"/"+c.scope(t)+", SignedHeaders="+signed+", Signature="+c.signature(t, canonical))
+}
+
+// presign 生成查询串签名的 URL（只签 host 头）
+func (c sigV4Credentials) presign(method string, u *url.URL, expires time.Duration, t time.Time) string {
+	t = t.UTC()
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", sigV4Algorithm)
+	q.Set("X-Amz-Credential", c.AccessKey+"/"+c.scope(t))
+	q.Set("X-Amz-Date", t.Format(sigV4TimeFormat))
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	q.Set("X-Amz-SignedHeaders", "host")
+	canonical := strings.Join([]string{method, escapePath(u.Path), canonicalQuery(q), "host:" + u.Host + "\n", "host", unsignedPayload}, "\n")
+	q.Set("X-Amz-Signature", c.signature(t, canonical))
+	out := *u
+	out.RawPath = escapePath(u.Path)
+	out.RawQuery = canonicalQuery(q)
+	return out.String()
+}
@@ -0,0 +1,177 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// State 熔断器状态
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+var (
+	ErrOpen            = errors.New("circuit breaker is open")
+	ErrTooManyRequests = errors.New("too many concurrent requests")
+)
+
+// Config 熔断与舱壁参数
+type Config struct {
+	FailureThreshold int           // 连续失败达到该次数后打开熔断
+	OpenTimeout      time.Duration // 打开后经过该时长进入半开
+	HalfOpenMaxCalls int           // 半开状态允许的试探请求数
+	MaxConcurrent    int           // 最大并发请求数，0 表示不限制
+}
+
+// Snapshot 熔断器当前状态
+type Snapshot struct {
+	Name     string     `json:"name"`
+	State    string     `json:"state"`
+	Failures int        `json:"consecutive_failures"`
+	InFlight int        `json:"in_flight"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+// Breaker 连续失败计数熔断器，附带并发舱壁
+type Breaker struct {
+	name     string
+	cfg      Config
+	onChange func(name string, from, to State)
+	now      func() time.Time
+
+	mu            sync.Mutex
+	state         State
+	failures      int
+	openedAt      time.Time
+	halfOpenCalls int
+	inFlight      int
+}
+
+func New(name string, cfg Config, onChange func(name string, from, to State)) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+	return &Breaker{name: name, cfg: cfg, onChange: onChange, now: time.Now}
+}
+
+func (b *Breaker) Name() string { return b.name }
+
+// Allow 申请一次调用；返回 nil 时调用方必须在结束后调用 Done
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+	switch b.state {
+	case StateOpen:
+		return ErrOpen
+	case StateHalfOpen:
+		if b.halfOpenCalls >= b.cfg.HalfOpenMaxCalls {
+			return ErrOpen
+		}
+	}
+	if b.cfg.MaxConcurrent > 0 && b.inFlight >= b.cfg.MaxConcurrent {
+		return ErrTooManyRequests
+	}
+	if b.state == StateHalfOpen {
+		b.halfOpenCalls++
+	}
+	b.inFlight++
+	return nil
+}
+
+// Done 报告调用结果
+func (b *Breaker) Done(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.inFlight > 0 {
+		b.inFlight--
+	}
+	if b.state == StateHalfOpen && b.halfOpenCalls > 0 {
+		b.halfOpenCalls--
+	}
+	if success {
+		b.failures = 0
+		if b.state != StateClosed {
+			b.setState(StateClosed)
+		}
+		return
+	}
+	b.failures++
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.cfg.FailureThreshold) {
+		b.openedAt = b.now()
+		b.setState(StateOpen)
+	}
+}
+
+// Cancel 释放调用占用的并发名额，不计入成功或失败（如调用方主动取消）
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inFlight > 0 {
+		b.inFlight--
+	}
+	if b.state == StateHalfOpen && b.halfOpenCalls > 0 {
+		b.halfOpenCalls--
+	}
+}
+
+// State 当前状态（到期的打开状态视为半开）
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	st := b.State()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snap := Snapshot{Name: b.name, State: st.String(), Failures: b.failures, InFlight: b.inFlight}
+	if st != StateClosed {
+		t := b.openedAt
+		snap.OpenedAt = &t
+	}
+	return snap
+}
+
+func (b *Breaker) setState(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	if to != StateHalfOpen {
+		b.halfOpenCalls = 0
+	}
+	if b.onChange != nil {
+		b.onChange(b.name, from, to)
+	}
+}
@@ -0,0 +1,53 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	now := time.Now()
+	var changes []string
+	b := New("ocr/default", Config{FailureThreshold: 2, OpenTimeout: time.Minute}, func(_ string, from, to State) {
+		changes = append(changes, from.String()+"->"+to.String())
+	})
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, b.Allow())
+		b.Done(false)
+	}
+	assert.Equal(t, StateOpen, b.State())
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+
+	// 超时后进入半开，仅放行一个试探请求
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+	b.Done(true)
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, []string{"closed->open", "open->half_open", "half_open->closed"}, changes)
+}
+
+func TestBreakerHalfOpenFailure(t *testing.T) {
+	now := time.Now()
+	b := New("face/default", Config{FailureThreshold: 1, OpenTimeout: time.Second}, nil)
+	b.now = func() time.Time { return now }
+
+	assert.NoError(t, b.Allow())
+	b.Done(false)
+	now = now.Add(time.Second)
+	assert.NoError(t, b.Allow())
+	b.Done(false)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBulkhead(t *testing.T) {
+	b := New("liveness/default", Config{MaxConcurrent: 1}, nil)
+	assert.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), ErrTooManyRequests)
+	b.Done(true)
+	assert.NoError(t, b.Allow())
+}
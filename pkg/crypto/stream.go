@@ -0,0 +1,268 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 分块 AES-256-GCM 流式加密格式（用于图片/视频等大文件）：
+//
+//	头部：magic(4) | 版本(1) | 数据密钥ID长度(1) | 数据密钥ID | nonce 前缀(7)
+//	分块：每块明文至多 StreamChunkSize 字节，密文 = 明文 + 16 字节 GCM 标签
+//
+// 第 i 块的 nonce = 前缀(7) | i(4，大端) | 是否末块(1)，附加数据为数据密钥ID，
+// 因此调换、截断或替换数据密钥ID都会导致解密失败。
+const (
+	StreamChunkSize = 64 * 1024
+
+	streamVersion     = 1
+	streamPrefixSize  = 7
+	streamTagSize     = 16
+	streamMaxKeyIDLen = 255
+)
+
+var streamMagic = []byte("KYCE")
+
+var (
+	ErrNotEncryptedStream = errors.New("not an encrypted stream")
+	ErrStreamCorrupted    = errors.New("encrypted stream corrupted")
+)
+
+// GenerateDataKey 生成 32 字节随机数据密钥
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// WrapKey 用主密钥（AES-256-GCM）包裹数据密钥，输出 nonce|密文
+func WrapKey(master, dataKey []byte) ([]byte, error) {
+	gcm, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+// UnwrapKey 解开 WrapKey 包裹的数据密钥
+func UnwrapKey(master, wrapped []byte) ([]byte, error) {
+	gcm, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, errors.New("密文太短")
+	}
+	return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func streamHeaderSize(keyID string) int64 {
+	return int64(len(streamMagic) + 2 + len(keyID) + streamPrefixSize)
+}
+
+// EncryptedSize 明文长度为 plainSize 时的密文总长度
+func EncryptedSize(plainSize int64, keyID string) int64 {
+	chunks := (plainSize + StreamChunkSize - 1) / StreamChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return streamHeaderSize(keyID) + plainSize + chunks*streamTagSize
+}
+
+// IsEncryptedStream 判断数据开头是否为本格式的头部
+func IsEncryptedStream(head []byte) bool {
+	return len(head) >= len(streamMagic)+1 && bytes.Equal(head[:len(streamMagic)], streamMagic) && head[len(streamMagic)] == streamVersion
+}
+
+func chunkNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+type encryptReader struct {
+	src    *bufio.Reader
+	gcm    cipher.AEAD
+	keyID  []byte
+	prefix []byte
+	index  uint32
+	plain  []byte
+	out    []byte
+	done   bool
+}
+
+// NewEncryptReader 返回读出密文的 Reader，key 为 32 字节数据密钥，keyID 写入头部供解密时查找密钥
+func NewEncryptReader(r io.Reader, key []byte, keyID string) (io.Reader, error) {
+	if len(keyID) > streamMaxKeyIDLen {
+		return nil, fmt.Errorf("data key id too long: %d", len(keyID))
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, streamPrefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, streamHeaderSize(keyID))
+	header = append(header, streamMagic...)
+	header = append(header, streamVersion, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = append(header, prefix...)
+	return &encryptReader{
+		src:    bufio.NewReaderSize(r, StreamChunkSize+1),
+		gcm:    gcm,
+		keyID:  []byte(keyID),
+		prefix: prefix,
+		plain:  make([]byte, StreamChunkSize),
+		out:    header,
+	}, nil
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// sealNext 读取下一块明文并加密；读满一块后再窥视一个字节以判断是否为末块
+func (e *encryptReader) sealNext() error {
+	n, err := io.ReadFull(e.src, e.plain)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, perr := e.src.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			return perr
+		}
+	}
+	e.out = e.gcm.Seal(e.out[:0], chunkNonce(e.prefix, e.index, last), e.plain[:n], e.keyID)
+	e.index++
+	e.done = last
+	return nil
+}
+
+type decryptReader struct {
+	src    *bufio.Reader
+	gcm    cipher.AEAD
+	keyID  []byte
+	prefix []byte
+	index  uint32
+	sealed []byte
+	out    []byte
+	done   bool
+}
+
+// NewDecryptReader 解析头部，通过 keyFor 按数据密钥ID取得密钥，返回读出明文的 Reader。
+// 数据不是本格式时返回 ErrNotEncryptedStream。
+func NewDecryptReader(r io.Reader, keyFor func(keyID string) ([]byte, error)) (io.Reader, error) {
+	src := bufio.NewReaderSize(r, StreamChunkSize+streamTagSize+1)
+	head, err := src.Peek(len(streamMagic) + 2)
+	if err != nil || !IsEncryptedStream(head) {
+		return nil, ErrNotEncryptedStream
+	}
+	fixed := make([]byte, len(streamMagic)+2)
+	if _, err := io.ReadFull(src, fixed); err != nil {
+		return nil, ErrStreamCorrupted
+	}
+	rest := make([]byte, int(fixed[len(fixed)-1])+streamPrefixSize)
+	if _, err := io.ReadFull(src, rest); err != nil {
+		return nil, ErrStreamCorrupted
+	}
+	keyID := rest[:len(rest)-streamPrefixSize]
+	key, err := keyFor(string(keyID))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		src:    src,
+		gcm:    gcm,
+		keyID:  keyID,
+		prefix: rest[len(keyID):],
+		sealed: make([]byte, StreamChunkSize+streamTagSize),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.openNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+func (d *decryptReader) openNext() error {
+	n, err := io.ReadFull(d.src, d.sealed)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, perr := d.src.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			return perr
+		}
+	}
+	if n < streamTagSize {
+		return ErrStreamCorrupted
+	}
+	plain, err := d.gcm.Open(d.out[:0], chunkNonce(d.prefix, d.index, last), d.sealed[:n], d.keyID)
+	if err != nil {
+		return ErrStreamCorrupted
+	}
+	d.out = plain
+	d.index++
+	d.done = last
+	return nil
+}
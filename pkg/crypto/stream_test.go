@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapKey(t *testing.T) {
+	master := bytes.Repeat([]byte{7}, 32)
+	dek, err := GenerateDataKey()
+	require.NoError(t, err)
+	wrapped, err := WrapKey(master, dek)
+	require.NoError(t, err)
+	got, err := UnwrapKey(master, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dek, got)
+
+	_, err = UnwrapKey(bytes.Repeat([]byte{8}, 32), wrapped)
+	assert.Error(t, err)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key, _ := GenerateDataKey()
+	keyFor := func(id string) ([]byte, error) {
+		if id != "dk-1" {
+			return nil, errors.New("unknown key")
+		}
+		return key, nil
+	}
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 17} {
+		plain := bytes.Repeat([]byte("x"), size)
+		r, err := NewEncryptReader(bytes.NewReader(plain), key, "dk-1")
+		require.NoError(t, err)
+		sealed, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, EncryptedSize(int64(size), "dk-1"), int64(len(sealed)), "size %d", size)
+		assert.True(t, IsEncryptedStream(sealed))
+
+		dr, err := NewDecryptReader(bytes.NewReader(sealed), keyFor)
+		require.NoError(t, err)
+		got, err := io.ReadAll(dr)
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, plain, got)
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	key, _ := GenerateDataKey()
+	keyFor := func(string) ([]byte, error) { return key, nil }
+	plain := bytes.Repeat([]byte("y"), 2*StreamChunkSize+5)
+	r, _ := NewEncryptReader(bytes.NewReader(plain), key, "dk-1")
+	sealed, _ := io.ReadAll(r)
+
+	// 截断到整块边界：末块标记不符
+	truncated := sealed[:EncryptedSize(2*StreamChunkSize, "dk-1")]
+	dr, err := NewDecryptReader(bytes.NewReader(truncated), keyFor)
+	require.NoError(t, err)
+	_, err = io.ReadAll(dr)
+	assert.ErrorIs(t, err, ErrStreamCorrupted)
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[len(flipped)-1] ^= 1
+	dr, _ = NewDecryptReader(bytes.NewReader(flipped), keyFor)
+	_, err = io.ReadAll(dr)
+	assert.ErrorIs(t, err, ErrStreamCorrupted)
+
+	_, err = NewDecryptReader(bytes.NewReader([]byte("\xff\xd8\xff plain jpeg")), keyFor)
+	assert.ErrorIs(t, err, ErrNotEncryptedStream)
+}
@@ -74,6 +74,40 @@ func (c *Client) SetConfig(count, timeout int) {
 	}
 }
 
+// doWithRetry 发送请求，仅在网络错误或5xx时重试：每次重试重建请求体，ctx 结束后不再重试
+func (c *Client) doWithRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	for i := 0; i < c.config.RetryCount+1; i++ {
+		if i > 0 {
+			if req.GetBody != nil {
+				body, gerr := req.GetBody()
+				if gerr != nil {
+					return nil, gerr
+				}
+				req.Body = body
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.config.RetryInterval):
+			}
+		}
+		resp, err = c.client.Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+		if ctx.Err() != nil || i == c.config.RetryCount {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		c.logger.WithFields(logrus.Fields{"url": req.URL.String(), "attempt": i + 1, "error": err}).Warn("Request failed, retrying...")
+	}
+	return resp, err
+}
+
 // Post sends a POST request to the specified URL.
 func (c *Client) Post(ctx context.Context, url string, body interface{}, headers http.Header) ([]byte, error) {
 	jsonBody, err := json.Marshal(body)
@@ -91,21 +125,7 @@ func (c *Client) Post(ctx context.Context, url string, body interface{}, headers
 	req.Header = headers
 	req.Header.Set("Content-Type", "application/json")
 
-	var resp *http.Response
-	for i := 0; i < c.config.RetryCount+1; i++ {
-		resp, err = c.client.Do(req)
-		if err == nil && resp.StatusCode < http.StatusInternalServerError {
-			break
-		}
-		c.logger.WithFields(logrus.Fields{
-			"url":     url,
-			"attempt": i + 1,
-			"error":   err,
-		}).Warn("Request failed, retrying...")
-		if i < c.config.RetryCount {
-			time.Sleep(c.config.RetryInterval)
-		}
-	}
+	resp, err := c.doWithRetry(ctx, req)
 
 	if err != nil {
 		c.logger.WithField("error", err).Error("Request failed after retries")
@@ -166,24 +186,7 @@ func (c *Client) PostMultipart(ctx context.Context, url string, data map[string]
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	var resp *http.Response
-
-	now4 := time.Now()
-	for i := 0; i < c.config.RetryCount+1; i++ {
-		now5 := time.Now()
-		resp, err = c.client.Do(req)
-
-		fmt.Printf("output: %v, %v, %v\n", "三方耗时:", time.Since(now5), c.config.RetryInterval)
-
-		if err == nil && resp.StatusCode < http.StatusInternalServerError {
-			break
-		}
-		c.logger.WithFields(logrus.Fields{"url": url, "attempt": i + 1, "error": err}).Warn("Request failed, retrying...")
-		if i < c.config.RetryCount {
-			time.Sleep(c.config.RetryInterval)
-		}
-	}
-	fmt.Printf("output: %v, %v\n", "end4", time.Since(now4))
+	resp, err := c.doWithRetry(ctx, req)
 
 	if err != nil {
 		c.logger.WithField("error", err).Error("Request failed after retries")
@@ -235,17 +238,7 @@ func (c *Client) PostMultipartNamed(ctx context.Context, url string, fileField s
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	var resp *http.Response
-	for i := 0; i < c.config.RetryCount+1; i++ {
-		resp, err = c.client.Do(req)
-		if err == nil && resp.StatusCode < http.StatusInternalServerError {
-			break
-		}
-		c.logger.WithFields(logrus.Fields{"url": url, "attempt": i + 1, "error": err}).Warn("Request failed, retrying...")
-		if i < c.config.RetryCount {
-			time.Sleep(c.config.RetryInterval)
-		}
-	}
+	resp, err := c.doWithRetry(ctx, req)
 	if err != nil {
 		c.logger.WithField("error", err).Error("Request failed after retries")
 		return nil, err
@@ -294,17 +287,7 @@ func (c *Client) PostMultipartMulti(ctx context.Context, url string, files map[s
 		return nil, err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	var resp *http.Response
-	for i := 0; i < c.config.RetryCount+1; i++ {
-		resp, err = c.client.Do(req)
-		if err == nil && resp.StatusCode < http.StatusInternalServerError {
-			break
-		}
-		c.logger.WithFields(logrus.Fields{"url": url, "attempt": i + 1, "error": err}).Warn("Request failed, retrying...")
-		if i < c.config.RetryCount {
-			time.Sleep(c.config.RetryInterval)
-		}
-	}
+	resp, err := c.doWithRetry(ctx, req)
 	if err != nil {
 		c.logger.WithField("error", err).Error("Request failed after retries")
 		return nil, err
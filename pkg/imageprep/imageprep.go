@@ -0,0 +1,346 @@
+// Package imageprep 调用识别供应商前的图片预处理：按 EXIF 方向摆正、等比缩小、
+// 重新编码为 JPEG（同时去除 EXIF/GPS 等元数据），并拒绝分辨率过低的图片。
+package imageprep
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels 解码前按头部尺寸拒绝超过该像素数（约 5000 万）的图片，防止解压炸弹
+const MaxPixels = 48 << 20
+
+// MaxDecodeBytes 解码后像素数据的内存上限，按头部的色彩模型估算（16 位 PNG/TIFF 每像素 8 字节）。
+// 合成与缩小逐行进行，不再分配原图尺寸的副本，单张图片的峰值内存约为此值
+const MaxDecodeBytes = 256 << 20
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooSmall     = errors.New("image resolution too small")
+	ErrImageTooLarge     = errors.New("image resolution too large")
+	// ErrHEICUnsupported 没有可用的纯 Go HEVC 解码器，HEIC/HEIF 需由客户端转换后上传
+	ErrHEICUnsupported = fmt.Errorf("%w: HEIC/HEIF is not supported, convert to JPEG or PNG before uploading", ErrUnsupportedFormat)
+)
+
+// 图片格式，由文件头判断
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+	FormatBMP  = "bmp"
+	FormatTIFF = "tiff"
+	FormatHEIC = "heic"
+)
+
+// Options 预处理参数
+type Options struct {
+	MaxDimension int // 长边上限（像素），超过时等比缩小；0 表示不缩放
+	MinDimension int // 短边下限（像素），不足时拒绝；0 表示不检查
+	Quality      int // JPEG 质量 1~100，0 使用 jpeg.DefaultQuality
+}
+
+// Result 预处理结果
+type Result struct {
+	Data           []byte
+	Format         string // 原图格式
+	Orientation    int    // 原图 EXIF 方向，1 表示无需旋转
+	OriginalWidth  int    // 摆正后的原图尺寸
+	OriginalHeight int
+	Width          int
+	Height         int
+}
+
+// DetectFormat 按文件头判断图片格式，无法识别时返回空
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return FormatWebP
+	case bytes.HasPrefix(data, []byte("BM")):
+		return FormatBMP
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return FormatTIFF
+	case isHEIF(data):
+		return FormatHEIC
+	}
+	return ""
+}
+
+// isHEIF ISO-BMFF ftyp 盒中的主品牌或兼容品牌为 HEIF 系列
+func isHEIF(data []byte) bool {
+	if len(data) < 16 || !bytes.Equal(data[4:8], []byte("ftyp")) {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(data[:4]))
+	if size < 16 || size > len(data) {
+		size = len(data)
+	}
+	for i := 8; i+4 <= size; i += 4 {
+		if i == 12 {
+			continue // minor_version
+		}
+		switch string(data[i : i+4]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1", "avif":
+			return true
+		}
+	}
+	return false
+}
+
+// Process 解码图片，按 EXIF 方向摆正、缩放后重新编码为 JPEG。
+// 支持 JPEG、PNG、GIF（取第一帧）、WebP、BMP、TIFF（取第一页）；HEIC/HEIF 返回 ErrHEICUnsupported，
+// 无法识别的格式返回 ErrUnsupportedFormat。
+func Process(data []byte, opt Options) (*Result, error) {
+	format := DetectFormat(data)
+	switch format {
+	case FormatJPEG, FormatPNG, FormatGIF, FormatWebP, FormatBMP, FormatTIFF:
+	case FormatHEIC:
+		return nil, ErrHEICUnsupported
+	case "":
+		return nil, ErrUnsupportedFormat
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels ||
+		cfg.Width*cfg.Height*bytesPerPixel(cfg.ColorModel) > MaxDecodeBytes {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	res := &Result{Format: format, Orientation: 1}
+	switch format {
+	case FormatJPEG:
+		res.Orientation = jpegOrientation(data)
+	case FormatTIFF:
+		res.Orientation = exifOrientation(data) // TIFF 本身即 EXIF 的容器格式
+	case FormatWebP:
+		res.Orientation = webpOrientation(data)
+	}
+	w, h := cfg.Width, cfg.Height
+	if res.Orientation >= 5 {
+		w, h = h, w
+	}
+	res.OriginalWidth, res.OriginalHeight = w, h
+	if opt.MinDimension > 0 && min(w, h) < opt.MinDimension {
+		return nil, fmt.Errorf("%w: %dx%d, shorter side must be at least %d", ErrImageTooSmall, w, h, opt.MinDimension)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	sb := src.Bounds()
+	tw, th := fitWithin(sb.Dx(), sb.Dy(), opt.MaxDimension)
+	img := orient(resample(src, tw, th), res.Orientation)
+
+	quality := opt.Quality
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	res.Data = buf.Bytes()
+	res.Width, res.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	return res, nil
+}
+
+// fitWithin 长边不超过 maxDim 的等比尺寸
+func fitWithin(w, h, maxDim int) (int, int) {
+	long := max(w, h)
+	if maxDim <= 0 || long <= maxDim {
+		return w, h
+	}
+	scale := float64(maxDim) / float64(long)
+	return max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+}
+
+// bytesPerPixel 解码后每像素占用的字节数（YCbCr 按 4:4:4 估算）
+func bytesPerPixel(m color.Model) int {
+	switch m {
+	case color.GrayModel, color.AlphaModel:
+		return 1
+	case color.Gray16Model, color.Alpha16Model:
+		return 2
+	case color.YCbCrModel:
+		return 3
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 8
+	}
+	if _, ok := m.(color.Palette); ok {
+		return 1
+	}
+	return 4
+}
+
+// resample 按白色背景合成透明区域（JPEG 不支持透明），并区域平均缩小到 w×h（目标像素取其覆盖的源像素均值）。
+// 逐行转换源图，只分配一行缓冲与目标图
+func resample(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	row := image.NewRGBA(image.Rect(0, 0, sw, 1))
+	cols := make([]int, sw)   // 源列所属的目标列
+	colN := make([]uint64, w) // 目标列覆盖的源列数
+	for x := 0; x < w; x++ {
+		for sx := x * sw / w; sx < max((x+1)*sw/w, x*sw/w+1); sx++ {
+			cols[sx] = x
+			colN[x]++
+		}
+	}
+	sum := make([]uint64, w*3)
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		clear(sum)
+		for sy := y0; sy < y1; sy++ {
+			draw.Draw(row, row.Bounds(), image.White, image.Point{}, draw.Src)
+			draw.Draw(row, row.Bounds(), src, image.Pt(b.Min.X, b.Min.Y+sy), draw.Over)
+			for sx, x := range cols {
+				p := row.Pix[sx*4 : sx*4+3]
+				sum[x*3], sum[x*3+1], sum[x*3+2] = sum[x*3]+uint64(p[0]), sum[x*3+1]+uint64(p[1]), sum[x*3+2]+uint64(p[2])
+			}
+		}
+		for x := 0; x < w; x++ {
+			n := uint64(y1-y0) * colN[x]
+			i := y*dst.Stride + x*4
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(sum[x*3]/n), uint8(sum[x*3+1]/n), uint8(sum[x*3+2]/n), 0xFF
+		}
+	}
+	return dst
+}
+
+// orient 按 EXIF 方向（1~8）变换为正向图片
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := sw, sh
+	if orientation >= 5 {
+		dw, dh = sh, sw
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = sw-1-x, y
+			case 3: // 旋转 180°
+				sx, sy = sw-1-x, sh-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, sh-1-y
+			case 5: // 沿主对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90°
+				sx, sy = y, sh-1-x
+			case 7: // 沿副对角线翻转
+				sx, sy = sw-1-y, sh-1-x
+			case 8: // 逆时针旋转 90°
+				sx, sy = sw-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:sy*src.Stride+sx*4+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation 读取 JPEG 中 APP1 Exif 段 IFD0 的 Orientation（0x0112），没有或无效时返回 1
+func jpegOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // 图像数据开始，之后不再有元数据段
+			return 1
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+n]
+		if marker == 0xE1 && len(seg) > 6 && bytes.Equal(seg[:6], []byte("Exif\x00\x00")) {
+			return exifOrientation(seg[6:])
+		}
+		i += 2 + n
+	}
+	return 1
+}
+
+// webpOrientation 读取 WebP 中 EXIF 块的 Orientation，没有或无效时返回 1
+func webpOrientation(data []byte) int {
+	for i := 12; i+8 <= len(data); {
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if n < 0 || i+8+n > len(data) {
+			return 1
+		}
+		if string(data[i:i+4]) == "EXIF" {
+			// 部分编码器在块内保留 JPEG APP1 的 "Exif\0\0" 前缀
+			return exifOrientation(bytes.TrimPrefix(data[i+8:i+8+n], []byte("Exif\x00\x00")))
+		}
+		i += 8 + n + n&1 // 块按偶数字节对齐
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 1
+	}
+	off := int(bo.Uint32(tiff[4:]))
+	if off < 8 || off+2 > len(tiff) {
+		return 1
+	}
+	count := int(bo.Uint16(tiff[off:]))
+	for k := 0; k < count; k++ {
+		e := off + 2 + k*12
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if bo.Uint16(tiff[e:]) == 0x0112 {
+			v := int(bo.Uint16(tiff[e+8:]))
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
@@ -0,0 +1,212 @@
+package imageprep
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// halves 左半红、右半蓝
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// exifWithOrientation 只含 Orientation 的 EXIF（TIFF 结构，大端）
+func exifWithOrientation(orientation uint16) []byte {
+	tiff := []byte("MM\x00*\x00\x00\x00\x08")
+	ifd := make([]byte, 2+12+4)
+	binary.BigEndian.PutUint16(ifd, 1)
+	binary.BigEndian.PutUint16(ifd[2:], 0x0112)
+	binary.BigEndian.PutUint16(ifd[4:], 3) // SHORT
+	binary.BigEndian.PutUint32(ifd[6:], 1)
+	binary.BigEndian.PutUint16(ifd[10:], orientation)
+	return append(tiff, ifd...)
+}
+
+// withExifOrientation 在 SOI 之后插入只含 Orientation 的 APP1 段
+func withExifOrientation(jpg []byte, orientation uint16) []byte {
+	payload := append([]byte("Exif\x00\x00"), exifWithOrientation(orientation)...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	seg = append(seg, payload...)
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, seg...)
+	return append(out, jpg[2:]...)
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xC000 && g < 0x4000 && b < 0x4000
+}
+
+func TestDetectFormat(t *testing.T) {
+	heic := append([]byte{0, 0, 0, 24}, []byte("ftypmif1\x00\x00\x00\x00mif1heic")...)
+	cases := map[string][]byte{
+		FormatJPEG: encodeJPEG(t, halves(4, 4)),
+		FormatHEIC: heic,
+		FormatWebP: []byte("RIFF\x00\x00\x00\x00WEBPVP8 "),
+		"":         []byte("not an image"),
+	}
+	for want, data := range cases {
+		if got := DetectFormat(data); got != want {
+			t.Errorf("DetectFormat = %q, want %q", got, want)
+		}
+	}
+	if _, err := Process(heic, Options{}); !errors.Is(err, ErrHEICUnsupported) || !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("heic: err = %v", err)
+	}
+}
+
+func TestProcessWebPBMPTIFF(t *testing.T) {
+	webp, err := os.ReadFile("testdata/gopher.lossless.webp") // 75x100，来自 golang.org/x/image 测试数据
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bmpBuf, tiffBuf bytes.Buffer
+	if err := bmp.Encode(&bmpBuf, halves(200, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tiff.Encode(&tiffBuf, halves(200, 100), nil); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		format string
+		data   []byte
+		w, h   int
+	}{
+		{FormatWebP, webp, 38, 50},
+		{FormatBMP, bmpBuf.Bytes(), 50, 25},
+		{FormatTIFF, tiffBuf.Bytes(), 50, 25},
+	}
+	for _, tc := range cases {
+		res, err := Process(tc.data, Options{MaxDimension: 50})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		if res.Format != tc.format || res.Width != tc.w || res.Height != tc.h || DetectFormat(res.Data) != FormatJPEG {
+			t.Fatalf("%s: got %s %dx%d", tc.format, res.Format, res.Width, res.Height)
+		}
+	}
+}
+
+func TestWebPOrientation(t *testing.T) {
+	chunk := func(fourCC string, payload []byte) []byte {
+		c := append([]byte(fourCC), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(c[4:], uint32(len(payload)))
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	riff := func(chunks ...[]byte) []byte {
+		out := []byte("RIFF\x00\x00\x00\x00WEBP")
+		for _, c := range chunks {
+			out = append(out, c...)
+		}
+		return out
+	}
+	exif := exifWithOrientation(6)
+	if got := webpOrientation(riff(chunk("VP8X", make([]byte, 10)), chunk("ICCP", []byte{1}), chunk("EXIF", exif))); got != 6 {
+		t.Fatalf("orientation = %d", got)
+	}
+	if got := webpOrientation(riff(chunk("EXIF", append([]byte("Exif\x00\x00"), exif...)))); got != 6 {
+		t.Fatalf("orientation with Exif prefix = %d", got)
+	}
+	if got := webpOrientation(riff(chunk("VP8L", []byte{1, 2, 3}))); got != 1 {
+		t.Fatalf("orientation without exif = %d", got)
+	}
+}
+
+// TestProcessRejectsLargeDecode 16 位 RGBA 按每像素 8 字节估算，未超过像素上限也会在解码前拒绝
+func TestProcessRejectsLargeDecode(t *testing.T) {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, 6000)
+	binary.BigEndian.PutUint32(ihdr[4:], 6000)
+	ihdr[8], ihdr[9] = 16, 6 // 16 位 RGBA
+	data := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	data = append(data, ihdr...)
+	data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(append([]byte("IHDR"), ihdr...)))
+	if 6000*6000 > MaxPixels {
+		t.Fatal("test image exceeds MaxPixels")
+	}
+	if _, err := Process(data, Options{}); !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestProcessOrientationAndStripsExif(t *testing.T) {
+	src := withExifOrientation(encodeJPEG(t, halves(200, 100)), 6)
+	if got := jpegOrientation(src); got != 6 {
+		t.Fatalf("orientation = %d", got)
+	}
+	res, err := Process(src, Options{Quality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Width != 100 || res.Height != 200 || res.Orientation != 6 {
+		t.Fatalf("got %dx%d orientation %d", res.Width, res.Height, res.Orientation)
+	}
+	if bytes.Contains(res.Data, []byte("Exif\x00\x00")) {
+		t.Fatal("exif not stripped")
+	}
+	out, err := jpeg.Decode(bytes.NewReader(res.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 顺时针旋转 90° 后原图左侧（红）位于上方
+	if !isRed(out.At(50, 20)) || isRed(out.At(50, 180)) {
+		t.Fatal("image not rotated clockwise")
+	}
+}
+
+func TestProcessDownscaleAndMinDimension(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves(1600, 400)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Process(buf.Bytes(), Options{MaxDimension: 800, MinDimension: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Format != FormatPNG || res.Width != 800 || res.Height != 200 || res.OriginalWidth != 1600 {
+		t.Fatalf("got %+v", res)
+	}
+	if DetectFormat(res.Data) != FormatJPEG {
+		t.Fatal("output is not jpeg")
+	}
+
+	_, err = Process(buf.Bytes(), Options{MinDimension: 401})
+	if !errors.Is(err, ErrImageTooSmall) {
+		t.Fatalf("err = %v", err)
+	}
+}
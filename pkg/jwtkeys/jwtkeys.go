@@ -0,0 +1,433 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 支持的签名算法
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+var (
+	ErrNoSigningKey = errors.New("no active signing key")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// Key 一把签名密钥：ActivatesAt 之后用于签名（之前仅发布在 JWKS 中），ExpiresAt 之后不再用于验签
+type Key struct {
+	ID          string
+	Algorithm   string
+	Private     crypto.Signer // 仅验签的节点可为 nil
+	Public      crypto.PublicKey
+	ActivatesAt time.Time
+	ExpiresAt   *time.Time
+	CreatedAt   time.Time
+}
+
+// GenerateKey 生成新的签名密钥，kid 取公钥指纹
+func GenerateKey(alg string, activatesAt time.Time) (*Key, error) {
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return &Key{
+		ID:          base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Algorithm:   alg,
+		Private:     priv,
+		Public:      priv.Public(),
+		ActivatesAt: activatesAt,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// MarshalPrivateKey 以 PKCS#8 PEM 编码私钥
+func MarshalPrivateKey(k *Key) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParsePrivateKey 解析 PKCS#8 PEM 私钥
+func ParsePrivateKey(s string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key is not a signer")
+	}
+	return signer, nil
+}
+
+// MarshalPublicKey 以 PKIX PEM 编码公钥
+func MarshalPublicKey(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// ParsePublicKey 解析 PKIX PEM 公钥
+func ParsePublicKey(s string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("invalid public key PEM")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func (k *Key) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgES256 {
+		return jwt.SigningMethodES256
+	}
+	return jwt.SigningMethodRS256
+}
+
+func (k *Key) verifiable(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// JWK RFC 7517 公钥表示
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet /jwks.json 响应体
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK 返回公钥的 JWK 表示
+func (k *Key) JWK() JWK {
+	out := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		out.Kty = "RSA"
+		out.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		out.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		out.Kty = "EC"
+		out.Crv = pub.Curve.Params().Name
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		out.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		out.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	}
+	return out
+}
+
+// Loader 从持久化存储加载全部未过期密钥
+type Loader func() ([]*Key, error)
+
+// Set 当前节点持有的签名/验签密钥集合。验签时遇到未知 kid 会（限频）重新加载，
+// 以便其它节点轮换后生成的令牌能立即通过校验。
+type Set struct {
+	loader     Loader
+	hmacSecret []byte // 非空时继续接受旧的 HS256 令牌，便于平滑迁移
+	now        func() time.Time
+
+	mu       sync.RWMutex
+	keys     []*Key // 按 ActivatesAt 升序
+	lastLoad time.Time
+}
+
+const minReloadInterval = 10 * time.Second
+
+func NewSet(loader Loader, hmacSecret string) *Set {
+	s := &Set{loader: loader, now: time.Now}
+	if hmacSecret != "" {
+		s.hmacSecret = []byte(hmacSecret)
+	}
+	return s
+}
+
+// Reload 从 Loader 重新加载密钥
+func (s *Set) Reload() error {
+	if s.loader == nil {
+		return nil
+	}
+	keys, err := s.loader()
+	if err != nil {
+		return err
+	}
+	s.Replace(keys)
+	return nil
+}
+
+// Replace 替换全部密钥
+func (s *Set) Replace(keys []*Key) {
+	sorted := append([]*Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ActivatesAt.Before(sorted[j].ActivatesAt) })
+	s.mu.Lock()
+	s.keys = sorted
+	s.lastLoad = s.now()
+	s.mu.Unlock()
+}
+
+// Keys 返回全部密钥（含尚未生效与已停止签名但仍可验签的）
+func (s *Set) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*Key(nil), s.keys...)
+}
+
+// Active 当前用于签名的密钥：已生效且未过期中最新的一把
+func (s *Set) Active() *Key {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		k := s.keys[i]
+		if k.Private != nil && !k.ActivatesAt.After(now) && k.verifiable(now) {
+			return k
+		}
+	}
+	return nil
+}
+
+// Latest 最新的一把密钥（可能尚未生效），用于判断是否需要轮换
+func (s *Set) Latest() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.keys) == 0 {
+		return nil
+	}
+	return s.keys[len(s.keys)-1]
+}
+
+// Sign 使用当前密钥签名并写入 kid 头
+func (s *Set) Sign(claims jwt.Claims) (string, error) {
+	k := s.Active()
+	if k == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(k.signingMethod(), claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.Private)
+}
+
+// JWKS 返回所有可验签密钥的公钥（含预发布的下一把密钥）
+func (s *Set) JWKS() JWKSet {
+	now := s.now()
+	out := JWKSet{Keys: []JWK{}}
+	for _, k := range s.Keys() {
+		if k.verifiable(now) {
+			out.Keys = append(out.Keys, k.JWK())
+		}
+	}
+	return out
+}
+
+// Keyfunc 供 jwt.Parse 使用：按 kid 选择公钥并校验算法与密钥类型一致，防止算法混淆
+func (s *Set) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if s.hmacSecret == nil {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return s.hmacSecret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+	k := s.lookup(kid)
+	if k == nil && s.shouldReload() {
+		_ = s.Reload()
+		k = s.lookup(kid)
+	}
+	if k == nil || !k.verifiable(s.now()) {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != k.Algorithm {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return k.Public, nil
+}
+
+func (s *Set) lookup(kid string) *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.ID == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+func (s *Set) shouldReload() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loader != nil && s.now().Sub(s.lastLoad) >= minReloadInterval
+}
+
+// PublicKey 将 JWK 还原为公钥（支持 RSA 与 P-256 EC）
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA JWK")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported EC curve: %s", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("invalid EC JWK: point not on curve")
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWK kty: %s", j.Kty)
+	}
+}
+
+// algorithmFor 按公钥类型推断签名算法
+func algorithmFor(pub crypto.PublicKey) (string, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return AlgRS256, nil
+	case *ecdsa.PublicKey:
+		return AlgES256, nil
+	}
+	return "", errors.New("unsupported public key type")
+}
+
+// ParsePublicKeys 解析第三方登记的验签公钥：JWK Set（JSON）或单个 PKIX PEM 公钥
+func ParsePublicKeys(s string) ([]*Key, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty public key")
+	}
+	if !strings.HasPrefix(s, "{") {
+		pub, err := ParsePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+		alg, err := algorithmFor(pub)
+		if err != nil {
+			return nil, err
+		}
+		return []*Key{{Algorithm: alg, Public: pub}}, nil
+	}
+	var set JWKSet
+	if err := json.Unmarshal([]byte(s), &set); err != nil {
+		return nil, err
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("JWK set contains no keys")
+	}
+	keys := make([]*Key, 0, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		pub, err := j.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		alg, err := algorithmFor(pub)
+		if err != nil {
+			return nil, err
+		}
+		if j.Alg != "" && j.Alg != alg {
+			return nil, fmt.Errorf("unsupported JWK alg: %s", j.Alg)
+		}
+		keys = append(keys, &Key{ID: j.Kid, Algorithm: alg, Public: pub})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWK set contains no signing keys")
+	}
+	return keys, nil
+}
+
+// StaticKeyfunc 以固定公钥集合验签（如客户端断言）。令牌带 kid 时按 kid 选择，
+// 集合只有一把密钥且无法按 kid 区分时直接使用；拒绝 HMAC 与算法不一致的令牌。
+func StaticKeyfunc(keys []*Key) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		var k *Key
+		for _, c := range keys {
+			if kid != "" && c.ID == kid {
+				k = c
+				break
+			}
+		}
+		if k == nil && len(keys) == 1 && (kid == "" || keys[0].ID == "") {
+			k = keys[0]
+		}
+		if k == nil {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != k.Algorithm {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return k.Public, nil
+	}
+}
@@ -0,0 +1,141 @@
+package jwtkeys
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifyAcrossRotation(t *testing.T) {
+	now := time.Now()
+	old, err := GenerateKey(AlgRS256, now.Add(-time.Hour))
+	require.NoError(t, err)
+	next, err := GenerateKey(AlgES256, now.Add(time.Minute))
+	require.NoError(t, err)
+
+	s := NewSet(nil, "")
+	s.now = func() time.Time { return now }
+	s.Replace([]*Key{next, old})
+
+	// 下一把密钥已发布但尚未生效，仍由旧密钥签名
+	assert.Equal(t, old.ID, s.Active().ID)
+	assert.Len(t, s.JWKS().Keys, 2)
+	oldToken, err := s.Sign(jwt.MapClaims{"sub": "u1"})
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	expires := now.Add(time.Hour)
+	old.ExpiresAt = &expires
+	assert.Equal(t, next.ID, s.Active().ID)
+	newToken, err := s.Sign(jwt.MapClaims{"sub": "u1"})
+	require.NoError(t, err)
+
+	for _, tok := range []string{oldToken, newToken} {
+		parsed, err := jwt.Parse(tok, s.Keyfunc)
+		require.NoError(t, err)
+		assert.True(t, parsed.Valid)
+	}
+
+	// 重叠窗口结束后旧令牌不再可验
+	now = expires
+	_, err = jwt.Parse(oldToken, s.Keyfunc)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Len(t, s.JWKS().Keys, 1)
+}
+
+func TestKeyfuncRejectsHMACWithoutSecret(t *testing.T) {
+	hs, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "u1"}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(hs, NewSet(nil, "").Keyfunc)
+	assert.Error(t, err)
+
+	parsed, err := jwt.Parse(hs, NewSet(nil, "secret").Keyfunc)
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+}
+
+func TestKeyfuncReloadsUnknownKid(t *testing.T) {
+	k, err := GenerateKey(AlgES256, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	signer := NewSet(nil, "")
+	signer.Replace([]*Key{k})
+	tok, err := signer.Sign(jwt.MapClaims{"sub": "u1"})
+	require.NoError(t, err)
+
+	loads := 0
+	verifier := NewSet(func() ([]*Key, error) {
+		loads++
+		return []*Key{{ID: k.ID, Algorithm: k.Algorithm, Public: k.Public, ActivatesAt: k.ActivatesAt}}, nil
+	}, "")
+	_, err = jwt.Parse(tok, verifier.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loads)
+}
+
+func TestPEMRoundTrip(t *testing.T) {
+	k, err := GenerateKey(AlgRS256, time.Now())
+	require.NoError(t, err)
+	privPEM, err := MarshalPrivateKey(k)
+	require.NoError(t, err)
+	priv, err := ParsePrivateKey(privPEM)
+	require.NoError(t, err)
+	pubPEM, err := MarshalPublicKey(k.Public)
+	require.NoError(t, err)
+	pub, err := ParsePublicKey(pubPEM)
+	require.NoError(t, err)
+	assert.Equal(t, k.Public, priv.Public())
+	assert.Equal(t, k.Public, pub)
+	assert.Equal(t, "RSA", k.JWK().Kty)
+}
+
+func TestParsePublicKeysFromJWKSAndPEM(t *testing.T) {
+	rs, err := GenerateKey(AlgRS256, time.Now())
+	require.NoError(t, err)
+	ec, err := GenerateKey(AlgES256, time.Now())
+	require.NoError(t, err)
+
+	body, err := json.Marshal(JWKSet{Keys: []JWK{rs.JWK(), ec.JWK()}})
+	require.NoError(t, err)
+	keys, err := ParsePublicKeys(string(body))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, rs.Public, keys[0].Public)
+	assert.Equal(t, ec.Public, keys[1].Public)
+
+	pemKey, err := MarshalPublicKey(ec.Public)
+	require.NoError(t, err)
+	keys, err = ParsePublicKeys(pemKey)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, AlgES256, keys[0].Algorithm)
+
+	_, err = ParsePublicKeys(`{"keys":[]}`)
+	assert.Error(t, err)
+}
+
+func TestStaticKeyfunc(t *testing.T) {
+	k, err := GenerateKey(AlgES256, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	signer := NewSet(nil, "")
+	signer.Replace([]*Key{k})
+	tok, err := signer.Sign(jwt.MapClaims{"sub": "c1"})
+	require.NoError(t, err)
+
+	pemKey, err := MarshalPublicKey(k.Public)
+	require.NoError(t, err)
+	keys, err := ParsePublicKeys(pemKey)
+	require.NoError(t, err)
+	_, err = jwt.Parse(tok, StaticKeyfunc(keys))
+	assert.NoError(t, err)
+
+	// 以公钥字节作为 HMAC 密钥伪造的令牌必须被拒绝
+	hs, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "c1"}).SignedString([]byte(pemKey))
+	require.NoError(t, err)
+	_, err = jwt.Parse(hs, StaticKeyfunc(keys))
+	assert.Error(t, err)
+}
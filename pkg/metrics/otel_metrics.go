@@ -57,11 +57,21 @@ var (
 	orgQuotaUsed   metric.Int64UpDownCounter
 	lastQuotaLimit = map[string]int{}
 
+	// 超出套餐额度的计费用量（overage）
+	orgQuotaOverage metric.Int64UpDownCounter
+
 	// 审计事件计数
 	auditEventsTotal metric.Int64Counter
 
 	// 配额持久化失败计数
 	quotaPersistErrorsTotal metric.Int64Counter
+
+	// 配额对账偏差（Redis/organization_quotas/流水 之间）
+	quotaDrift metric.Int64Gauge
+
+	// 第三方供应商熔断器
+	circuitBreakerState      metric.Int64Gauge
+	circuitBreakerRejections metric.Int64Counter
 )
 
 const (
@@ -280,6 +290,15 @@ func InitOTelMetrics() error {
 	if err != nil {
 		return fmt.Errorf("创建配额持久化失败指标失败: %w", err)
 	}
+
+	quotaDrift, err = meter.Int64Gauge(
+		"quota_drift",
+		metric.WithDescription("Quota drift found by reconciliation (source=redis|ledger|stale_reservations)"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return fmt.Errorf("创建配额对账偏差指标失败: %w", err)
+	}
 	// 组织配额指标
 	orgQuotaLimit, err = meter.Int64UpDownCounter(
 		"org_quota_limit",
@@ -299,6 +318,33 @@ func InitOTelMetrics() error {
 		return fmt.Errorf("创建组织配额使用指标失败: %w", err)
 	}
 
+	orgQuotaOverage, err = meter.Int64UpDownCounter(
+		"org_quota_overage",
+		metric.WithDescription("Billable overage units beyond plan allocation per service type"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return fmt.Errorf("创建组织配额超额指标失败: %w", err)
+	}
+
+	circuitBreakerState, err = meter.Int64Gauge(
+		"third_party_circuit_breaker_state",
+		metric.WithDescription("Third-party provider circuit breaker state (0=closed, 1=open, 2=half_open)"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return fmt.Errorf("创建熔断器状态指标失败: %w", err)
+	}
+
+	circuitBreakerRejections, err = meter.Int64Counter(
+		"third_party_circuit_breaker_rejections_total",
+		metric.WithDescription("Third-party calls rejected by circuit breaker or bulkhead"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return fmt.Errorf("创建熔断拒绝计数指标失败: %w", err)
+	}
+
 	// 启用 Go runtime 指标导出
 	_ = runtimeotel.Start(runtimeotel.WithMinimumReadMemStatsInterval(10 * time.Second))
 	otelMetricsInitialized = true
@@ -436,6 +482,9 @@ func RecordSensitiveDataAccess(ctx context.Context, dataType, userID string, aut
 
 // RecordDependencyCall 记录依赖调用
 func RecordDependencyCall(ctx context.Context, service, method string, success bool, duration time.Duration) {
+	if !otelMetricsInitialized {
+		return
+	}
 	status := "success"
 	if !success {
 		status = "failed"
@@ -469,6 +518,9 @@ func RecordDependencyCall(ctx context.Context, service, method string, success b
 }
 
 func RecordDependencyCallCode(ctx context.Context, service, method string, success bool, duration time.Duration, code string) {
+	if !otelMetricsInitialized {
+		return
+	}
 	status := "success"
 	if !success {
 		status = "failed"
@@ -505,6 +557,9 @@ func RecordThirdPartyRequest(ctx context.Context, thirdPartyName, result, httpSt
 }
 
 func RecordThirdPartyRequestWithOp(ctx context.Context, thirdPartyName, operation, result, httpStatusCode string, duration time.Duration) {
+	if !otelMetricsInitialized {
+		return
+	}
 	orgID := ""
 	if v := ctx.Value("org_id"); v != nil {
 		if s, ok := v.(string); ok {
@@ -546,6 +601,9 @@ func RecordThirdPartyRequestWithOp(ctx context.Context, thirdPartyName, operatio
 }
 
 func RecordThirdPartyError(ctx context.Context, thirdPartyName, operation, errorType string) {
+	if !otelMetricsInitialized {
+		return
+	}
 	orgID := ""
 	if v := ctx.Value("org_id"); v != nil {
 		if s, ok := v.(string); ok {
@@ -602,6 +660,9 @@ func RecordAuditEvent(ctx context.Context, action, resource, status string) {
 
 // RecordQuotaPersistFailure 记录配额持久化失败
 func RecordQuotaPersistFailure(ctx context.Context, orgID, serviceType, reason string) {
+	if !otelMetricsInitialized {
+		return
+	}
 	attrs := []attribute.KeyValue{
 		attribute.String("org_id", orgID),
 		attribute.String("service_type", serviceType),
@@ -652,3 +713,45 @@ func IncOrgQuotaUsed(ctx context.Context, orgID, serviceType string, delta int)
 		orgQuotaUsed.Add(ctx, int64(delta), metric.WithAttributes(attrs...))
 	}
 }
+
+// IncOrgQuotaOverage 记录超额用量变化（退还时 delta 为负）
+func IncOrgQuotaOverage(ctx context.Context, orgID, serviceType string, delta int) {
+	if !otelMetricsInitialized {
+		return
+	}
+	orgQuotaOverage.Add(ctx, int64(delta), metric.WithAttributes(
+		attribute.String("org_id", orgID),
+		attribute.String("service_type", serviceType),
+	))
+}
+
+// SetCircuitBreakerState 记录供应商熔断器状态（0=closed, 1=open, 2=half_open）
+func SetCircuitBreakerState(ctx context.Context, provider string, state int) {
+	if !otelMetricsInitialized {
+		return
+	}
+	circuitBreakerState.Record(ctx, int64(state), metric.WithAttributes(attribute.String("provider", provider)))
+}
+
+// RecordCircuitBreakerRejection 记录被熔断（open）或舱壁（bulkhead）拒绝的调用
+func RecordCircuitBreakerRejection(ctx context.Context, provider, reason string) {
+	if !otelMetricsInitialized {
+		return
+	}
+	circuitBreakerRejections.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("provider", provider),
+		attribute.String("reason", reason),
+	))
+}
+
+// SetQuotaDrift 记录配额对账偏差，0 表示一致
+func SetQuotaDrift(ctx context.Context, orgID, serviceType, source string, drift int) {
+	if !otelMetricsInitialized {
+		return
+	}
+	quotaDrift.Record(ctx, int64(drift), metric.WithAttributes(
+		attribute.String("org_id", orgID),
+		attribute.String("service_type", serviceType),
+		attribute.String("source", source),
+	))
+}
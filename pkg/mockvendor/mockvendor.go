@@ -0,0 +1,261 @@
+package mockvendor
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 模拟的供应商接口路径，与 ThirdPartyService / callOCRHTTP 调用的地址一致
+const (
+	PathOCR            = "/vrlOCR"
+	PathFaceSearch     = "/vrlFaceSearch"
+	PathFaceDetect     = "/vrlFaceDetection"
+	PathFaceCompare    = "/vrlFaceComparison"
+	PathLivenessSilent = "/vrlSilentLiveness"
+	PathLivenessVideo  = "/vrlMoveLiveness"
+)
+
+const (
+	maxUploadMemory     = 32 << 20
+	defaultFailureCode  = 500
+	defaultRejectedCode = 1001
+)
+
+// 文件名或文件路径中包含以下标记时注入对应错误，便于用固定样本复现异常场景
+const (
+	TriggerServerError = "mock_500"          // 返回 HTTP 500
+	TriggerTimeout     = "mock_timeout"      // 挂起直到调用方超时
+	TriggerRejected    = "mock_reject"       // HTTP 200 + 业务失败 code
+	TriggerMismatch    = "mock_mismatch"     // 人脸比对不一致 / 非活体 / 无相似人脸
+	TriggerWrongAction = "mock_wrong_action" // 动作活体：视频中的动作与要求不符
+)
+
+// Options 模拟服务参数
+type Options struct {
+	Latency   time.Duration // 每个请求的固定延迟
+	Jitter    time.Duration // 在固定延迟上叠加 [0, Jitter) 的随机延迟
+	ErrorRate float64       // 随机返回 HTTP 500 的比例（0-1）
+	Seed      int64         // 随机数种子，相同种子下延迟与错误序列可复现
+	Fixtures  []Fixture     // 固定响应，优先于默认响应
+}
+
+// Fixture 固定响应：按路径与文件名（或 picture_path/video_path）子串匹配
+type Fixture struct {
+	Path    string          `json:"path"`
+	Match   string          `json:"match"` // 为空表示匹配该路径下的所有请求
+	Status  int             `json:"status"`
+	DelayMS int             `json:"delay_ms"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// LoadFixtures 从 JSON 文件加载固定响应列表
+func LoadFixtures(path string) ([]Fixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取fixture文件失败: %w", err)
+	}
+	var out []Fixture
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("解析fixture文件失败: %w", err)
+	}
+	return out, nil
+}
+
+// Server 模拟 OCR/人脸/活体供应商，响应格式与真实接口一致
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	calls map[string]int
+}
+
+func New(opts Options) *Server {
+	s := &Server{opts: opts, mux: http.NewServeMux(), rng: rand.New(rand.NewSource(opts.Seed)), calls: map[string]int{}}
+	s.mux.HandleFunc(PathOCR, s.multipart(ocrResponse))
+	s.mux.HandleFunc(PathFaceSearch, s.multipart(faceSearchResponse))
+	s.mux.HandleFunc(PathFaceDetect, s.multipart(faceDetectResponse))
+	s.mux.HandleFunc(PathFaceCompare, s.multipart(faceCompareResponse))
+	s.mux.HandleFunc(PathLivenessSilent, s.jsonPath("picture_path", livenessResponse))
+	s.mux.HandleFunc(PathLivenessVideo, s.jsonPath("video_path", livenessResponse))
+	s.mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	return s
+}
+
+// NewTestServer 启动 httptest 模拟服务，测试结束时由调用方 Close
+func NewTestServer(opts Options) *httptest.Server {
+	return httptest.NewServer(New(opts))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Calls 返回各路径的累计调用次数
+func (s *Server) Calls() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.calls))
+	for k, v := range s.calls {
+		out[k] = v
+	}
+	return out
+}
+
+// upload 一次请求中的关键输入：用于匹配的名称及用于生成确定性结果的内容
+type upload struct {
+	names   []string
+	digests [][]byte
+	action  string // 动作活体要求校验的动作
+}
+
+type responder func(in upload) interface{}
+
+func (s *Server) multipart(fn responder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+			writeJSON(w, http.StatusBadRequest, failure(400, "invalid multipart form: "+err.Error()))
+			return
+		}
+		var in upload
+		for _, field := range []string{"picture", "picture1", "picture2"} {
+			f, fh, err := r.FormFile(field)
+			if err != nil {
+				continue
+			}
+			h := sha256.New()
+			_, _ = io.Copy(h, f)
+			f.Close()
+			in.names = append(in.names, fh.Filename)
+			in.digests = append(in.digests, h.Sum(nil))
+		}
+		if len(in.names) == 0 {
+			writeJSON(w, http.StatusBadRequest, failure(400, "picture is required"))
+			return
+		}
+		s.respond(w, r, in, fn)
+	}
+}
+
+func (s *Server) jsonPath(field string, fn responder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload[field] == "" {
+			writeJSON(w, http.StatusBadRequest, failure(400, field+" is required"))
+			return
+		}
+		sum := sha256.Sum256([]byte(payload[field]))
+		s.respond(w, r, upload{names: []string{payload[field]}, digests: [][]byte{sum[:]}, action: payload["action"]}, fn)
+	}
+}
+
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, in upload, fn responder) {
+	s.mu.Lock()
+	s.calls[r.URL.Path]++
+	delay := s.opts.Latency
+	if s.opts.Jitter > 0 {
+		delay += time.Duration(s.rng.Int63n(int64(s.opts.Jitter)))
+	}
+	injectError := s.opts.ErrorRate > 0 && s.rng.Float64() < s.opts.ErrorRate
+	s.mu.Unlock()
+
+	fx := s.fixture(r.URL.Path, in)
+	if fx != nil {
+		delay += time.Duration(fx.DelayMS) * time.Millisecond
+	}
+	if !sleep(r, delay) {
+		return
+	}
+
+	switch {
+	case in.has(TriggerTimeout):
+		<-r.Context().Done()
+		return
+	case injectError || in.has(TriggerServerError):
+		writeJSON(w, http.StatusInternalServerError, failure(defaultFailureCode, "mock error"))
+		return
+	case fx != nil:
+		status := fx.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(fx.Body)
+		return
+	case in.has(TriggerRejected):
+		writeJSON(w, http.StatusOK, failure(defaultRejectedCode, "recognition error"))
+		return
+	}
+	writeJSON(w, http.StatusOK, fn(in))
+}
+
+func (s *Server) fixture(path string, in upload) *Fixture {
+	for i := range s.opts.Fixtures {
+		fx := &s.opts.Fixtures[i]
+		if fx.Path == path && (fx.Match == "" || in.has(fx.Match)) {
+			return fx
+		}
+	}
+	return nil
+}
+
+func (in upload) has(marker string) bool {
+	for _, n := range in.names {
+		if strings.Contains(n, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// score 由输入内容摘要映射到 [0.80, 0.99] 的确定性分数
+func (in upload) score() float64 {
+	var b byte
+	for _, d := range in.digests {
+		b ^= d[0]
+	}
+	return 0.80 + float64(b%20)/100
+}
+
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func failure(code int, msg string) map[string]interface{} {
+	return map[string]interface{}{"code": code, "msg": msg, "error": msg}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,121 @@
+package mockvendor
+
+import (
+	"encoding/hex"
+	"path/filepath"
+)
+
+// 默认OCR识别结果，字段名与 KYC 流水线读取的候选字段一致
+var (
+	DefaultName     = "MOCK USER"
+	DefaultIDNumber = "110101199001011234"
+)
+
+func ocrResponse(in upload) interface{} {
+	score := in.score()
+	return map[string]interface{}{
+		"code": 0,
+		"msg":  "recognition success",
+		"parsing_results": map[string]interface{}{
+			"name":        map[string]interface{}{"text": DefaultName, "confidence": score},
+			"id_number":   map[string]interface{}{"text": DefaultIDNumber, "confidence": score},
+			"birth_date":  map[string]interface{}{"text": "1990-01-01", "confidence": score},
+			"nationality": map[string]interface{}{"text": "CHN", "confidence": score},
+		},
+		"full_text": DefaultName + " " + DefaultIDNumber,
+		"filename":  filepath.Base(in.names[0]),
+	}
+}
+
+func faceSearchResponse(in upload) interface{} {
+	pictures := []interface{}{}
+	has := 0
+	if !in.has(TriggerMismatch) {
+		has = 1
+		pictures = append(pictures, map[string]interface{}{
+			"id":         hex.EncodeToString(in.digests[0][:8]),
+			"confidence": in.score(),
+			"picture":    filepath.Base(in.names[0]),
+		})
+	}
+	return map[string]interface{}{
+		"code": 0,
+		"msg":  "search success",
+		"searching_results": map[string]interface{}{
+			"searched_similar_pictures": pictures,
+			"has_similar_picture":       has,
+		},
+		"filename": filepath.Base(in.names[0]),
+	}
+}
+
+func faceDetectResponse(in upload) interface{} {
+	return map[string]interface{}{
+		"code": 0,
+		"msg":  "detection success",
+		"detection_results": map[string]interface{}{
+			"is_face_exist": 1,
+			"face_num":      1,
+			"faces_detected": []interface{}{map[string]interface{}{
+				"facial_area": map[string]interface{}{
+					"x": 120, "y": 80, "w": 200, "h": 240,
+					"left_eye": []int{170, 170}, "right_eye": []int{270, 170},
+				},
+				"confidence": in.score(),
+			}},
+		},
+		"filename": filepath.Base(in.names[0]),
+	}
+}
+
+func faceCompareResponse(in upload) interface{} {
+	same, score, result := 1, in.score(), "same face"
+	if len(in.digests) == 2 && string(in.digests[0]) == string(in.digests[1]) {
+		score = 0.99
+	}
+	if in.has(TriggerMismatch) {
+		same, score, result = 0, 0.12, "different face"
+	}
+	names := make([]string, 0, len(in.names))
+	for _, n := range in.names {
+		names = append(names, filepath.Base(n))
+	}
+	return map[string]interface{}{
+		"code": 0,
+		"msg":  "comparison success",
+		"comparison_results": map[string]interface{}{
+			"is_face_exist":    1,
+			"confidence_exist": []float64{0.99, 0.99},
+			"is_same_face":     same,
+			"confidence":       score,
+			"detection_result": result,
+		},
+		"filename": names,
+	}
+}
+
+func livenessResponse(in upload) interface{} {
+	live, score := 1, in.score()
+	if in.has(TriggerMismatch) {
+		live, score = 0, 0.08
+	}
+	out := map[string]interface{}{
+		"code": 0,
+		"msg":  "liveness success",
+		"liveness_results": map[string]interface{}{
+			"is_liveness":           live,
+			"confidence":            score,
+			"is_face_exist":         1,
+			"face_exist_confidence": 0.99,
+		},
+		"filename": filepath.Base(in.names[0]),
+	}
+	if in.action != "" {
+		matched := 1
+		if in.has(TriggerWrongAction) {
+			matched = 0
+		}
+		out["action_results"] = map[string]interface{}{"action": in.action, "is_action_matched": matched, "confidence": score}
+	}
+	return out
+}
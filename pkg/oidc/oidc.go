@@ -0,0 +1,265 @@
+// Package oidc 实现企业 SSO 所需的 OpenID Connect 依赖方（RP）最小子集：
+// 发现文档与 JWKS 获取、授权码流程（含 PKCE S256）以及 ID Token 校验（RS256/ES256）。
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"kyc-service/pkg/jwtkeys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// maxResponseSize IdP 响应体大小上限
+	maxResponseSize = 1 << 20
+	// ClockSkew 校验 iat/exp 时容忍的时钟偏差
+	ClockSkew = 2 * time.Minute
+)
+
+var (
+	ErrIssuerMismatch = errors.New("oidc: issuer mismatch")
+	ErrNonceMismatch  = errors.New("oidc: nonce mismatch")
+	ErrNoIDToken      = errors.New("oidc: token response has no id_token")
+)
+
+// Provider IdP 发现文档中 RP 需要的字段
+type Provider struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint,omitempty"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported,omitempty"`
+}
+
+// TokenResponse 令牌端点响应
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Claims ID Token 中用于登录与开通账号的声明
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified,omitempty"` // 部分 IdP（如 Azure AD）不返回该声明
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	PreferredName string `json:"preferred_username"`
+	Picture       string `json:"picture"`
+}
+
+// DisplayName 优先 name，其次名+姓，最后 preferred_username
+func (c *Claims) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if n := strings.TrimSpace(c.GivenName + " " + c.FamilyName); n != "" {
+		return n
+	}
+	return c.PreferredName
+}
+
+// DiscoveryURL 接受 issuer 或完整的发现文档地址，返回发现文档地址
+func DiscoveryURL(issuerOrURL string) string {
+	u := strings.TrimRight(strings.TrimSpace(issuerOrURL), "/")
+	if strings.HasSuffix(u, discoveryPath) {
+		return u
+	}
+	return u + discoveryPath
+}
+
+// Discover 获取并校验发现文档：issuer 必须与发现地址一致（OpenID Connect Discovery 4.3）
+func Discover(ctx context.Context, client *http.Client, issuerOrURL string) (*Provider, error) {
+	docURL := DiscoveryURL(issuerOrURL)
+	var p Provider
+	if err := getJSON(ctx, client, docURL, &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(p.Issuer, "/")+discoveryPath != docURL {
+		return nil, ErrIssuerMismatch
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document missing required endpoints")
+	}
+	return &p, nil
+}
+
+// FetchKeys 获取 IdP 的验签公钥；不支持的密钥类型（如加密用途或 OKP）直接跳过
+func (p *Provider) FetchKeys(ctx context.Context, client *http.Client) ([]*jwtkeys.Key, error) {
+	var set jwtkeys.JWKSet
+	if err := getJSON(ctx, client, p.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make([]*jwtkeys.Key, 0, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		pub, err := j.PublicKey()
+		if err != nil {
+			continue
+		}
+		alg := ""
+		switch pub.(type) {
+		case *rsa.PublicKey:
+			alg = jwtkeys.AlgRS256
+		case *ecdsa.PublicKey:
+			alg = jwtkeys.AlgES256
+		}
+		if j.Alg != "" && j.Alg != alg {
+			continue
+		}
+		keys = append(keys, &jwtkeys.Key{ID: j.Kid, Algorithm: alg, Public: pub})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("oidc: JWKS contains no supported signing keys")
+	}
+	return keys, nil
+}
+
+// AuthCodeURL 构造授权请求地址（response_type=code，PKCE S256）
+func (p *Provider) AuthCodeURL(clientID, redirectURI, state, nonce, codeVerifier string, scopes []string) string {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// CodeChallenge PKCE S256 摘要
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Exchange 用授权码换取令牌，客户端认证方式为 client_secret_basic
+func (p *Provider) Exchange(ctx context.Context, client *http.Client, clientID, clientSecret, code, redirectURI, codeVerifier string) (*TokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint returned %d: %s", resp.StatusCode, truncate(body, 200))
+	}
+	var tr TokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("oidc: invalid token response: %w", err)
+	}
+	if tr.IDToken == "" {
+		return nil, ErrNoIDToken
+	}
+	return &tr, nil
+}
+
+// VerifyIDToken 校验 ID Token 的签名、iss、aud、exp/iat 与 nonce（OpenID Connect Core 3.1.3.7）
+func VerifyIDToken(raw string, keys []*jwtkeys.Key, issuer, clientID, nonce string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(raw, &claims, jwtkeys.StaticKeyfunc(keys),
+		jwt.WithValidMethods([]string{jwtkeys.AlgRS256, jwtkeys.AlgES256}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(ClockSkew))
+	if err != nil {
+		return nil, err
+	}
+	// 多个 audience 时 azp 必须为本客户端
+	if len(claims.Audience) > 1 {
+		var extra struct {
+			AZP string `json:"azp"`
+		}
+		if parts := strings.Split(raw, "."); len(parts) == 3 {
+			if payload, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+				_ = json.Unmarshal(payload, &extra)
+			}
+		}
+		if extra.AZP != clientID {
+			return nil, errors.New("oidc: azp does not match client_id")
+		}
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("oidc: id_token has no subject")
+	}
+	return &claims, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: request %s failed: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s returned %d", u, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(out); err != nil {
+		return fmt.Errorf("oidc: invalid JSON from %s: %w", u, err)
+	}
+	return nil
+}
+
+func truncate(b []byte, n int) string {
+	if len(b) > n {
+		return string(b[:n]) + "..."
+	}
+	return string(b)
+}
@@ -0,0 +1,158 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"kyc-service/pkg/jwtkeys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// stubIdP 最小 OIDC IdP：发现文档、JWKS 与授权码换取令牌
+type stubIdP struct {
+	srv      *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	secret   string
+	nonce    string
+	verifier string
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &stubIdP{key: key, clientID: "kyc-console", secret: "s3cret"}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Provider{
+			Issuer:                idp.srv.URL,
+			AuthorizationEndpoint: idp.srv.URL + "/authorize",
+			TokenEndpoint:         idp.srv.URL + "/token",
+			JWKSURI:               idp.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		k := jwtkeys.Key{ID: "k1", Algorithm: jwtkeys.AlgRS256, Public: &key.PublicKey}
+		enc := jwtkeys.JWK{Kty: "RSA", Kid: "enc", Use: "enc", N: "AQAB", E: "AQAB"}
+		_ = json.NewEncoder(w).Encode(jwtkeys.JWKSet{Keys: []jwtkeys.JWK{k.JWK(), enc}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != idp.clientID || pass != idp.secret || r.FormValue("code") != "good-code" ||
+			CodeChallenge(r.FormValue("code_verifier")) != CodeChallenge(idp.verifier) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(TokenResponse{AccessToken: "at", TokenType: "Bearer", IDToken: idp.idToken(t, idp.clientID, idp.nonce, time.Hour)})
+	})
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *stubIdP) idToken(t *testing.T, aud, nonce string, ttl time.Duration) string {
+	t.Helper()
+	now := time.Now()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.srv.URL,
+		"sub":            "user-1",
+		"aud":            aud,
+		"iat":            now.Unix(),
+		"exp":            now.Add(ttl).Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"given_name":     "Alice",
+		"family_name":    "Liddell",
+	})
+	tok.Header["kid"] = "k1"
+	s, err := tok.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newStubIdP(t)
+	idp.nonce, idp.verifier = "n-123", "verifier-verifier-verifier-verifier-1234"
+	ctx := context.Background()
+
+	p, err := Discover(ctx, idp.srv.Client(), idp.srv.URL+"/")
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	u, err := url.Parse(p.AuthCodeURL(idp.clientID, "https://kyc.example.com/cb", "st", idp.nonce, idp.verifier, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge") != CodeChallenge(idp.verifier) || q.Get("code_challenge_method") != "S256" || q.Get("scope") != "openid email profile" {
+		t.Fatalf("unexpected authorization request: %s", u)
+	}
+
+	keys, err := p.FetchKeys(ctx, idp.srv.Client())
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("fetch keys: %v (%d keys)", err, len(keys))
+	}
+	if _, err := p.Exchange(ctx, idp.srv.Client(), idp.clientID, "wrong", "good-code", "https://kyc.example.com/cb", idp.verifier); err == nil {
+		t.Fatal("exchange with wrong secret should fail")
+	}
+	tr, err := p.Exchange(ctx, idp.srv.Client(), idp.clientID, idp.secret, "good-code", "https://kyc.example.com/cb", idp.verifier)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	claims, err := VerifyIDToken(tr.IDToken, keys, p.Issuer, idp.clientID, idp.nonce)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Email != "alice@example.com" || claims.DisplayName() != "Alice Liddell" || claims.EmailVerified == nil || !*claims.EmailVerified {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	idp := newStubIdP(t)
+	keys := []*jwtkeys.Key{{ID: "k1", Algorithm: jwtkeys.AlgRS256, Public: &idp.key.PublicKey}}
+	iss := idp.srv.URL
+
+	if _, err := VerifyIDToken(idp.idToken(t, "kyc-console", "n1", time.Hour), keys, iss, "kyc-console", "n2"); err != ErrNonceMismatch {
+		t.Fatalf("nonce mismatch: got %v", err)
+	}
+	if _, err := VerifyIDToken(idp.idToken(t, "other-client", "n1", time.Hour), keys, iss, "kyc-console", "n1"); err == nil {
+		t.Fatal("foreign audience accepted")
+	}
+	if _, err := VerifyIDToken(idp.idToken(t, "kyc-console", "n1", -time.Hour), keys, iss, "kyc-console", "n1"); err == nil {
+		t.Fatal("expired token accepted")
+	}
+	if _, err := VerifyIDToken(idp.idToken(t, "kyc-console", "n1", time.Hour), keys, "https://evil.example.com", "kyc-console", "n1"); err == nil {
+		t.Fatal("foreign issuer accepted")
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	wrong := []*jwtkeys.Key{{ID: "k1", Algorithm: jwtkeys.AlgRS256, Public: &other.PublicKey}}
+	if _, err := VerifyIDToken(idp.idToken(t, "kyc-console", "n1", time.Hour), wrong, iss, "kyc-console", "n1"); err == nil {
+		t.Fatal("token signed by unknown key accepted")
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Provider{Issuer: "https://evil.example.com", AuthorizationEndpoint: "a", TokenEndpoint: "t", JWKSURI: "j"})
+	}))
+	defer srv.Close()
+	if _, err := Discover(context.Background(), srv.Client(), srv.URL); err != ErrIssuerMismatch {
+		t.Fatalf("expected issuer mismatch, got %v", err)
+	}
+}
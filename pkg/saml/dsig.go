@@ -0,0 +1,180 @@
+package saml
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+const nsDSig = "http://www.w3.org/2000/09/xmldsig#"
+
+var (
+	ErrNotSigned          = errors.New("saml: element is not signed")
+	ErrReferenceMismatch  = errors.New("saml: signature does not reference the signed element")
+	ErrBadSignature       = errors.New("saml: signature verification failed")
+	ErrUnsupportedSigning = errors.New("saml: unsupported signature algorithm")
+
+	errDTDNotAllowed = errors.New("saml: DTD is not allowed")
+)
+
+// weakAlgorithms goxmldsig 仍接受的 SHA-1 签名与摘要算法，这里拒绝
+var weakAlgorithms = map[string]bool{
+	"http://www.w3.org/2000/09/xmldsig#rsa-sha1":        true,
+	"http://www.w3.org/2000/09/xmldsig#dsa-sha1":        true,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha1": true,
+	"http://www.w3.org/2000/09/xmldsig#sha1":            true,
+}
+
+// parseXML 解析 XML 文档并返回根元素；拒绝 DTD
+func parseXML(data []byte) (*etree.Element, error) {
+	if bytes.Contains(data, []byte("<!DOCTYPE")) {
+		return nil, errDTDNotAllowed
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	for _, tok := range doc.Child {
+		if _, ok := tok.(*etree.Directive); ok {
+			return nil, errDTDNotAllowed
+		}
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, fmt.Errorf("%w: empty document", ErrInvalidResponse)
+	}
+	return root, nil
+}
+
+// verifyEnveloped 用 goxmldsig 校验 el 的直接子元素 ds:Signature，返回去掉签名后的已验证副本。
+// 额外要求：恰好一个签名、恰好一个 Reference 且指向 el 自身（URI="#ID"），不接受 SHA-1。
+// 调用方只能从返回的副本读取数据，避免签名包装攻击。
+func verifyEnveloped(el *etree.Element, certs []*x509.Certificate, now time.Time) (*etree.Element, error) {
+	sigs := childrenNamed(el, nsDSig, "Signature")
+	if len(sigs) == 0 {
+		return nil, ErrNotSigned
+	}
+	if len(sigs) > 1 {
+		return nil, errors.New("saml: multiple signatures on element")
+	}
+	si := child(sigs[0], nsDSig, "SignedInfo")
+	refs := childrenNamed(si, nsDSig, "Reference")
+	if len(refs) != 1 {
+		return nil, errors.New("saml: signature must contain exactly one reference")
+	}
+	if id := attr(el, "ID"); id == "" || attr(refs[0], "URI") != "#"+id {
+		return nil, ErrReferenceMismatch
+	}
+	for _, alg := range []string{
+		attr(child(si, nsDSig, "SignatureMethod"), "Algorithm"),
+		attr(child(refs[0], nsDSig, "DigestMethod"), "Algorithm"),
+	} {
+		if alg == "" || weakAlgorithms[alg] {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedSigning, alg)
+		}
+	}
+
+	// 带上祖先元素声明的命名空间后脱离文档，否则被签元素继承的前缀在规范化时无法解析
+	nsCtx, err := etreeutils.NSBuildParentContext(el)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if nsCtx, err = nsCtx.SubContext(el); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	detached, err := etreeutils.NSDetatch(nsCtx, el)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	// 逐个证书校验：签名不带 KeyInfo 时 goxmldsig 只接受唯一的信任证书，元数据在轮换密钥期间可能有多个
+	lastErr := errors.New("no signing certificate")
+	for _, cert := range certs {
+		vctx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: []*x509.Certificate{cert}})
+		vctx.Clock = dsig.NewFakeClockAt(now)
+		out, err := vctx.Validate(detached)
+		if err == nil {
+			return out, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("%w: %v", ErrBadSignature, lastErr)
+}
+
+// is 元素的命名空间与本地名是否匹配
+func is(el *etree.Element, space, local string) bool {
+	return el != nil && el.Tag == local && el.NamespaceURI() == space
+}
+
+// child 第一个匹配的直接子元素
+func child(el *etree.Element, space, local string) *etree.Element {
+	for _, e := range childrenNamed(el, space, local) {
+		return e
+	}
+	return nil
+}
+
+func childrenNamed(el *etree.Element, space, local string) []*etree.Element {
+	if el == nil {
+		return nil
+	}
+	var out []*etree.Element
+	for _, e := range el.ChildElements() {
+		if is(e, space, local) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// attr 无命名空间属性的值
+func attr(el *etree.Element, name string) string {
+	if el == nil {
+		return ""
+	}
+	return el.SelectAttrValue(name, "")
+}
+
+// text 元素的文本内容（含子元素文本），去除首尾空白；注释分隔的文本也会拼接，避免截断攻击
+func text(el *etree.Element) string {
+	if el == nil {
+		return ""
+	}
+	var b strings.Builder
+	var walk func(*etree.Element)
+	walk = func(e *etree.Element) {
+		for _, tok := range e.Child {
+			switch v := tok.(type) {
+			case *etree.CharData:
+				b.WriteString(v.Data)
+			case *etree.Element:
+				walk(v)
+			}
+		}
+	}
+	walk(el)
+	return strings.TrimSpace(b.String())
+}
+
+// decodeBase64 解码可能带换行的 Base64 文本
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return nil, errors.New("saml: empty base64 value")
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
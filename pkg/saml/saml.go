@@ -0,0 +1,420 @@
+// Package saml 实现企业 SSO 所需的 SAML 2.0 服务提供方（SP）最小子集：
+// 解析 IdP 元数据、以 HTTP-Redirect 绑定发起 AuthnRequest、以 HTTP-POST 绑定接收并校验 Response。
+// 签名校验使用 goxmldsig（不接受 SHA-1）；不支持加密断言与 IdP 发起的登录。
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// SAML 命名空间与常量
+const (
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+	BindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingHTTPPOST     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	NameIDFormatEmail   = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+
+	statusSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	methodBearer  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	// ClockSkew 校验时间条件时容忍的时钟偏差
+	ClockSkew = 2 * time.Minute
+	// maxResponseSize SAMLResponse 解码后的大小上限
+	maxResponseSize = 512 << 10
+)
+
+var (
+	ErrEncryptedAssertion = errors.New("saml: encrypted assertions are not supported")
+	ErrInvalidResponse    = errors.New("saml: invalid response")
+	ErrAudienceMismatch   = errors.New("saml: audience does not include this service provider")
+	ErrExpired            = errors.New("saml: assertion is not valid at this time")
+	ErrInResponseTo       = errors.New("saml: response does not match the authentication request")
+)
+
+// StatusError IdP 返回了非成功状态（如用户取消或无权限）
+type StatusError struct {
+	Code    string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("saml: IdP returned status %s: %s", e.Code, e.Message)
+}
+
+// IdPMetadata IdP 元数据中 SP 需要的部分
+type IdPMetadata struct {
+	EntityID     string
+	SSOURL       string // HTTP-Redirect 绑定的 SingleSignOnService 地址
+	Certificates []*x509.Certificate
+}
+
+type xmlEntity struct {
+	XMLName  xml.Name
+	EntityID string      `xml:"entityID,attr"`
+	IDPSSO   []xmlIDPSSO `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+	Entities []xmlEntity `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+}
+
+type xmlIDPSSO struct {
+	Keys []struct {
+		Use   string   `xml:"use,attr"`
+		Certs []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+	SSO []struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+}
+
+// ParseMetadata 解析 IdP 元数据（EntityDescriptor，或取 EntitiesDescriptor 中第一个 IdP）
+func ParseMetadata(data []byte) (*IdPMetadata, error) {
+	if bytes.Contains(data, []byte("<!DOCTYPE")) {
+		return nil, errDTDNotAllowed
+	}
+	var root xmlEntity
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("saml: invalid metadata: %w", err)
+	}
+	candidates := []xmlEntity{root}
+	if root.XMLName.Local == "EntitiesDescriptor" {
+		candidates = root.Entities
+	}
+	for _, e := range candidates {
+		if len(e.IDPSSO) == 0 {
+			continue
+		}
+		md := &IdPMetadata{EntityID: e.EntityID}
+		for _, d := range e.IDPSSO {
+			for _, s := range d.SSO {
+				if s.Binding == BindingHTTPRedirect && md.SSOURL == "" {
+					md.SSOURL = s.Location
+				}
+			}
+			for _, k := range d.Keys {
+				if k.Use != "" && k.Use != "signing" {
+					continue
+				}
+				for _, c := range k.Certs {
+					der, err := decodeBase64(c)
+					if err != nil {
+						return nil, fmt.Errorf("saml: invalid certificate in metadata: %w", err)
+					}
+					cert, err := x509.ParseCertificate(der)
+					if err != nil {
+						return nil, fmt.Errorf("saml: invalid certificate in metadata: %w", err)
+					}
+					md.Certificates = append(md.Certificates, cert)
+				}
+			}
+		}
+		if md.EntityID == "" {
+			return nil, errors.New("saml: metadata has no entityID")
+		}
+		if md.SSOURL == "" {
+			return nil, errors.New("saml: IdP does not offer an HTTP-Redirect SingleSignOnService")
+		}
+		if len(md.Certificates) == 0 {
+			return nil, errors.New("saml: metadata has no signing certificate")
+		}
+		return md, nil
+	}
+	return nil, errors.New("saml: metadata has no IDPSSODescriptor")
+}
+
+// ServiceProvider 本服务作为 SP 的标识与断言接收地址
+type ServiceProvider struct {
+	EntityID string
+	ACSURL   string
+}
+
+// NewRequestID 生成 AuthnRequest ID（XML ID 不能以数字开头）
+func NewRequestID() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "_" + hex.EncodeToString(buf), nil
+}
+
+// AuthnRequestURL 以 HTTP-Redirect 绑定构造登录地址（请求不签名），返回地址与请求 ID
+func (sp *ServiceProvider) AuthnRequestURL(idp *IdPMetadata, relayState string, now time.Time) (string, string, error) {
+	id, err := NewRequestID()
+	if err != nil {
+		return "", "", err
+	}
+	var req bytes.Buffer
+	fmt.Fprintf(&req, `<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s">`,
+		nsProtocol, nsAssertion, id, now.UTC().Format(time.RFC3339), xmlAttr(idp.SSOURL), xmlAttr(sp.ACSURL), BindingHTTPPOST)
+	fmt.Fprintf(&req, `<saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy Format="%s" AllowCreate="true"/></samlp:AuthnRequest>`,
+		xmlAttr(sp.EntityID), NameIDFormatEmail)
+
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := w.Write(req.Bytes()); err != nil {
+		return "", "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+	q := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString(deflated.Bytes())}}
+	if relayState != "" {
+		q.Set("RelayState", relayState)
+	}
+	sep := "?"
+	if strings.Contains(idp.SSOURL, "?") {
+		sep = "&"
+	}
+	return idp.SSOURL + sep + q.Encode(), id, nil
+}
+
+// Metadata SP 元数据，供 IdP 管理员导入
+func (sp *ServiceProvider) Metadata() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="%s" entityID="%s">
+  <md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="%s">
+    <md:NameIDFormat>%s</md:NameIDFormat>
+    <md:AssertionConsumerService Binding="%s" Location="%s" index="1" isDefault="true"/>
+  </md:SPSSODescriptor>
+</md:EntityDescriptor>
+`, nsMetadata, xmlAttr(sp.EntityID), nsProtocol, NameIDFormatEmail, BindingHTTPPOST, xmlAttr(sp.ACSURL))
+	return b.Bytes()
+}
+
+func xmlAttr(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Assertion 校验通过的断言中登录所需的信息
+type Assertion struct {
+	ID           string
+	Issuer       string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	NotOnOrAfter time.Time // 断言有效期截止，调用方据此保留防重放记录
+	Attributes   map[string][]string
+}
+
+// 常见 IdP 的邮箱与姓名属性名
+var (
+	emailAttributes = []string{"email", "mail", "emailaddress", "Email",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress", "urn:oid:0.9.2342.19200300.100.1.3"}
+	nameAttributes = []string{"name", "displayName", "http://schemas.microsoft.com/identity/claims/displayname",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name", "urn:oid:2.16.840.1.113730.3.1.241"}
+	givenNameAttributes = []string{"firstName", "givenName", "given_name",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname", "urn:oid:2.5.4.42"}
+	surnameAttributes = []string{"lastName", "surname", "sn", "family_name",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname", "urn:oid:2.5.4.4"}
+)
+
+func (a *Assertion) first(names []string) string {
+	for _, n := range names {
+		if v := a.Attributes[n]; len(v) > 0 && strings.TrimSpace(v[0]) != "" {
+			return strings.TrimSpace(v[0])
+		}
+	}
+	return ""
+}
+
+// Email NameID 为邮箱格式时直接使用，否则取邮箱属性
+func (a *Assertion) Email() string {
+	if a.NameIDFormat == NameIDFormatEmail && strings.Contains(a.NameID, "@") {
+		return a.NameID
+	}
+	if v := a.first(emailAttributes); v != "" {
+		return v
+	}
+	if strings.Contains(a.NameID, "@") {
+		return a.NameID
+	}
+	return ""
+}
+
+// DisplayName 显示名，其次名+姓
+func (a *Assertion) DisplayName() string {
+	if v := a.first(nameAttributes); v != "" && !strings.Contains(v, "@") {
+		return v
+	}
+	return strings.TrimSpace(a.first(givenNameAttributes) + " " + a.first(surnameAttributes))
+}
+
+// ParseResponse 解码并校验 HTTP-POST 绑定的 SAMLResponse：签名（Response 或 Assertion 至少一处）、
+// 颁发者、受众、有效期、Bearer 确认（Recipient/InResponseTo）。requestID 为发起登录时的 AuthnRequest ID。
+func (sp *ServiceProvider) ParseResponse(samlResponse string, idp *IdPMetadata, requestID string, now time.Time) (*Assertion, error) {
+	raw, err := decodeBase64(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if len(raw) > maxResponseSize {
+		return nil, fmt.Errorf("%w: response too large", ErrInvalidResponse)
+	}
+	resp, err := parseXML(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !is(resp, nsProtocol, "Response") {
+		return nil, fmt.Errorf("%w: root element is not samlp:Response", ErrInvalidResponse)
+	}
+	if dest := attr(resp, "Destination"); dest != "" && dest != sp.ACSURL {
+		return nil, fmt.Errorf("%w: unexpected destination %q", ErrInvalidResponse, dest)
+	}
+	if irt := attr(resp, "InResponseTo"); irt != "" && irt != requestID {
+		return nil, ErrInResponseTo
+	}
+	status := child(resp, nsProtocol, "Status")
+	if code := child(status, nsProtocol, "StatusCode"); attr(code, "Value") != statusSuccess {
+		se := &StatusError{Code: attr(code, "Value")}
+		if sub := child(code, nsProtocol, "StatusCode"); sub != nil {
+			se.Code += " / " + attr(sub, "Value")
+		}
+		se.Message = text(child(status, nsProtocol, "StatusMessage"))
+		return nil, se
+	}
+	if child(resp, nsAssertion, "EncryptedAssertion") != nil {
+		return nil, ErrEncryptedAssertion
+	}
+	as, err := singleAssertion(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response 与 Assertion 的签名存在即必须有效，且至少其一存在；之后只读取已验证的副本
+	signedResp, err := verifyEnveloped(resp, idp.Certificates, now)
+	switch {
+	case err == nil:
+		if as, err = singleAssertion(signedResp); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, ErrNotSigned):
+		return nil, err
+	}
+	if signedResp == nil || child(as, nsDSig, "Signature") != nil {
+		if as, err = verifyEnveloped(as, idp.Certificates, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if iss := text(child(as, nsAssertion, "Issuer")); idp.EntityID != "" && iss != idp.EntityID {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidResponse, iss)
+	}
+	out := &Assertion{ID: attr(as, "ID"), Issuer: idp.EntityID, Attributes: map[string][]string{}}
+
+	cond := child(as, nsAssertion, "Conditions")
+	if cond == nil {
+		return nil, fmt.Errorf("%w: missing conditions", ErrInvalidResponse)
+	}
+	if nb, ok := parseTime(attr(cond, "NotBefore")); ok && now.Add(ClockSkew).Before(nb) {
+		return nil, ErrExpired
+	}
+	if noa, ok := parseTime(attr(cond, "NotOnOrAfter")); ok {
+		if !now.Add(-ClockSkew).Before(noa) {
+			return nil, ErrExpired
+		}
+		out.NotOnOrAfter = noa
+	}
+	restrictions := childrenNamed(cond, nsAssertion, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return nil, ErrAudienceMismatch
+	}
+	for _, r := range restrictions {
+		matched := false
+		for _, a := range childrenNamed(r, nsAssertion, "Audience") {
+			if text(a) == sp.EntityID {
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, ErrAudienceMismatch
+		}
+	}
+
+	subject := child(as, nsAssertion, "Subject")
+	nameID := child(subject, nsAssertion, "NameID")
+	if nameID == nil {
+		return nil, fmt.Errorf("%w: missing NameID", ErrInvalidResponse)
+	}
+	out.NameID, out.NameIDFormat = text(nameID), attr(nameID, "Format")
+	if err := checkBearer(subject, sp.ACSURL, requestID, now); err != nil {
+		return nil, err
+	}
+	if out.NotOnOrAfter.IsZero() {
+		out.NotOnOrAfter = now.Add(5 * time.Minute)
+	}
+
+	if st := child(as, nsAssertion, "AuthnStatement"); st != nil {
+		out.SessionIndex = attr(st, "SessionIndex")
+	}
+	for _, stmt := range childrenNamed(as, nsAssertion, "AttributeStatement") {
+		for _, a := range childrenNamed(stmt, nsAssertion, "Attribute") {
+			for _, v := range childrenNamed(a, nsAssertion, "AttributeValue") {
+				out.Attributes[attr(a, "Name")] = append(out.Attributes[attr(a, "Name")], text(v))
+			}
+		}
+	}
+	return out, nil
+}
+
+// checkBearer 至少一个 bearer 确认满足：Recipient 为本 SP 的 ACS，未过期，InResponseTo 对应发起的请求
+func checkBearer(subject *etree.Element, acsURL, requestID string, now time.Time) error {
+	var lastErr error = fmt.Errorf("%w: missing bearer subject confirmation", ErrInvalidResponse)
+	for _, sc := range childrenNamed(subject, nsAssertion, "SubjectConfirmation") {
+		if attr(sc, "Method") != methodBearer {
+			continue
+		}
+		data := child(sc, nsAssertion, "SubjectConfirmationData")
+		if data == nil {
+			continue
+		}
+		noa, ok := parseTime(attr(data, "NotOnOrAfter"))
+		switch {
+		case attr(data, "Recipient") != acsURL:
+			lastErr = fmt.Errorf("%w: unexpected recipient %q", ErrInvalidResponse, attr(data, "Recipient"))
+		case !ok || !now.Add(-ClockSkew).Before(noa):
+			lastErr = ErrExpired
+		case attr(data, "InResponseTo") != requestID:
+			lastErr = ErrInResponseTo
+		default:
+			return nil
+		}
+	}
+	return lastErr
+}
+
+// singleAssertion Response 中唯一的 Assertion；多个时拒绝（签名包装攻击常见手法）
+func singleAssertion(resp *etree.Element) (*etree.Element, error) {
+	assertions := childrenNamed(resp, nsAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("%w: expected exactly one assertion, got %d", ErrInvalidResponse, len(assertions))
+	}
+	return assertions[0], nil
+}
+
+func parseTime(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
@@ -0,0 +1,247 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+func TestParseRejectsDTD(t *testing.T) {
+	doc := `<?xml version="1.0"?><!DOCTYPE a [<!ENTITY x "y">]><a>&x;</a>`
+	if _, err := testSP.ParseResponse(encode(doc), &IdPMetadata{}, "_req1", time.Now()); !errors.Is(err, errDTDNotAllowed) {
+		t.Fatalf("expected DTD rejection, got %v", err)
+	}
+	if _, err := ParseMetadata([]byte(doc)); !errors.Is(err, errDTDNotAllowed) {
+		t.Fatalf("expected DTD rejection, got %v", err)
+	}
+}
+
+// testIdP 测试用 IdP：自签证书与签名工具
+type testIdP struct {
+	key *rsa.PrivateKey
+	der []byte
+	md  *IdPMetadata
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := fmt.Sprintf(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/saml">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>
+%s
+    </ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso/redirect"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`, base64.StdEncoding.EncodeToString(der))
+	md, err := ParseMetadata([]byte(metadata))
+	if err != nil {
+		t.Fatalf("parse metadata: %v", err)
+	}
+	if md.EntityID != "https://idp.example.com/saml" || md.SSOURL != "https://idp.example.com/sso/redirect" || len(md.Certificates) != 1 {
+		t.Fatalf("unexpected metadata: %+v", md)
+	}
+	return &testIdP{key: key, der: der, md: md}
+}
+
+// sign 用 goxmldsig 对文档中 ID 为 id 的元素做 enveloped 签名（exclusive c14n + RSA-SHA256）
+func (idp *testIdP) sign(t *testing.T, doc, id string) string {
+	t.Helper()
+	return idp.signWith(t, doc, id, dsig.RSASHA256SignatureMethod)
+}
+
+func (idp *testIdP) signWith(t *testing.T, doc, id, method string) string {
+	t.Helper()
+	d := etree.NewDocument()
+	if err := d.ReadFromString(doc); err != nil {
+		t.Fatal(err)
+	}
+	el := d.FindElement("//[@ID='" + id + "']")
+	if el == nil {
+		t.Fatalf("element %s not found", id)
+	}
+	nsCtx, err := etreeutils.NSBuildParentContext(el)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nsCtx, err = nsCtx.SubContext(el); err != nil {
+		t.Fatal(err)
+	}
+	detached, err := etreeutils.NSDetatch(nsCtx, el)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(tls.Certificate{Certificate: [][]byte{idp.der}, PrivateKey: idp.key}))
+	ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	if err := ctx.SetSignatureMethod(method); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := ctx.SignEnveloped(detached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Root() == el {
+		d.SetRoot(signed)
+	} else {
+		parent, i := el.Parent(), el.Index()
+		parent.RemoveChildAt(i)
+		parent.InsertChildAt(i, signed)
+	}
+	out, err := d.WriteToString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+var testSP = &ServiceProvider{EntityID: "https://kyc.example.com/sp", ACSURL: "https://kyc.example.com/acs"}
+
+func responseDoc(now time.Time, requestID, email string) string {
+	ts := func(d time.Duration) string { return now.Add(d).UTC().Format(time.RFC3339) }
+	return fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_resp1" Version="2.0" IssueInstant="%[1]s" Destination="%[2]s" InResponseTo="%[3]s">
+  <saml:Issuer>https://idp.example.com/saml</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion ID="_assert1" Version="2.0" IssueInstant="%[1]s">
+    <saml:Issuer>https://idp.example.com/saml</saml:Issuer>
+    <saml:Subject>
+      <saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">%[4]s</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData NotOnOrAfter="%[5]s" Recipient="%[2]s" InResponseTo="%[3]s"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="%[6]s" NotOnOrAfter="%[5]s">
+      <saml:AudienceRestriction><saml:Audience>%[7]s</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AuthnStatement AuthnInstant="%[1]s" SessionIndex="_sess1"/>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="firstName"><saml:AttributeValue>Alice</saml:AttributeValue></saml:Attribute>
+      <saml:Attribute Name="lastName"><saml:AttributeValue>Liddell</saml:AttributeValue></saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`, ts(0), testSP.ACSURL, requestID, email, ts(5*time.Minute), ts(-time.Minute), testSP.EntityID)
+}
+
+func encode(doc string) string {
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestParseResponse(t *testing.T) {
+	idp := newTestIdP(t)
+	now := time.Now()
+	doc := responseDoc(now, "_req1", "alice@example.com")
+
+	for _, id := range []string{"_assert1", "_resp1"} {
+		t.Run("signed "+id, func(t *testing.T) {
+			a, err := testSP.ParseResponse(encode(idp.sign(t, doc, id)), idp.md, "_req1", now)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if a.Email() != "alice@example.com" || a.DisplayName() != "Alice Liddell" || a.ID != "_assert1" || a.SessionIndex != "_sess1" {
+				t.Fatalf("unexpected assertion: %+v", a)
+			}
+		})
+	}
+}
+
+func TestParseResponseRejects(t *testing.T) {
+	idp := newTestIdP(t)
+	now := time.Now()
+	signed := idp.sign(t, responseDoc(now, "_req1", "alice@example.com"), "_assert1")
+
+	other := newTestIdP(t)
+	cases := []struct {
+		name string
+		doc  string
+		idp  *IdPMetadata
+		req  string
+		now  time.Time
+	}{
+		{"unsigned", responseDoc(now, "_req1", "alice@example.com"), idp.md, "_req1", now},
+		{"tampered subject", strings.Replace(signed, "alice@example.com", "mallory@example.com", 1), idp.md, "_req1", now},
+		{"unknown signer", signed, other.md, "_req1", now},
+		{"sha1 signature", idp.signWith(t, responseDoc(now, "_req1", "alice@example.com"), "_assert1", dsig.RSASHA1SignatureMethod), idp.md, "_req1", now},
+		{"wrong request", signed, idp.md, "_req2", now},
+		{"expired", signed, idp.md, "_req1", now.Add(10 * time.Minute)},
+		{"wrapped second assertion", strings.Replace(signed, "</samlp:Response>",
+			`<saml:Assertion ID="_evil"><saml:Subject><saml:NameID>mallory@example.com</saml:NameID></saml:Subject></saml:Assertion></samlp:Response>`, 1),
+			idp.md, "_req1", now},
+		{"encrypted assertion", strings.Replace(responseDoc(now, "_req1", "a@example.com"), "<saml:Assertion ", "<saml:EncryptedAssertion/><saml:Assertion ", 1),
+			idp.md, "_req1", now},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := testSP.ParseResponse(encode(tc.doc), tc.idp, tc.req, tc.now); err == nil {
+				t.Fatal("expected rejection")
+			}
+		})
+	}
+
+	audience := &ServiceProvider{EntityID: "https://other.example.com/sp", ACSURL: testSP.ACSURL}
+	if _, err := audience.ParseResponse(encode(signed), idp.md, "_req1", now); !errors.Is(err, ErrAudienceMismatch) {
+		t.Fatalf("expected audience mismatch, got %v", err)
+	}
+	failed := strings.Replace(responseDoc(now, "_req1", "a@example.com"), "status:Success", "status:Responder", 1)
+	var se *StatusError
+	if _, err := testSP.ParseResponse(encode(failed), idp.md, "_req1", now); !errors.As(err, &se) {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	idp := newTestIdP(t)
+	u, id, err := testSP.AuthnRequestURL(idp.md, "relay-1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(u)
+	if !strings.HasPrefix(u, idp.md.SSOURL+"?") || parsed.Query().Get("RelayState") != "relay-1" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+	raw, err := base64.StdEncoding.DecodeString(parsed.Query().Get("SAMLRequest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmlReq, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := parseXML(xmlReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !is(req, nsProtocol, "AuthnRequest") || attr(req, "ID") != id || attr(req, "AssertionConsumerServiceURL") != testSP.ACSURL ||
+		text(child(req, nsAssertion, "Issuer")) != testSP.EntityID {
+		t.Fatalf("unexpected request: %s", xmlReq)
+	}
+}
@@ -0,0 +1,495 @@
+// Package scim 实现 SCIM 2.0（RFC 7643/7644）中 Users、Groups 资源所需的协议结构：
+// 资源表示、列表响应、错误响应、简单过滤表达式与 PATCH 操作。
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType SCIM 响应类型
+const ContentType = "application/scim+json"
+
+// 资源与消息 schema
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// 错误响应中的 scimType
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrInvalidPath   = "invalidPath"
+	ErrInvalidValue  = "invalidValue"
+	ErrNoTarget      = "noTarget"
+	ErrMutability    = "mutability"
+	ErrUniqueness    = "uniqueness"
+)
+
+// Error SCIM 错误响应（status 为字符串）
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+	code     int
+}
+
+// NewError 构造错误响应
+func NewError(status int, scimType, detail string) *Error {
+	return &Error{Schemas: []string{SchemaError}, Status: strconv.Itoa(status), ScimType: scimType, Detail: detail, code: status}
+}
+
+func (e *Error) Error() string { return e.Detail }
+
+// HTTPStatus 错误对应的 HTTP 状态码
+func (e *Error) HTTPStatus() int { return e.code }
+
+func badRequest(scimType, format string, args ...interface{}) *Error {
+	return NewError(400, scimType, fmt.Sprintf(format, args...))
+}
+
+// Meta 资源元信息
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+// Name 用户姓名
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Email 用户邮箱
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Ref 多值引用（用户所在组 / 组成员）
+type Ref struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User SCIM 用户资源
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Groups      []Ref    `json:"groups,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// IsActive 未提供 active 时视为启用
+func (u *User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// FullName 按 displayName、name.formatted、givenName+familyName 的顺序取姓名
+func (u *User) FullName() string {
+	if s := strings.TrimSpace(u.DisplayName); s != "" {
+		return s
+	}
+	if u.Name == nil {
+		return ""
+	}
+	if s := strings.TrimSpace(u.Name.Formatted); s != "" {
+		return s
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// PrimaryEmail 主邮箱；未提供邮箱时 userName 即邮箱
+func (u *User) PrimaryEmail() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return strings.TrimSpace(e.Value)
+		}
+	}
+	if len(u.Emails) > 0 {
+		return strings.TrimSpace(u.Emails[0].Value)
+	}
+	return strings.TrimSpace(u.UserName)
+}
+
+// Group SCIM 组资源
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Ref    `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// ListResponse 查询结果
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// NewListResponse 构造列表响应，resources 应为切片
+func NewListResponse(resources interface{}, n int, total int64, startIndex int) *ListResponse {
+	return &ListResponse{Schemas: []string{SchemaListResponse}, TotalResults: total, StartIndex: startIndex, ItemsPerPage: n, Resources: resources}
+}
+
+// Pagination 解析 startIndex（从 1 开始）与 count，count 上限为 max
+func Pagination(startIndex, count string, max int) (int, int) {
+	start, err := strconv.Atoi(startIndex)
+	if err != nil || start < 1 {
+		start = 1
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 || n > max {
+		n = max
+	}
+	return start, n
+}
+
+// Filter 仅支持单个相等比较（attr eq "value"），覆盖主流 IdP 的查重请求
+type Filter struct {
+	Attr  string // 小写属性路径，如 username、externalid、emails.value
+	Value string
+}
+
+// ParseFilter 解析过滤表达式，空串返回 nil
+func ParseFilter(s string) (*Filter, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	attr, rest, ok := cutSpace(s)
+	if !ok {
+		return nil, badRequest(ErrInvalidFilter, "unsupported filter %q", s)
+	}
+	op, value, ok := cutSpace(rest)
+	if !ok || !strings.EqualFold(op, "eq") {
+		return nil, badRequest(ErrInvalidFilter, "only the eq operator is supported")
+	}
+	v, err := filterValue(strings.TrimSpace(value))
+	if err != nil {
+		return nil, badRequest(ErrInvalidFilter, "invalid filter value: %v", err)
+	}
+	return &Filter{Attr: strings.ToLower(attr), Value: v}, nil
+}
+
+func cutSpace(s string) (string, string, bool) {
+	i := strings.IndexByte(s, ' ')
+	if i <= 0 {
+		return "", "", false
+	}
+	return s[:i], strings.TrimSpace(s[i+1:]), true
+}
+
+func filterValue(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		var v string
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return "", err
+		}
+		return v, nil
+	}
+	switch strings.ToLower(s) {
+	case "true", "false":
+		return strings.ToLower(s), nil
+	}
+	return "", errors.New("value must be a quoted string or boolean")
+}
+
+// PatchRequest PATCH 请求体
+type PatchRequest struct {
+	Schemas    []string  `json:"schemas"`
+	Operations []PatchOp `json:"Operations"`
+}
+
+// PatchOp 单个 PATCH 操作；op 大小写不敏感（部分 IdP 发送 "Replace"）
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (op PatchOp) kind() (string, error) {
+	switch k := strings.ToLower(op.Op); k {
+	case "add", "replace", "remove":
+		return k, nil
+	default:
+		return "", badRequest(ErrInvalidSyntax, "unsupported patch op %q", op.Op)
+	}
+}
+
+// parseBool 兼容部分 IdP 以字符串 "True"/"False" 表示布尔值
+func parseBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if v, err := strconv.ParseBool(s); err == nil {
+			return v, nil
+		}
+	}
+	return false, badRequest(ErrInvalidValue, "expected boolean, got %s", string(raw))
+}
+
+func parseString(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", badRequest(ErrInvalidValue, "expected string, got %s", string(raw))
+	}
+	return s, nil
+}
+
+// ApplyPatch 将 PATCH 操作应用到用户表示上（调用方随后按整体替换保存）；
+// 支持 active、userName、externalId、displayName、name.* 以及无 path 的属性对象
+func (u *User) ApplyPatch(ops []PatchOp) error {
+	for _, op := range ops {
+		kind, err := op.kind()
+		if err != nil {
+			return err
+		}
+		if op.Path == "" {
+			if kind == "remove" {
+				return badRequest(ErrNoTarget, "remove requires a path")
+			}
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return badRequest(ErrInvalidValue, "value must be an object when path is omitted")
+			}
+			for k, v := range attrs {
+				if err := u.setAttr(k, v, false); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := u.setAttr(op.Path, op.Value, kind == "remove"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *User) setAttr(path string, raw json.RawMessage, remove bool) error {
+	p := strings.ToLower(path)
+	p = strings.TrimPrefix(p, strings.ToLower(SchemaUser)+":")
+	if u.Name == nil {
+		u.Name = &Name{}
+	}
+	str := func(dst *string) error {
+		if remove {
+			*dst = ""
+			return nil
+		}
+		s, err := parseString(raw)
+		if err != nil {
+			return err
+		}
+		*dst = s
+		return nil
+	}
+	switch p {
+	case "active":
+		if remove {
+			return badRequest(ErrMutability, "active cannot be removed")
+		}
+		b, err := parseBool(raw)
+		if err != nil {
+			return err
+		}
+		u.Active = &b
+		return nil
+	case "username":
+		if remove {
+			return badRequest(ErrMutability, "userName cannot be removed")
+		}
+		return str(&u.UserName)
+	case "externalid":
+		return str(&u.ExternalID)
+	case "displayname":
+		return str(&u.DisplayName)
+	case "name.formatted":
+		return str(&u.Name.Formatted)
+	case "name.givenname":
+		return str(&u.Name.GivenName)
+	case "name.familyname":
+		return str(&u.Name.FamilyName)
+	case "name":
+		if remove {
+			u.Name = &Name{}
+			return nil
+		}
+		var n Name
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return badRequest(ErrInvalidValue, "invalid name")
+		}
+		u.Name = &n
+		return nil
+	case "emails", `emails[type eq "work"].value`, `emails[primary eq true].value`:
+		// 邮箱与 userName 一致，不单独修改
+		return nil
+	default:
+		// 忽略未支持的属性（如 title、phoneNumbers），避免 IdP 同步整体失败
+		return nil
+	}
+}
+
+// ApplyPatch 将 PATCH 操作应用到组表示上；支持 displayName、externalId 与 members 的增删改，
+// 包括 members[value eq "id"] 形式的路径
+func (g *Group) ApplyPatch(ops []PatchOp) error {
+	for _, op := range ops {
+		kind, err := op.kind()
+		if err != nil {
+			return err
+		}
+		path := strings.ToLower(strings.TrimSpace(op.Path))
+		switch {
+		case path == "":
+			if kind == "remove" {
+				return badRequest(ErrNoTarget, "remove requires a path")
+			}
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return badRequest(ErrInvalidValue, "value must be an object when path is omitted")
+			}
+			for k, v := range attrs {
+				if err := g.patchAttr(kind, strings.ToLower(k), v); err != nil {
+					return err
+				}
+			}
+		case strings.HasPrefix(path, "members["):
+			if kind != "remove" {
+				return badRequest(ErrInvalidPath, "only remove is supported with a members filter")
+			}
+			f, err := ParseFilter(strings.TrimSuffix(op.Path[len("members["):], "]"))
+			if err != nil || f == nil || f.Attr != "value" || !strings.HasSuffix(path, "]") {
+				return badRequest(ErrInvalidPath, "unsupported path %q", op.Path)
+			}
+			g.removeMembers([]Ref{{Value: f.Value}})
+		default:
+			if err := g.patchAttr(kind, path, op.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Group) patchAttr(kind, path string, raw json.RawMessage) error {
+	switch path {
+	case "displayname":
+		if kind == "remove" {
+			return badRequest(ErrMutability, "displayName cannot be removed")
+		}
+		s, err := parseString(raw)
+		if err != nil {
+			return err
+		}
+		g.DisplayName = s
+	case "externalid":
+		if kind == "remove" {
+			g.ExternalID = ""
+			return nil
+		}
+		s, err := parseString(raw)
+		if err != nil {
+			return err
+		}
+		g.ExternalID = s
+	case "members":
+		var refs []Ref
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &refs); err != nil {
+				return badRequest(ErrInvalidValue, "members must be an array")
+			}
+		}
+		switch kind {
+		case "add":
+			g.addMembers(refs)
+		case "replace":
+			g.Members = nil
+			g.addMembers(refs)
+		case "remove":
+			if len(refs) == 0 {
+				g.Members = nil
+			} else {
+				g.removeMembers(refs)
+			}
+		}
+	default:
+		return badRequest(ErrInvalidPath, "unsupported path %q", path)
+	}
+	return nil
+}
+
+func (g *Group) addMembers(refs []Ref) {
+	seen := map[string]bool{}
+	for _, m := range g.Members {
+		seen[m.Value] = true
+	}
+	for _, r := range refs {
+		if r.Value != "" && !seen[r.Value] {
+			seen[r.Value] = true
+			g.Members = append(g.Members, Ref{Value: r.Value})
+		}
+	}
+}
+
+func (g *Group) removeMembers(refs []Ref) {
+	drop := map[string]bool{}
+	for _, r := range refs {
+		drop[r.Value] = true
+	}
+	kept := g.Members[:0]
+	for _, m := range g.Members {
+		if !drop[m.Value] {
+			kept = append(kept, m)
+		}
+	}
+	g.Members = kept
+}
+
+// ServiceProviderConfig 声明本服务支持的 SCIM 特性
+func ServiceProviderConfig(maxResults int) map[string]interface{} {
+	unsupported := map[string]bool{"supported": false}
+	return map[string]interface{}{
+		"schemas":        []string{SchemaServiceProviderConfig},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxResults},
+		"changePassword": unsupported,
+		"sort":           unsupported,
+		"etag":           unsupported,
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Organization-scoped SCIM token",
+			"primary":     true,
+		}},
+	}
+}
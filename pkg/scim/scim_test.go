@@ -0,0 +1,112 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(`userName eq "Alice@Example.com"`)
+	require.NoError(t, err)
+	assert.Equal(t, &Filter{Attr: "username", Value: "Alice@Example.com"}, f)
+
+	f, err = ParseFilter(`externalId EQ "00u1\"x"`)
+	require.NoError(t, err)
+	assert.Equal(t, &Filter{Attr: "externalid", Value: `00u1"x`}, f)
+
+	f, err = ParseFilter(`active eq True`)
+	require.NoError(t, err)
+	assert.Equal(t, "true", f.Value)
+
+	f, err = ParseFilter("  ")
+	require.NoError(t, err)
+	assert.Nil(t, f)
+
+	for _, bad := range []string{`userName co "a"`, `userName eq a`, `userName`, `userName eq "a" and active eq true`} {
+		_, err := ParseFilter(bad)
+		var se *Error
+		require.True(t, errors.As(err, &se), bad)
+		assert.Equal(t, 400, se.HTTPStatus())
+		assert.Equal(t, ErrInvalidFilter, se.ScimType)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	start, n := Pagination("", "", 100)
+	assert.Equal(t, 1, start)
+	assert.Equal(t, 100, n)
+	start, n = Pagination("0", "500", 100)
+	assert.Equal(t, 1, start)
+	assert.Equal(t, 100, n)
+	start, n = Pagination("11", "0", 100)
+	assert.Equal(t, 11, start)
+	assert.Equal(t, 0, n)
+}
+
+func patchOps(t *testing.T, raw string) []PatchOp {
+	var req PatchRequest
+	require.NoError(t, json.Unmarshal([]byte(raw), &req))
+	return req.Operations
+}
+
+func TestUserApplyPatch(t *testing.T) {
+	active := true
+	u := &User{UserName: "a@example.com", Active: &active}
+
+	// Azure AD 风格：op 首字母大写、布尔值为字符串
+	require.NoError(t, u.ApplyPatch(patchOps(t, `{"Operations":[
+		{"op":"Replace","path":"active","value":"False"},
+		{"op":"replace","path":"name.givenName","value":"Alice"},
+		{"op":"add","path":"title","value":"ignored"}]}`)))
+	assert.False(t, u.IsActive())
+	assert.Equal(t, "Alice", u.Name.GivenName)
+
+	// Okta 风格：无 path，value 为属性对象
+	require.NoError(t, u.ApplyPatch(patchOps(t, `{"Operations":[{"op":"replace","value":{"active":true,"externalId":"00u1","displayName":"Alice A"}}]}`)))
+	assert.True(t, u.IsActive())
+	assert.Equal(t, "00u1", u.ExternalID)
+	assert.Equal(t, "Alice A", u.FullName())
+
+	err := u.ApplyPatch(patchOps(t, `{"Operations":[{"op":"replace","path":"active","value":"maybe"}]}`))
+	var se *Error
+	require.True(t, errors.As(err, &se))
+	assert.Equal(t, ErrInvalidValue, se.ScimType)
+
+	err = u.ApplyPatch(patchOps(t, `{"Operations":[{"op":"move","path":"active","value":true}]}`))
+	require.True(t, errors.As(err, &se))
+	assert.Equal(t, ErrInvalidSyntax, se.ScimType)
+}
+
+func members(g *Group) []string {
+	var out []string
+	for _, m := range g.Members {
+		out = append(out, m.Value)
+	}
+	return out
+}
+
+func TestGroupApplyPatch(t *testing.T) {
+	g := &Group{DisplayName: "KYC Admins", Members: []Ref{{Value: "u1"}}}
+
+	require.NoError(t, g.ApplyPatch(patchOps(t, `{"Operations":[{"op":"add","path":"members","value":[{"value":"u2"},{"value":"u1"}]}]}`)))
+	assert.Equal(t, []string{"u1", "u2"}, members(g))
+
+	require.NoError(t, g.ApplyPatch(patchOps(t, `{"Operations":[{"op":"remove","path":"members[value eq \"u1\"]"}]}`)))
+	assert.Equal(t, []string{"u2"}, members(g))
+
+	require.NoError(t, g.ApplyPatch(patchOps(t, `{"Operations":[{"op":"Remove","path":"members","value":[{"value":"u2"}]}]}`)))
+	assert.Empty(t, members(g))
+
+	require.NoError(t, g.ApplyPatch(patchOps(t, `{"Operations":[{"op":"replace","value":{"displayName":"KYC Ops","members":[{"value":"u3"}]}}]}`)))
+	assert.Equal(t, "KYC Ops", g.DisplayName)
+	assert.Equal(t, []string{"u3"}, members(g))
+
+	err := g.ApplyPatch(patchOps(t, `{"Operations":[{"op":"add","path":"members[value eq \"u4\"]"}]}`))
+	var se *Error
+	require.True(t, errors.As(err, &se))
+	assert.Equal(t, ErrInvalidPath, se.ScimType)
+}
@@ -0,0 +1,49 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 附录B 的 SHA1 测试向量（取后6位）
+func TestCodeRFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Now()
+	prev, err := Code(secret, Step(now)-1)
+	require.NoError(t, err)
+	old, err := Code(secret, Step(now)-3)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, prev, now)
+	assert.True(t, ok, "允许前一个时间步")
+	assert.Equal(t, Step(now)-1, step)
+	if old != prev {
+		_, ok = Validate(secret, old, now)
+		assert.False(t, ok, "超出容忍窗口的口令应被拒绝")
+	}
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok)
+}
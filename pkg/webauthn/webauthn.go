@@ -0,0 +1,345 @@
+// Package webauthn 实现控制台二次验证所需的 WebAuthn 依赖方（RP）最小子集：
+// 注册时仅信任凭证公钥本身（attestation: none，不校验证明链），登录时校验断言签名与签名计数。
+// 支持 ES256（P-256）与 RS256 两类公钥。
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+)
+
+// COSE 算法标识
+const (
+	AlgES256 = -7
+	AlgRS256 = -257
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+var (
+	ErrChallengeMismatch = errors.New("webauthn: challenge mismatch")
+	ErrOriginNotAllowed  = errors.New("webauthn: origin not allowed")
+	ErrRPIDMismatch      = errors.New("webauthn: rp id hash mismatch")
+	ErrUserNotPresent    = errors.New("webauthn: user presence flag not set")
+	ErrBadSignature      = errors.New("webauthn: signature verification failed")
+	ErrSignCount         = errors.New("webauthn: sign count did not increase, authenticator may be cloned")
+)
+
+// RelyingParty 依赖方配置：ID 为注册域名（如 console.example.com），Origins 为允许的前端来源
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// Credential 注册成功后需要保存的凭证信息
+type Credential struct {
+	ID        string // base64url
+	PublicKey crypto.PublicKey
+	Algorithm int
+	SignCount uint32
+	AAGUID    []byte
+}
+
+// CredentialCreationResponse navigator.credentials.create() 的结果（二进制字段为 base64url）
+type CredentialCreationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// CredentialAssertionResponse navigator.credentials.get() 的结果（二进制字段为 base64url）
+type CredentialAssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+type collectedClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type attestationObject struct {
+	Fmt      string                 `codec:"fmt"`
+	AuthData []byte                 `codec:"authData"`
+	AttStmt  map[string]interface{} `codec:"attStmt"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	aaguid    []byte
+	credID    []byte
+	credKey   []byte
+}
+
+// NewChallenge 生成 32 字节随机挑战（base64url）
+func NewChallenge() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CreationOptions 生成 PublicKeyCredentialCreationOptions，excludeIDs 为用户已注册的凭证
+func (rp RelyingParty) CreationOptions(challenge, userID, userName, displayName string, excludeIDs []string) map[string]interface{} {
+	exclude := make([]map[string]string, 0, len(excludeIDs))
+	for _, id := range excludeIDs {
+		exclude = append(exclude, map[string]string{"type": "public-key", "id": id})
+	}
+	return map[string]interface{}{
+		"challenge": challenge,
+		"rp":        map[string]string{"id": rp.ID, "name": rp.Name},
+		"user": map[string]string{
+			"id":          base64.RawURLEncoding.EncodeToString([]byte(userID)),
+			"name":        userName,
+			"displayName": displayName,
+		},
+		"pubKeyCredParams": []map[string]interface{}{
+			{"type": "public-key", "alg": AlgES256},
+			{"type": "public-key", "alg": AlgRS256},
+		},
+		"timeout":                300000,
+		"attestation":            "none",
+		"excludeCredentials":     exclude,
+		"authenticatorSelection": map[string]string{"userVerification": "preferred"},
+	}
+}
+
+// AssertionOptions 生成 PublicKeyCredentialRequestOptions
+func (rp RelyingParty) AssertionOptions(challenge string, allowIDs []string) map[string]interface{} {
+	allow := make([]map[string]string, 0, len(allowIDs))
+	for _, id := range allowIDs {
+		allow = append(allow, map[string]string{"type": "public-key", "id": id})
+	}
+	return map[string]interface{}{
+		"challenge":        challenge,
+		"rpId":             rp.ID,
+		"timeout":          300000,
+		"allowCredentials": allow,
+		"userVerification": "preferred",
+	}
+}
+
+// VerifyRegistration 校验注册响应并返回凭证
+func (rp RelyingParty) VerifyRegistration(resp *CredentialCreationResponse, challenge string) (*Credential, error) {
+	clientData, err := decodeB64(resp.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyClientData(clientData, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+	raw, err := decodeB64(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	var att attestationObject
+	if err := codec.NewDecoderBytes(raw, &codec.CborHandle{}).Decode(&att); err != nil {
+		return nil, fmt.Errorf("webauthn: invalid attestation object: %w", err)
+	}
+	ad, err := parseAuthenticatorData(att.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthData(ad); err != nil {
+		return nil, err
+	}
+	if ad.flags&flagAttested == 0 || len(ad.credID) == 0 {
+		return nil, errors.New("webauthn: attested credential data missing")
+	}
+	pub, alg, err := parseCOSEKey(ad.credKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{
+		ID:        base64.RawURLEncoding.EncodeToString(ad.credID),
+		PublicKey: pub,
+		Algorithm: alg,
+		SignCount: ad.signCount,
+		AAGUID:    ad.aaguid,
+	}, nil
+}
+
+// VerifyAssertion 校验登录断言，返回新的签名计数
+func (rp RelyingParty) VerifyAssertion(resp *CredentialAssertionResponse, challenge string, cred *Credential) (uint32, error) {
+	clientData, err := decodeB64(resp.Response.ClientDataJSON)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyClientData(clientData, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	rawAuth, err := decodeB64(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	ad, err := parseAuthenticatorData(rawAuth)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthData(ad); err != nil {
+		return 0, err
+	}
+	sig, err := decodeB64(resp.Response.Signature)
+	if err != nil {
+		return 0, err
+	}
+	clientHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, rawAuth...), clientHash[:]...))
+	switch pub := cred.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			return 0, ErrBadSignature
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return 0, ErrBadSignature
+		}
+	default:
+		return 0, errors.New("webauthn: unsupported credential key")
+	}
+	// 计数为0表示认证器不支持计数；否则必须递增
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+	return ad.signCount, nil
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, typ, challenge string) error {
+	var cd collectedClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("webauthn: unexpected client data type %q", cd.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimRight(cd.Challenge, "=")), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+	for _, o := range rp.Origins {
+		if cd.Origin == o {
+			return nil
+		}
+	}
+	return ErrOriginNotAllowed
+}
+
+func (rp RelyingParty) verifyAuthData(ad *authenticatorData) error {
+	want := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.rpIDHash, want[:]) {
+		return ErrRPIDMismatch
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	return nil
+}
+
+func parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, errors.New("webauthn: authenticator data too short")
+	}
+	ad := &authenticatorData{rpIDHash: b[:32], flags: b[32], signCount: binary.BigEndian.Uint32(b[33:37])}
+	if ad.flags&flagAttested == 0 {
+		return ad, nil
+	}
+	if len(b) < 55 {
+		return nil, errors.New("webauthn: attested credential data too short")
+	}
+	ad.aaguid = b[37:53]
+	n := int(binary.BigEndian.Uint16(b[53:55]))
+	if len(b) < 55+n {
+		return nil, errors.New("webauthn: credential id truncated")
+	}
+	ad.credID = b[55 : 55+n]
+	ad.credKey = b[55+n:]
+	return ad, nil
+}
+
+// parseCOSEKey 解析 COSE_Key（RFC 8152）为公钥
+func parseCOSEKey(b []byte) (crypto.PublicKey, int, error) {
+	var m map[int64]interface{}
+	if err := codec.NewDecoderBytes(b, &codec.CborHandle{}).Decode(&m); err != nil {
+		return nil, 0, fmt.Errorf("webauthn: invalid COSE key: %w", err)
+	}
+	kty, _ := toInt(m[1])
+	alg, _ := toInt(m[3])
+	switch {
+	case kty == 2 && alg == AlgES256:
+		crv, _ := toInt(m[-1])
+		x, _ := m[-2].([]byte)
+		y, _ := m[-3].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("webauthn: unsupported EC2 key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, errors.New("webauthn: EC point not on curve")
+		}
+		return pub, AlgES256, nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := m[-1].([]byte)
+		e, _ := m[-2].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("webauthn: invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, AlgRS256, nil
+	}
+	return nil, 0, fmt.Errorf("webauthn: unsupported key type kty=%d alg=%d", kty, alg)
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func decodeB64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid base64url: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,112 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+// fakeAuthenticator 模拟一个 ES256 安全密钥
+type fakeAuthenticator struct {
+	key    *ecdsa.PrivateKey
+	credID []byte
+	count  uint32
+}
+
+func newFakeAuthenticator(t *testing.T) *fakeAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &fakeAuthenticator{key: key, credID: []byte("credential-0001")}
+}
+
+func cborBytes(t *testing.T, v interface{}) []byte {
+	var out []byte
+	require.NoError(t, codec.NewEncoderBytes(&out, &codec.CborHandle{}).Encode(v))
+	return out
+}
+
+func (a *fakeAuthenticator) authData(rpID string, attested bool, t *testing.T) []byte {
+	h := sha256.Sum256([]byte(rpID))
+	b := append([]byte{}, h[:]...)
+	flags := byte(flagUserPresent | flagUserVerified)
+	if attested {
+		flags |= flagAttested
+	}
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, a.count)
+	if attested {
+		b = append(b, make([]byte, 16)...)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a.credID)))
+		b = append(b, a.credID...)
+		x := a.key.X.FillBytes(make([]byte, 32))
+		y := a.key.Y.FillBytes(make([]byte, 32))
+		b = append(b, cborBytes(t, map[int64]interface{}{1: 2, 3: AlgES256, -1: 1, -2: x, -3: y})...)
+	}
+	return b
+}
+
+func clientData(t *testing.T, typ, challenge, origin string) []byte {
+	b, err := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": origin})
+	require.NoError(t, err)
+	return b
+}
+
+func enc(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := RelyingParty{ID: "console.example.com", Name: "KYC", Origins: []string{"https://console.example.com"}}
+	auth := newFakeAuthenticator(t)
+
+	regChallenge, err := NewChallenge()
+	require.NoError(t, err)
+	var reg CredentialCreationResponse
+	reg.Response.ClientDataJSON = enc(clientData(t, "webauthn.create", regChallenge, "https://console.example.com"))
+	reg.Response.AttestationObject = enc(cborBytes(t, map[string]interface{}{
+		"fmt": "none", "attStmt": map[string]interface{}{}, "authData": auth.authData(rp.ID, true, t),
+	}))
+	cred, err := rp.VerifyRegistration(&reg, regChallenge)
+	require.NoError(t, err)
+	assert.Equal(t, enc(auth.credID), cred.ID)
+	assert.Equal(t, AlgES256, cred.Algorithm)
+
+	assertion := func(challenge, origin string) *CredentialAssertionResponse {
+		auth.count++
+		ad := auth.authData(rp.ID, false, t)
+		cd := clientData(t, "webauthn.get", challenge, origin)
+		ch := sha256.Sum256(cd)
+		digest := sha256.Sum256(append(append([]byte{}, ad...), ch[:]...))
+		sig, err := ecdsa.SignASN1(rand.Reader, auth.key, digest[:])
+		require.NoError(t, err)
+		var r CredentialAssertionResponse
+		r.Response.ClientDataJSON = enc(cd)
+		r.Response.AuthenticatorData = enc(ad)
+		r.Response.Signature = enc(sig)
+		return &r
+	}
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	count, err := rp.VerifyAssertion(assertion(challenge, "https://console.example.com"), challenge, cred)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+	cred.SignCount = count
+
+	_, err = rp.VerifyAssertion(assertion("other", "https://console.example.com"), challenge, cred)
+	assert.ErrorIs(t, err, ErrChallengeMismatch)
+	_, err = rp.VerifyAssertion(assertion(challenge, "https://evil.example.com"), challenge, cred)
+	assert.ErrorIs(t, err, ErrOriginNotAllowed)
+
+	// 签名计数回退视为克隆的认证器
+	auth.count = 0
+	_, err = rp.VerifyAssertion(assertion(challenge, "https://console.example.com"), challenge, cred)
+	assert.ErrorIs(t, err, ErrSignCount)
+}
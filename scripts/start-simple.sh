@@ -21,6 +21,11 @@ export KYC_REDIS_PASSWORD=""
 export KYC_REDIS_DB=0
 export KYC_SECURITY_JWT_SECRET="your-secret-key-here-must-be-32-by"
 export KYC_SECURITY_ENCRYPTION_KEY="your-encryption-key-here-32-by"
+# 存储加密主密钥不写入脚本，需预先设置（openssl rand -base64 32），且不能与 KYC_SECURITY_ENCRYPTION_KEY 相同
+if [ -z "$KYC_STORAGE_ENCRYPTION_MASTER_KEY" ]; then
+    echo "❌ 请先设置环境变量 KYC_STORAGE_ENCRYPTION_MASTER_KEY（openssl rand -base64 32）"
+    exit 1
+fi
 
 # 检查端口是否被占用
 if lsof -Pi :8083 -sTCP:LISTEN -t >/dev/null ; then